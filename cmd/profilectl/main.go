@@ -0,0 +1,10 @@
+package main
+
+import (
+	"dev/profileSaver/internal/ctl"
+	"os"
+)
+
+func main() {
+	os.Exit(ctl.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
@@ -5,12 +5,15 @@ import (
 	"dev/profileSaver/internal/app"
 	"dev/profileSaver/internal/config"
 	"log"
+	"os"
 )
 
-var cfg config.Config
+var reloader *config.Reloader
 
 func init() {
-	err := cfg.InitCfg()
+	var err error
+
+	reloader, err = config.NewReloader(os.Getenv(config.EnvConfigFile))
 	if err != nil {
 		panic(err)
 	}
@@ -23,7 +26,7 @@ func init() {
 
 // @securityDefinitions.basic BasicAuth
 func main() {
-	err := app.Run(cfg)
+	err := app.Run(reloader)
 	if err != nil {
 		log.Println(err)
 	}
@@ -0,0 +1,192 @@
+// Package apikey issues and checks the API keys machines authenticate with.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// Prefix starts every key, so leaked keys are easy to scan for.
+	Prefix = "psk_"
+	// Scheme is the Authorization header scheme carrying a key.
+	Scheme = "ApiKey"
+)
+
+// Scopes limit what a key may do on top of what its user may do. Besides
+// read and write a key can carry the permissions groups grant.
+const (
+	// ScopeRead allows GET and HEAD requests.
+	ScopeRead = "read"
+	// ScopeWrite allows requests of any method.
+	ScopeWrite = "write"
+)
+
+// touchInterval limits how often LastUsedAt is written back to the store.
+const touchInterval = time.Minute
+
+var (
+	ErrInvalid      = errors.New("invalid or expired api key")
+	ErrNotFound     = errors.New("api key not found")
+	ErrUnknownScope = errors.New("unknown scope")
+)
+
+// Scopes lists every scope a key can carry.
+func Scopes() []string {
+	return append([]string{ScopeRead, ScopeWrite}, model.Permissions...)
+}
+
+type Manager struct {
+	repo repository.APIKeyRepository
+	now  func() time.Time
+}
+
+func NewManager(repo repository.APIKeyRepository) *Manager {
+	return &Manager{
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+// ID returns the stored ID of key.
+func ID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue creates a key for userID and returns it. Only its hash and prefix are
+// stored. A zero expiresAt never expires.
+func (m *Manager) Issue(ctx context.Context, userID, name string, scopes []string, expiresAt time.Time) (string, model.APIKey, error) {
+	for _, s := range scopes {
+		if !known(s) {
+			return "", model.APIKey{}, fmt.Errorf("%w %q", ErrUnknownScope, s)
+		}
+	}
+
+	public := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(public); err != nil {
+		return "", model.APIKey{}, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", model.APIKey{}, err
+	}
+
+	prefix := Prefix + hex.EncodeToString(public)
+	key := prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+
+	k := model.APIKey{
+		ID:        ID(key),
+		UserID:    userID,
+		Prefix:    prefix,
+		Name:      name,
+		Scopes:    scopes,
+		CreatedAt: m.now(),
+		ExpiresAt: expiresAt,
+	}
+
+	if err := m.repo.CreateAPIKey(ctx, k); err != nil {
+		return "", model.APIKey{}, err
+	}
+
+	return key, k, nil
+}
+
+// Authenticate returns the live key for key and records its use. Unknown and
+// expired keys are ErrInvalid.
+func (m *Manager) Authenticate(ctx context.Context, key string) (model.APIKey, error) {
+	if !strings.HasPrefix(key, Prefix) {
+		return model.APIKey{}, ErrInvalid
+	}
+
+	k, err := m.repo.GetAPIKey(ctx, ID(key))
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return model.APIKey{}, ErrInvalid
+	}
+	if err != nil {
+		return model.APIKey{}, err
+	}
+
+	now := m.now()
+	if Expired(k, now) {
+		return model.APIKey{}, ErrInvalid
+	}
+
+	if now.Sub(k.LastUsedAt) >= touchInterval {
+		k.LastUsedAt = now
+		if err = m.repo.TouchAPIKey(ctx, k.ID, now); err != nil {
+			return model.APIKey{}, err
+		}
+	}
+
+	return k, nil
+}
+
+// List returns the keys of userID, expired ones included, newest first.
+func (m *Manager) List(ctx context.Context, userID string) []model.APIKey {
+	return m.repo.GetAPIKeysByUser(ctx, userID)
+}
+
+// Revoke deletes key id if it belongs to userID.
+func (m *Manager) Revoke(ctx context.Context, userID, id string) error {
+	k, err := m.repo.GetAPIKey(ctx, id)
+	if errors.Is(err, repository.ErrAPIKeyNotFound) || err == nil && k.UserID != userID {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return m.repo.DeleteAPIKey(ctx, id)
+}
+
+// Expired reports whether k has expired at now.
+func Expired(k model.APIKey, now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// Allows reports whether k may make a request with method.
+func Allows(k model.APIKey, method string) bool {
+	if has(k, ScopeWrite) {
+		return true
+	}
+
+	return has(k, ScopeRead) && (method == http.MethodGet || method == http.MethodHead)
+}
+
+// Grants reports whether k carries permission. The admin scope carries all
+// permissions.
+func Grants(k model.APIKey, permission string) bool {
+	return has(k, permission) || has(k, model.PermissionAdmin)
+}
+
+func has(k model.APIKey, scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+func known(scope string) bool {
+	for _, s := range Scopes() {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,78 @@
+package apikey
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.New()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m := NewManager(repo)
+	m.now = func() time.Time { return now }
+
+	key, k, err := m.Issue(ctx, "u1", "batch", []string{ScopeRead}, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, k.Prefix+"_"))
+	assert.True(t, strings.HasPrefix(k.Prefix, Prefix))
+	assert.Equal(t, ID(key), k.ID)
+
+	stored, err := repo.GetAPIKey(ctx, k.ID)
+	require.NoError(t, err)
+	assert.NotContains(t, stored.ID, key, "only the hash is stored")
+
+	got, err := m.Authenticate(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, now, got.LastUsedAt)
+
+	_, err = m.Authenticate(ctx, key+"x")
+	assert.Equal(t, ErrInvalid, err)
+	_, err = m.Authenticate(ctx, "Basic abc")
+	assert.Equal(t, ErrInvalid, err)
+
+	now = now.Add(time.Hour)
+	_, err = m.Authenticate(ctx, key)
+	assert.Equal(t, ErrInvalid, err, "expired")
+	assert.Len(t, m.List(ctx, "u1"), 1, "expired keys are still listed")
+
+	assert.Equal(t, ErrNotFound, m.Revoke(ctx, "u2", k.ID))
+	require.NoError(t, m.Revoke(ctx, "u1", k.ID))
+	assert.Empty(t, m.List(ctx, "u1"))
+
+	_, _, err = m.Issue(ctx, "u1", "bad", []string{"root"}, time.Time{})
+	assert.ErrorIs(t, err, ErrUnknownScope)
+}
+
+func TestScopes(t *testing.T) {
+	tests := []struct {
+		name      string
+		scopes    []string
+		method    string
+		allowed   bool
+		userWrite bool
+	}{
+		{name: "READ_GET", scopes: []string{ScopeRead}, method: "GET", allowed: true},
+		{name: "READ_POST", scopes: []string{ScopeRead}, method: "POST", allowed: false},
+		{name: "WRITE_DELETE", scopes: []string{ScopeWrite}, method: "DELETE", allowed: true},
+		{name: "NONE", scopes: nil, method: "GET", allowed: false},
+		{name: "PERMISSION", scopes: []string{ScopeWrite, model.PermissionUserWrite}, method: "POST", allowed: true, userWrite: true},
+		{name: "ADMIN", scopes: []string{ScopeWrite, model.PermissionAdmin}, method: "POST", allowed: true, userWrite: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			k := model.APIKey{Scopes: test.scopes}
+
+			assert.Equal(t, test.allowed, Allows(k, test.method))
+			assert.Equal(t, test.userWrite, Grants(k, model.PermissionUserWrite))
+		})
+	}
+}
@@ -2,11 +2,25 @@ package app
 
 import (
 	"context"
+	"dev/profileSaver/internal/apikey"
+	"dev/profileSaver/internal/authn"
+	"dev/profileSaver/internal/authn/ldap"
+	"dev/profileSaver/internal/bootstrap"
 	"dev/profileSaver/internal/config"
 	controller "dev/profileSaver/internal/controller/v1"
-	"dev/profileSaver/internal/model"
+	controllerv2 "dev/profileSaver/internal/controller/v2"
+	"dev/profileSaver/internal/federation"
+	"dev/profileSaver/internal/idempotency"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/notify"
+	"dev/profileSaver/internal/oauth"
+	"dev/profileSaver/internal/oidc"
 	"dev/profileSaver/internal/repository"
 	"dev/profileSaver/internal/server"
+	"dev/profileSaver/internal/session"
+	"dev/profileSaver/internal/token"
+	"dev/profileSaver/internal/tracing"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"os"
 	"os/signal"
@@ -14,46 +28,214 @@ import (
 	"time"
 )
 
-func Run(cfg config.Config) error {
+func Run(reloader *config.Reloader) error {
 	var err error
-	repo := repository.New()
-	repo.CreateUser(model.User{
-		Email:    "admin",
-		Username: "admin",
-		Password: "admin",
-		Admin:    true,
+
+	cfg := reloader.Live().Get()
+
+	l, err := logger.New(cfg.Log)
+	if err != nil {
+		return err
+	}
+	log.Logger = l
+	zerolog.DefaultContextLogger = &log.Logger
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error().Err(err).Msg("unable to flush traces")
+		}
+	}()
+
+	reloader.OnChange(func(old, new config.Config) {
+		if old.Log.Level != new.Log.Level {
+			if err := logger.SetLevel(new.Log.Level); err != nil {
+				log.Error().Err(err).Msg("unable to change log level")
+			}
+		}
 	})
+	reloader.Watch(logReload)
+
+	store, err := repository.Open(cfg.Storage,
+		repository.WithHashParams(repository.HashParamsFromConfig(cfg.Hashing)),
+	)
+	if err != nil {
+		return err
+	}
+
+	repo := repository.NewTraced(store)
+
+	err = bootstrap.Run(context.Background(), repo, cfg, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	sessionStore, err := session.OpenStore(cfg.Session, store)
+	if err != nil {
+		return err
+	}
+	sessions := session.NewManager(sessionStore, cfg.Session)
+
+	idempotencyStore, err := idempotency.OpenStore(cfg.Idempotency, store)
+	if err != nil {
+		return err
+	}
+	idempotencyKeys := idempotency.NewManager(idempotencyStore, cfg.Idempotency)
+
+	tokens := token.NewManager(store)
+	oauthServer := oauth.NewServer(store, cfg.OAuth)
+	provider := oidc.NewProvider(store, cfg.OIDC)
+
+	notifier, err := notify.Open(cfg.Notify)
+	if err != nil {
+		return err
+	}
+
+	authenticator, err := newAuthenticator(cfg.LDAP, repo, store)
+	if err != nil {
+		return err
+	}
 
-	handler := controller.New(repo)
+	pruneCtx, stopPrune := context.WithCancel(context.Background())
+	defer stopPrune()
+	go pruneExpired(pruneCtx, sessions, tokens, oauthServer, provider, idempotencyKeys)
+
+	handler := controller.New(repo,
+		controller.WithConfig(reloader.Live()),
+		controller.WithSessions(sessions),
+		controller.WithTOTP(store),
+		controller.WithTokens(tokens),
+		controller.WithNotifier(notifier),
+		controller.WithTenants(store),
+		controller.WithGroups(store),
+		controller.WithAPIKeys(apikey.NewManager(store)),
+		controller.WithOAuth(oauthServer),
+		controller.WithOIDC(provider),
+		controller.WithFederation(federation.NewManager(store, cfg.Federation.Connectors, nil)),
+		controller.WithAuthenticator(authenticator),
+		controller.WithIdempotency(idempotencyKeys),
+	)
 
 	srv := new(server.Server)
+	grpcSrv := new(server.GRPCServer)
 	defer func() {
 		log.Info().Msg("App Shutting Down")
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 		defer cancel()
 
+		grpcSrv.Shutdown(ctx)
+
 		err = srv.Shutdown(ctx)
 		if err != nil {
-			log.Error().Err(err)
+			log.Error().Err(err).Msg("unable to shut down server")
 		}
 		log.Info().Msg("Server Stopped")
 	}()
 
-	errChan := make(chan error, 1)
+	errChan := make(chan error, 2)
 
 	go func() {
-		if err = srv.Run(cfg.Server.Port, handler.InitRouter()); err != nil {
+		if err = srv.Run(cfg.Server, handler.InitRouter(controllerv2.New(handler).Routes)); err != nil {
 			errChan <- err
 		}
 	}()
 
+	if cfg.GRPC.Enabled {
+		go func() {
+			if err := grpcSrv.Run(cfg.GRPC, cfg.Server.TLS, handler.InitGRPC); err != nil {
+				errChan <- err
+			}
+		}()
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
-	select {
-	case <-quit:
-	case err = <-errChan:
-		return err
+	for {
+		select {
+		case <-hup:
+			logReload(reloader.Reload())
+		case <-quit:
+			return nil
+		case err = <-errChan:
+			return err
+		}
+	}
+}
+
+// pruneExpired drops expired sessions, tokens, signing keys and kept answers
+// every few minutes until ctx is done. Signing keys that are due also rotate
+// here.
+func pruneExpired(ctx context.Context, sessions *session.Manager, tokens *token.Manager, oauthServer *oauth.Server, provider *oidc.Provider, idempotencyKeys *idempotency.Manager) {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := sessions.Prune(ctx); err != nil {
+				log.Error().Err(err).Msg("unable to prune sessions")
+			}
+			if err := tokens.Prune(ctx); err != nil {
+				log.Error().Err(err).Msg("unable to prune tokens")
+			}
+			if err := oauthServer.Prune(ctx); err != nil {
+				log.Error().Err(err).Msg("unable to prune oauth tokens")
+			}
+			if err := provider.Prune(ctx); err != nil {
+				log.Error().Err(err).Msg("unable to prune signing keys")
+			}
+			if err := idempotencyKeys.Prune(ctx); err != nil {
+				log.Error().Err(err).Msg("unable to prune idempotency records")
+			}
+		}
+	}
+}
+
+func logReload(changes, ignored []string, err error) {
+	if err != nil {
+		log.Error().Err(err).Msg("config reload rejected")
+		return
+	}
+
+	if len(ignored) != 0 {
+		log.Warn().Strs("ignored", ignored).Msg("config changes that require a restart were ignored")
+	}
+
+	if len(changes) == 0 {
+		log.Debug().Msg("config reloaded without changes")
+		return
+	}
+
+	log.Info().Strs("changes", changes).Msg("config reloaded")
+}
+
+// newAuthenticator checks passwords against the directory when LDAP is
+// enabled, falling back to local users if cfg allows it.
+func newAuthenticator(cfg config.LDAP, repo repository.Repository, store ldap.Repository) (authn.Authenticator, error) {
+	local := authn.NewLocal(repo)
+	if !cfg.Enabled {
+		return local, nil
+	}
+
+	directory, err := ldap.New(store, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Fallback {
+		return directory, nil
 	}
 
-	return nil
+	return authn.Chain{directory, local}, nil
 }
@@ -0,0 +1,67 @@
+// Package authn checks the username and password a user logs in with,
+// against local users or an external directory.
+package authn
+
+import (
+	"context"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"errors"
+)
+
+// ErrInvalidCredentials is returned for a wrong username or password. Other
+// errors mean the credentials couldn't be checked.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Authenticator checks credentials in the tenant of ctx and returns the local
+// user they belong to.
+type Authenticator interface {
+	Authenticate(ctx context.Context, username, password string) (model.User, error)
+}
+
+// Local checks passwords of the users in the repository.
+type Local struct {
+	repo repository.Repository
+}
+
+func NewLocal(repo repository.Repository) *Local {
+	return &Local{repo: repo}
+}
+
+func (l *Local) Authenticate(ctx context.Context, username, password string) (model.User, error) {
+	if !l.repo.IsAuthorized(ctx, username, password) {
+		return model.User{}, ErrInvalidCredentials
+	}
+
+	user, err := l.repo.GetUserByName(ctx, username)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return model.User{}, ErrInvalidCredentials
+	}
+
+	return user, err
+}
+
+// Chain asks each authenticator in turn until one accepts the credentials.
+// When none does, the error of a failing authenticator wins over
+// ErrInvalidCredentials, so an unreachable directory isn't reported as a
+// wrong password.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(ctx context.Context, username, password string) (model.User, error) {
+	err := ErrInvalidCredentials
+
+	for _, a := range c {
+		user, e := a.Authenticate(ctx, username, password)
+		if e == nil {
+			return user, nil
+		}
+
+		if !errors.Is(e, ErrInvalidCredentials) {
+			logger.FromContext(ctx).Warn().Err(e).Msg("authenticator failed, trying the next one")
+			err = e
+		}
+	}
+
+	return model.User{}, err
+}
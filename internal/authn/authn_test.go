@@ -0,0 +1,76 @@
+package authn
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type authFunc func(ctx context.Context, username, password string) (model.User, error)
+
+func (f authFunc) Authenticate(ctx context.Context, username, password string) (model.User, error) {
+	return f(ctx, username, password)
+}
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.New(repository.WithHashParams(repository.HashParams{
+		Time: 1, Memory: 64, Threads: 1, KeyLen: 16, SaltLen: 8,
+	}))
+	_, err := repo.CreateUser(ctx, model.User{Username: "bob", Password: "bob-password"})
+	require.NoError(t, err)
+
+	user, err := NewLocal(repo).Authenticate(ctx, "bob", "bob-password")
+	require.NoError(t, err)
+	assert.Equal(t, "bob", user.Username)
+
+	_, err = NewLocal(repo).Authenticate(ctx, "bob", "nope")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = NewLocal(repo).Authenticate(ctx, "nobody", "nope")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestChain(t *testing.T) {
+	ctx := context.Background()
+	down := errors.New("directory unreachable")
+
+	refuse := authFunc(func(context.Context, string, string) (model.User, error) {
+		return model.User{}, ErrInvalidCredentials
+	})
+	fail := authFunc(func(context.Context, string, string) (model.User, error) {
+		return model.User{}, down
+	})
+	accept := authFunc(func(_ context.Context, username, _ string) (model.User, error) {
+		return model.User{Username: username}, nil
+	})
+
+	tests := []struct {
+		name  string
+		chain Chain
+		err   error
+	}{
+		{name: "FIRST", chain: Chain{accept, fail}},
+		{name: "FALLBACK", chain: Chain{refuse, accept}},
+		{name: "FALLBACK_WHEN_DOWN", chain: Chain{fail, accept}},
+		{name: "NOT_OK", chain: Chain{refuse, refuse}, err: ErrInvalidCredentials},
+		{name: "NOT_OK_DOWN", chain: Chain{fail, refuse}, err: down},
+		{name: "EMPTY", chain: Chain{}, err: ErrInvalidCredentials},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			user, err := test.chain.Authenticate(ctx, "bob", "pw")
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "bob", user.Username)
+		})
+	}
+}
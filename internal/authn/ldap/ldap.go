@@ -0,0 +1,332 @@
+// Package ldap checks passwords against an LDAP directory. The entry of a
+// username is searched with a service account and bound to with the
+// password; the user's groups decide their role. Directory accounts are
+// linked to local users, created at the first login.
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"dev/profileSaver/internal/authn"
+	"dev/profileSaver/internal/bootstrap"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/tenant"
+	"errors"
+	"fmt"
+	goldap "github.com/go-ldap/ldap/v3"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Repository is what the authenticator needs of the store.
+type Repository interface {
+	repository.Repository
+	repository.IdentityRepository
+}
+
+// account is what the directory knows about a user.
+type account struct {
+	dn       string
+	username string
+	email    string
+	groups   []string
+}
+
+type Authenticator struct {
+	repo      Repository
+	cfg       config.LDAP
+	tlsConfig *tls.Config
+	now       func() time.Time
+}
+
+// New returns an authenticator for the directory of cfg, failing if its CA
+// file can't be read.
+func New(repo Repository, cfg config.LDAP) (*Authenticator, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("ldap.ca_file: %w", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ldap.ca_file: no certificates in %s", cfg.CAFile)
+		}
+	}
+
+	return &Authenticator{
+		repo:      repo,
+		cfg:       cfg,
+		tlsConfig: tlsConfig,
+		now:       time.Now,
+	}, nil
+}
+
+// Authenticate checks the password of username in the directory, when ctx
+// names the tenant of the directory, and returns the local user linked to
+// the account.
+func (a *Authenticator) Authenticate(ctx context.Context, username, password string) (model.User, error) {
+	// An empty password would be an unauthenticated bind, which succeeds.
+	if tenant.FromContext(ctx) != a.cfg.Tenant || username == "" || password == "" {
+		return model.User{}, authn.ErrInvalidCredentials
+	}
+
+	acc, err := a.lookup(username, password)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	admin, allowed := a.role(acc.groups)
+	if !allowed {
+		logger.FromContext(ctx).Info().Str("dn", acc.dn).Msg("ldap account is in no mapped group")
+		return model.User{}, authn.ErrInvalidCredentials
+	}
+
+	return a.user(ctx, acc, admin)
+}
+
+// lookup finds the entry of username and binds to it with password.
+func (a *Authenticator) lookup(username, password string) (account, error) {
+	conn, err := a.dial()
+	if err != nil {
+		return account{}, err
+	}
+	defer conn.Close()
+
+	if err = a.serviceBind(conn); err != nil {
+		return account{}, err
+	}
+
+	res, err := conn.Search(goldap.NewSearchRequest(
+		a.cfg.BaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases, 2, a.timeLimit(), false,
+		fmt.Sprintf(a.cfg.UserFilter, goldap.EscapeFilter(username)),
+		[]string{a.cfg.UsernameAttribute, a.cfg.EmailAttribute}, nil,
+	))
+	if goldap.IsErrorWithCode(err, goldap.LDAPResultSizeLimitExceeded) || err == nil && len(res.Entries) > 1 {
+		return account{}, fmt.Errorf("ldap: more than one entry for %q", username)
+	}
+	if err != nil {
+		return account{}, fmt.Errorf("ldap: user search: %w", err)
+	}
+	if len(res.Entries) == 0 {
+		return account{}, authn.ErrInvalidCredentials
+	}
+
+	entry := res.Entries[0]
+	if err = conn.Bind(entry.DN, password); goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+		return account{}, authn.ErrInvalidCredentials
+	} else if err != nil {
+		return account{}, fmt.Errorf("ldap: user bind: %w", err)
+	}
+
+	acc := account{
+		dn:       entry.DN,
+		username: entry.GetEqualFoldAttributeValue(a.cfg.UsernameAttribute),
+		email:    entry.GetEqualFoldAttributeValue(a.cfg.EmailAttribute),
+	}
+	if acc.username == "" {
+		acc.username = username
+	}
+
+	// Groups are searched as the service account again, users may not be
+	// allowed to.
+	if err = a.serviceBind(conn); err != nil {
+		return account{}, err
+	}
+
+	base := a.cfg.GroupBaseDN
+	if base == "" {
+		base = a.cfg.BaseDN
+	}
+	res, err = conn.Search(goldap.NewSearchRequest(
+		base, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases, 0, a.timeLimit(), false,
+		fmt.Sprintf(a.cfg.GroupFilter, goldap.EscapeFilter(entry.DN)),
+		[]string{"dn"}, nil,
+	))
+	if err != nil {
+		return account{}, fmt.Errorf("ldap: group search: %w", err)
+	}
+	for _, g := range res.Entries {
+		acc.groups = append(acc.groups, g.DN)
+	}
+
+	return acc, nil
+}
+
+func (a *Authenticator) dial() (*goldap.Conn, error) {
+	conn, err := goldap.DialURL(a.cfg.URL,
+		goldap.DialWithDialer(&net.Dialer{Timeout: a.cfg.Timeout}),
+		goldap.DialWithTLSConfig(a.tlsConfig),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: %w", err)
+	}
+	conn.SetTimeout(a.cfg.Timeout)
+
+	if a.cfg.StartTLS {
+		if err = conn.StartTLS(a.tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap: starttls: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+func (a *Authenticator) serviceBind(conn *goldap.Conn) error {
+	if a.cfg.BindDN == "" {
+		return nil
+	}
+
+	if err := conn.Bind(a.cfg.BindDN, a.cfg.BindPassword); err != nil {
+		return fmt.Errorf("ldap: service account bind: %w", err)
+	}
+
+	return nil
+}
+
+// role returns whether groups make the user an admin and whether they may
+// log in at all.
+func (a *Authenticator) role(groups []string) (admin, allowed bool) {
+	if len(a.cfg.GroupRoles) == 0 {
+		return false, true
+	}
+
+	for _, gr := range a.cfg.GroupRoles {
+		for _, g := range groups {
+			if strings.EqualFold(gr.DN, g) {
+				allowed = true
+				admin = admin || gr.Role == config.RoleAdmin
+			}
+		}
+	}
+
+	return admin, allowed
+}
+
+// user returns the local user linked to acc: the one linked before, else the
+// one with the same username if link_existing allows it, else a new one.
+func (a *Authenticator) user(ctx context.Context, acc account, admin bool) (model.User, error) {
+	subject := strings.ToLower(acc.dn)
+
+	ident, err := a.repo.GetIdentity(ctx, config.ConnectorLDAP, subject)
+	if err == nil {
+		user, err := a.repo.GetUserByID(ctx, ident.UserID)
+		if err != nil {
+			return model.User{}, err
+		}
+
+		ident.Email = acc.email
+		ident.LastLoginAt = a.now()
+		if err = a.repo.UpdateIdentity(ctx, ident); err != nil {
+			return model.User{}, err
+		}
+
+		return a.sync(ctx, user, acc, admin)
+	}
+	if !errors.Is(err, repository.ErrIdentityNotFound) {
+		return model.User{}, err
+	}
+
+	ident = model.Identity{
+		Connector:   config.ConnectorLDAP,
+		Subject:     subject,
+		Email:       acc.email,
+		LinkedAt:    a.now(),
+		LastLoginAt: a.now(),
+	}
+
+	user, err := a.repo.GetUserByName(ctx, acc.username)
+	switch {
+	case err == nil:
+		if !a.cfg.LinkExisting || user.ServiceAccount {
+			logger.FromContext(ctx).Warn().Str("dn", acc.dn).
+				Msg("ldap account matches a local user it isn't linked to, set ldap.link_existing to link them")
+			return model.User{}, authn.ErrInvalidCredentials
+		}
+		if user, err = a.sync(ctx, user, acc, admin); err != nil {
+			return model.User{}, err
+		}
+	case errors.Is(err, repository.ErrUserNotFound):
+		if user, err = a.provision(ctx, acc, admin); err != nil {
+			return model.User{}, err
+		}
+		ident.Provisioned = true
+	default:
+		return model.User{}, err
+	}
+
+	ident.UserID = user.ID
+	if err = a.repo.CreateIdentity(ctx, ident); err != nil {
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
+func (a *Authenticator) provision(ctx context.Context, acc account, admin bool) (model.User, error) {
+	// Nobody knows the local password, the directory checks it.
+	password, err := bootstrap.GeneratePassword()
+	if err != nil {
+		return model.User{}, err
+	}
+
+	return a.repo.CreateUser(ctx, model.User{
+		Username: acc.username,
+		Password: password,
+		Email:    acc.email,
+		// The directory is trusted with the addresses it holds.
+		EmailVerified: acc.email != "",
+		Admin:         admin,
+		Tenant:        a.cfg.Tenant,
+	})
+}
+
+// sync updates user with the email and, if groups are mapped, the role the
+// directory holds.
+func (a *Authenticator) sync(ctx context.Context, user model.User, acc account, admin bool) (model.User, error) {
+	changed := false
+	if acc.email != "" && (user.Email != acc.email || !user.EmailVerified) {
+		user.Email, user.EmailVerified = acc.email, true
+		changed = true
+	}
+	if len(a.cfg.GroupRoles) > 0 && user.Admin != admin {
+		user.Admin = admin
+		changed = true
+	}
+
+	if !changed {
+		return user, nil
+	}
+
+	// Keeps the password hash.
+	update := user
+	update.Password = ""
+	if err := a.repo.UpdateUser(ctx, update); err != nil {
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
+func (a *Authenticator) timeLimit() int {
+	return int(a.cfg.Timeout / time.Second)
+}
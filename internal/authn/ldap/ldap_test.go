@@ -0,0 +1,243 @@
+package ldap
+
+import (
+	"context"
+	"dev/profileSaver/internal/authn"
+	"dev/profileSaver/internal/authn/ldap/ldaptest"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	aliceDN = "uid=alice,ou=people,dc=corp,dc=test"
+	bobDN   = "uid=bob,ou=people,dc=corp,dc=test"
+)
+
+var directory = []ldaptest.Entry{
+	{DN: "cn=svc,dc=corp,dc=test", Password: "svc-password"},
+	{DN: aliceDN, Password: "alice-password", Attributes: map[string][]string{
+		"objectClass": {"person"}, "uid": {"alice"}, "mail": {"alice@corp.test"},
+	}},
+	{DN: bobDN, Password: "bob-password", Attributes: map[string][]string{
+		"objectClass": {"person"}, "uid": {"bob"}, "mail": {"bob@corp.test"},
+	}},
+	{DN: "uid=carol,ou=people,dc=corp,dc=test", Password: "carol-password", Attributes: map[string][]string{
+		"objectClass": {"person"}, "uid": {"carol"},
+	}},
+	{DN: "uid=dave,ou=people,dc=corp,dc=test", Password: "dave-password", Attributes: map[string][]string{
+		"objectClass": {"person"}, "uid": {"dave"},
+	}},
+	{DN: "cn=admins,ou=groups,dc=corp,dc=test", Attributes: map[string][]string{
+		"objectClass": {"groupOfNames"}, "member": {aliceDN},
+	}},
+	{DN: "cn=staff,ou=groups,dc=corp,dc=test", Attributes: map[string][]string{
+		"objectClass": {"groupOfNames"}, "member": {aliceDN, bobDN, "uid=dave,ou=people,dc=corp,dc=test"},
+	}},
+}
+
+func newConfig(url string) config.LDAP {
+	return config.LDAP{
+		Enabled:           true,
+		URL:               url,
+		BindDN:            "cn=svc,dc=corp,dc=test",
+		BindPassword:      "svc-password",
+		BaseDN:            "ou=people,dc=corp,dc=test",
+		UserFilter:        "(&(objectClass=person)(uid=%s))",
+		UsernameAttribute: "uid",
+		EmailAttribute:    "mail",
+		GroupBaseDN:       "ou=groups,dc=corp,dc=test",
+		GroupFilter:       "(member=%s)",
+		GroupRoles: []config.GroupRole{
+			{DN: "cn=admins,ou=groups,dc=corp,dc=test", Role: config.RoleAdmin},
+			{DN: "cn=staff,ou=groups,dc=corp,dc=test", Role: config.RoleUser},
+		},
+		Timeout: 5 * time.Second,
+	}
+}
+
+func newRepo(t *testing.T) *repository.DB {
+	t.Helper()
+
+	return repository.New(repository.WithHashParams(repository.HashParams{
+		Time: 1, Memory: 64, Threads: 1, KeyLen: 16, SaltLen: 8,
+	}))
+}
+
+func caFile(t *testing.T, s *ldaptest.Server) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(path, s.CertPEM(), 0o600))
+
+	return path
+}
+
+func TestAuthenticator(t *testing.T) {
+	ctx := context.Background()
+	s := ldaptest.New(directory...)
+	defer s.Close()
+
+	repo := newRepo(t)
+	_, err := repo.CreateUser(ctx, model.User{Username: "dave", Password: "local-password"})
+	require.NoError(t, err)
+
+	a, err := New(repo, newConfig(s.URL))
+	require.NoError(t, err)
+
+	t.Run("OK", func(t *testing.T) {
+		alice, err := a.Authenticate(ctx, "alice", "alice-password")
+		require.NoError(t, err)
+		assert.Equal(t, "alice", alice.Username)
+		assert.Equal(t, "alice@corp.test", alice.Email)
+		assert.True(t, alice.EmailVerified)
+		assert.True(t, alice.Admin, "member of the admin group")
+
+		again, err := a.Authenticate(ctx, "ALICE", "alice-password")
+		require.NoError(t, err)
+		assert.Equal(t, alice.ID, again.ID)
+
+		identities := repo.GetIdentitiesByUser(ctx, alice.ID)
+		require.Len(t, identities, 1)
+		assert.Equal(t, config.ConnectorLDAP, identities[0].Connector)
+		assert.Equal(t, aliceDN, identities[0].Subject)
+		assert.True(t, identities[0].Provisioned)
+
+		bob, err := a.Authenticate(ctx, "bob", "bob-password")
+		require.NoError(t, err)
+		assert.False(t, bob.Admin)
+	})
+
+	t.Run("NOT_OK", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			username string
+			password string
+		}{
+			{name: "WRONG_PASSWORD", username: "alice", password: "nope"},
+			{name: "UNKNOWN_USER", username: "mallory", password: "nope"},
+			{name: "EMPTY_PASSWORD", username: "alice"},
+			{name: "FILTER_INJECTION", username: "*", password: "alice-password"},
+			{name: "NO_MAPPED_GROUP", username: "carol", password: "carol-password"},
+			{name: "UNLINKED_LOCAL_USER", username: "dave", password: "dave-password"},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				_, err := a.Authenticate(ctx, test.username, test.password)
+				assert.ErrorIs(t, err, authn.ErrInvalidCredentials)
+			})
+		}
+
+		_, err := a.Authenticate(tenant.WithID(ctx, "acme"), "alice", "alice-password")
+		assert.ErrorIs(t, err, authn.ErrInvalidCredentials, "the directory serves the default tenant")
+	})
+
+	t.Run("LINK_EXISTING", func(t *testing.T) {
+		cfg := newConfig(s.URL)
+		cfg.LinkExisting = true
+		a, err := New(repo, cfg)
+		require.NoError(t, err)
+
+		dave, err := a.Authenticate(ctx, "dave", "dave-password")
+		require.NoError(t, err)
+
+		local, err := repo.GetUserByName(ctx, "dave")
+		require.NoError(t, err)
+		assert.Equal(t, local.ID, dave.ID)
+		assert.True(t, repo.IsAuthorized(ctx, "dave", "local-password"), "the local password stays")
+	})
+
+	t.Run("ROLE_FOLLOWS_GROUPS", func(t *testing.T) {
+		cfg := newConfig(s.URL)
+		cfg.GroupRoles = cfg.GroupRoles[1:]
+		a, err := New(repo, cfg)
+		require.NoError(t, err)
+
+		alice, err := a.Authenticate(ctx, "alice", "alice-password")
+		require.NoError(t, err)
+		assert.False(t, alice.Admin, "the admin group is no longer mapped")
+	})
+
+	t.Run("BACKEND_ERRORS", func(t *testing.T) {
+		cfg := newConfig(s.URL)
+		cfg.BindPassword = "nope"
+		a, err := New(repo, cfg)
+		require.NoError(t, err)
+
+		_, err = a.Authenticate(ctx, "alice", "alice-password")
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, authn.ErrInvalidCredentials, "a broken service account isn't a wrong password")
+
+		down := ldaptest.New()
+		down.Close()
+		a, err = New(repo, newConfig(down.URL))
+		require.NoError(t, err)
+
+		_, err = a.Authenticate(ctx, "alice", "alice-password")
+		require.Error(t, err)
+		assert.NotErrorIs(t, err, authn.ErrInvalidCredentials)
+	})
+}
+
+func TestAuthenticator_TLS(t *testing.T) {
+	ctx := context.Background()
+
+	ldaps := ldaptest.NewTLS(directory...)
+	defer ldaps.Close()
+	plain := ldaptest.New(directory...)
+	defer plain.Close()
+
+	tests := []struct {
+		name string
+		cfg  func() config.LDAP
+		ok   bool
+	}{
+		{name: "LDAPS", ok: true, cfg: func() config.LDAP {
+			cfg := newConfig(ldaps.URL)
+			cfg.CAFile = caFile(t, ldaps)
+			return cfg
+		}},
+		{name: "START_TLS", ok: true, cfg: func() config.LDAP {
+			cfg := newConfig(plain.URL)
+			cfg.StartTLS = true
+			cfg.CAFile = caFile(t, plain)
+			return cfg
+		}},
+		{name: "NOT_OK_UNTRUSTED", cfg: func() config.LDAP {
+			return newConfig(ldaps.URL)
+		}},
+		{name: "NOT_OK_OTHER_CA", cfg: func() config.LDAP {
+			cfg := newConfig(plain.URL)
+			cfg.StartTLS = true
+			cfg.CAFile = caFile(t, ldaps)
+			return cfg
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := New(newRepo(t), test.cfg())
+			require.NoError(t, err)
+
+			_, err = a.Authenticate(ctx, "bob", "bob-password")
+			if test.ok {
+				assert.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			assert.NotErrorIs(t, err, authn.ErrInvalidCredentials)
+		})
+	}
+
+	_, err := New(newRepo(t), config.LDAP{URL: ldaps.URL, CAFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,382 @@
+// Package ldaptest is an in-process LDAP server for tests. It answers simple
+// binds, searches with equality, presence, and, or and not filters, and
+// StartTLS, over a fixed set of entries.
+package ldaptest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oidStartTLS names the StartTLS extended operation (RFC 4511).
+const oidStartTLS = "1.3.6.1.4.1.1466.20037"
+
+// Entry is an object in the directory.
+type Entry struct {
+	DN string
+	// Password is what a bind as DN succeeds with, empty for objects nobody
+	// binds as.
+	Password   string
+	Attributes map[string][]string
+}
+
+// Server listens on the loopback interface until closed.
+type Server struct {
+	// URL is ldap://127.0.0.1:port, or ldaps:// for NewTLS.
+	URL string
+
+	listener  net.Listener
+	tlsConfig *tls.Config
+	certPEM   []byte
+	entries   []Entry
+
+	mu    sync.Mutex
+	conns map[net.Conn]bool
+	wg    sync.WaitGroup
+}
+
+// New starts a server over plain TCP, which offers StartTLS.
+func New(entries ...Entry) *Server {
+	return start(false, entries)
+}
+
+// NewTLS starts a server speaking TLS from the first byte (ldaps).
+func NewTLS(entries ...Entry) *Server {
+	return start(true, entries)
+}
+
+func start(useTLS bool, entries []Entry) *Server {
+	s := &Server{entries: entries, conns: make(map[net.Conn]bool)}
+	s.tlsConfig, s.certPEM = selfSigned()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	s.URL = "ldap://" + l.Addr().String()
+	if useTLS {
+		l = tls.NewListener(l, s.tlsConfig)
+		s.URL = "ldaps://" + l.Addr().String()
+	}
+	s.listener = l
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s
+}
+
+// CertPEM returns the self-signed certificate of the server, to trust it.
+func (s *Server) CertPEM() []byte {
+	return s.certPEM
+}
+
+// Close stops listening and drops open connections.
+func (s *Server) Close() {
+	_ = s.listener.Close()
+
+	s.mu.Lock()
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[c] = true
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(c)
+		}()
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		_ = c.Close()
+	}()
+
+	for {
+		p, err := ber.ReadPacket(c)
+		if err != nil || len(p.Children) < 2 {
+			return
+		}
+
+		id, _ := p.Children[0].Value.(int64)
+		op := p.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			_, err = c.Write(s.bind(id, op).Bytes())
+		case ldap.ApplicationSearchRequest:
+			for _, r := range s.search(id, op) {
+				if _, err = c.Write(r.Bytes()); err != nil {
+					break
+				}
+			}
+		case ldap.ApplicationExtendedRequest:
+			if len(op.Children) == 0 || op.Children[0].Data.String() != oidStartTLS {
+				_, err = c.Write(result(id, ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError, "unsupported operation").Bytes())
+				break
+			}
+			if _, err = c.Write(result(id, ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess, "").Bytes()); err != nil {
+				break
+			}
+
+			tc := tls.Server(c, s.tlsConfig)
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.conns[tc] = true
+			s.mu.Unlock()
+			c = tc
+		case ldap.ApplicationUnbindRequest:
+			return
+		default:
+			_, err = c.Write(result(id, op.Tag+1, ldap.LDAPResultUnwillingToPerform, "unsupported operation").Bytes())
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// bind accepts a simple bind with the password of the entry. Like real
+// servers it accepts an empty password as an unauthenticated bind, which
+// clients must not take for a successful login.
+func (s *Server) bind(id int64, op *ber.Packet) *ber.Packet {
+	if len(op.Children) < 3 {
+		return result(id, ldap.ApplicationBindResponse, ldap.LDAPResultProtocolError, "malformed bind")
+	}
+
+	dn := packetString(op.Children[1])
+	password := op.Children[2].Data.String()
+	if password == "" {
+		return result(id, ldap.ApplicationBindResponse, ldap.LDAPResultSuccess, "")
+	}
+
+	for _, e := range s.entries {
+		if strings.EqualFold(e.DN, dn) && e.Password != "" && e.Password == password {
+			return result(id, ldap.ApplicationBindResponse, ldap.LDAPResultSuccess, "")
+		}
+	}
+
+	return result(id, ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials, "invalid credentials")
+}
+
+func (s *Server) search(id int64, op *ber.Packet) []*ber.Packet {
+	if len(op.Children) < 8 {
+		return []*ber.Packet{result(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError, "malformed search")}
+	}
+
+	base := packetString(op.Children[0])
+	scope, _ := op.Children[1].Value.(int64)
+	filter := op.Children[6]
+
+	var wanted []string
+	for _, a := range op.Children[7].Children {
+		wanted = append(wanted, packetString(a))
+	}
+
+	var responses []*ber.Packet
+	for _, e := range s.entries {
+		if inScope(e.DN, base, scope) && matches(filter, e) {
+			responses = append(responses, entryPacket(id, e, wanted))
+		}
+	}
+
+	return append(responses, result(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess, ""))
+}
+
+func inScope(dn, base string, scope int64) bool {
+	dn, base = strings.ToLower(dn), strings.ToLower(base)
+
+	switch scope {
+	case ldap.ScopeBaseObject:
+		return dn == base
+	case ldap.ScopeSingleLevel:
+		_, parent, _ := strings.Cut(dn, ",")
+		return parent == base
+	}
+
+	return base == "" || dn == base || strings.HasSuffix(dn, ","+base)
+}
+
+// matches evaluates the filters of RFC 4511 section 4.5.1 this server
+// supports against e.
+func matches(f *ber.Packet, e Entry) bool {
+	switch f.Tag {
+	case ldap.FilterAnd:
+		for _, c := range f.Children {
+			if !matches(c, e) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, c := range f.Children {
+			if matches(c, e) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return len(f.Children) == 1 && !matches(f.Children[0], e)
+	case ldap.FilterEqualityMatch:
+		if len(f.Children) != 2 {
+			return false
+		}
+		value := packetString(f.Children[1])
+		for _, v := range attribute(e, packetString(f.Children[0])) {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		name := f.Data.String()
+		return strings.EqualFold(name, "objectClass") || len(attribute(e, name)) > 0
+	}
+
+	return false
+}
+
+func attribute(e Entry, name string) []string {
+	for k, v := range e.Attributes {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func entryPacket(id int64, e Entry, wanted []string) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.DN, "objectName"))
+
+	names := wanted
+	if len(names) == 0 || contains(names, "*") {
+		names = names[:0]
+		for k := range e.Attributes {
+			names = append(names, k)
+		}
+	}
+
+	attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for _, name := range names {
+		values := attribute(e, name)
+		if len(values) == 0 {
+			continue
+		}
+
+		a := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		a.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, v := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "value"))
+		}
+		a.AppendChild(set)
+		attrs.AppendChild(a)
+	}
+	op.AppendChild(attrs)
+
+	return message(id, op)
+}
+
+// result is an LDAPResult response of the application type tag.
+func result(id int64, tag ber.Tag, code uint16, diagnostic string) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, diagnostic, "diagnosticMessage"))
+
+	return message(id, op)
+}
+
+func message(id int64, op *ber.Packet) *ber.Packet {
+	p := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Message")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "messageID"))
+	p.AppendChild(op)
+
+	return p
+}
+
+func packetString(p *ber.Packet) string {
+	if s, ok := p.Value.(string); ok {
+		return s
+	}
+
+	return p.Data.String()
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// selfSigned returns a TLS config with a fresh certificate for 127.0.0.1
+// and that certificate in PEM.
+func selfSigned() (*tls.Config, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldaptest"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	return cfg, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
@@ -0,0 +1,81 @@
+package bootstrap
+
+import (
+	"context"
+	"crypto/rand"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io"
+)
+
+// generatedPasswordBytes gives a 24 character password once encoded.
+const generatedPasswordBytes = 18
+
+var ErrDefaultCredentials = errors.New("default admin credentials are not allowed in production")
+
+// Run creates the first admin account when the store has no users. The
+// password comes from cfg.Bootstrap or, when empty, is generated and written
+// to out exactly once. The admin must change it on first login. In production
+// Run fails if the default admin/admin login works.
+func Run(ctx context.Context, repo repository.Repository, cfg config.Config, out io.Writer) error {
+	if len(repo.GetAllUsers(ctx)) != 0 {
+		if cfg.App.Env == config.EnvProduction &&
+			repo.IsAuthorized(ctx, cfg.Bootstrap.Username, config.DefaultAdminPassword) {
+			return ErrDefaultCredentials
+		}
+
+		return nil
+	}
+
+	password := cfg.Bootstrap.Password
+	generated := password == ""
+
+	if generated {
+		var err error
+
+		password, err = GeneratePassword()
+		if err != nil {
+			return err
+		}
+	} else if cfg.App.Env == config.EnvProduction && password == config.DefaultAdminPassword {
+		return ErrDefaultCredentials
+	}
+
+	_, err := repo.CreateUser(ctx, model.User{
+		Email:              cfg.Bootstrap.Email,
+		Username:           cfg.Bootstrap.Username,
+		Password:           password,
+		Admin:              true,
+		MustChangePassword: true,
+		// The address comes from the operator, there is no one to verify it.
+		EmailVerified: true,
+	})
+	if err != nil {
+		return fmt.Errorf("create bootstrap admin: %w", err)
+	}
+
+	log.Info().Str("username", cfg.Bootstrap.Username).Msg("bootstrap admin created, password change required on first login")
+
+	if generated {
+		_, err = fmt.Fprintf(out,
+			"Generated password for bootstrap admin %q: %s\nIt is shown only once and must be changed on first login.\n",
+			cfg.Bootstrap.Username, password)
+	}
+
+	return err
+}
+
+// GeneratePassword returns a random URL-safe password.
+func GeneratePassword() (string, error) {
+	b := make([]byte, generatedPasswordBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,105 @@
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		env         string
+		password    string
+		existing    *model.User
+		expectedErr error
+		// expectedLogin is the password that must work afterwards, "generated"
+		// means the one printed to out.
+		expectedLogin string
+	}{
+		{
+			name:          "GENERATED",
+			env:           config.EnvProduction,
+			expectedLogin: "generated",
+		},
+		{
+			name:          "CONFIGURED",
+			env:           config.EnvDevelopment,
+			password:      "s3cret-pass",
+			expectedLogin: "s3cret-pass",
+		},
+		{
+			name:          "STORE_NOT_EMPTY",
+			env:           config.EnvDevelopment,
+			password:      "ignored",
+			existing:      &model.User{Username: "admin", Password: "kept", Admin: true},
+			expectedLogin: "kept",
+		},
+		{
+			name:        "DEFAULT_PASSWORD_IN_PRODUCTION",
+			env:         config.EnvProduction,
+			password:    config.DefaultAdminPassword,
+			expectedErr: ErrDefaultCredentials,
+		},
+		{
+			name:        "DEFAULT_LOGIN_IN_PRODUCTION",
+			env:         config.EnvProduction,
+			password:    "anything",
+			existing:    &model.User{Username: "admin", Password: config.DefaultAdminPassword, Admin: true},
+			expectedErr: ErrDefaultCredentials,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := repository.New(repository.WithHashParams(repository.HashParams{
+				Time: 1, Memory: 64, Threads: 1, KeyLen: 16, SaltLen: 8,
+			}))
+			if test.existing != nil {
+				_, err := repo.CreateUser(ctx, *test.existing)
+				require.NoError(t, err)
+			}
+
+			cfg := config.Config{
+				App: config.App{Env: test.env},
+				Bootstrap: config.Bootstrap{
+					Username: "admin",
+					Email:    "admin@example.com",
+					Password: test.password,
+				},
+			}
+
+			var out bytes.Buffer
+			err := Run(ctx, repo, cfg, &out)
+			assert.Equal(t, test.expectedErr, err)
+			if err != nil {
+				return
+			}
+
+			password := test.expectedLogin
+			if password == "generated" {
+				m := regexp.MustCompile(`: (\S+)\n`).FindStringSubmatch(out.String())
+				require.Len(t, m, 2)
+				password = m[1]
+			} else {
+				assert.Empty(t, out.String())
+			}
+
+			assert.True(t, repo.IsAuthorized(ctx, "admin", password))
+
+			users := repo.GetAllUsers(ctx)
+			require.Len(t, users, 1)
+			assert.True(t, users[0].Admin)
+			assert.Equal(t, test.existing == nil, users[0].MustChangePassword)
+			assert.Equal(t, test.existing == nil, users[0].EmailVerified)
+		})
+	}
+}
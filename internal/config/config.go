@@ -1,30 +1,532 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"github.com/spf13/viper"
-	"log"
+	"strings"
+	"time"
 )
 
+// EnvConfigFile names the environment variable holding an explicit path to a
+// YAML, TOML or JSON config file. Without it ./config.{yaml,toml,json} is used
+// when present; every key can also be set from the environment.
+const EnvConfigFile = "CONFIG_FILE"
+
+// Config is the whole configuration. Fields tagged secret:"true" are never
+// printed when a reload reports what changed.
 type Config struct {
-	Server Server `mapstructure:",squash"`
+	App       App       `mapstructure:"app"`
+	Server    Server    `mapstructure:"server"`
+	GRPC      GRPC      `mapstructure:"grpc"`
+	GraphQL   GraphQL   `mapstructure:"graphql"`
+	Storage   Storage   `mapstructure:"storage"`
+	Hashing   Hashing   `mapstructure:"hashing"`
+	Bootstrap Bootstrap `mapstructure:"bootstrap"`
+	Log       Log       `mapstructure:"log"`
+	Tracing   Tracing   `mapstructure:"tracing"`
+	CORS      CORS      `mapstructure:"cors"`
+	RateLimit RateLimit `mapstructure:"rate_limit"`
+	Password  Password  `mapstructure:"password"`
+	Session   Session   `mapstructure:"session"`
+	TwoFactor TwoFactor `mapstructure:"two_factor"`
+	Notify    Notify    `mapstructure:"notify"`
+	// Idempotency configures the answers kept for retried requests.
+	Idempotency Idempotency `mapstructure:"idempotency"`
+	// PasswordReset is named reset in the config to keep keys short.
+	PasswordReset PasswordReset `mapstructure:"reset"`
+	Verification  Verification  `mapstructure:"verification"`
+	Registration  Registration  `mapstructure:"registration"`
+	OAuth         OAuth         `mapstructure:"oauth"`
+	OIDC          OIDC          `mapstructure:"oidc"`
+	Federation    Federation    `mapstructure:"federation"`
+	LDAP          LDAP          `mapstructure:"ldap"`
+}
+
+type App struct {
+	// Env is "development" or "production". Production refuses to start
+	// with the default admin credentials.
+	Env string `mapstructure:"env"`
 }
 
 type Server struct {
-	Port string
+	// Addr is the interface to listen on, empty means all interfaces.
+	Addr            string        `mapstructure:"addr"`
+	Port            string        `mapstructure:"port"`
+	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout     time.Duration `mapstructure:"idle_timeout"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	MaxHeaderBytes  int           `mapstructure:"max_header_bytes"`
+	TLS             TLS           `mapstructure:"tls"`
+}
+
+// GRPC configures the gRPC API, served on its own port with the TLS settings
+// of the server.
+type GRPC struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Addr is the interface to listen on, empty means all interfaces.
+	Addr string `mapstructure:"addr"`
+	Port string `mapstructure:"port"`
+}
+
+// GraphQL limits the queries of the /graphql endpoint. The complexity of a
+// query counts its fields, multiplied by the limit of the pages they are in.
+type GraphQL struct {
+	MaxDepth      int `mapstructure:"max_depth"`
+	MaxComplexity int `mapstructure:"max_complexity"`
+}
+
+type TLS struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	CertFile string `mapstructure:"cert_file"`
+	KeyFile  string `mapstructure:"key_file"`
+	// ReloadInterval is how often the cert and key files are checked for
+	// rotation.
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+	// ClientAuth is "none", "optional" or "require". A verified client
+	// certificate authenticates the user named by its subject common name.
+	ClientAuth   string `mapstructure:"client_auth"`
+	ClientCAFile string `mapstructure:"client_ca_file"`
+	// RedirectPort, when set, serves plain HTTP redirects to HTTPS.
+	RedirectPort string `mapstructure:"redirect_port"`
+}
+
+type Storage struct {
+	// Backend selects the Repository implementation.
+	Backend string `mapstructure:"backend"`
+	// DSN is passed to the backend, its meaning depends on the backend.
+	DSN string `mapstructure:"dsn"`
+}
+
+// Hashing holds the argon2id parameters used for password hashes.
+type Hashing struct {
+	Time uint32 `mapstructure:"time"`
+	// Memory is in KiB.
+	Memory  uint32 `mapstructure:"memory"`
+	Threads uint8  `mapstructure:"threads"`
+	KeyLen  uint32 `mapstructure:"key_len"`
+	SaltLen uint32 `mapstructure:"salt_len"`
+}
+
+// Bootstrap is the admin account created when the store has no users. An
+// empty password is replaced by a generated one that is printed once.
+type Bootstrap struct {
+	Username string `mapstructure:"username"`
+	Email    string `mapstructure:"email"`
+	Password string `mapstructure:"password" secret:"true"`
+}
+
+type Log struct {
+	// Level is a zerolog level name: trace, debug, info, warn, error.
+	Level string `mapstructure:"level"`
+	// Format is "json" or "console".
+	Format string `mapstructure:"format"`
+}
+
+type Tracing struct {
+	// Exporter is one of "none", "stdout" or "otlp".
+	Exporter string `mapstructure:"exporter"`
+	// Endpoint is the host:port of the OTLP/HTTP collector.
+	Endpoint    string `mapstructure:"endpoint"`
+	ServiceName string `mapstructure:"service_name"`
+}
+
+type CORS struct {
+	// AllowedOrigins is empty to disable CORS, "*" allows any origin.
+	AllowedOrigins   []string      `mapstructure:"allowed_origins"`
+	AllowedMethods   []string      `mapstructure:"allowed_methods"`
+	AllowedHeaders   []string      `mapstructure:"allowed_headers"`
+	AllowCredentials bool          `mapstructure:"allow_credentials"`
+	MaxAge           time.Duration `mapstructure:"max_age"`
+}
+
+// RateLimit limits requests per client IP, a zero rate disables limiting.
+type RateLimit struct {
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"`
+	Burst             int     `mapstructure:"burst"`
+}
+
+// Password is the policy applied to passwords set through the API.
+type Password struct {
+	MinLength     int  `mapstructure:"min_length"`
+	RequireUpper  bool `mapstructure:"require_upper"`
+	RequireLower  bool `mapstructure:"require_lower"`
+	RequireDigit  bool `mapstructure:"require_digit"`
+	RequireSymbol bool `mapstructure:"require_symbol"`
+}
+
+// Idempotency configures the answers kept for requests with an
+// Idempotency-Key header.
+type Idempotency struct {
+	// Store is "memory" or "repository". The repository store keeps answers
+	// in the storage backend so retries are answered across restarts.
+	Store string `mapstructure:"store"`
+	// TTL is how long an answer is replayed.
+	TTL time.Duration `mapstructure:"ttl"`
+	// MaxBodySize bounds, in bytes, the bodies of requests with a key, which
+	// are read whole to compare retries with the first request.
+	MaxBodySize int64 `mapstructure:"max_body_size"`
+}
+
+// Session configures the cookie sessions issued by POST /v1/auth/login.
+type Session struct {
+	// Store is "memory" or "repository". The repository store keeps sessions
+	// in the storage backend so they survive restarts with the file backend.
+	Store string        `mapstructure:"store"`
+	TTL   time.Duration `mapstructure:"ttl"`
+	// IdleTimeout ends sessions not used for that long, zero disables it.
+	IdleTimeout  time.Duration `mapstructure:"idle_timeout"`
+	CookieName   string        `mapstructure:"cookie_name"`
+	CookieSecure bool          `mapstructure:"cookie_secure"`
+	// SameSite is "lax", "strict" or "none".
+	SameSite string `mapstructure:"same_site"`
+}
+
+// TwoFactor configures TOTP second factors.
+type TwoFactor struct {
+	// Issuer is the account issuer shown by authenticator apps.
+	Issuer string `mapstructure:"issuer"`
+	// RequiredRoles lists the roles, "admin" or "user", that must enroll
+	// before they can use anything else.
+	RequiredRoles []string `mapstructure:"required_roles"`
+}
+
+// Notify configures how messages such as password reset tokens reach users.
+type Notify struct {
+	// Backend is "log" or "file" for development, or "smtp".
+	Backend string `mapstructure:"backend"`
+	// File receives the messages of the file backend.
+	File string `mapstructure:"file"`
+	SMTP SMTP   `mapstructure:"smtp"`
+}
+
+type SMTP struct {
+	Host     string `mapstructure:"host"`
+	Port     string `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password" secret:"true"`
+	From     string `mapstructure:"from"`
+	// RequireTLS refuses to send to servers that don't offer STARTTLS.
+	RequireTLS bool          `mapstructure:"require_tls"`
+	Timeout    time.Duration `mapstructure:"timeout"`
+}
+
+// PasswordReset configures POST /v1/auth/forgot.
+type PasswordReset struct {
+	TTL time.Duration `mapstructure:"ttl"`
+	// URL, when set, is sent as a link with the token appended as ?token=.
+	URL string `mapstructure:"url"`
+}
+
+// Verification configures email verification.
+type Verification struct {
+	TTL time.Duration `mapstructure:"ttl"`
+	// URL, when set, is sent as a link with the token appended as ?token=.
+	URL string `mapstructure:"url"`
+	// BlockLogin keeps users with an unverified email from logging in and
+	// from every route but resending the token.
+	BlockLogin bool `mapstructure:"block_login"`
+	// BlockRoutes lists route prefixes, e.g. "/v1/user", closed to users
+	// with an unverified email.
+	BlockRoutes []string `mapstructure:"block_routes"`
+}
+
+// Registration configures POST /v1/auth/register.
+type Registration struct {
+	// Mode is "disabled", "open", "invite" for admin-issued invite codes or
+	// "domain" for emails in Domains.
+	Mode    string   `mapstructure:"mode"`
+	Domains []string `mapstructure:"domains"`
+	// InviteTTL is how long invite codes stay valid.
+	InviteTTL time.Duration `mapstructure:"invite_ttl"`
+	// RateLimit applies per client IP on top of the global rate limit.
+	RateLimit RateLimit `mapstructure:"rate_limit"`
+}
+
+// OAuth configures the OAuth2 authorization server below /oauth.
+type OAuth struct {
+	// CodeTTL is how long authorization codes can be exchanged.
+	CodeTTL         time.Duration `mapstructure:"code_ttl"`
+	AccessTokenTTL  time.Duration `mapstructure:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `mapstructure:"refresh_token_ttl"`
+}
+
+// OIDC configures the OpenID Connect layer on top of the OAuth2 server.
+type OIDC struct {
+	// Issuer is the URL the service is reached at, e.g.
+	// https://id.example.com. Empty derives it from each request. Tenants
+	// issue as Issuer/t/{tenant}.
+	Issuer string `mapstructure:"issuer"`
+	// Algorithm is RS256 or ES256. Changing it rotates the signing key.
+	Algorithm string `mapstructure:"algorithm"`
+	// KeyRotation is how long a signing key signs before a new one takes
+	// over.
+	KeyRotation time.Duration `mapstructure:"key_rotation"`
+	IDTokenTTL  time.Duration `mapstructure:"id_token_ttl"`
 }
 
-func (c *Config) InitCfg() error {
-	viper.AddConfigPath("./")
-	viper.SetConfigName("config")
-	viper.SetConfigType("env")
-	viper.AutomaticEnv()
+// Federation configures login through upstream OpenID Connect providers.
+type Federation struct {
+	Connectors []Connector `mapstructure:"connectors"`
+}
+
+// Connector is an upstream OpenID Connect provider, e.g. a corporate IdP.
+type Connector struct {
+	// ID names the connector in URLs such as
+	// /v1/auth/connectors/{id}/login.
+	ID   string `mapstructure:"id"`
+	Name string `mapstructure:"name"`
+	// Issuer is where the provider publishes
+	// /.well-known/openid-configuration.
+	Issuer       string `mapstructure:"issuer"`
+	ClientID     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret" secret:"true"`
+	// RedirectURL is the callback registered at the provider, ending in
+	// /v1/auth/connectors/{id}/callback.
+	RedirectURL string `mapstructure:"redirect_url"`
+	// Scopes default to openid, profile and email.
+	Scopes []string `mapstructure:"scopes"`
+	// Tenant is where the users of the connector live.
+	Tenant string       `mapstructure:"tenant"`
+	Claims ClaimMapping `mapstructure:"claims"`
+	// Provision creates users at their first login. Without it only users
+	// linked beforehand can log in.
+	Provision bool `mapstructure:"provision"`
+	// LinkByEmail links a first login to the user of the tenant with the same
+	// email, when both the provider and the user verified it.
+	LinkByEmail bool `mapstructure:"link_by_email"`
+	// AdminGroups make provisioned users in one of them admins.
+	AdminGroups []string `mapstructure:"admin_groups"`
+}
+
+// ClaimMapping names the claims user fields are taken from. Empty names take
+// the standard claims, groups defaults to "groups".
+type ClaimMapping struct {
+	Username      string `mapstructure:"username"`
+	Email         string `mapstructure:"email"`
+	EmailVerified string `mapstructure:"email_verified"`
+	Groups        string `mapstructure:"groups"`
+}
+
+// LDAP checks passwords against a directory: the entry of a username is
+// searched with the service account, then bound to with the password.
+type LDAP struct {
+	Enabled bool `mapstructure:"enabled"`
+	// URL is ldap://host:389 or ldaps://host:636.
+	URL string `mapstructure:"url"`
+	// StartTLS upgrades an ldap:// connection before binding.
+	StartTLS bool `mapstructure:"start_tls"`
+	// CAFile verifies the server certificate, the system roots when empty.
+	CAFile             string `mapstructure:"ca_file"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+	// BindDN and BindPassword are the service account searching the
+	// directory, an anonymous search when BindDN is empty.
+	BindDN       string `mapstructure:"bind_dn"`
+	BindPassword string `mapstructure:"bind_password" secret:"true"`
+	BaseDN       string `mapstructure:"base_dn"`
+	// UserFilter finds the entry of a username, which replaces %s.
+	UserFilter        string `mapstructure:"user_filter"`
+	UsernameAttribute string `mapstructure:"username_attribute"`
+	EmailAttribute    string `mapstructure:"email_attribute"`
+	// GroupBaseDN defaults to BaseDN. GroupFilter finds the groups of a user,
+	// whose DN replaces %s.
+	GroupBaseDN string `mapstructure:"group_base_dn"`
+	GroupFilter string `mapstructure:"group_filter"`
+	// GroupRoles give the members of groups a role. With any set, only
+	// members of one of them can log in.
+	GroupRoles []GroupRole `mapstructure:"group_roles"`
+	// Tenant is where the users of the directory live.
+	Tenant string `mapstructure:"tenant"`
+	// LinkExisting links a directory account to the local user with the same
+	// username at its first login. Without it that login is refused.
+	LinkExisting bool `mapstructure:"link_existing"`
+	// Fallback lets local users log in with their local password when the
+	// directory refuses them or can't be reached.
+	Fallback bool          `mapstructure:"fallback"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
+
+// GroupRole gives the members of the group DN the role admin or user.
+type GroupRole struct {
+	DN   string `mapstructure:"dn"`
+	Role string `mapstructure:"role"`
+}
+
+var defaults = map[string]interface{}{
+	"app.env": EnvDevelopment,
+
+	"server.addr":                "",
+	"server.port":                "8080",
+	"server.read_timeout":        100 * time.Second,
+	"server.write_timeout":       100 * time.Second,
+	"server.idle_timeout":        120 * time.Second,
+	"server.shutdown_timeout":    5 * time.Second,
+	"server.max_header_bytes":    1 << 20,
+	"server.tls.enabled":         false,
+	"server.tls.cert_file":       "",
+	"server.tls.key_file":        "",
+	"server.tls.reload_interval": 30 * time.Second,
+	"server.tls.client_auth":     ClientAuthNone,
+	"server.tls.client_ca_file":  "",
+	"server.tls.redirect_port":   "",
+
+	"grpc.enabled": false,
+	"grpc.addr":    "",
+	"grpc.port":    "9090",
+
+	"graphql.max_depth":      10,
+	"graphql.max_complexity": 1000,
+
+	"storage.backend": StorageMemory,
+	"storage.dsn":     "",
+
+	"hashing.time":     1,
+	"hashing.memory":   64 * 1024,
+	"hashing.threads":  4,
+	"hashing.key_len":  32,
+	"hashing.salt_len": 8,
+
+	"bootstrap.username": "admin",
+	"bootstrap.email":    "admin",
+	"bootstrap.password": "",
+
+	"log.level":  "info",
+	"log.format": "json",
+
+	"tracing.exporter":     "none",
+	"tracing.endpoint":     "localhost:4318",
+	"tracing.service_name": "profileSaver",
+
+	"cors.allowed_origins":   []string{},
+	"cors.allowed_methods":   []string{"GET", "POST", "PATCH", "DELETE", "OPTIONS"},
+	"cors.allowed_headers":   []string{"Authorization", "Content-Type", "X-Request-ID", "X-Tenant", "Idempotency-Key"},
+	"cors.allow_credentials": false,
+	"cors.max_age":           10 * time.Minute,
+
+	"rate_limit.requests_per_second": 0,
+	"rate_limit.burst":               0,
+
+	"password.min_length":     8,
+	"password.require_upper":  false,
+	"password.require_lower":  false,
+	"password.require_digit":  false,
+	"password.require_symbol": false,
+
+	"session.store":         SessionStoreMemory,
+	"session.ttl":           24 * time.Hour,
+	"session.idle_timeout":  2 * time.Hour,
+	"session.cookie_name":   "profile_session",
+	"session.cookie_secure": true,
+	"session.same_site":     SameSiteLax,
+
+	"idempotency.store":         IdempotencyStoreMemory,
+	"idempotency.ttl":           24 * time.Hour,
+	"idempotency.max_body_size": 1 << 20,
 
-	err := viper.ReadInConfig()
+	"two_factor.issuer":         "profileSaver",
+	"two_factor.required_roles": []string{},
+
+	"notify.backend":          NotifyLog,
+	"notify.file":             "",
+	"notify.smtp.host":        "",
+	"notify.smtp.port":        "587",
+	"notify.smtp.username":    "",
+	"notify.smtp.password":    "",
+	"notify.smtp.from":        "",
+	"notify.smtp.require_tls": true,
+	"notify.smtp.timeout":     10 * time.Second,
+
+	"reset.ttl": time.Hour,
+	"reset.url": "",
+
+	"verification.ttl":          24 * time.Hour,
+	"verification.url":          "",
+	"verification.block_login":  false,
+	"verification.block_routes": []string{},
+
+	"registration.mode":                           RegistrationDisabled,
+	"registration.domains":                        []string{},
+	"registration.invite_ttl":                     7 * 24 * time.Hour,
+	"registration.rate_limit.requests_per_second": 0.05,
+	"registration.rate_limit.burst":               3,
+
+	"oauth.code_ttl":          time.Minute,
+	"oauth.access_token_ttl":  time.Hour,
+	"oauth.refresh_token_ttl": 30 * 24 * time.Hour,
+
+	"oidc.issuer":       "",
+	"oidc.algorithm":    SigningRS256,
+	"oidc.key_rotation": 30 * 24 * time.Hour,
+	"oidc.id_token_ttl": time.Hour,
+
+	"federation.connectors": []Connector{},
+
+	"ldap.enabled":              false,
+	"ldap.url":                  "",
+	"ldap.start_tls":            false,
+	"ldap.ca_file":              "",
+	"ldap.insecure_skip_verify": false,
+	"ldap.bind_dn":              "",
+	"ldap.bind_password":        "",
+	"ldap.base_dn":              "",
+	"ldap.user_filter":          "(uid=%s)",
+	"ldap.username_attribute":   "uid",
+	"ldap.email_attribute":      "mail",
+	"ldap.group_base_dn":        "",
+	"ldap.group_filter":         "(member=%s)",
+	"ldap.group_roles":          []GroupRole{},
+	"ldap.tenant":               "",
+	"ldap.link_existing":        false,
+	"ldap.fallback":             true,
+	"ldap.timeout":              10 * time.Second,
+}
+
+// Load reads the config file at path (or looks for ./config.* when path is
+// empty), applies environment overrides such as SERVER_PORT and validates the
+// result.
+func Load(path string) (Config, error) {
+	cfg, _, err := load(path)
+
+	return cfg, err
+}
+
+// load is Load that also returns the config file that was read, if any.
+func load(path string) (Config, string, error) {
+	v := viper.New()
+
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	// PORT predates the nested layout and is still honoured.
+	_ = v.BindEnv("server.port", "SERVER_PORT", "PORT")
+
+	if path != "" {
+		v.SetConfigFile(path)
+	} else {
+		v.AddConfigPath("./")
+		v.SetConfigName("config")
+	}
+
+	err := v.ReadInConfig()
 	if err != nil {
-		log.Fatal(err)
+		var notFound viper.ConfigFileNotFoundError
+		if path != "" || !errors.As(err, &notFound) {
+			return Config{}, "", fmt.Errorf("read config: %w", err)
+		}
 	}
 
-	err = viper.Unmarshal(&c)
+	var cfg Config
+	if err = v.Unmarshal(&cfg); err != nil {
+		return Config{}, "", fmt.Errorf("decode config: %w", err)
+	}
+
+	if err = cfg.Validate(); err != nil {
+		return Config{}, "", err
+	}
 
-	return err
+	return cfg, v.ConfigFileUsed(), nil
 }
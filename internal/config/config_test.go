@@ -0,0 +1,217 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		data   string
+		env    map[string]string
+		assert func(t *testing.T, cfg Config)
+	}{
+		{
+			name: "YAML",
+			file: "config.yaml",
+			data: `
+server:
+  port: "9090"
+  read_timeout: 5s
+cors:
+  allowed_origins: ["https://example.com"]
+`,
+			assert: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "9090", cfg.Server.Port)
+				assert.Equal(t, 5*time.Second, cfg.Server.ReadTimeout)
+				assert.Equal(t, 100*time.Second, cfg.Server.WriteTimeout)
+				assert.Equal(t, []string{"https://example.com"}, cfg.CORS.AllowedOrigins)
+			},
+		},
+		{
+			name: "CONNECTORS",
+			file: "config.yaml",
+			data: `
+federation:
+  connectors:
+    - id: corp
+      issuer: https://idp.corp.test
+      client_id: profile-saver
+      redirect_url: https://id.test/v1/auth/connectors/corp/callback
+      provision: true
+      claims:
+        username: upn
+      admin_groups: [it-admins]
+`,
+			assert: func(t *testing.T, cfg Config) {
+				require.Len(t, cfg.Federation.Connectors, 1)
+				c := cfg.Federation.Connectors[0]
+				assert.Equal(t, "corp", c.ID)
+				assert.True(t, c.Provision)
+				assert.Equal(t, "upn", c.Claims.Username)
+				assert.Equal(t, []string{"it-admins"}, c.AdminGroups)
+			},
+		},
+		{
+			name: "LDAP",
+			file: "config.yaml",
+			data: `
+ldap:
+  enabled: true
+  url: ldaps://ldap.corp.test
+  base_dn: ou=people,dc=corp,dc=test
+  group_roles:
+    - dn: cn=admins,ou=groups,dc=corp,dc=test
+      role: admin
+`,
+			assert: func(t *testing.T, cfg Config) {
+				assert.True(t, cfg.LDAP.Enabled)
+				assert.Equal(t, "(uid=%s)", cfg.LDAP.UserFilter)
+				assert.True(t, cfg.LDAP.Fallback)
+				assert.Equal(t, []GroupRole{{DN: "cn=admins,ou=groups,dc=corp,dc=test", Role: RoleAdmin}}, cfg.LDAP.GroupRoles)
+			},
+		},
+		{
+			name: "TOML",
+			file: "config.toml",
+			data: `
+[hashing]
+time = 3
+[log]
+level = "debug"
+`,
+			assert: func(t *testing.T, cfg Config) {
+				assert.Equal(t, uint32(3), cfg.Hashing.Time)
+				assert.Equal(t, "debug", cfg.Log.Level)
+				assert.Equal(t, "8080", cfg.Server.Port)
+			},
+		},
+		{
+			name: "ENV_OVERRIDE",
+			file: "config.yaml",
+			data: `
+server:
+  port: "9090"
+`,
+			env: map[string]string{
+				"SERVER_PORT":          "7070",
+				"STORAGE_BACKEND":      "memory",
+				"CORS_ALLOWED_ORIGINS": "https://a.com,https://b.com",
+			},
+			assert: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "7070", cfg.Server.Port)
+				assert.Equal(t, []string{"https://a.com", "https://b.com"}, cfg.CORS.AllowedOrigins)
+			},
+		},
+		{
+			name: "LEGACY_PORT",
+			file: "config.yaml",
+			data: `{}`,
+			env:  map[string]string{"PORT": "6060"},
+			assert: func(t *testing.T, cfg Config) {
+				assert.Equal(t, "6060", cfg.Server.Port)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for k, v := range test.env {
+				t.Setenv(k, v)
+			}
+
+			cfg, err := Load(writeFile(t, test.file, test.data))
+			require.NoError(t, err)
+
+			test.assert(t, cfg)
+		})
+	}
+}
+
+func TestLoad_MissingFile(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+server:
+  port: "http"
+  read_timeout: 0s
+  tls:
+    enabled: true
+grpc:
+  enabled: true
+  port: "70000"
+graphql:
+  max_depth: 0
+storage:
+  backend: postgres
+log:
+  level: loud
+session:
+  cookie_secure: false
+  same_site: none
+idempotency:
+  store: disk
+  max_body_size: -1
+federation:
+  connectors:
+    - id: corp
+      issuer: idp.corp.test
+      client_id: profile-saver
+      redirect_url: https://id.test/v1/auth/connectors/corp/callback
+    - id: corp
+ldap:
+  enabled: true
+  url: ldaps://ldap.corp.test
+  start_tls: true
+  user_filter: (uid=bob)
+  group_roles:
+    - dn: cn=ops,dc=corp,dc=test
+      role: operator
+`)
+
+	_, err := Load(path)
+	require.Error(t, err)
+
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, []string{
+		`server.port "http" is not a valid port`,
+		"server.read_timeout must be positive",
+		"server.tls.cert_file is required when tls is enabled",
+		"server.tls.key_file is required when tls is enabled",
+		`grpc.port "70000" is not a valid port`,
+		"graphql.max_depth must be positive",
+		`storage.backend "postgres" is not supported`,
+		`log.level "loud" is not a valid level`,
+		"session.same_site none requires session.cookie_secure",
+		`idempotency.store "disk" must be memory or repository`,
+		"idempotency.max_body_size must be positive",
+		`federation.connectors[0].issuer "idp.corp.test" must be an http(s) URL`,
+		`federation.connectors[1].id "corp" is used twice`,
+		`federation.connectors[1].issuer "" must be an http(s) URL`,
+		`federation.connectors[1].redirect_url "" must be an http(s) URL`,
+		"federation.connectors[1].client_id is required",
+		"ldap.start_tls can't be used with an ldaps:// URL",
+		"ldap.base_dn is required",
+		`ldap.user_filter "(uid=bob)" must contain %s once`,
+		`ldap.group_roles[0].role "operator" must be admin or user`,
+	}, verr.Problems)
+}
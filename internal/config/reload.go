@@ -0,0 +1,179 @@
+package config
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Live holds the current Config. Components that honour reloadable settings
+// read it on every use instead of keeping a copy.
+type Live struct {
+	cfg atomic.Pointer[Config]
+}
+
+func NewLive(cfg Config) *Live {
+	l := &Live{}
+	l.cfg.Store(&cfg)
+
+	return l
+}
+
+func (l *Live) Get() Config {
+	return *l.cfg.Load()
+}
+
+// Reloader re-reads the config file and applies the settings that are safe to
+// change at runtime: log level, rate limits, CORS origins, password policy, the
+// roles required to use two-factor authentication, what an unverified email
+// blocks and the registration policy.
+// Everything else still requires a restart.
+type Reloader struct {
+	mu       sync.Mutex
+	path     string
+	live     *Live
+	onChange []func(old, new Config)
+}
+
+// NewReloader loads the config like Load and returns a Reloader for it.
+func NewReloader(path string) (*Reloader, error) {
+	cfg, used, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reloader{
+		path: used,
+		live: NewLive(cfg),
+	}, nil
+}
+
+func (r *Reloader) Live() *Live {
+	return r.live
+}
+
+// OnChange registers fn to run after a reload applied at least one change.
+func (r *Reloader) OnChange(fn func(old, new Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.onChange = append(r.onChange, fn)
+}
+
+// Reload reads and validates the config again. On success it applies the
+// reloadable settings and returns a description of what changed, plus a
+// list of changes that were ignored because they need a restart.
+func (r *Reloader) Reload() (changes []string, ignored []string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loaded, _, err := load(r.path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	old := r.live.Get()
+	next := old
+	next.Log.Level = loaded.Log.Level
+	next.RateLimit = loaded.RateLimit
+	next.GraphQL = loaded.GraphQL
+	next.CORS.AllowedOrigins = loaded.CORS.AllowedOrigins
+	next.Password = loaded.Password
+	next.TwoFactor.RequiredRoles = loaded.TwoFactor.RequiredRoles
+	next.Verification.BlockLogin = loaded.Verification.BlockLogin
+	next.Verification.BlockRoutes = loaded.Verification.BlockRoutes
+	next.Registration = loaded.Registration
+
+	changes = diff(old, next)
+
+	if !reflect.DeepEqual(next, loaded) {
+		ignored = diff(next, loaded)
+	}
+
+	if len(changes) == 0 {
+		return nil, ignored, nil
+	}
+
+	r.live.cfg.Store(&next)
+
+	for _, fn := range r.onChange {
+		fn(old, next)
+	}
+
+	return changes, ignored, nil
+}
+
+// Watch reloads the config whenever the config file changes on disk and
+// reports the outcome to report. It does nothing when no file was loaded.
+func (r *Reloader) Watch(report func(changes, ignored []string, err error)) {
+	if r.path == "" {
+		return
+	}
+
+	v := viper.New()
+	v.SetConfigFile(r.path)
+	v.OnConfigChange(func(fsnotify.Event) {
+		report(r.Reload())
+	})
+	v.WatchConfig()
+}
+
+// diff describes the fields that differ between a and b as
+// "section.field: old -> new".
+func diff(a, b Config) []string {
+	var changes []string
+
+	diffStruct("", reflect.ValueOf(a), reflect.ValueOf(b), &changes)
+
+	return changes
+}
+
+func diffStruct(prefix string, a, b reflect.Value, changes *[]string) {
+	t := a.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := prefix + field.Tag.Get("mapstructure")
+
+		fa, fb := a.Field(i), b.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			diffStruct(name+".", fa, fb, changes)
+			continue
+		}
+
+		if reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			continue
+		}
+
+		if isSecret(field) {
+			*changes = append(*changes, name+": changed")
+			continue
+		}
+
+		*changes = append(*changes, fmt.Sprintf("%s: %v -> %v", name, fa.Interface(), fb.Interface()))
+	}
+}
+
+// isSecret reports whether field is tagged secret:"true" or holds such a
+// field, as the connectors of federation do. Its values are never reported.
+func isSecret(field reflect.StructField) bool {
+	return field.Tag.Get("secret") == "true" || holdsSecret(field.Type)
+}
+
+func holdsSecret(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Pointer:
+		return holdsSecret(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if isSecret(t.Field(i)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestReloader_Reload(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+log:
+  level: info
+`)
+
+	r, err := NewReloader(path)
+	require.NoError(t, err)
+
+	var notified []string
+	r.OnChange(func(old, new Config) {
+		notified = append(notified, old.Log.Level+"->"+new.Log.Level)
+	})
+
+	tests := []struct {
+		name            string
+		data            string
+		expectedErr     bool
+		expectedChanges []string
+		expectedIgnored []string
+		expectedLevel   string
+	}{
+		{
+			name: "APPLIED",
+			data: `
+log:
+  level: debug
+rate_limit:
+  requests_per_second: 5
+  burst: 10
+cors:
+  allowed_origins: ["https://example.com"]
+`,
+			expectedChanges: []string{
+				"log.level: info -> debug",
+				"cors.allowed_origins: [] -> [https://example.com]",
+				"rate_limit.requests_per_second: 0 -> 5",
+				"rate_limit.burst: 0 -> 10",
+			},
+			expectedLevel: "debug",
+		},
+		{
+			name: "INVALID",
+			data: `
+log:
+  level: loud
+`,
+			expectedErr:   true,
+			expectedLevel: "debug",
+		},
+		{
+			name: "RESTART_REQUIRED",
+			data: `
+server:
+  port: "9999"
+log:
+  level: debug
+rate_limit:
+  requests_per_second: 5
+  burst: 10
+cors:
+  allowed_origins: ["https://example.com"]
+`,
+			expectedIgnored: []string{"server.port: 8080 -> 9999"},
+			expectedLevel:   "debug",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(path, []byte(test.data), 0o600))
+
+			changes, ignored, err := r.Reload()
+			if test.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, test.expectedChanges, changes)
+			assert.Equal(t, test.expectedIgnored, ignored)
+			assert.Equal(t, test.expectedLevel, r.Live().Get().Log.Level)
+			assert.Equal(t, "8080", r.Live().Get().Server.Port)
+		})
+	}
+
+	assert.Equal(t, []string{"info->debug"}, notified)
+}
+
+func TestReloader_ReloadSecrets(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+ldap:
+  bind_password: old-secret
+`)
+
+	r, err := NewReloader(path)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+ldap:
+  bind_password: new-secret
+`), 0o600))
+
+	_, ignored, err := r.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ldap.bind_password: changed"}, ignored)
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+ldap:
+  bind_password: new-secret
+federation:
+  connectors:
+    - id: corp
+      issuer: https://idp.corp.test
+      client_id: profiles
+      client_secret: connector-secret
+      redirect_url: https://app.test/v1/auth/connectors/corp/callback
+`), 0o600))
+
+	_, ignored, err = r.Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"federation.connectors: changed", "ldap.bind_password: changed"}, ignored,
+		"changes needing a restart are reported until it happens")
+}
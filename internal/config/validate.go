@@ -0,0 +1,426 @@
+package config
+
+import (
+	"fmt"
+	"github.com/rs/zerolog"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	StorageMemory = "memory"
+	// StorageFile keeps users in the JSON file named by storage.dsn.
+	StorageFile = "file"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// DefaultAdminPassword is the well known password refused in production.
+const DefaultAdminPassword = "admin"
+
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+const (
+	SessionStoreMemory     = "memory"
+	SessionStoreRepository = "repository"
+)
+
+const (
+	IdempotencyStoreMemory     = "memory"
+	IdempotencyStoreRepository = "repository"
+)
+
+const (
+	SameSiteLax    = "lax"
+	SameSiteStrict = "strict"
+	SameSiteNone   = "none"
+)
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// ConnectorLDAP is the connector of the identities linking directory
+// accounts to users, reserved among the federation connectors.
+const ConnectorLDAP = "ldap"
+
+const (
+	NotifyLog  = "log"
+	NotifyFile = "file"
+	NotifySMTP = "smtp"
+)
+
+const (
+	RegistrationDisabled = "disabled"
+	RegistrationOpen     = "open"
+	RegistrationInvite   = "invite"
+	RegistrationDomain   = "domain"
+)
+
+const (
+	SigningRS256 = "RS256"
+	SigningES256 = "ES256"
+)
+
+// ValidationError lists every problem found in a Config.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config: " + strings.Join(e.Problems, ", ")
+}
+
+// Validate checks the whole config and reports all problems at once.
+func (c *Config) Validate() error {
+	var reason []string
+
+	add := func(format string, args ...interface{}) {
+		reason = append(reason, fmt.Sprintf(format, args...))
+	}
+
+	if c.App.Env != EnvDevelopment && c.App.Env != EnvProduction {
+		add("app.env %q must be development or production", c.App.Env)
+	}
+
+	if !validPort(c.Server.Port) {
+		add("server.port %q is not a valid port", c.Server.Port)
+	}
+	if c.Server.ReadTimeout <= 0 {
+		add("server.read_timeout must be positive")
+	}
+	if c.Server.WriteTimeout <= 0 {
+		add("server.write_timeout must be positive")
+	}
+	if c.Server.IdleTimeout <= 0 {
+		add("server.idle_timeout must be positive")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		add("server.shutdown_timeout must be positive")
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		add("server.max_header_bytes must be positive")
+	}
+	if c.Server.TLS.Enabled {
+		if c.Server.TLS.CertFile == "" {
+			add("server.tls.cert_file is required when tls is enabled")
+		}
+		if c.Server.TLS.KeyFile == "" {
+			add("server.tls.key_file is required when tls is enabled")
+		}
+		if c.Server.TLS.ReloadInterval <= 0 {
+			add("server.tls.reload_interval must be positive")
+		}
+
+		switch c.Server.TLS.ClientAuth {
+		case ClientAuthNone:
+		case ClientAuthOptional, ClientAuthRequire:
+			if c.Server.TLS.ClientCAFile == "" {
+				add("server.tls.client_ca_file is required when client_auth is %s", c.Server.TLS.ClientAuth)
+			}
+		default:
+			add("server.tls.client_auth %q must be none, optional or require", c.Server.TLS.ClientAuth)
+		}
+
+		if c.Server.TLS.RedirectPort != "" {
+			if !validPort(c.Server.TLS.RedirectPort) {
+				add("server.tls.redirect_port %q is not a valid port", c.Server.TLS.RedirectPort)
+			} else if c.Server.TLS.RedirectPort == c.Server.Port {
+				add("server.tls.redirect_port must differ from server.port")
+			}
+		}
+	}
+
+	if c.GRPC.Enabled {
+		if !validPort(c.GRPC.Port) {
+			add("grpc.port %q is not a valid port", c.GRPC.Port)
+		} else if c.GRPC.Port == c.Server.Port || c.GRPC.Port == c.Server.TLS.RedirectPort {
+			add("grpc.port must differ from the ports of the server")
+		}
+	}
+
+	if c.GraphQL.MaxDepth < 1 {
+		add("graphql.max_depth must be positive")
+	}
+	if c.GraphQL.MaxComplexity < 1 {
+		add("graphql.max_complexity must be positive")
+	}
+
+	switch c.Storage.Backend {
+	case StorageMemory:
+	case StorageFile:
+		if c.Storage.DSN == "" {
+			add("storage.dsn must name a file for the file backend")
+		}
+	default:
+		add("storage.backend %q is not supported", c.Storage.Backend)
+	}
+
+	if c.Hashing.Time < 1 {
+		add("hashing.time must be at least 1")
+	}
+	if c.Hashing.Threads < 1 {
+		add("hashing.threads must be at least 1")
+	}
+	if c.Hashing.Memory < 8*uint32(c.Hashing.Threads) {
+		add("hashing.memory must be at least 8 KiB per thread")
+	}
+	if c.Hashing.KeyLen < 16 {
+		add("hashing.key_len must be at least 16")
+	}
+	if c.Hashing.SaltLen < 8 {
+		add("hashing.salt_len must be at least 8")
+	}
+
+	if c.Bootstrap.Username == "" {
+		add("bootstrap.username is required")
+	}
+	if c.App.Env == EnvProduction && c.Bootstrap.Password == DefaultAdminPassword {
+		add("bootstrap.password can't be the default password in production")
+	}
+
+	if _, err := zerolog.ParseLevel(c.Log.Level); err != nil {
+		add("log.level %q is not a valid level", c.Log.Level)
+	}
+	if c.Log.Format != "json" && c.Log.Format != "console" {
+		add("log.format %q must be json or console", c.Log.Format)
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if c.Tracing.Endpoint == "" {
+			add("tracing.endpoint is required for the otlp exporter")
+		}
+	default:
+		add("tracing.exporter %q must be none, stdout or otlp", c.Tracing.Exporter)
+	}
+
+	if c.CORS.AllowCredentials {
+		for _, origin := range c.CORS.AllowedOrigins {
+			if origin == "*" {
+				add("cors.allowed_origins can't contain * when cors.allow_credentials is set")
+				break
+			}
+		}
+	}
+	if c.CORS.MaxAge < 0 {
+		add("cors.max_age can't be negative")
+	}
+
+	if c.RateLimit.RequestsPerSecond < 0 {
+		add("rate_limit.requests_per_second can't be negative")
+	}
+	if c.RateLimit.RequestsPerSecond > 0 && c.RateLimit.Burst < 1 {
+		add("rate_limit.burst must be at least 1 when rate limiting is enabled")
+	}
+
+	if c.Password.MinLength < 0 {
+		add("password.min_length can't be negative")
+	}
+
+	if c.Session.Store != SessionStoreMemory && c.Session.Store != SessionStoreRepository {
+		add("session.store %q must be memory or repository", c.Session.Store)
+	}
+	if c.Session.TTL <= 0 {
+		add("session.ttl must be positive")
+	}
+	if c.Session.IdleTimeout < 0 {
+		add("session.idle_timeout can't be negative")
+	}
+	if c.Session.CookieName == "" {
+		add("session.cookie_name is required")
+	}
+	switch c.Session.SameSite {
+	case SameSiteLax, SameSiteStrict:
+	case SameSiteNone:
+		if !c.Session.CookieSecure {
+			add("session.same_site none requires session.cookie_secure")
+		}
+	default:
+		add("session.same_site %q must be lax, strict or none", c.Session.SameSite)
+	}
+
+	if c.Idempotency.Store != IdempotencyStoreMemory && c.Idempotency.Store != IdempotencyStoreRepository {
+		add("idempotency.store %q must be memory or repository", c.Idempotency.Store)
+	}
+	if c.Idempotency.TTL <= 0 {
+		add("idempotency.ttl must be positive")
+	}
+	if c.Idempotency.MaxBodySize <= 0 {
+		add("idempotency.max_body_size must be positive")
+	}
+
+	if c.TwoFactor.Issuer == "" {
+		add("two_factor.issuer is required")
+	}
+	for _, role := range c.TwoFactor.RequiredRoles {
+		if role != RoleAdmin && role != RoleUser {
+			add("two_factor.required_roles: %q must be admin or user", role)
+		}
+	}
+
+	switch c.Notify.Backend {
+	case NotifyLog:
+	case NotifyFile:
+		if c.Notify.File == "" {
+			add("notify.file is required for the file backend")
+		}
+	case NotifySMTP:
+		if c.Notify.SMTP.Host == "" {
+			add("notify.smtp.host is required for the smtp backend")
+		}
+		if !validPort(c.Notify.SMTP.Port) {
+			add("notify.smtp.port %q is not a valid port", c.Notify.SMTP.Port)
+		}
+		if c.Notify.SMTP.From == "" {
+			add("notify.smtp.from is required for the smtp backend")
+		}
+		if c.Notify.SMTP.Timeout <= 0 {
+			add("notify.smtp.timeout must be positive")
+		}
+	default:
+		add("notify.backend %q must be log, file or smtp", c.Notify.Backend)
+	}
+
+	if c.PasswordReset.TTL <= 0 {
+		add("reset.ttl must be positive")
+	}
+
+	if c.Verification.TTL <= 0 {
+		add("verification.ttl must be positive")
+	}
+	for _, prefix := range c.Verification.BlockRoutes {
+		if !strings.HasPrefix(prefix, "/") {
+			add("verification.block_routes: %q must start with /", prefix)
+		}
+	}
+
+	switch c.Registration.Mode {
+	case RegistrationDisabled, RegistrationOpen, RegistrationInvite:
+	case RegistrationDomain:
+		if len(c.Registration.Domains) == 0 {
+			add("registration.domains is required in domain mode")
+		}
+	default:
+		add("registration.mode %q must be disabled, open, invite or domain", c.Registration.Mode)
+	}
+	if c.Registration.InviteTTL <= 0 {
+		add("registration.invite_ttl must be positive")
+	}
+	if c.Registration.RateLimit.RequestsPerSecond < 0 {
+		add("registration.rate_limit.requests_per_second can't be negative")
+	}
+	if c.Registration.RateLimit.RequestsPerSecond > 0 && c.Registration.RateLimit.Burst < 1 {
+		add("registration.rate_limit.burst must be at least 1 when rate limiting is enabled")
+	}
+
+	if c.OAuth.CodeTTL <= 0 {
+		add("oauth.code_ttl must be positive")
+	}
+	if c.OAuth.AccessTokenTTL <= 0 {
+		add("oauth.access_token_ttl must be positive")
+	}
+	if c.OAuth.RefreshTokenTTL <= 0 {
+		add("oauth.refresh_token_ttl must be positive")
+	}
+
+	if c.OIDC.Issuer != "" {
+		u, err := url.Parse(c.OIDC.Issuer)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" ||
+			u.RawQuery != "" || u.Fragment != "" || strings.HasSuffix(u.Path, "/") {
+			add("oidc.issuer %q must be an http(s) URL without query, fragment or trailing slash", c.OIDC.Issuer)
+		}
+	}
+	switch c.OIDC.Algorithm {
+	case SigningRS256, SigningES256:
+	default:
+		add("oidc.algorithm %q must be RS256 or ES256", c.OIDC.Algorithm)
+	}
+	if c.OIDC.KeyRotation <= 0 {
+		add("oidc.key_rotation must be positive")
+	}
+	if c.OIDC.IDTokenTTL <= 0 {
+		add("oidc.id_token_ttl must be positive")
+	}
+
+	seen := make(map[string]bool)
+	for n, conn := range c.Federation.Connectors {
+		name := fmt.Sprintf("federation.connectors[%d]", n)
+		if conn.ID == "" || strings.ContainsAny(conn.ID, "/?#") {
+			add("%s.id %q must be set and can't contain /, ? or #", name, conn.ID)
+		} else if seen[conn.ID] {
+			add("%s.id %q is used twice", name, conn.ID)
+		} else if conn.ID == ConnectorLDAP {
+			add("%s.id %q is reserved", name, conn.ID)
+		}
+		seen[conn.ID] = true
+
+		if !absoluteURL(conn.Issuer) {
+			add("%s.issuer %q must be an http(s) URL", name, conn.Issuer)
+		}
+		if !absoluteURL(conn.RedirectURL) {
+			add("%s.redirect_url %q must be an http(s) URL", name, conn.RedirectURL)
+		}
+		if conn.ClientID == "" {
+			add("%s.client_id is required", name)
+		}
+	}
+
+	if c.LDAP.Enabled {
+		u, err := url.Parse(c.LDAP.URL)
+		switch {
+		case err != nil || u.Host == "" || (u.Scheme != "ldap" && u.Scheme != "ldaps"):
+			add("ldap.url %q must be an ldap:// or ldaps:// URL", c.LDAP.URL)
+		case u.Scheme == "ldaps" && c.LDAP.StartTLS:
+			add("ldap.start_tls can't be used with an ldaps:// URL")
+		}
+		if c.LDAP.BaseDN == "" {
+			add("ldap.base_dn is required")
+		}
+		if strings.Count(c.LDAP.UserFilter, "%s") != 1 {
+			add("ldap.user_filter %q must contain %%s once", c.LDAP.UserFilter)
+		}
+		if strings.Count(c.LDAP.GroupFilter, "%s") != 1 {
+			add("ldap.group_filter %q must contain %%s once", c.LDAP.GroupFilter)
+		}
+		for n, g := range c.LDAP.GroupRoles {
+			if g.DN == "" {
+				add("ldap.group_roles[%d].dn is required", n)
+			}
+			if g.Role != RoleAdmin && g.Role != RoleUser {
+				add("ldap.group_roles[%d].role %q must be admin or user", n, g.Role)
+			}
+		}
+		if c.LDAP.Timeout <= 0 {
+			add("ldap.timeout must be positive")
+		}
+	}
+
+	if len(reason) != 0 {
+		return &ValidationError{Problems: reason}
+	}
+
+	return nil
+}
+
+func absoluteURL(s string) bool {
+	u, err := url.Parse(s)
+
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+func validPort(port string) bool {
+	p, err := strconv.Atoi(port)
+
+	return err == nil && p >= 1 && p <= 65535
+}
@@ -1,10 +1,22 @@
 package controller
 
+import "time"
+
 type UserResponse struct {
 	ID       string `json:"id"`
 	Email    string `json:"email"`
 	Username string `json:"username"`
 	Admin    bool   `json:"admin"`
+	// EmailVerified is set once the user confirmed a token sent to Email.
+	EmailVerified bool `json:"email_verified"`
+	// Tenant is empty for users of the default tenant.
+	Tenant string `json:"tenant,omitempty"`
+	// ServiceAccount marks users that authenticate with API keys only.
+	ServiceAccount bool `json:"service_account,omitempty"`
+	// Disabled users can't sign in.
+	Disabled  bool      `json:"disabled,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type UserRequest struct {
@@ -13,3 +25,277 @@ type UserRequest struct {
 	Password string `json:"password"`
 	Admin    bool   `json:"admin"`
 }
+
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// Code is a TOTP or recovery code, required when two-factor
+	// authentication is enabled.
+	Code string `json:"code,omitempty"`
+}
+
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current marks the session of the request.
+	Current bool `json:"current"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorStatusResponse struct {
+	Enabled           bool `json:"enabled"`
+	Required          bool `json:"required"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth:// key URI, the payload of the QR code.
+	URI string `json:"uri"`
+	// QRCode is URI as a PNG data URL.
+	QRCode string `json:"qr_code"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type RegisterRequest struct {
+	UserRequest
+	// InviteCode is required when registration is invite-only.
+	InviteCode string `json:"invite_code,omitempty"`
+}
+
+type InviteRequest struct {
+	// Email, when set, is the only address the invite works for, and the
+	// code is also sent to it.
+	Email string `json:"email,omitempty"`
+}
+
+type InviteResponse struct {
+	Code      string    `json:"code"`
+	Email     string    `json:"email,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type TenantRequest struct {
+	// ID names the tenant in paths and the X-Tenant header, it can't be
+	// changed.
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Disabled bool   `json:"disabled"`
+}
+
+type TenantResponse struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type GroupRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Permissions granted to the members, see README for the list.
+	Permissions []string `json:"permissions"`
+}
+
+type GroupResponse struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Page describes the slice of a list a response holds.
+type Page struct {
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type GroupListResponse struct {
+	Groups []GroupResponse `json:"groups"`
+	Page
+}
+
+type MemberListResponse struct {
+	Members []UserResponse `json:"members"`
+	Page
+}
+
+type ServiceAccountRequest struct {
+	Username string `json:"username"`
+	Admin    bool   `json:"admin"`
+}
+
+type APIKeyRequest struct {
+	Name string `json:"name"`
+	// Scopes are read or write, plus any permissions the key may use, see
+	// README.
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is optional, keys without it don't expire.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	Prefix     string     `json:"prefix"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	Expired    bool       `json:"expired"`
+}
+
+type APIKeyCreatedResponse struct {
+	APIKeyResponse
+	// Key is only ever shown in this response.
+	Key string `json:"key"`
+}
+
+type OAuthClientRequest struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	// GrantTypes are authorization_code, client_credentials and
+	// refresh_token.
+	GrantTypes []string `json:"grant_types"`
+	// Scopes the client may ask for.
+	Scopes []string `json:"scopes"`
+	// Confidential clients get a secret, public ones must use PKCE. It
+	// can't be changed.
+	Confidential bool `json:"confidential"`
+}
+
+type OAuthClientResponse struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	GrantTypes   []string  `json:"grant_types"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	CreatedAt    time.Time `json:"created_at"`
+	// Secret is only shown when it is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+// ConsentRequiredResponse asks the user to allow a client, by sending the
+// same authorization request with POST and consent=allow or consent=deny.
+type ConsentRequiredResponse struct {
+	ClientID    string   `json:"client_id"`
+	ClientName  string   `json:"client_name"`
+	RedirectURI string   `json:"redirect_uri"`
+	Scopes      []string `json:"scopes"`
+}
+
+type ConsentResponse struct {
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+}
+
+// OAuthTokenResponse follows RFC 6749, without the data envelope.
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	// IDToken is issued to users' tokens with the openid scope.
+	IDToken string `json:"id_token,omitempty"`
+}
+
+// OAuthErrorResponse follows RFC 6749, without the error envelope.
+type OAuthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// IntrospectionResponse follows RFC 7662.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Tenant    string `json:"tenant,omitempty"`
+}
+
+// DiscoveryResponse is the provider metadata of OpenID Connect Discovery 1.0.
+type DiscoveryResponse struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RevocationEndpoint                string   `json:"revocation_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+type ConnectorResponse struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// LoginURL starts a login through the connector.
+	LoginURL string `json:"login_url"`
+}
+
+type IdentityResponse struct {
+	Connector   string    `json:"connector"`
+	Subject     string    `json:"subject"`
+	Email       string    `json:"email,omitempty"`
+	Provisioned bool      `json:"provisioned"`
+	LinkedAt    time.Time `json:"linked_at"`
+	LastLoginAt time.Time `json:"last_login_at,omitempty"`
+}
+
+// LinkIdentityResponse names where to send the browser to link an identity.
+type LinkIdentityResponse struct {
+	URL string `json:"url"`
+}
+
+// GraphQLRequest is a GraphQL operation sent to /graphql.
+type GraphQLRequest struct {
+	Query string `json:"query"`
+	// OperationName picks the operation to run when Query holds several.
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty" swaggertype:"object"`
+}
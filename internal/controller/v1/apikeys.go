@@ -0,0 +1,303 @@
+package v1
+
+import (
+	"dev/profileSaver/internal/apikey"
+	"dev/profileSaver/internal/bootstrap"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/tenant"
+	"encoding/json"
+	"errors"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+	"time"
+)
+
+// getOwnAPIKeys
+// @Summary Get own API keys
+// @Tags Me
+// @Description Get the API keys of the authenticated user, newest first. Not available to API keys.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Success 200 {array} controller.APIKeyResponse
+// @Failure 403
+// @Router /v1/me/api-keys [GET]
+func (h *Handler) getOwnAPIKeys(w http.ResponseWriter, req bunrouter.Request) error {
+	return h.responseJSON(w, req, http.StatusOK, h.apiKeyList(req, authenticatedUser(req.Context()).ID))
+}
+
+// createOwnAPIKey
+// @Summary Create own API key
+// @Tags Me
+// @Description Create an API key for the authenticated user. The key is only shown in this response, send it as "Authorization: ApiKey <key>". Permission scopes must be held by the caller. Not available to API keys.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param input body controller.APIKeyRequest true "key"
+// @Success 200 {object} controller.APIKeyCreatedResponse
+// @Failure 400
+// @Failure 403
+// @Failure 500
+// @Router /v1/me/api-keys [POST]
+func (h *Handler) createOwnAPIKey(w http.ResponseWriter, req bunrouter.Request) error {
+	return h.issueAPIKey(w, req, authenticatedUser(req.Context()))
+}
+
+// deleteOwnAPIKey
+// @Summary Revoke own API key
+// @Tags Me
+// @Description Revoke an API key of the authenticated user. Not available to API keys.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "key id"
+// @Success 200
+// @Failure 403
+// @Failure 404
+// @Router /v1/me/api-keys/{id} [DELETE]
+func (h *Handler) deleteOwnAPIKey(w http.ResponseWriter, req bunrouter.Request) error {
+	return h.revokeAPIKey(w, req, authenticatedUser(req.Context()).ID, req.Params().ByName("id"))
+}
+
+// getUserAPIKeys
+// @Summary Get user API keys
+// @Tags User
+// @Description Get the API keys of a user, newest first. Needs the user:write permission, and admin for keys of admins. Not available to API keys.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "user id"
+// @Success 200 {array} controller.APIKeyResponse
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Router /v1/user/{id}/api-keys [GET]
+func (h *Handler) getUserAPIKeys(w http.ResponseWriter, req bunrouter.Request) error {
+	target, ok, err := h.loadManagedUser(w, req)
+	if !ok {
+		return err
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, h.apiKeyList(req, target.ID))
+}
+
+// createUserAPIKey
+// @Summary Create user API key
+// @Tags User
+// @Description Create an API key for a user, typically a service account. The key is only shown in this response. Needs the user:write permission, and admin for keys of admins. Permission scopes must be held by the caller. Not available to API keys.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "user id"
+// @Param input body controller.APIKeyRequest true "key"
+// @Success 200 {object} controller.APIKeyCreatedResponse
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Router /v1/user/{id}/api-keys [POST]
+func (h *Handler) createUserAPIKey(w http.ResponseWriter, req bunrouter.Request) error {
+	target, ok, err := h.loadManagedUser(w, req)
+	if !ok {
+		return err
+	}
+
+	return h.issueAPIKey(w, req, target)
+}
+
+// deleteUserAPIKey
+// @Summary Revoke user API key
+// @Tags User
+// @Description Revoke an API key of a user. Needs the user:write permission, and admin for keys of admins. Not available to API keys.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "user id"
+// @Param key_id path string true "key id"
+// @Success 200
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Router /v1/user/{id}/api-keys/{key_id} [DELETE]
+func (h *Handler) deleteUserAPIKey(w http.ResponseWriter, req bunrouter.Request) error {
+	target, ok, err := h.loadManagedUser(w, req)
+	if !ok {
+		return err
+	}
+
+	return h.revokeAPIKey(w, req, target.ID, req.Params().ByName("key_id"))
+}
+
+// createServiceAccount
+// @Summary Create service account
+// @Tags User
+// @Description Create a user for machines. Service accounts can't sign in with a password and authenticate with API keys created by POST /v1/user/{id}/api-keys. Needs the user:write permission, and admin for admin accounts. Not available to API keys.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param input body controller.ServiceAccountRequest true "service account"
+// @Success 200 {object} controller.UserResponse
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 500
+// @Router /v1/service-account [POST]
+func (h *Handler) createServiceAccount(w http.ResponseWriter, req bunrouter.Request) error {
+	var input controller.ServiceAccountRequest
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	if input.Username == "" {
+		return h.responseJSON(w, req, http.StatusBadRequest, "empty username")
+	}
+
+	if !h.mayManage(req.Context(), authenticatedUser(req.Context()), model.User{}, input.Admin) {
+		return h.responseJSON(w, req, http.StatusForbidden, errManageAdmins.Error())
+	}
+
+	// Nobody learns the password, IsAuthorized refuses service accounts anyway.
+	password, err := bootstrap.GeneratePassword()
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	user, err := h.repo.CreateUser(req.Context(), model.User{
+		Username:       input.Username,
+		Password:       password,
+		Admin:          input.Admin,
+		Tenant:         tenant.FromContext(req.Context()),
+		ServiceAccount: true,
+	})
+	if errors.Is(err, repository.ErrUserNameExists) {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, userResponse(user))
+}
+
+// loadManagedUser returns the user named by the id parameter, if the
+// authenticated user may manage them. When it returns false the response has
+// been written.
+func (h *Handler) loadManagedUser(w http.ResponseWriter, req bunrouter.Request) (model.User, bool, error) {
+	target, err := h.tenantUser(req.Context(), req.Params().ByName("id"))
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return model.User{}, false, h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return model.User{}, false, h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	if !h.mayManage(req.Context(), authenticatedUser(req.Context()), target, false) {
+		return model.User{}, false, h.responseJSON(w, req, http.StatusForbidden, errManageAdmins.Error())
+	}
+
+	return target, true, nil
+}
+
+// issueAPIKey creates a key for user. Besides read or write a key may only
+// carry permissions the caller holds, so nobody can hand out more than they
+// may do themselves.
+func (h *Handler) issueAPIKey(w http.ResponseWriter, req bunrouter.Request, user model.User) error {
+	var input controller.APIKeyRequest
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	if input.Name == "" {
+		return h.responseJSON(w, req, http.StatusBadRequest, "empty name")
+	}
+
+	var (
+		permissions []string
+		access      bool
+	)
+	for _, s := range input.Scopes {
+		switch s {
+		case apikey.ScopeRead, apikey.ScopeWrite:
+			access = true
+		default:
+			permissions = append(permissions, s)
+		}
+	}
+	if !access {
+		return h.responseJSON(w, req, http.StatusBadRequest, "scopes need read or write")
+	}
+
+	if ok, err := h.checkGrant(w, req, permissions); !ok {
+		return err
+	}
+
+	var expiresAt time.Time
+	if input.ExpiresAt != nil {
+		if !input.ExpiresAt.After(time.Now()) {
+			return h.responseJSON(w, req, http.StatusBadRequest, "expires_at is in the past")
+		}
+		expiresAt = *input.ExpiresAt
+	}
+
+	key, k, err := h.apiKeys.Issue(req.Context(), user.ID, input.Name, input.Scopes, expiresAt)
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	logger.FromContext(req.Context()).Info().
+		Str("user", user.Username).
+		Str("key", k.Prefix).
+		Msg("api key created")
+
+	return h.responseJSON(w, req, http.StatusOK, controller.APIKeyCreatedResponse{
+		APIKeyResponse: apiKeyResponse(k, time.Now()),
+		Key:            key,
+	})
+}
+
+func (h *Handler) revokeAPIKey(w http.ResponseWriter, req bunrouter.Request, userID, id string) error {
+	err := h.apiKeys.Revoke(req.Context(), userID, id)
+	if errors.Is(err, apikey.ErrNotFound) {
+		return h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, "api key was revoked")
+}
+
+func (h *Handler) apiKeyList(req bunrouter.Request, userID string) []controller.APIKeyResponse {
+	keys := h.apiKeys.List(req.Context(), userID)
+	now := time.Now()
+
+	response := make([]controller.APIKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		response = append(response, apiKeyResponse(k, now))
+	}
+
+	return response
+}
+
+func apiKeyResponse(k model.APIKey, now time.Time) controller.APIKeyResponse {
+	r := controller.APIKeyResponse{
+		ID:        k.ID,
+		Prefix:    k.Prefix,
+		Name:      k.Name,
+		Scopes:    k.Scopes,
+		CreatedAt: k.CreatedAt,
+		Expired:   apikey.Expired(k, now),
+	}
+
+	if !k.ExpiresAt.IsZero() {
+		r.ExpiresAt = &k.ExpiresAt
+	}
+	if !k.LastUsedAt.IsZero() {
+		r.LastUsedAt = &k.LastUsedAt
+	}
+
+	return r
+}
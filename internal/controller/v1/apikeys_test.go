@@ -0,0 +1,140 @@
+package v1
+
+import (
+	"dev/profileSaver/internal/apikey"
+	"dev/profileSaver/internal/controller"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func Test_apiKeys(t *testing.T) {
+	s := newTestServer(t)
+	s.route(WithGroups(s.repo), WithAPIKeys(apikey.NewManager(s.repo)))
+
+	issue := func(target, body string) controller.APIKeyCreatedResponse {
+		w := s.do(testRequest{method: "POST", target: target, body: body, username: "admin", password: "admin"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp struct {
+			Data controller.APIKeyCreatedResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+		return resp.Data
+	}
+
+	var bot controller.UserResponse
+
+	t.Run("SERVICE_ACCOUNT", func(t *testing.T) {
+		w := s.do(testRequest{method: "POST", target: "/v1/service-account", body: `{"username":"batch"}`, username: "admin", password: "admin"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp struct {
+			Data controller.UserResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		bot = resp.Data
+		assert.True(t, bot.ServiceAccount)
+
+		w = s.do(testRequest{method: "POST", target: "/v1/service-account", body: `{"username":"root","admin":true}`,
+			username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = s.do(testRequest{method: "POST", target: "/v1/auth/login", body: `{"username":"batch","password":""}`})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("READ_KEY", func(t *testing.T) {
+		k := issue("/v1/user/"+bot.ID+"/api-keys", `{"name":"reports","scopes":["read"]}`)
+		assert.Equal(t, k.Prefix, k.Key[:len(k.Prefix)])
+
+		w := s.do(testRequest{method: "GET", target: "/v1/user", authorization: "ApiKey " + k.Key})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"service_account":true`)
+
+		w = s.do(testRequest{method: "DELETE", target: "/v1/me/sessions", authorization: "ApiKey " + k.Key})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, `{"error":"api key scopes don't allow this request"}
+`, w.Body.String())
+
+		w = s.do(testRequest{method: "GET", target: "/v1/user/" + bot.ID + "/api-keys", username: "admin", password: "admin"})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"last_used_at"`)
+		assert.NotContains(t, w.Body.String(), k.Key, "keys are shown once")
+
+		assert.Equal(t, http.StatusUnauthorized, s.do(testRequest{method: "GET", target: "/v1/user", authorization: "ApiKey " + k.Key + "x"}).Code)
+	})
+
+	t.Run("SCOPES_LIMIT_PERMISSIONS", func(t *testing.T) {
+		k := issue("/v1/me/api-keys", `{"name":"jobs","scopes":["write"]}`)
+
+		newUser := `{"username":"dave","email":"dave@example.com","password":"dave-password"}`
+		w := s.do(testRequest{method: "POST", target: "/v1/user", body: newUser, authorization: "ApiKey " + k.Key})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "the admin's key has no permission scopes")
+
+		k = issue("/v1/me/api-keys", `{"name":"jobs","scopes":["write","user:write"]}`)
+		w = s.do(testRequest{method: "POST", target: "/v1/user", body: newUser, authorization: "ApiKey " + k.Key})
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		w = s.do(testRequest{method: "POST", target: "/v1/user", authorization: "ApiKey " + k.Key,
+			body: `{"username":"eve","email":"eve@example.com","password":"eve-password","admin":true}`})
+		assert.Equal(t, http.StatusForbidden, w.Code, "user:write doesn't make admins")
+
+		w = s.do(testRequest{method: "POST", target: "/v1/me/api-keys", body: `{"name":"more","scopes":["write"]}`, authorization: "ApiKey " + k.Key})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, `{"error":"not available to api keys"}
+`, w.Body.String())
+
+		w = s.do(testRequest{method: "PUT", target: "/v1/me/password", body: `{}`, authorization: "ApiKey " + k.Key})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("GRANT_ONLY_HELD_PERMISSIONS", func(t *testing.T) {
+		w := s.do(testRequest{method: "POST", target: "/v1/me/api-keys", body: `{"name":"x","scopes":["write","admin"]}`,
+			username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = s.do(testRequest{method: "POST", target: "/v1/me/api-keys", body: `{"name":"x","scopes":["user:write"]}`,
+			username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = s.do(testRequest{method: "POST", target: "/v1/me/api-keys", body: `{"name":"x","scopes":["read"],"expires_at":"2000-01-01T00:00:00Z"}`,
+			username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = s.do(testRequest{method: "GET", target: "/v1/user/" + bot.ID + "/api-keys", username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("REVOKE", func(t *testing.T) {
+		w := s.do(testRequest{method: "POST", target: "/v1/me/api-keys", body: `{"name":"mine","scopes":["read"]}`,
+			username: "bob", password: "bob-password"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp struct {
+			Data controller.APIKeyCreatedResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+		w = s.do(testRequest{method: "GET", target: "/v1/me/groups", authorization: "ApiKey " + resp.Data.Key})
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = s.do(testRequest{method: "DELETE", target: "/v1/user/" + bot.ID + "/api-keys/" + resp.Data.ID, username: "admin", password: "admin"})
+		assert.Equal(t, http.StatusNotFound, w.Code, "the key belongs to bob")
+
+		w = s.do(testRequest{method: "DELETE", target: "/v1/me/api-keys/" + resp.Data.ID, username: "bob", password: "bob-password"})
+		require.Equal(t, http.StatusOK, w.Code)
+
+		assert.Equal(t, http.StatusUnauthorized, s.do(testRequest{method: "GET", target: "/v1/me/groups", authorization: "ApiKey " + resp.Data.Key}).Code)
+	})
+
+	t.Run("DELETED_USER", func(t *testing.T) {
+		k := issue("/v1/user/"+s.bob.ID+"/api-keys", `{"name":"bob","scopes":["read"]}`)
+		require.Equal(t, http.StatusOK, s.do(testRequest{method: "DELETE", target: "/v1/user/" + s.bob.ID, username: "admin", password: "admin"}).Code)
+
+		assert.Equal(t, http.StatusUnauthorized, s.do(testRequest{method: "GET", target: "/v1/user", authorization: "ApiKey " + k.Key}).Code)
+	})
+}
@@ -0,0 +1,329 @@
+package v1
+
+import (
+	"context"
+	"crypto/tls"
+	"dev/profileSaver/internal/apikey"
+	"dev/profileSaver/internal/authn"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/session"
+	"dev/profileSaver/internal/tenant"
+	"errors"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+	"strings"
+)
+
+type ctxKey int
+
+const (
+	userKey ctxKey = iota
+	sessionKey
+	apiKeyKey
+)
+
+// routeChangePassword and routeLogout stay reachable for users that must
+// change their password before doing anything else.
+const (
+	routeChangePassword = "/v1/me/password"
+	routeLogout         = "/v1/auth/logout"
+)
+
+// enrollmentRoutes stay reachable for users whose role requires two-factor
+// authentication before they have enrolled.
+var enrollmentRoutes = map[string]bool{
+	routeChangePassword:  true,
+	routeLogout:          true,
+	"/v1/me/2fa":         true,
+	"/v1/me/2fa/enroll":  true,
+	"/v1/me/2fa/confirm": true,
+}
+
+// apiKeyDenied are the routes API keys can't reach: credentials and keys are
+// only managed by a person signed in.
+var apiKeyDenied = []string{
+	routeChangePassword,
+	"/v1/me/2fa",
+	"/v1/me/api-keys",
+	"/v1/me/identities/:connector",
+	"/v1/user/:id/api-keys",
+	"/v1/service-account",
+	routeAuthorize,
+}
+
+// Errors refusing an authenticated user, all answered with 403.
+var (
+	errUserDisabled        = errors.New("user is disabled")
+	errOtherTenant         = errors.New("user belongs to another tenant")
+	errDeniedToAPIKeys     = errors.New("not available to api keys")
+	errAPIKeyScopes        = errors.New("api key scopes don't allow this request")
+	errMustChangePassword  = errors.New("password change required")
+	errTwoFactorEnrollment = errors.New("two-factor enrollment required")
+	errEmailNotVerified    = errors.New("email not verified")
+)
+
+func (h *Handler) authMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		user, s, key, ok := h.authenticate(req)
+		if !ok {
+			askPassword(w)
+			return nil
+		}
+
+		logger.WithUser(req.Context(), user.Username)
+
+		scope, err := h.admit(req.Context(), user, key, req.Route(), req.Method)
+		if err != nil {
+			return h.responseJSON(w, req, ErrorStatus(err), err.Error())
+		}
+
+		ctx := context.WithValue(req.Context(), userKey, user)
+		ctx = context.WithValue(ctx, sessionKey, s)
+		if key.ID != "" {
+			ctx = context.WithValue(ctx, apiKeyKey, key)
+		}
+		ctx = tenant.WithID(ctx, scope)
+
+		w.Header().Set("Content-Type", "application/json")
+		return next(w, req.WithContext(ctx))
+	}
+}
+
+// admit checks that the authenticated user may make a request to route with
+// method, key being the API key it was made with, and returns the tenant it
+// acts on. A request naming no tenant acts on the user's own, only
+// super-admins act on other tenants.
+func (h *Handler) admit(ctx context.Context, user model.User, key model.APIKey, route, method string) (string, error) {
+	if user.Disabled {
+		return "", errUserDisabled
+	}
+
+	scope, named := tenant.Lookup(ctx)
+	switch {
+	case !named:
+		scope = user.Tenant
+		if err := h.activeTenant(ctx, scope); err != nil {
+			return "", err
+		}
+	case scope != user.Tenant && !superAdmin(user):
+		return "", errOtherTenant
+	}
+
+	if key.ID != "" {
+		if deniedToAPIKeys(route) {
+			return "", errDeniedToAPIKeys
+		}
+		// GraphQL queries are posted too, the scope is checked per operation.
+		if !apikey.Allows(key, method) && route != routeGraphQL {
+			return "", errAPIKeyScopes
+		}
+	}
+
+	if user.MustChangePassword && route != routeChangePassword && route != routeLogout {
+		return "", errMustChangePassword
+	}
+
+	if !enrollmentRoutes[route] && h.twoFactorRequired(user) {
+		enabled, err := h.twoFactorEnabled(ctx, user.ID)
+		if err != nil {
+			return "", err
+		}
+		if !enabled {
+			return "", errTwoFactorEnrollment
+		}
+	}
+
+	if h.verificationBlocks(user, route) {
+		return "", errEmailNotVerified
+	}
+
+	return scope, nil
+}
+
+// authenticate identifies the caller by a session cookie, an API key in an
+// "Authorization: ApiKey ..." or "Bearer ..." header, a verified TLS client
+// certificate, whose subject common name must be an existing username, or
+// else by Basic auth credentials. Usernames are looked up in the tenant the
+// request names, then among the super-admins. Basic auth is refused for users
+// with two-factor authentication, they log in for a session instead. The
+// session is empty unless the cookie was used, the key unless a key was used.
+func (h *Handler) authenticate(req bunrouter.Request) (model.User, model.Session, model.APIKey, bool) {
+	ctx := req.Context()
+
+	if cookie, err := req.Cookie(h.sessions.CookieName()); err == nil {
+		if user, s, ok := h.authenticateSession(ctx, cookie.Value); ok {
+			return user, s, model.APIKey{}, true
+		}
+	}
+
+	if key, ok := apiKeyHeader(req.Header.Get("Authorization")); ok {
+		user, k, ok := h.authenticateAPIKey(ctx, key)
+		return user, model.Session{}, k, ok
+	}
+
+	if username, ok := clientCertUsername(req.TLS); ok {
+		user, ok := h.credentialUser(ctx, username, "", true)
+		return user, model.Session{}, model.APIKey{}, ok
+	}
+
+	username, password, ok := req.BasicAuth()
+	if !ok {
+		return model.User{}, model.Session{}, model.APIKey{}, false
+	}
+
+	user, ok := h.basicAuthUser(ctx, username, password)
+
+	return user, model.Session{}, model.APIKey{}, ok
+}
+
+// basicAuthUser checks Basic auth credentials, refused for users with
+// two-factor authentication.
+func (h *Handler) basicAuthUser(ctx context.Context, username, password string) (model.User, bool) {
+	user, ok := h.credentialUser(ctx, username, password, false)
+	if !ok {
+		return model.User{}, false
+	}
+
+	enabled, err := h.twoFactorEnabled(ctx, user.ID)
+	if err != nil || enabled {
+		logger.FromContext(ctx).Info().
+			Str("user", user.Username).
+			Msg("basic auth refused, two-factor authentication is enabled")
+		return model.User{}, false
+	}
+
+	return user, true
+}
+
+// credentialUser returns the user username, checking password unless the
+// username came from a verified certificate. Outside the default tenant only
+// super-admins are found in the default tenant.
+func (h *Handler) credentialUser(ctx context.Context, username, password string, viaCert bool) (model.User, bool) {
+	scopes := []context.Context{ctx}
+	if tenant.FromContext(ctx) != tenant.Default {
+		scopes = append(scopes, tenant.WithID(ctx, tenant.Default))
+	}
+
+	for i, scope := range scopes {
+		var (
+			user model.User
+			err  error
+		)
+		if viaCert {
+			user, err = h.repo.GetUserByName(scope, username)
+		} else {
+			user, err = h.authn.Authenticate(scope, username, password)
+			if err != nil && !errors.Is(err, authn.ErrInvalidCredentials) {
+				logger.FromContext(ctx).Error().Err(err).Msg("unable to check credentials")
+			}
+		}
+		if err != nil || i != 0 && !superAdmin(user) {
+			continue
+		}
+
+		return user, true
+	}
+
+	return model.User{}, false
+}
+
+func (h *Handler) authenticateSession(ctx context.Context, token string) (model.User, model.Session, bool) {
+	s, err := h.sessions.Validate(ctx, token)
+	if err != nil {
+		if !errors.Is(err, session.ErrNotFound) {
+			logger.FromContext(ctx).Error().Err(err).Msg("unable to check session")
+		}
+		return model.User{}, model.Session{}, false
+	}
+
+	user, err := h.repo.GetUserByID(ctx, s.UserID)
+	if err != nil {
+		return model.User{}, model.Session{}, false
+	}
+
+	return user, s, true
+}
+
+func (h *Handler) authenticateAPIKey(ctx context.Context, key string) (model.User, model.APIKey, bool) {
+	k, err := h.apiKeys.Authenticate(ctx, key)
+	if err != nil {
+		if !errors.Is(err, apikey.ErrInvalid) {
+			logger.FromContext(ctx).Error().Err(err).Msg("unable to check api key")
+		}
+		return model.User{}, model.APIKey{}, false
+	}
+
+	user, err := h.repo.GetUserByID(ctx, k.UserID)
+	if err != nil {
+		return model.User{}, model.APIKey{}, false
+	}
+
+	return user, k, true
+}
+
+// apiKeyHeader returns the key of an "Authorization: ApiKey ..." header, or
+// of a Bearer token that is an API key, as SCIM clients send them.
+func apiKeyHeader(authorization string) (string, bool) {
+	scheme, key, ok := strings.Cut(authorization, " ")
+	key = strings.TrimSpace(key)
+
+	switch {
+	case !ok:
+		return "", false
+	case strings.EqualFold(scheme, apikey.Scheme):
+		return key, true
+	case strings.EqualFold(scheme, "Bearer") && strings.HasPrefix(key, apikey.Prefix):
+		return key, true
+	}
+
+	return "", false
+}
+
+func deniedToAPIKeys(route string) bool {
+	for _, prefix := range apiKeyDenied {
+		if strings.HasPrefix(route, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func clientCertUsername(state *tls.ConnectionState) (string, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+
+	cn := state.VerifiedChains[0][0].Subject.CommonName
+
+	return cn, cn != ""
+}
+
+// authenticatedUser returns the user set by authMiddleware.
+func authenticatedUser(ctx context.Context) model.User {
+	user, _ := ctx.Value(userKey).(model.User)
+
+	return user
+}
+
+// currentSession returns the session set by authMiddleware, empty when the
+// request was not authenticated by a session cookie.
+func currentSession(ctx context.Context) model.Session {
+	s, _ := ctx.Value(sessionKey).(model.Session)
+
+	return s
+}
+
+// currentAPIKey returns the API key set by authMiddleware, false when the
+// request was not authenticated by a key.
+func currentAPIKey(ctx context.Context) (model.APIKey, bool) {
+	k, ok := ctx.Value(apiKeyKey).(model.APIKey)
+
+	return k, ok
+}
+
+func askPassword(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
+	w.WriteHeader(http.StatusUnauthorized)
+}
@@ -0,0 +1,86 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/authn"
+	"dev/profileSaver/internal/authn/ldap"
+	"dev/profileSaver/internal/authn/ldap/ldaptest"
+	"dev/profileSaver/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_authenticator(t *testing.T) {
+	ctx := context.Background()
+	server := ldaptest.New(
+		ldaptest.Entry{DN: "uid=alice,ou=people,dc=corp,dc=test", Password: "alice-password", Attributes: map[string][]string{
+			"uid": {"alice"}, "mail": {"alice@corp.test"},
+		}},
+	)
+	defer server.Close()
+
+	s := newTestServer(t)
+
+	cfg := config.LDAP{
+		Enabled:           true,
+		BaseDN:            "ou=people,dc=corp,dc=test",
+		UserFilter:        "(uid=%s)",
+		UsernameAttribute: "uid",
+		EmailAttribute:    "mail",
+		GroupFilter:       "(member=%s)",
+		Timeout:           time.Second,
+	}
+
+	route := func(url string, fallback bool) {
+		cfg := cfg
+		cfg.URL = url
+		directory, err := ldap.New(s.repo, cfg)
+		require.NoError(t, err)
+
+		var a authn.Authenticator = directory
+		if fallback {
+			a = authn.Chain{directory, authn.NewLocal(s.repo)}
+		}
+
+		s.route(WithAuthenticator(a))
+	}
+
+	login := func(username, password string) int {
+		return s.do(testRequest{method: "POST", target: "/v1/auth/login",
+			body: `{"username":"` + username + `","password":"` + password + `"}`}).Code
+	}
+
+	down := ldaptest.New()
+	down.Close()
+
+	t.Run("OK", func(t *testing.T) {
+		route(server.URL, true)
+		assert.Equal(t, http.StatusOK, login("alice", "alice-password"))
+		assert.Equal(t, http.StatusOK, login("bob", "bob-password"), "local users are the fallback")
+
+		w := s.do(testRequest{method: "GET", target: "/v1/me/groups", username: "alice", password: "alice-password"})
+		assert.Equal(t, http.StatusOK, w.Code, "basic auth asks the directory too")
+
+		alice, err := s.repo.GetUserByName(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, "alice@corp.test", alice.Email)
+		assert.Len(t, s.repo.GetIdentitiesByUser(ctx, alice.ID), 1)
+
+		route(down.URL, true)
+		assert.Equal(t, http.StatusOK, login("bob", "bob-password"), "local users log in while the directory is down")
+	})
+
+	t.Run("NOT_OK", func(t *testing.T) {
+		route(server.URL, true)
+		assert.Equal(t, http.StatusUnauthorized, login("alice", "nope"))
+		route(server.URL, false)
+		assert.Equal(t, http.StatusUnauthorized, login("bob", "bob-password"), "without fallback only the directory is asked")
+		route(down.URL, false)
+		assert.Equal(t, http.StatusServiceUnavailable, login("alice", "alice-password"))
+		route(down.URL, true)
+		assert.Equal(t, http.StatusServiceUnavailable, login("alice", "alice-password"))
+	})
+}
@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"dev/profileSaver/internal/config"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// corsMiddleware answers preflight requests and sets the CORS response
+// headers for allowed origins. It is a no-op when no origins are configured.
+// The config is read per request so reloaded origins apply immediately.
+func corsMiddleware(live *config.Live) bunrouter.MiddlewareFunc {
+	return func(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+		return func(w http.ResponseWriter, req bunrouter.Request) error {
+			cfg := live.Get().CORS
+
+			origin := req.Header.Get("Origin")
+			if origin == "" || !originAllowed(cfg.AllowedOrigins, origin) {
+				return next(w, req)
+			}
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			h.Set("Access-Control-Allow-Origin", origin)
+			if cfg.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+				h.Set("Access-Control-Allow-Methods", strings.Join(cfg.AllowedMethods, ", "))
+				h.Set("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+				w.WriteHeader(http.StatusNoContent)
+				return nil
+			}
+
+			return next(w, req)
+		}
+	}
+}
+
+func originAllowed(allowed []string, origin string) bool {
+	for _, o := range allowed {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+
+	return false
+}
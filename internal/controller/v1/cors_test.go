@@ -0,0 +1,64 @@
+package v1
+
+import (
+	"dev/profileSaver/internal/config"
+	mock_repository "dev/profileSaver/internal/repository/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_corsMiddleware(t *testing.T) {
+	cfg := config.CORS{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Authorization"},
+		MaxAge:         time.Minute,
+	}
+
+	tests := []struct {
+		name               string
+		origin             string
+		expectedStatusCode int
+		expectedOrigin     string
+		expectedMethods    string
+	}{
+		{
+			name:               "PREFLIGHT",
+			origin:             "https://app.example.com",
+			expectedStatusCode: 204,
+			expectedOrigin:     "https://app.example.com",
+			expectedMethods:    "GET, POST",
+		},
+		{
+			name:               "FOREIGN_ORIGIN",
+			origin:             "https://evil.example.com",
+			expectedStatusCode: 401,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repo := mock_repository.NewMockRepository(c)
+			repo.EXPECT().IsAuthorized(gomock.Any(), "", "").Return(false).AnyTimes()
+
+			r := New(repo, WithConfig(config.NewLive(config.Config{CORS: cfg}))).InitRouter()
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("OPTIONS", "/v1/user", nil)
+			req.Header.Set("Origin", test.origin)
+			req.Header.Set("Access-Control-Request-Method", "POST")
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, test.expectedMethods, w.Header().Get("Access-Control-Allow-Methods"))
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package v1
+
+import (
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/federation"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/tenant"
+	"errors"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+)
+
+// getConnectors
+// @Summary List login connectors
+// @Tags Auth
+// @Description List the upstream identity providers users of the tenant can log in with.
+// @Produce  json
+// @Success 200 {array} controller.ConnectorResponse
+// @Router /v1/auth/connectors [GET]
+func (h *Handler) getConnectors(w http.ResponseWriter, req bunrouter.Request) error {
+	prefix := ""
+	if id, named := tenant.Lookup(req.Context()); named {
+		prefix = tenant.PathPrefix + id
+	}
+
+	connectors := h.federation.Connectors(tenant.FromContext(req.Context()))
+
+	response := make([]controller.ConnectorResponse, 0, len(connectors))
+	for _, c := range connectors {
+		response = append(response, controller.ConnectorResponse{
+			ID:       c.ID,
+			Name:     c.Name,
+			LoginURL: prefix + "/v1/auth/connectors/" + c.ID + "/login",
+		})
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, response)
+}
+
+// connectorLogin
+// @Summary Log in through connector
+// @Tags Auth
+// @Description Redirect the browser to the identity provider of a connector. It comes back to the callback, which starts a session.
+// @Param id path string true "connector id"
+// @Param redirect query string false "path on this service to go to after the login"
+// @Success 302
+// @Failure 400
+// @Failure 404
+// @Failure 502
+// @Router /v1/auth/connectors/{id}/login [GET]
+func (h *Handler) connectorLogin(w http.ResponseWriter, req bunrouter.Request) error {
+	target, err := h.federation.Begin(req.Context(), req.Params().ByName("id"), federation.LoginRequest{
+		Redirect: req.URL.Query().Get("redirect"),
+	})
+	if err != nil {
+		return h.federationError(w, req, err)
+	}
+
+	http.Redirect(w, req.Request, target, http.StatusFound)
+
+	return nil
+}
+
+// connectorCallback
+// @Summary Connector callback
+// @Tags Auth
+// @Description Where the identity provider sends the browser back to. Logs the user of the identity in, creating them if the connector provisions users, and sets the session cookie. Redirects when the login asked for it. Users with two-factor authentication are not asked for a code, the provider authenticated them.
+// @Produce  json
+// @Param id path string true "connector id"
+// @Param code query string true "authorization code"
+// @Param state query string true "state"
+// @Success 200 {object} controller.SessionResponse
+// @Success 302
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Failure 409
+// @Failure 502
+// @Router /v1/auth/connectors/{id}/callback [GET]
+func (h *Handler) connectorCallback(w http.ResponseWriter, req bunrouter.Request) error {
+	q := req.URL.Query()
+	if e := q.Get("error"); e != "" {
+		return h.responseJSON(w, req, http.StatusUnauthorized, "identity provider refused the login: "+e)
+	}
+
+	res, err := h.federation.Complete(req.Context(), req.Params().ByName("id"), q.Get("code"), q.Get("state"))
+	if err != nil {
+		return h.federationError(w, req, err)
+	}
+
+	logger.WithUser(req.Context(), res.User.Username)
+	logger.FromContext(req.Context()).Info().
+		Str("connector", res.Identity.Connector).
+		Bool("linked", res.Linked).
+		Bool("provisioned", res.Provisioned).
+		Msg("federated login")
+
+	if res.User.Disabled {
+		return h.responseJSON(w, req, http.StatusForbidden, "user is disabled")
+	}
+
+	if res.LinkOnly {
+		if res.Redirect != "" {
+			http.Redirect(w, req.Request, res.Redirect, http.StatusFound)
+			return nil
+		}
+
+		return h.responseJSON(w, req, http.StatusOK, identityResponse(res.Identity))
+	}
+
+	if h.verificationBlocks(res.User, req.Route()) {
+		return h.responseJSON(w, req, http.StatusForbidden, "email not verified")
+	}
+
+	token, s, err := h.sessions.Create(req.Context(), res.User.ID, clientIP(req.Request), req.UserAgent())
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	http.SetCookie(w, h.sessions.Cookie(token, s))
+
+	if res.Redirect != "" {
+		http.Redirect(w, req.Request, res.Redirect, http.StatusFound)
+		return nil
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, sessionResponse(s, s.ID))
+}
+
+// getOwnIdentities
+// @Summary Get own linked identities
+// @Tags Me
+// @Description Get the accounts at upstream identity providers linked to the authenticated user.
+// @Produce  json
+// @Security BasicAuth
+// @Success 200 {array} controller.IdentityResponse
+// @Router /v1/me/identities [GET]
+func (h *Handler) getOwnIdentities(w http.ResponseWriter, req bunrouter.Request) error {
+	return h.responseJSON(w, req, http.StatusOK, h.identityList(req, authenticatedUser(req.Context()).ID))
+}
+
+// linkOwnIdentity
+// @Summary Link own identity
+// @Tags Me
+// @Description Start linking an account at the identity provider of a connector to the authenticated user. Send the browser to the returned URL, the callback links the account the user logs in with there. Not available to API keys.
+// @Produce  json
+// @Security BasicAuth
+// @Param connector path string true "connector id"
+// @Param redirect query string false "path on this service to go to after linking"
+// @Success 200 {object} controller.LinkIdentityResponse
+// @Failure 400
+// @Failure 403
+// @Failure 404
+// @Failure 502
+// @Router /v1/me/identities/{connector} [POST]
+func (h *Handler) linkOwnIdentity(w http.ResponseWriter, req bunrouter.Request) error {
+	target, err := h.federation.Begin(req.Context(), req.Params().ByName("connector"), federation.LoginRequest{
+		Redirect:   req.URL.Query().Get("redirect"),
+		LinkUserID: authenticatedUser(req.Context()).ID,
+	})
+	if err != nil {
+		return h.federationError(w, req, err)
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, controller.LinkIdentityResponse{URL: target})
+}
+
+// unlinkOwnIdentity
+// @Summary Unlink own identity
+// @Tags Me
+// @Description Unlink the account at a connector from the authenticated user. Not available to API keys.
+// @Produce  json
+// @Security BasicAuth
+// @Param connector path string true "connector id"
+// @Success 200
+// @Failure 403
+// @Failure 404
+// @Router /v1/me/identities/{connector} [DELETE]
+func (h *Handler) unlinkOwnIdentity(w http.ResponseWriter, req bunrouter.Request) error {
+	return h.unlinkIdentity(w, req, authenticatedUser(req.Context()).ID)
+}
+
+// getUserIdentities
+// @Summary Get user linked identities
+// @Tags User
+// @Description Get the accounts at upstream identity providers linked to a user. Needs the user:write permission, and admin for admins.
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "user id"
+// @Success 200 {array} controller.IdentityResponse
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Router /v1/user/{id}/identities [GET]
+func (h *Handler) getUserIdentities(w http.ResponseWriter, req bunrouter.Request) error {
+	target, ok, err := h.loadManagedUser(w, req)
+	if !ok {
+		return err
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, h.identityList(req, target.ID))
+}
+
+// unlinkUserIdentity
+// @Summary Unlink user identity
+// @Tags User
+// @Description Unlink the account at a connector from a user. Needs the user:write permission, and admin for admins.
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "user id"
+// @Param connector path string true "connector id"
+// @Success 200
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Router /v1/user/{id}/identities/{connector} [DELETE]
+func (h *Handler) unlinkUserIdentity(w http.ResponseWriter, req bunrouter.Request) error {
+	target, ok, err := h.loadManagedUser(w, req)
+	if !ok {
+		return err
+	}
+
+	return h.unlinkIdentity(w, req, target.ID)
+}
+
+func (h *Handler) unlinkIdentity(w http.ResponseWriter, req bunrouter.Request, userID string) error {
+	connector := req.Params().ByName("connector")
+
+	err := h.federation.Unlink(req.Context(), userID, connector)
+	if errors.Is(err, repository.ErrIdentityNotFound) {
+		return h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	logger.FromContext(req.Context()).Info().Str("connector", connector).Msg("identity unlinked")
+
+	return h.responseJSON(w, req, http.StatusOK, "identity unlinked")
+}
+
+func (h *Handler) identityList(req bunrouter.Request, userID string) []controller.IdentityResponse {
+	identities := h.federation.Identities(req.Context(), userID)
+
+	response := make([]controller.IdentityResponse, 0, len(identities))
+	for _, i := range identities {
+		response = append(response, identityResponse(i))
+	}
+
+	return response
+}
+
+// federationError answers with the status matching an error of a login
+// through a connector.
+func (h *Handler) federationError(w http.ResponseWriter, req bunrouter.Request, err error) error {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, federation.ErrConnectorNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, federation.ErrInvalidState), errors.Is(err, federation.ErrInvalidRedirect):
+		code = http.StatusBadRequest
+	case errors.Is(err, federation.ErrNotLinked):
+		code = http.StatusForbidden
+	case errors.Is(err, federation.ErrIdentityLinked), errors.Is(err, federation.ErrUsernameTaken):
+		code = http.StatusConflict
+	case errors.Is(err, federation.ErrUpstream):
+		code = http.StatusBadGateway
+	}
+
+	return h.responseJSON(w, req, code, err.Error())
+}
+
+func identityResponse(i model.Identity) controller.IdentityResponse {
+	return controller.IdentityResponse{
+		Connector:   i.Connector,
+		Subject:     i.Subject,
+		Email:       i.Email,
+		Provisioned: i.Provisioned,
+		LinkedAt:    i.LinkedAt,
+		LastLoginAt: i.LastLoginAt,
+	}
+}
@@ -0,0 +1,135 @@
+package v1
+
+import (
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/federation"
+	"dev/profileSaver/internal/federation/fakeidp"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func Test_federation(t *testing.T) {
+	idp := fakeidp.New("profile-saver", "secret")
+	defer idp.Close()
+
+	s := newTestServer(t)
+
+	manager := federation.NewManager(s.repo, []config.Connector{{
+		ID:           "corp",
+		Name:         "Corp SSO",
+		Issuer:       idp.Issuer(),
+		ClientID:     "profile-saver",
+		ClientSecret: "secret",
+		RedirectURL:  "http://example.com/v1/auth/connectors/corp/callback",
+		Provision:    true,
+	}}, nil)
+
+	s.route(WithFederation(manager))
+
+	// callback lets the provider sign in at authURL and follows its redirect
+	// back to us.
+	callback := func(authURL string) *httptest.ResponseRecorder {
+		back, err := idp.Authorize(authURL)
+		require.NoError(t, err)
+
+		return s.do(testRequest{method: "GET", target: back.RequestURI()})
+	}
+
+	t.Run("CONNECTORS", func(t *testing.T) {
+		w := s.do(testRequest{method: "GET", target: "/v1/auth/connectors"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp struct {
+			Data []controller.ConnectorResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, []controller.ConnectorResponse{{
+			ID: "corp", Name: "Corp SSO", LoginURL: "/v1/auth/connectors/corp/login",
+		}}, resp.Data)
+	})
+
+	t.Run("LOGIN", func(t *testing.T) {
+		idp.SignIn(map[string]interface{}{"sub": "u-alice", "preferred_username": "alice"})
+
+		w := s.do(testRequest{method: "GET", target: "/v1/auth/connectors/corp/login?redirect=/app"})
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+
+		w = callback(w.Header().Get("Location"))
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+		assert.Equal(t, "/app", w.Header().Get("Location"))
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+
+		w = s.do(testRequest{method: "GET", target: "/v1/me/identities", cookie: cookies[0]})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp struct {
+			Data []controller.IdentityResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Len(t, resp.Data, 1)
+		assert.Equal(t, "u-alice", resp.Data[0].Subject)
+		assert.True(t, resp.Data[0].Provisioned)
+	})
+
+	t.Run("LINK", func(t *testing.T) {
+		w := s.do(testRequest{method: "POST", target: "/v1/me/identities/corp", username: "bob", password: "bob-password"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var link struct {
+			Data controller.LinkIdentityResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &link))
+
+		idp.SignIn(map[string]interface{}{"sub": "u-bob", "preferred_username": "bob"})
+		w = callback(link.Data.URL)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Empty(t, w.Result().Cookies(), "linking logs nobody in")
+
+		w = s.do(testRequest{method: "GET", target: "/v1/auth/connectors/corp/login"})
+		w = callback(w.Header().Get("Location"))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+
+		w = s.do(testRequest{method: "GET", target: "/v1/me/identities", cookie: cookies[0]})
+		assert.Contains(t, w.Body.String(), "u-bob", "logged in as bob")
+
+		w = s.do(testRequest{method: "GET", target: "/v1/user/" + s.bob.ID + "/identities", username: "admin", password: "admin"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), "u-bob")
+
+		w = s.do(testRequest{method: "DELETE", target: "/v1/user/" + s.bob.ID + "/identities/corp", username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "needs user:write")
+
+		w = s.do(testRequest{method: "DELETE", target: "/v1/me/identities/corp", username: "bob", password: "bob-password"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = s.do(testRequest{method: "DELETE", target: "/v1/me/identities/corp", username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("NOT_OK", func(t *testing.T) {
+		w := s.do(testRequest{method: "GET", target: "/v1/auth/connectors/corp/login?redirect=" + url.QueryEscape("//evil.test")})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = s.do(testRequest{method: "GET", target: "/v1/auth/connectors/nope/login"})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = s.do(testRequest{method: "GET", target: "/v1/auth/connectors/corp/callback?code=c&state=forged"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		idp.SignIn(nil)
+		w = s.do(testRequest{method: "GET", target: "/v1/auth/connectors/corp/login"})
+		w = callback(w.Header().Get("Location"))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "the provider refused")
+
+		idp.SignIn(map[string]interface{}{"sub": "u-admin", "preferred_username": "admin"})
+		w = s.do(testRequest{method: "GET", target: "/v1/auth/connectors/corp/login"})
+		w = callback(w.Header().Get("Location"))
+		assert.Equal(t, http.StatusConflict, w.Code, "no provisioning over existing users")
+	})
+}
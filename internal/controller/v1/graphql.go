@@ -0,0 +1,712 @@
+package v1
+
+import (
+	"dev/profileSaver/internal/apikey"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const routeGraphQL = "/graphql"
+
+// graphqlMaxCost caps the complexity computed for a query, far above any
+// sensible limit, so that deep pages can't overflow it.
+const graphqlMaxCost = 1 << 30
+
+// graphqlPaginated are the fields taking a page limit, their selections are
+// paid for once per element of the page.
+var graphqlPaginated = map[string]bool{
+	"users":   true,
+	"members": true,
+}
+
+// graphqlCodes translate the HTTP status of an error, see ErrorStatus, into
+// the code extension of GraphQL errors.
+var graphqlCodes = map[int]string{
+	http.StatusBadRequest:   "BAD_USER_INPUT",
+	http.StatusUnauthorized: "UNAUTHENTICATED",
+	http.StatusForbidden:    "FORBIDDEN",
+	http.StatusNotFound:     "NOT_FOUND",
+	http.StatusConflict:     "CONFLICT",
+}
+
+// errPermissionRequired refuses GraphQL mutations to users without the
+// permission they need.
+var errPermissionRequired = errors.New("permission required")
+
+// graphqlError is an error of a resolver, its code extension tells clients
+// what went wrong like the status of the REST API does.
+type graphqlError struct {
+	err  error
+	code string
+}
+
+func (e graphqlError) Error() string {
+	return e.err.Error()
+}
+
+func (e graphqlError) Unwrap() error {
+	return e.err
+}
+
+func (e graphqlError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+// gqlError returns the error answering err, coded by its HTTP status.
+func gqlError(err error) error {
+	code, ok := graphqlCodes[ErrorStatus(err)]
+	if !ok {
+		code = "INTERNAL"
+	}
+
+	return graphqlError{err: err, code: code}
+}
+
+// gqlInputError returns the error answering invalid arguments.
+func gqlInputError(err error) error {
+	return graphqlError{err: err, code: graphqlCodes[http.StatusBadRequest]}
+}
+
+// userPage is a page of users, as returned by users and members.
+type userPage struct {
+	Nodes  []model.User
+	Total  int
+	Limit  int
+	Offset int
+}
+
+// graphql
+// @Summary GraphQL API
+// @Tags GraphQL
+// @Description Query the users and groups of the tenant and change users. Queries can also be sent with GET and the query, operationName and variables parameters, mutations only with POST. Queries deeper or more complex than graphql.max_depth and graphql.max_complexity are refused with 400.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param input body controller.GraphQLRequest true "operation"
+// @Success 200
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 405
+// @Router /graphql [POST]
+// @Router /graphql [GET]
+func (h *Handler) graphql(w http.ResponseWriter, req bunrouter.Request) error {
+	var request controller.GraphQLRequest
+
+	if req.Method == http.MethodGet {
+		query := req.URL.Query()
+		request.Query = query.Get("query")
+		request.OperationName = query.Get("operationName")
+
+		if v := query.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &request.Variables); err != nil {
+				return h.graphqlFailed(w, req, http.StatusBadRequest, err)
+			}
+		}
+	} else {
+		body := req.Body
+		defer body.Close()
+
+		if err := json.NewDecoder(body).Decode(&request); err != nil {
+			return h.graphqlFailed(w, req, http.StatusBadRequest, err)
+		}
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: request.Query})
+	if err != nil {
+		return h.graphqlFailed(w, req, http.StatusBadRequest, err)
+	}
+
+	validation := graphql.ValidateDocument(&h.schema, doc, nil)
+	if !validation.IsValid {
+		errs := make([]error, 0, len(validation.Errors))
+		for _, e := range validation.Errors {
+			errs = append(errs, e)
+		}
+		return h.graphqlFailed(w, req, http.StatusBadRequest, errs...)
+	}
+
+	op, fragments := graphqlOperation(doc, request.OperationName)
+	if op == nil {
+		return h.graphqlFailed(w, req, http.StatusBadRequest, errors.New("unknown operation, name one of the document"))
+	}
+
+	if op.Operation == ast.OperationTypeMutation {
+		if req.Method == http.MethodGet {
+			w.Header().Set("Allow", http.MethodPost)
+			return h.graphqlFailed(w, req, http.StatusMethodNotAllowed, errors.New("mutations must be sent with POST"))
+		}
+
+		if key, ok := currentAPIKey(req.Context()); ok && !apikey.Allows(key, http.MethodPost) {
+			return h.graphqlFailed(w, req, http.StatusForbidden, gqlError(errAPIKeyScopes))
+		}
+	}
+
+	limits := h.cfg.Get().GraphQL
+	depth, complexity := graphqlCost(op.SelectionSet, fragments, request.Variables)
+
+	if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+		return h.graphqlFailed(w, req, http.StatusBadRequest,
+			fmt.Errorf("query depth %d exceeds the limit of %d", depth, limits.MaxDepth))
+	}
+	if limits.MaxComplexity > 0 && complexity > limits.MaxComplexity {
+		return h.graphqlFailed(w, req, http.StatusBadRequest,
+			fmt.Errorf("query complexity %d exceeds the limit of %d", complexity, limits.MaxComplexity))
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       req.Context(),
+	})
+
+	return bunrouter.JSON(w, result)
+}
+
+// graphqlFailed answers a request that wasn't executed with errs.
+func (h *Handler) graphqlFailed(w http.ResponseWriter, req bunrouter.Request, code int, errs ...error) error {
+	formatted := make([]gqlerrors.FormattedError, 0, len(errs))
+	for _, err := range errs {
+		f := gqlerrors.FormatError(err)
+		if extended, ok := err.(gqlerrors.ExtendedError); ok {
+			f.Extensions = extended.Extensions()
+		}
+		formatted = append(formatted, f)
+	}
+
+	logger.FromContext(req.Context()).Warn().
+		Int("status", code).
+		Interface("error", formatted).
+		Msg("request failed")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	return bunrouter.JSON(w, bunrouter.H{
+		"errors": formatted,
+	})
+}
+
+// graphqlOperation returns the operation of doc named name, or its only one
+// when name is empty, and the fragments of doc by name. The operation is nil
+// when there is no such operation.
+func graphqlOperation(doc *ast.Document, name string) (*ast.OperationDefinition, map[string]*ast.FragmentDefinition) {
+	var (
+		operations []*ast.OperationDefinition
+		op         *ast.OperationDefinition
+	)
+	fragments := make(map[string]*ast.FragmentDefinition)
+
+	for _, def := range doc.Definitions {
+		switch d := def.(type) {
+		case *ast.OperationDefinition:
+			operations = append(operations, d)
+			if d.Name != nil && d.Name.Value == name {
+				op = d
+			}
+		case *ast.FragmentDefinition:
+			fragments[d.Name.Value] = d
+		}
+	}
+
+	if name == "" && len(operations) == 1 {
+		op = operations[0]
+	}
+
+	return op, fragments
+}
+
+// graphqlCost returns the depth and complexity of set. A field costs one
+// plus what its selections cost, which paginated fields pay once per
+// element of their page. Introspection is free.
+func graphqlCost(set *ast.SelectionSet, fragments map[string]*ast.FragmentDefinition, variables map[string]interface{}) (int, int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	var depth, complexity int
+
+	for _, selection := range set.Selections {
+		var d, c int
+
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name.Value, "__") {
+				continue
+			}
+
+			d, c = graphqlCost(s.SelectionSet, fragments, variables)
+			d++
+			c = 1 + graphqlPageLimit(s, variables)*c
+		case *ast.InlineFragment:
+			d, c = graphqlCost(s.SelectionSet, fragments, variables)
+		case *ast.FragmentSpread:
+			// Validation has refused unknown and cyclic fragments.
+			if f, ok := fragments[s.Name.Value]; ok {
+				d, c = graphqlCost(f.SelectionSet, fragments, variables)
+			}
+		}
+
+		if d > depth {
+			depth = d
+		}
+
+		complexity += c
+		if complexity > graphqlMaxCost {
+			complexity = graphqlMaxCost
+		}
+	}
+
+	return depth, complexity
+}
+
+// graphqlPageLimit returns the page limit of a paginated field, 1 for other
+// fields. A limit passed in a variable that wasn't sent counts as the
+// largest page.
+func graphqlPageLimit(field *ast.Field, variables map[string]interface{}) int {
+	if !graphqlPaginated[field.Name.Value] {
+		return 1
+	}
+
+	limit := defaultPageLimit
+
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil {
+				limit = n
+			}
+		case *ast.Variable:
+			limit = maxPageLimit
+			if n, ok := variables[v.Name.Value].(float64); ok {
+				limit = int(n)
+			}
+		}
+	}
+
+	// Limits out of range are refused when the field resolves.
+	switch {
+	case limit < 1:
+		return 1
+	case limit > maxPageLimit:
+		return maxPageLimit
+	}
+
+	return limit
+}
+
+// graphqlSchema returns the schema served at /graphql, resolved by h.
+func (h *Handler) graphqlSchema() (graphql.Schema, error) {
+	pageArgs := graphql.FieldConfigArgument{
+		"limit": &graphql.ArgumentConfig{
+			Type:         graphql.Int,
+			DefaultValue: defaultPageLimit,
+			Description:  fmt.Sprintf("between 1 and %d", maxPageLimit),
+		},
+		"offset": &graphql.ArgumentConfig{
+			Type:         graphql.Int,
+			DefaultValue: 0,
+		},
+	}
+
+	groupType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Group",
+		Fields: graphql.Fields{
+			"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"name":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"description": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"permissions": &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+			"externalId":  &graphql.Field{Type: graphql.String},
+			"createdAt":   &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		},
+	})
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":       &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"username": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"email":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"admin":    &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"emailVerified": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "set once the user confirmed a token sent to email",
+			},
+			"tenant": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "empty for users of the default tenant",
+			},
+			"serviceAccount": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"disabled":       &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"externalId":     &graphql.Field{Type: graphql.String},
+			"groups": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(groupType))),
+				Resolve: h.graphqlUserGroups,
+			},
+		},
+	})
+
+	pageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserPage",
+		Fields: graphql.Fields{
+			"nodes":  &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userType)))},
+			"total":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"limit":  &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"offset": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	groupType.AddFieldConfig("members", &graphql.Field{
+		Type:    graphql.NewNonNull(pageType),
+		Args:    pageArgs,
+		Resolve: h.graphqlGroupMembers,
+	})
+
+	filterType := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UserFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"search": &graphql.InputObjectFieldConfig{
+				Type:        graphql.String,
+				Description: "part of the username or email, in any case",
+			},
+			"admin":         &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+			"disabled":      &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+			"emailVerified": &graphql.InputObjectFieldConfig{Type: graphql.Boolean},
+		},
+	})
+
+	usersArgs := graphql.FieldConfigArgument{
+		"filter": &graphql.ArgumentConfig{Type: filterType},
+	}
+	for name, arg := range pageArgs {
+		usersArgs[name] = arg
+	}
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": &graphql.Field{
+				Type:    graphql.NewNonNull(userType),
+				Resolve: h.graphqlMe,
+			},
+			"user": &graphql.Field{
+				Type: userType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: h.graphqlUser,
+			},
+			"users": &graphql.Field{
+				Type:        graphql.NewNonNull(pageType),
+				Description: "users of the tenant ordered by username",
+				Args:        usersArgs,
+				Resolve:     h.graphqlUsers,
+			},
+			"group": &graphql.Field{
+				Type: groupType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: h.graphqlGroup,
+			},
+		},
+	})
+
+	userInput := func(name string, passwordType graphql.Input) *graphql.InputObject {
+		return graphql.NewInputObject(graphql.InputObjectConfig{
+			Name: name,
+			Fields: graphql.InputObjectConfigFieldMap{
+				"username": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+				"email":    &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+				"password": &graphql.InputObjectFieldConfig{Type: passwordType},
+				"admin":    &graphql.InputObjectFieldConfig{Type: graphql.Boolean, DefaultValue: false},
+			},
+		})
+	}
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createUser": &graphql.Field{
+				Type:        graphql.NewNonNull(userType),
+				Description: "needs the user:write permission",
+				Args: graphql.FieldConfigArgument{
+					"input": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(userInput("CreateUserInput", graphql.NewNonNull(graphql.String))),
+					},
+				},
+				Resolve: h.graphqlCreateUser,
+			},
+			"updateUser": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Description: "needs the user:write permission. Setting a password signs the user out everywhere, " +
+					"a new email has to be verified again",
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(userInput("UpdateUserInput", graphql.String))},
+				},
+				Resolve: h.graphqlUpdateUser,
+			},
+			"deleteUser": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "needs the user:write permission",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: h.graphqlDeleteUser,
+			},
+			"changePassword": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "changes the own password and signs out the other sessions, not available to API keys",
+				Args: graphql.FieldConfigArgument{
+					"currentPassword": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"newPassword":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: h.graphqlChangePassword,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    query,
+		Mutation: mutation,
+	})
+}
+
+func (h *Handler) graphqlMe(p graphql.ResolveParams) (interface{}, error) {
+	return authenticatedUser(p.Context), nil
+}
+
+func (h *Handler) graphqlUser(p graphql.ResolveParams) (interface{}, error) {
+	id, _ := p.Args["id"].(string)
+
+	user, err := h.tenantUser(p.Context, id)
+	if err != nil {
+		return nil, gqlError(err)
+	}
+
+	return user, nil
+}
+
+func (h *Handler) graphqlUsers(p graphql.ResolveParams) (interface{}, error) {
+	page, err := graphqlPage(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, _ := p.Args["filter"].(map[string]interface{})
+
+	users := h.Users(p.Context)
+
+	matching := users[:0]
+	for _, user := range users {
+		if graphqlMatches(user, filter) {
+			matching = append(matching, user)
+		}
+	}
+
+	return newUserPage(matching, page), nil
+}
+
+func (h *Handler) graphqlGroup(p graphql.ResolveParams) (interface{}, error) {
+	id, _ := p.Args["id"].(string)
+
+	g, err := h.tenantGroup(p.Context, id)
+	if err != nil {
+		return nil, gqlError(err)
+	}
+
+	return g, nil
+}
+
+func (h *Handler) graphqlGroupMembers(p graphql.ResolveParams) (interface{}, error) {
+	page, err := graphqlPage(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	g, _ := p.Source.(model.Group)
+
+	members, err := h.groups.GetMembers(p.Context, g.ID)
+	if err != nil {
+		return nil, gqlError(err)
+	}
+
+	return newUserPage(members, page), nil
+}
+
+func (h *Handler) graphqlUserGroups(p graphql.ResolveParams) (interface{}, error) {
+	user, _ := p.Source.(model.User)
+
+	return h.groups.GetGroupsByUser(p.Context, user.ID), nil
+}
+
+func (h *Handler) graphqlCreateUser(p graphql.ResolveParams) (interface{}, error) {
+	if err := h.graphqlPermitted(p, model.PermissionUserWrite); err != nil {
+		return nil, err
+	}
+
+	user, err := h.AddUser(p.Context, graphqlUserInput(p.Args))
+	if err != nil {
+		return nil, gqlError(err)
+	}
+
+	return user, nil
+}
+
+func (h *Handler) graphqlUpdateUser(p graphql.ResolveParams) (interface{}, error) {
+	if err := h.graphqlPermitted(p, model.PermissionUserWrite); err != nil {
+		return nil, err
+	}
+
+	id, _ := p.Args["id"].(string)
+
+	user, err := h.EditUser(p.Context, id, graphqlUserInput(p.Args))
+	if err != nil {
+		return nil, gqlError(err)
+	}
+
+	return user, nil
+}
+
+func (h *Handler) graphqlDeleteUser(p graphql.ResolveParams) (interface{}, error) {
+	if err := h.graphqlPermitted(p, model.PermissionUserWrite); err != nil {
+		return nil, err
+	}
+
+	id, _ := p.Args["id"].(string)
+
+	if err := h.RemoveUser(p.Context, id); err != nil {
+		return nil, gqlError(err)
+	}
+
+	return true, nil
+}
+
+// graphqlChangePassword is changePassword.
+func (h *Handler) graphqlChangePassword(p graphql.ResolveParams) (interface{}, error) {
+	if _, ok := currentAPIKey(p.Context); ok {
+		return nil, gqlError(errDeniedToAPIKeys)
+	}
+
+	current, _ := p.Args["currentPassword"].(string)
+	newPassword, _ := p.Args["newPassword"].(string)
+
+	reason := validatePassword(newPassword, h.cfg.Get().Password)
+	if newPassword == "" {
+		reason = append(reason, "empty password")
+	}
+	if newPassword == current {
+		reason = append(reason, "new password equals current password")
+	}
+	if len(reason) != 0 {
+		return nil, gqlInputError(errors.New(strings.Join(reason, ", ")))
+	}
+
+	user := authenticatedUser(p.Context)
+
+	if !h.repo.IsAuthorized(p.Context, user.Username, current) {
+		return nil, gqlInputError(errors.New("wrong current password"))
+	}
+
+	user.Password = newPassword
+	user.MustChangePassword = false
+
+	if err := h.repo.UpdateUser(p.Context, user); err != nil {
+		return nil, gqlError(err)
+	}
+
+	// Other sessions are signed out, the one making the change is kept.
+	if err := h.sessions.RevokeAll(p.Context, user.ID, currentSession(p.Context).ID); err != nil {
+		return nil, gqlError(err)
+	}
+
+	return true, nil
+}
+
+// graphqlPermitted refuses callers without permission.
+func (h *Handler) graphqlPermitted(p graphql.ResolveParams, permission string) error {
+	if !h.permitted(p.Context, authenticatedUser(p.Context), permission) {
+		return gqlError(fmt.Errorf("%w: %s", errPermissionRequired, permission))
+	}
+
+	return nil
+}
+
+// graphqlPage reads the limit and offset arguments, see PageParams.
+func graphqlPage(args map[string]interface{}) (controller.Page, error) {
+	limit, _ := args["limit"].(int)
+	offset, _ := args["offset"].(int)
+
+	if limit < 1 || limit > maxPageLimit || offset < 0 {
+		return controller.Page{}, gqlError(errInvalidPage)
+	}
+
+	return controller.Page{Limit: limit, Offset: offset}, nil
+}
+
+func newUserPage(users []model.User, page controller.Page) userPage {
+	start, end := PageBounds(&page, len(users))
+
+	return userPage{
+		Nodes:  append(make([]model.User, 0, end-start), users[start:end]...),
+		Total:  page.Total,
+		Limit:  page.Limit,
+		Offset: page.Offset,
+	}
+}
+
+// graphqlMatches reports whether user passes the UserFilter filter, a nil
+// filter passes everyone.
+func graphqlMatches(user model.User, filter map[string]interface{}) bool {
+	if search, ok := filter["search"].(string); ok {
+		search = strings.ToLower(search)
+		if !strings.Contains(strings.ToLower(user.Username), search) &&
+			!strings.Contains(strings.ToLower(user.Email), search) {
+			return false
+		}
+	}
+
+	flags := map[string]bool{
+		"admin":         user.Admin,
+		"disabled":      user.Disabled,
+		"emailVerified": user.EmailVerified,
+	}
+	for name, set := range flags {
+		if want, ok := filter[name].(bool); ok && want != set {
+			return false
+		}
+	}
+
+	return true
+}
+
+func graphqlUserInput(args map[string]interface{}) controller.UserRequest {
+	input, _ := args["input"].(map[string]interface{})
+
+	var user controller.UserRequest
+	user.Username, _ = input["username"].(string)
+	user.Email, _ = input["email"].(string)
+	user.Password, _ = input["password"].(string)
+	user.Admin, _ = input["admin"].(bool)
+
+	return user
+}
@@ -0,0 +1,233 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/apikey"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/model"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_graphql(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	_, err := s.repo.CreateUser(ctx, model.User{Username: "dave", Email: "dave@test.net", Password: "dave-password"})
+	require.NoError(t, err)
+
+	require.NoError(t, s.repo.CreateGroup(ctx, model.Group{ID: "ops", Name: "ops", Permissions: []string{model.PermissionUserWrite}}))
+	require.NoError(t, s.repo.AddMember(ctx, "ops", s.bob.ID))
+
+	keys := apikey.NewManager(s.repo)
+	readKey, _, err := keys.Issue(ctx, s.admin.ID, "reports", []string{apikey.ScopeRead}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	cfg := config.NewLive(config.Config{GraphQL: config.GraphQL{MaxDepth: 4, MaxComplexity: 300}})
+	s.route(WithConfig(cfg), WithGroups(s.repo), WithAPIKeys(keys))
+
+	type response struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []struct {
+			Message    string `json:"message"`
+			Extensions struct {
+				Code string `json:"code"`
+			} `json:"extensions"`
+		} `json:"errors"`
+	}
+
+	do := func(t *testing.T, authorization, query string, variables map[string]interface{}, code int) response {
+		body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+		require.NoError(t, err)
+
+		w := s.do(testRequest{method: "POST", target: "/graphql", body: string(body), authorization: authorization})
+		require.Equal(t, code, w.Code, w.Body.String())
+
+		var resp response
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+		return resp
+	}
+
+	basic := func(username, password string) string {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.SetBasicAuth(username, password)
+		return req.Header.Get("Authorization")
+	}
+	asAdmin := basic("admin", "admin")
+
+	var aliceID string
+
+	t.Run("CREATE_USER", func(t *testing.T) {
+		resp := do(t, asAdmin, `mutation($input: CreateUserInput!) {
+			createUser(input: $input) { id username email emailVerified }
+		}`, map[string]interface{}{
+			"input": map[string]interface{}{"username": "alice", "email": "alice@example.com", "password": "alice-password"},
+		}, http.StatusOK)
+		require.Empty(t, resp.Errors)
+
+		var user struct {
+			ID       string `json:"id"`
+			Username string `json:"username"`
+		}
+		require.NoError(t, json.Unmarshal(resp.Data["createUser"], &user))
+		assert.NotEmpty(t, user.ID)
+		assert.Equal(t, "alice", user.Username)
+		aliceID = user.ID
+
+		resp = do(t, asAdmin, `mutation {
+			createUser(input: {username: "alice", email: "alice@example.com", password: "alice-password"}) { id }
+		}`, nil, http.StatusOK)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "CONFLICT", resp.Errors[0].Extensions.Code)
+
+		resp = do(t, asAdmin, `mutation {
+			createUser(input: {username: "", email: "carol@example.com", password: "carol-password"}) { id }
+		}`, nil, http.StatusOK)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "BAD_USER_INPUT", resp.Errors[0].Extensions.Code)
+		assert.Contains(t, resp.Errors[0].Message, "empty username")
+	})
+
+	t.Run("QUERY_USERS", func(t *testing.T) {
+		resp := do(t, basic("bob", "bob-password"), `query($filter: UserFilter) {
+			me { username groups { name } }
+			users(filter: $filter, limit: 1) { total limit offset nodes { username } }
+		}`, map[string]interface{}{"filter": map[string]interface{}{"search": "EXAMPLE.COM"}}, http.StatusOK)
+		require.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{"username": "bob", "groups": [{"name": "ops"}]}`, string(resp.Data["me"]))
+		assert.JSONEq(t, `{"total": 3, "limit": 1, "offset": 0, "nodes": [{"username": "admin"}]}`, string(resp.Data["users"]))
+
+		resp = do(t, asAdmin, `{
+			users(filter: {admin: false}, offset: 1) { total nodes { username } }
+			user(id: "`+aliceID+`") { email }
+			group(id: "ops") { members { nodes { username } } }
+		}`, nil, http.StatusOK)
+		require.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{"total": 3, "nodes": [{"username": "bob"}, {"username": "dave"}]}`, string(resp.Data["users"]))
+		assert.JSONEq(t, `{"email": "alice@example.com"}`, string(resp.Data["user"]))
+		assert.JSONEq(t, `{"members": {"nodes": [{"username": "bob"}]}}`, string(resp.Data["group"]))
+
+		q := url.Values{"query": {`{ users(limit: 1) { nodes { username } } }`}}
+		w := s.do(testRequest{method: "GET", target: "/graphql?" + q.Encode(), authorization: "Bearer " + readKey})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"data": {"users": {"nodes": [{"username": "admin"}]}}}`, w.Body.String())
+	})
+
+	t.Run("UPDATE_USER", func(t *testing.T) {
+		resp := do(t, basic("bob", "bob-password"), `mutation($id: ID!) {
+			updateUser(id: $id, input: {username: "alice", email: "alice@example.org"}) { email emailVerified }
+		}`, map[string]interface{}{"id": aliceID}, http.StatusOK)
+		require.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{"email": "alice@example.org", "emailVerified": false}`, string(resp.Data["updateUser"]))
+
+		resp = do(t, basic("alice", "alice-password"), `mutation {
+			changePassword(currentPassword: "alice-password", newPassword: "alice-password-2")
+		}`, nil, http.StatusOK)
+		require.Empty(t, resp.Errors)
+		assert.True(t, s.repo.IsAuthorized(ctx, "alice", "alice-password-2"))
+	})
+
+	t.Run("DELETE_USER", func(t *testing.T) {
+		resp := do(t, asAdmin, `mutation { deleteUser(id: "`+aliceID+`") }`, nil, http.StatusOK)
+		require.Empty(t, resp.Errors)
+
+		resp = do(t, asAdmin, `{ user(id: "`+aliceID+`") { id } }`, nil, http.StatusOK)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, "NOT_FOUND", resp.Errors[0].Extensions.Code)
+		assert.Equal(t, "null", string(resp.Data["user"]))
+	})
+
+	t.Run("NOT_OK", func(t *testing.T) {
+		tests := []struct {
+			name          string
+			authorization string
+			query         string
+			code          int
+			errorCode     string
+		}{
+			{
+				name:          "NO_CREDENTIALS",
+				authorization: "",
+				query:         `{ me { id } }`,
+				code:          http.StatusUnauthorized,
+			},
+			{
+				name:          "NO_PERMISSION",
+				authorization: basic("dave", "dave-password"),
+				query:         `mutation { deleteUser(id: "` + s.bob.ID + `") }`,
+				code:          http.StatusOK,
+				errorCode:     "FORBIDDEN",
+			},
+			{
+				name:          "NOT_ADMIN",
+				authorization: basic("bob", "bob-password"),
+				query:         `mutation { deleteUser(id: "` + s.admin.ID + `") }`,
+				code:          http.StatusOK,
+				errorCode:     "FORBIDDEN",
+			},
+			{
+				name:          "READ_KEY_WRITES",
+				authorization: "Bearer " + readKey,
+				query:         `mutation { deleteUser(id: "` + s.bob.ID + `") }`,
+				code:          http.StatusForbidden,
+				errorCode:     "FORBIDDEN",
+			},
+			{
+				name:          "INVALID_PAGE",
+				authorization: asAdmin,
+				query:         `{ users(limit: 0) { total } }`,
+				code:          http.StatusOK,
+				errorCode:     "BAD_USER_INPUT",
+			},
+			{
+				name:          "UNKNOWN_FIELD",
+				authorization: asAdmin,
+				query:         `{ me { password } }`,
+				code:          http.StatusBadRequest,
+			},
+			{
+				name:          "TOO_DEEP",
+				authorization: asAdmin,
+				query:         `{ me { groups { members { nodes { groups { name } } } } } }`,
+				code:          http.StatusBadRequest,
+			},
+			{
+				name:          "TOO_COMPLEX",
+				authorization: asAdmin,
+				query:         `{ users(limit: 150) { nodes { groups { name } } } }`,
+				code:          http.StatusBadRequest,
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				body, err := json.Marshal(map[string]string{"query": test.query})
+				require.NoError(t, err)
+
+				w := s.do(testRequest{method: "POST", target: "/graphql", body: string(body), authorization: test.authorization})
+				require.Equal(t, test.code, w.Code, w.Body.String())
+
+				if test.code == http.StatusUnauthorized {
+					return
+				}
+
+				var resp response
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				require.NotEmpty(t, resp.Errors)
+				if test.errorCode != "" {
+					assert.Equal(t, test.errorCode, resp.Errors[0].Extensions.Code)
+				}
+			})
+		}
+
+		q := url.Values{"query": {`mutation { deleteUser(id: "` + s.bob.ID + `") }`}}
+		w := s.do(testRequest{method: "GET", target: "/graphql?" + q.Encode(), username: "admin", password: "admin"})
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
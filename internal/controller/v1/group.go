@@ -0,0 +1,525 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/apikey"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/tenant"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+	"time"
+)
+
+// permissionMiddleware lets a request pass when the authenticated user is an
+// admin or a member of a group granting permission.
+func (h *Handler) permissionMiddleware(permission string) bunrouter.MiddlewareFunc {
+	return func(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+		return func(w http.ResponseWriter, req bunrouter.Request) error {
+			if !h.permitted(req.Context(), authenticatedUser(req.Context()), permission) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return nil
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			return next(w, req)
+		}
+	}
+}
+
+// permissions returns what user may do: everything for admins, otherwise what
+// their groups grant. The admin permission implies all others. Requests made
+// with an API key keep only what the key's scopes allow.
+func (h *Handler) permissions(ctx context.Context, user model.User) map[string]bool {
+	granted := make(map[string]bool)
+
+	if !user.Admin {
+		for _, g := range h.groups.GetGroupsByUser(ctx, user.ID) {
+			for _, p := range g.Permissions {
+				granted[p] = true
+			}
+		}
+	}
+
+	if user.Admin || granted[model.PermissionAdmin] {
+		for _, p := range model.Permissions {
+			granted[p] = true
+		}
+	}
+
+	if k, ok := currentAPIKey(ctx); ok {
+		for p := range granted {
+			if !apikey.Grants(k, p) {
+				delete(granted, p)
+			}
+		}
+	}
+
+	return granted
+}
+
+func (h *Handler) permitted(ctx context.Context, user model.User, permission string) bool {
+	return h.permissions(ctx, user)[permission]
+}
+
+// holdsAdmin reports whether user is an admin, themselves or through a group.
+// Unlike permitted it ignores the API key of the request, which is the
+// caller's and not user's.
+func (h *Handler) holdsAdmin(ctx context.Context, user model.User) bool {
+	if user.Admin {
+		return true
+	}
+
+	for _, g := range h.groups.GetGroupsByUser(ctx, user.ID) {
+		for _, p := range g.Permissions {
+			if p == model.PermissionAdmin {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// mayManage reports whether user may change target, or make them an admin.
+// Only holders of the admin permission manage admins, including those who
+// are admins through a group.
+func (h *Handler) mayManage(ctx context.Context, user, target model.User, makeAdmin bool) bool {
+	if !makeAdmin && !h.holdsAdmin(ctx, target) {
+		return true
+	}
+
+	return h.permitted(ctx, user, model.PermissionAdmin)
+}
+
+// checkGrant checks that permissions are known and held by the authenticated
+// user, who can't hand out more than they may do themselves. When it returns
+// false the response has been written.
+func (h *Handler) checkGrant(w http.ResponseWriter, req bunrouter.Request, permissions []string) (bool, error) {
+	held := h.permissions(req.Context(), authenticatedUser(req.Context()))
+
+	for _, p := range permissions {
+		if !knownPermission(p) {
+			return false, h.responseJSON(w, req, http.StatusBadRequest, fmt.Sprintf("unknown permission %q", p))
+		}
+
+		if !held[p] {
+			return false, h.responseJSON(w, req, http.StatusForbidden, fmt.Sprintf("permission %q is not yours to grant", p))
+		}
+	}
+
+	return true, nil
+}
+
+func knownPermission(permission string) bool {
+	for _, p := range model.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tenantGroup returns group id of the tenant the request acts on. Groups of
+// other tenants are repository.ErrGroupNotFound.
+func (h *Handler) tenantGroup(ctx context.Context, id string) (model.Group, error) {
+	g, err := h.groups.GetGroup(ctx, id)
+	if err != nil {
+		return model.Group{}, err
+	}
+
+	if g.Tenant != tenant.FromContext(ctx) {
+		return model.Group{}, repository.ErrGroupNotFound
+	}
+
+	return g, nil
+}
+
+// loadGroup returns the group named by the id parameter. When it returns false
+// the response has been written.
+func (h *Handler) loadGroup(w http.ResponseWriter, req bunrouter.Request) (model.Group, bool, error) {
+	g, err := h.tenantGroup(req.Context(), req.Params().ByName("id"))
+	if errors.Is(err, repository.ErrGroupNotFound) {
+		return model.Group{}, false, h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return model.Group{}, false, h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return g, true, nil
+}
+
+// getGroups
+// @Summary Get groups
+// @Tags Group
+// @Description Get the groups of the tenant ordered by name
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param limit query int false "page size, 50 by default, at most 200"
+// @Param offset query int false "number of groups to skip"
+// @Success 200 {object} controller.GroupListResponse
+// @Failure 400
+// @Router /v1/group [GET]
+func (h *Handler) getGroups(w http.ResponseWriter, req bunrouter.Request) error {
+	page, err := PageParams(req)
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, groupList(h.groups.GetGroups(req.Context()), page))
+}
+
+// createGroup
+// @Summary Create group
+// @Tags Group
+// @Description Create a group, needs the group:write permission. Only permissions held by the caller can be granted.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param input body controller.GroupRequest true "group"
+// @Success 200 {object} controller.GroupResponse
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 500
+// @Router /v1/group [POST]
+func (h *Handler) createGroup(w http.ResponseWriter, req bunrouter.Request) error {
+	var input controller.GroupRequest
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	if input.Name == "" {
+		return h.responseJSON(w, req, http.StatusBadRequest, "empty name")
+	}
+
+	if ok, err := h.checkGrant(w, req, input.Permissions); !ok {
+		return err
+	}
+
+	g := model.Group{
+		ID:          uuid.New().String(),
+		Tenant:      tenant.FromContext(req.Context()),
+		Name:        input.Name,
+		Description: input.Description,
+		Permissions: input.Permissions,
+		CreatedAt:   time.Now(),
+	}
+
+	err := h.groups.CreateGroup(req.Context(), g)
+	if errors.Is(err, repository.ErrGroupNameExists) {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, groupResponse(g))
+}
+
+// getGroup
+// @Summary Get group by id
+// @Tags Group
+// @Description Get group by id
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "group id"
+// @Success 200 {object} controller.GroupResponse
+// @Failure 404
+// @Router /v1/group/{id} [GET]
+func (h *Handler) getGroup(w http.ResponseWriter, req bunrouter.Request) error {
+	g, ok, err := h.loadGroup(w, req)
+	if !ok {
+		return err
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, groupResponse(g))
+}
+
+// updateGroup
+// @Summary Update group
+// @Tags Group
+// @Description Replace name, description and permissions of a group, needs the group:write permission and every permission the group grants before and after.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "group id"
+// @Param input body controller.GroupRequest true "group"
+// @Success 200 {object} controller.GroupResponse
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Failure 500
+// @Router /v1/group/{id} [PATCH]
+func (h *Handler) updateGroup(w http.ResponseWriter, req bunrouter.Request) error {
+	var input controller.GroupRequest
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	if input.Name == "" {
+		return h.responseJSON(w, req, http.StatusBadRequest, "empty name")
+	}
+
+	g, ok, err := h.loadGroup(w, req)
+	if !ok {
+		return err
+	}
+
+	granted := append(append([]string{}, g.Permissions...), input.Permissions...)
+	if ok, err = h.checkGrant(w, req, granted); !ok {
+		return err
+	}
+
+	g.Name = input.Name
+	g.Description = input.Description
+	g.Permissions = input.Permissions
+
+	err = h.groups.UpdateGroup(req.Context(), g)
+	if errors.Is(err, repository.ErrGroupNameExists) {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, groupResponse(g))
+}
+
+// deleteGroup
+// @Summary Delete group
+// @Tags Group
+// @Description Delete a group, needs the group:write permission and every permission the group grants
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "group id"
+// @Success 200
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Failure 500
+// @Router /v1/group/{id} [DELETE]
+func (h *Handler) deleteGroup(w http.ResponseWriter, req bunrouter.Request) error {
+	g, ok, err := h.loadGroup(w, req)
+	if !ok {
+		return err
+	}
+
+	if ok, err = h.checkGrant(w, req, g.Permissions); !ok {
+		return err
+	}
+
+	if err = h.groups.DeleteGroup(req.Context(), g.ID); err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, "group was deleted")
+}
+
+// getGroupMembers
+// @Summary Get group members
+// @Tags Group
+// @Description Get the members of a group ordered by username
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "group id"
+// @Param limit query int false "page size, 50 by default, at most 200"
+// @Param offset query int false "number of members to skip"
+// @Success 200 {object} controller.MemberListResponse
+// @Failure 400
+// @Failure 404
+// @Router /v1/group/{id}/members [GET]
+func (h *Handler) getGroupMembers(w http.ResponseWriter, req bunrouter.Request) error {
+	page, err := PageParams(req)
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	g, ok, err := h.loadGroup(w, req)
+	if !ok {
+		return err
+	}
+
+	members, err := h.groups.GetMembers(req.Context(), g.ID)
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	start, end := PageBounds(&page, len(members))
+
+	response := controller.MemberListResponse{
+		Members: make([]controller.UserResponse, 0, end-start),
+		Page:    page,
+	}
+	for _, u := range members[start:end] {
+		response.Members = append(response.Members, userResponse(u))
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, response)
+}
+
+// addGroupMember
+// @Summary Add group member
+// @Tags Group
+// @Description Add a user of the tenant to a group, needs the group:write permission and every permission the group grants
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "group id"
+// @Param user_id path string true "user id"
+// @Success 200
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Failure 500
+// @Router /v1/group/{id}/members/{user_id} [PUT]
+func (h *Handler) addGroupMember(w http.ResponseWriter, req bunrouter.Request) error {
+	g, ok, err := h.loadGroup(w, req)
+	if !ok {
+		return err
+	}
+
+	if ok, err = h.checkGrant(w, req, g.Permissions); !ok {
+		return err
+	}
+
+	err = h.groups.AddMember(req.Context(), g.ID, req.Params().ByName("user_id"))
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, "member was added")
+}
+
+// removeGroupMember
+// @Summary Remove group member
+// @Tags Group
+// @Description Remove a user from a group, needs the group:write permission and every permission the group grants
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "group id"
+// @Param user_id path string true "user id"
+// @Success 200
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Failure 500
+// @Router /v1/group/{id}/members/{user_id} [DELETE]
+func (h *Handler) removeGroupMember(w http.ResponseWriter, req bunrouter.Request) error {
+	g, ok, err := h.loadGroup(w, req)
+	if !ok {
+		return err
+	}
+
+	if ok, err = h.checkGrant(w, req, g.Permissions); !ok {
+		return err
+	}
+
+	err = h.groups.RemoveMember(req.Context(), g.ID, req.Params().ByName("user_id"))
+	if errors.Is(err, repository.ErrMemberNotFound) {
+		return h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, "member was removed")
+}
+
+// getUserGroups
+// @Summary Get groups of a user
+// @Tags User
+// @Description Get the groups of a user ordered by name
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "user id"
+// @Param limit query int false "page size, 50 by default, at most 200"
+// @Param offset query int false "number of groups to skip"
+// @Success 200 {object} controller.GroupListResponse
+// @Failure 400
+// @Failure 404
+// @Router /v1/user/{id}/groups [GET]
+func (h *Handler) getUserGroups(w http.ResponseWriter, req bunrouter.Request) error {
+	page, err := PageParams(req)
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	user, err := h.tenantUser(req.Context(), req.Params().ByName("id"))
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, groupList(h.groups.GetGroupsByUser(req.Context(), user.ID), page))
+}
+
+// getOwnGroups
+// @Summary Get own groups
+// @Tags Me
+// @Description Get the groups of the authenticated user ordered by name
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param limit query int false "page size, 50 by default, at most 200"
+// @Param offset query int false "number of groups to skip"
+// @Success 200 {object} controller.GroupListResponse
+// @Failure 400
+// @Router /v1/me/groups [GET]
+func (h *Handler) getOwnGroups(w http.ResponseWriter, req bunrouter.Request) error {
+	page, err := PageParams(req)
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	groups := h.groups.GetGroupsByUser(req.Context(), authenticatedUser(req.Context()).ID)
+
+	return h.responseJSON(w, req, http.StatusOK, groupList(groups, page))
+}
+
+func groupList(groups []model.Group, page controller.Page) controller.GroupListResponse {
+	start, end := PageBounds(&page, len(groups))
+
+	response := controller.GroupListResponse{
+		Groups: make([]controller.GroupResponse, 0, end-start),
+		Page:   page,
+	}
+	for _, g := range groups[start:end] {
+		response.Groups = append(response.Groups, groupResponse(g))
+	}
+
+	return response
+}
+
+func groupResponse(g model.Group) controller.GroupResponse {
+	permissions := g.Permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	return controller.GroupResponse{
+		ID:          g.ID,
+		Name:        g.Name,
+		Description: g.Description,
+		Permissions: permissions,
+		CreatedAt:   g.CreatedAt,
+	}
+}
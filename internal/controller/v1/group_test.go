@@ -0,0 +1,158 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/model"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func Test_groups(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	s.route(WithGroups(s.repo))
+	carol, err := s.repo.CreateUser(ctx, model.User{Username: "carol", Password: "carol-password"})
+	require.NoError(t, err)
+
+	create := func(body string) string {
+		w := s.do(testRequest{method: "POST", target: "/v1/group", body: body, username: "admin", password: "admin"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp struct {
+			Data controller.GroupResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+		return resp.Data.ID
+	}
+
+	ops := create(`{"name":"ops","permissions":["user:write"]}`)
+	leads := create(`{"name":"leads","permissions":["group:write"]}`)
+
+	newUser := `{"username":"dave","email":"dave@example.com","password":"dave-password"}`
+
+	t.Run("PERMISSION_FROM_GROUP", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, s.do(testRequest{method: "POST", target: "/v1/user", body: newUser, username: "bob", password: "bob-password"}).Code)
+
+		require.Equal(t, http.StatusOK, s.do(testRequest{method: "PUT", target: "/v1/group/" + ops + "/members/" + s.bob.ID, username: "admin", password: "admin"}).Code)
+
+		w := s.do(testRequest{method: "POST", target: "/v1/user", body: newUser, username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	})
+
+	t.Run("ADMINS_STAY_WITH_ADMINS", func(t *testing.T) {
+		w := s.do(testRequest{method: "POST", target: "/v1/user", body: `{"username":"eve","email":"eve@example.com","password":"eve-password","admin":true}`, username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, `{"error":"only admins manage admins"}
+`, w.Body.String())
+
+		w = s.do(testRequest{method: "DELETE", target: "/v1/user/" + s.admin.ID, username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = s.do(testRequest{method: "DELETE", target: "/v1/user/" + s.admin.ID + "/sessions", username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		grace, err := s.repo.CreateUser(ctx, model.User{Username: "grace", Email: "grace@example.com", Password: "grace-password"})
+		require.NoError(t, err)
+		root := create(`{"name":"root","permissions":["admin"]}`)
+		require.Equal(t, http.StatusOK, s.do(testRequest{method: "PUT", target: "/v1/group/" + root + "/members/" + grace.ID, username: "admin", password: "admin"}).Code)
+
+		w = s.do(testRequest{method: "PATCH", target: "/v1/user/" + grace.ID, body: `{"username":"grace","email":"grace@example.com","password":"taken-over"}`, username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusForbidden, w.Code, "grace is an admin through root")
+
+		w = s.do(testRequest{method: "DELETE", target: "/v1/user/" + grace.ID, username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = s.do(testRequest{method: "DELETE", target: "/v1/user/" + grace.ID + "/sessions", username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		assert.True(t, s.repo.IsAuthorized(ctx, "grace", "grace-password"))
+
+		require.Equal(t, http.StatusOK, s.do(testRequest{method: "DELETE", target: "/v1/group/" + root, username: "admin", password: "admin"}).Code)
+		w = s.do(testRequest{method: "DELETE", target: "/v1/user/" + grace.ID, username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusOK, w.Code, "without root grace is managed like anyone")
+	})
+
+	t.Run("GRANT_ONLY_HELD_PERMISSIONS", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, s.do(testRequest{method: "PUT", target: "/v1/group/" + leads + "/members/" + carol.ID, username: "admin", password: "admin"}).Code)
+
+		w := s.do(testRequest{method: "POST", target: "/v1/group", body: `{"name":"root","permissions":["admin"]}`, username: "carol", password: "carol-password"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, `{"error":"permission \"admin\" is not yours to grant"}
+`, w.Body.String())
+
+		w = s.do(testRequest{method: "POST", target: "/v1/group", body: `{"name":"x","permissions":["everything"]}`, username: "admin", password: "admin"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = s.do(testRequest{method: "POST", target: "/v1/group", body: `{"name":"readers"}`, username: "carol", password: "carol-password"})
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = s.do(testRequest{method: "POST", target: "/v1/group", body: `{"name":"readers"}`, username: "carol", password: "carol-password"})
+		assert.Equal(t, http.StatusBadRequest, w.Code, "names are unique")
+
+		w = s.do(testRequest{method: "PUT", target: "/v1/group/" + ops + "/members/" + carol.ID, username: "carol", password: "carol-password"})
+		assert.Equal(t, http.StatusForbidden, w.Code, "joining ops would grant user:write")
+
+		w = s.do(testRequest{method: "PATCH", target: "/v1/group/" + leads, body: `{"name":"leads","permissions":["group:write","user:write"]}`, username: "carol", password: "carol-password"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("PAGINATION", func(t *testing.T) {
+		w := s.do(testRequest{method: "GET", target: "/v1/group?limit=2&offset=1", username: "bob", password: "bob-password"})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp struct {
+			Data controller.GroupListResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, 3, resp.Data.Total)
+		assert.Equal(t, 2, resp.Data.Limit)
+		assert.Equal(t, 1, resp.Data.Offset)
+		require.Len(t, resp.Data.Groups, 2)
+		assert.Equal(t, "ops", resp.Data.Groups[0].Name)
+		assert.Equal(t, "readers", resp.Data.Groups[1].Name)
+
+		w = s.do(testRequest{method: "GET", target: "/v1/group?offset=10", username: "bob", password: "bob-password"})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"groups":[]`)
+
+		assert.Equal(t, http.StatusBadRequest, s.do(testRequest{method: "GET", target: "/v1/group?limit=0", username: "bob", password: "bob-password"}).Code)
+		assert.Equal(t, http.StatusBadRequest, s.do(testRequest{method: "GET", target: "/v1/group?offset=-1", username: "bob", password: "bob-password"}).Code)
+	})
+
+	t.Run("LISTS", func(t *testing.T) {
+		w := s.do(testRequest{method: "GET", target: "/v1/group/" + ops + "/members", username: "carol", password: "carol-password"})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"username":"bob"`)
+		assert.Contains(t, w.Body.String(), `"total":1`)
+
+		w = s.do(testRequest{method: "GET", target: "/v1/user/" + s.bob.ID + "/groups", username: "carol", password: "carol-password"})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"name":"ops"`)
+
+		w = s.do(testRequest{method: "GET", target: "/v1/me/groups", username: "carol", password: "carol-password"})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"name":"leads"`)
+		assert.NotContains(t, w.Body.String(), `"name":"ops"`)
+
+		assert.Equal(t, http.StatusNotFound, s.do(testRequest{method: "GET", target: "/v1/group/nope", username: "carol", password: "carol-password"}).Code)
+	})
+
+	t.Run("REMOVE_MEMBER", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, s.do(testRequest{method: "DELETE", target: "/v1/group/" + ops + "/members/" + s.bob.ID, username: "admin", password: "admin"}).Code)
+		assert.Equal(t, http.StatusNotFound, s.do(testRequest{method: "DELETE", target: "/v1/group/" + ops + "/members/" + s.bob.ID, username: "admin", password: "admin"}).Code)
+
+		w := s.do(testRequest{method: "POST", target: "/v1/user", body: `{"username":"frank","email":"frank@example.com","password":"frank-password"}`, username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "the permission went with the membership")
+	})
+
+	t.Run("DELETE", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, s.do(testRequest{method: "DELETE", target: "/v1/group/" + ops, username: "carol", password: "carol-password"}).Code)
+		assert.Equal(t, http.StatusOK, s.do(testRequest{method: "DELETE", target: "/v1/group/" + ops, username: "admin", password: "admin"}).Code)
+		assert.Equal(t, http.StatusNotFound, s.do(testRequest{method: "GET", target: "/v1/group/" + ops, username: "admin", password: "admin"}).Code)
+	})
+}
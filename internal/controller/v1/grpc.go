@@ -0,0 +1,343 @@
+package v1
+
+import (
+	"context"
+	"crypto/tls"
+	userv1 "dev/profileSaver/api/user/v1"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/tenant"
+	"encoding/base64"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// grpcUserService prefixes the full method names of the UserService.
+const grpcUserService = "/profilesaver.user.v1.UserService/"
+
+// grpcAuthenticate is the only call made without credentials.
+const grpcAuthenticate = grpcUserService + "Authenticate"
+
+// grpcReadCalls only read, API keys with the read scope may make them.
+var grpcReadCalls = map[string]bool{
+	grpcUserService + "GetUser":   true,
+	grpcUserService + "ListUsers": true,
+}
+
+// grpcPermissions are the permissions calls need on top of authentication.
+var grpcPermissions = map[string]string{
+	grpcUserService + "CreateUser": model.PermissionUserWrite,
+	grpcUserService + "UpdateUser": model.PermissionUserWrite,
+	grpcUserService + "DeleteUser": model.PermissionUserWrite,
+}
+
+// grpcCodes translate the HTTP status of an error, see ErrorStatus.
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:         codes.InvalidArgument,
+	http.StatusUnauthorized:       codes.Unauthenticated,
+	http.StatusForbidden:          codes.PermissionDenied,
+	http.StatusNotFound:           codes.NotFound,
+	http.StatusConflict:           codes.AlreadyExists,
+	http.StatusTooManyRequests:    codes.ResourceExhausted,
+	http.StatusServiceUnavailable: codes.Unavailable,
+}
+
+// InitGRPC returns the gRPC API: the UserService, authenticated like the
+// REST API. opts, such as transport credentials, are passed to
+// grpc.NewServer.
+func (h *Handler) InitGRPC(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(logger.UnaryServerInterceptor(log.Logger), h.grpcUnaryInterceptor),
+		grpc.ChainStreamInterceptor(logger.StreamServerInterceptor(log.Logger), h.grpcStreamInterceptor),
+	)
+
+	s := grpc.NewServer(opts...)
+	userv1.RegisterUserServiceServer(s, &userService{h: h})
+
+	return s
+}
+
+func (h *Handler) grpcUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := h.grpcAuth(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (h *Handler) grpcStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := h.grpcAuth(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &grpcStream{ServerStream: ss, ctx: ctx})
+}
+
+// grpcAuth is the middleware of the REST API for a call to method: it limits
+// the rate of calls, resolves the tenant of the x-tenant metadata and admits
+// the caller, returning the context of the call.
+func (h *Handler) grpcAuth(ctx context.Context, method string) (context.Context, error) {
+	if !h.limiter.allow(peerIP(ctx), h.cfg.Get().RateLimit) {
+		return nil, status.Error(codes.ResourceExhausted, "too many requests")
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if ids := md.Get(tenant.Header); len(ids) != 0 {
+		ctx = tenant.WithID(ctx, ids[0])
+		if err := h.activeTenant(ctx, ids[0]); err != nil {
+			return nil, grpcError(err)
+		}
+	}
+
+	if method == grpcAuthenticate {
+		return ctx, nil
+	}
+
+	var authorization string
+	if values := md.Get("authorization"); len(values) != 0 {
+		authorization = values[0]
+	}
+
+	user, s, key, ok := h.grpcCredentials(ctx, authorization)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+	}
+
+	logger.WithUser(ctx, user.Username)
+
+	httpMethod := http.MethodPost
+	if grpcReadCalls[method] {
+		httpMethod = http.MethodGet
+	}
+
+	scope, err := h.admit(ctx, user, key, method, httpMethod)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	ctx = context.WithValue(ctx, userKey, user)
+	ctx = context.WithValue(ctx, sessionKey, s)
+	if key.ID != "" {
+		ctx = context.WithValue(ctx, apiKeyKey, key)
+	}
+	ctx = tenant.WithID(ctx, scope)
+
+	if permission, ok := grpcPermissions[method]; ok && !h.permitted(ctx, user, permission) {
+		return nil, status.Errorf(codes.PermissionDenied, "permission %s required", permission)
+	}
+
+	return ctx, nil
+}
+
+// grpcCredentials identifies the caller by the authorization metadata: an API
+// key, a session token sent as "Bearer <token>" or Basic auth credentials,
+// else by a verified TLS client certificate.
+func (h *Handler) grpcCredentials(ctx context.Context, authorization string) (model.User, model.Session, model.APIKey, bool) {
+	if key, ok := apiKeyHeader(authorization); ok {
+		user, k, ok := h.authenticateAPIKey(ctx, key)
+		return user, model.Session{}, k, ok
+	}
+
+	scheme, value, _ := strings.Cut(authorization, " ")
+	value = strings.TrimSpace(value)
+
+	switch {
+	case strings.EqualFold(scheme, "Bearer"):
+		user, s, ok := h.authenticateSession(ctx, value)
+		return user, s, model.APIKey{}, ok
+	case strings.EqualFold(scheme, "Basic"):
+		username, password, ok := parseBasicAuth(value)
+		if !ok {
+			return model.User{}, model.Session{}, model.APIKey{}, false
+		}
+
+		user, ok := h.basicAuthUser(ctx, username, password)
+		return user, model.Session{}, model.APIKey{}, ok
+	}
+
+	if username, ok := clientCertUsername(peerTLS(ctx)); ok {
+		user, ok := h.credentialUser(ctx, username, "", true)
+		return user, model.Session{}, model.APIKey{}, ok
+	}
+
+	return model.User{}, model.Session{}, model.APIKey{}, false
+}
+
+// grpcError returns the status answering err, with the code matching the
+// HTTP status the REST API would answer with.
+func grpcError(err error) error {
+	code, ok := grpcCodes[ErrorStatus(err)]
+	if !ok {
+		code = codes.Internal
+	}
+
+	return status.Error(code, err.Error())
+}
+
+// userService implements userv1.UserServiceServer. Calls reach it through
+// grpcAuth.
+type userService struct {
+	userv1.UnimplementedUserServiceServer
+
+	h *Handler
+}
+
+func (s *userService) CreateUser(ctx context.Context, req *userv1.CreateUserRequest) (*userv1.User, error) {
+	user, err := s.h.AddUser(ctx, controller.UserRequest{
+		Email:    req.Email,
+		Username: req.Username,
+		Password: req.Password,
+		Admin:    req.Admin,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return userMessage(user), nil
+}
+
+func (s *userService) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.User, error) {
+	user, err := s.h.tenantUser(ctx, req.Id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return userMessage(user), nil
+}
+
+func (s *userService) ListUsers(req *userv1.ListUsersRequest, stream userv1.UserService_ListUsersServer) error {
+	for _, user := range s.h.Users(stream.Context()) {
+		if !strings.HasPrefix(user.Username, req.UsernamePrefix) {
+			continue
+		}
+
+		if err := stream.Send(userMessage(user)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *userService) UpdateUser(ctx context.Context, req *userv1.UpdateUserRequest) (*userv1.User, error) {
+	user, err := s.h.EditUser(ctx, req.Id, controller.UserRequest{
+		Email:    req.Email,
+		Username: req.Username,
+		Password: req.Password,
+		Admin:    req.Admin,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return userMessage(user), nil
+}
+
+func (s *userService) DeleteUser(ctx context.Context, req *userv1.DeleteUserRequest) (*userv1.DeleteUserResponse, error) {
+	if err := s.h.RemoveUser(ctx, req.Id); err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &userv1.DeleteUserResponse{}, nil
+}
+
+// Authenticate is the login of the REST API, the session token is returned
+// instead of set as a cookie.
+func (s *userService) Authenticate(ctx context.Context, req *userv1.AuthenticateRequest) (*userv1.AuthenticateResponse, error) {
+	var userAgent string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("user-agent"); len(values) != 0 {
+			userAgent = values[0]
+		}
+	}
+
+	user, token, session, err := s.h.signIn(ctx, grpcAuthenticate, controller.LoginRequest{
+		Username: req.Username,
+		Password: req.Password,
+		Code:     req.Code,
+	}, peerIP(ctx), userAgent)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &userv1.AuthenticateResponse{
+		Token:     token,
+		ExpiresAt: timestamppb.New(session.ExpiresAt),
+		User:      userMessage(user),
+	}, nil
+}
+
+func userMessage(user model.User) *userv1.User {
+	return &userv1.User{
+		Id:             user.ID,
+		Username:       user.Username,
+		Email:          user.Email,
+		Admin:          user.Admin,
+		EmailVerified:  user.EmailVerified,
+		Tenant:         user.Tenant,
+		ServiceAccount: user.ServiceAccount,
+		Disabled:       user.Disabled,
+	}
+}
+
+// grpcStream is a grpc.ServerStream with the context set by grpcAuth.
+type grpcStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcStream) Context() context.Context {
+	return s.ctx
+}
+
+func parseBasicAuth(value string) (string, string, bool) {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", "", false
+	}
+
+	return strings.Cut(string(decoded), ":")
+}
+
+// peerIP is clientIP for gRPC calls.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
+
+// peerTLS returns the TLS connection of a call, nil without TLS.
+func peerTLS(ctx context.Context) *tls.ConnectionState {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+
+	return &info.State
+}
@@ -0,0 +1,206 @@
+package v1
+
+import (
+	"context"
+	userv1 "dev/profileSaver/api/user/v1"
+	"dev/profileSaver/internal/apikey"
+	"dev/profileSaver/internal/tenant"
+	"encoding/base64"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_grpc(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+
+	keys := apikey.NewManager(s.repo)
+	readKey, _, err := keys.Issue(ctx, s.admin.ID, "reports", []string{apikey.ScopeRead}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	listener := bufconn.Listen(1 << 20)
+	srv := New(s.repo, WithGroups(s.repo), WithAPIKeys(keys)).InitGRPC()
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	defer srv.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := userv1.NewUserServiceClient(conn)
+
+	as := func(authorization string) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, "authorization", authorization)
+	}
+	basic := func(username, password string) context.Context {
+		return as("Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+	}
+	code := func(err error) codes.Code {
+		return status.Code(err)
+	}
+
+	var alice *userv1.User
+
+	t.Run("CREATE_USER", func(t *testing.T) {
+		alice, err = client.CreateUser(basic("admin", "admin"), &userv1.CreateUserRequest{
+			Username: "alice", Email: "alice@example.com", Password: "alice-password",
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, alice.Id)
+		assert.Equal(t, "alice", alice.Username)
+
+		_, err = client.CreateUser(basic("admin", "admin"), &userv1.CreateUserRequest{
+			Username: "alice", Email: "alice@example.com", Password: "alice-password",
+		})
+		assert.Equal(t, codes.AlreadyExists, code(err))
+
+		_, err = client.CreateUser(basic("admin", "admin"), &userv1.CreateUserRequest{Username: "carol"})
+		assert.Equal(t, codes.InvalidArgument, code(err))
+		assert.Contains(t, status.Convert(err).Message(), "empty password")
+	})
+
+	t.Run("AUTHENTICATE", func(t *testing.T) {
+		resp, err := client.Authenticate(ctx, &userv1.AuthenticateRequest{Username: "alice", Password: "alice-password"})
+		require.NoError(t, err)
+		assert.Equal(t, alice.Id, resp.User.Id)
+		assert.True(t, resp.ExpiresAt.AsTime().After(time.Now()))
+
+		user, err := client.GetUser(as("Bearer "+resp.Token), &userv1.GetUserRequest{Id: alice.Id})
+		require.NoError(t, err)
+		assert.Equal(t, "alice@example.com", user.Email)
+
+		_, err = client.Authenticate(ctx, &userv1.AuthenticateRequest{Username: "alice", Password: "wrong"})
+		assert.Equal(t, codes.Unauthenticated, code(err))
+	})
+
+	t.Run("LIST_USERS", func(t *testing.T) {
+		stream, err := client.ListUsers(basic("bob", "bob-password"), &userv1.ListUsersRequest{})
+		require.NoError(t, err)
+
+		var usernames []string
+		for {
+			user, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(t, err)
+			usernames = append(usernames, user.Username)
+		}
+		assert.Equal(t, []string{"admin", "alice", "bob"}, usernames)
+
+		stream, err = client.ListUsers(as("Bearer "+readKey), &userv1.ListUsersRequest{UsernamePrefix: "al"})
+		require.NoError(t, err)
+		user, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, "alice", user.Username)
+		_, err = stream.Recv()
+		assert.True(t, errors.Is(err, io.EOF), "%v", err)
+	})
+
+	t.Run("UPDATE_USER", func(t *testing.T) {
+		user, err := client.UpdateUser(basic("admin", "admin"), &userv1.UpdateUserRequest{
+			Id: alice.Id, Username: "alice", Email: "alice@example.org",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "alice@example.org", user.Email)
+		assert.False(t, user.EmailVerified)
+
+		_, err = client.GetUser(basic("alice", "alice-password"), &userv1.GetUserRequest{Id: alice.Id})
+		assert.NoError(t, err, "an empty password is kept")
+
+		_, err = client.UpdateUser(basic("admin", "admin"), &userv1.UpdateUserRequest{
+			Id: "unknown", Username: "x", Email: "x@example.com",
+		})
+		assert.Equal(t, codes.NotFound, code(err))
+	})
+
+	t.Run("DELETE_USER", func(t *testing.T) {
+		_, err := client.DeleteUser(basic("admin", "admin"), &userv1.DeleteUserRequest{Id: alice.Id})
+		require.NoError(t, err)
+
+		_, err = client.GetUser(basic("admin", "admin"), &userv1.GetUserRequest{Id: alice.Id})
+		assert.Equal(t, codes.NotFound, code(err))
+	})
+
+	t.Run("NOT_OK", func(t *testing.T) {
+		tests := []struct {
+			name string
+			ctx  context.Context
+			call func(ctx context.Context) error
+			code codes.Code
+		}{
+			{
+				name: "NO_CREDENTIALS",
+				ctx:  ctx,
+				call: func(ctx context.Context) error {
+					_, err := client.GetUser(ctx, &userv1.GetUserRequest{Id: s.admin.ID})
+					return err
+				},
+				code: codes.Unauthenticated,
+			},
+			{
+				name: "WRONG_PASSWORD",
+				ctx:  basic("admin", "wrong"),
+				call: func(ctx context.Context) error {
+					_, err := client.GetUser(ctx, &userv1.GetUserRequest{Id: s.admin.ID})
+					return err
+				},
+				code: codes.Unauthenticated,
+			},
+			{
+				name: "NO_PERMISSION",
+				ctx:  basic("bob", "bob-password"),
+				call: func(ctx context.Context) error {
+					_, err := client.DeleteUser(ctx, &userv1.DeleteUserRequest{Id: s.admin.ID})
+					return err
+				},
+				code: codes.PermissionDenied,
+			},
+			{
+				name: "READ_KEY_WRITES",
+				ctx:  as("ApiKey " + readKey),
+				call: func(ctx context.Context) error {
+					_, err := client.CreateUser(ctx, &userv1.CreateUserRequest{
+						Username: "dave", Email: "dave@example.com", Password: "dave-password",
+					})
+					return err
+				},
+				code: codes.PermissionDenied,
+			},
+			{
+				name: "UNKNOWN_TENANT",
+				ctx:  metadata.AppendToOutgoingContext(basic("admin", "admin"), strings.ToLower(tenant.Header), "acme"),
+				call: func(ctx context.Context) error {
+					_, err := client.GetUser(ctx, &userv1.GetUserRequest{Id: s.admin.ID})
+					return err
+				},
+				code: codes.NotFound,
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				assert.Equal(t, test.code, code(test.call(test.ctx)))
+			})
+		}
+	})
+}
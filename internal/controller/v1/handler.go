@@ -1,26 +1,40 @@
 package v1
 
 import (
+	"context"
+	"dev/profileSaver/internal/config"
 	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/logger"
 	"dev/profileSaver/internal/model"
 	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/tenant"
 	"encoding/json"
 	"errors"
-	"github.com/rs/zerolog/log"
+	"fmt"
 	"github.com/uptrace/bunrouter"
 	"net/http"
+	"sort"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // createUser
 // @Summary Create new user
 // @Tags User
-// @Description Create new user
+// @Description Create new user, answering the user as stored with its Location. A retry with the same Idempotency-Key is answered like the first request, reusing the key for another body (422) or while the first request is handled (409) is refused.
 // @Accept  json
 // @Produce  json
 // @Security BasicAuth
+// @Param Idempotency-Key header string false "key making retries safe"
 // @Param input body controller.UserRequest true "user"
-// @Success 200
+// @Success 201 {object} controller.UserResponse
+// @Header 201 {string} Location "/v1/user/{id}"
+// @Failure 400
+// @Failure 403
+// @Failure 409
+// @Failure 413
+// @Failure 422
 // @Failure 500
 // @Router /v1/user [POST]
 func (h *Handler) createUser(w http.ResponseWriter, req bunrouter.Request) error {
@@ -29,34 +43,19 @@ func (h *Handler) createUser(w http.ResponseWriter, req bunrouter.Request) error
 
 	var newUser controller.UserRequest
 	if err := json.NewDecoder(body).Decode(&newUser); err != nil {
-		log.Error().Err(err)
-		return h.responseJSON(w, req, http.StatusBadRequest, err)
+		logger.FromContext(req.Context()).Error().Err(err).Msg("unable to decode request body")
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
 	}
 
-	err := validate(newUser)
+	user, err := h.AddUser(req.Context(), newUser)
 	if err != nil {
-		return h.responseJSON(w, req, http.StatusBadRequest, err)
+		logger.FromContext(req.Context()).Error().Err(err).Str("username", newUser.Username).Msg("unable to create user")
+		return h.responseJSON(w, req, ErrorStatus(err), err.Error())
 	}
 
-	user := model.User{
-		ID:       "",
-		Email:    newUser.Email,
-		Username: newUser.Username,
-		Password: newUser.Password,
-		Salt:     nil,
-		Admin:    newUser.Admin,
-	}
-
-	err = h.repo.CreateUser(user)
-	if err != nil {
-		log.Error().Err(err)
-		if errors.Is(err, repository.ErrUserNameExists) {
-			return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
-		}
-		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
-	}
+	w.Header().Set("Location", Location(req, "/v1/user/"+user.ID))
 
-	return h.responseJSON(w, req, http.StatusOK, "user was created")
+	return h.responseJSON(w, req, http.StatusCreated, userResponse(user))
 }
 
 // getAllUsers
@@ -69,17 +68,12 @@ func (h *Handler) createUser(w http.ResponseWriter, req bunrouter.Request) error
 // @Failure 500
 // @Router /v1/user [GET]
 func (h *Handler) getAllUsers(w http.ResponseWriter, req bunrouter.Request) error {
-	users := h.repo.GetAllUsers()
+	users := h.repo.GetAllUsers(req.Context())
 
 	var response []controller.UserResponse
 
 	for _, user := range users {
-		response = append(response, controller.UserResponse{
-			ID:       user.ID,
-			Email:    user.Email,
-			Username: user.Username,
-			Admin:    user.Admin,
-		})
+		response = append(response, userResponse(user))
 	}
 
 	return h.responseJSON(w, req, http.StatusOK, response)
@@ -93,30 +87,27 @@ func (h *Handler) getAllUsers(w http.ResponseWriter, req bunrouter.Request) erro
 // @Produce  json
 // @Param id path string true "user id"
 // @Success 200 {array} controller.UserResponse
+// @Failure 404
 // @Failure 500
 // @Router /v1/user/{id} [GET]
 func (h *Handler) getUser(w http.ResponseWriter, req bunrouter.Request) error {
 	id := req.Params().ByName("id")
 
-	user, err := h.repo.GetUserByID(id)
+	user, err := h.tenantUser(req.Context(), id)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
 	if err != nil {
 		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
 	}
 
-	response := controller.UserResponse{
-		ID:       user.ID,
-		Email:    user.Email,
-		Username: user.Username,
-		Admin:    user.Admin,
-	}
-
-	return h.responseJSON(w, req, http.StatusOK, response)
+	return h.responseJSON(w, req, http.StatusOK, userResponse(user))
 }
 
 // updateUser
 // @Summary Update user
 // @Tags User
-// @Description Update user
+// @Description Update user, an empty password keeps the current one. Setting a password revokes all sessions of the user, a new email has to be verified again.
 // @Accept  json
 // @Produce  json
 // @Param id path string true "user id"
@@ -130,29 +121,12 @@ func (h *Handler) updateUser(w http.ResponseWriter, req bunrouter.Request) error
 
 	var newUser controller.UserRequest
 	if err := json.NewDecoder(body).Decode(&newUser); err != nil {
-		log.Error().Err(err)
-		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
-	}
-
-	err := validate(newUser)
-	if err != nil {
+		logger.FromContext(req.Context()).Error().Err(err).Msg("unable to decode request body")
 		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
 	}
 
-	id := req.Params().ByName("id")
-
-	user := model.User{
-		ID:       id,
-		Email:    newUser.Email,
-		Username: newUser.Username,
-		Password: newUser.Password,
-		Salt:     nil,
-		Admin:    newUser.Admin,
-	}
-
-	err = h.repo.UpdateUser(user)
-	if err != nil {
-		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	if _, err := h.EditUser(req.Context(), req.Params().ByName("id"), newUser); err != nil {
+		return h.responseJSON(w, req, ErrorStatus(err), err.Error())
 	}
 
 	return h.responseJSON(w, req, http.StatusOK, "user was updated")
@@ -172,7 +146,19 @@ func (h *Handler) updateUser(w http.ResponseWriter, req bunrouter.Request) error
 func (h *Handler) deleteUser(w http.ResponseWriter, req bunrouter.Request) error {
 	id := req.Params().ByName("id")
 
-	err := h.repo.DeleteUser(id)
+	target, err := h.tenantUser(req.Context(), id)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	if !h.mayManage(req.Context(), authenticatedUser(req.Context()), target, false) {
+		return h.responseJSON(w, req, http.StatusForbidden, errManageAdmins.Error())
+	}
+
+	err = h.repo.DeleteUser(req.Context(), id)
 	if err != nil {
 		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
 	}
@@ -180,24 +166,265 @@ func (h *Handler) deleteUser(w http.ResponseWriter, req bunrouter.Request) error
 	return h.responseJSON(w, req, http.StatusOK, "user was deleted")
 }
 
-func validate(newUser controller.UserRequest) error {
+// changePassword
+// @Summary Change own password
+// @Tags Me
+// @Description Change the password of the authenticated user. Required before any other call when the account was created by bootstrap. Signs out all other sessions.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param input body controller.PasswordChangeRequest true "passwords"
+// @Success 200
+// @Failure 400
+// @Failure 500
+// @Router /v1/me/password [PUT]
+func (h *Handler) changePassword(w http.ResponseWriter, req bunrouter.Request) error {
+	body := req.Body
+	defer body.Close()
+
+	var change controller.PasswordChangeRequest
+	if err := json.NewDecoder(body).Decode(&change); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	reason := validatePassword(change.NewPassword, h.cfg.Get().Password)
+	if change.NewPassword == "" {
+		reason = append(reason, "empty password")
+	}
+	if change.NewPassword == change.CurrentPassword {
+		reason = append(reason, "new password equals current password")
+	}
+	if len(reason) != 0 {
+		return h.responseJSON(w, req, http.StatusBadRequest, strings.Join(reason, ", "))
+	}
+
+	user := authenticatedUser(req.Context())
+
+	if !h.repo.IsAuthorized(req.Context(), user.Username, change.CurrentPassword) {
+		return h.responseJSON(w, req, http.StatusBadRequest, "wrong current password")
+	}
+
+	user.Password = change.NewPassword
+	user.MustChangePassword = false
+
+	err := h.repo.UpdateUser(req.Context(), user)
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	// Other sessions are signed out, the one making the change is kept.
+	err = h.sessions.RevokeAll(req.Context(), user.ID, currentSession(req.Context()).ID)
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, "password was changed")
+}
+
+// errManageAdmins refuses changes to admins by users without the admin
+// permission.
+var errManageAdmins = errors.New("only admins manage admins")
+
+// AddUser validates newUser and creates it in the tenant the request acts on,
+// returning the user as stored. Only holders of the admin permission create
+// admins.
+func (h *Handler) AddUser(ctx context.Context, newUser controller.UserRequest) (model.User, error) {
+	if err := validate(newUser, h.cfg.Get().Password); err != nil {
+		return model.User{}, err
+	}
+
+	if !h.mayManage(ctx, authenticatedUser(ctx), model.User{}, newUser.Admin) {
+		return model.User{}, errManageAdmins
+	}
+
+	user, err := h.repo.CreateUser(ctx, model.User{
+		Email:    newUser.Email,
+		Username: newUser.Username,
+		Password: newUser.Password,
+		Admin:    newUser.Admin,
+		Tenant:   tenant.FromContext(ctx),
+	})
+	if err != nil {
+		return model.User{}, err
+	}
+
+	h.sendVerificationTo(ctx, user.Username)
+
+	return user, nil
+}
+
+// EditUser validates change and replaces user id with it, keeping the
+// password when the change has none, and returns the user as stored.
+func (h *Handler) EditUser(ctx context.Context, id string, change controller.UserRequest) (model.User, error) {
+	err := validateUser(change.Username, change.Email, change.Password, false, h.cfg.Get().Password)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	old, err := h.tenantUser(ctx, id)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if !h.mayManage(ctx, authenticatedUser(ctx), old, change.Admin) {
+		return model.User{}, errManageAdmins
+	}
+
+	emailChanged := !strings.EqualFold(old.Email, change.Email)
+
+	user := old
+	user.Username = change.Username
+	user.Email = change.Email
+	user.Password = change.Password
+	user.Admin = change.Admin
+	user.EmailVerified = old.EmailVerified && !emailChanged
+
+	if err = h.repo.UpdateUser(ctx, user); err != nil {
+		return model.User{}, err
+	}
+
+	if emailChanged {
+		h.sendVerification(ctx, user)
+	}
+
+	// A password set by an admin signs the user out everywhere.
+	if change.Password != "" {
+		if err = h.sessions.RevokeAll(ctx, user.ID, ""); err != nil {
+			return model.User{}, err
+		}
+	}
+
+	return h.repo.GetUserByID(ctx, user.ID)
+}
+
+// RemoveUser deletes user id of the tenant the request acts on.
+func (h *Handler) RemoveUser(ctx context.Context, id string) error {
+	target, err := h.tenantUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !h.mayManage(ctx, authenticatedUser(ctx), target, false) {
+		return errManageAdmins
+	}
+
+	return h.repo.DeleteUser(ctx, id)
+}
+
+// User returns user id of the tenant the request acts on.
+func (h *Handler) User(ctx context.Context, id string) (model.User, error) {
+	return h.tenantUser(ctx, id)
+}
+
+// Users returns the users of the tenant the request acts on, ordered by
+// username.
+func (h *Handler) Users(ctx context.Context) []model.User {
+	users := h.repo.GetAllUsers(ctx)
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	return users
+}
+
+// Permitted reports whether the authenticated user holds permission.
+func (h *Handler) Permitted(ctx context.Context, permission string) bool {
+	return h.permitted(ctx, authenticatedUser(ctx), permission)
+}
+
+func userResponse(user model.User) controller.UserResponse {
+	return controller.UserResponse{
+		ID:             user.ID,
+		Email:          user.Email,
+		Username:       user.Username,
+		Admin:          user.Admin,
+		EmailVerified:  user.EmailVerified,
+		Tenant:         user.Tenant,
+		ServiceAccount: user.ServiceAccount,
+		Disabled:       user.Disabled,
+		CreatedAt:      user.CreatedAt,
+		UpdatedAt:      user.UpdatedAt,
+	}
+}
+
+func validate(newUser controller.UserRequest, policy config.Password) error {
+	return validateUser(newUser.Username, newUser.Email, newUser.Password, true, policy)
+}
+
+// validateUser checks the fields of a user, an empty password is fine unless
+// requirePassword is set.
+func validateUser(username, email, password string, requirePassword bool, policy config.Password) error {
 	var reason []string
 
-	if newUser.Username == "" {
+	if username == "" {
 		reason = append(reason, "empty username")
 	}
 
-	if newUser.Password == "" {
+	switch {
+	case password != "":
+		reason = append(reason, validatePassword(password, policy)...)
+	case requirePassword:
 		reason = append(reason, "empty password")
 	}
 
-	if newUser.Email == "" {
+	if email == "" {
 		reason = append(reason, "empty email")
 	}
 
 	if len(reason) != 0 {
-		return errors.New(strings.Join(reason, ", "))
+		return &inputError{reason: strings.Join(reason, ", ")}
 	}
 
 	return nil
 }
+
+// inputError rejects invalid fields of a request, answered with 400.
+type inputError struct {
+	reason string
+}
+
+func (e *inputError) Error() string {
+	return e.reason
+}
+
+func validatePassword(password string, policy config.Password) []string {
+	var (
+		reason                       []string
+		upper, lower, digit, special bool
+	)
+
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			special = true
+		}
+	}
+
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		reason = append(reason, fmt.Sprintf("password shorter than %d characters", policy.MinLength))
+	}
+
+	if policy.RequireUpper && !upper {
+		reason = append(reason, "password without uppercase letter")
+	}
+
+	if policy.RequireLower && !lower {
+		reason = append(reason, "password without lowercase letter")
+	}
+
+	if policy.RequireDigit && !digit {
+		reason = append(reason, "password without digit")
+	}
+
+	if policy.RequireSymbol && !special {
+		reason = append(reason, "password without symbol")
+	}
+
+	return reason
+}
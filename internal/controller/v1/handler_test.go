@@ -2,7 +2,11 @@ package v1
 
 import (
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
 	mock_repository "dev/profileSaver/internal/repository/mocks"
 	"errors"
 	"github.com/golang/mock/gomock"
@@ -10,6 +14,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func Test_handler(t *testing.T) {
@@ -31,15 +36,26 @@ func Test_handler(t *testing.T) {
 			method:  "POST",
 			isAdmin: true,
 			mockBehavior: func(s *mock_repository.MockRepository) {
-				s.EXPECT().CreateUser(model.User{
+				s.EXPECT().CreateUser(gomock.Any(), model.User{
 					Email:    "test@mail.ru",
 					Username: "test",
 					Password: "test",
-				}).Return(nil)
+				}).Return(model.User{
+					ID:        "2",
+					Email:     "test@mail.ru",
+					Username:  "test",
+					CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+					UpdatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				}, nil)
+				s.EXPECT().GetUserByName(gomock.Any(), "test").Return(model.User{
+					ID:       "2",
+					Email:    "test@mail.ru",
+					Username: "test",
+				}, nil)
 			},
 			inputBody:          `{"email":"test@mail.ru", "username":"test", "password":"test"}`,
-			expectedStatusCode: 200,
-			expectedResponseBody: `{"data":"user was created"}
+			expectedStatusCode: 201,
+			expectedResponseBody: `{"data":{"id":"2","email":"test@mail.ru","username":"test","admin":false,"email_verified":false,"created_at":"2024-01-02T03:04:05Z","updated_at":"2024-01-02T03:04:05Z"}}
 `,
 		},
 		{
@@ -48,11 +64,11 @@ func Test_handler(t *testing.T) {
 			method:  "POST",
 			isAdmin: true,
 			mockBehavior: func(s *mock_repository.MockRepository) {
-				s.EXPECT().CreateUser(model.User{
+				s.EXPECT().CreateUser(gomock.Any(), model.User{
 					Email:    "test@mail.ru",
 					Username: "test",
 					Password: "test",
-				}).Return(errors.New("error"))
+				}).Return(model.User{}, errors.New("error"))
 			},
 			inputBody:          `{"email":"test@mail.ru", "username":"test", "password":"test"}`,
 			expectedStatusCode: 500,
@@ -79,7 +95,35 @@ func Test_handler(t *testing.T) {
 			mockBehavior:       func(s *mock_repository.MockRepository) {},
 			inputBody:          `{1}`,
 			expectedStatusCode: 400,
-			expectedResponseBody: `{"error":{"Offset":2}}
+			expectedResponseBody: `{"error":"invalid character '1' looking for beginning of object key string"}
+`,
+		},
+		{
+			name:               "INVALID",
+			handler:            "CreateUser",
+			method:             "POST",
+			isAdmin:            true,
+			mockBehavior:       func(s *mock_repository.MockRepository) {},
+			inputBody:          `{"username":"test"}`,
+			expectedStatusCode: 400,
+			expectedResponseBody: `{"error":"empty password, empty email"}
+`,
+		},
+		{
+			name:    "EXISTS",
+			handler: "CreateUser",
+			method:  "POST",
+			isAdmin: true,
+			mockBehavior: func(s *mock_repository.MockRepository) {
+				s.EXPECT().CreateUser(gomock.Any(), model.User{
+					Email:    "test@mail.ru",
+					Username: "test",
+					Password: "test",
+				}).Return(model.User{}, repository.ErrUserNameExists)
+			},
+			inputBody:          `{"email":"test@mail.ru", "username":"test", "password":"test"}`,
+			expectedStatusCode: 409,
+			expectedResponseBody: `{"error":"username exists"}
 `,
 		},
 		{
@@ -87,7 +131,7 @@ func Test_handler(t *testing.T) {
 			method:  "GET",
 			handler: "GetAllUsers",
 			mockBehavior: func(s *mock_repository.MockRepository) {
-				s.EXPECT().GetAllUsers().Return([]model.User{})
+				s.EXPECT().GetAllUsers(gomock.Any()).Return([]model.User{})
 			},
 			expectedStatusCode: 200,
 			expectedResponseBody: `{"data":null}
@@ -98,10 +142,10 @@ func Test_handler(t *testing.T) {
 			method:  "GET",
 			handler: "GetUser",
 			mockBehavior: func(s *mock_repository.MockRepository) {
-				s.EXPECT().GetUserByID("1").Return(model.User{}, nil)
+				s.EXPECT().GetUserByID(gomock.Any(), "1").Return(model.User{}, nil)
 			},
 			expectedStatusCode: 200,
-			expectedResponseBody: `{"data":{"id":"","email":"","username":"","admin":false}}
+			expectedResponseBody: `{"data":{"id":"","email":"","username":"","admin":false,"email_verified":false,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}}
 `,
 		},
 		{
@@ -109,7 +153,7 @@ func Test_handler(t *testing.T) {
 			method:  "GET",
 			handler: "GetUser",
 			mockBehavior: func(s *mock_repository.MockRepository) {
-				s.EXPECT().GetUserByID("1").Return(model.User{}, errors.New("error"))
+				s.EXPECT().GetUserByID(gomock.Any(), "1").Return(model.User{}, errors.New("error"))
 			},
 			expectedStatusCode: 500,
 			expectedResponseBody: `{"error":"error"}
@@ -121,16 +165,46 @@ func Test_handler(t *testing.T) {
 			handler: "UpdateUser",
 			isAdmin: true,
 			mockBehavior: func(s *mock_repository.MockRepository) {
-				s.EXPECT().UpdateUser(model.User{
-					ID:       "1",
-					Email:    "test@mail.ru",
-					Username: "test",
-					Password: "test",
+				s.EXPECT().GetUserByID(gomock.Any(), "1").Return(model.User{
+					ID:            "1",
+					Email:         "test@mail.ru",
+					Username:      "old",
+					EmailVerified: true,
+				}, nil).Times(2)
+				s.EXPECT().UpdateUser(gomock.Any(), model.User{
+					ID:            "1",
+					Email:         "test@mail.ru",
+					Username:      "test",
+					Password:      "test",
+					EmailVerified: true,
 				}).Return(nil)
 			},
 			inputBody:          `{"email":"test@mail.ru", "username":"test", "password":"test"}`,
 			expectedStatusCode: 200,
 			expectedResponseBody: `{"data":"user was updated"}
+`,
+		},
+		{
+			name:    "KEEPS_FIELDS",
+			method:  "PATCH",
+			handler: "UpdateUser",
+			isAdmin: true,
+			mockBehavior: func(s *mock_repository.MockRepository) {
+				old := model.User{
+					ID:                 "1",
+					Email:              "test@mail.ru",
+					Username:           "old",
+					ServiceAccount:     true,
+					MustChangePassword: true,
+				}
+				s.EXPECT().GetUserByID(gomock.Any(), "1").Return(old, nil).Times(2)
+				changed := old
+				changed.Username = "test"
+				s.EXPECT().UpdateUser(gomock.Any(), changed).Return(nil)
+			},
+			inputBody:          `{"email":"test@mail.ru", "username":"test"}`,
+			expectedStatusCode: 200,
+			expectedResponseBody: `{"data":"user was updated"}
 `,
 		},
 		{
@@ -141,7 +215,7 @@ func Test_handler(t *testing.T) {
 			mockBehavior:       func(s *mock_repository.MockRepository) {},
 			inputBody:          `{}`,
 			expectedStatusCode: 400,
-			expectedResponseBody: `{"error":"empty username, empty password, empty email"}
+			expectedResponseBody: `{"error":"empty username, empty email"}
 `,
 		},
 		{
@@ -171,7 +245,8 @@ func Test_handler(t *testing.T) {
 			handler: "DeleteUser",
 			isAdmin: true,
 			mockBehavior: func(s *mock_repository.MockRepository) {
-				s.EXPECT().DeleteUser("1").Return(nil)
+				s.EXPECT().GetUserByID(gomock.Any(), "1").Return(model.User{ID: "1"}, nil)
+				s.EXPECT().DeleteUser(gomock.Any(), "1").Return(nil)
 			},
 			expectedStatusCode: 200,
 			expectedResponseBody: `{"data":"user was deleted"}
@@ -183,10 +258,23 @@ func Test_handler(t *testing.T) {
 			handler: "DeleteUser",
 			isAdmin: true,
 			mockBehavior: func(s *mock_repository.MockRepository) {
-				s.EXPECT().DeleteUser("1").Return(errors.New("error"))
+				s.EXPECT().GetUserByID(gomock.Any(), "1").Return(model.User{ID: "1"}, nil)
+				s.EXPECT().DeleteUser(gomock.Any(), "1").Return(errors.New("error"))
 			},
 			expectedStatusCode: 500,
 			expectedResponseBody: `{"error":"error"}
+`,
+		},
+		{
+			name:    "OTHER_TENANT",
+			method:  "DELETE",
+			handler: "DeleteUser",
+			isAdmin: true,
+			mockBehavior: func(s *mock_repository.MockRepository) {
+				s.EXPECT().GetUserByID(gomock.Any(), "1").Return(model.User{ID: "1", Tenant: "acme"}, nil)
+			},
+			expectedStatusCode: 404,
+			expectedResponseBody: `{"error":"user not found"}
 `,
 		},
 		{
@@ -206,7 +294,7 @@ func Test_handler(t *testing.T) {
 			defer c.Finish()
 
 			repo := mock_repository.NewMockRepository(c)
-			repo.EXPECT().IsAuthorized("admin", "admin").Return(true)
+			repo.EXPECT().IsAuthorized(gomock.Any(), "admin", "admin").Return(true)
 			testCase.mockBehavior(repo)
 
 			handlers := New(repo)
@@ -216,29 +304,31 @@ func Test_handler(t *testing.T) {
 			var req *http.Request
 			switch testCase.handler {
 			case "GetAllUsers":
+				repo.EXPECT().GetUserByName(gomock.Any(), "admin").Return(model.User{}, nil)
 				w = httptest.NewRecorder()
 				req = httptest.NewRequest("GET", "/v1/user",
 					nil)
 				req.SetBasicAuth("admin", "admin")
 			case "CreateUser":
-				repo.EXPECT().GetUserByName("admin").Return(model.User{Admin: testCase.isAdmin}, nil)
+				repo.EXPECT().GetUserByName(gomock.Any(), "admin").Return(model.User{Admin: testCase.isAdmin}, nil)
 				w = httptest.NewRecorder()
 				req = httptest.NewRequest("POST", "/v1/user",
 					bytes.NewBufferString(testCase.inputBody))
 				req.SetBasicAuth("admin", "admin")
 			case "UpdateUser":
-				repo.EXPECT().GetUserByName("admin").Return(model.User{Admin: testCase.isAdmin}, nil)
+				repo.EXPECT().GetUserByName(gomock.Any(), "admin").Return(model.User{Admin: testCase.isAdmin}, nil)
 				w = httptest.NewRecorder()
 				req = httptest.NewRequest("PATCH", "/v1/user/1",
 					bytes.NewBufferString(testCase.inputBody))
 				req.SetBasicAuth("admin", "admin")
 			case "GetUser":
+				repo.EXPECT().GetUserByName(gomock.Any(), "admin").Return(model.User{}, nil)
 				w = httptest.NewRecorder()
 				req = httptest.NewRequest("GET", "/v1/user/1",
 					nil)
 				req.SetBasicAuth("admin", "admin")
 			case "DeleteUser":
-				repo.EXPECT().GetUserByName("admin").Return(model.User{Admin: testCase.isAdmin}, nil)
+				repo.EXPECT().GetUserByName(gomock.Any(), "admin").Return(model.User{Admin: testCase.isAdmin}, nil)
 				w = httptest.NewRecorder()
 				req = httptest.NewRequest("DELETE", "/v1/user/1",
 					nil)
@@ -253,3 +343,165 @@ func Test_handler(t *testing.T) {
 		})
 	}
 }
+
+func Test_authMiddleware(t *testing.T) {
+	type mockBehavior func(s *mock_repository.MockRepository)
+
+	tests := []struct {
+		name               string
+		clientCertCN       string
+		basicAuth          bool
+		mockBehavior       mockBehavior
+		expectedStatusCode int
+	}{
+		{
+			name:         "CLIENT_CERT",
+			clientCertCN: "admin",
+			mockBehavior: func(s *mock_repository.MockRepository) {
+				s.EXPECT().GetUserByName(gomock.Any(), "admin").Return(model.User{Username: "admin"}, nil)
+				s.EXPECT().GetAllUsers(gomock.Any()).Return([]model.User{})
+			},
+			expectedStatusCode: 200,
+		},
+		{
+			name:         "UNKNOWN_CLIENT_CERT",
+			clientCertCN: "ghost",
+			mockBehavior: func(s *mock_repository.MockRepository) {
+				s.EXPECT().GetUserByName(gomock.Any(), "ghost").Return(model.User{}, repository.ErrUserNotFound)
+			},
+			expectedStatusCode: 401,
+		},
+		{
+			name:      "BASIC_AUTH",
+			basicAuth: true,
+			mockBehavior: func(s *mock_repository.MockRepository) {
+				s.EXPECT().IsAuthorized(gomock.Any(), "admin", "admin").Return(true)
+				s.EXPECT().GetUserByName(gomock.Any(), "admin").Return(model.User{Username: "admin"}, nil)
+				s.EXPECT().GetAllUsers(gomock.Any()).Return([]model.User{})
+			},
+			expectedStatusCode: 200,
+		},
+		{
+			name:               "NO_CREDENTIALS",
+			mockBehavior:       func(s *mock_repository.MockRepository) {},
+			expectedStatusCode: 401,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repo := mock_repository.NewMockRepository(c)
+			testCase.mockBehavior(repo)
+
+			r := New(repo).InitRouter()
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/v1/user", nil)
+			if testCase.basicAuth {
+				req.SetBasicAuth("admin", "admin")
+			}
+			if testCase.clientCertCN != "" {
+				req.TLS = &tls.ConnectionState{
+					VerifiedChains: [][]*x509.Certificate{{
+						{Subject: pkix.Name{CommonName: testCase.clientCertCN}},
+					}},
+				}
+			}
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func Test_changePassword(t *testing.T) {
+	type mockBehavior func(s *mock_repository.MockRepository)
+
+	mustChange := model.User{ID: "1", Username: "admin", Password: "hash", Admin: true, MustChangePassword: true}
+
+	tests := []struct {
+		name                 string
+		method               string
+		target               string
+		inputBody            string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:   "BLOCKED_UNTIL_CHANGED",
+			method: "GET",
+			target: "/v1/user",
+			mockBehavior: func(s *mock_repository.MockRepository) {
+			},
+			expectedStatusCode: 403,
+			expectedResponseBody: `{"error":"password change required"}
+`,
+		},
+		{
+			name:      "OK",
+			method:    "PUT",
+			target:    "/v1/me/password",
+			inputBody: `{"current_password":"admin","new_password":"n3w-password"}`,
+			mockBehavior: func(s *mock_repository.MockRepository) {
+				s.EXPECT().IsAuthorized(gomock.Any(), "admin", "admin").Return(true)
+				changed := mustChange
+				changed.Password = "n3w-password"
+				changed.MustChangePassword = false
+				s.EXPECT().UpdateUser(gomock.Any(), changed).Return(nil)
+			},
+			expectedStatusCode: 200,
+			expectedResponseBody: `{"data":"password was changed"}
+`,
+		},
+		{
+			name:      "WRONG_CURRENT",
+			method:    "PUT",
+			target:    "/v1/me/password",
+			inputBody: `{"current_password":"nope","new_password":"n3w-password"}`,
+			mockBehavior: func(s *mock_repository.MockRepository) {
+				s.EXPECT().IsAuthorized(gomock.Any(), "admin", "nope").Return(false)
+			},
+			expectedStatusCode: 400,
+			expectedResponseBody: `{"error":"wrong current password"}
+`,
+		},
+		{
+			name:               "SAME_PASSWORD",
+			method:             "PUT",
+			target:             "/v1/me/password",
+			inputBody:          `{"current_password":"admin","new_password":"admin"}`,
+			mockBehavior:       func(s *mock_repository.MockRepository) {},
+			expectedStatusCode: 400,
+			expectedResponseBody: `{"error":"new password equals current password"}
+`,
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repo := mock_repository.NewMockRepository(c)
+			repo.EXPECT().IsAuthorized(gomock.Any(), "admin", "admin").Return(true)
+			repo.EXPECT().GetUserByName(gomock.Any(), "admin").Return(mustChange, nil)
+			testCase.mockBehavior(repo)
+
+			r := New(repo).InitRouter()
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(testCase.method, testCase.target, bytes.NewBufferString(testCase.inputBody))
+			req.SetBasicAuth("admin", "admin")
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, testCase.expectedStatusCode, w.Code)
+			assert.Equal(t, testCase.expectedResponseBody, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testServer is a router over a fresh repository holding the users admin,
+// password admin, and bob, password bob-password, with unverified emails at
+// example.com.
+type testServer struct {
+	repo   *repository.DB
+	router http.Handler
+	admin  model.User
+	bob    model.User
+}
+
+// testRequest is a request to a testServer, sent with Basic auth when
+// username is set.
+type testRequest struct {
+	method, target, body string
+	username, password   string
+	authorization        string
+	header               map[string]string
+	cookie               *http.Cookie
+}
+
+// newTestServer returns a testServer routing without options, see route.
+func newTestServer(t *testing.T) *testServer {
+	ctx := context.Background()
+
+	s := &testServer{repo: repository.New(repository.WithHashParams(repository.HashParams{
+		Time: 1, Memory: 64, Threads: 1, KeyLen: 16, SaltLen: 8,
+	}))}
+
+	var err error
+	s.admin, err = s.repo.CreateUser(ctx, model.User{Username: "admin", Email: "admin@example.com", Password: "admin", Admin: true})
+	require.NoError(t, err)
+	s.bob, err = s.repo.CreateUser(ctx, model.User{Username: "bob", Email: "bob@example.com", Password: "bob-password"})
+	require.NoError(t, err)
+
+	s.route()
+
+	return s
+}
+
+// route replaces the router with one made with opts.
+func (s *testServer) route(opts ...Option) {
+	s.router = New(s.repo, opts...).InitRouter()
+}
+
+// do sends r to the router.
+func (s *testServer) do(r testRequest) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(r.method, r.target, bytes.NewBufferString(r.body))
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+	if r.authorization != "" {
+		req.Header.Set("Authorization", r.authorization)
+	}
+	for name, value := range r.header {
+		req.Header.Set(name, value)
+	}
+	if r.cookie != nil {
+		req.AddCookie(r.cookie)
+	}
+
+	return s.serve(req)
+}
+
+// serve sends req to the router.
+func (s *testServer) serve(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	return w
+}
@@ -0,0 +1,175 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/apikey"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/idempotency"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_idempotent(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	_, err := s.repo.CreateUser(ctx, model.User{Username: "root", Password: "root-password", Admin: true})
+	require.NoError(t, err)
+
+	keys := idempotency.NewManager(idempotency.NewRepositoryStore(s.repo), config.Idempotency{})
+	s.route(WithGroups(s.repo), WithIdempotency(keys), WithAPIKeys(apikey.NewManager(s.repo)))
+
+	alice := `{"username":"alice","email":"alice@example.com","password":"alice-password"}`
+
+	var aliceID string
+
+	t.Run("OK", func(t *testing.T) {
+		first := s.do(testRequest{method: "POST", target: "/v1/user", body: alice, username: "admin", password: "admin", header: map[string]string{idempotency.Header: "create-alice"}})
+		require.Equal(t, http.StatusCreated, first.Code, first.Body.String())
+
+		var created struct {
+			Data controller.UserResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(first.Body.Bytes(), &created))
+		assert.NotEmpty(t, created.Data.ID)
+		assert.False(t, created.Data.CreatedAt.IsZero())
+		assert.Equal(t, "/v1/user/"+created.Data.ID, first.Header().Get("Location"))
+		assert.Empty(t, first.Header().Get(idempotency.HeaderReplayed))
+		aliceID = created.Data.ID
+
+		retry := s.do(testRequest{method: "POST", target: "/v1/user", body: alice, username: "admin", password: "admin", header: map[string]string{idempotency.Header: "create-alice"}})
+		require.Equal(t, http.StatusCreated, retry.Code, retry.Body.String())
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, first.Header().Get("Location"), retry.Header().Get("Location"))
+		assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+		assert.Equal(t, "true", retry.Header().Get(idempotency.HeaderReplayed))
+
+		stored, err := s.repo.GetUserByName(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, created.Data.ID, stored.ID)
+	})
+
+	t.Run("OTHER_ROUTES", func(t *testing.T) {
+		issue := func() controller.APIKeyCreatedResponse {
+			w := s.do(testRequest{method: "POST", target: "/v1/me/api-keys", body: `{"name":"jobs","scopes":["read"]}`, username: "admin", password: "admin", header: map[string]string{idempotency.Header: "issue-key"}})
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Empty(t, w.Header().Get(idempotency.HeaderReplayed))
+
+			var resp struct {
+				Data controller.APIKeyCreatedResponse `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+			return resp.Data
+		}
+
+		first, second := issue(), issue()
+		assert.NotEqual(t, first.Key, second.Key, "keys are shown once and never kept for a replay")
+
+		key, err := idempotency.Key("", s.admin.ID, "issue-key")
+		require.NoError(t, err)
+		_, err = s.repo.GetIdempotencyRecord(ctx, key)
+		assert.ErrorIs(t, err, repository.ErrIdempotencyRecordNotFound)
+
+		w := s.do(testRequest{method: "DELETE", target: "/v1/user/" + aliceID, username: "admin", password: "admin", header: map[string]string{idempotency.Header: "delete-alice"}})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		w = s.do(testRequest{method: "DELETE", target: "/v1/user/" + aliceID, username: "admin", password: "admin", header: map[string]string{idempotency.Header: "delete-alice"}})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = s.do(testRequest{method: "GET", target: "/v1/user", username: "admin", password: "admin", header: map[string]string{idempotency.Header: "create-alice"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(idempotency.HeaderReplayed))
+	})
+
+	t.Run("MISMATCH", func(t *testing.T) {
+		erin := `{"username":"erin","email":"erin@example.com","password":"erin-password"}`
+		w := s.do(testRequest{method: "POST", target: "/v1/user", body: erin, username: "admin", password: "admin", header: map[string]string{idempotency.Header: "create-alice"}})
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+		_, err := s.repo.GetUserByName(ctx, "erin")
+		assert.ErrorIs(t, err, repository.ErrUserNotFound)
+	})
+
+	t.Run("IN_PROGRESS", func(t *testing.T) {
+		carol := `{"username":"carol","email":"carol@example.com","password":"carol-password"}`
+		key, err := idempotency.Key("", s.admin.ID, "create-carol")
+		require.NoError(t, err)
+		_, _, err = keys.Begin(ctx, key, idempotency.Fingerprint("POST", "/v1/user", []byte(carol)))
+		require.NoError(t, err)
+
+		w := s.do(testRequest{method: "POST", target: "/v1/user", body: carol, username: "admin", password: "admin", header: map[string]string{idempotency.Header: "create-carol"}})
+		assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("CONCURRENT", func(t *testing.T) {
+		dave := `{"username":"dave","email":"dave@example.com","password":"dave-password"}`
+
+		var (
+			wg    sync.WaitGroup
+			mu    sync.Mutex
+			codes = make(map[string]int)
+		)
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w := s.do(testRequest{method: "POST", target: "/v1/user", body: dave, username: "admin", password: "admin", header: map[string]string{idempotency.Header: "create-dave"}})
+
+				outcome := http.StatusText(w.Code)
+				if w.Header().Get(idempotency.HeaderReplayed) != "" {
+					outcome = "replayed"
+				}
+
+				mu.Lock()
+				codes[outcome]++
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 1, codes[http.StatusText(http.StatusCreated)], "dave is created once: %v", codes)
+		assert.Equal(t, 8, codes[http.StatusText(http.StatusCreated)]+codes[http.StatusText(http.StatusConflict)]+codes["replayed"], codes)
+	})
+
+	t.Run("NOT_OK", func(t *testing.T) {
+		w := s.do(testRequest{method: "POST", target: "/v1/user", body: alice, username: "root", password: "root-password", header: map[string]string{idempotency.Header: "create-alice"}})
+		assert.Equal(t, http.StatusCreated, w.Code, "keys are kept per user")
+		assert.Empty(t, w.Header().Get(idempotency.HeaderReplayed))
+
+		w = s.do(testRequest{method: "POST", target: "/v1/user", body: alice, username: "admin", password: "admin"})
+		assert.Equal(t, http.StatusConflict, w.Code, "requests without a key aren't replayed")
+
+		erin := `{"username":"erin","email":"erin@example.com"}`
+		w = s.do(testRequest{method: "POST", target: "/v1/user", body: erin, username: "admin", password: "admin", header: map[string]string{idempotency.Header: "create-erin"}})
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		erin = `{"username":"erin","email":"erin@example.com","password":"erin-password"}`
+		w = s.do(testRequest{method: "POST", target: "/v1/user", body: erin, username: "admin", password: "admin", header: map[string]string{idempotency.Header: "create-erin"}})
+		assert.Equal(t, http.StatusCreated, w.Code, "failed requests may be retried with their key")
+
+		w = s.do(testRequest{method: "POST", target: "/v1/user", body: erin, username: "admin", password: "admin", header: map[string]string{idempotency.Header: strings.Repeat("k", idempotency.MaxKeyLength+1)}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("TOO_LARGE", func(t *testing.T) {
+		s.route(WithIdempotency(idempotency.NewManager(idempotency.NewMemoryStore(), config.Idempotency{MaxBodySize: 16})))
+
+		frank := `{"username":"frank","email":"frank@example.com","password":"frank-password"}`
+		w := s.do(testRequest{method: "POST", target: "/v1/user", body: frank, username: "admin", password: "admin", header: map[string]string{idempotency.Header: "create-frank"}})
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, w.Body.String())
+
+		_, err := s.repo.GetUserByName(ctx, "frank")
+		assert.ErrorIs(t, err, repository.ErrUserNotFound)
+
+		w = s.do(testRequest{method: "POST", target: "/v1/user", body: frank, username: "admin", password: "admin"})
+		assert.Equal(t, http.StatusCreated, w.Code, "requests without a key aren't capped")
+	})
+}
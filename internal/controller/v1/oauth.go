@@ -0,0 +1,602 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/oauth"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/tenant"
+	"encoding/json"
+	"errors"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// routeAuthorize is where users sign in to clients. It is outside /v1 like
+// the other OAuth2 endpoints.
+const routeAuthorize = "/oauth/authorize"
+
+// oauthAuthorize
+// @Summary OAuth2 authorization endpoint
+// @Tags OAuth
+// @Description Authorization code grant for the authenticated user (RFC 6749, PKCE per RFC 7636 with S256, required for public clients). Redirects with a code once the user allowed the client the requested scopes; until then GET answers with the consent to ask for, and POST with consent=allow or consent=deny answers it. Errors about the client or redirect_uri are 400, others are redirected. Not available to API keys.
+// @Produce  json
+// @Security BasicAuth
+// @Param response_type query string true "code"
+// @Param client_id query string true "client id"
+// @Param redirect_uri query string false "registered redirect uri, optional with a single one"
+// @Param scope query string false "space separated scopes, all of the client when empty"
+// @Param state query string false "returned with the redirect"
+// @Param code_challenge query string false "PKCE challenge"
+// @Param code_challenge_method query string false "S256"
+// @Param nonce query string false "OpenID Connect nonce, returned in the ID token"
+// @Param consent formData string false "allow or deny, POST only"
+// @Success 200 {object} controller.ConsentRequiredResponse
+// @Success 302
+// @Failure 400
+// @Failure 401
+// @Router /oauth/authorize [GET]
+// @Router /oauth/authorize [POST]
+func (h *Handler) oauthAuthorize(w http.ResponseWriter, req bunrouter.Request) error {
+	if err := req.ParseForm(); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	a, err := h.oauth.Authorize(req.Context(), oauth.AuthorizeRequest{
+		ResponseType:        req.Form.Get("response_type"),
+		ClientID:            req.Form.Get("client_id"),
+		RedirectURI:         req.Form.Get("redirect_uri"),
+		Scope:               req.Form.Get("scope"),
+		State:               req.Form.Get("state"),
+		CodeChallenge:       req.Form.Get("code_challenge"),
+		CodeChallengeMethod: req.Form.Get("code_challenge_method"),
+		Nonce:               req.Form.Get("nonce"),
+	})
+	if a.RedirectURI != "" && a.Client.Tenant != tenant.FromContext(req.Context()) {
+		a, err = oauth.Authorization{}, &oauth.Error{Code: oauth.InvalidClient, Description: "unknown client"}
+	}
+	if oauth.ErrorCode(err) != "" && a.RedirectURI == "" {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+	if oauth.ErrorCode(err) != "" {
+		return authorizeRedirect(w, req, a, oauthErrorParams(err))
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	user := authenticatedUser(req.Context())
+
+	if req.Method == http.MethodPost {
+		switch req.PostForm.Get("consent") {
+		case "allow":
+			if err = h.oauth.Consent(req.Context(), user.ID, a); err != nil {
+				return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+			}
+		case "deny":
+			return authorizeRedirect(w, req, a, url.Values{
+				"error":             {oauth.AccessDenied},
+				"error_description": {"the user denied the request"},
+			})
+		default:
+			return h.responseJSON(w, req, http.StatusBadRequest, "consent must be allow or deny")
+		}
+	} else if !h.oauth.Consented(req.Context(), user.ID, a) {
+		return h.responseJSON(w, req, http.StatusOK, controller.ConsentRequiredResponse{
+			ClientID:    a.Client.ID,
+			ClientName:  a.Client.Name,
+			RedirectURI: a.RedirectURI,
+			Scopes:      a.Scopes,
+		})
+	}
+
+	code, err := h.oauth.IssueCode(req.Context(), user.ID, a)
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return authorizeRedirect(w, req, a, url.Values{"code": {code}})
+}
+
+// oauthToken
+// @Summary OAuth2 token endpoint
+// @Tags OAuth
+// @Description Exchanges an authorization code, client credentials or a refresh token for tokens (RFC 6749). Clients authenticate with Basic auth or client_id and client_secret; public clients send only client_id. Refresh tokens are single-use, a new one comes with every refresh. Tokens of users with the openid scope come with an ID token. Answers follow the RFC, without the data envelope.
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param grant_type formData string true "authorization_code, client_credentials or refresh_token"
+// @Param code formData string false "authorization code"
+// @Param redirect_uri formData string false "redirect uri of the authorization request, required if it was sent there"
+// @Param code_verifier formData string false "PKCE verifier"
+// @Param refresh_token formData string false "refresh token"
+// @Param scope formData string false "space separated scopes"
+// @Param client_id formData string false "client id, unless sent with Basic auth"
+// @Param client_secret formData string false "client secret, unless sent with Basic auth"
+// @Success 200 {object} controller.OAuthTokenResponse
+// @Failure 400 {object} controller.OAuthErrorResponse
+// @Failure 401 {object} controller.OAuthErrorResponse
+// @Router /oauth/token [POST]
+func (h *Handler) oauthToken(w http.ResponseWriter, req bunrouter.Request) error {
+	c, ok, err := h.oauthClient(w, req)
+	if !ok {
+		return err
+	}
+
+	tokens, err := h.oauth.Token(req.Context(), c, oauth.TokenRequest{
+		GrantType:    req.PostForm.Get("grant_type"),
+		Code:         req.PostForm.Get("code"),
+		RedirectURI:  req.PostForm.Get("redirect_uri"),
+		CodeVerifier: req.PostForm.Get("code_verifier"),
+		RefreshToken: req.PostForm.Get("refresh_token"),
+		Scope:        req.PostForm.Get("scope"),
+		Check: func(ctx context.Context, t model.OAuthToken) error {
+			_, err := h.oauthSubject(ctx, t)
+			return err
+		},
+	})
+	if err != nil {
+		return h.oauthError(w, req, err)
+	}
+
+	idToken, err := h.idToken(req, c, tokens)
+	if err != nil {
+		return h.oauthError(w, req, err)
+	}
+
+	logger.FromContext(req.Context()).Info().
+		Str("client", c.ID).
+		Str("grant_type", req.PostForm.Get("grant_type")).
+		Msg("oauth tokens issued")
+
+	return oauthJSON(w, http.StatusOK, controller.OAuthTokenResponse{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(tokens.ExpiresIn.Seconds()),
+		RefreshToken: tokens.RefreshToken,
+		Scope:        strings.Join(tokens.Scopes, " "),
+		IDToken:      idToken,
+	})
+}
+
+// oauthIntrospect
+// @Summary OAuth2 token introspection
+// @Tags OAuth
+// @Description Tells resource servers whether an access or refresh token is active and what it grants (RFC 7662). Only confidential clients may ask, and only about tokens of their tenant.
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param token formData string true "token"
+// @Success 200 {object} controller.IntrospectionResponse
+// @Failure 401 {object} controller.OAuthErrorResponse
+// @Router /oauth/introspect [POST]
+func (h *Handler) oauthIntrospect(w http.ResponseWriter, req bunrouter.Request) error {
+	c, ok, err := h.oauthClient(w, req)
+	if !ok {
+		return err
+	}
+
+	if !c.Confidential() {
+		return h.oauthError(w, req, &oauth.Error{Code: oauth.InvalidClient, Description: "public clients can't introspect"})
+	}
+
+	t, active := h.oauth.Introspect(req.Context(), req.PostForm.Get("token"))
+	if !active || t.Tenant != c.Tenant {
+		return oauthJSON(w, http.StatusOK, controller.IntrospectionResponse{Active: false})
+	}
+
+	response := controller.IntrospectionResponse{
+		Active:    true,
+		Scope:     strings.Join(t.Scopes, " "),
+		ClientID:  t.ClientID,
+		Subject:   t.UserID,
+		TokenType: t.Kind + "_token",
+		ExpiresAt: t.ExpiresAt.Unix(),
+		IssuedAt:  t.CreatedAt.Unix(),
+		Tenant:    t.Tenant,
+	}
+
+	user, err := h.oauthSubject(req.Context(), t)
+	if err != nil {
+		return oauthJSON(w, http.StatusOK, controller.IntrospectionResponse{Active: false})
+	}
+	response.Username = user.Username
+
+	return oauthJSON(w, http.StatusOK, response)
+}
+
+// oauthSubject returns the user of t, none for client credentials, and
+// checks that they and the tenant of t are still enabled.
+func (h *Handler) oauthSubject(ctx context.Context, t model.OAuthToken) (model.User, error) {
+	if err := h.activeTenant(ctx, t.Tenant); err != nil {
+		return model.User{}, err
+	}
+
+	if t.UserID == "" {
+		return model.User{}, nil
+	}
+
+	user, err := h.repo.GetUserByID(ctx, t.UserID)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if user.Disabled {
+		return model.User{}, errUserDisabled
+	}
+
+	return user, nil
+}
+
+// oauthRevoke
+// @Summary OAuth2 token revocation
+// @Tags OAuth
+// @Description Revokes an access or refresh token of the calling client (RFC 7009). Revoking a refresh token also revokes the access tokens issued with it. Unknown tokens are no error.
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param token formData string true "token"
+// @Success 200
+// @Failure 401 {object} controller.OAuthErrorResponse
+// @Router /oauth/revoke [POST]
+func (h *Handler) oauthRevoke(w http.ResponseWriter, req bunrouter.Request) error {
+	c, ok, err := h.oauthClient(w, req)
+	if !ok {
+		return err
+	}
+
+	if err = h.oauth.Revoke(req.Context(), c, req.PostForm.Get("token")); err != nil {
+		return h.oauthError(w, req, err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// getOAuthClients
+// @Summary Get OAuth2 clients
+// @Tags OAuth
+// @Description Get the OAuth2 clients of the tenant ordered by name, needs the admin permission
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Success 200 {array} controller.OAuthClientResponse
+// @Failure 401
+// @Router /v1/oauth/client [GET]
+func (h *Handler) getOAuthClients(w http.ResponseWriter, req bunrouter.Request) error {
+	clients := h.oauth.Clients(req.Context())
+
+	response := make([]controller.OAuthClientResponse, 0, len(clients))
+	for _, c := range clients {
+		response = append(response, oauthClientResponse(c, ""))
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, response)
+}
+
+// createOAuthClient
+// @Summary Register OAuth2 client
+// @Tags OAuth
+// @Description Register an application that delegates login, needs the admin permission. The secret of a confidential client is only shown in this response.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param input body controller.OAuthClientRequest true "client"
+// @Success 200 {object} controller.OAuthClientResponse
+// @Failure 400
+// @Failure 401
+// @Failure 500
+// @Router /v1/oauth/client [POST]
+func (h *Handler) createOAuthClient(w http.ResponseWriter, req bunrouter.Request) error {
+	var input controller.OAuthClientRequest
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	c, secret, err := h.oauth.RegisterClient(req.Context(), model.OAuthClient{
+		Tenant:       tenant.FromContext(req.Context()),
+		Name:         input.Name,
+		RedirectURIs: input.RedirectURIs,
+		GrantTypes:   input.GrantTypes,
+		Scopes:       input.Scopes,
+	}, input.Confidential)
+	if errors.Is(err, oauth.ErrInvalidClient) {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, oauthClientResponse(c, secret))
+}
+
+// getOAuthClient
+// @Summary Get OAuth2 client by id
+// @Tags OAuth
+// @Description Get an OAuth2 client, needs the admin permission
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "client id"
+// @Success 200 {object} controller.OAuthClientResponse
+// @Failure 401
+// @Failure 404
+// @Router /v1/oauth/client/{id} [GET]
+func (h *Handler) getOAuthClient(w http.ResponseWriter, req bunrouter.Request) error {
+	c, ok, err := h.loadOAuthClient(w, req)
+	if !ok {
+		return err
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, oauthClientResponse(c, ""))
+}
+
+// updateOAuthClient
+// @Summary Update OAuth2 client
+// @Tags OAuth
+// @Description Replace name, redirect uris, grant types and scopes of an OAuth2 client, needs the admin permission. Whether it is confidential can't be changed.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "client id"
+// @Param input body controller.OAuthClientRequest true "client"
+// @Success 200 {object} controller.OAuthClientResponse
+// @Failure 400
+// @Failure 401
+// @Failure 404
+// @Router /v1/oauth/client/{id} [PATCH]
+func (h *Handler) updateOAuthClient(w http.ResponseWriter, req bunrouter.Request) error {
+	var input controller.OAuthClientRequest
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	c, ok, err := h.loadOAuthClient(w, req)
+	if !ok {
+		return err
+	}
+
+	c.Name = input.Name
+	c.RedirectURIs = input.RedirectURIs
+	c.GrantTypes = input.GrantTypes
+	c.Scopes = input.Scopes
+
+	err = h.oauth.UpdateClient(req.Context(), c)
+	if errors.Is(err, oauth.ErrInvalidClient) {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, oauthClientResponse(c, ""))
+}
+
+// deleteOAuthClient
+// @Summary Delete OAuth2 client
+// @Tags OAuth
+// @Description Delete an OAuth2 client together with its consents and tokens, needs the admin permission
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "client id"
+// @Success 200
+// @Failure 401
+// @Failure 404
+// @Router /v1/oauth/client/{id} [DELETE]
+func (h *Handler) deleteOAuthClient(w http.ResponseWriter, req bunrouter.Request) error {
+	c, ok, err := h.loadOAuthClient(w, req)
+	if !ok {
+		return err
+	}
+
+	if err = h.oauth.DeleteClient(req.Context(), c.ID); err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, "client was deleted")
+}
+
+// rotateOAuthClientSecret
+// @Summary Rotate OAuth2 client secret
+// @Tags OAuth
+// @Description Give a confidential client a new secret, shown only in this response. The old secret stops working. Needs the admin permission.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "client id"
+// @Success 200 {object} controller.OAuthClientResponse
+// @Failure 400
+// @Failure 401
+// @Failure 404
+// @Router /v1/oauth/client/{id}/secret [POST]
+func (h *Handler) rotateOAuthClientSecret(w http.ResponseWriter, req bunrouter.Request) error {
+	c, ok, err := h.loadOAuthClient(w, req)
+	if !ok {
+		return err
+	}
+
+	secret, err := h.oauth.RotateSecret(req.Context(), c)
+	if errors.Is(err, oauth.ErrPublicClient) {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, oauthClientResponse(c, secret))
+}
+
+// getConsents
+// @Summary Get own consents
+// @Tags Me
+// @Description Get the OAuth2 clients the authenticated user allowed, newest first
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Success 200 {array} controller.ConsentResponse
+// @Router /v1/me/consents [GET]
+func (h *Handler) getConsents(w http.ResponseWriter, req bunrouter.Request) error {
+	consents := h.oauth.Consents(req.Context(), authenticatedUser(req.Context()).ID)
+
+	response := make([]controller.ConsentResponse, 0, len(consents))
+	for _, c := range consents {
+		r := controller.ConsentResponse{
+			ClientID:  c.ClientID,
+			Scopes:    c.Scopes,
+			GrantedAt: c.GrantedAt,
+		}
+		if client, err := h.oauth.Client(req.Context(), c.ClientID); err == nil {
+			r.ClientName = client.Name
+		}
+		response = append(response, r)
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, response)
+}
+
+// deleteConsent
+// @Summary Withdraw consent
+// @Tags Me
+// @Description Withdraw the consent of the authenticated user to an OAuth2 client, revoking the tokens the client holds for them
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param client_id path string true "client id"
+// @Success 200
+// @Failure 404
+// @Router /v1/me/consents/{client_id} [DELETE]
+func (h *Handler) deleteConsent(w http.ResponseWriter, req bunrouter.Request) error {
+	err := h.oauth.Withdraw(req.Context(), authenticatedUser(req.Context()).ID, req.Params().ByName("client_id"))
+	if errors.Is(err, repository.ErrConsentNotFound) {
+		return h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, "consent was withdrawn")
+}
+
+// oauthClient authenticates the client calling the token, introspection or
+// revocation endpoint, by Basic auth or the client_id and client_secret form
+// values. When it returns false the response has been written.
+func (h *Handler) oauthClient(w http.ResponseWriter, req bunrouter.Request) (model.OAuthClient, bool, error) {
+	if err := req.ParseForm(); err != nil {
+		return model.OAuthClient{}, false, oauthJSON(w, http.StatusBadRequest, controller.OAuthErrorResponse{
+			Error:            oauth.InvalidRequest,
+			ErrorDescription: err.Error(),
+		})
+	}
+
+	id, secret, ok := req.BasicAuth()
+	if ok {
+		// RFC 6749 has the credentials form-urlencoded before Basic encoding.
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id, secret = req.PostForm.Get("client_id"), req.PostForm.Get("client_secret")
+	}
+
+	c, err := h.oauth.AuthenticateClient(req.Context(), id, secret)
+	if err == nil && !sameTenant(req.Context(), c) {
+		err = &oauth.Error{Code: oauth.InvalidClient, Description: "unknown client"}
+	}
+	if err != nil {
+		return model.OAuthClient{}, false, h.oauthError(w, req, err)
+	}
+
+	return c, true, nil
+}
+
+// sameTenant reports whether c belongs to the tenant the request names, if
+// it names one.
+func sameTenant(ctx context.Context, c model.OAuthClient) bool {
+	id, named := tenant.Lookup(ctx)
+
+	return !named || id == c.Tenant
+}
+
+// loadOAuthClient returns the client named by the id parameter. When it
+// returns false the response has been written.
+func (h *Handler) loadOAuthClient(w http.ResponseWriter, req bunrouter.Request) (model.OAuthClient, bool, error) {
+	c, err := h.oauth.Client(req.Context(), req.Params().ByName("id"))
+	if err == nil && c.Tenant != tenant.FromContext(req.Context()) {
+		err = repository.ErrClientNotFound
+	}
+	if errors.Is(err, repository.ErrClientNotFound) {
+		return model.OAuthClient{}, false, h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return model.OAuthClient{}, false, h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return c, true, nil
+}
+
+// oauthError writes err as an RFC 6749 error response.
+func (h *Handler) oauthError(w http.ResponseWriter, req bunrouter.Request, err error) error {
+	var e *oauth.Error
+	if !errors.As(err, &e) {
+		logger.FromContext(req.Context()).Error().Err(err).Msg("oauth request failed")
+		return oauthJSON(w, http.StatusInternalServerError, controller.OAuthErrorResponse{Error: "server_error"})
+	}
+
+	code := http.StatusBadRequest
+	if e.Code == oauth.InvalidClient {
+		code = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+
+	logger.FromContext(req.Context()).Warn().
+		Int("status", code).
+		Str("error", e.Code).
+		Str("description", e.Description).
+		Msg("oauth request failed")
+
+	return oauthJSON(w, code, controller.OAuthErrorResponse{
+		Error:            e.Code,
+		ErrorDescription: e.Description,
+	})
+}
+
+// oauthJSON writes value as is, OAuth2 responses have no data envelope.
+func oauthJSON(w http.ResponseWriter, code int, value interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(code)
+
+	return json.NewEncoder(w).Encode(value)
+}
+
+func authorizeRedirect(w http.ResponseWriter, req bunrouter.Request, a oauth.Authorization, params url.Values) error {
+	w.Header().Del("Content-Type")
+	http.Redirect(w, req.Request, oauth.Redirect(a, params), http.StatusFound)
+
+	return nil
+}
+
+func oauthErrorParams(err error) url.Values {
+	var e *oauth.Error
+	errors.As(err, &e)
+
+	return url.Values{
+		"error":             {e.Code},
+		"error_description": {e.Description},
+	}
+}
+
+func oauthClientResponse(c model.OAuthClient, secret string) controller.OAuthClientResponse {
+	return controller.OAuthClientResponse{
+		ID:           c.ID,
+		Name:         c.Name,
+		RedirectURIs: c.RedirectURIs,
+		GrantTypes:   c.GrantTypes,
+		Scopes:       c.Scopes,
+		Confidential: c.Confidential(),
+		CreatedAt:    c.CreatedAt,
+		Secret:       secret,
+	}
+}
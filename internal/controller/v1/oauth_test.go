@@ -0,0 +1,286 @@
+package v1
+
+import (
+	"context"
+	"crypto/sha256"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/oauth"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_oauth(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	server := oauth.NewServer(s.repo, config.OAuth{})
+	s.route(WithOAuth(server), WithTenants(s.repo))
+
+	asAdmin := func(method, target, body string) *httptest.ResponseRecorder {
+		return s.do(testRequest{method: method, target: target, body: body, username: "admin", password: "admin"})
+	}
+
+	asBob := func(method, target string, form url.Values) *httptest.ResponseRecorder {
+		return s.do(testRequest{method: method, target: target, body: form.Encode(), username: "bob", password: "bob-password",
+			header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"}})
+	}
+
+	post := func(target string, form url.Values, clientID, secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if secret != "" {
+			req.SetBasicAuth(clientID, secret)
+		}
+
+		return s.serve(req)
+	}
+
+	register := func(body string) controller.OAuthClientResponse {
+		w := asAdmin("POST", "/v1/oauth/client", body)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp struct {
+			Data controller.OAuthClientResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+		return resp.Data
+	}
+
+	tokens := func(w *httptest.ResponseRecorder) controller.OAuthTokenResponse {
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+		var resp controller.OAuthTokenResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+		return resp
+	}
+
+	spa := register(`{"name":"spa","redirect_uris":["https://app.test/cb"],
+		"grant_types":["authorization_code","refresh_token"],"scopes":["profile","write"]}`)
+	assert.Empty(t, spa.Secret)
+
+	api := register(`{"name":"api","grant_types":["client_credentials"],"scopes":["reports"],"confidential":true}`)
+	require.NotEmpty(t, api.Secret)
+
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+
+	authorize := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {spa.ID},
+		"scope":                 {"profile"},
+		"state":                 {"s1"},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	var refreshToken string
+
+	t.Run("AUTHORIZATION_CODE", func(t *testing.T) {
+		w := asBob("GET", "/oauth/authorize?"+authorize.Encode(), nil)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"client_name":"spa"`, "consent is asked first")
+
+		form := url.Values{"consent": {"allow"}}
+		w = asBob("POST", "/oauth/authorize?"+authorize.Encode(), form)
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "app.test", location.Host)
+		assert.Equal(t, "s1", location.Query().Get("state"))
+		code := location.Query().Get("code")
+		require.NotEmpty(t, code)
+
+		exchange := url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {spa.ID},
+			"code":          {code},
+			"redirect_uri":  {"https://app.test/cb"},
+			"code_verifier": {verifier},
+		}
+		resp := tokens(post("/oauth/token", exchange, "", ""))
+		assert.Equal(t, "Bearer", resp.TokenType)
+		assert.Equal(t, "profile", resp.Scope)
+		assert.Equal(t, int64(3600), resp.ExpiresIn)
+		refreshToken = resp.RefreshToken
+
+		w = post("/oauth/token", exchange, "", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"invalid_grant"`)
+
+		w = post("/oauth/introspect", url.Values{"token": {resp.AccessToken}}, api.ID, api.Secret)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"active":true`)
+		assert.Contains(t, w.Body.String(), `"username":"bob"`)
+		assert.Contains(t, w.Body.String(), `"sub":"`+s.bob.ID+`"`)
+
+		w = asBob("GET", "/oauth/authorize?"+authorize.Encode(), nil)
+		assert.Equal(t, http.StatusFound, w.Code, "consent is remembered")
+	})
+
+	t.Run("AUTHORIZE_ERRORS", func(t *testing.T) {
+		bad := url.Values{"response_type": {"code"}, "client_id": {spa.ID}, "redirect_uri": {"https://evil.test/cb"}}
+		w := asBob("GET", "/oauth/authorize?"+bad.Encode(), nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "never redirect to unregistered uris")
+
+		noPKCE := url.Values{"response_type": {"code"}, "client_id": {spa.ID}, "state": {"s2"}}
+		w = asBob("GET", "/oauth/authorize?"+noPKCE.Encode(), nil)
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Contains(t, w.Header().Get("Location"), "error=invalid_request")
+		assert.Contains(t, w.Header().Get("Location"), "state=s2")
+
+		w = asBob("POST", "/oauth/authorize?"+authorize.Encode(), url.Values{"consent": {"deny"}})
+		require.Equal(t, http.StatusFound, w.Code)
+		assert.Contains(t, w.Header().Get("Location"), "error=access_denied")
+
+		w = s.serve(httptest.NewRequest("GET", "/oauth/authorize?"+authorize.Encode(), nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "users sign in first")
+	})
+
+	t.Run("REFRESH", func(t *testing.T) {
+		refresh := url.Values{"grant_type": {"refresh_token"}, "client_id": {spa.ID}, "refresh_token": {refreshToken}}
+		resp := tokens(post("/oauth/token", refresh, "", ""))
+		assert.NotEqual(t, refreshToken, resp.RefreshToken)
+
+		w := post("/oauth/token", refresh, "", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, "refresh tokens are single-use")
+
+		w = post("/oauth/revoke", url.Values{"token": {resp.RefreshToken}, "client_id": {spa.ID}}, "", "")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = post("/oauth/introspect", url.Values{"token": {resp.AccessToken}}, api.ID, api.Secret)
+		assert.Equal(t, `{"active":false}
+`, w.Body.String(), "revoking the refresh token revokes its grant")
+	})
+
+	t.Run("CLIENT_CREDENTIALS", func(t *testing.T) {
+		form := url.Values{"grant_type": {"client_credentials"}}
+		resp := tokens(post("/oauth/token", form, api.ID, api.Secret))
+		assert.Empty(t, resp.RefreshToken)
+		assert.Equal(t, "reports", resp.Scope)
+
+		w := post("/oauth/token", form, api.ID, "wrong")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"invalid_client"`)
+
+		w = post("/oauth/token", url.Values{"grant_type": {"client_credentials"}, "client_id": {spa.ID}}, "", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"unauthorized_client"`)
+
+		w = post("/oauth/introspect", url.Values{"token": {resp.AccessToken}, "client_id": {spa.ID}}, "", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "public clients can't introspect")
+	})
+
+	t.Run("DISABLED", func(t *testing.T) {
+		code := func() string {
+			w := asBob("GET", "/oauth/authorize?"+authorize.Encode(), nil)
+			require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+
+			location, err := url.Parse(w.Header().Get("Location"))
+			require.NoError(t, err)
+
+			return location.Query().Get("code")
+		}
+		exchange := func(code string) url.Values {
+			return url.Values{
+				"grant_type":    {"authorization_code"},
+				"client_id":     {spa.ID},
+				"code":          {code},
+				"code_verifier": {verifier},
+			}
+		}
+
+		resp := tokens(post("/oauth/token", exchange(code()), "", ""))
+		pending := code()
+
+		disabled := s.bob
+		disabled.Password = ""
+		disabled.Disabled = true
+		require.NoError(t, s.repo.UpdateUser(ctx, disabled))
+		defer func() {
+			disabled.Disabled = false
+			require.NoError(t, s.repo.UpdateUser(ctx, disabled))
+		}()
+
+		w := post("/oauth/token", exchange(pending), "", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"invalid_grant"`)
+
+		refresh := url.Values{"grant_type": {"refresh_token"}, "client_id": {spa.ID}, "refresh_token": {resp.RefreshToken}}
+		w = post("/oauth/token", refresh, "", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"invalid_grant"`)
+
+		w = post("/oauth/introspect", url.Values{"token": {resp.AccessToken}}, api.ID, api.Secret)
+		assert.Equal(t, `{"active":false}
+`, w.Body.String())
+
+		require.NoError(t, s.repo.CreateTenant(ctx, model.Tenant{ID: "acme", Name: "Acme"}))
+		c, secret, err := server.RegisterClient(ctx, model.OAuthClient{
+			Name:       "acme-api",
+			Tenant:     "acme",
+			GrantTypes: []string{model.GrantClientCredentials},
+			Scopes:     []string{"reports"},
+		}, true)
+		require.NoError(t, err)
+
+		form := url.Values{"grant_type": {"client_credentials"}}
+		acme := tokens(post("/oauth/token", form, c.ID, secret))
+
+		require.NoError(t, s.repo.UpdateTenant(ctx, model.Tenant{ID: "acme", Name: "Acme", Disabled: true}))
+
+		w = post("/oauth/token", form, c.ID, secret)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"error":"invalid_grant"`)
+
+		w = post("/oauth/introspect", url.Values{"token": {acme.AccessToken}}, c.ID, secret)
+		assert.Equal(t, `{"active":false}
+`, w.Body.String(), "tokens of disabled tenants are inactive")
+	})
+
+	t.Run("CONSENTS", func(t *testing.T) {
+		w := asBob("GET", "/v1/me/consents", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"client_name":"spa"`)
+
+		require.Equal(t, http.StatusOK, asBob("DELETE", "/v1/me/consents/"+spa.ID, nil).Code)
+		assert.Equal(t, http.StatusNotFound, asBob("DELETE", "/v1/me/consents/"+spa.ID, nil).Code)
+
+		w = asBob("GET", "/oauth/authorize?"+authorize.Encode(), nil)
+		assert.Equal(t, http.StatusOK, w.Code, "consent is asked again")
+	})
+
+	t.Run("CLIENT_ADMIN", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, asBob("GET", "/v1/oauth/client", nil).Code)
+
+		w := asAdmin("POST", "/v1/oauth/client", `{"name":"x","grant_types":["implicit"]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = asAdmin("POST", "/v1/oauth/client/"+spa.ID+"/secret", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, "public clients have no secret")
+
+		w = asAdmin("POST", "/v1/oauth/client/"+api.ID+"/secret", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusUnauthorized,
+			post("/oauth/token", url.Values{"grant_type": {"client_credentials"}}, api.ID, api.Secret).Code)
+
+		w = asAdmin("PATCH", "/v1/oauth/client/"+spa.ID, `{"name":"spa2","redirect_uris":["https://app.test/cb"],
+			"grant_types":["authorization_code"],"scopes":["profile"]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"name":"spa2"`)
+
+		require.Equal(t, http.StatusOK, asAdmin("DELETE", "/v1/oauth/client/"+spa.ID, "").Code)
+		assert.Equal(t, http.StatusNotFound, asAdmin("GET", "/v1/oauth/client/"+spa.ID, "").Code)
+	})
+}
@@ -0,0 +1,204 @@
+package v1
+
+import (
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/oauth"
+	"dev/profileSaver/internal/oidc"
+	"dev/profileSaver/internal/tenant"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+	"strings"
+)
+
+// routeDiscovery is where OpenID Connect clients find the other endpoints.
+const routeDiscovery = "/.well-known/openid-configuration"
+
+// oidcDiscovery
+// @Summary OpenID Connect discovery
+// @Tags OAuth
+// @Description OpenID Provider metadata (OpenID Connect Discovery 1.0). Tenants have their own issuer below /t/{tenant}. Answers without the data envelope.
+// @Produce  json
+// @Success 200 {object} controller.DiscoveryResponse
+// @Router /.well-known/openid-configuration [GET]
+func (h *Handler) oidcDiscovery(w http.ResponseWriter, req bunrouter.Request) error {
+	issuer := h.issuer(req, tenant.FromContext(req.Context()))
+
+	return oauthJSON(w, http.StatusOK, controller.DiscoveryResponse{
+		Issuer:                 issuer,
+		AuthorizationEndpoint:  issuer + routeAuthorize,
+		TokenEndpoint:          issuer + "/oauth/token",
+		UserInfoEndpoint:       issuer + "/oauth/userinfo",
+		JWKSURI:                issuer + "/oauth/jwks",
+		IntrospectionEndpoint:  issuer + "/oauth/introspect",
+		RevocationEndpoint:     issuer + "/oauth/revoke",
+		ScopesSupported:        []string{oidc.ScopeOpenID, oidc.ScopeProfile, oidc.ScopeEmail},
+		ResponseTypesSupported: []string{"code"},
+		GrantTypesSupported: []string{
+			model.GrantAuthorizationCode,
+			model.GrantClientCredentials,
+			model.GrantRefreshToken,
+		},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  h.oidc.Algorithms(req.Context()),
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{oauth.MethodS256},
+		ClaimsSupported: []string{
+			"iss", "sub", "aud", "exp", "iat", "nonce", "at_hash",
+			"preferred_username", "tenant", "email", "email_verified",
+		},
+	})
+}
+
+// oidcKeys
+// @Summary OpenID Connect signing keys
+// @Tags OAuth
+// @Description The public keys ID tokens are signed with (RFC 7517): the current one and, after a rotation, the previous ones until their tokens expired.
+// @Produce  json
+// @Success 200 {object} oidc.JWKS
+// @Failure 500
+// @Router /oauth/jwks [GET]
+func (h *Handler) oidcKeys(w http.ResponseWriter, req bunrouter.Request) error {
+	keys, err := h.oidc.Keys(req.Context())
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	return bunrouter.JSON(w, keys)
+}
+
+// oidcUserInfo
+// @Summary OpenID Connect userinfo
+// @Tags OAuth
+// @Description Claims about the user an access token with the openid scope was issued for. profile adds preferred_username and tenant, email adds email and email_verified. Answers without the data envelope.
+// @Produce  json
+// @Param Authorization header string true "Bearer access token"
+// @Success 200 {object} oidc.Claims
+// @Failure 401
+// @Failure 403
+// @Router /oauth/userinfo [GET]
+// @Router /oauth/userinfo [POST]
+func (h *Handler) oidcUserInfo(w http.ResponseWriter, req bunrouter.Request) error {
+	token, ok := bearerToken(req)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="oauth"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return nil
+	}
+
+	t, active := h.oauth.Introspect(req.Context(), token)
+	if id, named := tenant.Lookup(req.Context()); named && id != t.Tenant {
+		active = false
+	}
+	if !active || t.Kind != model.OAuthAccess || t.UserID == "" {
+		return bearerError(w, http.StatusUnauthorized, "invalid_token")
+	}
+
+	if !hasScope(t.Scopes, oidc.ScopeOpenID) {
+		return bearerError(w, http.StatusForbidden, "insufficient_scope")
+	}
+
+	user, err := h.oauthSubject(req.Context(), t)
+	if err != nil {
+		return bearerError(w, http.StatusUnauthorized, "invalid_token")
+	}
+
+	return oauthJSON(w, http.StatusOK, oidc.UserInfo(user, t.Scopes))
+}
+
+// rotateSigningKey
+// @Summary Rotate OpenID Connect signing key
+// @Tags OAuth
+// @Description Sign ID tokens with a new key from now on, only for super-admins. The old key stays published until its tokens expired. Keys also rotate on their own after oidc.key_rotation.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Success 200 {object} oidc.JWK
+// @Failure 401
+// @Failure 500
+// @Router /v1/oidc/keys/rotate [POST]
+func (h *Handler) rotateSigningKey(w http.ResponseWriter, req bunrouter.Request) error {
+	k, err := h.oidc.Rotate(req.Context())
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	jwk, err := oidc.PublicJWK(k)
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	logger.FromContext(req.Context()).Info().Str("kid", k.ID).Msg("signing key rotated")
+
+	return h.responseJSON(w, req, http.StatusOK, jwk)
+}
+
+// idToken returns the ID token to issue with tokens, empty unless they were
+// issued to a user with the openid scope.
+func (h *Handler) idToken(req bunrouter.Request, c model.OAuthClient, tokens oauth.Tokens) (string, error) {
+	if tokens.Access.UserID == "" || !hasScope(tokens.Scopes, oidc.ScopeOpenID) {
+		return "", nil
+	}
+
+	user, err := h.repo.GetUserByID(req.Context(), tokens.Access.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	return h.oidc.IDToken(req.Context(), oidc.IDTokenRequest{
+		Issuer:      h.issuer(req, c.Tenant),
+		ClientID:    c.ID,
+		User:        user,
+		Scopes:      tokens.Scopes,
+		Nonce:       tokens.Nonce,
+		AccessToken: tokens.AccessToken,
+	})
+}
+
+// issuer returns the issuer of tenantID, derived from the request unless
+// oidc.issuer is set.
+func (h *Handler) issuer(req bunrouter.Request, tenantID string) string {
+	return h.oidc.Issuer(requestOrigin(req), tenantID)
+}
+
+// requestOrigin returns the scheme and host the request was sent to.
+func requestOrigin(req bunrouter.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	} else if proto := req.Header.Get("X-Forwarded-Proto"); proto == "https" {
+		scheme = proto
+	}
+
+	return scheme + "://" + req.Host
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+func bearerToken(req bunrouter.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+
+	return token, true
+}
+
+// bearerError answers with an RFC 6750 error.
+func bearerError(w http.ResponseWriter, code int, e string) error {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="oauth", error="`+e+`"`)
+
+	return oauthJSON(w, code, controller.OAuthErrorResponse{Error: e})
+}
@@ -0,0 +1,204 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/oauth"
+	"dev/profileSaver/internal/oidc"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func Test_oidc(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	require.NoError(t, s.repo.CreateTenant(ctx, model.Tenant{ID: "acme", Name: "Acme"}))
+	s.bob.Password = ""
+	s.bob.EmailVerified = true
+	require.NoError(t, s.repo.UpdateUser(ctx, s.bob))
+
+	s.route(
+		WithOAuth(oauth.NewServer(s.repo, config.OAuth{})),
+		WithOIDC(oidc.NewProvider(s.repo, config.OIDC{})),
+		WithTenants(s.repo),
+	)
+
+	get := func(target, bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+
+		return s.serve(req)
+	}
+
+	req := httptest.NewRequest("POST", "/v1/oauth/client", bytes.NewBufferString(`{"name":"wiki",
+		"redirect_uris":["https://wiki.test/cb"],"grant_types":["authorization_code","refresh_token"],
+		"scopes":["openid","profile","email","read"],"confidential":true}`))
+	req.SetBasicAuth("admin", "admin")
+	w := s.serve(req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var created struct {
+		Data controller.OAuthClientResponse `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	wiki := created.Data
+
+	// login runs the authorization code grant for bob with scope and returns
+	// the token response.
+	login := func(scope string) controller.OAuthTokenResponse {
+		authorize := url.Values{
+			"response_type": {"code"},
+			"client_id":     {wiki.ID},
+			"scope":         {scope},
+			"nonce":         {"n-123"},
+		}
+		req := httptest.NewRequest("POST", "/oauth/authorize?"+authorize.Encode(),
+			strings.NewReader(url.Values{"consent": {"allow"}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth("bob", "bob-password")
+		w := s.serve(req)
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+
+		req = httptest.NewRequest("POST", "/oauth/token", strings.NewReader(url.Values{
+			"grant_type":   {"authorization_code"},
+			"code":         {location.Query().Get("code")},
+			"redirect_uri": {"https://wiki.test/cb"},
+		}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.SetBasicAuth(wiki.ID, wiki.Secret)
+		w = s.serve(req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp controller.OAuthTokenResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+		return resp
+	}
+
+	jwks := func() oidc.JWKS {
+		w := get("/oauth/jwks", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var keys oidc.JWKS
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &keys))
+
+		return keys
+	}
+
+	t.Run("DISCOVERY", func(t *testing.T) {
+		w := get("/.well-known/openid-configuration", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var d controller.DiscoveryResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &d))
+		assert.Equal(t, "http://example.com", d.Issuer)
+		assert.Equal(t, "http://example.com/oauth/jwks", d.JWKSURI)
+		assert.Equal(t, "http://example.com/oauth/authorize", d.AuthorizationEndpoint)
+		assert.Equal(t, []string{model.AlgRS256}, d.IDTokenSigningAlgValuesSupported)
+
+		w = get("/t/acme/.well-known/openid-configuration", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &d))
+		assert.Equal(t, "http://example.com/t/acme", d.Issuer)
+		assert.Equal(t, "http://example.com/t/acme/oauth/token", d.TokenEndpoint)
+
+		w = get("/t/nope/.well-known/openid-configuration", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("ID_TOKEN", func(t *testing.T) {
+		resp := login("openid profile email")
+		require.NotEmpty(t, resp.IDToken)
+
+		var claims oidc.Claims
+		require.NoError(t, oidc.Verify(resp.IDToken, jwks().Keys, &claims))
+		assert.Equal(t, "http://example.com", claims.Issuer)
+		assert.Equal(t, s.bob.ID, claims.Subject)
+		assert.True(t, claims.Audience.Contains(wiki.ID))
+		assert.Equal(t, "n-123", claims.Nonce)
+		assert.Equal(t, "bob", claims.Username)
+		assert.Equal(t, "bob@example.com", claims.Email)
+		require.NotNil(t, claims.EmailVerified)
+		assert.True(t, *claims.EmailVerified)
+
+		assert.Empty(t, login("read").IDToken, "only with the openid scope")
+	})
+
+	t.Run("USERINFO", func(t *testing.T) {
+		resp := login("openid email")
+
+		w := get("/oauth/userinfo", resp.AccessToken)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var claims oidc.Claims
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &claims))
+		assert.Equal(t, s.bob.ID, claims.Subject)
+		assert.Equal(t, "bob@example.com", claims.Email)
+		assert.Empty(t, claims.Username, "no profile scope")
+
+		w = get("/oauth/userinfo", login("read").AccessToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "insufficient_scope")
+
+		w = get("/oauth/userinfo", "nope")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "invalid_token")
+
+		w = get("/oauth/userinfo", resp.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "refresh tokens don't work")
+
+		w = get("/t/acme/oauth/userinfo", resp.AccessToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "tokens stay in their tenant")
+
+		w = get("/oauth/userinfo", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		disabled := s.bob
+		disabled.Password = ""
+		disabled.Disabled = true
+		require.NoError(t, s.repo.UpdateUser(ctx, disabled))
+		w = get("/oauth/userinfo", resp.AccessToken)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "disabled users have no userinfo")
+
+		disabled.Disabled = false
+		require.NoError(t, s.repo.UpdateUser(ctx, disabled))
+	})
+
+	t.Run("KEY_ROTATION", func(t *testing.T) {
+		before := login("openid").IDToken
+
+		req := httptest.NewRequest("POST", "/v1/oidc/keys/rotate", nil)
+		req.SetBasicAuth("bob", "bob-password")
+		assert.Equal(t, http.StatusUnauthorized, s.serve(req).Code)
+
+		req = httptest.NewRequest("POST", "/v1/oidc/keys/rotate", nil)
+		req.SetBasicAuth("admin", "admin")
+		w := s.serve(req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var rotated struct {
+			Data oidc.JWK `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rotated))
+
+		keys := jwks().Keys
+		assert.Len(t, keys, 2)
+
+		var claims oidc.Claims
+		assert.NoError(t, oidc.Verify(before, keys, &claims), "tokens of the old key still verify")
+
+		after := login("openid").IDToken
+		assert.NoError(t, oidc.Verify(after, []oidc.JWK{rotated.Data}, &claims), "the new key signs")
+	})
+}
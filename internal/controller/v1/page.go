@@ -0,0 +1,58 @@
+package v1
+
+import (
+	"dev/profileSaver/internal/controller"
+	"errors"
+	"github.com/uptrace/bunrouter"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+var errInvalidPage = errors.New("limit must be between 1 and 200, offset not negative")
+
+// PageParams reads the limit and offset query parameters.
+func PageParams(req bunrouter.Request) (controller.Page, error) {
+	page := controller.Page{Limit: defaultPageLimit}
+
+	query := req.URL.Query()
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return controller.Page{}, errInvalidPage
+		}
+		page.Limit = limit
+	}
+
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return controller.Page{}, errInvalidPage
+		}
+		page.Offset = offset
+	}
+
+	return page, nil
+}
+
+// PageBounds sets the total of page and returns the part of a list of total
+// elements it covers.
+func PageBounds(page *controller.Page, total int) (int, int) {
+	page.Total = total
+
+	start := page.Offset
+	if start > total {
+		start = total
+	}
+
+	end := start + page.Limit
+	if end > total {
+		end = total
+	}
+
+	return start, end
+}
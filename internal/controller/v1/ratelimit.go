@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"dev/profileSaver/internal/config"
+	"github.com/uptrace/bunrouter"
+	"golang.org/x/time/rate"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// limiterIdleTTL is how long a client's bucket is kept after its last request.
+const limiterIdleTTL = 10 * time.Minute
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter keeps a token bucket per client IP. The limits are read from
+// the live config on every request, so a reload takes effect immediately.
+type rateLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*clientLimiter
+	current config.RateLimit
+	sweep   time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{clients: make(map[string]*clientLimiter)}
+}
+
+func (rl *rateLimiter) allow(key string, cfg config.RateLimit) bool {
+	if cfg.RequestsPerSecond <= 0 {
+		return true
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+
+	if cfg != rl.current {
+		rl.clients = make(map[string]*clientLimiter)
+		rl.current = cfg
+	}
+
+	if now.Sub(rl.sweep) > limiterIdleTTL {
+		for k, c := range rl.clients {
+			if now.Sub(c.lastSeen) > limiterIdleTTL {
+				delete(rl.clients, k)
+			}
+		}
+		rl.sweep = now
+	}
+
+	c, ok := rl.clients[key]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), cfg.Burst)}
+		rl.clients[key] = c
+	}
+	c.lastSeen = now
+
+	return c.limiter.AllowN(now, 1)
+}
+
+func (h *Handler) rateLimitMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		cfg := h.cfg.Get().RateLimit
+
+		if !h.limiter.allow(clientIP(req.Request), cfg) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(1/cfg.RequestsPerSecond))))
+			return h.responseJSON(w, req, http.StatusTooManyRequests, "too many requests")
+		}
+
+		return next(w, req)
+	}
+}
+
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,61 @@
+package v1
+
+import (
+	"dev/profileSaver/internal/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_rateLimiter(t *testing.T) {
+	rl := newRateLimiter()
+
+	assert.True(t, rl.allow("a", config.RateLimit{}))
+
+	limit := config.RateLimit{RequestsPerSecond: 0.001, Burst: 2}
+	assert.True(t, rl.allow("a", limit))
+	assert.True(t, rl.allow("a", limit))
+	assert.False(t, rl.allow("a", limit))
+	assert.True(t, rl.allow("b", limit))
+
+	// A reloaded limit starts every client with a fresh bucket.
+	reloaded := config.RateLimit{RequestsPerSecond: 0.001, Burst: 3}
+	assert.True(t, rl.allow("a", reloaded))
+}
+
+func Test_validatePassword(t *testing.T) {
+	policy := config.Password{
+		MinLength:     8,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "OK",
+			input:    "Passw0rd!",
+			expected: nil,
+		},
+		{
+			name:  "WEAK",
+			input: "pass",
+			expected: []string{
+				"password shorter than 8 characters",
+				"password without uppercase letter",
+				"password without digit",
+				"password without symbol",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, validatePassword(test.input, policy))
+		})
+	}
+}
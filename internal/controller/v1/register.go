@@ -0,0 +1,182 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/notify"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/tenant"
+	"dev/profileSaver/internal/token"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/uptrace/bunrouter"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultInviteTTL applies when no registration.invite_ttl is configured.
+const defaultInviteTTL = 7 * 24 * time.Hour
+
+// register
+// @Summary Register
+// @Tags Auth
+// @Description Create an account without authentication when registration.mode allows it. The account is never an admin, the admin field is ignored.
+// @Accept  json
+// @Produce  json
+// @Param input body controller.RegisterRequest true "user"
+// @Success 200
+// @Failure 400
+// @Failure 403
+// @Failure 404
+// @Failure 429
+// @Failure 500
+// @Router /v1/auth/register [POST]
+func (h *Handler) register(w http.ResponseWriter, req bunrouter.Request) error {
+	cfg := h.cfg.Get().Registration
+
+	if cfg.Mode == config.RegistrationDisabled || cfg.Mode == "" {
+		return h.responseJSON(w, req, http.StatusNotFound, "registration is disabled")
+	}
+
+	if !h.registerLimiter.allow(clientIP(req.Request), cfg.RateLimit) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(1/cfg.RateLimit.RequestsPerSecond))))
+		return h.responseJSON(w, req, http.StatusTooManyRequests, "too many requests")
+	}
+
+	var input controller.RegisterRequest
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+	input.Admin = false
+
+	if err := validate(input.UserRequest, h.cfg.Get().Password); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	switch cfg.Mode {
+	case config.RegistrationDomain:
+		if !domainAllowed(input.Email, cfg.Domains) {
+			return h.responseJSON(w, req, http.StatusForbidden, "email domain not allowed")
+		}
+	case config.RegistrationInvite:
+		// A taken username is reported before the invite is spent.
+		if _, err := h.repo.GetUserByName(req.Context(), input.Username); err == nil {
+			return h.responseJSON(w, req, http.StatusBadRequest, repository.ErrUserNameExists.Error())
+		}
+
+		t, err := h.tokens.Consume(req.Context(), token.PurposeInvite, input.InviteCode)
+		if errors.Is(err, token.ErrInvalid) || err == nil && !inviteMatches(req.Context(), t, input.Email) {
+			return h.responseJSON(w, req, http.StatusForbidden, "invalid invite code")
+		}
+		if err != nil {
+			return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	user := model.User{
+		Email:    input.Email,
+		Username: input.Username,
+		Password: input.Password,
+		Admin:    false,
+		Tenant:   tenant.FromContext(req.Context()),
+	}
+
+	user, err := h.repo.CreateUser(req.Context(), user)
+	if errors.Is(err, repository.ErrUserNameExists) {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	logger.WithUser(req.Context(), user.Username)
+	h.sendVerificationTo(req.Context(), user.Username)
+
+	return h.responseJSON(w, req, http.StatusOK, "user was registered")
+}
+
+// createInvite
+// @Summary Create invite
+// @Tags User
+// @Description Issue a single-use invite code for registration.mode invite. With an email the code only works for that address and is sent to it.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param input body controller.InviteRequest false "invite"
+// @Success 200 {object} controller.InviteResponse
+// @Failure 400
+// @Failure 500
+// @Router /v1/invite [POST]
+func (h *Handler) createInvite(w http.ResponseWriter, req bunrouter.Request) error {
+	var input controller.InviteRequest
+	if req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+			return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+		}
+	}
+
+	ttl := h.cfg.Get().Registration.InviteTTL
+	if ttl <= 0 {
+		ttl = defaultInviteTTL
+	}
+
+	code, err := h.tokens.Issue(req.Context(), token.PurposeInvite, "", input.Email, ttl)
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	resp := controller.InviteResponse{
+		Code:      code,
+		Email:     input.Email,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	if input.Email != "" {
+		err = h.notifier.Send(req.Context(), notify.Message{
+			To:      input.Email,
+			Subject: "Invitation",
+			Body: fmt.Sprintf("Hello,\n\n"+
+				"you are invited to create an account. Register within %s with the invite code:\n\n"+
+				"%s\n",
+				ttl, code),
+		})
+		if err != nil {
+			return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, resp)
+}
+
+// inviteMatches reports whether invite t works in the tenant of ctx and, when
+// it was issued for an email, for email.
+func inviteMatches(ctx context.Context, t model.Token, email string) bool {
+	if t.Tenant != tenant.FromContext(ctx) {
+		return false
+	}
+
+	return t.Email == "" || strings.EqualFold(t.Email, email)
+}
+
+func domainAllowed(email string, domains []string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+
+	domain := email[at+1:]
+	for _, allowed := range domains {
+		if strings.EqualFold(domain, strings.TrimPrefix(allowed, "@")) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,145 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/config"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_register(t *testing.T) {
+	ctx := context.Background()
+
+	const erin = `{"username":"erin","email":"erin@example.com","password":"erin-password","admin":true`
+
+	tests := []struct {
+		name                 string
+		registration         config.Registration
+		body                 string
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:               "DISABLED",
+			registration:       config.Registration{Mode: config.RegistrationDisabled},
+			body:               erin + `}`,
+			expectedStatusCode: 404,
+			expectedResponseBody: `{"error":"registration is disabled"}
+`,
+		},
+		{
+			name:               "OPEN",
+			registration:       config.Registration{Mode: config.RegistrationOpen},
+			body:               erin + `}`,
+			expectedStatusCode: 200,
+			expectedResponseBody: `{"data":"user was registered"}
+`,
+		},
+		{
+			name:               "INVALID",
+			registration:       config.Registration{Mode: config.RegistrationOpen},
+			body:               `{"username":"erin"}`,
+			expectedStatusCode: 400,
+			expectedResponseBody: `{"error":"empty password, empty email"}
+`,
+		},
+		{
+			name:               "DOMAIN_ALLOWED",
+			registration:       config.Registration{Mode: config.RegistrationDomain, Domains: []string{"Example.com"}},
+			body:               erin + `}`,
+			expectedStatusCode: 200,
+			expectedResponseBody: `{"data":"user was registered"}
+`,
+		},
+		{
+			name:               "DOMAIN_REFUSED",
+			registration:       config.Registration{Mode: config.RegistrationDomain, Domains: []string{"example.org"}},
+			body:               erin + `}`,
+			expectedStatusCode: 403,
+			expectedResponseBody: `{"error":"email domain not allowed"}
+`,
+		},
+		{
+			name:               "INVITE_MISSING",
+			registration:       config.Registration{Mode: config.RegistrationInvite},
+			body:               erin + `}`,
+			expectedStatusCode: 403,
+			expectedResponseBody: `{"error":"invalid invite code"}
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer(t)
+			s.route(WithConfig(config.NewLive(config.Config{Registration: test.registration})))
+
+			w := s.do(testRequest{method: "POST", target: "/v1/auth/register", body: test.body})
+
+			assert.Equal(t, test.expectedStatusCode, w.Code)
+			assert.Equal(t, test.expectedResponseBody, w.Body.String())
+
+			if w.Code == http.StatusOK {
+				u, err := s.repo.GetUserByName(ctx, "erin")
+				require.NoError(t, err)
+				assert.False(t, u.Admin, "registered users are never admins")
+			}
+		})
+	}
+}
+
+func Test_registerInvite(t *testing.T) {
+	s := newTestServer(t)
+
+	mail := &outbox{}
+	live := config.NewLive(config.Config{Registration: config.Registration{
+		Mode:      config.RegistrationInvite,
+		RateLimit: config.RateLimit{RequestsPerSecond: 0.001, Burst: 3},
+	}})
+	s.route(WithConfig(live), WithNotifier(mail))
+
+	do := func(target, body string, admin bool) *httptest.ResponseRecorder {
+		if admin {
+			return s.do(testRequest{method: "POST", target: target, body: body, username: "admin", password: "admin"})
+		}
+
+		return s.do(testRequest{method: "POST", target: target, body: body})
+	}
+
+	invite := func(body string) string {
+		w := do("/v1/invite", body, true)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp struct {
+			Data struct {
+				Code string `json:"code"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+		return resp.Data.Code
+	}
+
+	open := invite("")
+	bound := invite(`{"email":"carol@example.com"}`)
+	require.Len(t, mail.take(), 1, "an invite with an email is sent to it")
+
+	w := do("/v1/auth/register", `{"username":"erin","email":"erin@example.com","password":"erin-password","invite_code":"`+bound+`"}`, false)
+	assert.Equal(t, http.StatusForbidden, w.Code, "bound invites only work for their email")
+
+	w = do("/v1/auth/register", `{"username":"erin","email":"erin@example.com","password":"erin-password","invite_code":"`+open+`"}`, false)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = do("/v1/auth/register", `{"username":"dave","email":"dave@example.com","password":"dave-password","invite_code":"`+open+`"}`, false)
+	assert.Equal(t, http.StatusForbidden, w.Code, "invites are single-use")
+
+	w = do("/v1/auth/register", `{"username":"carol","email":"carol@example.com","password":"carol-password","invite_code":"`+bound+`"}`, false)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "registration has its own rate limit")
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusUnauthorized, do("/v1/invite", "", false).Code)
+}
@@ -0,0 +1,169 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/notify"
+	"dev/profileSaver/internal/tenant"
+	"dev/profileSaver/internal/token"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultResetTTL applies when no reset.ttl is configured.
+const defaultResetTTL = time.Hour
+
+// forgotPassword
+// @Summary Request a password reset
+// @Tags Auth
+// @Description Send a single-use password reset token to every account with the given email. The response is the same, and as fast, whether an account matched or not.
+// @Accept  json
+// @Produce  json
+// @Param input body controller.ForgotPasswordRequest true "email"
+// @Success 200
+// @Failure 400
+// @Router /v1/auth/forgot [POST]
+func (h *Handler) forgotPassword(w http.ResponseWriter, req bunrouter.Request) error {
+	var input controller.ForgotPasswordRequest
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	email := strings.TrimSpace(input.Email)
+	if email == "" {
+		return h.responseJSON(w, req, http.StatusBadRequest, "empty email")
+	}
+
+	// Tokens are sent after answering, so the answer takes as long whether
+	// the email belongs to an account or not. The request context ends with
+	// the answer, only its tenant and logger are kept.
+	ctx := logger.FromContext(req.Context()).WithContext(tenant.WithID(context.Background(), tenant.FromContext(req.Context())))
+	go h.sendResetTokens(ctx, email)
+
+	return h.responseJSON(w, req, http.StatusOK, "if the email belongs to an account, a reset token was sent")
+}
+
+// sendResetTokens sends a reset token to every account with email, logging
+// the ones that fail.
+func (h *Handler) sendResetTokens(ctx context.Context, email string) {
+	for _, u := range h.repo.GetAllUsers(ctx) {
+		if !strings.EqualFold(u.Email, email) {
+			continue
+		}
+
+		if err := h.sendResetToken(ctx, u); err != nil {
+			logger.FromContext(ctx).Error().Err(err).
+				Str("user", u.Username).
+				Msg("unable to send password reset token")
+		}
+	}
+}
+
+// resetPassword
+// @Summary Reset password
+// @Tags Auth
+// @Description Set a new password with a token from /v1/auth/forgot. The token works once, all sessions of the user are revoked.
+// @Accept  json
+// @Produce  json
+// @Param input body controller.ResetPasswordRequest true "token and password"
+// @Success 200
+// @Failure 400
+// @Failure 500
+// @Router /v1/auth/reset [POST]
+func (h *Handler) resetPassword(w http.ResponseWriter, req bunrouter.Request) error {
+	var input controller.ResetPasswordRequest
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	// The password is checked first so a rejected one doesn't spend the token.
+	reason := validatePassword(input.NewPassword, h.cfg.Get().Password)
+	if input.NewPassword == "" {
+		reason = append(reason, "empty password")
+	}
+	if len(reason) != 0 {
+		return h.responseJSON(w, req, http.StatusBadRequest, strings.Join(reason, ", "))
+	}
+
+	t, err := h.tokens.Consume(req.Context(), token.PurposeReset, input.Token)
+	if errors.Is(err, token.ErrInvalid) {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	user, err := h.repo.GetUserByID(req.Context(), t.UserID)
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, token.ErrInvalid.Error())
+	}
+
+	logger.WithUser(req.Context(), user.Username)
+
+	user.Password = input.NewPassword
+	user.MustChangePassword = false
+
+	if err = h.repo.UpdateUser(req.Context(), user); err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	if err = h.tokens.Revoke(req.Context(), token.PurposeReset, user.ID); err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+	if err = h.sessions.RevokeAll(req.Context(), user.ID, ""); err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, "password was reset")
+}
+
+func (h *Handler) sendResetToken(ctx context.Context, u model.User) error {
+	cfg := h.cfg.Get().PasswordReset
+
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = defaultResetTTL
+	}
+
+	t, err := h.tokens.Issue(ctx, token.PurposeReset, u.ID, u.Email, ttl)
+	if err != nil {
+		return err
+	}
+
+	return h.notifier.Send(ctx, notify.Message{
+		To:      u.Email,
+		Subject: "Password reset",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"someone asked to reset the password of your account. Use this within %s to set a new one:\n\n"+
+			"%s\n\n"+
+			"If it wasn't you, ignore this message, your password stays unchanged.\n",
+			u.Username, ttl, tokenLink(cfg.URL, t)),
+	})
+}
+
+// tokenLink appends t to base as the token query parameter, or returns t
+// alone when base is empty.
+func tokenLink(base, t string) string {
+	if base == "" {
+		return t
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return t
+	}
+
+	q := u.Query()
+	q.Set("token", t)
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
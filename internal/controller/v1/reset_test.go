@@ -0,0 +1,145 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+// outbox is a Notifier keeping the messages it is sent. While hold is set,
+// sends wait until it is closed.
+type outbox struct {
+	mu       sync.Mutex
+	hold     chan struct{}
+	messages []notify.Message
+}
+
+func (o *outbox) Send(_ context.Context, msg notify.Message) error {
+	o.mu.Lock()
+	hold := o.hold
+	o.mu.Unlock()
+
+	if hold != nil {
+		<-hold
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.messages = append(o.messages, msg)
+
+	return nil
+}
+
+func (o *outbox) take() []notify.Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	messages := o.messages
+	o.messages = nil
+
+	return messages
+}
+
+// wait takes the messages once there are any.
+func (o *outbox) wait(t *testing.T) []notify.Message {
+	var messages []notify.Message
+	require.Eventually(t, func() bool {
+		messages = o.take()
+		return len(messages) != 0
+	}, time.Second, time.Millisecond, "no message was sent")
+
+	return messages
+}
+
+var resetLink = regexp.MustCompile(`https://app\.example\.com/reset\?token=\S+`)
+
+func Test_resetPassword(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+	s.bob.Password = ""
+	s.bob.Email = "Bob@example.com"
+	require.NoError(t, s.repo.UpdateUser(ctx, s.bob))
+
+	mail := &outbox{}
+	live := config.NewLive(config.Config{
+		Password:      config.Password{MinLength: 8},
+		PasswordReset: config.PasswordReset{URL: "https://app.example.com/reset"},
+	})
+	s.route(WithConfig(live), WithNotifier(mail))
+
+	do := func(target, body string) *httptest.ResponseRecorder {
+		return s.do(testRequest{method: "POST", target: target, body: body})
+	}
+
+	forgot := func(email string) string {
+		w := do("/v1/auth/forgot", `{"email":"`+email+`"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		messages := mail.wait(t)
+		require.Len(t, messages, 1)
+		assert.Equal(t, "Bob@example.com", messages[0].To)
+
+		link, err := url.Parse(resetLink.FindString(messages[0].Body))
+		require.NoError(t, err)
+
+		return link.Query().Get("token")
+	}
+
+	t.Run("UNKNOWN_EMAIL", func(t *testing.T) {
+		w := do("/v1/auth/forgot", `{"email":"nobody@example.com"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.NotEmpty(t, forgot("bob@example.com"), "only bob is sent a token")
+	})
+
+	t.Run("BACKGROUND", func(t *testing.T) {
+		hold := make(chan struct{})
+		mail.mu.Lock()
+		mail.hold = hold
+		mail.mu.Unlock()
+		defer func() {
+			mail.mu.Lock()
+			mail.hold = nil
+			mail.mu.Unlock()
+		}()
+
+		w := do("/v1/auth/forgot", `{"email":"bob@example.com"}`)
+		assert.Equal(t, http.StatusOK, w.Code, "the answer doesn't wait for the message")
+		assert.Empty(t, mail.take())
+
+		close(hold)
+		assert.Len(t, mail.wait(t), 1)
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		old := forgot("bob@example.com")
+		tok := forgot("bob@example.com")
+		require.NotEmpty(t, tok)
+
+		w := do("/v1/auth/reset", `{"token":"`+tok+`","new_password":"short"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, `{"error":"password shorter than 8 characters"}
+`, w.Body.String())
+
+		w = do("/v1/auth/reset", `{"token":"`+tok+`","new_password":"n3w-password"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.True(t, s.repo.IsAuthorized(ctx, "bob", "n3w-password"))
+
+		w = do("/v1/auth/reset", `{"token":"`+tok+`","new_password":"0ther-password"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "tokens are single-use")
+		assert.Equal(t, `{"error":"invalid or expired token"}
+`, w.Body.String())
+
+		w = do("/v1/auth/reset", `{"token":"`+old+`","new_password":"0ther-password"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "a reset revokes the other tokens")
+	})
+}
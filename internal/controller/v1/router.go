@@ -1,104 +1,393 @@
 package v1
 
 import (
+	"context"
+	"dev/profileSaver/internal/apikey"
+	"dev/profileSaver/internal/authn"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/federation"
+	"dev/profileSaver/internal/idempotency"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/notify"
+	"dev/profileSaver/internal/oauth"
+	"dev/profileSaver/internal/oidc"
 	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/scim"
+	"dev/profileSaver/internal/session"
+	"dev/profileSaver/internal/tenant"
+	"dev/profileSaver/internal/token"
+	"errors"
+	"github.com/graphql-go/graphql"
 	"github.com/rs/zerolog/log"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"github.com/uptrace/bunrouter"
-	"github.com/uptrace/bunrouter/extra/reqlog"
 	"net/http"
 )
 
 type Handler struct {
-	repo repository.Repository
+	repo            repository.Repository
+	cfg             *config.Live
+	limiter         *rateLimiter
+	registerLimiter *rateLimiter
+	idempotency     *idempotency.Manager
+	sessions        *session.Manager
+	totp            repository.TOTPRepository
+	tokens          *token.Manager
+	notifier        notify.Notifier
+	tenants         repository.TenantRepository
+	groups          repository.GroupRepository
+	apiKeys         *apikey.Manager
+	oauth           *oauth.Server
+	oidc            *oidc.Provider
+	federation      *federation.Manager
+	authn           authn.Authenticator
+	schema          graphql.Schema
 }
 
-func New(repo repository.Repository) *Handler {
-	return &Handler{repo: repo}
+type Option func(h *Handler)
+
+// WithConfig makes the handler follow the CORS, rate limit, password policy
+// and GraphQL limit settings of live. Without it all of them are disabled.
+func WithConfig(live *config.Live) Option {
+	return func(h *Handler) {
+		h.cfg = live
+	}
+}
+
+// WithSessions sets the manager for login sessions. Without it sessions are
+// kept in memory with default settings.
+func WithSessions(m *session.Manager) Option {
+	return func(h *Handler) {
+		h.sessions = m
+	}
+}
+
+// WithTOTP sets where two-factor secrets and recovery codes are kept. Without
+// it they are kept in memory.
+func WithTOTP(repo repository.TOTPRepository) Option {
+	return func(h *Handler) {
+		h.totp = repo
+	}
+}
+
+// WithTokens sets the manager for tokens sent to users. Without it they are
+// kept in memory.
+func WithTokens(m *token.Manager) Option {
+	return func(h *Handler) {
+		h.tokens = m
+	}
+}
+
+// WithNotifier sets how tokens reach users. Without it they are logged.
+func WithNotifier(n notify.Notifier) Option {
+	return func(h *Handler) {
+		h.notifier = n
+	}
+}
+
+// WithTenants sets where tenants are kept. Without it they are kept in memory.
+func WithTenants(repo repository.TenantRepository) Option {
+	return func(h *Handler) {
+		h.tenants = repo
+	}
+}
+
+// WithGroups sets where groups and their members are kept. Without it they are
+// kept in memory.
+func WithGroups(repo repository.GroupRepository) Option {
+	return func(h *Handler) {
+		h.groups = repo
+	}
+}
+
+// WithAPIKeys sets the manager for API keys. Without it they are kept in
+// memory.
+func WithAPIKeys(m *apikey.Manager) Option {
+	return func(h *Handler) {
+		h.apiKeys = m
+	}
+}
+
+// WithOAuth sets the OAuth2 authorization server. Without it clients and
+// tokens are kept in memory with default lifetimes.
+func WithOAuth(s *oauth.Server) Option {
+	return func(h *Handler) {
+		h.oauth = s
+	}
+}
+
+// WithOIDC sets the OpenID Connect provider. Without it signing keys are kept
+// in memory.
+func WithOIDC(p *oidc.Provider) Option {
+	return func(h *Handler) {
+		h.oidc = p
+	}
+}
+
+// WithFederation sets the manager for logins through upstream identity
+// providers. Without it there are no connectors.
+func WithFederation(m *federation.Manager) Option {
+	return func(h *Handler) {
+		h.federation = m
+	}
+}
+
+// WithAuthenticator sets how the passwords of logins are checked. Without it
+// they are checked against the local users.
+func WithAuthenticator(a authn.Authenticator) Option {
+	return func(h *Handler) {
+		h.authn = a
+	}
+}
+
+// WithIdempotency sets the manager for the answers to requests with an
+// Idempotency-Key. Without it they are kept in memory for a day.
+func WithIdempotency(m *idempotency.Manager) Option {
+	return func(h *Handler) {
+		h.idempotency = m
+	}
+}
+
+// Idempotency returns the middleware replaying the answers to requests with
+// an Idempotency-Key of the authenticated user, see idempotency.Middleware.
+func (h *Handler) Idempotency(fail func(w http.ResponseWriter, req bunrouter.Request, err error) error) bunrouter.MiddlewareFunc {
+	return h.idempotency.Middleware(func(ctx context.Context) (string, string) {
+		return tenant.FromContext(ctx), authenticatedUser(ctx).ID
+	}, fail)
+}
+
+// idempotent is Idempotency answering errors like the other v1 routes.
+func (h *Handler) idempotent(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return h.Idempotency(func(w http.ResponseWriter, req bunrouter.Request, err error) error {
+		return h.responseJSON(w, req, ErrorStatus(err), err.Error())
+	})(next)
+}
+
+func New(repo repository.Repository, opts ...Option) *Handler {
+	h := &Handler{
+		repo:            repo,
+		cfg:             config.NewLive(config.Config{}),
+		limiter:         newRateLimiter(),
+		registerLimiter: newRateLimiter(),
+		idempotency:     idempotency.NewManager(idempotency.NewMemoryStore(), config.Idempotency{}),
+		sessions:        session.NewManager(session.NewMemoryStore(), config.Session{CookieSecure: true}),
+		totp:            repository.New(),
+		tokens:          token.NewManager(repository.New()),
+		notifier:        notify.NewLog(),
+		tenants:         repository.New(),
+		groups:          repository.New(),
+		apiKeys:         apikey.NewManager(repository.New()),
+		oauth:           oauth.NewServer(repository.New(), config.OAuth{}),
+		oidc:            oidc.NewProvider(repository.New(), config.OIDC{}),
+		federation:      federation.NewManager(repository.New(), nil, nil),
+		authn:           authn.NewLocal(repo),
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	// The schema is fixed, failing to build it is a bug.
+	schema, err := h.graphqlSchema()
+	if err != nil {
+		panic(err)
+	}
+	h.schema = schema
+
+	return h
 }
 
-func (h *Handler) InitRouter() *bunrouter.Router {
+// Mount registers the routes of another API version. They are served behind
+// the middleware of the authenticated v1 routes, auth being their group.
+type Mount func(auth *bunrouter.Group)
+
+// InitRouter returns the API, with the routes of mounts next to v1. Every
+// route is also served below /t/{tenant}, acting on that tenant.
+func (h *Handler) InitRouter(mounts ...Mount) http.Handler {
 	router := bunrouter.New(
-		bunrouter.Use(reqlog.NewMiddleware()),
-		bunrouter.Use(h.authMiddleware),
+		bunrouter.Use(tracingMiddleware),
+		bunrouter.Use(logger.Middleware(log.Logger)),
+		bunrouter.Use(corsMiddleware(h.cfg)),
+		bunrouter.Use(h.rateLimitMiddleware),
+		bunrouter.Use(h.tenantMiddleware),
 	)
 
+	router.WithGroup("/v1/auth", func(g *bunrouter.Group) {
+		g.POST("/login", h.login)
+		g.POST("/forgot", h.forgotPassword)
+		g.POST("/reset", h.resetPassword)
+		g.POST("/verify", h.confirmEmail)
+		g.POST("/register", h.register)
+
+		g.GET("/connectors", h.getConnectors)
+		g.GET("/connectors/:id/login", h.connectorLogin)
+		g.GET("/connectors/:id/callback", h.connectorCallback)
+	})
+
+	// The client authenticates itself at these, not a user.
+	router.WithGroup("/oauth", func(g *bunrouter.Group) {
+		g.POST("/token", h.oauthToken)
+		g.POST("/introspect", h.oauthIntrospect)
+		g.POST("/revoke", h.oauthRevoke)
+
+		// These take the access token of a user or nothing.
+		g.GET("/userinfo", h.oidcUserInfo)
+		g.POST("/userinfo", h.oidcUserInfo)
+		g.GET("/jwks", h.oidcKeys)
+	})
+
+	router.GET(routeDiscovery, h.oidcDiscovery)
+
+	// Clients discover the SCIM API before authenticating.
+	router.WithGroup(scimPath, func(g *bunrouter.Group) {
+		g.GET("/ServiceProviderConfig", h.scimServiceProviderConfig)
+		g.GET("/ResourceTypes", h.scimResourceTypes)
+		g.GET("/ResourceTypes/:id", h.scimResourceType)
+		g.GET("/Schemas", h.scimSchemas)
+		g.GET("/Schemas/:id", h.scimSchema)
+	})
+
+	auth := router.Use(h.authMiddleware)
+
 	swagHandler := httpSwagger.Handler(
 		httpSwagger.URL("/swagger/doc.json"),
 	)
 	bswag := bunrouter.HTTPHandlerFunc(swagHandler)
-	router.GET("/swagger/:*", bswag)
+	auth.GET("/swagger/:*", bswag)
+
+	auth.GET(routeAuthorize, h.oauthAuthorize)
+	auth.POST(routeAuthorize, h.oauthAuthorize)
+
+	auth.GET(routeGraphQL, h.graphql)
+	auth.POST(routeGraphQL, h.graphql)
+
+	auth.WithGroup("/v1", func(g *bunrouter.Group) {
+		g.POST("/auth/logout", h.logout)
+
+		g.WithGroup("/me", func(g *bunrouter.Group) {
+			g.PUT("/password", h.changePassword)
+			g.POST("/email/verify", h.resendVerification)
+			g.GET("/sessions", h.getSessions)
+			g.DELETE("/sessions", h.deleteSessions)
+			g.DELETE("/sessions/:id", h.deleteSession)
+
+			g.GET("/2fa", h.getTwoFactor)
+			g.POST("/2fa/enroll", h.enrollTwoFactor)
+			g.POST("/2fa/confirm", h.confirmTwoFactor)
+			g.POST("/2fa/recovery-codes", h.regenerateRecoveryCodes)
+			g.DELETE("/2fa", h.disableTwoFactor)
+
+			g.GET("/groups", h.getOwnGroups)
+
+			g.GET("/api-keys", h.getOwnAPIKeys)
+			g.POST("/api-keys", h.createOwnAPIKey)
+			g.DELETE("/api-keys/:id", h.deleteOwnAPIKey)
+
+			g.GET("/consents", h.getConsents)
+			g.DELETE("/consents/:client_id", h.deleteConsent)
+
+			g.GET("/identities", h.getOwnIdentities)
+			g.POST("/identities/:connector", h.linkOwnIdentity)
+			g.DELETE("/identities/:connector", h.unlinkOwnIdentity)
+		})
+
+		userWrite := h.permissionMiddleware(model.PermissionUserWrite)
+		groupWrite := h.permissionMiddleware(model.PermissionGroupWrite)
+
+		g.WithMiddleware(h.permissionMiddleware(model.PermissionUserInvite)).POST("/invite", h.createInvite)
 
-	router.WithGroup("/v1", func(g *bunrouter.Group) {
 		g.WithGroup("/user", func(g *bunrouter.Group) {
-			g.WithMiddleware(h.isAdminMiddleware).POST("", h.createUser)
-			g.WithMiddleware(h.isAdminMiddleware).PATCH("/:id", h.updateUser)
-			g.WithMiddleware(h.isAdminMiddleware).DELETE("/:id", h.deleteUser)
+			// Only creation takes an Idempotency-Key: the answers are stored,
+			// and those of other routes carry secrets shown just once.
+			g.WithMiddleware(userWrite).WithMiddleware(h.idempotent).POST("", h.createUser)
+			g.WithMiddleware(userWrite).PATCH("/:id", h.updateUser)
+			g.WithMiddleware(userWrite).DELETE("/:id", h.deleteUser)
+			g.WithMiddleware(userWrite).DELETE("/:id/sessions", h.deleteUserSessions)
+			g.WithMiddleware(userWrite).DELETE("/:id/2fa", h.resetTwoFactor)
 			g.GET("", h.getAllUsers)
 			g.GET("/:id", h.getUser)
+			g.GET("/:id/groups", h.getUserGroups)
+			g.WithMiddleware(userWrite).GET("/:id/api-keys", h.getUserAPIKeys)
+			g.WithMiddleware(userWrite).POST("/:id/api-keys", h.createUserAPIKey)
+			g.WithMiddleware(userWrite).DELETE("/:id/api-keys/:key_id", h.deleteUserAPIKey)
+			g.WithMiddleware(userWrite).GET("/:id/identities", h.getUserIdentities)
+			g.WithMiddleware(userWrite).DELETE("/:id/identities/:connector", h.unlinkUserIdentity)
 		})
-	})
 
-	return router
-}
+		g.WithMiddleware(userWrite).POST("/service-account", h.createServiceAccount)
 
-func (h *Handler) authMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
-	return func(w http.ResponseWriter, req bunrouter.Request) error {
-		username, password, ok := req.BasicAuth()
-		if !ok {
-			askPassword(w)
-		}
+		g.WithGroup("/group", func(g *bunrouter.Group) {
+			g.GET("", h.getGroups)
+			g.WithMiddleware(groupWrite).POST("", h.createGroup)
+			g.GET("/:id", h.getGroup)
+			g.WithMiddleware(groupWrite).PATCH("/:id", h.updateGroup)
+			g.WithMiddleware(groupWrite).DELETE("/:id", h.deleteGroup)
+			g.GET("/:id/members", h.getGroupMembers)
+			g.WithMiddleware(groupWrite).PUT("/:id/members/:user_id", h.addGroupMember)
+			g.WithMiddleware(groupWrite).DELETE("/:id/members/:user_id", h.removeGroupMember)
+		})
 
-		if !h.repo.IsAuthorized(username, password) {
-			askPassword(w)
-			return nil
-		}
+		g.WithGroup("/oauth/client", func(g *bunrouter.Group) {
+			g = g.WithMiddleware(h.permissionMiddleware(model.PermissionAdmin))
 
-		w.Header().Set("Content-Type", "application/json")
-		return next(w, req)
-	}
-}
+			g.GET("", h.getOAuthClients)
+			g.POST("", h.createOAuthClient)
+			g.GET("/:id", h.getOAuthClient)
+			g.PATCH("/:id", h.updateOAuthClient)
+			g.DELETE("/:id", h.deleteOAuthClient)
+			g.POST("/:id/secret", h.rotateOAuthClientSecret)
+		})
 
-func (h *Handler) isAdminMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
-	return func(w http.ResponseWriter, req bunrouter.Request) error {
-		username, _, ok := req.BasicAuth()
-		if !ok {
-			askPassword(w)
-		}
+		g.WithMiddleware(h.isSuperAdminMiddleware).POST("/oidc/keys/rotate", h.rotateSigningKey)
 
-		user, err := h.repo.GetUserByName(username)
-		if err != nil {
-			internalError(w, err)
-			return nil
-		}
+		g.WithGroup("/tenant", func(g *bunrouter.Group) {
+			g.WithMiddleware(h.isSuperAdminMiddleware).GET("", h.getTenants)
+			g.WithMiddleware(h.isSuperAdminMiddleware).POST("", h.createTenant)
+			g.WithMiddleware(h.isSuperAdminMiddleware).GET("/:id", h.getTenant)
+			g.WithMiddleware(h.isSuperAdminMiddleware).PATCH("/:id", h.updateTenant)
+			g.WithMiddleware(h.isSuperAdminMiddleware).DELETE("/:id", h.deleteTenant)
+		})
+	})
 
-		if !user.Admin {
-			w.WriteHeader(http.StatusUnauthorized)
-			return nil
-		}
+	auth.WithGroup(scimPath, func(g *bunrouter.Group) {
+		g.WithGroup("/Users", func(g *bunrouter.Group) {
+			g = g.WithMiddleware(h.permissionMiddleware(model.PermissionUserWrite))
+			g.GET("", h.scimGetUsers)
+			g.POST("", h.scimCreateUser)
+			g.GET("/:id", h.scimGetUser)
+			g.PUT("/:id", h.scimReplaceUser)
+			g.PATCH("/:id", h.scimPatchUser)
+			g.DELETE("/:id", h.scimDeleteUser)
+		})
 
-		w.Header().Set("Content-Type", "application/json")
-		return next(w, req)
-	}
-}
+		g.WithGroup("/Groups", func(g *bunrouter.Group) {
+			g = g.WithMiddleware(h.permissionMiddleware(model.PermissionGroupWrite))
+			g.GET("", h.scimGetGroups)
+			g.POST("", h.scimCreateGroup)
+			g.GET("/:id", h.scimGetGroup)
+			g.PUT("/:id", h.scimReplaceGroup)
+			g.PATCH("/:id", h.scimPatchGroup)
+			g.DELETE("/:id", h.scimDeleteGroup)
+		})
+	})
 
-func askPassword(w http.ResponseWriter) {
-	w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
-	w.WriteHeader(http.StatusUnauthorized)
-}
+	for _, mount := range mounts {
+		mount(auth)
+	}
 
-func internalError(w http.ResponseWriter, err error) {
-	log.Error().Msgf("unable to get user from the store error - %s", err)
-	w.WriteHeader(http.StatusInternalServerError)
-	_, _ = w.Write([]byte("something went wrong"))
+	return tenantPath(router)
 }
 
 func (h *Handler) responseJSON(w http.ResponseWriter, req bunrouter.Request, code int, value interface{}) error {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 
-	if code != http.StatusOK {
-		log.Warn().Msgf("route: %s, http code: %d, error: %v", req.Route(), code, value)
+	if code >= http.StatusBadRequest {
+		logger.FromContext(req.Context()).Warn().
+			Int("status", code).
+			Interface("error", value).
+			Msg("request failed")
 		return bunrouter.JSON(w, bunrouter.H{
 			"error": value,
 		})
@@ -108,3 +397,40 @@ func (h *Handler) responseJSON(w http.ResponseWriter, req bunrouter.Request, cod
 		"data": value,
 	})
 }
+
+// ErrorStatus returns the HTTP status answering err, 500 for errors without
+// a meaning of their own.
+func ErrorStatus(err error) int {
+	var (
+		bad     *scim.BadRequestError
+		invalid *inputError
+	)
+
+	switch {
+	case errors.As(err, &bad), errors.As(err, &invalid), errors.Is(err, errInvalidPage),
+		errors.Is(err, idempotency.ErrInvalidKey), errors.Is(err, idempotency.ErrUnreadableBody):
+		return http.StatusBadRequest
+	case errors.Is(err, authn.ErrInvalidCredentials), errors.Is(err, errTwoFactorRequired),
+		errors.Is(err, errInvalidTwoFactor):
+		return http.StatusUnauthorized
+	case errors.Is(err, repository.ErrUserNotFound), errors.Is(err, repository.ErrGroupNotFound),
+		errors.Is(err, repository.ErrTenantNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrUserNameExists), errors.Is(err, repository.ErrGroupNameExists),
+		errors.Is(err, idempotency.ErrInProgress):
+		return http.StatusConflict
+	case errors.Is(err, idempotency.ErrMismatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, errUserDisabled), errors.Is(err, errOtherTenant), errors.Is(err, errTenantDisabled),
+		errors.Is(err, errDeniedToAPIKeys), errors.Is(err, errAPIKeyScopes), errors.Is(err, errMustChangePassword),
+		errors.Is(err, errTwoFactorEnrollment), errors.Is(err, errEmailNotVerified),
+		errors.Is(err, errManageAdmins), errors.Is(err, errNotGranted), errors.Is(err, errPermissionRequired):
+		return http.StatusForbidden
+	case errors.Is(err, idempotency.ErrBodyTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, errAuthnUnavailable):
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
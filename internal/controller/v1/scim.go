@@ -0,0 +1,833 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/bootstrap"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/scim"
+	"dev/profileSaver/internal/tenant"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// scimPath is where the SCIM 2.0 API is served.
+const scimPath = "/scim/v2"
+
+// errNotGranted refuses changes to groups granting permissions the caller
+// doesn't hold.
+var errNotGranted = errors.New("the group grants permissions that aren't yours to grant")
+
+// scimServiceProviderConfig
+// @Summary SCIM service provider configuration
+// @Tags SCIM
+// @Description The SCIM features available: PATCH and filters, no bulk operations, sorting or ETags.
+// @Produce  json
+// @Success 200 {object} scim.ServiceProviderConfig
+// @Router /scim/v2/ServiceProviderConfig [GET]
+func (h *Handler) scimServiceProviderConfig(w http.ResponseWriter, req bunrouter.Request) error {
+	return scimJSON(w, http.StatusOK, scim.NewServiceProviderConfig(scimBase(req), maxPageLimit))
+}
+
+// scimResourceTypes
+// @Summary SCIM resource types
+// @Tags SCIM
+// @Description The User and Group resource types.
+// @Produce  json
+// @Success 200 {object} scim.ListResponse
+// @Router /scim/v2/ResourceTypes [GET]
+func (h *Handler) scimResourceTypes(w http.ResponseWriter, req bunrouter.Request) error {
+	types := scim.ResourceTypes(scimBase(req))
+
+	resources := make([]interface{}, 0, len(types))
+	for _, t := range types {
+		resources = append(resources, t)
+	}
+
+	return scimJSON(w, http.StatusOK, scim.ListParams{StartIndex: 1}.List(len(resources), resources))
+}
+
+// scimResourceType
+// @Summary SCIM resource type
+// @Tags SCIM
+// @Description Get a resource type by id: User or Group.
+// @Produce  json
+// @Param id path string true "resource type id"
+// @Success 200 {object} scim.ResourceType
+// @Failure 404
+// @Router /scim/v2/ResourceTypes/{id} [GET]
+func (h *Handler) scimResourceType(w http.ResponseWriter, req bunrouter.Request) error {
+	id := req.Params().ByName("id")
+
+	for _, t := range scim.ResourceTypes(scimBase(req)) {
+		if t.ID == id {
+			return scimJSON(w, http.StatusOK, t)
+		}
+	}
+
+	return scimJSON(w, http.StatusNotFound, scim.NewError(http.StatusNotFound, "", "resource type not found"))
+}
+
+// scimSchemas
+// @Summary SCIM schemas
+// @Tags SCIM
+// @Description The attributes of users and groups that are kept.
+// @Produce  json
+// @Success 200 {object} scim.ListResponse
+// @Router /scim/v2/Schemas [GET]
+func (h *Handler) scimSchemas(w http.ResponseWriter, req bunrouter.Request) error {
+	schemas := scim.Schemas(scimBase(req))
+
+	resources := make([]interface{}, 0, len(schemas))
+	for _, s := range schemas {
+		resources = append(resources, s)
+	}
+
+	return scimJSON(w, http.StatusOK, scim.ListParams{StartIndex: 1}.List(len(resources), resources))
+}
+
+// scimSchema
+// @Summary SCIM schema
+// @Tags SCIM
+// @Description Get a schema by its URN.
+// @Produce  json
+// @Param id path string true "schema URN"
+// @Success 200 {object} scim.Schema
+// @Failure 404
+// @Router /scim/v2/Schemas/{id} [GET]
+func (h *Handler) scimSchema(w http.ResponseWriter, req bunrouter.Request) error {
+	id := req.Params().ByName("id")
+
+	for _, s := range scim.Schemas(scimBase(req)) {
+		if s.ID == id {
+			return scimJSON(w, http.StatusOK, s)
+		}
+	}
+
+	return scimJSON(w, http.StatusNotFound, scim.NewError(http.StatusNotFound, "", "schema not found"))
+}
+
+// scimGetUsers
+// @Summary List SCIM users
+// @Tags SCIM
+// @Description List the users of the tenant ordered by username, needs the user:write permission. Service accounts aren't listed.
+// @Produce  json
+// @Security BasicAuth
+// @Param filter query string false "SCIM filter, e.g. userName eq \"bob\""
+// @Param startIndex query int false "1-based index of the first user"
+// @Param count query int false "page size, at most 200"
+// @Param attributes query string false "comma-separated attributes to return"
+// @Param excludedAttributes query string false "comma-separated attributes not to return"
+// @Success 200 {object} scim.ListResponse
+// @Failure 400
+// @Failure 401
+// @Router /scim/v2/Users [GET]
+func (h *Handler) scimGetUsers(w http.ResponseWriter, req bunrouter.Request) error {
+	params, err := scim.ParseListParams(req.URL.Query(), maxPageLimit)
+	if err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	users := h.repo.GetAllUsers(req.Context())
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	base := scimBase(req)
+
+	var matched []map[string]interface{}
+	for _, u := range users {
+		if u.ServiceAccount {
+			continue
+		}
+
+		r, err := scim.ToMap(h.scimUser(req.Context(), u, base))
+		if err != nil {
+			return h.scimError(w, req, err)
+		}
+
+		if params.Filter == nil || params.Filter.Match(r) {
+			matched = append(matched, r)
+		}
+	}
+
+	return scimJSON(w, http.StatusOK, scimList(params, matched))
+}
+
+// scimCreateUser
+// @Summary Create SCIM user
+// @Tags SCIM
+// @Description Create a user, needs the user:write permission. The primary email counts as verified. Users created without a password get a random one.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param input body scim.User true "user"
+// @Success 201 {object} scim.User
+// @Failure 400
+// @Failure 401
+// @Failure 409
+// @Router /scim/v2/Users [POST]
+func (h *Handler) scimCreateUser(w http.ResponseWriter, req bunrouter.Request) error {
+	var r scim.User
+	if err := json.NewDecoder(req.Body).Decode(&r); err != nil {
+		return h.scimError(w, req, &scim.BadRequestError{Type: scim.TypeInvalidSyntax, Detail: err.Error()})
+	}
+
+	user, err := h.scimUserFields(model.User{Tenant: tenant.FromContext(req.Context())}, r)
+	if err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	// Nobody learns a generated password, the user sets one by a reset.
+	if user.Password == "" {
+		if user.Password, err = bootstrap.GeneratePassword(); err != nil {
+			return h.scimError(w, req, err)
+		}
+	}
+
+	created, err := h.repo.CreateUser(req.Context(), user)
+	if err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	resource := h.scimUser(req.Context(), created, scimBase(req))
+	w.Header().Set("Location", resource.Meta.Location)
+
+	return scimJSON(w, http.StatusCreated, resource)
+}
+
+// scimGetUser
+// @Summary Get SCIM user
+// @Tags SCIM
+// @Description Get a user by id, needs the user:write permission.
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "user id"
+// @Param attributes query string false "comma-separated attributes to return"
+// @Param excludedAttributes query string false "comma-separated attributes not to return"
+// @Success 200 {object} scim.User
+// @Failure 401
+// @Failure 404
+// @Router /scim/v2/Users/{id} [GET]
+func (h *Handler) scimGetUser(w http.ResponseWriter, req bunrouter.Request) error {
+	user, ok, err := h.loadSCIMUser(w, req)
+	if !ok {
+		return err
+	}
+
+	return h.scimResource(w, req, h.scimUser(req.Context(), user, scimBase(req)))
+}
+
+// scimReplaceUser
+// @Summary Replace SCIM user
+// @Tags SCIM
+// @Description Replace a user, needs the user:write permission and for admins the admin permission. Deactivating a user or setting their password signs them out everywhere.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "user id"
+// @Param input body scim.User true "user"
+// @Success 200 {object} scim.User
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Failure 409
+// @Router /scim/v2/Users/{id} [PUT]
+func (h *Handler) scimReplaceUser(w http.ResponseWriter, req bunrouter.Request) error {
+	old, ok, err := h.loadSCIMUser(w, req)
+	if !ok {
+		return err
+	}
+
+	var r scim.User
+	if err = json.NewDecoder(req.Body).Decode(&r); err != nil {
+		return h.scimError(w, req, &scim.BadRequestError{Type: scim.TypeInvalidSyntax, Detail: err.Error()})
+	}
+
+	return h.scimUpdateUser(w, req, old, r)
+}
+
+// scimPatchUser
+// @Summary Patch SCIM user
+// @Tags SCIM
+// @Description Add, replace or remove attributes of a user, needs the user:write permission and for admins the admin permission.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "user id"
+// @Param input body scim.PatchRequest true "operations"
+// @Success 200 {object} scim.User
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Failure 409
+// @Router /scim/v2/Users/{id} [PATCH]
+func (h *Handler) scimPatchUser(w http.ResponseWriter, req bunrouter.Request) error {
+	old, ok, err := h.loadSCIMUser(w, req)
+	if !ok {
+		return err
+	}
+
+	var patch scim.PatchRequest
+	if err = json.NewDecoder(req.Body).Decode(&patch); err != nil {
+		return h.scimError(w, req, &scim.BadRequestError{Type: scim.TypeInvalidSyntax, Detail: err.Error()})
+	}
+
+	resource, err := scim.ToMap(h.scimUser(req.Context(), old, scimBase(req)))
+	if err != nil {
+		return h.scimError(w, req, err)
+	}
+	if err = patch.Apply(resource); err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	var r scim.User
+	if err = scim.FromMap(resource, &r); err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	return h.scimUpdateUser(w, req, old, r)
+}
+
+// scimDeleteUser
+// @Summary Delete SCIM user
+// @Tags SCIM
+// @Description Delete a user, needs the user:write permission and for admins the admin permission.
+// @Security BasicAuth
+// @Param id path string true "user id"
+// @Success 204
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Router /scim/v2/Users/{id} [DELETE]
+func (h *Handler) scimDeleteUser(w http.ResponseWriter, req bunrouter.Request) error {
+	user, ok, err := h.loadSCIMUser(w, req)
+	if !ok {
+		return err
+	}
+
+	if !h.mayManage(req.Context(), authenticatedUser(req.Context()), user, false) {
+		return h.scimError(w, req, errManageAdmins)
+	}
+
+	if err = h.repo.DeleteUser(req.Context(), user.ID); err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// scimGetGroups
+// @Summary List SCIM groups
+// @Tags SCIM
+// @Description List the groups of the tenant ordered by name, needs the group:write permission.
+// @Produce  json
+// @Security BasicAuth
+// @Param filter query string false "SCIM filter, e.g. displayName eq \"staff\""
+// @Param startIndex query int false "1-based index of the first group"
+// @Param count query int false "page size, at most 200"
+// @Param attributes query string false "comma-separated attributes to return"
+// @Param excludedAttributes query string false "comma-separated attributes not to return, e.g. members"
+// @Success 200 {object} scim.ListResponse
+// @Failure 400
+// @Failure 401
+// @Router /scim/v2/Groups [GET]
+func (h *Handler) scimGetGroups(w http.ResponseWriter, req bunrouter.Request) error {
+	params, err := scim.ParseListParams(req.URL.Query(), maxPageLimit)
+	if err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	base := scimBase(req)
+
+	var matched []map[string]interface{}
+	for _, g := range h.groups.GetGroups(req.Context()) {
+		group, err := h.scimGroup(req.Context(), g, base)
+		if err != nil {
+			return h.scimError(w, req, err)
+		}
+
+		r, err := scim.ToMap(group)
+		if err != nil {
+			return h.scimError(w, req, err)
+		}
+
+		if params.Filter == nil || params.Filter.Match(r) {
+			matched = append(matched, r)
+		}
+	}
+
+	return scimJSON(w, http.StatusOK, scimList(params, matched))
+}
+
+// scimCreateGroup
+// @Summary Create SCIM group
+// @Tags SCIM
+// @Description Create a group of users, needs the group:write permission. The group grants no permissions.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param input body scim.Group true "group"
+// @Success 201 {object} scim.Group
+// @Failure 400
+// @Failure 401
+// @Failure 409
+// @Router /scim/v2/Groups [POST]
+func (h *Handler) scimCreateGroup(w http.ResponseWriter, req bunrouter.Request) error {
+	var r scim.Group
+	if err := json.NewDecoder(req.Body).Decode(&r); err != nil {
+		return h.scimError(w, req, &scim.BadRequestError{Type: scim.TypeInvalidSyntax, Detail: err.Error()})
+	}
+
+	members, err := h.scimMembers(req.Context(), r)
+	if err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	g := model.Group{
+		ID:         uuid.New().String(),
+		Tenant:     tenant.FromContext(req.Context()),
+		Name:       r.DisplayName,
+		ExternalID: r.ExternalID,
+		CreatedAt:  time.Now(),
+	}
+
+	if err = h.groups.CreateGroup(req.Context(), g); err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	if err = h.syncMembers(req.Context(), g.ID, members); err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	resource, err := h.scimGroup(req.Context(), g, scimBase(req))
+	if err != nil {
+		return h.scimError(w, req, err)
+	}
+	w.Header().Set("Location", resource.Meta.Location)
+
+	return scimJSON(w, http.StatusCreated, resource)
+}
+
+// scimGetGroup
+// @Summary Get SCIM group
+// @Tags SCIM
+// @Description Get a group with its members by id, needs the group:write permission.
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "group id"
+// @Param attributes query string false "comma-separated attributes to return"
+// @Param excludedAttributes query string false "comma-separated attributes not to return"
+// @Success 200 {object} scim.Group
+// @Failure 401
+// @Failure 404
+// @Router /scim/v2/Groups/{id} [GET]
+func (h *Handler) scimGetGroup(w http.ResponseWriter, req bunrouter.Request) error {
+	g, ok, err := h.loadSCIMGroup(w, req)
+	if !ok {
+		return err
+	}
+
+	resource, err := h.scimGroup(req.Context(), g, scimBase(req))
+	if err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	return h.scimResource(w, req, resource)
+}
+
+// scimReplaceGroup
+// @Summary Replace SCIM group
+// @Tags SCIM
+// @Description Replace the name and members of a group, needs the group:write permission and every permission the group grants. Description and permissions are kept.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "group id"
+// @Param input body scim.Group true "group"
+// @Success 200 {object} scim.Group
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Failure 409
+// @Router /scim/v2/Groups/{id} [PUT]
+func (h *Handler) scimReplaceGroup(w http.ResponseWriter, req bunrouter.Request) error {
+	g, ok, err := h.loadSCIMGroup(w, req)
+	if !ok {
+		return err
+	}
+
+	var r scim.Group
+	if err = json.NewDecoder(req.Body).Decode(&r); err != nil {
+		return h.scimError(w, req, &scim.BadRequestError{Type: scim.TypeInvalidSyntax, Detail: err.Error()})
+	}
+
+	return h.scimUpdateGroup(w, req, g, r)
+}
+
+// scimPatchGroup
+// @Summary Patch SCIM group
+// @Tags SCIM
+// @Description Add, replace or remove the name or members of a group, needs the group:write permission and every permission the group grants.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "group id"
+// @Param input body scim.PatchRequest true "operations"
+// @Success 200 {object} scim.Group
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Failure 409
+// @Router /scim/v2/Groups/{id} [PATCH]
+func (h *Handler) scimPatchGroup(w http.ResponseWriter, req bunrouter.Request) error {
+	g, ok, err := h.loadSCIMGroup(w, req)
+	if !ok {
+		return err
+	}
+
+	var patch scim.PatchRequest
+	if err = json.NewDecoder(req.Body).Decode(&patch); err != nil {
+		return h.scimError(w, req, &scim.BadRequestError{Type: scim.TypeInvalidSyntax, Detail: err.Error()})
+	}
+
+	group, err := h.scimGroup(req.Context(), g, scimBase(req))
+	if err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	resource, err := scim.ToMap(group)
+	if err != nil {
+		return h.scimError(w, req, err)
+	}
+	if err = patch.Apply(resource); err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	var r scim.Group
+	if err = scim.FromMap(resource, &r); err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	return h.scimUpdateGroup(w, req, g, r)
+}
+
+// scimDeleteGroup
+// @Summary Delete SCIM group
+// @Tags SCIM
+// @Description Delete a group, needs the group:write permission and every permission the group grants.
+// @Security BasicAuth
+// @Param id path string true "group id"
+// @Success 204
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Router /scim/v2/Groups/{id} [DELETE]
+func (h *Handler) scimDeleteGroup(w http.ResponseWriter, req bunrouter.Request) error {
+	g, ok, err := h.loadSCIMGroup(w, req)
+	if !ok {
+		return err
+	}
+
+	if !h.holdsAll(req.Context(), g.Permissions) {
+		return h.scimError(w, req, errNotGranted)
+	}
+
+	if err = h.groups.DeleteGroup(req.Context(), g.ID); err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// scimUser returns the resource of u. base is the URL of the SCIM API.
+func (h *Handler) scimUser(ctx context.Context, u model.User, base string) scim.User {
+	return scim.NewUser(u, h.groups.GetGroupsByUser(ctx, u.ID), base)
+}
+
+// scimUserFields sets the fields of user the resource r holds.
+func (h *Handler) scimUserFields(user model.User, r scim.User) (model.User, error) {
+	if r.UserName == "" {
+		return model.User{}, &scim.BadRequestError{Type: scim.TypeInvalidValue, Detail: "userName is required"}
+	}
+
+	user.Username = r.UserName
+	user.ExternalID = r.ExternalID
+	user.Disabled = !r.IsActive()
+
+	// Provisioning clients are trusted with the addresses they hold.
+	if email := r.Email(); email != user.Email {
+		user.Email = email
+		user.EmailVerified = email != ""
+	}
+
+	// An empty password keeps the stored one.
+	user.Password = ""
+	if r.Password != "" {
+		if reason := validatePassword(r.Password, h.cfg.Get().Password); len(reason) != 0 {
+			return model.User{}, &scim.BadRequestError{Type: scim.TypeInvalidValue, Detail: strings.Join(reason, ", ")}
+		}
+		user.Password = r.Password
+	}
+
+	return user, nil
+}
+
+func (h *Handler) scimUpdateUser(w http.ResponseWriter, req bunrouter.Request, old model.User, r scim.User) error {
+	if !h.mayManage(req.Context(), authenticatedUser(req.Context()), old, false) {
+		return h.scimError(w, req, errManageAdmins)
+	}
+
+	user, err := h.scimUserFields(old, r)
+	if err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	if err = h.repo.UpdateUser(req.Context(), user); err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	// Deactivating a user or setting their password signs them out
+	// everywhere.
+	if user.Password != "" || user.Disabled && !old.Disabled {
+		if err = h.sessions.RevokeAll(req.Context(), user.ID, ""); err != nil {
+			return h.scimError(w, req, err)
+		}
+	}
+
+	// Clients lose what the deactivated user granted them.
+	if user.Disabled && !old.Disabled {
+		if err = h.oauth.RevokeUser(req.Context(), user.ID); err != nil {
+			return h.scimError(w, req, err)
+		}
+	}
+
+	updated, err := h.repo.GetUserByID(req.Context(), user.ID)
+	if err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	return scimJSON(w, http.StatusOK, h.scimUser(req.Context(), updated, scimBase(req)))
+}
+
+// loadSCIMUser returns the user named by the id parameter. Service accounts
+// aren't provisioned. When it returns false the response has been written.
+func (h *Handler) loadSCIMUser(w http.ResponseWriter, req bunrouter.Request) (model.User, bool, error) {
+	user, err := h.tenantUser(req.Context(), req.Params().ByName("id"))
+	if err == nil && user.ServiceAccount {
+		err = repository.ErrUserNotFound
+	}
+	if err != nil {
+		return model.User{}, false, h.scimError(w, req, err)
+	}
+
+	return user, true, nil
+}
+
+// scimGroup returns the resource of g. base is the URL of the SCIM API.
+func (h *Handler) scimGroup(ctx context.Context, g model.Group, base string) (scim.Group, error) {
+	members, err := h.groups.GetMembers(ctx, g.ID)
+	if err != nil {
+		return scim.Group{}, err
+	}
+
+	return scim.NewGroup(g, members, base), nil
+}
+
+func (h *Handler) scimUpdateGroup(w http.ResponseWriter, req bunrouter.Request, g model.Group, r scim.Group) error {
+	// Changing the members hands out what the group grants.
+	if !h.holdsAll(req.Context(), g.Permissions) {
+		return h.scimError(w, req, errNotGranted)
+	}
+
+	members, err := h.scimMembers(req.Context(), r)
+	if err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	g.Name = r.DisplayName
+	g.ExternalID = r.ExternalID
+
+	if err = h.groups.UpdateGroup(req.Context(), g); err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	if err = h.syncMembers(req.Context(), g.ID, members); err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	resource, err := h.scimGroup(req.Context(), g, scimBase(req))
+	if err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	return scimJSON(w, http.StatusOK, resource)
+}
+
+// scimMembers checks the name of r and returns the IDs of its members, all
+// users of the tenant the request acts on.
+func (h *Handler) scimMembers(ctx context.Context, r scim.Group) ([]string, error) {
+	if r.DisplayName == "" {
+		return nil, &scim.BadRequestError{Type: scim.TypeInvalidValue, Detail: "displayName is required"}
+	}
+
+	ids, err := r.MemberIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		_, err := h.tenantUser(ctx, id)
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, &scim.BadRequestError{Type: scim.TypeInvalidValue, Detail: fmt.Sprintf("member %q not found", id)}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ids, nil
+}
+
+// syncMembers makes ids the members of group groupID.
+func (h *Handler) syncMembers(ctx context.Context, groupID string, ids []string) error {
+	current, err := h.groups.GetMembers(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	for _, u := range current {
+		if wanted[u.ID] {
+			delete(wanted, u.ID)
+			continue
+		}
+
+		if err = h.groups.RemoveMember(ctx, groupID, u.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range ids {
+		if !wanted[id] {
+			continue
+		}
+
+		if err = h.groups.AddMember(ctx, groupID, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadSCIMGroup returns the group named by the id parameter. When it returns
+// false the response has been written.
+func (h *Handler) loadSCIMGroup(w http.ResponseWriter, req bunrouter.Request) (model.Group, bool, error) {
+	g, err := h.tenantGroup(req.Context(), req.Params().ByName("id"))
+	if err != nil {
+		return model.Group{}, false, h.scimError(w, req, err)
+	}
+
+	return g, true, nil
+}
+
+// holdsAll reports whether the authenticated user holds every permission.
+func (h *Handler) holdsAll(ctx context.Context, permissions []string) bool {
+	held := h.permissions(ctx, authenticatedUser(ctx))
+
+	for _, p := range permissions {
+		if !held[p] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// scimResource answers with resource, limited to the attributes the query
+// asks for.
+func (h *Handler) scimResource(w http.ResponseWriter, req bunrouter.Request, resource interface{}) error {
+	params, err := scim.ParseListParams(req.URL.Query(), maxPageLimit)
+	if err != nil {
+		return h.scimError(w, req, err)
+	}
+
+	m, err := scim.ToMap(resource)
+	if err != nil {
+		return h.scimError(w, req, err)
+	}
+	scim.Project(m, params.Attributes, params.ExcludedAttributes)
+
+	return scimJSON(w, http.StatusOK, m)
+}
+
+// scimList answers a list request with the page of resources params asks
+// for.
+func scimList(params scim.ListParams, resources []map[string]interface{}) scim.ListResponse {
+	start, end := params.Page(len(resources))
+
+	page := make([]interface{}, 0, end-start)
+	for _, r := range resources[start:end] {
+		scim.Project(r, params.Attributes, params.ExcludedAttributes)
+		page = append(page, r)
+	}
+
+	return params.List(len(resources), page)
+}
+
+// scimBase returns the URL of the SCIM API of the tenant the request acts on.
+func scimBase(req bunrouter.Request) string {
+	base := requestOrigin(req)
+	if id := tenant.FromContext(req.Context()); id != tenant.Default {
+		base += tenant.PathPrefix + id
+	}
+
+	return base + scimPath
+}
+
+// scimError answers with the SCIM error for err.
+func (h *Handler) scimError(w http.ResponseWriter, req bunrouter.Request, err error) error {
+	code, scimType := ErrorStatus(err), ""
+
+	var bad *scim.BadRequestError
+	switch {
+	case errors.As(err, &bad):
+		scimType = bad.Type
+	case code == http.StatusConflict:
+		scimType = scim.TypeUniqueness
+	}
+
+	logger.FromContext(req.Context()).Warn().
+		Int("status", code).
+		Err(err).
+		Msg("scim request failed")
+
+	return scimJSON(w, code, scim.NewError(code, scimType, err.Error()))
+}
+
+// scimJSON writes value as is, SCIM responses have no data envelope.
+func scimJSON(w http.ResponseWriter, code int, value interface{}) error {
+	w.Header().Set("Content-Type", scim.ContentType)
+	w.WriteHeader(code)
+
+	return json.NewEncoder(w).Encode(value)
+}
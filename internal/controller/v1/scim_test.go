@@ -0,0 +1,208 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/apikey"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/oauth"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/scim"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_scim(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+
+	keys := apikey.NewManager(s.repo)
+	key, _, err := keys.Issue(ctx, s.admin.ID, "directory",
+		[]string{apikey.ScopeWrite, model.PermissionUserWrite, model.PermissionGroupWrite}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	s.route(WithGroups(s.repo), WithAPIKeys(keys), WithOAuth(oauth.NewServer(s.repo, config.OAuth{})))
+
+	// do sends r with the API key of the directory unless it has a username.
+	do := func(r testRequest) *httptest.ResponseRecorder {
+		if r.username == "" {
+			r.authorization = "Bearer " + key
+		}
+
+		return s.do(r)
+	}
+
+	decode := func(t *testing.T, w *httptest.ResponseRecorder, code int, v interface{}) {
+		require.Equal(t, code, w.Code, w.Body.String())
+		assert.Equal(t, scim.ContentType, w.Header().Get("Content-Type"))
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+	}
+
+	var alice scim.User
+
+	t.Run("DISCOVERY", func(t *testing.T) {
+		w := s.do(testRequest{method: "GET", target: "/scim/v2/ServiceProviderConfig"})
+
+		var config scim.ServiceProviderConfig
+		decode(t, w, http.StatusOK, &config)
+		assert.True(t, config.Patch.Supported)
+		assert.Equal(t, maxPageLimit, config.Filter.MaxResults)
+
+		var list scim.ListResponse
+		decode(t, do(testRequest{method: "GET", target: "/scim/v2/ResourceTypes"}), http.StatusOK, &list)
+		assert.Equal(t, 2, list.TotalResults)
+
+		w = do(testRequest{method: "GET", target: "/scim/v2/Schemas/" + scim.SchemaGroup})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"displayName"`)
+
+		assert.Equal(t, http.StatusNotFound, do(testRequest{method: "GET", target: "/scim/v2/Schemas/unknown"}).Code)
+	})
+
+	t.Run("CREATE_USER", func(t *testing.T) {
+		w := do(testRequest{method: "POST", target: "/scim/v2/Users", body: `{
+			"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+			"userName": "alice",
+			"externalId": "00u1",
+			"password": "alice-password",
+			"emails": [{"value": "alice@example.com", "type": "work", "primary": true}]
+		}`})
+		decode(t, w, http.StatusCreated, &alice)
+		assert.Equal(t, "http://example.com/scim/v2/Users/"+alice.ID, w.Header().Get("Location"))
+		assert.Equal(t, "00u1", alice.ExternalID)
+		assert.True(t, alice.IsActive())
+		assert.Empty(t, alice.Password)
+
+		stored, err := s.repo.GetUserByID(ctx, alice.ID)
+		require.NoError(t, err)
+		assert.True(t, stored.EmailVerified)
+
+		w = do(testRequest{method: "POST", target: "/scim/v2/Users", body: `{"userName": "alice"}`})
+		var scimErr scim.Error
+		decode(t, w, http.StatusConflict, &scimErr)
+		assert.Equal(t, scim.TypeUniqueness, scimErr.ScimType)
+
+		w = do(testRequest{method: "POST", target: "/scim/v2/Users", body: `{"emails": [{"value": "carol@example.com"}]}`})
+		decode(t, w, http.StatusBadRequest, &scimErr)
+		assert.Equal(t, scim.TypeInvalidValue, scimErr.ScimType)
+	})
+
+	t.Run("LIST_USERS", func(t *testing.T) {
+		var list scim.ListResponse
+		decode(t, do(testRequest{method: "GET", target: `/scim/v2/Users?filter=userName+eq+"ALICE"`}), http.StatusOK, &list)
+		require.Equal(t, 1, list.TotalResults)
+		assert.Contains(t, list.Resources[0], "emails")
+
+		decode(t, do(testRequest{method: "GET", target: "/scim/v2/Users?startIndex=2&count=1&attributes=userName"}), http.StatusOK, &list)
+		assert.Equal(t, 3, list.TotalResults)
+		assert.Equal(t, 2, list.StartIndex)
+		require.Len(t, list.Resources, 1)
+		assert.Equal(t, "alice", list.Resources[0].(map[string]interface{})["userName"])
+		assert.NotContains(t, list.Resources[0], "emails")
+
+		var scimErr scim.Error
+		decode(t, do(testRequest{method: "GET", target: "/scim/v2/Users?filter=userName+eq"}), http.StatusBadRequest, &scimErr)
+		assert.Equal(t, scim.TypeInvalidFilter, scimErr.ScimType)
+	})
+
+	t.Run("DEACTIVATE_USER", func(t *testing.T) {
+		w := do(testRequest{method: "POST", target: "/v1/auth/login", body: `{"username":"alice","password":"alice-password"}`})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		granted := model.OAuthToken{ID: "granted", Kind: model.OAuthRefresh, ClientID: "spa", UserID: alice.ID,
+			ExpiresAt: time.Now().Add(time.Hour)}
+		require.NoError(t, s.repo.CreateOAuthToken(ctx, granted))
+
+		var patched scim.User
+		decode(t, do(testRequest{method: "PATCH", target: "/scim/v2/Users/" + alice.ID, body: `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations": [{"op": "Replace", "value": {"active": "False"}}]
+		}`}), http.StatusOK, &patched)
+		assert.False(t, patched.IsActive())
+
+		w = do(testRequest{method: "POST", target: "/v1/auth/login", body: `{"username":"alice","password":"alice-password"}`})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = do(testRequest{method: "GET", target: "/v1/me/groups", username: "alice", password: "alice-password"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		_, err := s.repo.GetOAuthToken(ctx, granted.ID)
+		assert.ErrorIs(t, err, repository.ErrOAuthTokenNotFound, "clients lose the grants of deactivated users")
+	})
+
+	t.Run("REPLACE_USER", func(t *testing.T) {
+		var replaced scim.User
+		decode(t, do(testRequest{method: "PUT", target: "/scim/v2/Users/" + alice.ID, body: `{
+			"userName": "alice",
+			"active": true,
+			"emails": [{"value": "alice@example.org"}]
+		}`}), http.StatusOK, &replaced)
+		assert.True(t, replaced.IsActive())
+		assert.Equal(t, "alice@example.org", replaced.Email())
+		assert.Empty(t, replaced.ExternalID)
+
+		w := do(testRequest{method: "POST", target: "/v1/auth/login", body: `{"username":"alice","password":"alice-password"}`})
+		assert.Equal(t, http.StatusOK, w.Code, "the password is kept")
+
+		assert.Equal(t, http.StatusNotFound, do(testRequest{method: "PUT", target: "/scim/v2/Users/unknown", body: `{"userName":"x"}`}).Code)
+	})
+
+	t.Run("GROUPS", func(t *testing.T) {
+		var group scim.Group
+		w := do(testRequest{method: "POST", target: "/scim/v2/Groups", body: `{
+			"displayName": "staff",
+			"members": [{"value": "` + alice.ID + `"}]
+		}`})
+		decode(t, w, http.StatusCreated, &group)
+		assert.Equal(t, "http://example.com/scim/v2/Groups/"+group.ID, w.Header().Get("Location"))
+		require.Len(t, group.Members, 1)
+		assert.Equal(t, "alice", group.Members[0].Display)
+
+		decode(t, do(testRequest{method: "PATCH", target: "/scim/v2/Groups/" + group.ID, body: `{
+			"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+			"Operations": [
+				{"op": "add", "path": "members", "value": [{"value": "` + s.bob.ID + `"}]},
+				{"op": "remove", "path": "members[value eq \"` + alice.ID + `\"]"}
+			]
+		}`}), http.StatusOK, &group)
+		require.Len(t, group.Members, 1)
+		assert.Equal(t, "bob", group.Members[0].Display)
+
+		var user scim.User
+		decode(t, do(testRequest{method: "GET", target: "/scim/v2/Users/" + s.bob.ID}), http.StatusOK, &user)
+		require.Len(t, user.Groups, 1)
+		assert.Equal(t, "staff", user.Groups[0].Display)
+
+		var list scim.ListResponse
+		decode(t, do(testRequest{method: "GET", target: `/scim/v2/Groups?filter=displayName+eq+"staff"&excludedAttributes=members`}),
+			http.StatusOK, &list)
+		require.Equal(t, 1, list.TotalResults)
+		assert.NotContains(t, list.Resources[0], "members")
+
+		var scimErr scim.Error
+		decode(t, do(testRequest{method: "POST", target: "/scim/v2/Groups", body: `{"displayName": "ops", "members": [{"value": "unknown"}]}`}),
+			http.StatusBadRequest, &scimErr)
+		assert.Equal(t, scim.TypeInvalidValue, scimErr.ScimType)
+
+		assert.Equal(t, http.StatusNoContent, do(testRequest{method: "DELETE", target: "/scim/v2/Groups/" + group.ID}).Code)
+		assert.Equal(t, http.StatusNotFound, do(testRequest{method: "GET", target: "/scim/v2/Groups/" + group.ID}).Code)
+	})
+
+	t.Run("DELETE_USER", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do(testRequest{method: "DELETE", target: "/scim/v2/Users/" + alice.ID}).Code)
+		assert.Equal(t, http.StatusNotFound, do(testRequest{method: "GET", target: "/scim/v2/Users/" + alice.ID}).Code)
+	})
+
+	t.Run("NOT_OK", func(t *testing.T) {
+		w := do(testRequest{method: "GET", target: "/scim/v2/Users", username: "bob", password: "bob-password"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = s.do(testRequest{method: "GET", target: "/scim/v2/Users", authorization: "Bearer " + key + "x"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
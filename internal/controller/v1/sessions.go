@@ -0,0 +1,241 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/authn"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/session"
+	"encoding/json"
+	"errors"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+)
+
+// login
+// @Summary Log in
+// @Tags Auth
+// @Description Check the credentials and start a session. The session token is returned in an HttpOnly cookie that authenticates later requests. Users with two-factor authentication also send a TOTP or recovery code.
+// @Accept  json
+// @Produce  json
+// @Param input body controller.LoginRequest true "credentials"
+// @Success 200 {object} controller.SessionResponse
+// @Failure 400
+// @Failure 401
+// @Failure 500
+// @Failure 503
+// @Router /v1/auth/login [POST]
+func (h *Handler) login(w http.ResponseWriter, req bunrouter.Request) error {
+	body := req.Body
+	defer body.Close()
+
+	var credentials controller.LoginRequest
+	if err := json.NewDecoder(body).Decode(&credentials); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	_, token, s, err := h.signIn(req.Context(), req.Route(), credentials, clientIP(req.Request), req.UserAgent())
+	if err != nil {
+		return h.responseJSON(w, req, ErrorStatus(err), err.Error())
+	}
+
+	http.SetCookie(w, h.sessions.Cookie(token, s))
+
+	return h.responseJSON(w, req, http.StatusOK, sessionResponse(s, s.ID))
+}
+
+var (
+	errAuthnUnavailable  = errors.New("unable to check credentials")
+	errTwoFactorRequired = errors.New("two-factor code required")
+	errInvalidTwoFactor  = errors.New("invalid two-factor code")
+)
+
+// signIn checks the credentials sent to route, by login or the gRPC
+// Authenticate, and starts a session of their user from ip with userAgent.
+func (h *Handler) signIn(ctx context.Context, route string, credentials controller.LoginRequest, ip, userAgent string) (model.User, string, model.Session, error) {
+	user, err := h.authn.Authenticate(ctx, credentials.Username, credentials.Password)
+	if errors.Is(err, authn.ErrInvalidCredentials) {
+		return model.User{}, "", model.Session{}, err
+	}
+	if err != nil {
+		logger.FromContext(ctx).Error().Err(err).Msg("unable to check credentials")
+		return model.User{}, "", model.Session{}, errAuthnUnavailable
+	}
+
+	logger.WithUser(ctx, user.Username)
+
+	if user.Disabled {
+		return model.User{}, "", model.Session{}, errUserDisabled
+	}
+
+	if h.verificationBlocks(user, route) {
+		return model.User{}, "", model.Session{}, errEmailNotVerified
+	}
+
+	t, err := h.totp.GetTOTP(ctx, user.ID)
+	if err != nil && !errors.Is(err, repository.ErrTOTPNotFound) {
+		return model.User{}, "", model.Session{}, err
+	}
+	if err == nil && t.Enabled {
+		if credentials.Code == "" {
+			return model.User{}, "", model.Session{}, errTwoFactorRequired
+		}
+
+		if !h.verifySecondFactor(ctx, t, credentials.Code) {
+			return model.User{}, "", model.Session{}, errInvalidTwoFactor
+		}
+	}
+
+	token, s, err := h.sessions.Create(ctx, user.ID, ip, userAgent)
+	if err != nil {
+		return model.User{}, "", model.Session{}, err
+	}
+
+	return user, token, s, nil
+}
+
+// logout
+// @Summary Log out
+// @Tags Auth
+// @Description End the session of the request and clear the session cookie.
+// @Produce  json
+// @Success 200
+// @Failure 500
+// @Router /v1/auth/logout [POST]
+func (h *Handler) logout(w http.ResponseWriter, req bunrouter.Request) error {
+	s := currentSession(req.Context())
+	if s.ID != "" {
+		err := h.sessions.Revoke(req.Context(), s.UserID, s.ID)
+		if err != nil && !errors.Is(err, session.ErrNotFound) {
+			return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+		}
+	}
+
+	http.SetCookie(w, h.sessions.ClearCookie())
+
+	return h.responseJSON(w, req, http.StatusOK, "logged out")
+}
+
+// getSessions
+// @Summary List own sessions
+// @Tags Me
+// @Description List the active sessions of the authenticated user with the IP and user agent they were started from.
+// @Produce  json
+// @Security BasicAuth
+// @Success 200 {array} controller.SessionResponse
+// @Failure 500
+// @Router /v1/me/sessions [GET]
+func (h *Handler) getSessions(w http.ResponseWriter, req bunrouter.Request) error {
+	user := authenticatedUser(req.Context())
+
+	sessions, err := h.sessions.List(req.Context(), user.ID)
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	current := currentSession(req.Context()).ID
+
+	resp := make([]controller.SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		resp = append(resp, sessionResponse(s, current))
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, resp)
+}
+
+// deleteSession
+// @Summary Revoke own session
+// @Tags Me
+// @Description Revoke one session of the authenticated user.
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "session id"
+// @Success 200
+// @Failure 404
+// @Failure 500
+// @Router /v1/me/sessions/{id} [DELETE]
+func (h *Handler) deleteSession(w http.ResponseWriter, req bunrouter.Request) error {
+	user := authenticatedUser(req.Context())
+	id := req.Params().ByName("id")
+
+	err := h.sessions.Revoke(req.Context(), user.ID, id)
+	if errors.Is(err, session.ErrNotFound) {
+		return h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	if id == currentSession(req.Context()).ID {
+		http.SetCookie(w, h.sessions.ClearCookie())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, "session was revoked")
+}
+
+// deleteSessions
+// @Summary Revoke all own sessions
+// @Tags Me
+// @Description Revoke every session of the authenticated user, including the current one.
+// @Produce  json
+// @Security BasicAuth
+// @Success 200
+// @Failure 500
+// @Router /v1/me/sessions [DELETE]
+func (h *Handler) deleteSessions(w http.ResponseWriter, req bunrouter.Request) error {
+	user := authenticatedUser(req.Context())
+
+	if err := h.sessions.RevokeAll(req.Context(), user.ID, ""); err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	http.SetCookie(w, h.sessions.ClearCookie())
+
+	return h.responseJSON(w, req, http.StatusOK, "sessions were revoked")
+}
+
+// deleteUserSessions
+// @Summary Revoke user sessions
+// @Tags User
+// @Description Revoke every session of a user.
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "user id"
+// @Success 200
+// @Failure 500
+// @Router /v1/user/{id}/sessions [DELETE]
+func (h *Handler) deleteUserSessions(w http.ResponseWriter, req bunrouter.Request) error {
+	id := req.Params().ByName("id")
+
+	target, err := h.tenantUser(req.Context(), id)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	if !h.mayManage(req.Context(), authenticatedUser(req.Context()), target, false) {
+		return h.responseJSON(w, req, http.StatusForbidden, errManageAdmins.Error())
+	}
+
+	if err = h.sessions.RevokeAll(req.Context(), id, ""); err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, "sessions were revoked")
+}
+
+func sessionResponse(s model.Session, current string) controller.SessionResponse {
+	return controller.SessionResponse{
+		ID:         s.ID,
+		IP:         s.IP,
+		UserAgent:  s.UserAgent,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+		Current:    s.ID == current,
+	}
+}
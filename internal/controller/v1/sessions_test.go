@@ -0,0 +1,113 @@
+package v1
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func Test_sessions(t *testing.T) {
+	s := newTestServer(t)
+
+	login := func(username, password, agent string) *http.Cookie {
+		w := s.do(testRequest{method: "POST", target: "/v1/auth/login", body: `{"username":"` + username + `","password":"` + password + `"}`, header: map[string]string{"User-Agent": agent}})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+
+		return cookies[0]
+	}
+
+	list := func(cookie *http.Cookie) []map[string]interface{} {
+		w := s.do(testRequest{method: "GET", target: "/v1/me/sessions", cookie: cookie})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp struct {
+			Data []map[string]interface{} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+		return resp.Data
+	}
+
+	t.Run("WRONG_PASSWORD", func(t *testing.T) {
+		w := s.do(testRequest{method: "POST", target: "/v1/auth/login", body: `{"username":"admin","password":"nope"}`})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `{"error":"invalid credentials"}
+`, w.Body.String())
+		assert.Empty(t, w.Result().Cookies())
+	})
+
+	t.Run("COOKIE_FLAGS", func(t *testing.T) {
+		c := login("admin", "admin", "laptop")
+		assert.Equal(t, "profile_session", c.Name)
+		assert.True(t, c.HttpOnly)
+		assert.True(t, c.Secure)
+		assert.Equal(t, http.SameSiteLaxMode, c.SameSite)
+
+		assert.Equal(t, http.StatusOK, s.do(testRequest{method: "POST", target: "/v1/auth/logout", cookie: c}).Code)
+	})
+
+	t.Run("LIST_AND_REVOKE", func(t *testing.T) {
+		laptop := login("admin", "admin", "laptop")
+		phone := login("admin", "admin", "phone")
+
+		sessions := list(laptop)
+		require.Len(t, sessions, 2)
+
+		var phoneID string
+		for _, s := range sessions {
+			assert.Equal(t, "192.0.2.1", s["ip"])
+			assert.Equal(t, s["user_agent"] == "laptop", s["current"])
+			if s["user_agent"] == "phone" {
+				phoneID = s["id"].(string)
+			}
+		}
+
+		assert.Equal(t, http.StatusNotFound, s.do(testRequest{method: "DELETE", target: "/v1/me/sessions/unknown", cookie: laptop}).Code)
+		assert.Equal(t, http.StatusOK, s.do(testRequest{method: "DELETE", target: "/v1/me/sessions/" + phoneID, cookie: laptop}).Code)
+		assert.Equal(t, http.StatusUnauthorized, s.do(testRequest{method: "GET", target: "/v1/me/sessions", cookie: phone}).Code)
+		assert.Len(t, list(laptop), 1)
+
+		assert.Equal(t, http.StatusOK, s.do(testRequest{method: "DELETE", target: "/v1/me/sessions", cookie: laptop}).Code)
+		assert.Equal(t, http.StatusUnauthorized, s.do(testRequest{method: "GET", target: "/v1/me/sessions", cookie: laptop}).Code)
+	})
+
+	t.Run("NOT_OWN_SESSION", func(t *testing.T) {
+		admin := login("admin", "admin", "")
+		bobs := login("bob", "bob-password", "")
+
+		id := list(bobs)[0]["id"].(string)
+		assert.Equal(t, http.StatusNotFound, s.do(testRequest{method: "DELETE", target: "/v1/me/sessions/" + id, cookie: admin}).Code)
+		assert.Len(t, list(bobs), 1)
+
+		assert.Equal(t, http.StatusUnauthorized, s.do(testRequest{method: "DELETE", target: "/v1/user/" + s.bob.ID + "/sessions", cookie: bobs}).Code)
+		assert.Equal(t, http.StatusOK, s.do(testRequest{method: "DELETE", target: "/v1/user/" + s.bob.ID + "/sessions", cookie: admin}).Code)
+		assert.Equal(t, http.StatusUnauthorized, s.do(testRequest{method: "GET", target: "/v1/me/sessions", cookie: bobs}).Code)
+	})
+
+	t.Run("ADMIN_PASSWORD_CHANGE", func(t *testing.T) {
+		admin := login("admin", "admin", "")
+		bobs := login("bob", "bob-password", "")
+
+		w := s.do(testRequest{method: "PATCH", target: "/v1/user/" + s.bob.ID, body: `{"username":"bob","email":"bob","password":"n3w-password"}`, cookie: admin})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		assert.Equal(t, http.StatusUnauthorized, s.do(testRequest{method: "GET", target: "/v1/me/sessions", cookie: bobs}).Code)
+		assert.NotEmpty(t, list(admin))
+	})
+
+	t.Run("OWN_PASSWORD_CHANGE", func(t *testing.T) {
+		first := login("bob", "n3w-password", "")
+		second := login("bob", "n3w-password", "")
+
+		w := s.do(testRequest{method: "PUT", target: "/v1/me/password", body: `{"current_password":"n3w-password","new_password":"0ther-password"}`, cookie: first})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		assert.Len(t, list(first), 1)
+		assert.Equal(t, http.StatusUnauthorized, s.do(testRequest{method: "GET", target: "/v1/me/sessions", cookie: second}).Code)
+	})
+}
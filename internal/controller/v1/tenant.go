@@ -0,0 +1,295 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/tenant"
+	"encoding/json"
+	"errors"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// tenantPath resolves the tenant a request names, by a /t/{tenant} path
+// prefix, which is stripped before routing, or else by the X-Tenant header.
+// Requests naming no tenant act on the tenant of the authenticated user.
+func tenantPath(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.URL.Path, tenant.PathPrefix) {
+			rest := strings.TrimPrefix(req.URL.Path, tenant.PathPrefix)
+
+			id, path := rest, "/"
+			if i := strings.IndexByte(rest, '/'); i >= 0 {
+				id, path = rest[:i], rest[i:]
+			}
+
+			u := *req.URL
+			u.Path = path
+			u.RawPath = ""
+
+			req = req.WithContext(tenant.WithID(req.Context(), id))
+			req.URL = &u
+		} else if id := req.Header.Get(tenant.Header); id != "" {
+			req = req.WithContext(tenant.WithID(req.Context(), id))
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Location returns path as the client addresses it: below /t/{tenant} when
+// the request named its tenant that way.
+func Location(req bunrouter.Request, path string) string {
+	if id, ok := tenant.Lookup(req.Context()); ok && strings.HasPrefix(req.RequestURI, tenant.PathPrefix+id+"/") {
+		return tenant.PathPrefix + id + path
+	}
+
+	return path
+}
+
+// tenantMiddleware refuses requests naming an unknown or disabled tenant.
+func (h *Handler) tenantMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		if id, named := tenant.Lookup(req.Context()); named {
+			if err := h.activeTenant(req.Context(), id); err != nil {
+				return h.responseJSON(w, req, ErrorStatus(err), err.Error())
+			}
+		}
+
+		return next(w, req)
+	}
+}
+
+// errTenantDisabled refuses requests acting on a disabled tenant.
+var errTenantDisabled = errors.New("tenant is disabled")
+
+// activeTenant checks that tenant id exists and is enabled.
+func (h *Handler) activeTenant(ctx context.Context, id string) error {
+	if id == tenant.Default {
+		return nil
+	}
+
+	if !tenant.Valid(id) {
+		return repository.ErrTenantNotFound
+	}
+
+	t, err := h.tenants.GetTenant(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if t.Disabled {
+		return errTenantDisabled
+	}
+
+	return nil
+}
+
+// superAdmin reports whether user manages all tenants.
+func superAdmin(user model.User) bool {
+	return user.Admin && user.Tenant == tenant.Default
+}
+
+func (h *Handler) isSuperAdminMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		if !superAdmin(authenticatedUser(req.Context())) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return nil
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		return next(w, req)
+	}
+}
+
+// tenantUser returns user id of the tenant the request acts on. Users of other
+// tenants are repository.ErrUserNotFound.
+func (h *Handler) tenantUser(ctx context.Context, id string) (model.User, error) {
+	user, err := h.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if user.Tenant != tenant.FromContext(ctx) {
+		return model.User{}, repository.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// getTenants
+// @Summary Get all tenants
+// @Tags Tenant
+// @Description Get all tenants, only for admins of the default tenant
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Success 200 {array} controller.TenantResponse
+// @Failure 401
+// @Router /v1/tenant [GET]
+func (h *Handler) getTenants(w http.ResponseWriter, req bunrouter.Request) error {
+	tenants := h.tenants.GetAllTenants(req.Context())
+
+	response := make([]controller.TenantResponse, 0, len(tenants))
+	for _, t := range tenants {
+		response = append(response, tenantResponse(t))
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, response)
+}
+
+// createTenant
+// @Summary Create tenant
+// @Tags Tenant
+// @Description Create a tenant, only for admins of the default tenant. Its first admin is created with POST /t/{tenant}/v1/user.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param input body controller.TenantRequest true "tenant"
+// @Success 200 {object} controller.TenantResponse
+// @Failure 400
+// @Failure 401
+// @Failure 500
+// @Router /v1/tenant [POST]
+func (h *Handler) createTenant(w http.ResponseWriter, req bunrouter.Request) error {
+	var input controller.TenantRequest
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	if !tenant.Valid(input.ID) {
+		return h.responseJSON(w, req, http.StatusBadRequest,
+			"invalid tenant id, use up to 63 lowercase letters, digits and dashes")
+	}
+
+	t := model.Tenant{
+		ID:        input.ID,
+		Name:      input.Name,
+		Disabled:  input.Disabled,
+		CreatedAt: time.Now(),
+	}
+	if t.Name == "" {
+		t.Name = t.ID
+	}
+
+	err := h.tenants.CreateTenant(req.Context(), t)
+	if errors.Is(err, repository.ErrTenantExists) {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, tenantResponse(t))
+}
+
+// getTenant
+// @Summary Get tenant by id
+// @Tags Tenant
+// @Description Get tenant by id, only for admins of the default tenant
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "tenant id"
+// @Success 200 {object} controller.TenantResponse
+// @Failure 401
+// @Failure 404
+// @Router /v1/tenant/{id} [GET]
+func (h *Handler) getTenant(w http.ResponseWriter, req bunrouter.Request) error {
+	t, err := h.tenants.GetTenant(req.Context(), req.Params().ByName("id"))
+	if errors.Is(err, repository.ErrTenantNotFound) {
+		return h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, tenantResponse(t))
+}
+
+// updateTenant
+// @Summary Update tenant
+// @Tags Tenant
+// @Description Rename, disable or enable a tenant, only for admins of the default tenant. Users of a disabled tenant can't sign in.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "tenant id"
+// @Param input body controller.TenantRequest true "tenant"
+// @Success 200 {object} controller.TenantResponse
+// @Failure 400
+// @Failure 401
+// @Failure 404
+// @Router /v1/tenant/{id} [PATCH]
+func (h *Handler) updateTenant(w http.ResponseWriter, req bunrouter.Request) error {
+	var input controller.TenantRequest
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	id := req.Params().ByName("id")
+	if input.ID != "" && input.ID != id {
+		return h.responseJSON(w, req, http.StatusBadRequest, "the tenant id can't be changed")
+	}
+
+	old, err := h.tenants.GetTenant(req.Context(), id)
+	if errors.Is(err, repository.ErrTenantNotFound) {
+		return h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	t := model.Tenant{
+		ID:        id,
+		Name:      input.Name,
+		Disabled:  input.Disabled,
+		CreatedAt: old.CreatedAt,
+	}
+	if t.Name == "" {
+		t.Name = old.Name
+	}
+
+	if err = h.tenants.UpdateTenant(req.Context(), t); err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, tenantResponse(t))
+}
+
+// deleteTenant
+// @Summary Delete tenant
+// @Tags Tenant
+// @Description Delete a tenant together with all its users, only for admins of the default tenant
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "tenant id"
+// @Success 200
+// @Failure 401
+// @Failure 404
+// @Router /v1/tenant/{id} [DELETE]
+func (h *Handler) deleteTenant(w http.ResponseWriter, req bunrouter.Request) error {
+	err := h.tenants.DeleteTenant(req.Context(), req.Params().ByName("id"))
+	if errors.Is(err, repository.ErrTenantNotFound) {
+		return h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, "tenant was deleted")
+}
+
+func tenantResponse(t model.Tenant) controller.TenantResponse {
+	return controller.TenantResponse{
+		ID:        t.ID,
+		Name:      t.Name,
+		Disabled:  t.Disabled,
+		CreatedAt: t.CreatedAt,
+	}
+}
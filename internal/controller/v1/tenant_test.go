@@ -0,0 +1,187 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/tenant"
+	"dev/profileSaver/internal/token"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_tenants(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+
+	live := config.NewLive(config.Config{Registration: config.Registration{Mode: config.RegistrationInvite}})
+	s.route(WithConfig(live), WithTenants(s.repo), WithTOTP(s.repo), WithTokens(token.NewManager(s.repo)))
+
+	usernames := func(w *httptest.ResponseRecorder) []string {
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var resp struct {
+			Data []struct {
+				Username string `json:"username"`
+				Tenant   string `json:"tenant"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+		var names []string
+		for _, u := range resp.Data {
+			names = append(names, u.Tenant+"/"+u.Username)
+		}
+
+		return names
+	}
+
+	const (
+		admin = "admin"
+		boss  = "boss-password"
+	)
+
+	t.Run("CREATE", func(t *testing.T) {
+		w := s.do(testRequest{method: "POST", target: "/v1/tenant", body: `{"id":"acme","name":"Acme"}`, username: admin, password: admin})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"name":"Acme"`)
+
+		w = s.do(testRequest{method: "POST", target: "/v1/tenant", body: `{"id":"acme"}`, username: admin, password: admin})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, `{"error":"tenant exists"}
+`, w.Body.String())
+
+		w = s.do(testRequest{method: "POST", target: "/v1/tenant", body: `{"id":"Other/x"}`, username: admin, password: admin})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		require.Equal(t, http.StatusOK, s.do(testRequest{method: "POST", target: "/v1/tenant", body: `{"id":"other"}`,
+			username: admin, password: admin}).Code)
+	})
+
+	t.Run("USERS", func(t *testing.T) {
+		w := s.do(testRequest{method: "POST", target: "/t/acme/v1/user", username: admin, password: admin,
+			body: `{"username":"boss","email":"boss@acme.test","password":"boss-password","admin":true}`})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		assert.True(t, strings.HasPrefix(w.Header().Get("Location"), "/t/acme/v1/user/"), w.Header().Get("Location"))
+
+		w = s.do(testRequest{method: "POST", target: "/v1/user", header: map[string]string{tenant.Header: "acme"}, username: admin, password: admin,
+			body: `{"username":"bob","email":"bob@acme.test","password":"bob-password"}`})
+		require.Equal(t, http.StatusCreated, w.Code, "usernames are unique per tenant: %s", w.Body.String())
+		assert.True(t, strings.HasPrefix(w.Header().Get("Location"), "/v1/user/"), w.Header().Get("Location"))
+
+		w = s.do(testRequest{method: "POST", target: "/t/acme/v1/user", username: "boss", password: boss,
+			body: `{"username":"bob","email":"bob@acme.test","password":"bob-password"}`})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		assert.ElementsMatch(t, []string{"acme/boss", "acme/bob"},
+			usernames(s.do(testRequest{method: "GET", target: "/t/acme/v1/user", username: "boss", password: boss})))
+		assert.ElementsMatch(t, []string{"/admin", "/bob"},
+			usernames(s.do(testRequest{method: "GET", target: "/v1/user", username: admin, password: admin})))
+	})
+
+	t.Run("TENANT_ADMIN_ISOLATION", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized,
+			s.do(testRequest{method: "GET", target: "/v1/user", username: "boss", password: boss}).Code,
+			"tenant users only exist in their tenant")
+
+		w := s.do(testRequest{method: "GET", target: "/t/other/v1/user", username: "boss", password: boss})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = s.do(testRequest{method: "GET", target: "/t/nope/v1/user", username: "boss", password: boss})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, `{"error":"tenant not found"}
+`, w.Body.String())
+
+		w = s.do(testRequest{method: "GET", target: "/t/acme/v1/user/" + s.bob.ID, username: "boss", password: boss})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = s.do(testRequest{method: "DELETE", target: "/t/acme/v1/user/" + s.bob.ID, username: "boss", password: boss})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = s.do(testRequest{method: "GET", target: "/t/acme/v1/tenant", username: "boss", password: boss})
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "tenant admins don't manage tenants")
+	})
+
+	t.Run("SESSION", func(t *testing.T) {
+		w := s.do(testRequest{method: "POST", target: "/t/acme/v1/auth/login", body: `{"username":"bob","password":"bob-password"}`})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		cookie := w.Result().Cookies()[0]
+
+		assert.Contains(t, usernames(s.do(testRequest{method: "GET", target: "/v1/user", cookie: cookie})), "acme/boss",
+			"the session acts on the tenant of its user")
+
+		w = s.do(testRequest{method: "GET", target: "/v1/user", header: map[string]string{tenant.Header: "other"}, cookie: cookie})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, `{"error":"user belongs to another tenant"}
+`, w.Body.String())
+	})
+
+	t.Run("INVITE", func(t *testing.T) {
+		invite := func() string {
+			w := s.do(testRequest{method: "POST", target: "/t/acme/v1/invite", username: "boss", password: boss})
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			var resp struct {
+				Data struct {
+					Code string `json:"code"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+			return resp.Data.Code
+		}
+
+		register := func(target, username, code string) *httptest.ResponseRecorder {
+			return s.do(testRequest{method: "POST", target: target,
+				body: `{"username":"` + username + `","email":"` + username + `@acme.test","password":"` + username +
+					`-password","invite_code":"` + code + `"}`})
+		}
+
+		w := register("/t/acme/v1/auth/register", "carol", invite())
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		carol, err := s.repo.GetUserByName(tenant.WithID(ctx, "acme"), "carol")
+		require.NoError(t, err)
+		assert.Equal(t, "acme", carol.Tenant)
+
+		w = register("/v1/auth/register", "dave", invite())
+		assert.Equal(t, http.StatusForbidden, w.Code, "invites only work in their tenant")
+	})
+
+	t.Run("DISABLE", func(t *testing.T) {
+		w := s.do(testRequest{method: "PATCH", target: "/v1/tenant/acme", body: `{"disabled":true}`, username: admin, password: admin})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"name":"Acme"`, "an empty name keeps the current one")
+
+		w = s.do(testRequest{method: "GET", target: "/t/acme/v1/user", username: "boss", password: boss})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, `{"error":"tenant is disabled"}
+`, w.Body.String())
+
+		w = s.do(testRequest{method: "PATCH", target: "/v1/tenant/acme", body: `{"disabled":false}`, username: admin, password: admin})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("DELETE", func(t *testing.T) {
+		w := s.do(testRequest{method: "GET", target: "/v1/tenant", username: admin, password: admin})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"id":"acme"`)
+
+		w = s.do(testRequest{method: "DELETE", target: "/v1/tenant/acme", username: admin, password: admin})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		_, err := s.repo.GetUserByName(tenant.WithID(ctx, "acme"), "boss")
+		assert.Equal(t, repository.ErrUserNotFound, err)
+
+		w = s.do(testRequest{method: "GET", target: "/v1/tenant/acme", username: admin, password: admin})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		_, err = s.repo.GetUserByID(ctx, s.bob.ID)
+		assert.NoError(t, err, "the default tenant is untouched")
+	})
+}
@@ -0,0 +1,46 @@
+package v1
+
+import (
+	"dev/profileSaver/internal/logger"
+	"github.com/uptrace/bunrouter"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+var tracer = otel.Tracer("dev/profileSaver/internal/controller/v1")
+
+// tracingMiddleware continues the trace from an incoming traceparent header
+// (or starts a new one) and opens a server span for the matched route. The
+// trace is not sent back, so clients never learn internal span IDs.
+func tracingMiddleware(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+	return func(w http.ResponseWriter, req bunrouter.Request) error {
+		ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+
+		ctx, span := tracer.Start(ctx, req.Method+" "+req.Route(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethodKey.String(req.Method),
+				semconv.HTTPRouteKey.String(req.Route()),
+				semconv.HTTPTargetKey.String(req.URL.RequestURI()),
+			),
+		)
+		defer span.End()
+
+		rec := logger.NewStatusRecorder(w)
+		err := next(rec, req.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(rec.Code))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else if rec.Code >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.Code))
+		}
+
+		return err
+	}
+}
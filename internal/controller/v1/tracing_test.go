@@ -0,0 +1,58 @@
+package v1
+
+import (
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	mock_repository "dev/profileSaver/internal/repository/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_tracingMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	repo := mock_repository.NewMockRepository(c)
+	repo.EXPECT().IsAuthorized(gomock.Any(), "admin", "admin").Return(true)
+	repo.EXPECT().GetUserByName(gomock.Any(), "admin").Return(model.User{Username: "admin"}, nil)
+	repo.EXPECT().GetUserByID(gomock.Any(), "1").Return(model.User{ID: "1"}, nil)
+
+	r := New(repository.NewTraced(repo)).InitRouter()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/v1/user/1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	req.SetBasicAuth("admin", "admin")
+
+	r.ServeHTTP(w, req)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 4)
+
+	names := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range spans {
+		assert.Equal(t, traceID, s.SpanContext().TraceID().String())
+		names[s.Name()] = s
+	}
+
+	server, ok := names["GET /v1/user/:id"]
+	require.True(t, ok)
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+	assert.Equal(t, server.SpanContext().SpanID(), names["repository.GetUserByID"].Parent().SpanID())
+	assert.Equal(t, server.SpanContext().SpanID(), names["repository.IsAuthorized"].Parent().SpanID())
+	assert.Empty(t, w.Header().Get("traceparent"), "traces aren't sent to clients")
+}
@@ -0,0 +1,312 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/totp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+	"rsc.io/qr"
+	"time"
+)
+
+const recoveryCodeCount = 10
+
+// defaultIssuer names the account in authenticator apps when no issuer is
+// configured.
+const defaultIssuer = "profileSaver"
+
+// getTwoFactor
+// @Summary Two-factor status
+// @Tags Me
+// @Description Show whether two-factor authentication is enabled or required for the authenticated user.
+// @Produce  json
+// @Security BasicAuth
+// @Success 200 {object} controller.TwoFactorStatusResponse
+// @Failure 500
+// @Router /v1/me/2fa [GET]
+func (h *Handler) getTwoFactor(w http.ResponseWriter, req bunrouter.Request) error {
+	user := authenticatedUser(req.Context())
+
+	t, err := h.totp.GetTOTP(req.Context(), user.ID)
+	if err != nil && !errors.Is(err, repository.ErrTOTPNotFound) {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, controller.TwoFactorStatusResponse{
+		Enabled:           t.Enabled,
+		Required:          h.twoFactorRequired(user),
+		RecoveryCodesLeft: len(t.RecoveryCodes),
+	})
+}
+
+// enrollTwoFactor
+// @Summary Start two-factor enrollment
+// @Tags Me
+// @Description Generate a TOTP secret for the authenticated user. Scan the QR code or enter the secret in an authenticator app, then confirm with a code. Enrolling again before confirming replaces the secret.
+// @Produce  json
+// @Security BasicAuth
+// @Success 200 {object} controller.TwoFactorEnrollResponse
+// @Failure 409
+// @Failure 500
+// @Router /v1/me/2fa/enroll [POST]
+func (h *Handler) enrollTwoFactor(w http.ResponseWriter, req bunrouter.Request) error {
+	user := authenticatedUser(req.Context())
+
+	enabled, err := h.twoFactorEnabled(req.Context(), user.ID)
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+	if enabled {
+		return h.responseJSON(w, req, http.StatusConflict, "two-factor authentication is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	err = h.totp.SaveTOTP(req.Context(), model.TOTP{UserID: user.ID, Secret: secret})
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	issuer := h.cfg.Get().TwoFactor.Issuer
+	if issuer == "" {
+		issuer = defaultIssuer
+	}
+	uri := totp.URI(issuer, user.Username, secret)
+
+	code, err := qr.Encode(uri, qr.M)
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, controller.TwoFactorEnrollResponse{
+		Secret: secret,
+		URI:    uri,
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(code.PNG()),
+	})
+}
+
+// confirmTwoFactor
+// @Summary Confirm two-factor enrollment
+// @Tags Me
+// @Description Enable two-factor authentication with a code from the authenticator app. Returns the recovery codes, they are shown only once.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param input body controller.TwoFactorCodeRequest true "code"
+// @Success 200 {object} controller.RecoveryCodesResponse
+// @Failure 400
+// @Failure 409
+// @Failure 500
+// @Router /v1/me/2fa/confirm [POST]
+func (h *Handler) confirmTwoFactor(w http.ResponseWriter, req bunrouter.Request) error {
+	var input controller.TwoFactorCodeRequest
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	user := authenticatedUser(req.Context())
+
+	t, err := h.totp.GetTOTP(req.Context(), user.ID)
+	if errors.Is(err, repository.ErrTOTPNotFound) {
+		return h.responseJSON(w, req, http.StatusBadRequest, "two-factor enrollment not started")
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+	if t.Enabled {
+		return h.responseJSON(w, req, http.StatusConflict, "two-factor authentication is already enabled")
+	}
+
+	step, ok := totp.Validate(t.Secret, input.Code, time.Now(), t.LastStep)
+	if !ok {
+		return h.responseJSON(w, req, http.StatusBadRequest, "invalid two-factor code")
+	}
+
+	t.Enabled = true
+	t.LastStep = step
+	if err = h.totp.SaveTOTP(req.Context(), t); err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.issueRecoveryCodes(w, req, user.ID)
+}
+
+// regenerateRecoveryCodes
+// @Summary Replace recovery codes
+// @Tags Me
+// @Description Replace all recovery codes after checking a TOTP or recovery code. The new codes are shown only once.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param input body controller.TwoFactorCodeRequest true "code"
+// @Success 200 {object} controller.RecoveryCodesResponse
+// @Failure 400
+// @Failure 500
+// @Router /v1/me/2fa/recovery-codes [POST]
+func (h *Handler) regenerateRecoveryCodes(w http.ResponseWriter, req bunrouter.Request) error {
+	user := authenticatedUser(req.Context())
+
+	if ok, err := h.checkTwoFactorRequest(w, req, user.ID); !ok {
+		return err
+	}
+
+	return h.issueRecoveryCodes(w, req, user.ID)
+}
+
+// disableTwoFactor
+// @Summary Disable two-factor authentication
+// @Tags Me
+// @Description Remove the TOTP secret and recovery codes after checking a TOTP or recovery code. Not allowed when the role of the user requires two-factor authentication.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param input body controller.TwoFactorCodeRequest true "code"
+// @Success 200
+// @Failure 400
+// @Failure 403
+// @Failure 500
+// @Router /v1/me/2fa [DELETE]
+func (h *Handler) disableTwoFactor(w http.ResponseWriter, req bunrouter.Request) error {
+	user := authenticatedUser(req.Context())
+
+	if h.twoFactorRequired(user) {
+		return h.responseJSON(w, req, http.StatusForbidden, "two-factor authentication is required for your role")
+	}
+
+	if ok, err := h.checkTwoFactorRequest(w, req, user.ID); !ok {
+		return err
+	}
+
+	if err := h.totp.DeleteTOTP(req.Context(), user.ID); err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, "two-factor authentication was disabled")
+}
+
+// resetTwoFactor
+// @Summary Reset user two-factor authentication
+// @Tags User
+// @Description Remove the TOTP secret and recovery codes of a user who lost their authenticator.
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "user id"
+// @Success 200
+// @Failure 404
+// @Failure 500
+// @Router /v1/user/{id}/2fa [DELETE]
+func (h *Handler) resetTwoFactor(w http.ResponseWriter, req bunrouter.Request) error {
+	id := req.Params().ByName("id")
+
+	target, err := h.tenantUser(req.Context(), id)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	if !h.mayManage(req.Context(), authenticatedUser(req.Context()), target, false) {
+		return h.responseJSON(w, req, http.StatusForbidden, errManageAdmins.Error())
+	}
+
+	err = h.totp.DeleteTOTP(req.Context(), id)
+	if errors.Is(err, repository.ErrTOTPNotFound) {
+		return h.responseJSON(w, req, http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, "two-factor authentication was reset")
+}
+
+// checkTwoFactorRequest decodes a TwoFactorCodeRequest and checks its code
+// against the enabled second factor of userID. When it returns false the
+// response has been written.
+func (h *Handler) checkTwoFactorRequest(w http.ResponseWriter, req bunrouter.Request, userID string) (bool, error) {
+	var input controller.TwoFactorCodeRequest
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		return false, h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	t, err := h.totp.GetTOTP(req.Context(), userID)
+	if errors.Is(err, repository.ErrTOTPNotFound) || err == nil && !t.Enabled {
+		return false, h.responseJSON(w, req, http.StatusBadRequest, "two-factor authentication is not enabled")
+	}
+	if err != nil {
+		return false, h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	if !h.verifySecondFactor(req.Context(), t, input.Code) {
+		return false, h.responseJSON(w, req, http.StatusBadRequest, "invalid two-factor code")
+	}
+
+	return true, nil
+}
+
+func (h *Handler) issueRecoveryCodes(w http.ResponseWriter, req bunrouter.Request, userID string) error {
+	codes, err := totp.RecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	if err = h.totp.SetRecoveryCodes(req.Context(), userID, codes); err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, controller.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// verifySecondFactor accepts a TOTP code not used before or a recovery code,
+// which is spent.
+func (h *Handler) verifySecondFactor(ctx context.Context, t model.TOTP, code string) bool {
+	if step, ok := totp.Validate(t.Secret, code, time.Now(), t.LastStep); ok {
+		return h.totp.UseTOTPStep(ctx, t.UserID, step)
+	}
+
+	return h.totp.UseRecoveryCode(ctx, t.UserID, code)
+}
+
+func (h *Handler) twoFactorEnabled(ctx context.Context, userID string) (bool, error) {
+	t, err := h.totp.GetTOTP(ctx, userID)
+	if errors.Is(err, repository.ErrTOTPNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return t.Enabled, nil
+}
+
+// twoFactorRequired reports whether the role of user requires two-factor
+// authentication. Service accounts can't enroll and are exempt.
+func (h *Handler) twoFactorRequired(user model.User) bool {
+	if user.ServiceAccount {
+		return false
+	}
+
+	role := config.RoleUser
+	if user.Admin {
+		role = config.RoleAdmin
+	}
+
+	for _, required := range h.cfg.Get().TwoFactor.RequiredRoles {
+		if required == role {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,154 @@
+package v1
+
+import (
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/totp"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_twoFactor(t *testing.T) {
+	s := newTestServer(t)
+
+	live := config.NewLive(config.Config{TwoFactor: config.TwoFactor{
+		Issuer:        "test",
+		RequiredRoles: []string{config.RoleAdmin},
+	}})
+	s.route(WithConfig(live), WithTOTP(s.repo))
+
+	// An auth is a testRequest carrying only credentials.
+	type auth = testRequest
+	basic := func(username, password string) auth {
+		return auth{username: username, password: password}
+	}
+	cookie := func(c *http.Cookie) auth {
+		return auth{cookie: c}
+	}
+
+	// do sends a request with auth, decoding the data of a successful answer
+	// into out if set.
+	do := func(method, target, body string, auth auth, out interface{}) *httptest.ResponseRecorder {
+		auth.method, auth.target, auth.body = method, target, body
+		w := s.do(auth)
+
+		if out != nil {
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &struct {
+				Data interface{} `json:"data"`
+			}{Data: out}))
+		}
+
+		return w
+	}
+
+	// enroll returns the secret and recovery codes of a confirmed enrollment.
+	enroll := func(auth auth) (string, []string) {
+		var enrollment struct {
+			Secret string `json:"secret"`
+			URI    string `json:"uri"`
+			QRCode string `json:"qr_code"`
+		}
+		do("POST", "/v1/me/2fa/enroll", "", auth, &enrollment)
+		assert.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/test:"))
+		assert.True(t, strings.HasPrefix(enrollment.QRCode, "data:image/png;base64,"))
+
+		w := do("POST", "/v1/me/2fa/confirm", `{"code":"x"}`, auth, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		code, err := totp.Code(enrollment.Secret, totp.Step(time.Now()))
+		require.NoError(t, err)
+
+		var codes struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}
+		do("POST", "/v1/me/2fa/confirm", `{"code":"`+code+`"}`, auth, &codes)
+		require.Len(t, codes.RecoveryCodes, 10)
+
+		return enrollment.Secret, codes.RecoveryCodes
+	}
+
+	login := func(username, password, code string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"username": username, "password": password, "code": code})
+
+		return do("POST", "/v1/auth/login", string(body), auth{}, nil)
+	}
+
+	t.Run("REQUIRED_FOR_ADMIN", func(t *testing.T) {
+		w := do("GET", "/v1/user", "", basic("admin", "admin"), nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, `{"error":"two-factor enrollment required"}
+`, w.Body.String())
+
+		assert.Equal(t, http.StatusOK, do("GET", "/v1/user", "", basic("bob", "bob-password"), nil).Code)
+	})
+
+	t.Run("ADMIN_LOGIN", func(t *testing.T) {
+		secret, recovery := enroll(basic("admin", "admin"))
+
+		assert.Equal(t, http.StatusUnauthorized, do("GET", "/v1/user", "", basic("admin", "admin"), nil).Code,
+			"basic auth is refused once two-factor authentication is enabled")
+
+		w := login("admin", "admin", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `{"error":"two-factor code required"}
+`, w.Body.String())
+
+		used, err := totp.Code(secret, totp.Step(time.Now()))
+		require.NoError(t, err)
+		w = login("admin", "admin", used)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, "a code is accepted once")
+		assert.Equal(t, `{"error":"invalid two-factor code"}
+`, w.Body.String())
+
+		w = login("admin", "admin", recovery[0])
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		session := cookie(w.Result().Cookies()[0])
+
+		assert.Equal(t, http.StatusUnauthorized, login("admin", "admin", recovery[0]).Code, "recovery codes are single-use")
+		assert.Equal(t, http.StatusOK, do("GET", "/v1/user", "", session, nil).Code)
+
+		var status struct {
+			Enabled           bool `json:"enabled"`
+			Required          bool `json:"required"`
+			RecoveryCodesLeft int  `json:"recovery_codes_left"`
+		}
+		do("GET", "/v1/me/2fa", "", session, &status)
+		assert.Equal(t, true, status.Enabled)
+		assert.Equal(t, true, status.Required)
+		assert.Equal(t, 9, status.RecoveryCodesLeft)
+
+		w = do("DELETE", "/v1/me/2fa", `{"code":"`+recovery[1]+`"}`, session, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		var codes struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}
+		do("POST", "/v1/me/2fa/recovery-codes", `{"code":"`+recovery[1]+`"}`, session, &codes)
+		assert.Len(t, codes.RecoveryCodes, 10)
+		assert.Equal(t, http.StatusUnauthorized, login("admin", "admin", recovery[2]).Code, "old codes are replaced")
+
+		assert.Equal(t, http.StatusNotFound, do("DELETE", "/v1/user/"+s.bob.ID+"/2fa", "", session, nil).Code)
+	})
+
+	t.Run("USER_DISABLE", func(t *testing.T) {
+		_, recovery := enroll(basic("bob", "bob-password"))
+
+		w := login("bob", "bob-password", recovery[0])
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		session := cookie(w.Result().Cookies()[0])
+
+		w = do("DELETE", "/v1/me/2fa", `{"code":"wrong"}`, session, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = do("DELETE", "/v1/me/2fa", `{"code":"`+recovery[1]+`"}`, session, nil)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		assert.Equal(t, http.StatusOK, do("GET", "/v1/user", "", basic("bob", "bob-password"), nil).Code)
+	})
+}
@@ -0,0 +1,170 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/notify"
+	"dev/profileSaver/internal/token"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultVerificationTTL applies when no verification.ttl is configured.
+const defaultVerificationTTL = 24 * time.Hour
+
+const routeResendVerification = "/v1/me/email/verify"
+
+// confirmEmail
+// @Summary Confirm email
+// @Tags Auth
+// @Description Mark the email of a user as verified with a token sent to it. Tokens work once and only for the address they were sent to.
+// @Accept  json
+// @Produce  json
+// @Param input body controller.VerifyEmailRequest true "token"
+// @Success 200
+// @Failure 400
+// @Failure 500
+// @Router /v1/auth/verify [POST]
+func (h *Handler) confirmEmail(w http.ResponseWriter, req bunrouter.Request) error {
+	var input controller.VerifyEmailRequest
+	if err := json.NewDecoder(req.Body).Decode(&input); err != nil {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	t, err := h.tokens.Consume(req.Context(), token.PurposeVerify, input.Token)
+	if errors.Is(err, token.ErrInvalid) {
+		return h.responseJSON(w, req, http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	user, err := h.repo.GetUserByID(req.Context(), t.UserID)
+	if err != nil || !strings.EqualFold(user.Email, t.Email) {
+		return h.responseJSON(w, req, http.StatusBadRequest, token.ErrInvalid.Error())
+	}
+
+	logger.WithUser(req.Context(), user.Username)
+
+	// An empty password keeps the stored hash.
+	user.Password = ""
+	user.EmailVerified = true
+
+	if err = h.repo.UpdateUser(req.Context(), user); err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	if err = h.tokens.Revoke(req.Context(), token.PurposeVerify, user.ID); err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, "email was verified")
+}
+
+// resendVerification
+// @Summary Resend email verification
+// @Tags Me
+// @Description Send a new verification token to the email of the authenticated user.
+// @Produce  json
+// @Security BasicAuth
+// @Success 200
+// @Failure 400
+// @Failure 500
+// @Router /v1/me/email/verify [POST]
+func (h *Handler) resendVerification(w http.ResponseWriter, req bunrouter.Request) error {
+	user := authenticatedUser(req.Context())
+
+	if user.EmailVerified {
+		return h.responseJSON(w, req, http.StatusBadRequest, "email is already verified")
+	}
+	if user.Email == "" {
+		return h.responseJSON(w, req, http.StatusBadRequest, "empty email")
+	}
+
+	if err := h.issueVerification(req.Context(), user); err != nil {
+		return h.responseJSON(w, req, http.StatusInternalServerError, err.Error())
+	}
+
+	return h.responseJSON(w, req, http.StatusOK, "verification token was sent")
+}
+
+// sendVerificationTo sends a verification token to the user named username.
+// Failures are logged, they must not fail the request that created the user.
+func (h *Handler) sendVerificationTo(ctx context.Context, username string) {
+	user, err := h.repo.GetUserByName(ctx, username)
+	if err != nil {
+		logger.FromContext(ctx).Error().Err(err).Str("user", username).Msg("unable to send verification token")
+		return
+	}
+
+	h.sendVerification(ctx, user)
+}
+
+// sendVerification is sendVerificationTo for a user at hand.
+func (h *Handler) sendVerification(ctx context.Context, user model.User) {
+	if user.Email == "" {
+		return
+	}
+
+	if err := h.issueVerification(ctx, user); err != nil {
+		logger.FromContext(ctx).Error().Err(err).Str("user", user.Username).Msg("unable to send verification token")
+	}
+}
+
+// issueVerification replaces pending verification tokens of user with a new
+// one and sends it.
+func (h *Handler) issueVerification(ctx context.Context, user model.User) error {
+	cfg := h.cfg.Get().Verification
+
+	ttl := cfg.TTL
+	if ttl <= 0 {
+		ttl = defaultVerificationTTL
+	}
+
+	if err := h.tokens.Revoke(ctx, token.PurposeVerify, user.ID); err != nil {
+		return err
+	}
+
+	t, err := h.tokens.Issue(ctx, token.PurposeVerify, user.ID, user.Email, ttl)
+	if err != nil {
+		return err
+	}
+
+	return h.notifier.Send(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Hello %s,\n\n"+
+			"confirm that this is your email address within %s with:\n\n"+
+			"%s\n",
+			user.Username, ttl, tokenLink(cfg.URL, t)),
+	})
+}
+
+// verificationBlocks reports whether an unverified email keeps user from
+// route. Admins are never blocked so they can't lock themselves out, nor are
+// service accounts, which have no email.
+func (h *Handler) verificationBlocks(user model.User, route string) bool {
+	if user.EmailVerified || user.Admin || user.ServiceAccount || route == routeResendVerification || route == routeLogout {
+		return false
+	}
+
+	cfg := h.cfg.Get().Verification
+	if cfg.BlockLogin {
+		return true
+	}
+
+	for _, prefix := range cfg.BlockRoutes {
+		if strings.HasPrefix(route, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,114 @@
+package v1
+
+import (
+	"context"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_verifyEmail(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer(t)
+
+	mail := &outbox{}
+	tokens := token.NewManager(s.repo)
+
+	route := func(cfg config.Verification) {
+		live := config.NewLive(config.Config{Verification: cfg})
+
+		s.route(WithConfig(live), WithNotifier(mail), WithTokens(tokens))
+	}
+
+	do := func(method, target, body string, username, password string) *httptest.ResponseRecorder {
+		return s.do(testRequest{method: method, target: target, body: body, username: username, password: password})
+	}
+
+	// sent returns the token of the only message sent since the last call.
+	sent := func(to string) string {
+		messages := mail.take()
+		require.Len(t, messages, 1)
+		assert.Equal(t, to, messages[0].To)
+
+		fields := strings.Fields(messages[0].Body)
+
+		return fields[len(fields)-1]
+	}
+
+	t.Run("BLOCK_ROUTES", func(t *testing.T) {
+		route(config.Verification{BlockRoutes: []string{"/v1/user"}})
+
+		w := do("POST", "/v1/user", `{"username":"erin","email":"erin@example.com","password":"erin-password"}`, "admin", "admin")
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		created := sent("erin@example.com")
+
+		w = do("GET", "/v1/user", "", "erin", "erin-password")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, `{"error":"email not verified"}
+`, w.Body.String())
+		assert.Equal(t, http.StatusOK, do("GET", "/v1/me/sessions", "", "erin", "erin-password").Code)
+
+		w = do("POST", "/v1/auth/verify", `{"token":"`+created+`"}`, "", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, http.StatusOK, do("GET", "/v1/user", "", "erin", "erin-password").Code)
+
+		w = do("POST", "/v1/auth/verify", `{"token":"`+created+`"}`, "", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, "tokens are single-use")
+
+		w = do("POST", "/v1/me/email/verify", "", "erin", "erin-password")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, `{"error":"email is already verified"}
+`, w.Body.String())
+	})
+
+	t.Run("EMAIL_CHANGE", func(t *testing.T) {
+		route(config.Verification{BlockRoutes: []string{"/v1/user"}})
+		erin, err := s.repo.GetUserByName(ctx, "erin")
+		require.NoError(t, err)
+		require.True(t, erin.EmailVerified)
+
+		w := do("PATCH", "/v1/user/"+erin.ID, `{"username":"erin","email":"robert@example.com","password":"erin-password"}`, "admin", "admin")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		changed := sent("robert@example.com")
+
+		assert.Equal(t, http.StatusForbidden, do("GET", "/v1/user", "", "erin", "erin-password").Code)
+
+		w = do("POST", "/v1/me/email/verify", "", "erin", "erin-password")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		resent := sent("robert@example.com")
+
+		w = do("POST", "/v1/auth/verify", `{"token":"`+changed+`"}`, "", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, "resending replaces the pending token")
+
+		w = do("POST", "/v1/auth/verify", `{"token":"`+resent+`"}`, "", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, http.StatusOK, do("GET", "/v1/user", "", "erin", "erin-password").Code)
+	})
+
+	t.Run("BLOCK_LOGIN", func(t *testing.T) {
+		route(config.Verification{BlockLogin: true})
+
+		w := do("POST", "/v1/user", `{"username":"carol","email":"carol@example.com","password":"carol-password"}`, "admin", "admin")
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		mail.take()
+
+		w = do("POST", "/v1/auth/login", `{"username":"carol","password":"carol-password"}`, "", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Equal(t, http.StatusForbidden, do("GET", "/v1/me/sessions", "", "carol", "carol-password").Code)
+		assert.Equal(t, http.StatusOK, do("GET", "/v1/me/sessions", "", "admin", "admin").Code, "admins are never blocked")
+
+		w = do("POST", "/v1/me/email/verify", "", "carol", "carol-password")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = do("POST", "/v1/auth/verify", `{"token":"`+sent("carol@example.com")+`"}`, "", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = do("POST", "/v1/auth/login", `{"username":"carol","password":"carol-password"}`, "", "")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+}
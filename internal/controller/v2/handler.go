@@ -0,0 +1,136 @@
+// Package v2 is the second version of the REST API. Every response with a
+// body has the same envelope: the resource in data or an error with a stable
+// code, and metadata such as the request ID and the page of a list. Created
+// resources are answered with 201 and their Location, deletions with 204.
+// Authentication and the user operations are those of v1.
+package v2
+
+import (
+	"dev/profileSaver/internal/controller"
+	v1 "dev/profileSaver/internal/controller/v1"
+	"dev/profileSaver/internal/logger"
+	"dev/profileSaver/internal/model"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+)
+
+// errorCodes name the statuses errors are answered with.
+var errorCodes = map[int]string{
+	http.StatusBadRequest:   "bad_request",
+	http.StatusUnauthorized: "unauthorized",
+	http.StatusForbidden:    "forbidden",
+	http.StatusNotFound:     "not_found",
+	http.StatusConflict:     "conflict",
+	// A reused Idempotency-Key.
+	http.StatusUnprocessableEntity: "unprocessable",
+}
+
+// Response is the envelope of every response with a body.
+type Response struct {
+	Data  interface{} `json:"data,omitempty"`
+	Error *Error      `json:"error,omitempty"`
+	Meta  Meta        `json:"meta"`
+}
+
+type Error struct {
+	// Code is bad_request, unauthorized, forbidden, not_found, conflict,
+	// unprocessable or internal.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type Meta struct {
+	// RequestID is the X-Request-ID of the request, to quote in bug reports.
+	RequestID string `json:"request_id"`
+	// Page is set for lists.
+	Page *controller.Page `json:"page,omitempty"`
+}
+
+type Handler struct {
+	core *v1.Handler
+}
+
+// New returns the v2 API acting through core.
+func New(core *v1.Handler) *Handler {
+	return &Handler{core: core}
+}
+
+// Routes registers the v2 API, see v1.Mount.
+func (h *Handler) Routes(auth *bunrouter.Group) {
+	auth.WithGroup("/v2", func(g *bunrouter.Group) {
+		userWrite := h.permissionMiddleware(model.PermissionUserWrite)
+
+		g.WithGroup("/users", func(g *bunrouter.Group) {
+			g.GET("", h.getUsers)
+			g.WithMiddleware(userWrite).WithMiddleware(h.core.Idempotency(h.failWith)).POST("", h.createUser)
+			g.GET("/:id", h.getUser)
+			g.WithMiddleware(userWrite).PATCH("/:id", h.updateUser)
+			g.WithMiddleware(userWrite).DELETE("/:id", h.deleteUser)
+		})
+	})
+}
+
+// permissionMiddleware answers 403 to users without permission.
+func (h *Handler) permissionMiddleware(permission string) bunrouter.MiddlewareFunc {
+	return func(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+		return func(w http.ResponseWriter, req bunrouter.Request) error {
+			if !h.core.Permitted(req.Context(), permission) {
+				return h.fail(w, req, http.StatusForbidden, "permission "+permission+" required")
+			}
+
+			return next(w, req)
+		}
+	}
+}
+
+// respond answers with data in the envelope.
+func (h *Handler) respond(w http.ResponseWriter, req bunrouter.Request, code int, data interface{}, page *controller.Page) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	return bunrouter.JSON(w, Response{
+		Data: data,
+		Meta: Meta{RequestID: w.Header().Get(logger.HeaderRequestID), Page: page},
+	})
+}
+
+// created answers 201 with the resource at path.
+func (h *Handler) created(w http.ResponseWriter, req bunrouter.Request, path string, data interface{}) error {
+	w.Header().Set("Location", v1.Location(req, path))
+
+	return h.respond(w, req, http.StatusCreated, data, nil)
+}
+
+// noContent answers 204.
+func (h *Handler) noContent(w http.ResponseWriter) error {
+	w.Header().Del("Content-Type")
+	w.WriteHeader(http.StatusNoContent)
+
+	return nil
+}
+
+// fail answers with an error in the envelope.
+func (h *Handler) fail(w http.ResponseWriter, req bunrouter.Request, code int, message string) error {
+	logger.FromContext(req.Context()).Warn().
+		Int("status", code).
+		Str("error", message).
+		Msg("request failed")
+
+	errCode, ok := errorCodes[code]
+	if !ok {
+		errCode = "internal"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	return bunrouter.JSON(w, Response{
+		Error: &Error{Code: errCode, Message: message},
+		Meta:  Meta{RequestID: w.Header().Get(logger.HeaderRequestID)},
+	})
+}
+
+// failWith answers err with the status v1 gives it.
+func (h *Handler) failWith(w http.ResponseWriter, req bunrouter.Request, err error) error {
+	return h.fail(w, req, v1.ErrorStatus(err), err.Error())
+}
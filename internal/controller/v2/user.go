@@ -0,0 +1,191 @@
+package v2
+
+import (
+	"dev/profileSaver/internal/controller"
+	v1 "dev/profileSaver/internal/controller/v1"
+	"dev/profileSaver/internal/model"
+	"encoding/json"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+)
+
+// UserPatch changes the fields it sets, the others are kept.
+type UserPatch struct {
+	Email    *string `json:"email,omitempty"`
+	Username *string `json:"username,omitempty"`
+	// Password, when set, signs the user out everywhere.
+	Password *string `json:"password,omitempty"`
+	Admin    *bool   `json:"admin,omitempty"`
+}
+
+// getUsers
+// @Summary List users
+// @Tags User v2
+// @Description List the users of the tenant ordered by username, a page at a time
+// @Produce  json
+// @Security BasicAuth
+// @Param limit query int false "page size, 1 to 200, default 50"
+// @Param offset query int false "users to skip"
+// @Success 200 {object} v2.Response{data=[]controller.UserResponse}
+// @Failure 400 {object} v2.Response
+// @Router /v2/users [GET]
+func (h *Handler) getUsers(w http.ResponseWriter, req bunrouter.Request) error {
+	page, err := v1.PageParams(req)
+	if err != nil {
+		return h.failWith(w, req, err)
+	}
+
+	users := h.core.Users(req.Context())
+	start, end := v1.PageBounds(&page, len(users))
+
+	response := make([]controller.UserResponse, 0, end-start)
+	for _, user := range users[start:end] {
+		response = append(response, userResponse(user))
+	}
+
+	return h.respond(w, req, http.StatusOK, response, &page)
+}
+
+// createUser
+// @Summary Create user
+// @Tags User v2
+// @Description Create a user in the tenant, needs the user:write permission. Answers the user as stored with its Location.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param Idempotency-Key header string false "key making retries safe"
+// @Param input body controller.UserRequest true "user"
+// @Success 201 {object} v2.Response{data=controller.UserResponse}
+// @Header 201 {string} Location "/v2/users/{id}"
+// @Failure 400 {object} v2.Response
+// @Failure 403 {object} v2.Response
+// @Failure 409 {object} v2.Response
+// @Failure 413 {object} v2.Response
+// @Failure 422 {object} v2.Response
+// @Router /v2/users [POST]
+func (h *Handler) createUser(w http.ResponseWriter, req bunrouter.Request) error {
+	body := req.Body
+	defer body.Close()
+
+	var newUser controller.UserRequest
+	if err := json.NewDecoder(body).Decode(&newUser); err != nil {
+		return h.fail(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	user, err := h.core.AddUser(req.Context(), newUser)
+	if err != nil {
+		return h.failWith(w, req, err)
+	}
+
+	return h.created(w, req, "/v2/users/"+user.ID, userResponse(user))
+}
+
+// getUser
+// @Summary Get user
+// @Tags User v2
+// @Description Get a user of the tenant by id
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "user id"
+// @Success 200 {object} v2.Response{data=controller.UserResponse}
+// @Failure 404 {object} v2.Response
+// @Router /v2/users/{id} [GET]
+func (h *Handler) getUser(w http.ResponseWriter, req bunrouter.Request) error {
+	user, err := h.core.User(req.Context(), req.Params().ByName("id"))
+	if err != nil {
+		return h.failWith(w, req, err)
+	}
+
+	return h.respond(w, req, http.StatusOK, userResponse(user), nil)
+}
+
+// updateUser
+// @Summary Update user
+// @Tags User v2
+// @Description Change the fields of a user the patch sets, needs the user:write permission. A new password signs the user out everywhere, a new email has to be verified again. Answers the user as stored.
+// @Accept  json
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "user id"
+// @Param input body v2.UserPatch true "changes"
+// @Success 200 {object} v2.Response{data=controller.UserResponse}
+// @Failure 400 {object} v2.Response
+// @Failure 403 {object} v2.Response
+// @Failure 404 {object} v2.Response
+// @Failure 409 {object} v2.Response
+// @Router /v2/users/{id} [PATCH]
+func (h *Handler) updateUser(w http.ResponseWriter, req bunrouter.Request) error {
+	body := req.Body
+	defer body.Close()
+
+	var patch UserPatch
+	if err := json.NewDecoder(body).Decode(&patch); err != nil {
+		return h.fail(w, req, http.StatusBadRequest, err.Error())
+	}
+
+	id := req.Params().ByName("id")
+
+	old, err := h.core.User(req.Context(), id)
+	if err != nil {
+		return h.failWith(w, req, err)
+	}
+
+	change := controller.UserRequest{
+		Email:    old.Email,
+		Username: old.Username,
+		Admin:    old.Admin,
+	}
+	if patch.Email != nil {
+		change.Email = *patch.Email
+	}
+	if patch.Username != nil {
+		change.Username = *patch.Username
+	}
+	if patch.Password != nil {
+		change.Password = *patch.Password
+	}
+	if patch.Admin != nil {
+		change.Admin = *patch.Admin
+	}
+
+	user, err := h.core.EditUser(req.Context(), id, change)
+	if err != nil {
+		return h.failWith(w, req, err)
+	}
+
+	return h.respond(w, req, http.StatusOK, userResponse(user), nil)
+}
+
+// deleteUser
+// @Summary Delete user
+// @Tags User v2
+// @Description Delete a user of the tenant, needs the user:write permission
+// @Produce  json
+// @Security BasicAuth
+// @Param id path string true "user id"
+// @Success 204
+// @Failure 403 {object} v2.Response
+// @Failure 404 {object} v2.Response
+// @Router /v2/users/{id} [DELETE]
+func (h *Handler) deleteUser(w http.ResponseWriter, req bunrouter.Request) error {
+	if err := h.core.RemoveUser(req.Context(), req.Params().ByName("id")); err != nil {
+		return h.failWith(w, req, err)
+	}
+
+	return h.noContent(w)
+}
+
+func userResponse(user model.User) controller.UserResponse {
+	return controller.UserResponse{
+		ID:             user.ID,
+		Email:          user.Email,
+		Username:       user.Username,
+		Admin:          user.Admin,
+		EmailVerified:  user.EmailVerified,
+		Tenant:         user.Tenant,
+		ServiceAccount: user.ServiceAccount,
+		Disabled:       user.Disabled,
+		CreatedAt:      user.CreatedAt,
+		UpdatedAt:      user.UpdatedAt,
+	}
+}
@@ -0,0 +1,200 @@
+package v2
+
+import (
+	"bytes"
+	"context"
+	"dev/profileSaver/internal/controller"
+	v1 "dev/profileSaver/internal/controller/v1"
+	"dev/profileSaver/internal/idempotency"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_users(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.New(repository.WithHashParams(repository.HashParams{
+		Time: 1, Memory: 64, Threads: 1, KeyLen: 16, SaltLen: 8,
+	}))
+	_, err := repo.CreateUser(ctx, model.User{Username: "admin", Email: "admin@example.com", Password: "admin", Admin: true})
+	require.NoError(t, err)
+	_, err = repo.CreateUser(ctx, model.User{Username: "bob", Email: "bob@example.com", Password: "bob-password"})
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateTenant(ctx, model.Tenant{ID: "acme", Name: "Acme"}))
+
+	core := v1.New(repo, v1.WithGroups(repo), v1.WithTenants(repo))
+	router := core.InitRouter(New(core).Routes)
+
+	do := func(method, target, body, username, password string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.SetBasicAuth(username, password)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	type envelope struct {
+		Data  json.RawMessage `json:"data"`
+		Error *Error          `json:"error"`
+		Meta  Meta            `json:"meta"`
+	}
+
+	decode := func(t *testing.T, w *httptest.ResponseRecorder, code int, data interface{}) envelope {
+		require.Equal(t, code, w.Code, w.Body.String())
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var resp envelope
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.NotEmpty(t, resp.Meta.RequestID)
+		assert.Equal(t, w.Header().Get("X-Request-ID"), resp.Meta.RequestID)
+
+		if data != nil {
+			require.NoError(t, json.Unmarshal(resp.Data, data))
+		}
+
+		return resp
+	}
+
+	var alice controller.UserResponse
+
+	t.Run("CREATE", func(t *testing.T) {
+		body := `{"username": "alice", "email": "alice@example.com", "password": "alice-password"}`
+		w := do("POST", "/v2/users", body, "admin", "admin", idempotency.Header, "create-alice")
+		decode(t, w, http.StatusCreated, &alice)
+		assert.NotEmpty(t, alice.ID)
+		assert.Equal(t, "alice", alice.Username)
+		assert.False(t, alice.CreatedAt.IsZero())
+		assert.Equal(t, "/v2/users/"+alice.ID, w.Header().Get("Location"))
+
+		retry := do("POST", "/v2/users", body, "admin", "admin", idempotency.Header, "create-alice")
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, w.Body.String(), retry.Body.String())
+		assert.Equal(t, "true", retry.Header().Get(idempotency.HeaderReplayed))
+
+		other := do("POST", "/v2/users", `{"username": "dave", "email": "dave@example.com", "password": "dave-password"}`,
+			"admin", "admin", idempotency.Header, "create-alice")
+		resp := decode(t, other, http.StatusUnprocessableEntity, nil)
+		assert.Equal(t, "unprocessable", resp.Error.Code)
+
+		w = do("POST", "/t/acme/v2/users", `{"username": "carol", "email": "carol@acme.test", "password": "carol-password"}`,
+			"admin", "admin")
+		var carol controller.UserResponse
+		decode(t, w, http.StatusCreated, &carol)
+		assert.Equal(t, "acme", carol.Tenant)
+		assert.Equal(t, "/t/acme/v2/users/"+carol.ID, w.Header().Get("Location"))
+	})
+
+	t.Run("LIST", func(t *testing.T) {
+		var users []controller.UserResponse
+		resp := decode(t, do("GET", "/v2/users?limit=2&offset=1", "", "bob", "bob-password"), http.StatusOK, &users)
+		require.Len(t, users, 2)
+		assert.Equal(t, "alice", users[0].Username)
+		assert.Equal(t, "bob", users[1].Username)
+		assert.Equal(t, &controller.Page{Total: 3, Limit: 2, Offset: 1}, resp.Meta.Page)
+
+		resp = decode(t, do("GET", "/v2/users?offset=10", "", "bob", "bob-password"), http.StatusOK, &users)
+		assert.Empty(t, users)
+		assert.Equal(t, "[]", string(resp.Data))
+	})
+
+	t.Run("GET", func(t *testing.T) {
+		var user controller.UserResponse
+		decode(t, do("GET", "/v2/users/"+alice.ID, "", "bob", "bob-password"), http.StatusOK, &user)
+		assert.Equal(t, alice, user)
+	})
+
+	t.Run("UPDATE", func(t *testing.T) {
+		var user controller.UserResponse
+		decode(t, do("PATCH", "/v2/users/"+alice.ID, `{"email": "alice@example.org"}`, "admin", "admin"),
+			http.StatusOK, &user)
+		assert.Equal(t, "alice", user.Username)
+		assert.Equal(t, "alice@example.org", user.Email)
+		assert.True(t, repo.IsAuthorized(ctx, "alice", "alice-password"), "the password is kept")
+
+		decode(t, do("PATCH", "/v2/users/"+alice.ID, `{"password": "alice-password-2"}`, "admin", "admin"),
+			http.StatusOK, &user)
+		assert.True(t, repo.IsAuthorized(ctx, "alice", "alice-password-2"))
+	})
+
+	t.Run("DELETE", func(t *testing.T) {
+		w := do("DELETE", "/v2/users/"+alice.ID, "", "admin", "admin")
+		require.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Empty(t, w.Header().Get("Content-Type"))
+
+		resp := decode(t, do("GET", "/v2/users/"+alice.ID, "", "admin", "admin"), http.StatusNotFound, nil)
+		assert.Equal(t, "not_found", resp.Error.Code)
+	})
+
+	t.Run("NOT_OK", func(t *testing.T) {
+		tests := []struct {
+			name                   string
+			method, target, body   string
+			username, password     string
+			code                   int
+			errorCode, errorPrefix string
+		}{
+			{
+				name:   "NO_PERMISSION",
+				method: "POST", target: "/v2/users",
+				body:     `{"username": "dave", "email": "dave@example.com", "password": "dave-password"}`,
+				username: "bob", password: "bob-password",
+				code: http.StatusForbidden, errorCode: "forbidden", errorPrefix: "permission user:write",
+			},
+			{
+				name:   "INVALID_USER",
+				method: "POST", target: "/v2/users",
+				body:     `{"username": "dave", "email": "dave@example.com"}`,
+				username: "admin", password: "admin",
+				code: http.StatusBadRequest, errorCode: "bad_request", errorPrefix: "empty password",
+			},
+			{
+				name:   "INVALID_JSON",
+				method: "POST", target: "/v2/users",
+				body:     `{`,
+				username: "admin", password: "admin",
+				code: http.StatusBadRequest, errorCode: "bad_request",
+			},
+			{
+				name:   "UNKNOWN_USER",
+				method: "PATCH", target: "/v2/users/unknown",
+				body:     `{"username": "bob"}`,
+				username: "admin", password: "admin",
+				code: http.StatusNotFound, errorCode: "not_found",
+			},
+			{
+				name:   "DUPLICATE",
+				method: "POST", target: "/v2/users",
+				body:     `{"username": "bob", "email": "bob@example.com", "password": "bob-password"}`,
+				username: "admin", password: "admin",
+				code: http.StatusConflict, errorCode: "conflict",
+			},
+			{
+				name:   "INVALID_PAGE",
+				method: "GET", target: "/v2/users?limit=0",
+				username: "admin", password: "admin",
+				code: http.StatusBadRequest, errorCode: "bad_request",
+			},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				resp := decode(t, do(test.method, test.target, test.body, test.username, test.password), test.code, nil)
+				require.NotNil(t, resp.Error)
+				assert.Equal(t, test.errorCode, resp.Error.Code)
+				assert.Contains(t, resp.Error.Message, test.errorPrefix)
+				assert.Empty(t, resp.Data)
+			})
+		}
+	})
+}
@@ -0,0 +1,230 @@
+package ctl
+
+import (
+	"bytes"
+	"context"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/controller"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/tenant"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnsupported      = errors.New("not supported over the HTTP API, run against the storage backend instead")
+	ErrPasswordRequired = errors.New("the HTTP API only updates users together with a password, pass -password")
+)
+
+// Client is the set of operations profilectl needs. It is implemented on top
+// of the storage backend and on top of the HTTP API.
+type Client interface {
+	List(ctx context.Context) ([]model.User, error)
+	Create(ctx context.Context, u model.User) error
+	// Update replaces u, an empty password keeps the current one.
+	Update(ctx context.Context, u model.User) error
+	Delete(ctx context.Context, id string) error
+	// Import restores an exported record.
+	Import(ctx context.Context, u model.User) error
+	Verify(ctx context.Context) ([]string, error)
+}
+
+// directStore is what the persistent backends offer beyond Storage.
+type directStore interface {
+	repository.Storage
+	RestoreUser(ctx context.Context, u model.User) error
+	Verify(ctx context.Context) []string
+}
+
+type directClient struct {
+	store directStore
+}
+
+// NewDirect opens the configured storage backend. The in-memory backend is
+// refused since its users only live inside the running service.
+func NewDirect(cfg config.Config) (Client, error) {
+	if cfg.Storage.Backend == config.StorageMemory {
+		return nil, errors.New("storage backend memory is only reachable through the running service, use -api")
+	}
+
+	repo, err := repository.Open(cfg.Storage,
+		repository.WithHashParams(repository.HashParamsFromConfig(cfg.Hashing)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	store, ok := repo.(directStore)
+	if !ok {
+		return nil, fmt.Errorf("storage backend %s doesn't support direct access", cfg.Storage.Backend)
+	}
+
+	return &directClient{store: store}, nil
+}
+
+func (c *directClient) List(ctx context.Context) ([]model.User, error) {
+	return c.store.GetAllUsers(ctx), nil
+}
+
+func (c *directClient) Create(ctx context.Context, u model.User) error {
+	_, err := c.store.CreateUser(ctx, u)
+	return err
+}
+
+// Update also signs the user out everywhere when a password is set.
+func (c *directClient) Update(ctx context.Context, u model.User) error {
+	if err := c.store.UpdateUser(ctx, u); err != nil {
+		return err
+	}
+
+	if u.Password == "" {
+		return nil
+	}
+
+	return c.store.DeleteSessionsByUser(ctx, u.ID)
+}
+
+func (c *directClient) Delete(ctx context.Context, id string) error {
+	return c.store.DeleteUser(ctx, id)
+}
+
+func (c *directClient) Import(ctx context.Context, u model.User) error {
+	return c.store.RestoreUser(ctx, u)
+}
+
+func (c *directClient) Verify(ctx context.Context) ([]string, error) {
+	return c.store.Verify(ctx), nil
+}
+
+type httpClient struct {
+	base     string
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewHTTP talks to a running service at base with Basic auth. Requests name
+// the tenant of their context in the X-Tenant header.
+func NewHTTP(base, username, password string) (Client, error) {
+	if _, err := url.ParseRequestURI(base); err != nil {
+		return nil, fmt.Errorf("invalid api url: %w", err)
+	}
+
+	return &httpClient{
+		base:     strings.TrimSuffix(base, "/"),
+		username: username,
+		password: password,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (c *httpClient) List(ctx context.Context) ([]model.User, error) {
+	var resp []controller.UserResponse
+	if err := c.do(ctx, http.MethodGet, "/v1/user", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	users := make([]model.User, 0, len(resp))
+	for _, u := range resp {
+		users = append(users, model.User{
+			ID:            u.ID,
+			Email:         u.Email,
+			Username:      u.Username,
+			Admin:         u.Admin,
+			EmailVerified: u.EmailVerified,
+		})
+	}
+
+	return users, nil
+}
+
+func (c *httpClient) Create(ctx context.Context, u model.User) error {
+	return c.do(ctx, http.MethodPost, "/v1/user", userRequest(u), nil)
+}
+
+func (c *httpClient) Update(ctx context.Context, u model.User) error {
+	if u.Password == "" {
+		return ErrPasswordRequired
+	}
+
+	return c.do(ctx, http.MethodPatch, "/v1/user/"+url.PathEscape(u.ID), userRequest(u), nil)
+}
+
+func (c *httpClient) Delete(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/v1/user/"+url.PathEscape(id), nil, nil)
+}
+
+// Import creates the user again, which needs a plain text password in the
+// record since hashes can't be sent through the API.
+func (c *httpClient) Import(ctx context.Context, u model.User) error {
+	if u.Password == "" || repository.IsHashed(u) {
+		return fmt.Errorf("import %q: %w", u.Username, ErrPasswordRequired)
+	}
+
+	return c.Create(ctx, u)
+}
+
+func (c *httpClient) Verify(context.Context) ([]string, error) {
+	return nil, ErrUnsupported
+}
+
+func (c *httpClient) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, &payload)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Content-Type", "application/json")
+	if id := tenant.FromContext(ctx); id != tenant.Default {
+		req.Header.Set(tenant.Header, id)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Data  json.RawMessage `json:"data"`
+		Error json.RawMessage `json:"error"`
+	}
+	// Auth failures come without a body.
+	_ = json.NewDecoder(resp.Body).Decode(&envelope)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		if len(envelope.Error) != 0 {
+			return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, envelope.Error)
+		}
+
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+
+	if out == nil || len(envelope.Data) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(envelope.Data, out)
+}
+
+func userRequest(u model.User) controller.UserRequest {
+	return controller.UserRequest{
+		Email:    u.Email,
+		Username: u.Username,
+		Password: u.Password,
+		Admin:    u.Admin,
+	}
+}
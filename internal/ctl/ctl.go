@@ -0,0 +1,361 @@
+package ctl
+
+import (
+	"context"
+	"dev/profileSaver/internal/bootstrap"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/tenant"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+const usage = `usage: profilectl [global flags] <command> [flags]
+
+Without -api the command works on the storage backend from the config
+(CONFIG_FILE or ./config.*). Stop the service first when doing so: it does not
+see changes made behind its back and may overwrite them. Commands act on the
+users of the tenant given by -tenant, the default tenant without it.
+
+commands:
+  list             list users
+  create           create a user
+  update           change username, email or password of a user
+  delete           delete a user
+  reset-password   set a new password, generated and printed when not given
+  promote          make a user an admin
+  export           write all users as JSON
+  import           restore users from an export
+  verify           check the storage for inconsistencies
+
+global flags:
+`
+
+var ErrVerifyFailed = errors.New("storage verification failed")
+
+type cli struct {
+	client Client
+	direct bool
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+// Run executes profilectl with args (without the program name) and returns
+// the process exit code.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("profilectl", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		global.PrintDefaults()
+	}
+
+	configPath := global.String("config", os.Getenv(config.EnvConfigFile), "config file for direct storage access")
+	api := global.String("api", "", "base URL of a running service, e.g. http://localhost:8080")
+	user := global.String("user", "admin", "username for -api")
+	password := global.String("password", os.Getenv("PROFILECTL_PASSWORD"), "password for -api (or PROFILECTL_PASSWORD)")
+	tenantID := global.String("tenant", "", "tenant to act on, empty for the default tenant")
+
+	if err := global.Parse(args); err != nil {
+		return 2
+	}
+
+	if global.NArg() == 0 {
+		global.Usage()
+		return 2
+	}
+
+	c := &cli{stdin: stdin, stdout: stdout}
+
+	var err error
+	if *api != "" {
+		c.client, err = NewHTTP(*api, *user, *password)
+	} else {
+		var cfg config.Config
+
+		cfg, err = config.Load(*configPath)
+		if err == nil {
+			c.client, err = NewDirect(cfg)
+			c.direct = true
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "profilectl:", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	if *tenantID != "" {
+		ctx = tenant.WithID(ctx, *tenantID)
+	}
+
+	err = c.run(ctx, global.Arg(0), global.Args()[1:], stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "profilectl:", err)
+		return 1
+	}
+
+	return 0
+}
+
+func (c *cli) run(ctx context.Context, command string, args []string, stderr io.Writer) error {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	switch command {
+	case "list":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+
+		return c.list(ctx)
+	case "create":
+		username := fs.String("username", "", "username")
+		email := fs.String("email", "", "email")
+		password := fs.String("password", "", "password, generated and printed when empty")
+		admin := fs.Bool("admin", false, "make the user an admin")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+
+		return c.create(ctx, model.User{Username: *username, Email: *email, Password: *password, Admin: *admin})
+	case "update":
+		username := fs.String("username", "", "user to update")
+		newUsername := fs.String("new-username", "", "new username")
+		email := fs.String("email", "", "new email")
+		password := fs.String("password", "", "new password")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+
+		return c.update(ctx, *username, func(u *model.User) {
+			if *newUsername != "" {
+				u.Username = *newUsername
+			}
+			if *email != "" && *email != u.Email {
+				u.Email = *email
+				u.EmailVerified = false
+			}
+			u.Password = *password
+		})
+	case "delete":
+		username := fs.String("username", "", "user to delete")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+
+		u, err := c.find(ctx, *username)
+		if err != nil {
+			return err
+		}
+
+		return c.client.Delete(ctx, u.ID)
+	case "reset-password":
+		username := fs.String("username", "", "user whose password is reset")
+		password := fs.String("password", "", "new password, generated and printed when empty")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+
+		return c.resetPassword(ctx, *username, *password)
+	case "promote":
+		username := fs.String("username", "", "user to promote")
+		password := fs.String("password", "", "new password, needed with -api")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+
+		return c.update(ctx, *username, func(u *model.User) {
+			u.Admin = true
+			u.Password = *password
+		})
+	case "export":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+
+		return c.export(ctx)
+	case "import":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+
+		return c.importUsers(ctx)
+	case "verify":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+
+		return c.verify(ctx)
+	default:
+		return fmt.Errorf("unknown command %q, run profilectl -h for help", command)
+	}
+}
+
+func (c *cli) list(ctx context.Context) error {
+	users, err := c.client.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSERNAME\tEMAIL\tADMIN")
+	for _, u := range users {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", u.ID, u.Username, u.Email, u.Admin)
+	}
+
+	return w.Flush()
+}
+
+func (c *cli) create(ctx context.Context, u model.User) error {
+	if u.Username == "" {
+		return errors.New("-username is required")
+	}
+
+	generated, err := c.fillPassword(&u.Password)
+	if err != nil {
+		return err
+	}
+
+	u.Tenant = tenant.FromContext(ctx)
+
+	if err = c.client.Create(ctx, u); err != nil {
+		return err
+	}
+
+	if generated {
+		fmt.Fprintf(c.stdout, "password for %s: %s\n", u.Username, u.Password)
+	}
+
+	return nil
+}
+
+func (c *cli) update(ctx context.Context, username string, change func(u *model.User)) error {
+	u, err := c.find(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	change(&u)
+
+	return c.client.Update(ctx, u)
+}
+
+// resetPassword sets a new password. Against the storage backend the user also
+// has to change it on the next login.
+func (c *cli) resetPassword(ctx context.Context, username, password string) error {
+	generated, err := c.fillPassword(&password)
+	if err != nil {
+		return err
+	}
+
+	err = c.update(ctx, username, func(u *model.User) {
+		u.Password = password
+		u.MustChangePassword = c.direct
+	})
+	if err != nil {
+		return err
+	}
+
+	if generated {
+		fmt.Fprintf(c.stdout, "password for %s: %s\n", username, password)
+	}
+
+	return nil
+}
+
+func (c *cli) export(ctx context.Context) error {
+	users, err := c.client.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(users)
+}
+
+func (c *cli) importUsers(ctx context.Context) error {
+	var users []model.User
+	if err := json.NewDecoder(c.stdin).Decode(&users); err != nil {
+		return fmt.Errorf("decode import: %w", err)
+	}
+
+	for _, u := range users {
+		if err := c.client.Import(ctx, u); err != nil {
+			return fmt.Errorf("import %q: %w", u.Username, err)
+		}
+	}
+
+	fmt.Fprintf(c.stdout, "imported %d users\n", len(users))
+
+	return nil
+}
+
+func (c *cli) verify(ctx context.Context) error {
+	problems, err := c.client.Verify(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range problems {
+		fmt.Fprintln(c.stdout, p)
+	}
+
+	if len(problems) != 0 {
+		return ErrVerifyFailed
+	}
+
+	fmt.Fprintln(c.stdout, "ok")
+
+	return nil
+}
+
+func (c *cli) find(ctx context.Context, username string) (model.User, error) {
+	if username == "" {
+		return model.User{}, errors.New("-username is required")
+	}
+
+	users, err := c.client.List(ctx)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	for _, u := range users {
+		if u.Username == username {
+			// The stored hash must not be sent back as a new password.
+			u.Password = ""
+			return u, nil
+		}
+	}
+
+	return model.User{}, fmt.Errorf("user %q not found", username)
+}
+
+func (c *cli) fillPassword(password *string) (bool, error) {
+	if *password != "" {
+		return false, nil
+	}
+
+	generated, err := bootstrap.GeneratePassword()
+	if err != nil {
+		return false, err
+	}
+	*password = generated
+
+	return true, nil
+}
@@ -0,0 +1,199 @@
+package ctl
+
+import (
+	"bytes"
+	"context"
+	"dev/profileSaver/internal/config"
+	v1 "dev/profileSaver/internal/controller/v1"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var testHash = repository.HashParams{Time: 1, Memory: 64, Threads: 1, KeyLen: 16, SaltLen: 8}
+
+func run(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := Run(args, strings.NewReader(stdin), &stdout, &stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func writeConfig(t *testing.T, dir, dsn string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+storage:
+  backend: file
+  dsn: `+dsn+`
+hashing:
+  time: 1
+  memory: 64
+  threads: 1
+  key_len: 16
+  salt_len: 8
+`), 0o600))
+
+	return path
+}
+
+func TestRun_Direct(t *testing.T) {
+	dir := t.TempDir()
+	dsn := filepath.Join(dir, "users.json")
+	cfg := writeConfig(t, dir, dsn)
+
+	code, _, stderr := run(t, "", "-config", cfg, "create", "-username", "alice", "-email", "a@example.com", "-password", "alice-pass")
+	require.Equal(t, 0, code, stderr)
+
+	code, _, stderr = run(t, "", "-config", cfg, "promote", "-username", "alice")
+	require.Equal(t, 0, code, stderr)
+
+	code, stdout, stderr := run(t, "", "-config", cfg, "reset-password", "-username", "alice")
+	require.Equal(t, 0, code, stderr)
+	m := regexp.MustCompile(`password for alice: (\S+)`).FindStringSubmatch(stdout)
+	require.Len(t, m, 2)
+
+	db, err := repository.NewFile(dsn, repository.WithHashParams(testHash))
+	require.NoError(t, err)
+	assert.True(t, db.IsAuthorized(context.Background(), "alice", m[1]))
+
+	alice, err := db.GetUserByName(context.Background(), "alice")
+	require.NoError(t, err)
+	assert.True(t, alice.Admin)
+	assert.True(t, alice.MustChangePassword)
+	assert.Equal(t, "a@example.com", alice.Email)
+
+	code, stdout, _ = run(t, "", "-config", cfg, "list")
+	require.Equal(t, 0, code)
+	assert.Contains(t, stdout, "alice")
+
+	code, stdout, _ = run(t, "", "-config", cfg, "verify")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "ok\n", stdout)
+
+	code, export, _ := run(t, "", "-config", cfg, "export")
+	require.Equal(t, 0, code)
+
+	restored := filepath.Join(t.TempDir(), "restored.json")
+	restoredCfg := writeConfig(t, filepath.Dir(restored), restored)
+
+	code, stdout, stderr = run(t, export, "-config", restoredCfg, "import")
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "imported 1 users\n", stdout)
+
+	db, err = repository.NewFile(restored, repository.WithHashParams(testHash))
+	require.NoError(t, err)
+	assert.True(t, db.IsAuthorized(context.Background(), "alice", m[1]))
+
+	code, _, _ = run(t, "", "-config", cfg, "delete", "-username", "alice")
+	require.Equal(t, 0, code)
+
+	code, stdout, _ = run(t, "", "-config", cfg, "delete", "-username", "alice")
+	assert.Equal(t, 1, code)
+	assert.Empty(t, stdout)
+}
+
+func TestRun_DirectVerifyFails(t *testing.T) {
+	dir := t.TempDir()
+	dsn := filepath.Join(dir, "users.json")
+	require.NoError(t, os.WriteFile(dsn, []byte(`{"users":[{"id":"1","username":"bob","password":"zz"}]}`), 0o600))
+
+	code, stdout, stderr := run(t, "", "-config", writeConfig(t, dir, dsn), "verify")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, `no admin user
+user "1" has no salt
+user "1" has no valid password hash
+`, stdout)
+	assert.Contains(t, stderr, ErrVerifyFailed.Error())
+}
+
+func TestRun_DirectMemoryRefused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("storage:\n  backend: memory\n"), 0o600))
+
+	code, _, stderr := run(t, "", "-config", path, "list")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "-api")
+}
+
+func TestRun_HTTP(t *testing.T) {
+	repo := repository.New(repository.WithHashParams(testHash))
+	_, err := repo.CreateUser(context.Background(), model.User{
+		Username: "admin", Email: "admin", Password: "admin-pass", Admin: true,
+	})
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(v1.New(repo, v1.WithConfig(config.NewLive(config.Config{}))).InitRouter())
+	defer srv.Close()
+
+	api := []string{"-api", srv.URL, "-user", "admin", "-password", "admin-pass"}
+
+	code, _, stderr := run(t, "", append(api, "create", "-username", "bob", "-email", "b@example.com", "-password", "bob-pass")...)
+	require.Equal(t, 0, code, stderr)
+
+	code, stdout, _ := run(t, "", append(api, "list")...)
+	require.Equal(t, 0, code)
+	assert.Contains(t, stdout, "bob")
+	assert.Contains(t, stdout, "b@example.com")
+
+	code, _, stderr = run(t, "", append(api, "promote", "-username", "bob")...)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, ErrPasswordRequired.Error())
+
+	code, _, stderr = run(t, "", append(api, "promote", "-username", "bob", "-password", "bob-pass-2")...)
+	require.Equal(t, 0, code, stderr)
+
+	bob, err := repo.GetUserByName(context.Background(), "bob")
+	require.NoError(t, err)
+	assert.True(t, bob.Admin)
+	assert.True(t, repo.IsAuthorized(context.Background(), "bob", "bob-pass-2"))
+
+	code, _, stderr = run(t, "", append(api, "verify")...)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, ErrUnsupported.Error())
+
+	code, _, stderr = run(t, "", "-api", srv.URL, "-user", "admin", "-password", "wrong", "list")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "401")
+}
+
+func TestRun_Tenant(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.New(repository.WithHashParams(testHash))
+	_, err := repo.CreateUser(ctx, model.User{
+		Username: "admin", Email: "admin", Password: "admin-pass", Admin: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateTenant(ctx, model.Tenant{ID: "acme"}))
+
+	srv := httptest.NewServer(v1.New(repo, v1.WithConfig(config.NewLive(config.Config{})), v1.WithTenants(repo)).InitRouter())
+	defer srv.Close()
+
+	api := []string{"-api", srv.URL, "-user", "admin", "-password", "admin-pass", "-tenant", "acme"}
+
+	code, _, stderr := run(t, "", append(api, "create", "-username", "bob", "-email", "b@example.com", "-password", "bob-pass")...)
+	require.Equal(t, 0, code, stderr)
+
+	code, stdout, _ := run(t, "", append(api, "list")...)
+	require.Equal(t, 0, code)
+	assert.Contains(t, stdout, "bob")
+	assert.NotContains(t, stdout, "admin")
+
+	bob, err := repo.GetUserByName(tenant.WithID(ctx, "acme"), "bob")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", bob.Tenant)
+
+	_, err = repo.GetUserByName(ctx, "bob")
+	assert.Equal(t, repository.ErrUserNotFound, err)
+}
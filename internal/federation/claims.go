@@ -0,0 +1,92 @@
+package federation
+
+import (
+	"dev/profileSaver/internal/config"
+	"encoding/json"
+	"fmt"
+)
+
+// profile holds the user fields taken from the claims of a login.
+type profile struct {
+	subject       string
+	username      string
+	email         string
+	emailVerified bool
+	groups        []string
+}
+
+// mapClaims reads the fields of a user from claims as m names them.
+func mapClaims(claims map[string]interface{}, m config.ClaimMapping) profile {
+	p := profile{
+		subject:       claimString(claims["sub"]),
+		username:      claimString(claims[m.Username]),
+		email:         claimString(claims[m.Email]),
+		emailVerified: claimBool(claims[m.EmailVerified]),
+		groups:        claimStrings(claims[m.Groups]),
+	}
+	if p.username == "" {
+		p.username = p.email
+	}
+
+	return p
+}
+
+// withDefaults fills in the standard claims for names left empty.
+func withDefaults(m config.ClaimMapping) config.ClaimMapping {
+	if m.Username == "" {
+		m.Username = "preferred_username"
+	}
+	if m.Email == "" {
+		m.Email = "email"
+	}
+	if m.EmailVerified == "" {
+		m.EmailVerified = "email_verified"
+	}
+	if m.Groups == "" {
+		m.Groups = "groups"
+	}
+
+	return m
+}
+
+func claimString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64, json.Number:
+		return fmt.Sprint(v)
+	}
+
+	return ""
+}
+
+// claimBool accepts "true" as well, which some providers send.
+func claimBool(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+
+	return false
+}
+
+// claimStrings accepts a single string as well as a list.
+func claimStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				list = append(list, s)
+			}
+		}
+
+		return list
+	}
+
+	return nil
+}
@@ -0,0 +1,236 @@
+// Package fakeidp is an OpenID Connect provider for tests. It signs in
+// whoever the test tells it to, without asking.
+package fakeidp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/oidc"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// IdP is a provider serving discovery, authorization, token and JWKS
+// endpoints.
+type IdP struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	mu     sync.Mutex
+	kid    string
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+	codes  map[string]grant
+}
+
+// grant is an issued authorization code.
+type grant struct {
+	claims      map[string]interface{}
+	nonce       string
+	redirectURI string
+	challenge   string
+}
+
+// New starts a provider for the client clientID. Close it when done.
+func New(clientID, clientSecret string) *IdP {
+	p := &IdP{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		codes:        make(map[string]grant),
+	}
+	p.RotateKey()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/jwks", p.jwks)
+	p.Server = httptest.NewServer(mux)
+
+	return p
+}
+
+// Issuer returns the issuer of the provider.
+func (p *IdP) Issuer() string {
+	return p.URL
+}
+
+// SignIn makes claims the account of the following logins. sub is required.
+func (p *IdP) SignIn(claims map[string]interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.claims = claims
+}
+
+// RotateKey signs with a new key from now on and stops publishing the old
+// one.
+func (p *IdP) RotateKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.key = key
+	p.kid = randomString()
+}
+
+// Authorize plays the browser at the provider: it follows the authorization
+// URL and returns the callback it redirected to.
+func (p *IdP) Authorize(authURL string) (*url.URL, error) {
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		return nil, fmt.Errorf("authorize answered %d", resp.StatusCode)
+	}
+
+	return url.Parse(resp.Header.Get("Location"))
+}
+
+func (p *IdP) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.URL,
+		"authorization_endpoint":                p.URL + "/authorize",
+		"token_endpoint":                        p.URL + "/token",
+		"jwks_uri":                              p.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{model.AlgRS256},
+	})
+}
+
+func (p *IdP) authorize(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	switch {
+	case err != nil || redirectURI.Scheme == "":
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	case q.Get("client_id") != p.ClientID, q.Get("response_type") != "code":
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	case q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "":
+		http.Error(w, "pkce required", http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	claims := p.claims
+	code := randomString()
+	p.codes[code] = grant{
+		claims:      claims,
+		nonce:       q.Get("nonce"),
+		redirectURI: redirectURI.String(),
+		challenge:   q.Get("code_challenge"),
+	}
+	p.mu.Unlock()
+
+	callback := redirectURI.Query()
+	if claims == nil {
+		callback.Set("error", "access_denied")
+	} else {
+		callback.Set("code", code)
+	}
+	callback.Set("state", q.Get("state"))
+	redirectURI.RawQuery = callback.Encode()
+
+	http.Redirect(w, req, redirectURI.String(), http.StatusFound)
+}
+
+func (p *IdP) token(w http.ResponseWriter, req *http.Request) {
+	id, secret, _ := req.BasicAuth()
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	if id != p.ClientID || secret != p.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	g, ok := p.codes[req.PostFormValue("code")]
+	delete(p.codes, req.PostFormValue("code"))
+	kid, key := p.kid, p.key
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(req.PostFormValue("code_verifier")))
+	if !ok || req.PostFormValue("grant_type") != "authorization_code" ||
+		req.PostFormValue("redirect_uri") != g.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":   p.URL,
+		"aud":   p.ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": g.nonce,
+	}
+	for k, v := range g.claims {
+		claims[k] = v
+	}
+
+	idToken, err := oidc.Sign(kid, key, claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (p *IdP) jwks(w http.ResponseWriter, _ *http.Request) {
+	p.mu.Lock()
+	jwk, err := oidc.NewJWK(p.kid, model.AlgRS256, &p.key.PublicKey)
+	p.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, oidc.JWKS{Keys: []oidc.JWK{jwk}})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
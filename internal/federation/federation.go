@@ -0,0 +1,422 @@
+// Package federation lets users log in through upstream OpenID Connect
+// providers, linking the accounts there to local users.
+package federation
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"dev/profileSaver/internal/bootstrap"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/tenant"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stateTTL is how long a login may take at the provider.
+const stateTTL = 10 * time.Minute
+
+var (
+	ErrConnectorNotFound = errors.New("connector not found")
+	ErrInvalidState      = errors.New("invalid or expired login state")
+	ErrInvalidRedirect   = errors.New("redirect must be a path on this service")
+	ErrNotLinked         = errors.New("identity is not linked to a user")
+	ErrIdentityLinked    = errors.New("identity is linked to another user")
+	ErrUsernameTaken     = errors.New("username of the identity is taken")
+	// ErrUpstream wraps everything that went wrong talking to the provider.
+	ErrUpstream = errors.New("identity provider error")
+)
+
+// Repository is what federation needs of the store.
+type Repository interface {
+	repository.Repository
+	repository.IdentityRepository
+}
+
+// Connector is the public part of a configured provider.
+type Connector struct {
+	ID     string
+	Name   string
+	Tenant string
+}
+
+// LoginRequest starts a login through a connector.
+type LoginRequest struct {
+	// Redirect is a path on this service to send the browser to afterwards.
+	Redirect string
+	// LinkUserID links the identity to this user instead of logging in.
+	LinkUserID string
+}
+
+// Result is the outcome of a login.
+type Result struct {
+	User     model.User
+	Identity model.Identity
+	Redirect string
+	// Linked is set when the identity was linked to an existing user.
+	Linked bool
+	// Provisioned is set when the user was created by this login.
+	Provisioned bool
+	// LinkOnly is set when the login linked the identity for the user who
+	// started it. Nobody is logged in.
+	LinkOnly bool
+}
+
+// loginState is what we remember of a login while the browser is at the
+// provider.
+type loginState struct {
+	connector  string
+	nonce      string
+	verifier   string
+	redirect   string
+	linkUserID string
+	expiresAt  time.Time
+}
+
+type Manager struct {
+	repo       Repository
+	connectors map[string]*upstream
+	now        func() time.Time
+
+	mu     sync.Mutex
+	states map[string]loginState
+}
+
+// NewManager returns a manager for connectors. client talks to the
+// providers, a client with a 10s timeout when nil.
+func NewManager(repo Repository, connectors []config.Connector, client *http.Client) *Manager {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	m := &Manager{
+		repo:       repo,
+		connectors: make(map[string]*upstream, len(connectors)),
+		now:        time.Now,
+		states:     make(map[string]loginState),
+	}
+
+	for _, c := range connectors {
+		if len(c.Scopes) == 0 {
+			c.Scopes = []string{"openid", "profile", "email"}
+		}
+		c.Claims = withDefaults(c.Claims)
+
+		m.connectors[c.ID] = &upstream{cfg: c, client: client, now: m.clock}
+	}
+
+	return m
+}
+
+// Connectors lists the connectors of tenantID ordered by ID.
+func (m *Manager) Connectors(tenantID string) []Connector {
+	list := make([]Connector, 0)
+	for _, u := range m.connectors {
+		if u.cfg.Tenant == tenantID {
+			list = append(list, Connector{ID: u.cfg.ID, Name: u.cfg.Name, Tenant: u.cfg.Tenant})
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+
+	return list
+}
+
+// Begin starts a login through connector id of the tenant of ctx and returns
+// the URL of the provider to send the browser to.
+func (m *Manager) Begin(ctx context.Context, id string, r LoginRequest) (string, error) {
+	u, err := m.connector(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	if r.Redirect != "" && !localPath(r.Redirect) {
+		return "", ErrInvalidRedirect
+	}
+
+	var state, nonce, verifier string
+	for _, s := range []*string{&state, &nonce, &verifier} {
+		if *s, err = randomToken(); err != nil {
+			return "", err
+		}
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	target, err := u.authCodeURL(ctx, state, nonce, base64.RawURLEncoding.EncodeToString(sum[:]))
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for token, s := range m.states {
+		if !now.Before(s.expiresAt) {
+			delete(m.states, token)
+		}
+	}
+
+	m.states[state] = loginState{
+		connector:  id,
+		nonce:      nonce,
+		verifier:   verifier,
+		redirect:   r.Redirect,
+		linkUserID: r.LinkUserID,
+		expiresAt:  now.Add(stateTTL),
+	}
+
+	return target, nil
+}
+
+// Complete finishes a login through connector id the provider redirected
+// back with code and state. The identity is looked up, linked or, if the
+// connector allows it, provisioned with a new user.
+func (m *Manager) Complete(ctx context.Context, id, code, state string) (Result, error) {
+	st, ok := m.takeState(state)
+	if !ok || st.connector != id {
+		return Result{}, ErrInvalidState
+	}
+
+	u, ok := m.connectors[id]
+	if !ok {
+		return Result{}, ErrConnectorNotFound
+	}
+
+	claims, err := u.exchange(ctx, code, st.verifier, st.nonce)
+	if err != nil {
+		return Result{}, err
+	}
+
+	p := mapClaims(claims, u.cfg.Claims)
+	ctx = tenant.WithID(ctx, u.cfg.Tenant)
+	res := Result{Redirect: st.redirect, LinkOnly: st.linkUserID != ""}
+
+	ident, err := m.repo.GetIdentity(ctx, id, p.subject)
+	switch {
+	case err == nil:
+		if st.linkUserID != "" && ident.UserID != st.linkUserID {
+			return Result{}, ErrIdentityLinked
+		}
+		res.User, err = m.repo.GetUserByID(ctx, ident.UserID)
+		if err == nil && ident.Provisioned {
+			res.User, err = m.sync(ctx, u, res.User, p)
+		}
+	case errors.Is(err, repository.ErrIdentityNotFound):
+		ident = model.Identity{Connector: id, Subject: p.subject, LinkedAt: m.now()}
+		res.User, ident.Provisioned, err = m.resolve(ctx, u, p, st.linkUserID)
+		res.Linked = err == nil && !ident.Provisioned
+		res.Provisioned = ident.Provisioned
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	if res.User.ServiceAccount {
+		return Result{}, ErrNotLinked
+	}
+
+	ident.UserID = res.User.ID
+	ident.Email = p.email
+	if st.linkUserID == "" {
+		ident.LastLoginAt = m.now()
+	}
+
+	if res.Linked || res.Provisioned {
+		err = m.repo.CreateIdentity(ctx, ident)
+	} else {
+		err = m.repo.UpdateIdentity(ctx, ident)
+	}
+	if errors.Is(err, repository.ErrIdentityExists) {
+		return Result{}, ErrIdentityLinked
+	} else if err != nil {
+		return Result{}, err
+	}
+	res.Identity = ident
+
+	return res, nil
+}
+
+// Identities returns the identities linked to userID.
+func (m *Manager) Identities(ctx context.Context, userID string) []model.Identity {
+	return m.repo.GetIdentitiesByUser(ctx, userID)
+}
+
+// Unlink removes the identity of userID at connector.
+func (m *Manager) Unlink(ctx context.Context, userID, connector string) error {
+	for _, i := range m.repo.GetIdentitiesByUser(ctx, userID) {
+		if i.Connector == connector {
+			return m.repo.DeleteIdentity(ctx, i.Connector, i.Subject)
+		}
+	}
+
+	return repository.ErrIdentityNotFound
+}
+
+// resolve finds the user a new identity belongs to: the user linking it, the
+// user with the same verified email or a new one. provisioned is set for the
+// latter.
+func (m *Manager) resolve(ctx context.Context, u *upstream, p profile, linkUserID string) (model.User, bool, error) {
+	if linkUserID != "" {
+		user, err := m.repo.GetUserByID(ctx, linkUserID)
+		if err != nil {
+			return model.User{}, false, err
+		}
+		if user.Tenant != u.cfg.Tenant {
+			return model.User{}, false, ErrConnectorNotFound
+		}
+
+		return user, false, nil
+	}
+
+	if u.cfg.LinkByEmail && p.email != "" && p.emailVerified {
+		if user, ok := m.userByEmail(ctx, p.email); ok {
+			return user, false, nil
+		}
+	}
+
+	if !u.cfg.Provision {
+		return model.User{}, false, ErrNotLinked
+	}
+
+	user, err := m.provision(ctx, u, p)
+
+	return user, err == nil, err
+}
+
+// userByEmail returns the only user of the tenant of ctx with the verified
+// email.
+func (m *Manager) userByEmail(ctx context.Context, email string) (model.User, bool) {
+	var found []model.User
+	for _, user := range m.repo.GetAllUsers(ctx) {
+		if !user.ServiceAccount && user.EmailVerified && strings.EqualFold(user.Email, email) {
+			found = append(found, user)
+		}
+	}
+
+	if len(found) != 1 {
+		return model.User{}, false
+	}
+
+	return found[0], true
+}
+
+func (m *Manager) provision(ctx context.Context, u *upstream, p profile) (model.User, error) {
+	if p.username == "" {
+		return model.User{}, fmt.Errorf("%w: no %q claim to name the user by", ErrUpstream, u.cfg.Claims.Username)
+	}
+
+	// Nobody knows the password, the user logs in through the provider.
+	password, err := bootstrap.GeneratePassword()
+	if err != nil {
+		return model.User{}, err
+	}
+
+	user, err := m.repo.CreateUser(ctx, model.User{
+		Username:      p.username,
+		Password:      password,
+		Email:         p.email,
+		EmailVerified: p.email != "" && p.emailVerified,
+		Admin:         inGroups(p.groups, u.cfg.AdminGroups),
+		Tenant:        u.cfg.Tenant,
+	})
+	if errors.Is(err, repository.ErrUserNameExists) {
+		return model.User{}, ErrUsernameTaken
+	} else if err != nil {
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
+// sync updates a provisioned user with the claims of their login.
+func (m *Manager) sync(ctx context.Context, u *upstream, user model.User, p profile) (model.User, error) {
+	changed := false
+	if p.email != "" && (user.Email != p.email || user.EmailVerified != p.emailVerified) {
+		user.Email, user.EmailVerified = p.email, p.emailVerified
+		changed = true
+	}
+	if len(u.cfg.AdminGroups) > 0 {
+		if admin := inGroups(p.groups, u.cfg.AdminGroups); admin != user.Admin {
+			user.Admin = admin
+			changed = true
+		}
+	}
+
+	if !changed {
+		return user, nil
+	}
+
+	// Keeps the password hash.
+	update := user
+	update.Password = ""
+	if err := m.repo.UpdateUser(ctx, update); err != nil {
+		return model.User{}, err
+	}
+
+	return user, nil
+}
+
+func (m *Manager) connector(ctx context.Context, id string) (*upstream, error) {
+	u, ok := m.connectors[id]
+	if !ok || u.cfg.Tenant != tenant.FromContext(ctx) {
+		return nil, ErrConnectorNotFound
+	}
+
+	return u, nil
+}
+
+// takeState returns the login of state once.
+func (m *Manager) takeState(state string) (loginState, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.states[state]
+	if !ok {
+		return loginState{}, false
+	}
+	delete(m.states, state)
+
+	return s, m.now().Before(s.expiresAt)
+}
+
+func (m *Manager) clock() time.Time {
+	return m.now()
+}
+
+// localPath reports whether redirect stays on this service.
+func localPath(redirect string) bool {
+	return strings.HasPrefix(redirect, "/") && !strings.HasPrefix(redirect, "//") &&
+		!strings.ContainsAny(redirect, "\\\r\n")
+}
+
+func inGroups(groups, wanted []string) bool {
+	for _, g := range groups {
+		for _, w := range wanted {
+			if g == w {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,192 @@
+package federation
+
+import (
+	"context"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/federation/fakeidp"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	idp := fakeidp.New("profile-saver", "s3cr/t")
+	defer idp.Close()
+
+	repo := repository.New(repository.WithHashParams(repository.HashParams{
+		Time: 1, Memory: 64, Threads: 1, KeyLen: 16, SaltLen: 8,
+	}))
+	require.NoError(t, repo.CreateTenant(ctx, model.Tenant{ID: "acme", Name: "Acme"}))
+	bob, err := repo.CreateUser(ctx, model.User{Username: "bob", Password: "bob",
+		Email: "bob@example.com", EmailVerified: true})
+	require.NoError(t, err)
+	carol, err := repo.CreateUser(ctx, model.User{Username: "carol", Password: "carol",
+		Email: "carol@example.com"})
+	require.NoError(t, err)
+	_, err = repo.CreateUser(ctx, model.User{Username: "taken", Password: "taken"})
+	require.NoError(t, err)
+
+	connector := func(id string) config.Connector {
+		return config.Connector{
+			ID:           id,
+			Name:         "Corp " + id,
+			Issuer:       idp.Issuer(),
+			ClientID:     "profile-saver",
+			ClientSecret: "s3cr/t",
+			RedirectURL:  "https://saver.test/v1/auth/connectors/" + id + "/callback",
+		}
+	}
+	provision := connector("provision")
+	provision.Provision = true
+	provision.AdminGroups = []string{"admins"}
+	byEmail := connector("email")
+	byEmail.LinkByEmail = true
+	acme := connector("acme")
+	acme.Tenant = "acme"
+	acme.Provision = true
+	wrongSecret := connector("wrong")
+	wrongSecret.ClientSecret = "nope"
+
+	m := NewManager(repo, []config.Connector{provision, byEmail, connector("plain"), acme, wrongSecret}, nil)
+
+	login := func(ctx context.Context, id string, r LoginRequest) (Result, error) {
+		target, err := m.Begin(ctx, id, r)
+		require.NoError(t, err)
+
+		callback, err := idp.Authorize(target)
+		require.NoError(t, err)
+
+		return m.Complete(ctx, id, callback.Query().Get("code"), callback.Query().Get("state"))
+	}
+
+	t.Run("CONNECTORS", func(t *testing.T) {
+		list := m.Connectors("")
+		require.Len(t, list, 4)
+		assert.Equal(t, "email", list[0].ID)
+		assert.Equal(t, []Connector{{ID: "acme", Name: "Corp acme", Tenant: "acme"}}, m.Connectors("acme"))
+	})
+
+	t.Run("PROVISION", func(t *testing.T) {
+		idp.SignIn(map[string]interface{}{
+			"sub":                "u-alice",
+			"preferred_username": "alice",
+			"email":              "alice@corp.test",
+			"email_verified":     "true",
+			"groups":             []string{"staff", "admins"},
+		})
+
+		res, err := login(ctx, "provision", LoginRequest{Redirect: "/app"})
+		require.NoError(t, err)
+		assert.True(t, res.Provisioned)
+		assert.Equal(t, "/app", res.Redirect)
+		assert.Equal(t, "alice", res.User.Username)
+		assert.True(t, res.User.Admin)
+		assert.True(t, res.User.EmailVerified)
+		assert.True(t, res.Identity.Provisioned)
+		assert.Equal(t, "u-alice", res.Identity.Subject)
+
+		idp.SignIn(map[string]interface{}{"sub": "u-alice", "preferred_username": "renamed",
+			"email": "alice@corp.test", "email_verified": true, "groups": "staff"})
+		again, err := login(ctx, "provision", LoginRequest{})
+		require.NoError(t, err)
+		assert.False(t, again.Provisioned)
+		assert.Equal(t, res.User.ID, again.User.ID)
+		assert.Equal(t, "alice", again.User.Username, "usernames stay")
+		assert.False(t, again.User.Admin, "left the admin group")
+
+		stored, err := repo.GetUserByID(ctx, res.User.ID)
+		require.NoError(t, err)
+		assert.False(t, stored.Admin)
+		assert.Equal(t, res.User.Password, stored.Password, "the password is kept")
+	})
+
+	t.Run("LINK_BY_EMAIL", func(t *testing.T) {
+		idp.SignIn(map[string]interface{}{"sub": "u-bob", "email": "BOB@example.com", "email_verified": true})
+		res, err := login(ctx, "email", LoginRequest{})
+		require.NoError(t, err)
+		assert.True(t, res.Linked)
+		assert.Equal(t, bob.ID, res.User.ID)
+
+		idp.SignIn(map[string]interface{}{"sub": "u-carol", "email": "carol@example.com", "email_verified": true})
+		_, err = login(ctx, "email", LoginRequest{})
+		assert.ErrorIs(t, err, ErrNotLinked, "carol didn't verify her email")
+	})
+
+	t.Run("LINK", func(t *testing.T) {
+		idp.SignIn(map[string]interface{}{"sub": "u-carol"})
+		_, err := login(ctx, "plain", LoginRequest{})
+		assert.ErrorIs(t, err, ErrNotLinked)
+
+		res, err := login(ctx, "plain", LoginRequest{LinkUserID: carol.ID})
+		require.NoError(t, err)
+		assert.True(t, res.Linked)
+		assert.True(t, res.LinkOnly)
+		assert.True(t, res.Identity.LastLoginAt.IsZero())
+
+		res, err = login(ctx, "plain", LoginRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, carol.ID, res.User.ID)
+		assert.False(t, res.Identity.LastLoginAt.IsZero())
+
+		_, err = login(ctx, "plain", LoginRequest{LinkUserID: bob.ID})
+		assert.ErrorIs(t, err, ErrIdentityLinked)
+
+		idp.SignIn(map[string]interface{}{"sub": "u-carol-2"})
+		_, err = login(ctx, "plain", LoginRequest{LinkUserID: carol.ID})
+		assert.ErrorIs(t, err, ErrIdentityLinked, "one identity per connector")
+
+		require.Len(t, m.Identities(ctx, carol.ID), 1)
+		require.NoError(t, m.Unlink(ctx, carol.ID, "plain"))
+		assert.Empty(t, m.Identities(ctx, carol.ID))
+		assert.ErrorIs(t, m.Unlink(ctx, carol.ID, "plain"), repository.ErrIdentityNotFound)
+	})
+
+	t.Run("TENANT", func(t *testing.T) {
+		_, err := m.Begin(ctx, "acme", LoginRequest{})
+		assert.ErrorIs(t, err, ErrConnectorNotFound)
+
+		idp.SignIn(map[string]interface{}{"sub": "u-dave", "preferred_username": "dave"})
+		res, err := login(tenant.WithID(ctx, "acme"), "acme", LoginRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, "acme", res.User.Tenant)
+	})
+
+	t.Run("KEY_ROTATION", func(t *testing.T) {
+		idp.RotateKey()
+		idp.SignIn(map[string]interface{}{"sub": "u-bob"})
+		_, err := login(ctx, "email", LoginRequest{})
+		assert.NoError(t, err, "the new key is fetched")
+	})
+
+	t.Run("NOT_OK", func(t *testing.T) {
+		_, err := m.Begin(ctx, "nope", LoginRequest{})
+		assert.ErrorIs(t, err, ErrConnectorNotFound)
+
+		for _, redirect := range []string{"https://evil.test", "//evil.test", "/\\evil.test"} {
+			_, err = m.Begin(ctx, "plain", LoginRequest{Redirect: redirect})
+			assert.ErrorIs(t, err, ErrInvalidRedirect, redirect)
+		}
+
+		idp.SignIn(map[string]interface{}{"sub": "u-taken", "preferred_username": "taken"})
+		_, err = login(ctx, "provision", LoginRequest{})
+		assert.ErrorIs(t, err, ErrUsernameTaken)
+
+		_, err = login(ctx, "wrong", LoginRequest{})
+		assert.ErrorIs(t, err, ErrUpstream)
+
+		target, err := m.Begin(ctx, "plain", LoginRequest{})
+		require.NoError(t, err)
+		callback, err := idp.Authorize(target)
+		require.NoError(t, err)
+		code, state := callback.Query().Get("code"), callback.Query().Get("state")
+
+		_, err = m.Complete(ctx, "email", code, state)
+		assert.ErrorIs(t, err, ErrInvalidState, "state of another connector")
+		_, err = m.Complete(ctx, "plain", code, state)
+		assert.ErrorIs(t, err, ErrInvalidState, "states are used once")
+	})
+}
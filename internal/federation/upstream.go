@@ -0,0 +1,231 @@
+package federation
+
+import (
+	"context"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/oidc"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// upstream talks to the provider of a connector. Its metadata and keys are
+// fetched on first use.
+type upstream struct {
+	cfg    config.Connector
+	client *http.Client
+	now    func() time.Time
+
+	mu   sync.Mutex
+	meta *metadata
+	keys []oidc.JWK
+}
+
+// metadata is the part of the provider's discovery document we use.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func (u *upstream) metadata(ctx context.Context) (metadata, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.meta != nil {
+		return *u.meta, nil
+	}
+
+	var m metadata
+	if err := u.getJSON(ctx, strings.TrimSuffix(u.cfg.Issuer, "/")+"/.well-known/openid-configuration", &m); err != nil {
+		return metadata{}, err
+	}
+
+	if m.Issuer != u.cfg.Issuer {
+		return metadata{}, fmt.Errorf("%w: discovery names issuer %q instead of %q", ErrUpstream, m.Issuer, u.cfg.Issuer)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return metadata{}, fmt.Errorf("%w: discovery lacks endpoints", ErrUpstream)
+	}
+
+	u.meta = &m
+
+	return m, nil
+}
+
+// signingKeys returns the provider's keys, fetched again when refresh is set.
+func (u *upstream) signingKeys(ctx context.Context, m metadata, refresh bool) ([]oidc.JWK, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.keys != nil && !refresh {
+		return u.keys, nil
+	}
+
+	var set oidc.JWKS
+	if err := u.getJSON(ctx, m.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	u.keys = set.Keys
+
+	return u.keys, nil
+}
+
+// authCodeURL returns where to send the browser to sign in.
+func (u *upstream) authCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	m, err := u.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	target, err := url.Parse(m.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+
+	q := target.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", u.cfg.ClientID)
+	q.Set("redirect_uri", u.cfg.RedirectURL)
+	q.Set("scope", strings.Join(u.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", challenge)
+	q.Set("code_challenge_method", "S256")
+	target.RawQuery = q.Encode()
+
+	return target.String(), nil
+}
+
+// exchange redeems code at the provider and returns the claims of the
+// verified ID token.
+func (u *upstream) exchange(ctx context.Context, code, verifier, nonce string) (map[string]interface{}, error) {
+	m, err := u.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {u.cfg.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(u.cfg.ClientID), url.QueryEscape(u.cfg.ClientSecret))
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	defer resp.Body.Close()
+
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("%w: token response: %v", ErrUpstream, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: token endpoint answered %d: %s %s", ErrUpstream, resp.StatusCode, tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: no id_token in the token response", ErrUpstream)
+	}
+
+	return u.verify(ctx, m, tokens.IDToken, nonce)
+}
+
+// verify checks the signature and the claims of an ID token issued to us.
+func (u *upstream) verify(ctx context.Context, m metadata, token, nonce string) (map[string]interface{}, error) {
+	keys, err := u.signingKeys(ctx, m, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	err = oidc.Verify(token, keys, &raw)
+	if errors.Is(err, oidc.ErrUnknownKey) {
+		// The provider may have rotated its keys since we fetched them.
+		if keys, err = u.signingKeys(ctx, m, true); err != nil {
+			return nil, err
+		}
+		err = oidc.Verify(token, keys, &raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: id_token: %v", ErrUpstream, err)
+	}
+
+	// Only the registered claims, the others vary between providers.
+	var std struct {
+		Issuer    string        `json:"iss"`
+		Subject   string        `json:"sub"`
+		Audience  oidc.Audience `json:"aud"`
+		ExpiresAt int64         `json:"exp"`
+		Nonce     string        `json:"nonce"`
+	}
+	if err = json.Unmarshal(raw, &std); err != nil {
+		return nil, fmt.Errorf("%w: id_token: %v", ErrUpstream, err)
+	}
+
+	switch {
+	case std.Issuer != m.Issuer:
+		err = fmt.Errorf("issuer %q", std.Issuer)
+	case !std.Audience.Contains(u.cfg.ClientID):
+		err = errors.New("audience")
+	case u.now().Unix() >= std.ExpiresAt:
+		err = errors.New("expired")
+	case std.Nonce != nonce:
+		err = errors.New("nonce")
+	case std.Subject == "":
+		err = errors.New("no subject")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: id_token: %v", ErrUpstream, err)
+	}
+
+	var claims map[string]interface{}
+	if err = json.Unmarshal(raw, &claims); err != nil {
+		return nil, fmt.Errorf("%w: id_token: %v", ErrUpstream, err)
+	}
+
+	return claims, nil
+}
+
+func (u *upstream) getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s answered %d", ErrUpstream, target, resp.StatusCode)
+	}
+
+	if err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrUpstream, target, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+// Package idempotency keeps the answers to requests made with an
+// Idempotency-Key, so that a retried request is answered like the first one
+// instead of being handled again.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/model"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// Header names the key a client chooses to make a request safe to retry.
+const Header = "Idempotency-Key"
+
+// MaxKeyLength bounds the keys clients choose.
+const MaxKeyLength = 255
+
+const DefaultTTL = 24 * time.Hour
+
+// DefaultMaxBodySize bounds request bodies when the config sets no limit.
+const DefaultMaxBodySize = 1 << 20
+
+// pendingTTL is how long a key stays reserved for a request that is never
+// finished, e.g. because the process stopped while handling it.
+const pendingTTL = 5 * time.Minute
+
+var (
+	ErrNotFound = errors.New("idempotency record not found")
+	// ErrExists is returned by Store.Create for a key already kept.
+	ErrExists = errors.New("idempotency key exists")
+	// ErrInvalidKey is returned for keys longer than MaxKeyLength.
+	ErrInvalidKey = errors.New("idempotency key is too long")
+	// ErrInProgress is returned while the first request with a key is handled.
+	ErrInProgress = errors.New("a request with this idempotency key is in progress")
+	// ErrMismatch is returned when a key is reused for another request.
+	ErrMismatch = errors.New("idempotency key was used for another request")
+)
+
+// Store keeps records by key.
+type Store interface {
+	// Create stores r unless a record with its key is kept beyond r.CreatedAt,
+	// then it returns ErrExists.
+	Create(ctx context.Context, r model.IdempotencyRecord) error
+	Get(ctx context.Context, key string) (model.IdempotencyRecord, error)
+	Update(ctx context.Context, r model.IdempotencyRecord) error
+	Delete(ctx context.Context, key string) error
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+// Manager reserves keys for requests, keeps their answers and finds the
+// answers to replay.
+type Manager struct {
+	store Store
+	cfg   config.Idempotency
+	now   func() time.Time
+}
+
+// NewManager returns a Manager over store. A zero TTL or MaxBodySize falls
+// back to DefaultTTL or DefaultMaxBodySize.
+func NewManager(store Store, cfg config.Idempotency) *Manager {
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DefaultMaxBodySize
+	}
+
+	return &Manager{
+		store: store,
+		cfg:   cfg,
+		now:   time.Now,
+	}
+}
+
+// Key returns the key the client's key is kept under. Clients only share keys
+// with themselves, so it is scoped to the tenant and user.
+func Key(tenant, userID, key string) (string, error) {
+	if len(key) > MaxKeyLength {
+		return "", ErrInvalidKey
+	}
+
+	return hash([]byte(tenant), []byte(userID), []byte(key)), nil
+}
+
+// Fingerprint identifies a request by its method, path and body.
+func Fingerprint(method, path string, body []byte) string {
+	return hash([]byte(method), []byte(path), body)
+}
+
+// Begin reserves key for the request with fingerprint. When the key was used
+// before it returns the record of that request with replay set, to answer
+// with its response instead of handling the request again. Reusing a key for
+// another request fails with ErrMismatch, retrying before the first request
+// was answered with ErrInProgress.
+func (m *Manager) Begin(ctx context.Context, key, fingerprint string) (r model.IdempotencyRecord, replay bool, err error) {
+	now := m.now()
+	r = model.IdempotencyRecord{
+		Key:         key,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(pendingTTL),
+	}
+
+	err = m.store.Create(ctx, r)
+	if err == nil {
+		return r, false, nil
+	}
+	if !errors.Is(err, ErrExists) {
+		return model.IdempotencyRecord{}, false, err
+	}
+
+	old, err := m.store.Get(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		// The first request failed just now and released the key.
+		return model.IdempotencyRecord{}, false, ErrInProgress
+	}
+	if err != nil {
+		return model.IdempotencyRecord{}, false, err
+	}
+
+	switch {
+	case old.Fingerprint != fingerprint:
+		return model.IdempotencyRecord{}, false, ErrMismatch
+	case old.Status == 0:
+		return model.IdempotencyRecord{}, false, ErrInProgress
+	}
+
+	return old, true, nil
+}
+
+// Finish keeps the answer to the request r was reserved for, to be replayed
+// for the TTL.
+func (m *Manager) Finish(ctx context.Context, r model.IdempotencyRecord, status int, header map[string][]string, body []byte) error {
+	r.Status = status
+	r.Header = header
+	r.Body = body
+	r.ExpiresAt = m.now().Add(m.cfg.TTL)
+
+	return m.store.Update(ctx, r)
+}
+
+// Release frees key for a request that wasn't answered in a way worth
+// replaying, so that it can be retried.
+func (m *Manager) Release(ctx context.Context, key string) error {
+	if err := m.store.Delete(ctx, key); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// Prune deletes the records no longer replayed.
+func (m *Manager) Prune(ctx context.Context) error {
+	return m.store.DeleteExpired(ctx, m.now())
+}
+
+// hash returns the hex SHA-256 of parts, each prefixed by its length so that
+// different parts never hash alike.
+func hash(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(p)))
+		h.Write(n[:])
+		h.Write(p)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,140 @@
+package idempotency
+
+import (
+	"context"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func stores(t *testing.T) map[string]Store {
+	file, err := repository.NewFile(filepath.Join(t.TempDir(), "db.json"))
+	require.NoError(t, err)
+
+	return map[string]Store{
+		"MEMORY":     NewMemoryStore(),
+		"REPOSITORY": NewRepositoryStore(repository.New()),
+		"FILE":       NewRepositoryStore(file),
+	}
+}
+
+func TestManager_Begin(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			m := NewManager(store, config.Idempotency{TTL: time.Hour})
+			m.now = func() time.Time { return now }
+
+			key, err := Key("", "1", "create-alice")
+			require.NoError(t, err)
+			fingerprint := Fingerprint("POST", "/v1/user", []byte(`{"username":"alice"}`))
+
+			r, replay, err := m.Begin(ctx, key, fingerprint)
+			require.NoError(t, err)
+			assert.False(t, replay)
+
+			_, _, err = m.Begin(ctx, key, fingerprint)
+			assert.ErrorIs(t, err, ErrInProgress)
+
+			header := map[string][]string{"Location": {"/v1/user/2"}}
+			require.NoError(t, m.Finish(ctx, r, http.StatusCreated, header, []byte(`{"data":{}}`)))
+
+			now = now.Add(30 * time.Minute)
+			kept, replay, err := m.Begin(ctx, key, fingerprint)
+			require.NoError(t, err)
+			assert.True(t, replay)
+			assert.Equal(t, http.StatusCreated, kept.Status)
+			assert.Equal(t, header, kept.Header)
+			assert.Equal(t, []byte(`{"data":{}}`), kept.Body)
+
+			_, _, err = m.Begin(ctx, key, Fingerprint("POST", "/v1/user", []byte(`{"username":"bob"}`)))
+			assert.ErrorIs(t, err, ErrMismatch)
+			_, _, err = m.Begin(ctx, key, Fingerprint("POST", "/v2/users", []byte(`{"username":"alice"}`)))
+			assert.ErrorIs(t, err, ErrMismatch)
+
+			now = now.Add(time.Hour)
+			_, replay, err = m.Begin(ctx, key, fingerprint)
+			require.NoError(t, err)
+			assert.False(t, replay, "expired answers aren't replayed")
+		})
+	}
+}
+
+func TestManager_Release(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			m := NewManager(store, config.Idempotency{})
+			m.now = func() time.Time { return now }
+
+			_, _, err := m.Begin(ctx, "key", "first")
+			require.NoError(t, err)
+			require.NoError(t, m.Release(ctx, "key"))
+			require.NoError(t, m.Release(ctx, "key"))
+
+			_, replay, err := m.Begin(ctx, "key", "second")
+			require.NoError(t, err, "a released key can be used for another request")
+			assert.False(t, replay)
+
+			now = now.Add(pendingTTL)
+			_, replay, err = m.Begin(ctx, "key", "second")
+			require.NoError(t, err, "a key of a request never finished is freed")
+			assert.False(t, replay)
+
+			now = now.Add(pendingTTL)
+			require.NoError(t, m.Prune(ctx))
+			_, err = store.Get(ctx, "key")
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	a, err := Key("acme", "1", "k")
+	require.NoError(t, err)
+	b, err := Key("", "1", "k")
+	require.NoError(t, err)
+	c, err := Key("acme", "2", "k")
+	require.NoError(t, err)
+	d, err := Key("acme1", "", "k")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, a, b)
+	assert.NotEqual(t, a, c)
+	assert.NotEqual(t, a, d)
+
+	_, err = Key("", "1", strings.Repeat("k", MaxKeyLength+1))
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestRepositoryStore_persists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "db.json")
+
+	file, err := repository.NewFile(path)
+	require.NoError(t, err)
+
+	m := NewManager(NewRepositoryStore(file), config.Idempotency{})
+	r, _, err := m.Begin(ctx, "key", "fingerprint")
+	require.NoError(t, err)
+	require.NoError(t, m.Finish(ctx, r, http.StatusOK, nil, []byte(`{"data":"ok"}`)))
+
+	reopened, err := repository.NewFile(path)
+	require.NoError(t, err)
+
+	m = NewManager(NewRepositoryStore(reopened), config.Idempotency{})
+	kept, replay, err := m.Begin(ctx, "key", "fingerprint")
+	require.NoError(t, err)
+	assert.True(t, replay)
+	assert.Equal(t, []byte(`{"data":"ok"}`), kept.Body)
+}
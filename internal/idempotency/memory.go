@@ -0,0 +1,82 @@
+package idempotency
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps records in process memory, they are lost on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]model.IdempotencyRecord
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]model.IdempotencyRecord)}
+}
+
+func (m *MemoryStore) Create(_ context.Context, r model.IdempotencyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if old, ok := m.records[r.Key]; ok && old.ExpiresAt.After(r.CreatedAt) {
+		return ErrExists
+	}
+
+	m.records[r.Key] = r
+
+	return nil
+}
+
+func (m *MemoryStore) Get(_ context.Context, key string) (model.IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.records[key]
+	if !ok {
+		return model.IdempotencyRecord{}, ErrNotFound
+	}
+
+	return r, nil
+}
+
+func (m *MemoryStore) Update(_ context.Context, r model.IdempotencyRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.records[r.Key]; !ok {
+		return ErrNotFound
+	}
+
+	m.records[r.Key] = r
+
+	return nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.records[key]; !ok {
+		return ErrNotFound
+	}
+
+	delete(m.records, key)
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteExpired(_ context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, r := range m.records {
+		if !now.Before(r.ExpiresAt) {
+			delete(m.records, key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"dev/profileSaver/internal/logger"
+	"errors"
+	"github.com/uptrace/bunrouter"
+	"io"
+	"net/http"
+)
+
+// HeaderReplayed is set on answers replayed for a retry.
+const HeaderReplayed = "Idempotent-Replayed"
+
+var (
+	// ErrUnreadableBody is passed to fail when the body of a request with a
+	// key can't be read.
+	ErrUnreadableBody = errors.New("unable to read the request body")
+	// ErrBodyTooLarge is passed to fail when the body of a request with a key
+	// is larger than the configured MaxBodySize.
+	ErrBodyTooLarge = errors.New("request body is too large")
+)
+
+// replayedHeaders are the response headers kept with an answer.
+var replayedHeaders = []string{"Content-Type", "Location"}
+
+// Middleware answers a retried request, one with the Idempotency-Key of an
+// earlier request of the same tenant and user as returned by scope, with the
+// answer to the earlier request instead of handling it again. Only successful
+// answers are kept, a failed request may be retried with its key. Reusing a
+// key for another method, path or body, or retrying while the first request
+// is still handled, is answered by fail, as are bodies larger than the
+// configured MaxBodySize.
+func (m *Manager) Middleware(scope func(ctx context.Context) (tenant, userID string), fail func(w http.ResponseWriter, req bunrouter.Request, err error) error) bunrouter.MiddlewareFunc {
+	return func(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+		return func(w http.ResponseWriter, req bunrouter.Request) error {
+			clientKey := req.Header.Get(Header)
+			if clientKey == "" || req.Method == http.MethodGet || req.Method == http.MethodHead || req.Method == http.MethodOptions {
+				return next(w, req)
+			}
+
+			ctx := req.Context()
+
+			tenantID, userID := scope(ctx)
+			key, err := Key(tenantID, userID, clientKey)
+			if err != nil {
+				return fail(w, req, err)
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, m.cfg.MaxBodySize))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return fail(w, req, ErrBodyTooLarge)
+			}
+			if err != nil {
+				return fail(w, req, ErrUnreadableBody)
+			}
+			req.Body.Close()
+			req.Body = io.NopCloser(bytes.NewReader(body))
+
+			r, replay, err := m.Begin(ctx, key, Fingerprint(req.Method, req.URL.Path, body))
+			if errors.Is(err, ErrInProgress) {
+				w.Header().Set("Retry-After", "1")
+			}
+			if err != nil {
+				return fail(w, req, err)
+			}
+
+			if replay {
+				for name, values := range r.Header {
+					w.Header()[name] = values
+				}
+				w.Header().Set(HeaderReplayed, "true")
+				w.WriteHeader(r.Status)
+				_, err = w.Write(r.Body)
+
+				return err
+			}
+
+			rec := logger.NewStatusRecorder(w)
+			rec.Body = &bytes.Buffer{}
+			err = next(rec, req)
+			if err != nil || rec.Code < http.StatusOK || rec.Code >= http.StatusMultipleChoices {
+				if err := m.Release(ctx, key); err != nil {
+					logger.FromContext(ctx).Error().Err(err).Msg("unable to release idempotency key")
+				}
+
+				return err
+			}
+
+			header := make(map[string][]string)
+			for _, name := range replayedHeaders {
+				if v := w.Header().Values(name); len(v) > 0 {
+					header[name] = v
+				}
+			}
+
+			// The answer is sent, a retry is just handled again.
+			if err := m.Finish(ctx, r, rec.Code, header, rec.Body.Bytes()); err != nil {
+				logger.FromContext(ctx).Error().Err(err).Msg("unable to keep the answer for the idempotency key")
+			}
+
+			return nil
+		}
+	}
+}
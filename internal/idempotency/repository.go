@@ -0,0 +1,67 @@
+package idempotency
+
+import (
+	"context"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RepositoryStore keeps records in the storage backend.
+type RepositoryStore struct {
+	repo repository.IdempotencyRepository
+}
+
+func NewRepositoryStore(repo repository.IdempotencyRepository) *RepositoryStore {
+	return &RepositoryStore{repo: repo}
+}
+
+// OpenStore returns the Store selected by cfg.Store.
+func OpenStore(cfg config.Idempotency, repo repository.IdempotencyRepository) (Store, error) {
+	switch cfg.Store {
+	case config.IdempotencyStoreMemory, "":
+		return NewMemoryStore(), nil
+	case config.IdempotencyStoreRepository:
+		return NewRepositoryStore(repo), nil
+	default:
+		return nil, fmt.Errorf("unsupported idempotency store %q", cfg.Store)
+	}
+}
+
+func (r *RepositoryStore) Create(ctx context.Context, rec model.IdempotencyRecord) error {
+	err := r.repo.CreateIdempotencyRecord(ctx, rec)
+	if errors.Is(err, repository.ErrIdempotencyKeyExists) {
+		return ErrExists
+	}
+
+	return err
+}
+
+func (r *RepositoryStore) Get(ctx context.Context, key string) (model.IdempotencyRecord, error) {
+	rec, err := r.repo.GetIdempotencyRecord(ctx, key)
+
+	return rec, notFound(err)
+}
+
+func (r *RepositoryStore) Update(ctx context.Context, rec model.IdempotencyRecord) error {
+	return notFound(r.repo.UpdateIdempotencyRecord(ctx, rec))
+}
+
+func (r *RepositoryStore) Delete(ctx context.Context, key string) error {
+	return notFound(r.repo.DeleteIdempotencyRecord(ctx, key))
+}
+
+func (r *RepositoryStore) DeleteExpired(ctx context.Context, now time.Time) error {
+	return r.repo.DeleteExpiredIdempotencyRecords(ctx, now)
+}
+
+func notFound(err error) error {
+	if errors.Is(err, repository.ErrIdempotencyRecordNotFound) {
+		return ErrNotFound
+	}
+
+	return err
+}
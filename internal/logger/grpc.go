@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+	"time"
+)
+
+// UnaryServerInterceptor is Middleware for gRPC: calls are tagged with the ID
+// of the x-request-id metadata, or a new one, which is also sent back in the
+// header metadata.
+func UnaryServerInterceptor(base zerolog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, done := startCall(ctx, base, info.FullMethod)
+
+		resp, err := handler(ctx, req)
+		done(err)
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming calls.
+func StreamServerInterceptor(base zerolog.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, done := startCall(ss.Context(), base, info.FullMethod)
+
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		done(err)
+
+		return err
+	}
+}
+
+// startCall stores the logger of a call in ctx. done writes the access log
+// line once the call returned err.
+func startCall(ctx context.Context, base zerolog.Logger, method string) (context.Context, func(err error)) {
+	start := time.Now()
+
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(HeaderRequestID); len(ids) != 0 {
+			requestID = ids[0]
+		}
+	}
+	if requestID == "" || len(requestID) > maxRequestIDLen {
+		requestID = uuid.New().String()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(HeaderRequestID), requestID))
+
+	ctx = base.With().
+		Str("request_id", requestID).
+		Str("method", method).
+		Logger().
+		WithContext(ctx)
+	l := zerolog.Ctx(ctx)
+
+	return ctx, func(err error) {
+		code := status.Code(err)
+
+		event := l.Info()
+		switch code {
+		case codes.Unknown, codes.Internal, codes.Unavailable, codes.DataLoss:
+			event = l.Error().Err(err)
+		}
+		event.
+			Str("code", code.String()).
+			Dur("duration", time.Since(start)).
+			Msg("call handled")
+	}
+}
+
+// serverStream is a grpc.ServerStream with another context.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"context"
+	"dev/profileSaver/internal/config"
+	"fmt"
+	"github.com/rs/zerolog"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+// New builds the application logger. Everything it writes passes through a
+// redacting writer, so passwords and salts never reach the output.
+func New(cfg config.Log) (zerolog.Logger, error) {
+	return newLogger(cfg, os.Stdout)
+}
+
+func newLogger(cfg config.Log, out io.Writer) (zerolog.Logger, error) {
+	if err := SetLevel(cfg.Level); err != nil {
+		return zerolog.Logger{}, err
+	}
+
+	switch cfg.Format {
+	case "", FormatJSON:
+	case FormatConsole:
+		out = zerolog.ConsoleWriter{Out: out, TimeFormat: time.RFC3339}
+	default:
+		return zerolog.Logger{}, fmt.Errorf("invalid log format %q", cfg.Format)
+	}
+
+	return zerolog.New(NewRedactWriter(out)).With().Timestamp().Logger(), nil
+}
+
+// SetLevel changes the minimum level of every logger at runtime.
+func SetLevel(name string) error {
+	level, err := zerolog.ParseLevel(name)
+	if err != nil {
+		return fmt.Errorf("invalid log level %q: %w", name, err)
+	}
+	if level == zerolog.NoLevel {
+		level = zerolog.InfoLevel
+	}
+
+	zerolog.SetGlobalLevel(level)
+
+	return nil
+}
+
+// FromContext returns the request-scoped logger stored by Middleware, or the
+// default context logger when there is none.
+func FromContext(ctx context.Context) *zerolog.Logger {
+	return zerolog.Ctx(ctx)
+}
+
+// WithUser attaches the authenticated username to the request-scoped logger.
+func WithUser(ctx context.Context, username string) {
+	FromContext(ctx).UpdateContext(func(c zerolog.Context) zerolog.Context {
+		return c.Str("user", username)
+	})
+}
@@ -0,0 +1,161 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"dev/profileSaver/internal/config"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bunrouter"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "PASSWORD",
+			input:    `{"password":"se\"cret","username":"test"}`,
+			expected: `{"password":"[REDACTED]","username":"test"}`,
+		},
+		{
+			name:     "SALT",
+			input:    `{"salt":[1,2,3],"Salt": "AQID"}`,
+			expected: `{"salt":"[REDACTED]","Salt": "[REDACTED]"}`,
+		},
+		{
+			name:     "NEW_PASSWORD",
+			input:    `{"new_password":"x","id":1}`,
+			expected: `{"new_password":"[REDACTED]","id":1}`,
+		},
+		{
+			name:     "UNTOUCHED",
+			input:    `{"username":"password"}`,
+			expected: `{"username":"password"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, string(Redact([]byte(test.input))))
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+
+	_, err := newLogger(config.Log{Level: "loud"}, &buf)
+	assert.Error(t, err)
+
+	_, err = newLogger(config.Log{Level: "info", Format: "xml"}, &buf)
+	assert.Error(t, err)
+
+	l, err := newLogger(config.Log{Level: "warn", Format: "json"}, &buf)
+	require.NoError(t, err)
+
+	l.Info().Msg("skipped")
+	assert.Empty(t, buf.String())
+
+	l.Warn().Str("password", "admin").Msg("kept")
+	assert.Contains(t, buf.String(), `"password":"[REDACTED]"`)
+	assert.NotContains(t, buf.String(), "admin")
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+
+	l, err := newLogger(config.Log{Level: "info"}, &buf)
+	require.NoError(t, err)
+
+	router := bunrouter.New(bunrouter.Use(Middleware(l)))
+	router.GET("/user/:id", func(w http.ResponseWriter, req bunrouter.Request) error {
+		WithUser(req.Context(), "admin")
+		FromContext(req.Context()).Info().Msg("inside")
+		w.WriteHeader(http.StatusTeapot)
+		return nil
+	})
+
+	tests := []struct {
+		name      string
+		requestID string
+	}{
+		{
+			name:      "CLIENT_ID",
+			requestID: "abc-123",
+		},
+		{
+			name:      "GENERATED_ID",
+			requestID: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf.Reset()
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/user/1", nil)
+			if test.requestID != "" {
+				req.Header.Set(HeaderRequestID, test.requestID)
+			}
+
+			router.ServeHTTP(w, req)
+
+			requestID := w.Header().Get(HeaderRequestID)
+			assert.NotEmpty(t, requestID)
+			if test.requestID != "" {
+				assert.Equal(t, test.requestID, requestID)
+			}
+
+			lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+			require.Len(t, lines, 2)
+
+			for _, line := range lines {
+				var entry map[string]interface{}
+				require.NoError(t, json.Unmarshal(line, &entry))
+
+				assert.Equal(t, requestID, entry["request_id"])
+				assert.Equal(t, "/user/:id", entry["route"])
+				assert.Equal(t, "admin", entry["user"])
+			}
+
+			var access map[string]interface{}
+			require.NoError(t, json.Unmarshal(lines[1], &access))
+			assert.Equal(t, float64(http.StatusTeapot), access["status"])
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := newLogger(config.Log{Level: "info"}, &buf)
+	require.NoError(t, err)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "abc-123"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/profilesaver.user.v1.UserService/GetUser"}
+
+	_, err = UnaryServerInterceptor(l)(ctx, nil, info, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		WithUser(ctx, "admin")
+		return nil, status.Error(codes.NotFound, "user not found")
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "abc-123", entry["request_id"])
+	assert.Equal(t, info.FullMethod, entry["method"])
+	assert.Equal(t, "admin", entry["user"])
+	assert.Equal(t, "NotFound", entry["code"])
+	assert.Equal(t, "info", entry["level"])
+}
@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"bytes"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/uptrace/bunrouter"
+	"net/http"
+	"time"
+)
+
+const HeaderRequestID = "X-Request-ID"
+
+// maxRequestIDLen bounds client supplied request IDs so they can't bloat logs.
+const maxRequestIDLen = 128
+
+// Middleware tags every request with an ID (taken from X-Request-ID when the
+// client sends one), stores a logger carrying the ID and route in the request
+// context and writes an access log line once the handler returns.
+func Middleware(base zerolog.Logger) bunrouter.MiddlewareFunc {
+	return func(next bunrouter.HandlerFunc) bunrouter.HandlerFunc {
+		return func(w http.ResponseWriter, req bunrouter.Request) error {
+			start := time.Now()
+
+			requestID := req.Header.Get(HeaderRequestID)
+			if requestID == "" || len(requestID) > maxRequestIDLen {
+				requestID = uuid.New().String()
+			}
+			w.Header().Set(HeaderRequestID, requestID)
+
+			ctx := base.With().
+				Str("request_id", requestID).
+				Str("method", req.Method).
+				Str("route", req.Route()).
+				Logger().
+				WithContext(req.Context())
+			// Fetch the stored pointer so fields added downstream, such as
+			// the user, also end up in the access log line.
+			l := zerolog.Ctx(ctx)
+
+			rec := NewStatusRecorder(w)
+			err := next(rec, req.WithContext(ctx))
+
+			event := l.Info()
+			if err != nil || rec.Code >= http.StatusInternalServerError {
+				event = l.Error().Err(err)
+			}
+			event.
+				Int("status", rec.Code).
+				Dur("duration", time.Since(start)).
+				Str("path", req.URL.Path).
+				Msg("request handled")
+
+			return err
+		}
+	}
+}
+
+// StatusRecorder passes a response through, noting its status code and,
+// when Body is set, copying the body to it.
+type StatusRecorder struct {
+	http.ResponseWriter
+	Code int
+	Body *bytes.Buffer
+}
+
+// NewStatusRecorder returns a StatusRecorder over w, with the status code
+// sent when none is written.
+func NewStatusRecorder(w http.ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w, Code: http.StatusOK}
+}
+
+func (r *StatusRecorder) WriteHeader(code int) {
+	r.Code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *StatusRecorder) Write(b []byte) (int, error) {
+	if r.Body != nil {
+		r.Body.Write(b)
+	}
+
+	return r.ResponseWriter.Write(b)
+}
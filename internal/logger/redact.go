@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"io"
+	"regexp"
+)
+
+const redacted = `"[REDACTED]"`
+
+// sensitiveField matches a JSON key that names a credential together with
+// its value, which may be a string, an array (salts are []byte) or a scalar.
+var sensitiveField = regexp.MustCompile(
+	`("(?i:[a-z_]*(?:password|salt|secret)[a-z_]*)"\s*:\s*)("(?:[^"\\]|\\.)*"|\[[^\]]*\]|[^,}\s]+)`,
+)
+
+type redactWriter struct {
+	out io.Writer
+}
+
+// NewRedactWriter wraps out so that credential fields in JSON log lines are
+// replaced before they are written.
+func NewRedactWriter(out io.Writer) io.Writer {
+	return &redactWriter{out: out}
+}
+
+func (w *redactWriter) Write(p []byte) (int, error) {
+	if _, err := w.out.Write(Redact(p)); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Redact replaces the values of password, salt and secret fields in p.
+func Redact(p []byte) []byte {
+	return sensitiveField.ReplaceAll(p, []byte("${1}"+redacted))
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// APIKey authenticates its user without a password, limited to its scopes.
+// ID is the SHA-256 of the key, the key itself is never stored.
+type APIKey struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	// Prefix is the public start of the key, enough to recognise it.
+	Prefix    string    `json:"prefix"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is zero for keys that don't expire.
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
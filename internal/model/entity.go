@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type User struct {
 	ID       string `json:"id"`
 	Email    string `json:"email"`
@@ -7,4 +9,20 @@ type User struct {
 	Password string `json:"password"`
 	Salt     []byte `json:"salt"`
 	Admin    bool   `json:"admin"`
+	// MustChangePassword limits the user to changing their password.
+	MustChangePassword bool `json:"must_change_password"`
+	// EmailVerified is set once the user confirmed a token sent to Email.
+	EmailVerified bool `json:"email_verified"`
+	// Tenant is the organisation the user belongs to, empty for the default
+	// tenant. Usernames are unique within a tenant.
+	Tenant string `json:"tenant,omitempty"`
+	// ServiceAccount marks users for machines, which can't sign in with a
+	// password and authenticate with API keys only.
+	ServiceAccount bool `json:"service_account,omitempty"`
+	// Disabled users keep their data and can't sign in.
+	Disabled bool `json:"disabled,omitempty"`
+	// ExternalID is what a provisioning client knows the user by.
+	ExternalID string    `json:"external_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
 }
@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// Permissions granted to the members of a group. Admins hold all of them.
+const (
+	// PermissionAdmin grants everything an admin of the tenant may do.
+	PermissionAdmin = "admin"
+	// PermissionUserWrite grants creating, changing and deleting users.
+	PermissionUserWrite = "user:write"
+	// PermissionUserInvite grants issuing registration invites.
+	PermissionUserInvite = "user:invite"
+	// PermissionGroupWrite grants managing groups and their members.
+	PermissionGroupWrite = "group:write"
+)
+
+// Permissions lists every permission a group can grant.
+var Permissions = []string{PermissionAdmin, PermissionUserWrite, PermissionUserInvite, PermissionGroupWrite}
+
+// Group is a team of users of one tenant. Group names are unique within a
+// tenant.
+type Group struct {
+	ID          string    `json:"id"`
+	Tenant      string    `json:"tenant,omitempty"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	// ExternalID is what a provisioning client knows the group by.
+	ExternalID string `json:"external_id,omitempty"`
+}
+
+// Membership puts a user into a group.
+type Membership struct {
+	GroupID string `json:"group_id"`
+	UserID  string `json:"user_id"`
+}
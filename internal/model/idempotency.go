@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// IdempotencyRecord is a request made with an Idempotency-Key and, once it was
+// handled, its answer, replayed when the request is retried. Key is the
+// SHA-256 of the client's key with its tenant and user.
+type IdempotencyRecord struct {
+	Key string `json:"key"`
+	// Fingerprint is the SHA-256 of the method, path and body of the request.
+	Fingerprint string `json:"fingerprint"`
+	// Status is zero while the request is handled.
+	Status    int                 `json:"status,omitempty"`
+	Header    map[string][]string `json:"header,omitempty"`
+	Body      []byte              `json:"body,omitempty"`
+	CreatedAt time.Time           `json:"created_at"`
+	ExpiresAt time.Time           `json:"expires_at"`
+}
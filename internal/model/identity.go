@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// Identity links a user to their account at an upstream identity provider.
+// A user has at most one identity per connector.
+type Identity struct {
+	// Connector is the ID of the configured provider.
+	Connector string `json:"connector"`
+	// Subject is the sub claim the provider knows the account by.
+	Subject string `json:"subject"`
+	UserID  string `json:"user_id"`
+	// Email is the address the provider reported at the last login.
+	Email string `json:"email,omitempty"`
+	// Provisioned is set for users created at their first login. Their
+	// fields follow the provider's claims at every login.
+	Provisioned bool      `json:"provisioned,omitempty"`
+	LinkedAt    time.Time `json:"linked_at"`
+	LastLoginAt time.Time `json:"last_login_at,omitempty"`
+}
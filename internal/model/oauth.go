@@ -0,0 +1,68 @@
+package model
+
+import "time"
+
+// OAuth2 grant types.
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// OAuthClient is an application that delegates login to profileSaver.
+type OAuthClient struct {
+	ID     string `json:"id"`
+	Tenant string `json:"tenant,omitempty"`
+	Name   string `json:"name"`
+	// SecretHash is the SHA-256 of the secret of confidential clients. Public
+	// clients have none and must use PKCE.
+	SecretHash   string    `json:"secret_hash,omitempty"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	GrantTypes   []string  `json:"grant_types"`
+	Scopes       []string  `json:"scopes"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Confidential reports whether c authenticates with a secret.
+func (c OAuthClient) Confidential() bool {
+	return c.SecretHash != ""
+}
+
+// OAuthConsent records the scopes a user allowed a client.
+type OAuthConsent struct {
+	UserID    string    `json:"user_id"`
+	ClientID  string    `json:"client_id"`
+	Scopes    []string  `json:"scopes"`
+	GrantedAt time.Time `json:"granted_at"`
+}
+
+// Kinds of OAuthToken.
+const (
+	OAuthCode    = "code"
+	OAuthAccess  = "access"
+	OAuthRefresh = "refresh"
+)
+
+// OAuthToken is an authorization code, access token or refresh token. ID is
+// the SHA-256 of the token, the token itself is never stored.
+type OAuthToken struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+	ClientID string `json:"client_id"`
+	// UserID is empty for tokens of the client credentials grant.
+	UserID string   `json:"user_id,omitempty"`
+	Tenant string   `json:"tenant,omitempty"`
+	Scopes []string `json:"scopes"`
+	// GrantID is shared by the code and all tokens issued from it, so they
+	// can be revoked together.
+	GrantID string `json:"grant_id"`
+	// RedirectURI, the PKCE challenge and the OpenID Connect nonce are only
+	// set for codes. RedirectURISent is whether the authorization request
+	// named RedirectURI.
+	RedirectURI     string    `json:"redirect_uri,omitempty"`
+	RedirectURISent bool      `json:"redirect_uri_sent,omitempty"`
+	CodeChallenge   string    `json:"code_challenge,omitempty"`
+	Nonce           string    `json:"nonce,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// Algorithms of SigningKey.
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+)
+
+// SigningKey signs ID tokens. The newest key not retired signs, retired keys
+// stay published until the tokens they signed have expired.
+type SigningKey struct {
+	// ID is the kid of the JWS header.
+	ID        string `json:"id"`
+	Algorithm string `json:"algorithm"`
+	// PrivateKey is PKCS #8, DER encoded.
+	PrivateKey []byte    `json:"private_key"`
+	CreatedAt  time.Time `json:"created_at"`
+	// RetiredAt is zero while the key signs.
+	RetiredAt time.Time `json:"retired_at,omitempty"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// Session is a login made through POST /v1/auth/login. ID is the SHA-256 of
+// the token in the session cookie, the token itself is never stored.
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
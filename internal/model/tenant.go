@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// Tenant is an organisation whose users are kept apart from other tenants.
+type Tenant struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Disabled tenants keep their users, who can't sign in.
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"created_at"`
+}
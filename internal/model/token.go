@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Token is a single-use token sent to a user, e.g. to reset a password. ID is
+// the SHA-256 of the token, the token itself is never stored.
+type Token struct {
+	ID      string `json:"id"`
+	Purpose string `json:"purpose"`
+	UserID  string `json:"user_id"`
+	// Tenant the token was issued in.
+	Tenant string `json:"tenant,omitempty"`
+	// Email is the address the token was sent to.
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,19 @@
+package model
+
+// TOTP is the second factor of a user. It is created disabled on enrollment
+// and enabled once the user proved their authenticator works.
+type TOTP struct {
+	UserID string `json:"user_id"`
+	// Secret is the base32 TOTP secret shared with the authenticator.
+	Secret  string `json:"secret"`
+	Enabled bool   `json:"enabled"`
+	// LastStep is the last accepted time step, its code is not accepted again.
+	LastStep      int64          `json:"last_step"`
+	RecoveryCodes []RecoveryCode `json:"recovery_codes"`
+}
+
+// RecoveryCode is a hashed single-use code replacing a TOTP code.
+type RecoveryCode struct {
+	Hash string `json:"hash"`
+	Salt []byte `json:"salt"`
+}
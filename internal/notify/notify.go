@@ -0,0 +1,50 @@
+// Package notify delivers messages such as password reset tokens to users.
+package notify
+
+import (
+	"context"
+	"dev/profileSaver/internal/config"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Message is a plain text message to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var ErrInvalidMessage = errors.New("invalid message")
+
+// Open returns the Notifier for the configured backend.
+func Open(cfg config.Notify) (Notifier, error) {
+	switch cfg.Backend {
+	case config.NotifyLog, "":
+		return NewLog(), nil
+	case config.NotifyFile:
+		return NewFile(cfg.File), nil
+	case config.NotifySMTP:
+		return NewSMTP(cfg.SMTP), nil
+	default:
+		return nil, fmt.Errorf("unsupported notify backend %q", cfg.Backend)
+	}
+}
+
+// check refuses messages that can't be sent safely, e.g. a recipient or
+// subject that would inject mail headers.
+func (m Message) check() error {
+	if m.To == "" {
+		return fmt.Errorf("%w: no recipient", ErrInvalidMessage)
+	}
+	if strings.ContainsAny(m.To, "\r\n") || strings.ContainsAny(m.Subject, "\r\n") {
+		return fmt.Errorf("%w: line break in header", ErrInvalidMessage)
+	}
+
+	return nil
+}
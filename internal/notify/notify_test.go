@@ -0,0 +1,188 @@
+package notify
+
+import (
+	"context"
+	"dev/profileSaver/internal/config"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type received struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP is a minimal SMTP server accepting everything it is sent.
+type fakeSMTP struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	messages []received
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeSMTP{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeSMTP) port() string {
+	_, port, _ := net.SplitHostPort(s.ln.Addr().String())
+
+	return port
+}
+
+func (s *fakeSMTP) received() []received {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]received(nil), s.messages...)
+}
+
+func (s *fakeSMTP) handle(conn net.Conn) {
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 fake ESMTP")
+
+	var msg received
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb := strings.ToUpper(strings.Fields(line + " ")[0])
+		switch verb {
+		case "EHLO", "HELO":
+			_ = tp.PrintfLine("250-fake")
+			_ = tp.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			msg.auth = strings.TrimPrefix(line, "AUTH PLAIN ")
+			_ = tp.PrintfLine("235 ok")
+		case "MAIL":
+			msg.from = line
+			_ = tp.PrintfLine("250 ok")
+		case "RCPT":
+			msg.to = append(msg.to, line)
+			_ = tp.PrintfLine("250 ok")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.data = string(data)
+
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+
+			msg = received{}
+			_ = tp.PrintfLine("250 queued")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestSMTP_Send(t *testing.T) {
+	server := newFakeSMTP(t)
+
+	tests := []struct {
+		name        string
+		cfg         config.SMTP
+		msg         Message
+		expectedErr error
+		expectedTo  []string
+	}{
+		{
+			name: "OK",
+			cfg: config.SMTP{
+				Host: "127.0.0.1", Port: server.port(), From: "noreply@example.com",
+				Username: "mailer", Password: "secret", Timeout: time.Second,
+			},
+			msg:        Message{To: "bob@example.com", Subject: "Reset", Body: "line 1\nline 2"},
+			expectedTo: []string{"RCPT TO:<bob@example.com>"},
+		},
+		{
+			name: "TLS_REQUIRED",
+			cfg: config.SMTP{
+				Host: "127.0.0.1", Port: server.port(), From: "noreply@example.com",
+				RequireTLS: true, Timeout: time.Second,
+			},
+			msg:         Message{To: "bob@example.com", Subject: "Reset"},
+			expectedErr: ErrNoTLS,
+		},
+		{
+			name:        "HEADER_INJECTION",
+			cfg:         config.SMTP{Host: "127.0.0.1", Port: server.port(), From: "noreply@example.com"},
+			msg:         Message{To: "bob@example.com\r\nBcc: eve@example.com", Subject: "Reset"},
+			expectedErr: ErrInvalidMessage,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := len(server.received())
+
+			err := NewSMTP(test.cfg).Send(context.Background(), test.msg)
+			if test.expectedErr != nil {
+				assert.ErrorIs(t, err, test.expectedErr)
+				assert.Len(t, server.received(), before)
+				return
+			}
+			require.NoError(t, err)
+
+			messages := server.received()
+			require.Len(t, messages, before+1)
+
+			got := messages[before]
+			assert.Equal(t, "MAIL FROM:<noreply@example.com>", got.from)
+			assert.Equal(t, test.expectedTo, got.to)
+			assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("\x00mailer\x00secret")), got.auth)
+			assert.Contains(t, got.data, "Subject: Reset\n")
+			assert.Contains(t, got.data, "\n\nline 1\nline 2\n")
+		})
+	}
+}
+
+func TestFile_Send(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.txt")
+	n := NewFile(path)
+
+	require.NoError(t, n.Send(context.Background(), Message{To: "a@example.com", Subject: "one", Body: "first"}))
+	require.NoError(t, n.Send(context.Background(), Message{To: "b@example.com", Subject: "two", Body: "second"}))
+	assert.ErrorIs(t, n.Send(context.Background(), Message{Subject: "none"}), ErrInvalidMessage)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "To: a@example.com\nSubject: one\n\nfirst\n")
+	assert.Contains(t, string(data), "To: b@example.com\nSubject: two\n\nsecond\n")
+}
@@ -0,0 +1,712 @@
+// Package oauth implements the grants of the OAuth2 authorization server:
+// authorization code with PKCE, client credentials and refresh tokens.
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Error codes of RFC 6749.
+const (
+	InvalidRequest          = "invalid_request"
+	InvalidClient           = "invalid_client"
+	InvalidGrant            = "invalid_grant"
+	InvalidScope            = "invalid_scope"
+	UnauthorizedClient      = "unauthorized_client"
+	UnsupportedGrantType    = "unsupported_grant_type"
+	UnsupportedResponseType = "unsupported_response_type"
+	AccessDenied            = "access_denied"
+)
+
+// MethodS256 is the only PKCE code challenge method accepted.
+const MethodS256 = "S256"
+
+var (
+	// ErrInvalidClient wraps the problems ValidateClient finds.
+	ErrInvalidClient = errors.New("invalid client")
+	ErrPublicClient  = errors.New("public clients have no secret")
+)
+
+// Error is an OAuth2 error response.
+type Error struct {
+	Code        string
+	Description string
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func errorf(code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Description: fmt.Sprintf(format, args...)}
+}
+
+// ErrorCode returns the OAuth2 error code of err, empty when err is no *Error.
+func ErrorCode(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	return ""
+}
+
+type Server struct {
+	repo repository.OAuthRepository
+	cfg  config.OAuth
+	now  func() time.Time
+}
+
+// NewServer returns a server keeping clients, consents and tokens in repo.
+// Zero lifetimes in cfg take the defaults of the config package.
+func NewServer(repo repository.OAuthRepository, cfg config.OAuth) *Server {
+	if cfg.CodeTTL <= 0 {
+		cfg.CodeTTL = time.Minute
+	}
+	if cfg.AccessTokenTTL <= 0 {
+		cfg.AccessTokenTTL = time.Hour
+	}
+	if cfg.RefreshTokenTTL <= 0 {
+		cfg.RefreshTokenTTL = 30 * 24 * time.Hour
+	}
+
+	return &Server{
+		repo: repo,
+		cfg:  cfg,
+		now:  time.Now,
+	}
+}
+
+// ID returns the stored ID of a secret, code or token.
+func ID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidateClient checks the settings of c.
+func ValidateClient(c model.OAuthClient) error {
+	var reason []string
+
+	if c.Name == "" {
+		reason = append(reason, "empty name")
+	}
+
+	for _, g := range c.GrantTypes {
+		switch g {
+		case model.GrantAuthorizationCode, model.GrantRefreshToken:
+		case model.GrantClientCredentials:
+			if !c.Confidential() {
+				reason = append(reason, "public clients can't use client_credentials")
+			}
+		default:
+			reason = append(reason, fmt.Sprintf("unknown grant type %q", g))
+		}
+	}
+	if len(c.GrantTypes) == 0 {
+		reason = append(reason, "no grant types")
+	}
+
+	if hasGrant(c, model.GrantAuthorizationCode) && len(c.RedirectURIs) == 0 {
+		reason = append(reason, "authorization_code needs a redirect uri")
+	}
+	for _, uri := range c.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Fragment != "" {
+			reason = append(reason, fmt.Sprintf("invalid redirect uri %q", uri))
+		}
+	}
+
+	for _, s := range c.Scopes {
+		if !validScope(s) {
+			reason = append(reason, fmt.Sprintf("invalid scope %q", s))
+		}
+	}
+
+	if len(reason) != 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidClient, strings.Join(reason, ", "))
+	}
+
+	return nil
+}
+
+// RegisterClient stores c with a new ID. Confidential clients get a secret,
+// returned once; only its hash is stored.
+func (s *Server) RegisterClient(ctx context.Context, c model.OAuthClient, confidential bool) (model.OAuthClient, string, error) {
+	var secret string
+	if confidential {
+		var err error
+		if secret, err = randomToken(); err != nil {
+			return model.OAuthClient{}, "", err
+		}
+		c.SecretHash = ID(secret)
+	}
+
+	if err := ValidateClient(c); err != nil {
+		return model.OAuthClient{}, "", err
+	}
+
+	c.ID = uuid.New().String()
+	c.CreatedAt = s.now()
+
+	if err := s.repo.CreateOAuthClient(ctx, c); err != nil {
+		return model.OAuthClient{}, "", err
+	}
+
+	return c, secret, nil
+}
+
+// RotateSecret gives confidential client c a new secret and returns it, the
+// old one stops working.
+func (s *Server) RotateSecret(ctx context.Context, c model.OAuthClient) (string, error) {
+	if !c.Confidential() {
+		return "", ErrPublicClient
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	c.SecretHash = ID(secret)
+
+	if err = s.repo.UpdateOAuthClient(ctx, c); err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// Client returns client id of any tenant.
+func (s *Server) Client(ctx context.Context, id string) (model.OAuthClient, error) {
+	return s.repo.GetOAuthClient(ctx, id)
+}
+
+// Clients returns the clients of the tenant of ctx.
+func (s *Server) Clients(ctx context.Context) []model.OAuthClient {
+	return s.repo.GetOAuthClients(ctx)
+}
+
+// UpdateClient checks and stores the changed client c.
+func (s *Server) UpdateClient(ctx context.Context, c model.OAuthClient) error {
+	if err := ValidateClient(c); err != nil {
+		return err
+	}
+
+	return s.repo.UpdateOAuthClient(ctx, c)
+}
+
+// DeleteClient deletes client id with its consents and tokens.
+func (s *Server) DeleteClient(ctx context.Context, id string) error {
+	return s.repo.DeleteOAuthClient(ctx, id)
+}
+
+// AuthenticateClient returns client id if secret is its secret, or empty
+// for a public client.
+func (s *Server) AuthenticateClient(ctx context.Context, id, secret string) (model.OAuthClient, error) {
+	c, err := s.repo.GetOAuthClient(ctx, id)
+	if errors.Is(err, repository.ErrClientNotFound) {
+		return model.OAuthClient{}, errorf(InvalidClient, "unknown client")
+	}
+	if err != nil {
+		return model.OAuthClient{}, err
+	}
+
+	if c.Confidential() {
+		if subtle.ConstantTimeCompare([]byte(ID(secret)), []byte(c.SecretHash)) != 1 {
+			return model.OAuthClient{}, errorf(InvalidClient, "wrong client secret")
+		}
+	} else if secret != "" {
+		return model.OAuthClient{}, errorf(InvalidClient, "public clients have no secret")
+	}
+
+	return c, nil
+}
+
+// AuthorizeRequest holds the parameters of the authorization endpoint.
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	// Nonce is the OpenID Connect nonce, returned in the ID token.
+	Nonce string
+}
+
+// Authorization is a checked AuthorizeRequest.
+type Authorization struct {
+	Client      model.OAuthClient
+	RedirectURI string
+	// RedirectURISent is whether the request named RedirectURI, the token
+	// request then has to send it again.
+	RedirectURISent bool
+	Scopes          []string
+	State           string
+	CodeChallenge   string
+	Nonce           string
+}
+
+// Authorize checks r. Errors about the client or redirect URI must not be
+// redirected and come with an empty RedirectURI; the others can be sent to
+// the returned RedirectURI.
+func (s *Server) Authorize(ctx context.Context, r AuthorizeRequest) (Authorization, error) {
+	c, err := s.repo.GetOAuthClient(ctx, r.ClientID)
+	if errors.Is(err, repository.ErrClientNotFound) {
+		return Authorization{}, errorf(InvalidClient, "unknown client")
+	}
+	if err != nil {
+		return Authorization{}, err
+	}
+
+	redirectURI := r.RedirectURI
+	if redirectURI == "" && len(c.RedirectURIs) == 1 {
+		redirectURI = c.RedirectURIs[0]
+	}
+	if !contains(c.RedirectURIs, redirectURI) {
+		return Authorization{}, errorf(InvalidRequest, "redirect_uri is not registered")
+	}
+
+	a := Authorization{
+		Client:          c,
+		RedirectURI:     redirectURI,
+		RedirectURISent: r.RedirectURI != "",
+		State:           r.State,
+		CodeChallenge:   r.CodeChallenge,
+		Nonce:           r.Nonce,
+	}
+
+	if r.ResponseType != "code" {
+		return a, errorf(UnsupportedResponseType, "response_type must be code")
+	}
+	if !hasGrant(c, model.GrantAuthorizationCode) {
+		return a, errorf(UnauthorizedClient, "client may not use authorization_code")
+	}
+
+	if r.CodeChallenge == "" && !c.Confidential() {
+		return a, errorf(InvalidRequest, "public clients must use PKCE")
+	}
+	if r.CodeChallenge != "" && r.CodeChallengeMethod != MethodS256 {
+		return a, errorf(InvalidRequest, "code_challenge_method must be S256")
+	}
+
+	if a.Scopes, err = requestedScopes(r.Scope, c.Scopes); err != nil {
+		return a, err
+	}
+
+	return a, nil
+}
+
+// Consented reports whether userID already allowed the scopes of a.
+func (s *Server) Consented(ctx context.Context, userID string, a Authorization) bool {
+	c, err := s.repo.GetConsent(ctx, userID, a.Client.ID)
+	if err != nil {
+		return false
+	}
+
+	for _, scope := range a.Scopes {
+		if !contains(c.Scopes, scope) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Consent records that userID allows the scopes of a, on top of what they
+// allowed before.
+func (s *Server) Consent(ctx context.Context, userID string, a Authorization) error {
+	scopes := append([]string{}, a.Scopes...)
+	if old, err := s.repo.GetConsent(ctx, userID, a.Client.ID); err == nil {
+		for _, scope := range old.Scopes {
+			if !contains(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return s.repo.SaveConsent(ctx, model.OAuthConsent{
+		UserID:    userID,
+		ClientID:  a.Client.ID,
+		Scopes:    scopes,
+		GrantedAt: s.now(),
+	})
+}
+
+// Consents returns the consents userID gave.
+func (s *Server) Consents(ctx context.Context, userID string) []model.OAuthConsent {
+	return s.repo.GetConsentsByUser(ctx, userID)
+}
+
+// Withdraw drops the consent of userID to clientID and the tokens the client
+// holds for them.
+func (s *Server) Withdraw(ctx context.Context, userID, clientID string) error {
+	return s.repo.DeleteConsent(ctx, userID, clientID)
+}
+
+// RevokeUser drops the consents of userID and every token issued for them.
+func (s *Server) RevokeUser(ctx context.Context, userID string) error {
+	return s.repo.DeleteUserOAuthGrants(ctx, userID)
+}
+
+// IssueCode returns an authorization code for userID.
+func (s *Server) IssueCode(ctx context.Context, userID string, a Authorization) (string, error) {
+	code, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := s.now()
+	err = s.repo.CreateOAuthToken(ctx, model.OAuthToken{
+		ID:              ID(code),
+		Kind:            model.OAuthCode,
+		ClientID:        a.Client.ID,
+		UserID:          userID,
+		Tenant:          a.Client.Tenant,
+		Scopes:          a.Scopes,
+		GrantID:         uuid.New().String(),
+		RedirectURI:     a.RedirectURI,
+		RedirectURISent: a.RedirectURISent,
+		CodeChallenge:   a.CodeChallenge,
+		Nonce:           a.Nonce,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(s.cfg.CodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// Redirect returns the redirect URI of a with params and the state added.
+func Redirect(a Authorization, params url.Values) string {
+	u, err := url.Parse(a.RedirectURI)
+	if err != nil {
+		return a.RedirectURI
+	}
+
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if a.State != "" {
+		q.Set("state", a.State)
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+// TokenRequest holds the parameters of the token endpoint.
+type TokenRequest struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+	// Check, when set, vets the token about to be issued, e.g. that its
+	// user and tenant are still enabled. Its error refuses the grant.
+	Check func(ctx context.Context, t model.OAuthToken) error
+}
+
+// Tokens is the outcome of a grant.
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+	Scopes       []string
+	// Access is the stored access token.
+	Access model.OAuthToken
+	// Nonce is the nonce of the exchanged code.
+	Nonce string
+}
+
+// Token runs the grant of r for the authenticated client c.
+func (s *Server) Token(ctx context.Context, c model.OAuthClient, r TokenRequest) (Tokens, error) {
+	switch r.GrantType {
+	case model.GrantAuthorizationCode, model.GrantClientCredentials, model.GrantRefreshToken:
+	default:
+		return Tokens{}, errorf(UnsupportedGrantType, "unsupported grant_type %q", r.GrantType)
+	}
+
+	if !hasGrant(c, r.GrantType) {
+		return Tokens{}, errorf(UnauthorizedClient, "client may not use %s", r.GrantType)
+	}
+
+	switch r.GrantType {
+	case model.GrantAuthorizationCode:
+		return s.exchangeCode(ctx, c, r)
+	case model.GrantRefreshToken:
+		return s.refresh(ctx, c, r)
+	}
+
+	scopes, err := requestedScopes(r.Scope, c.Scopes)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	t := model.OAuthToken{
+		ClientID: c.ID,
+		Tenant:   c.Tenant,
+		Scopes:   scopes,
+		GrantID:  uuid.New().String(),
+	}
+	if err = r.check(ctx, t); err != nil {
+		return Tokens{}, err
+	}
+
+	return s.issue(ctx, t, false)
+}
+
+// check runs r.Check on t.
+func (r TokenRequest) check(ctx context.Context, t model.OAuthToken) error {
+	if r.Check == nil {
+		return nil
+	}
+
+	if err := r.Check(ctx, t); err != nil {
+		return errorf(InvalidGrant, "%s", err)
+	}
+
+	return nil
+}
+
+func (s *Server) exchangeCode(ctx context.Context, c model.OAuthClient, r TokenRequest) (Tokens, error) {
+	code, err := s.repo.ConsumeOAuthToken(ctx, ID(r.Code), model.OAuthCode)
+	if errors.Is(err, repository.ErrOAuthTokenNotFound) {
+		return Tokens{}, errorf(InvalidGrant, "invalid or used code")
+	}
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if code.ClientID != c.ID || !s.now().Before(code.ExpiresAt) {
+		return Tokens{}, errorf(InvalidGrant, "invalid or expired code")
+	}
+
+	if (code.RedirectURISent || r.RedirectURI != "") && r.RedirectURI != code.RedirectURI {
+		return Tokens{}, errorf(InvalidGrant, "redirect_uri doesn't match the authorization request")
+	}
+
+	if code.CodeChallenge != "" || r.CodeVerifier != "" {
+		if !VerifyPKCE(code.CodeChallenge, r.CodeVerifier) {
+			return Tokens{}, errorf(InvalidGrant, "code_verifier doesn't match the code_challenge")
+		}
+	}
+
+	t := model.OAuthToken{
+		ClientID: c.ID,
+		UserID:   code.UserID,
+		Tenant:   code.Tenant,
+		Scopes:   code.Scopes,
+		GrantID:  code.GrantID,
+	}
+	if err = r.check(ctx, t); err != nil {
+		return Tokens{}, err
+	}
+
+	tokens, err := s.issue(ctx, t, hasGrant(c, model.GrantRefreshToken))
+	tokens.Nonce = code.Nonce
+
+	return tokens, err
+}
+
+func (s *Server) refresh(ctx context.Context, c model.OAuthClient, r TokenRequest) (Tokens, error) {
+	old, err := s.repo.ConsumeOAuthToken(ctx, ID(r.RefreshToken), model.OAuthRefresh)
+	if errors.Is(err, repository.ErrOAuthTokenNotFound) {
+		return Tokens{}, errorf(InvalidGrant, "invalid or used refresh token")
+	}
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if old.ClientID != c.ID || !s.now().Before(old.ExpiresAt) {
+		return Tokens{}, errorf(InvalidGrant, "invalid or expired refresh token")
+	}
+
+	scopes, err := requestedScopes(r.Scope, old.Scopes)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	t := model.OAuthToken{
+		ClientID: c.ID,
+		UserID:   old.UserID,
+		Tenant:   old.Tenant,
+		Scopes:   scopes,
+		GrantID:  old.GrantID,
+	}
+	if err = r.check(ctx, t); err != nil {
+		return Tokens{}, err
+	}
+
+	return s.issue(ctx, t, true)
+}
+
+// issue stores an access token like t, and a refresh token when asked.
+func (s *Server) issue(ctx context.Context, t model.OAuthToken, withRefresh bool) (Tokens, error) {
+	access, err := randomToken()
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	now := s.now()
+	t.CreatedAt = now
+
+	a := t
+	a.ID = ID(access)
+	a.Kind = model.OAuthAccess
+	a.ExpiresAt = now.Add(s.cfg.AccessTokenTTL)
+	if err = s.repo.CreateOAuthToken(ctx, a); err != nil {
+		return Tokens{}, err
+	}
+
+	tokens := Tokens{
+		AccessToken: access,
+		ExpiresIn:   s.cfg.AccessTokenTTL,
+		Scopes:      t.Scopes,
+		Access:      a,
+	}
+
+	if withRefresh {
+		if tokens.RefreshToken, err = randomToken(); err != nil {
+			return Tokens{}, err
+		}
+
+		rt := t
+		rt.ID = ID(tokens.RefreshToken)
+		rt.Kind = model.OAuthRefresh
+		rt.ExpiresAt = now.Add(s.cfg.RefreshTokenTTL)
+		if err = s.repo.CreateOAuthToken(ctx, rt); err != nil {
+			return Tokens{}, err
+		}
+	}
+
+	return tokens, nil
+}
+
+// Introspect returns the live access or refresh token token.
+func (s *Server) Introspect(ctx context.Context, token string) (model.OAuthToken, bool) {
+	t, err := s.repo.GetOAuthToken(ctx, ID(token))
+	if err != nil || t.Kind == model.OAuthCode || !s.now().Before(t.ExpiresAt) {
+		return model.OAuthToken{}, false
+	}
+
+	return t, true
+}
+
+// Revoke revokes token if it was issued to client c. Revoking a refresh
+// token revokes every token of its grant. Unknown tokens are ignored.
+func (s *Server) Revoke(ctx context.Context, c model.OAuthClient, token string) error {
+	t, err := s.repo.GetOAuthToken(ctx, ID(token))
+	if errors.Is(err, repository.ErrOAuthTokenNotFound) || err == nil && t.ClientID != c.ID {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if t.Kind == model.OAuthRefresh {
+		return s.repo.DeleteOAuthGrant(ctx, t.GrantID)
+	}
+
+	err = s.repo.DeleteOAuthToken(ctx, t.ID)
+	if errors.Is(err, repository.ErrOAuthTokenNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// Prune deletes expired codes and tokens.
+func (s *Server) Prune(ctx context.Context) error {
+	return s.repo.DeleteExpiredOAuthTokens(ctx, s.now())
+}
+
+// VerifyPKCE reports whether verifier matches an S256 challenge.
+func VerifyPKCE(challenge, verifier string) bool {
+	if challenge == "" || len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+
+	return subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(challenge)) == 1
+}
+
+// ParseScope splits a space separated scope parameter.
+func ParseScope(scope string) []string {
+	return strings.Fields(scope)
+}
+
+// requestedScopes returns the scopes of a scope parameter, all of allowed when
+// it is empty.
+func requestedScopes(scope string, allowed []string) ([]string, error) {
+	requested := ParseScope(scope)
+	if len(requested) == 0 {
+		return append([]string{}, allowed...), nil
+	}
+
+	for _, s := range requested {
+		if !contains(allowed, s) {
+			return nil, errorf(InvalidScope, "scope %q is not allowed", s)
+		}
+	}
+
+	return requested, nil
+}
+
+// validScope follows the scope-token syntax of RFC 6749.
+func validScope(scope string) bool {
+	if scope == "" {
+		return false
+	}
+
+	for _, r := range scope {
+		if r < 0x21 || r > 0x7e || r == '"' || r == '\\' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasGrant(c model.OAuthClient, grant string) bool {
+	return contains(c.GrantTypes, grant)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
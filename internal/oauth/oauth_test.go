@@ -0,0 +1,225 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+func challenge(v string) string {
+	sum := sha256.Sum256([]byte(v))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestServer_AuthorizationCode(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewServer(repository.New(), config.OAuth{})
+	s.now = func() time.Time { return now }
+
+	c, secret, err := s.RegisterClient(ctx, model.OAuthClient{
+		Name:         "spa",
+		RedirectURIs: []string{"https://app.test/cb"},
+		GrantTypes:   []string{model.GrantAuthorizationCode, model.GrantRefreshToken},
+		Scopes:       []string{"read", "write"},
+	}, false)
+	require.NoError(t, err)
+	assert.Empty(t, secret, "public clients have no secret")
+
+	tests := []struct {
+		name     string
+		req      AuthorizeRequest
+		code     string
+		redirect bool
+	}{
+		{name: "UNKNOWN_CLIENT", req: AuthorizeRequest{ClientID: "nope"}, code: InvalidClient},
+		{name: "WRONG_REDIRECT", req: AuthorizeRequest{ClientID: c.ID, RedirectURI: "https://evil.test/cb"}, code: InvalidRequest},
+		{name: "RESPONSE_TYPE", req: AuthorizeRequest{ClientID: c.ID, ResponseType: "token"}, code: UnsupportedResponseType, redirect: true},
+		{name: "NO_PKCE", req: AuthorizeRequest{ClientID: c.ID, ResponseType: "code"}, code: InvalidRequest, redirect: true},
+		{name: "PLAIN_PKCE", req: AuthorizeRequest{ClientID: c.ID, ResponseType: "code", CodeChallenge: verifier,
+			CodeChallengeMethod: "plain"}, code: InvalidRequest, redirect: true},
+		{name: "SCOPE", req: AuthorizeRequest{ClientID: c.ID, ResponseType: "code", CodeChallenge: challenge(verifier),
+			CodeChallengeMethod: MethodS256, Scope: "admin"}, code: InvalidScope, redirect: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := s.Authorize(ctx, test.req)
+			assert.Equal(t, test.code, ErrorCode(err))
+			assert.Equal(t, test.redirect, a.RedirectURI != "")
+		})
+	}
+
+	a, err := s.Authorize(ctx, AuthorizeRequest{ClientID: c.ID, ResponseType: "code", Scope: "read", State: "xyz",
+		CodeChallenge: challenge(verifier), CodeChallengeMethod: MethodS256})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read"}, a.Scopes)
+
+	assert.False(t, s.Consented(ctx, "u1", a))
+
+	code, err := s.IssueCode(ctx, "u1", a)
+	require.NoError(t, err)
+
+	location, err := url.Parse(Redirect(a, url.Values{"code": {code}}))
+	require.NoError(t, err)
+	assert.Equal(t, "xyz", location.Query().Get("state"))
+	assert.Equal(t, code, location.Query().Get("code"))
+
+	exchange := TokenRequest{GrantType: model.GrantAuthorizationCode, Code: code, RedirectURI: "https://app.test/cb"}
+
+	_, err = s.Token(ctx, c, exchange)
+	assert.Equal(t, InvalidGrant, ErrorCode(err), "the verifier is missing")
+
+	code, err = s.IssueCode(ctx, "u1", a)
+	require.NoError(t, err)
+	exchange.Code = code
+	exchange.CodeVerifier = verifier
+
+	tokens, err := s.Token(ctx, c, exchange)
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	assert.Equal(t, time.Hour, tokens.ExpiresIn)
+
+	_, err = s.Token(ctx, c, exchange)
+	assert.Equal(t, InvalidGrant, ErrorCode(err), "codes are single-use")
+
+	access, ok := s.Introspect(ctx, tokens.AccessToken)
+	require.True(t, ok)
+	assert.Equal(t, "u1", access.UserID)
+	assert.Equal(t, []string{"read"}, access.Scopes)
+
+	refreshed, err := s.Token(ctx, c, TokenRequest{GrantType: model.GrantRefreshToken, RefreshToken: tokens.RefreshToken})
+	require.NoError(t, err)
+	assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken, "refresh tokens rotate")
+
+	_, err = s.Token(ctx, c, TokenRequest{GrantType: model.GrantRefreshToken, RefreshToken: tokens.RefreshToken})
+	assert.Equal(t, InvalidGrant, ErrorCode(err))
+
+	_, err = s.Token(ctx, c, TokenRequest{GrantType: model.GrantRefreshToken, RefreshToken: refreshed.RefreshToken, Scope: "write"})
+	assert.Equal(t, InvalidScope, ErrorCode(err), "refreshing can't widen the scopes")
+
+	require.NoError(t, s.Revoke(ctx, c, tokens.AccessToken))
+	_, ok = s.Introspect(ctx, tokens.AccessToken)
+	assert.False(t, ok)
+
+	now = now.Add(2 * time.Hour)
+	_, ok = s.Introspect(ctx, refreshed.AccessToken)
+	assert.False(t, ok, "expired")
+}
+
+func TestServer_RedirectURI(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(repository.New(), config.OAuth{})
+
+	c, _, err := s.RegisterClient(ctx, model.OAuthClient{
+		Name:         "spa",
+		RedirectURIs: []string{"https://app.test/cb"},
+		GrantTypes:   []string{model.GrantAuthorizationCode},
+		Scopes:       []string{"read"},
+	}, false)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		authorize string
+		exchange  string
+		ok        bool
+	}{
+		{name: "SENT_TWICE", authorize: "https://app.test/cb", exchange: "https://app.test/cb", ok: true},
+		{name: "NEVER_SENT", ok: true},
+		{name: "SENT_ON_EXCHANGE", exchange: "https://app.test/cb", ok: true},
+		{name: "NOT_SENT_AGAIN", authorize: "https://app.test/cb"},
+		{name: "OTHER_ON_EXCHANGE", exchange: "https://evil.test/cb"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := s.Authorize(ctx, AuthorizeRequest{ClientID: c.ID, ResponseType: "code", RedirectURI: test.authorize,
+				CodeChallenge: challenge(verifier), CodeChallengeMethod: MethodS256})
+			require.NoError(t, err)
+
+			code, err := s.IssueCode(ctx, "u1", a)
+			require.NoError(t, err)
+
+			_, err = s.Token(ctx, c, TokenRequest{GrantType: model.GrantAuthorizationCode, Code: code,
+				RedirectURI: test.exchange, CodeVerifier: verifier})
+			if test.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, InvalidGrant, ErrorCode(err))
+			}
+		})
+	}
+}
+
+func TestServer_ClientCredentials(t *testing.T) {
+	ctx := context.Background()
+	s := NewServer(repository.New(), config.OAuth{AccessTokenTTL: time.Minute})
+
+	_, _, err := s.RegisterClient(ctx, model.OAuthClient{Name: "cron", GrantTypes: []string{model.GrantClientCredentials}}, false)
+	assert.Error(t, err, "public clients can't use client credentials")
+
+	c, secret, err := s.RegisterClient(ctx, model.OAuthClient{
+		Name:       "cron",
+		GrantTypes: []string{model.GrantClientCredentials},
+		Scopes:     []string{"reports"},
+	}, true)
+	require.NoError(t, err)
+	assert.NotContains(t, c.SecretHash, secret)
+
+	_, err = s.AuthenticateClient(ctx, c.ID, "wrong")
+	assert.Equal(t, InvalidClient, ErrorCode(err))
+	c, err = s.AuthenticateClient(ctx, c.ID, secret)
+	require.NoError(t, err)
+
+	tokens, err := s.Token(ctx, c, TokenRequest{GrantType: model.GrantClientCredentials})
+	require.NoError(t, err)
+	assert.Empty(t, tokens.RefreshToken)
+	assert.Equal(t, []string{"reports"}, tokens.Scopes)
+	assert.Empty(t, tokens.Access.UserID)
+
+	_, err = s.Token(ctx, c, TokenRequest{GrantType: model.GrantAuthorizationCode})
+	assert.Equal(t, UnauthorizedClient, ErrorCode(err))
+	_, err = s.Token(ctx, c, TokenRequest{GrantType: "password"})
+	assert.Equal(t, UnsupportedGrantType, ErrorCode(err))
+
+	rotated, err := s.RotateSecret(ctx, c)
+	require.NoError(t, err)
+	_, err = s.AuthenticateClient(ctx, c.ID, secret)
+	assert.Equal(t, InvalidClient, ErrorCode(err))
+	_, err = s.AuthenticateClient(ctx, c.ID, rotated)
+	assert.NoError(t, err)
+}
+
+func TestValidateClient(t *testing.T) {
+	err := ValidateClient(model.OAuthClient{
+		Name:         "x",
+		GrantTypes:   []string{model.GrantAuthorizationCode, "implicit"},
+		RedirectURIs: []string{"/relative", "https://app.test/cb#frag"},
+		Scopes:       []string{"ok", `bad"`},
+	})
+	require.Error(t, err)
+
+	for _, problem := range []string{`"implicit"`, `"/relative"`, `"https://app.test/cb#frag"`, `"bad\""`} {
+		assert.True(t, strings.Contains(err.Error(), problem), problem)
+	}
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	assert.True(t, VerifyPKCE(challenge(verifier), verifier))
+	assert.False(t, VerifyPKCE(challenge(verifier), verifier[1:]+"x"))
+	assert.False(t, VerifyPKCE("", verifier))
+	assert.False(t, VerifyPKCE(challenge("short"), "short"))
+}
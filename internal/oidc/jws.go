@@ -0,0 +1,232 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"dev/profileSaver/internal/model"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed or whose
+	// signature doesn't check out.
+	ErrInvalidToken = errors.New("invalid token")
+	ErrUnknownKey   = errors.New("unknown signing key")
+)
+
+// JWK is a public key of a JSON Web Key Set (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg"`
+	// N and E are set for RSA keys.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Crv, X and Y are set for EC keys.
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK describes the public key pub, which signs with alg under kid.
+func NewJWK(kid, alg string, pub crypto.PublicKey) (JWK, error) {
+	k := JWK{Kid: kid, Use: "sig", Alg: alg}
+
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		k.Kty = "RSA"
+		k.N = b64(pub.N.Bytes())
+		k.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return JWK{}, fmt.Errorf("unsupported curve %s", pub.Curve.Params().Name)
+		}
+		k.Kty = "EC"
+		k.Crv = "P-256"
+		k.X = b64(pub.X.FillBytes(make([]byte, 32)))
+		k.Y = b64(pub.Y.FillBytes(make([]byte, 32)))
+	default:
+		return JWK{}, fmt.Errorf("unsupported key type %T", pub)
+	}
+
+	return k, nil
+}
+
+// PublicKey returns the key k describes.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA exponent of key %q", k.Kid)
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q of key %q", k.Crv, k.Kid)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("key %q is not on its curve", k.Kid)
+		}
+
+		return pub, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q of key %q", k.Kty, k.Kid)
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// Sign returns claims as a compact JWS (RFC 7515) signed with key, an RSA
+// key for RS256 or a P-256 key for ES256.
+func Sign(kid string, key crypto.PrivateKey, claims interface{}) (string, error) {
+	h := header{Typ: "JWT", Kid: kid}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		h.Alg = model.AlgRS256
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return "", fmt.Errorf("unsupported curve %s", key.Curve.Params().Name)
+		}
+		h.Alg = model.AlgES256
+	default:
+		return "", fmt.Errorf("unsupported key type %T", key)
+	}
+
+	head, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	input := b64(head) + "." + b64(payload)
+	sum := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:]); err != nil {
+			return "", err
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, sum[:])
+		if err != nil {
+			return "", err
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+
+	return input + "." + b64(sig), nil
+}
+
+// Verify checks the signature of token against the key of keys named by its
+// header and decodes its claims into v. It doesn't look at the claims.
+func Verify(token string, keys []JWK, v interface{}) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrInvalidToken
+	}
+
+	head, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return ErrInvalidToken
+	}
+	var h header
+	if err = json.Unmarshal(head, &h); err != nil {
+		return ErrInvalidToken
+	}
+
+	var jwk *JWK
+	for i := range keys {
+		if keys[i].Kid == h.Kid || h.Kid == "" && len(keys) == 1 {
+			jwk = &keys[i]
+			break
+		}
+	}
+	if jwk == nil {
+		return fmt.Errorf("%w %q", ErrUnknownKey, h.Kid)
+	}
+	// The algorithm comes from the key, never from the token alone.
+	if jwk.Alg != "" && jwk.Alg != h.Alg {
+		return ErrInvalidToken
+	}
+
+	pub, err := jwk.PublicKey()
+	if err != nil {
+		return err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return ErrInvalidToken
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		if h.Alg != model.AlgRS256 || rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) != nil {
+			return ErrInvalidToken
+		}
+	case *ecdsa.PublicKey:
+		if h.Alg != model.AlgES256 || len(sig) != 64 {
+			return ErrInvalidToken
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(pub, sum[:], r, s) {
+			return ErrInvalidToken
+		}
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err = json.Unmarshal(payload, v); err != nil {
+		return ErrInvalidToken
+	}
+
+	return nil
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,375 @@
+// Package oidc is the OpenID Connect layer of the OAuth2 server: signing keys
+// with rotation, ID tokens and the claims of the userinfo endpoint.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/tenant"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sync"
+	"time"
+)
+
+// Scopes with a meaning in OpenID Connect. ScopeOpenID asks for an ID token,
+// the others for the claims about the user.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// Audience is the aud claim, a single string or a list of them.
+type Audience []string
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+
+	return json.Marshal([]string(a))
+}
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = Audience{one}
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+
+	return nil
+}
+
+// Contains reports whether clientID is among the audience.
+func (a Audience) Contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Claims are the claims of ID tokens and of the userinfo endpoint.
+type Claims struct {
+	Issuer          string   `json:"iss,omitempty"`
+	Subject         string   `json:"sub"`
+	Audience        Audience `json:"aud,omitempty"`
+	ExpiresAt       int64    `json:"exp,omitempty"`
+	IssuedAt        int64    `json:"iat,omitempty"`
+	Nonce           string   `json:"nonce,omitempty"`
+	AccessTokenHash string   `json:"at_hash,omitempty"`
+	// Username and Tenant come with the profile scope.
+	Username string `json:"preferred_username,omitempty"`
+	Tenant   string `json:"tenant,omitempty"`
+	// Email and EmailVerified come with the email scope.
+	Email         string `json:"email,omitempty"`
+	EmailVerified *bool  `json:"email_verified,omitempty"`
+}
+
+// UserInfo returns the claims about u the scopes allow.
+func UserInfo(u model.User, scopes []string) Claims {
+	c := Claims{Subject: u.ID}
+
+	for _, s := range scopes {
+		switch s {
+		case ScopeProfile:
+			c.Username = u.Username
+			c.Tenant = u.Tenant
+		case ScopeEmail:
+			if u.Email != "" {
+				verified := u.EmailVerified
+				c.Email = u.Email
+				c.EmailVerified = &verified
+			}
+		}
+	}
+
+	return c
+}
+
+type Provider struct {
+	repo repository.SigningKeyRepository
+	cfg  config.OIDC
+	now  func() time.Time
+	// mu keeps concurrent requests from rotating the key twice.
+	mu sync.Mutex
+}
+
+// NewProvider returns a provider keeping its signing keys in repo. Zero
+// settings in cfg take the defaults of the config package.
+func NewProvider(repo repository.SigningKeyRepository, cfg config.OIDC) *Provider {
+	if cfg.Algorithm == "" {
+		cfg.Algorithm = config.SigningRS256
+	}
+	if cfg.KeyRotation <= 0 {
+		cfg.KeyRotation = 30 * 24 * time.Hour
+	}
+	if cfg.IDTokenTTL <= 0 {
+		cfg.IDTokenTTL = time.Hour
+	}
+
+	return &Provider{
+		repo: repo,
+		cfg:  cfg,
+		now:  time.Now,
+	}
+}
+
+// Issuer returns the issuer of tenantID. base, the URL the request came in
+// at, is used unless an issuer is configured.
+func (p *Provider) Issuer(base, tenantID string) string {
+	issuer := p.cfg.Issuer
+	if issuer == "" {
+		issuer = base
+	}
+
+	if tenantID != tenant.Default {
+		issuer += tenant.PathPrefix + tenantID
+	}
+
+	return issuer
+}
+
+// IDTokenRequest describes the ID token to issue along with AccessToken.
+type IDTokenRequest struct {
+	Issuer      string
+	ClientID    string
+	User        model.User
+	Scopes      []string
+	Nonce       string
+	AccessToken string
+}
+
+// IDToken returns a signed ID token for r.
+func (p *Provider) IDToken(ctx context.Context, r IDTokenRequest) (string, error) {
+	k, err := p.signingKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("signing key %q: %w", k.ID, err)
+	}
+
+	now := p.now()
+	claims := UserInfo(r.User, r.Scopes)
+	claims.Issuer = r.Issuer
+	claims.Audience = Audience{r.ClientID}
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = now.Add(p.cfg.IDTokenTTL).Unix()
+	claims.Nonce = r.Nonce
+	if r.AccessToken != "" {
+		sum := sha256.Sum256([]byte(r.AccessToken))
+		claims.AccessTokenHash = b64(sum[:len(sum)/2])
+	}
+
+	return Sign(k.ID, key, claims)
+}
+
+// Verify checks that token was signed by one of the published keys and has
+// not expired.
+func (p *Provider) Verify(ctx context.Context, token string) (Claims, error) {
+	keys, err := p.Keys(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	var c Claims
+	if err = Verify(token, keys.Keys, &c); err != nil {
+		return Claims{}, err
+	}
+
+	if p.now().Unix() >= c.ExpiresAt {
+		return Claims{}, ErrInvalidToken
+	}
+
+	return c, nil
+}
+
+// Keys returns the public keys ID tokens may be signed with: the current one
+// and retired ones whose tokens may still be valid.
+func (p *Provider) Keys(ctx context.Context) (JWKS, error) {
+	if _, err := p.signingKey(ctx); err != nil {
+		return JWKS{}, err
+	}
+
+	set := JWKS{Keys: make([]JWK, 0)}
+	for _, k := range p.repo.GetSigningKeys(ctx) {
+		if !p.published(k) {
+			continue
+		}
+
+		jwk, err := PublicJWK(k)
+		if err != nil {
+			return JWKS{}, err
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set, nil
+}
+
+// PublicJWK returns the public half of k.
+func PublicJWK(k model.SigningKey) (JWK, error) {
+	key, err := x509.ParsePKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return JWK{}, fmt.Errorf("signing key %q: %w", k.ID, err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return JWK{}, fmt.Errorf("signing key %q: unsupported key type %T", k.ID, key)
+	}
+
+	jwk, err := NewJWK(k.ID, k.Algorithm, signer.Public())
+	if err != nil {
+		return JWK{}, fmt.Errorf("signing key %q: %w", k.ID, err)
+	}
+
+	return jwk, nil
+}
+
+// Algorithms returns the algorithms of the published keys.
+func (p *Provider) Algorithms(ctx context.Context) []string {
+	algs := []string{p.cfg.Algorithm}
+	for _, k := range p.repo.GetSigningKeys(ctx) {
+		if p.published(k) && !contains(algs, k.Algorithm) {
+			algs = append(algs, k.Algorithm)
+		}
+	}
+
+	return algs
+}
+
+// Rotate retires the current signing key and returns its successor.
+func (p *Provider) Rotate(ctx context.Context) (model.SigningKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.rotate(ctx)
+}
+
+// Prune rotates the signing key when it is due and deletes retired keys no
+// valid token was signed with.
+func (p *Provider) Prune(ctx context.Context) error {
+	if _, err := p.signingKey(ctx); err != nil {
+		return err
+	}
+
+	for _, k := range p.repo.GetSigningKeys(ctx) {
+		if p.published(k) {
+			continue
+		}
+
+		err := p.repo.DeleteSigningKey(ctx, k.ID)
+		if err != nil && !errors.Is(err, repository.ErrSigningKeyNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// signingKey returns the key that signs now, rotating it when it is due or
+// the configured algorithm changed.
+func (p *Provider) signingKey(ctx context.Context) (model.SigningKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, k := range p.repo.GetSigningKeys(ctx) {
+		if !k.RetiredAt.IsZero() {
+			continue
+		}
+
+		if k.Algorithm == p.cfg.Algorithm && p.now().Sub(k.CreatedAt) < p.cfg.KeyRotation {
+			return k, nil
+		}
+		break
+	}
+
+	return p.rotate(ctx)
+}
+
+// rotate is Rotate for callers holding mu.
+func (p *Provider) rotate(ctx context.Context) (model.SigningKey, error) {
+	key, err := generateKey(p.cfg.Algorithm)
+	if err != nil {
+		return model.SigningKey{}, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return model.SigningKey{}, err
+	}
+
+	now := p.now()
+	k := model.SigningKey{
+		ID:         uuid.New().String(),
+		Algorithm:  p.cfg.Algorithm,
+		PrivateKey: der,
+		CreatedAt:  now,
+	}
+
+	old := p.repo.GetSigningKeys(ctx)
+	if err = p.repo.CreateSigningKey(ctx, k); err != nil {
+		return model.SigningKey{}, err
+	}
+
+	for _, o := range old {
+		if o.RetiredAt.IsZero() {
+			if err = p.repo.RetireSigningKey(ctx, o.ID, now); err != nil {
+				return model.SigningKey{}, err
+			}
+		}
+	}
+
+	return k, nil
+}
+
+// published reports whether k signs or may have signed a token that is still
+// valid.
+func (p *Provider) published(k model.SigningKey) bool {
+	return k.RetiredAt.IsZero() || p.now().Before(k.RetiredAt.Add(p.cfg.IDTokenTTL))
+}
+
+func generateKey(alg string) (crypto.PrivateKey, error) {
+	switch alg {
+	case model.AlgRS256:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case model.AlgES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+
+	return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,182 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	rsaJWK, err := NewJWK("r", model.AlgRS256, &rsaKey.PublicKey)
+	require.NoError(t, err)
+	ecJWK, err := NewJWK("e", model.AlgES256, &ecKey.PublicKey)
+	require.NoError(t, err)
+	keys := []JWK{rsaJWK, ecJWK}
+
+	tests := []struct {
+		name string
+		kid  string
+		key  interface{}
+	}{
+		{name: "RS256", kid: "r", key: rsaKey},
+		{name: "ES256", kid: "e", key: ecKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, err := Sign(test.kid, test.key, Claims{Subject: "bob", Audience: Audience{"spa"}})
+			require.NoError(t, err)
+
+			var c Claims
+			require.NoError(t, Verify(token, keys, &c))
+			assert.Equal(t, "bob", c.Subject)
+			assert.True(t, c.Audience.Contains("spa"))
+
+			parts := strings.Split(token, ".")
+			forged := parts[0] + "." + b64([]byte(`{"sub":"admin"}`)) + "." + parts[2]
+			assert.ErrorIs(t, Verify(forged, keys, &c), ErrInvalidToken)
+
+			other := test.kid + "x"
+			assert.ErrorIs(t, Verify(token, []JWK{{Kid: other}}, &c), ErrUnknownKey)
+		})
+	}
+
+	t.Run("NOT_OK_ALG_NONE", func(t *testing.T) {
+		token := b64([]byte(`{"alg":"none","kid":"r"}`)) + "." + b64([]byte(`{"sub":"admin"}`)) + "."
+
+		var c Claims
+		assert.ErrorIs(t, Verify(token, keys, &c), ErrInvalidToken)
+	})
+
+	t.Run("NOT_OK_KEY_CONFUSION", func(t *testing.T) {
+		token, err := Sign("r", ecKey, Claims{Subject: "bob"})
+		require.NoError(t, err)
+
+		var c Claims
+		assert.ErrorIs(t, Verify(token, keys, &c), ErrInvalidToken, "the key decides the algorithm")
+	})
+}
+
+func TestAudience(t *testing.T) {
+	data, err := json.Marshal(Audience{"spa"})
+	require.NoError(t, err)
+	assert.Equal(t, `"spa"`, string(data))
+
+	var a Audience
+	require.NoError(t, json.Unmarshal([]byte(`["spa","api"]`), &a))
+	assert.True(t, a.Contains("api"))
+	require.NoError(t, json.Unmarshal([]byte(`"spa"`), &a))
+	assert.Equal(t, Audience{"spa"}, a)
+}
+
+func TestProvider_IDToken(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	p := NewProvider(repository.New(), config.OIDC{Algorithm: config.SigningES256})
+	p.now = func() time.Time { return now }
+
+	u := model.User{ID: "u1", Username: "bob", Email: "bob@example.com", EmailVerified: true, Tenant: "acme"}
+
+	tests := []struct {
+		name     string
+		scopes   []string
+		username string
+		email    string
+	}{
+		{name: "OPENID", scopes: []string{ScopeOpenID}},
+		{name: "PROFILE", scopes: []string{ScopeOpenID, ScopeProfile}, username: "bob"},
+		{name: "EMAIL", scopes: []string{ScopeOpenID, ScopeEmail}, email: "bob@example.com"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, err := p.IDToken(ctx, IDTokenRequest{
+				Issuer:      "https://id.test",
+				ClientID:    "spa",
+				User:        u,
+				Scopes:      test.scopes,
+				Nonce:       "n1",
+				AccessToken: "access",
+			})
+			require.NoError(t, err)
+
+			c, err := p.Verify(ctx, token)
+			require.NoError(t, err)
+			assert.Equal(t, "https://id.test", c.Issuer)
+			assert.Equal(t, "u1", c.Subject)
+			assert.Equal(t, Audience{"spa"}, c.Audience)
+			assert.Equal(t, "n1", c.Nonce)
+			assert.Equal(t, now.Add(time.Hour).Unix(), c.ExpiresAt)
+			assert.NotEmpty(t, c.AccessTokenHash)
+			assert.Equal(t, test.username, c.Username)
+			assert.Equal(t, test.email, c.Email)
+		})
+	}
+
+	token, err := p.IDToken(ctx, IDTokenRequest{ClientID: "spa", User: u, Scopes: []string{ScopeOpenID}})
+	require.NoError(t, err)
+	now = now.Add(time.Hour)
+	_, err = p.Verify(ctx, token)
+	assert.ErrorIs(t, err, ErrInvalidToken, "expired")
+}
+
+func TestProvider_Rotation(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	repo := repository.New()
+	p := NewProvider(repo, config.OIDC{Algorithm: config.SigningRS256, KeyRotation: 24 * time.Hour, IDTokenTTL: time.Hour})
+	p.now = func() time.Time { return now }
+
+	old, err := p.IDToken(ctx, IDTokenRequest{ClientID: "spa", User: model.User{ID: "u1"}})
+	require.NoError(t, err)
+	keys, err := p.Keys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys.Keys, 1)
+	assert.Equal(t, "RSA", keys.Keys[0].Kty)
+
+	now = now.Add(24 * time.Hour)
+	require.NoError(t, p.Prune(ctx))
+	keys, err = p.Keys(ctx)
+	require.NoError(t, err)
+	assert.Len(t, keys.Keys, 2, "the old key stays published after rotating")
+
+	_, err = p.Verify(ctx, old)
+	assert.ErrorIs(t, err, ErrInvalidToken, "expired, but the signature still checks out")
+
+	now = now.Add(time.Hour)
+	require.NoError(t, p.Prune(ctx))
+	keys, err = p.Keys(ctx)
+	require.NoError(t, err)
+	assert.Len(t, keys.Keys, 1)
+	assert.Len(t, repo.GetSigningKeys(ctx), 1, "retired keys are deleted")
+
+	p.cfg.Algorithm = config.SigningES256
+	keys, err = p.Keys(ctx)
+	require.NoError(t, err)
+	assert.Len(t, keys.Keys, 2, "changing the algorithm rotates")
+	assert.Equal(t, "EC", keys.Keys[0].Kty)
+	assert.Equal(t, []string{config.SigningES256, config.SigningRS256}, p.Algorithms(ctx))
+
+	k, err := p.Rotate(ctx)
+	require.NoError(t, err)
+	jwk, err := PublicJWK(k)
+	require.NoError(t, err)
+	assert.Equal(t, k.ID, jwk.Kid)
+	assert.Equal(t, "P-256", jwk.Crv)
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"errors"
+	"sort"
+	"time"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+func (db *DB) CreateAPIKey(_ context.Context, k model.APIKey) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.apiKeys[k.ID] = k
+
+	return nil
+}
+
+func (db *DB) GetAPIKey(_ context.Context, id string) (model.APIKey, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	k, ok := db.apiKeys[id]
+	if !ok {
+		return model.APIKey{}, ErrAPIKeyNotFound
+	}
+
+	return k, nil
+}
+
+// GetAPIKeysByUser returns the keys of userID, newest first.
+func (db *DB) GetAPIKeysByUser(_ context.Context, userID string) []model.APIKey {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var keys []model.APIKey
+	for _, k := range db.apiKeys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+
+	sortAPIKeys(keys)
+
+	return keys
+}
+
+// TouchAPIKey records that key id was used at.
+func (db *DB) TouchAPIKey(_ context.Context, id string, at time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	k, ok := db.apiKeys[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+
+	k.LastUsedAt = at
+	db.apiKeys[id] = k
+
+	return nil
+}
+
+func (db *DB) DeleteAPIKey(_ context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.apiKeys[id]; !ok {
+		return ErrAPIKeyNotFound
+	}
+
+	delete(db.apiKeys, id)
+
+	return nil
+}
+
+func (db *DB) allAPIKeys() []model.APIKey {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	keys := make([]model.APIKey, 0, len(db.apiKeys))
+	for _, k := range db.apiKeys {
+		keys = append(keys, k)
+	}
+
+	sortAPIKeys(keys)
+
+	return keys
+}
+
+func sortAPIKeys(keys []model.APIKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.After(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDB_APIKeys(t *testing.T) {
+	db := New(WithHashParams(HashParams{Time: 1, Memory: 64, Threads: 1, KeyLen: 16, SaltLen: 8}))
+	ctx := context.Background()
+
+	_, err := db.CreateUser(ctx, model.User{Username: "bot", Password: "p", ServiceAccount: true})
+	require.NoError(t, err)
+	bot, err := db.GetUserByName(ctx, "bot")
+	require.NoError(t, err)
+	assert.False(t, db.IsAuthorized(ctx, "bot", "p"), "service accounts have no password")
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, db.CreateAPIKey(ctx, model.APIKey{ID: "a", UserID: bot.ID, CreatedAt: created}))
+	require.NoError(t, db.CreateAPIKey(ctx, model.APIKey{ID: "b", UserID: bot.ID, CreatedAt: created.Add(time.Hour)}))
+
+	keys := db.GetAPIKeysByUser(ctx, bot.ID)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "b", keys[0].ID, "newest first")
+
+	require.NoError(t, db.TouchAPIKey(ctx, "a", created.Add(time.Minute)))
+	k, err := db.GetAPIKey(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, created.Add(time.Minute), k.LastUsedAt)
+	assert.Equal(t, ErrAPIKeyNotFound, db.TouchAPIKey(ctx, "nope", created))
+
+	require.NoError(t, db.DeleteAPIKey(ctx, "b"))
+	assert.Equal(t, ErrAPIKeyNotFound, db.DeleteAPIKey(ctx, "b"))
+
+	require.NoError(t, db.DeleteUser(ctx, bot.ID))
+	_, err = db.GetAPIKey(ctx, "a")
+	assert.Equal(t, ErrAPIKeyNotFound, err, "keys go with their user")
+}
+
+func TestFileDB_APIKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	ctx := context.Background()
+
+	f, err := NewFile(path)
+	require.NoError(t, err)
+	_, err = f.CreateUser(ctx, model.User{Username: "bob", Password: "p", Admin: true})
+	require.NoError(t, err)
+	bob, err := f.GetUserByName(ctx, "bob")
+	require.NoError(t, err)
+	require.NoError(t, f.CreateAPIKey(ctx, model.APIKey{ID: "a", UserID: bob.ID, Scopes: []string{"read"}}))
+	require.NoError(t, f.TouchAPIKey(ctx, "a", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	f, err = NewFile(path)
+	require.NoError(t, err)
+
+	k, err := f.GetAPIKey(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read"}, k.Scopes)
+	assert.Equal(t, 2024, k.LastUsedAt.Year())
+	assert.Empty(t, f.Verify(ctx))
+}
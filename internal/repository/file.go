@@ -0,0 +1,618 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileDB is the in-memory DB persisted as a JSON snapshot. Every successful
+// change rewrites the file atomically. The file is only read on open, so two
+// processes must not write to it at the same time.
+type FileDB struct {
+	*DB
+	path string
+	wmu  sync.Mutex
+}
+
+type snapshot struct {
+	Users         []model.User         `json:"users"`
+	Sessions      []model.Session      `json:"sessions,omitempty"`
+	TOTP          []model.TOTP         `json:"totp,omitempty"`
+	Tokens        []model.Token        `json:"tokens,omitempty"`
+	Tenants       []model.Tenant       `json:"tenants,omitempty"`
+	Groups        []model.Group        `json:"groups,omitempty"`
+	Members       []model.Membership   `json:"members,omitempty"`
+	APIKeys       []model.APIKey       `json:"api_keys,omitempty"`
+	OAuthClients  []model.OAuthClient  `json:"oauth_clients,omitempty"`
+	OAuthConsents []model.OAuthConsent `json:"oauth_consents,omitempty"`
+	OAuthTokens   []model.OAuthToken   `json:"oauth_tokens,omitempty"`
+	SigningKeys   []model.SigningKey   `json:"signing_keys,omitempty"`
+	Identities    []model.Identity     `json:"identities,omitempty"`
+	// Idempotency keeps the answers replayed for retried requests.
+	Idempotency []model.IdempotencyRecord `json:"idempotency,omitempty"`
+}
+
+// NewFile opens the snapshot at path, creating it on the first change if it
+// doesn't exist yet.
+func NewFile(path string, opts ...Option) (*FileDB, error) {
+	f := &FileDB{
+		DB:   New(opts...),
+		path: path,
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snap snapshot
+	if err = json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+
+	for _, t := range snap.Tenants {
+		if err = f.DB.CreateTenant(context.Background(), t); err != nil {
+			return nil, fmt.Errorf("load tenant %q: %w", t.ID, err)
+		}
+	}
+
+	for _, u := range snap.Users {
+		if err = f.DB.RestoreUser(context.Background(), u); err != nil {
+			return nil, fmt.Errorf("load user %q: %w", u.Username, err)
+		}
+	}
+
+	for _, g := range snap.Groups {
+		if err = f.DB.CreateGroup(context.Background(), g); err != nil {
+			return nil, fmt.Errorf("load group %q: %w", g.Name, err)
+		}
+	}
+
+	for _, m := range snap.Members {
+		if err = f.DB.AddMember(context.Background(), m.GroupID, m.UserID); err != nil {
+			return nil, fmt.Errorf("load member %q of group %q: %w", m.UserID, m.GroupID, err)
+		}
+	}
+
+	for _, k := range snap.APIKeys {
+		if err = f.DB.CreateAPIKey(context.Background(), k); err != nil {
+			return nil, fmt.Errorf("load api key %q: %w", k.Prefix, err)
+		}
+	}
+
+	for _, c := range snap.OAuthClients {
+		if err = f.DB.CreateOAuthClient(context.Background(), c); err != nil {
+			return nil, fmt.Errorf("load oauth client %q: %w", c.ID, err)
+		}
+	}
+
+	for _, c := range snap.OAuthConsents {
+		if err = f.DB.SaveConsent(context.Background(), c); err != nil {
+			return nil, fmt.Errorf("load consent of %q to %q: %w", c.UserID, c.ClientID, err)
+		}
+	}
+
+	for _, t := range snap.OAuthTokens {
+		if err = f.DB.CreateOAuthToken(context.Background(), t); err != nil {
+			return nil, fmt.Errorf("load oauth token: %w", err)
+		}
+	}
+
+	for _, i := range snap.Identities {
+		if err = f.DB.CreateIdentity(context.Background(), i); err != nil {
+			return nil, fmt.Errorf("load identity %q of %q: %w", i.Subject, i.Connector, err)
+		}
+	}
+
+	for _, k := range snap.SigningKeys {
+		if err = f.DB.CreateSigningKey(context.Background(), k); err != nil {
+			return nil, fmt.Errorf("load signing key %q: %w", k.ID, err)
+		}
+	}
+
+	for _, s := range snap.Sessions {
+		if err = f.DB.CreateSession(context.Background(), s); err != nil {
+			return nil, fmt.Errorf("load session: %w", err)
+		}
+	}
+
+	for _, t := range snap.TOTP {
+		if err = f.DB.SaveTOTP(context.Background(), t); err != nil {
+			return nil, fmt.Errorf("load two-factor settings of %q: %w", t.UserID, err)
+		}
+	}
+
+	for _, t := range snap.Tokens {
+		if err = f.DB.CreateToken(context.Background(), t); err != nil {
+			return nil, fmt.Errorf("load token: %w", err)
+		}
+	}
+
+	for _, r := range snap.Idempotency {
+		if err = f.DB.CreateIdempotencyRecord(context.Background(), r); err != nil {
+			return nil, fmt.Errorf("load idempotency record: %w", err)
+		}
+	}
+
+	return f, nil
+}
+
+func (f *FileDB) CreateUser(ctx context.Context, u model.User) (model.User, error) {
+	u, err := f.DB.CreateUser(ctx, u)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	return u, f.save(ctx)
+}
+
+func (f *FileDB) UpdateUser(ctx context.Context, u model.User) error {
+	if err := f.DB.UpdateUser(ctx, u); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteUser(ctx context.Context, id string) error {
+	if err := f.DB.DeleteUser(ctx, id); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) RestoreUser(ctx context.Context, u model.User) error {
+	if err := f.DB.RestoreUser(ctx, u); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) CreateSession(ctx context.Context, s model.Session) error {
+	if err := f.DB.CreateSession(ctx, s); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) UpdateSession(ctx context.Context, s model.Session) error {
+	if err := f.DB.UpdateSession(ctx, s); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteSession(ctx context.Context, id string) error {
+	if err := f.DB.DeleteSession(ctx, id); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteSessionsByUser(ctx context.Context, userID string) error {
+	if err := f.DB.DeleteSessionsByUser(ctx, userID); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteExpiredSessions(ctx context.Context, now time.Time) error {
+	if err := f.DB.DeleteExpiredSessions(ctx, now); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) SaveTOTP(ctx context.Context, t model.TOTP) error {
+	if err := f.DB.SaveTOTP(ctx, t); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteTOTP(ctx context.Context, userID string) error {
+	if err := f.DB.DeleteTOTP(ctx, userID); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) SetRecoveryCodes(ctx context.Context, userID string, codes []string) error {
+	if err := f.DB.SetRecoveryCodes(ctx, userID, codes); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) UseRecoveryCode(ctx context.Context, userID, code string) bool {
+	if !f.DB.UseRecoveryCode(ctx, userID, code) {
+		return false
+	}
+
+	// The code is spent even if the snapshot can't be written, a failed
+	// save only means it may be accepted again after a restart.
+	_ = f.save(ctx)
+
+	return true
+}
+
+func (f *FileDB) IsAuthorized(ctx context.Context, username, password string) bool {
+	ok, rehashed := f.DB.authorize(ctx, username, password)
+	if rehashed {
+		// The old hash still verifies if the new one can't be saved.
+		_ = f.save(ctx)
+	}
+
+	return ok
+}
+
+func (f *FileDB) UseTOTPStep(ctx context.Context, userID string, step int64) bool {
+	if !f.DB.UseTOTPStep(ctx, userID, step) {
+		return false
+	}
+
+	// As with recovery codes, a failed save only means the code may be
+	// accepted again after a restart.
+	_ = f.save(ctx)
+
+	return true
+}
+
+func (f *FileDB) CreateToken(ctx context.Context, t model.Token) error {
+	if err := f.DB.CreateToken(ctx, t); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) ConsumeToken(ctx context.Context, id, purpose string) (model.Token, error) {
+	t, err := f.DB.ConsumeToken(ctx, id, purpose)
+	if err != nil {
+		return model.Token{}, err
+	}
+
+	return t, f.save(ctx)
+}
+
+func (f *FileDB) DeleteTokensByUser(ctx context.Context, userID, purpose string) error {
+	if err := f.DB.DeleteTokensByUser(ctx, userID, purpose); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteExpiredTokens(ctx context.Context, now time.Time) error {
+	if err := f.DB.DeleteExpiredTokens(ctx, now); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) CreateTenant(ctx context.Context, t model.Tenant) error {
+	if err := f.DB.CreateTenant(ctx, t); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) UpdateTenant(ctx context.Context, t model.Tenant) error {
+	if err := f.DB.UpdateTenant(ctx, t); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteTenant(ctx context.Context, id string) error {
+	if err := f.DB.DeleteTenant(ctx, id); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) CreateGroup(ctx context.Context, g model.Group) error {
+	if err := f.DB.CreateGroup(ctx, g); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) UpdateGroup(ctx context.Context, g model.Group) error {
+	if err := f.DB.UpdateGroup(ctx, g); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteGroup(ctx context.Context, id string) error {
+	if err := f.DB.DeleteGroup(ctx, id); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) AddMember(ctx context.Context, groupID, userID string) error {
+	if err := f.DB.AddMember(ctx, groupID, userID); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) RemoveMember(ctx context.Context, groupID, userID string) error {
+	if err := f.DB.RemoveMember(ctx, groupID, userID); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) CreateAPIKey(ctx context.Context, k model.APIKey) error {
+	if err := f.DB.CreateAPIKey(ctx, k); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	if err := f.DB.TouchAPIKey(ctx, id, at); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteAPIKey(ctx context.Context, id string) error {
+	if err := f.DB.DeleteAPIKey(ctx, id); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) CreateOAuthClient(ctx context.Context, c model.OAuthClient) error {
+	if err := f.DB.CreateOAuthClient(ctx, c); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) UpdateOAuthClient(ctx context.Context, c model.OAuthClient) error {
+	if err := f.DB.UpdateOAuthClient(ctx, c); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteOAuthClient(ctx context.Context, id string) error {
+	if err := f.DB.DeleteOAuthClient(ctx, id); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) SaveConsent(ctx context.Context, c model.OAuthConsent) error {
+	if err := f.DB.SaveConsent(ctx, c); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteConsent(ctx context.Context, userID, clientID string) error {
+	if err := f.DB.DeleteConsent(ctx, userID, clientID); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) CreateOAuthToken(ctx context.Context, t model.OAuthToken) error {
+	if err := f.DB.CreateOAuthToken(ctx, t); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteOAuthToken(ctx context.Context, id string) error {
+	if err := f.DB.DeleteOAuthToken(ctx, id); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteOAuthGrant(ctx context.Context, grantID string) error {
+	if err := f.DB.DeleteOAuthGrant(ctx, grantID); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteUserOAuthGrants(ctx context.Context, userID string) error {
+	if err := f.DB.DeleteUserOAuthGrants(ctx, userID); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteExpiredOAuthTokens(ctx context.Context, now time.Time) error {
+	if err := f.DB.DeleteExpiredOAuthTokens(ctx, now); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) ConsumeOAuthToken(ctx context.Context, id, kind string) (model.OAuthToken, error) {
+	t, err := f.DB.ConsumeOAuthToken(ctx, id, kind)
+	if err != nil {
+		return model.OAuthToken{}, err
+	}
+
+	return t, f.save(ctx)
+}
+
+func (f *FileDB) CreateSigningKey(ctx context.Context, k model.SigningKey) error {
+	if err := f.DB.CreateSigningKey(ctx, k); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) RetireSigningKey(ctx context.Context, id string, at time.Time) error {
+	if err := f.DB.RetireSigningKey(ctx, id, at); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteSigningKey(ctx context.Context, id string) error {
+	if err := f.DB.DeleteSigningKey(ctx, id); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) CreateIdentity(ctx context.Context, i model.Identity) error {
+	if err := f.DB.CreateIdentity(ctx, i); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) UpdateIdentity(ctx context.Context, i model.Identity) error {
+	if err := f.DB.UpdateIdentity(ctx, i); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteIdentity(ctx context.Context, connector, subject string) error {
+	if err := f.DB.DeleteIdentity(ctx, connector, subject); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) CreateIdempotencyRecord(ctx context.Context, r model.IdempotencyRecord) error {
+	if err := f.DB.CreateIdempotencyRecord(ctx, r); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) UpdateIdempotencyRecord(ctx context.Context, r model.IdempotencyRecord) error {
+	if err := f.DB.UpdateIdempotencyRecord(ctx, r); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	if err := f.DB.DeleteIdempotencyRecord(ctx, key); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) DeleteExpiredIdempotencyRecords(ctx context.Context, now time.Time) error {
+	if err := f.DB.DeleteExpiredIdempotencyRecords(ctx, now); err != nil {
+		return err
+	}
+
+	return f.save(ctx)
+}
+
+func (f *FileDB) save(_ context.Context) error {
+	f.wmu.Lock()
+	defer f.wmu.Unlock()
+
+	groups, members := f.DB.allGroups()
+
+	data, err := json.MarshalIndent(snapshot{
+		Users:         f.DB.allUsers(),
+		Sessions:      f.DB.allSessions(),
+		TOTP:          f.DB.allTOTP(),
+		Tokens:        f.DB.allTokens(),
+		Tenants:       f.DB.allTenants(),
+		Groups:        groups,
+		Members:       members,
+		APIKeys:       f.DB.allAPIKeys(),
+		OAuthClients:  f.DB.allClients(),
+		OAuthConsents: f.DB.allConsents(),
+		OAuthTokens:   f.DB.allOAuthTokens(),
+		SigningKeys:   f.DB.allSigningKeys(),
+		Identities:    f.DB.allIdentities(),
+		Idempotency:   f.DB.allIdempotencyRecords(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/tenant"
+	"errors"
+	"sort"
+)
+
+var (
+	ErrGroupNotFound   = errors.New("group not found")
+	ErrGroupNameExists = errors.New("group name exists")
+	ErrMemberNotFound  = errors.New("user is not a member of the group")
+)
+
+func (db *DB) CreateGroup(_ context.Context, g model.Group) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.groupNameTaken(g) {
+		return ErrGroupNameExists
+	}
+
+	if _, ok := db.tenants[g.Tenant]; g.Tenant != tenant.Default && !ok {
+		return ErrTenantNotFound
+	}
+
+	db.groups[g.ID] = g
+	db.members[g.ID] = make(map[string]bool)
+
+	return nil
+}
+
+func (db *DB) GetGroup(_ context.Context, id string) (model.Group, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	g, ok := db.groups[id]
+	if !ok {
+		return model.Group{}, ErrGroupNotFound
+	}
+
+	return g, nil
+}
+
+// GetGroups returns the groups of the tenant of ctx ordered by name.
+func (db *DB) GetGroups(ctx context.Context) []model.Group {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	id := tenant.FromContext(ctx)
+
+	var groups []model.Group
+	for _, g := range db.groups {
+		if g.Tenant == id {
+			groups = append(groups, g)
+		}
+	}
+
+	sortGroups(groups)
+
+	return groups
+}
+
+// UpdateGroup replaces name, description and permissions of a group.
+func (db *DB) UpdateGroup(_ context.Context, g model.Group) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	old, ok := db.groups[g.ID]
+	if !ok {
+		return ErrGroupNotFound
+	}
+
+	g.Tenant = old.Tenant
+	g.CreatedAt = old.CreatedAt
+
+	if db.groupNameTaken(g) {
+		return ErrGroupNameExists
+	}
+
+	db.groups[g.ID] = g
+
+	return nil
+}
+
+func (db *DB) DeleteGroup(_ context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.groups[id]; !ok {
+		return ErrGroupNotFound
+	}
+
+	delete(db.groups, id)
+	delete(db.members, id)
+
+	return nil
+}
+
+// AddMember puts user userID into group groupID, both of the same tenant.
+// Adding a member again is not an error.
+func (db *DB) AddMember(_ context.Context, groupID, userID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	g, ok := db.groups[groupID]
+	if !ok {
+		return ErrGroupNotFound
+	}
+
+	u, ok := db.store[userID]
+	if !ok || u.Tenant != g.Tenant {
+		return ErrUserNotFound
+	}
+
+	db.members[groupID][userID] = true
+
+	return nil
+}
+
+func (db *DB) RemoveMember(_ context.Context, groupID, userID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	members, ok := db.members[groupID]
+	if !ok {
+		return ErrGroupNotFound
+	}
+
+	if !members[userID] {
+		return ErrMemberNotFound
+	}
+
+	delete(members, userID)
+
+	return nil
+}
+
+// GetMembers returns the members of group id ordered by username.
+func (db *DB) GetMembers(_ context.Context, id string) ([]model.User, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	members, ok := db.members[id]
+	if !ok {
+		return nil, ErrGroupNotFound
+	}
+
+	users := make([]model.User, 0, len(members))
+	for userID := range members {
+		users = append(users, db.store[userID])
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+
+	return users, nil
+}
+
+// GetGroupsByUser returns the groups of user userID ordered by name.
+func (db *DB) GetGroupsByUser(_ context.Context, userID string) []model.Group {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var groups []model.Group
+	for id, members := range db.members {
+		if members[userID] {
+			groups = append(groups, db.groups[id])
+		}
+	}
+
+	sortGroups(groups)
+
+	return groups
+}
+
+// groupNameTaken reports whether another group of the tenant of g is named
+// like g. The caller holds the lock.
+func (db *DB) groupNameTaken(g model.Group) bool {
+	for _, other := range db.groups {
+		if other.ID != g.ID && other.Tenant == g.Tenant && other.Name == g.Name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deleteMemberships removes user userID from all groups. The caller holds the
+// write lock.
+func (db *DB) deleteMemberships(userID string) {
+	for _, members := range db.members {
+		delete(members, userID)
+	}
+}
+
+func (db *DB) allGroups() ([]model.Group, []model.Membership) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	groups := make([]model.Group, 0, len(db.groups))
+	var memberships []model.Membership
+	for id, g := range db.groups {
+		groups = append(groups, g)
+
+		for userID := range db.members[id] {
+			memberships = append(memberships, model.Membership{GroupID: id, UserID: userID})
+		}
+	}
+
+	sortGroups(groups)
+	sort.Slice(memberships, func(i, j int) bool {
+		if memberships[i].GroupID != memberships[j].GroupID {
+			return memberships[i].GroupID < memberships[j].GroupID
+		}
+		return memberships[i].UserID < memberships[j].UserID
+	})
+
+	return groups, memberships
+}
+
+func sortGroups(groups []model.Group) {
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Name != groups[j].Name {
+			return groups[i].Name < groups[j].Name
+		}
+		return groups[i].ID < groups[j].ID
+	})
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestDB_Groups(t *testing.T) {
+	db := New(WithHashParams(HashParams{Time: 1, Memory: 64, Threads: 1, KeyLen: 16, SaltLen: 8}))
+
+	ctx := context.Background()
+	acme := tenant.WithID(ctx, "acme")
+
+	require.NoError(t, db.CreateTenant(ctx, model.Tenant{ID: "acme"}))
+	_, err := db.CreateUser(ctx, model.User{Username: "bob", Password: "p"})
+	require.NoError(t, err)
+	_, err = db.CreateUser(ctx, model.User{Username: "carol", Password: "p", Tenant: "acme"})
+	require.NoError(t, err)
+	bob, err := db.GetUserByName(ctx, "bob")
+	require.NoError(t, err)
+	carol, err := db.GetUserByName(acme, "carol")
+	require.NoError(t, err)
+
+	require.NoError(t, db.CreateGroup(ctx, model.Group{ID: "1", Name: "ops"}))
+	require.NoError(t, db.CreateGroup(ctx, model.Group{ID: "2", Name: "dev"}))
+	require.NoError(t, db.CreateGroup(ctx, model.Group{ID: "3", Name: "ops", Tenant: "acme"}), "names are unique per tenant")
+	assert.Equal(t, ErrGroupNameExists, db.CreateGroup(ctx, model.Group{ID: "4", Name: "ops"}))
+	assert.Equal(t, ErrGroupNameExists, db.UpdateGroup(ctx, model.Group{ID: "2", Name: "ops"}))
+
+	groups := db.GetGroups(ctx)
+	require.Len(t, groups, 2)
+	assert.Equal(t, "dev", groups[0].Name)
+	assert.Len(t, db.GetGroups(acme), 1)
+
+	require.NoError(t, db.AddMember(ctx, "1", bob.ID))
+	require.NoError(t, db.AddMember(ctx, "1", bob.ID), "adding again is not an error")
+	require.NoError(t, db.AddMember(ctx, "2", bob.ID))
+	assert.Equal(t, ErrUserNotFound, db.AddMember(ctx, "1", carol.ID), "members belong to the tenant of the group")
+	assert.Equal(t, ErrGroupNotFound, db.AddMember(ctx, "nope", bob.ID))
+
+	members, err := db.GetMembers(ctx, "1")
+	require.NoError(t, err)
+	require.Len(t, members, 1)
+	assert.Equal(t, "bob", members[0].Username)
+
+	assert.Len(t, db.GetGroupsByUser(ctx, bob.ID), 2)
+
+	require.NoError(t, db.RemoveMember(ctx, "2", bob.ID))
+	assert.Equal(t, ErrMemberNotFound, db.RemoveMember(ctx, "2", bob.ID))
+
+	require.NoError(t, db.DeleteUser(ctx, bob.ID))
+	members, err = db.GetMembers(ctx, "1")
+	require.NoError(t, err)
+	assert.Empty(t, members, "deleted users leave their groups")
+
+	require.NoError(t, db.DeleteGroup(ctx, "1"))
+	assert.Equal(t, ErrGroupNotFound, db.DeleteGroup(ctx, "1"))
+
+	require.NoError(t, db.DeleteTenant(ctx, "acme"))
+	_, err = db.GetGroup(ctx, "3")
+	assert.Equal(t, ErrGroupNotFound, err, "groups go with their tenant")
+}
+
+func TestFileDB_Groups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	ctx := context.Background()
+
+	f, err := NewFile(path)
+	require.NoError(t, err)
+	_, err = f.CreateUser(ctx, model.User{Username: "bob", Password: "p", Admin: true})
+	require.NoError(t, err)
+	bob, err := f.GetUserByName(ctx, "bob")
+	require.NoError(t, err)
+	require.NoError(t, f.CreateGroup(ctx, model.Group{ID: "1", Name: "ops", Permissions: []string{model.PermissionUserWrite}}))
+	require.NoError(t, f.AddMember(ctx, "1", bob.ID))
+
+	f, err = NewFile(path)
+	require.NoError(t, err)
+
+	groups := f.GetGroupsByUser(ctx, bob.ID)
+	require.Len(t, groups, 1)
+	assert.Equal(t, []string{model.PermissionUserWrite}, groups[0].Permissions)
+	assert.Empty(t, f.Verify(ctx))
+}
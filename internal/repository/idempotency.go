@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyExists      = errors.New("idempotency key exists")
+	ErrIdempotencyRecordNotFound = errors.New("idempotency record not found")
+)
+
+// CreateIdempotencyRecord stores r unless a record with its key is kept
+// beyond r.CreatedAt, which makes it the atomic reservation of the key.
+func (db *DB) CreateIdempotencyRecord(_ context.Context, r model.IdempotencyRecord) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if old, ok := db.idempotency[r.Key]; ok && old.ExpiresAt.After(r.CreatedAt) {
+		return ErrIdempotencyKeyExists
+	}
+
+	db.idempotency[r.Key] = r
+
+	return nil
+}
+
+func (db *DB) GetIdempotencyRecord(_ context.Context, key string) (model.IdempotencyRecord, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	r, ok := db.idempotency[key]
+	if !ok {
+		return model.IdempotencyRecord{}, ErrIdempotencyRecordNotFound
+	}
+
+	return r, nil
+}
+
+func (db *DB) UpdateIdempotencyRecord(_ context.Context, r model.IdempotencyRecord) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.idempotency[r.Key]; !ok {
+		return ErrIdempotencyRecordNotFound
+	}
+
+	db.idempotency[r.Key] = r
+
+	return nil
+}
+
+func (db *DB) DeleteIdempotencyRecord(_ context.Context, key string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.idempotency[key]; !ok {
+		return ErrIdempotencyRecordNotFound
+	}
+
+	delete(db.idempotency, key)
+
+	return nil
+}
+
+func (db *DB) DeleteExpiredIdempotencyRecords(_ context.Context, now time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for key, r := range db.idempotency {
+		if !now.Before(r.ExpiresAt) {
+			delete(db.idempotency, key)
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) allIdempotencyRecords() []model.IdempotencyRecord {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	records := make([]model.IdempotencyRecord, 0, len(db.idempotency))
+	for _, r := range db.idempotency {
+		records = append(records, r)
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+
+	return records
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"errors"
+	"sort"
+)
+
+var (
+	ErrIdentityExists   = errors.New("identity is already linked")
+	ErrIdentityNotFound = errors.New("identity not found")
+)
+
+// CreateIdentity links identity i to its user. Neither the identity nor
+// another identity of the user at the same connector may be linked yet.
+func (db *DB) CreateIdentity(_ context.Context, i model.Identity) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.store[i.UserID]; !ok {
+		return ErrUserNotFound
+	}
+
+	if _, ok := db.identities[identityKey(i.Connector, i.Subject)]; ok {
+		return ErrIdentityExists
+	}
+	for _, other := range db.identities {
+		if other.UserID == i.UserID && other.Connector == i.Connector {
+			return ErrIdentityExists
+		}
+	}
+
+	db.identities[identityKey(i.Connector, i.Subject)] = i
+
+	return nil
+}
+
+func (db *DB) GetIdentity(_ context.Context, connector, subject string) (model.Identity, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	i, ok := db.identities[identityKey(connector, subject)]
+	if !ok {
+		return model.Identity{}, ErrIdentityNotFound
+	}
+
+	return i, nil
+}
+
+// GetIdentitiesByUser returns the identities of userID ordered by connector.
+func (db *DB) GetIdentitiesByUser(_ context.Context, userID string) []model.Identity {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	identities := make([]model.Identity, 0)
+	for _, i := range db.identities {
+		if i.UserID == userID {
+			identities = append(identities, i)
+		}
+	}
+
+	sortIdentities(identities)
+
+	return identities
+}
+
+// UpdateIdentity replaces identity i, keeping the user it is linked to.
+func (db *DB) UpdateIdentity(_ context.Context, i model.Identity) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	key := identityKey(i.Connector, i.Subject)
+	old, ok := db.identities[key]
+	if !ok {
+		return ErrIdentityNotFound
+	}
+
+	i.UserID = old.UserID
+	db.identities[key] = i
+
+	return nil
+}
+
+func (db *DB) DeleteIdentity(_ context.Context, connector, subject string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	key := identityKey(connector, subject)
+	if _, ok := db.identities[key]; !ok {
+		return ErrIdentityNotFound
+	}
+
+	delete(db.identities, key)
+
+	return nil
+}
+
+// deleteUserIdentities unlinks the identities of userID, the caller holds
+// the lock.
+func (db *DB) deleteUserIdentities(userID string) {
+	for key, i := range db.identities {
+		if i.UserID == userID {
+			delete(db.identities, key)
+		}
+	}
+}
+
+func (db *DB) allIdentities() []model.Identity {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	identities := make([]model.Identity, 0, len(db.identities))
+	for _, i := range db.identities {
+		identities = append(identities, i)
+	}
+
+	sortIdentities(identities)
+
+	return identities
+}
+
+func identityKey(connector, subject string) string {
+	return connector + "\x00" + subject
+}
+
+func sortIdentities(identities []model.Identity) {
+	sort.Slice(identities, func(i, j int) bool {
+		if identities[i].Connector != identities[j].Connector {
+			return identities[i].Connector < identities[j].Connector
+		}
+		return identities[i].Subject < identities[j].Subject
+	})
+}
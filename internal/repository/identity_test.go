@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileDB_Identities(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	ctx := context.Background()
+
+	f, err := NewFile(path)
+	require.NoError(t, err)
+	_, err = f.CreateUser(ctx, model.User{Username: "bob", Password: "p", Admin: true})
+	require.NoError(t, err)
+	bob, err := f.GetUserByName(ctx, "bob")
+	require.NoError(t, err)
+
+	require.NoError(t, f.CreateIdentity(ctx, model.Identity{Connector: "corp", Subject: "s1", UserID: bob.ID}))
+	assert.Equal(t, ErrIdentityExists, f.CreateIdentity(ctx, model.Identity{Connector: "corp", Subject: "s1", UserID: bob.ID}))
+	assert.Equal(t, ErrIdentityExists, f.CreateIdentity(ctx, model.Identity{Connector: "corp", Subject: "s2", UserID: bob.ID}),
+		"one identity per connector")
+	assert.Equal(t, ErrUserNotFound, f.CreateIdentity(ctx, model.Identity{Connector: "corp", Subject: "s3", UserID: "nope"}))
+	require.NoError(t, f.CreateIdentity(ctx, model.Identity{Connector: "github", Subject: "s1", UserID: bob.ID}))
+
+	require.NoError(t, f.UpdateIdentity(ctx, model.Identity{Connector: "corp", Subject: "s1", UserID: "other", Email: "bob@corp.test"}))
+	assert.Equal(t, ErrIdentityNotFound, f.UpdateIdentity(ctx, model.Identity{Connector: "corp", Subject: "nope"}))
+
+	f, err = NewFile(path)
+	require.NoError(t, err)
+
+	i, err := f.GetIdentity(ctx, "corp", "s1")
+	require.NoError(t, err)
+	assert.Equal(t, bob.ID, i.UserID, "identities stay with their user")
+	assert.Equal(t, "bob@corp.test", i.Email)
+
+	identities := f.GetIdentitiesByUser(ctx, bob.ID)
+	require.Len(t, identities, 2)
+	assert.Equal(t, "corp", identities[0].Connector)
+	assert.Empty(t, f.Verify(ctx))
+
+	require.NoError(t, f.DeleteIdentity(ctx, "github", "s1"))
+	assert.Equal(t, ErrIdentityNotFound, f.DeleteIdentity(ctx, "github", "s1"))
+
+	require.NoError(t, f.DeleteUser(ctx, bob.ID))
+	_, err = f.GetIdentity(ctx, "corp", "s1")
+	assert.Equal(t, ErrIdentityNotFound, err, "identities go with their user")
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/tenant"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RestoreUser stores u exactly as given, keeping its ID and password hash.
+// It is meant for restoring exported records, not for new users.
+func (db *DB) RestoreUser(_ context.Context, u model.User) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if u.ID == "" {
+		return fmt.Errorf("restore %q: %w", u.Username, ErrUserNotFound)
+	}
+
+	if id, ok := db.userId[nameKey(u.Tenant, u.Username)]; ok && id != u.ID {
+		return ErrUserNameExists
+	}
+
+	if old, ok := db.store[u.ID]; ok {
+		delete(db.userId, nameKey(old.Tenant, old.Username))
+	}
+
+	db.userId[nameKey(u.Tenant, u.Username)] = u.ID
+	db.store[u.ID] = u
+
+	return nil
+}
+
+// Verify checks the store for inconsistencies and returns one line per
+// problem found.
+func (db *DB) Verify(_ context.Context) []string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var problems []string
+
+	admins := 0
+	for id, u := range db.store {
+		if u.ID != id {
+			problems = append(problems, fmt.Sprintf("user %q stored under id %q", u.ID, id))
+		}
+
+		if u.Username == "" {
+			problems = append(problems, fmt.Sprintf("user %q has no username", id))
+		} else if db.userId[nameKey(u.Tenant, u.Username)] != id {
+			problems = append(problems, fmt.Sprintf("username %q is not indexed to user %q", u.Username, id))
+		}
+
+		if _, ok := db.tenants[u.Tenant]; u.Tenant != tenant.Default && !ok {
+			problems = append(problems, fmt.Sprintf("user %q belongs to missing tenant %q", id, u.Tenant))
+		}
+
+		if strings.HasPrefix(u.Password, hashPrefix) {
+			if _, _, _, err := decodeHash(u.Password); err != nil {
+				problems = append(problems, fmt.Sprintf("user %q has no valid password hash: %s", id, err))
+			}
+		} else {
+			// Hashes stored before their parameters were kept with them.
+			if len(u.Salt) == 0 {
+				problems = append(problems, fmt.Sprintf("user %q has no salt", id))
+			}
+
+			if hash, err := hex.DecodeString(u.Password); err != nil || len(hash) == 0 {
+				problems = append(problems, fmt.Sprintf("user %q has no valid password hash", id))
+			}
+		}
+
+		if u.Admin && u.Tenant == tenant.Default {
+			admins++
+		}
+	}
+
+	for name, id := range db.userId {
+		if _, ok := db.store[id]; !ok {
+			problems = append(problems, fmt.Sprintf("username %q points to missing user %q", name, id))
+		}
+	}
+
+	for id, members := range db.members {
+		g := db.groups[id]
+		for userID := range members {
+			if u, ok := db.store[userID]; !ok || u.Tenant != g.Tenant {
+				problems = append(problems, fmt.Sprintf("group %q has member %q outside its tenant", g.Name, userID))
+			}
+		}
+	}
+
+	for id, k := range db.apiKeys {
+		if _, ok := db.store[k.UserID]; !ok {
+			problems = append(problems, fmt.Sprintf("api key %q belongs to missing user %q", k.Prefix, k.UserID))
+		}
+		if k.ID != id {
+			problems = append(problems, fmt.Sprintf("api key %q stored under id %q", k.Prefix, id))
+		}
+	}
+
+	for _, i := range db.identities {
+		if _, ok := db.store[i.UserID]; !ok {
+			problems = append(problems, fmt.Sprintf("identity %q of %q belongs to missing user %q", i.Subject, i.Connector, i.UserID))
+		}
+	}
+
+	for id, c := range db.clients {
+		if _, ok := db.tenants[c.Tenant]; c.Tenant != tenant.Default && !ok {
+			problems = append(problems, fmt.Sprintf("oauth client %q belongs to missing tenant %q", id, c.Tenant))
+		}
+	}
+
+	for _, t := range db.oauth {
+		if _, ok := db.clients[t.ClientID]; !ok {
+			problems = append(problems, fmt.Sprintf("oauth token of grant %q belongs to missing client %q", t.GrantID, t.ClientID))
+		}
+	}
+
+	if len(db.store) != 0 && admins == 0 {
+		problems = append(problems, "no admin user")
+	}
+
+	sort.Strings(problems)
+
+	return problems
+}
@@ -1,15 +1,131 @@
 package repository
 
-import "dev/profileSaver/internal/model"
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"time"
+)
 
 //go:generate mockgen -source=interfaces.go -destination=mocks/mock.go
 
 type Repository interface {
-	CreateUser(u model.User) error
-	GetAllUsers() []model.User
-	GetUserByName(name string) (model.User, error)
-	GetUserByID(id string) (model.User, error)
-	UpdateUser(u model.User) error
-	DeleteUser(id string) error
-	IsAuthorized(username string, password string) bool
+	// CreateUser stores u with a new ID and returns it as stored.
+	CreateUser(ctx context.Context, u model.User) (model.User, error)
+	GetAllUsers(ctx context.Context) []model.User
+	GetUserByName(ctx context.Context, name string) (model.User, error)
+	GetUserByID(ctx context.Context, id string) (model.User, error)
+	UpdateUser(ctx context.Context, u model.User) error
+	DeleteUser(ctx context.Context, id string) error
+	IsAuthorized(ctx context.Context, username string, password string) bool
+}
+
+type SessionRepository interface {
+	CreateSession(ctx context.Context, s model.Session) error
+	GetSession(ctx context.Context, id string) (model.Session, error)
+	GetSessionsByUser(ctx context.Context, userID string) []model.Session
+	UpdateSession(ctx context.Context, s model.Session) error
+	DeleteSession(ctx context.Context, id string) error
+	DeleteSessionsByUser(ctx context.Context, userID string) error
+	DeleteExpiredSessions(ctx context.Context, now time.Time) error
+}
+
+type TOTPRepository interface {
+	GetTOTP(ctx context.Context, userID string) (model.TOTP, error)
+	SaveTOTP(ctx context.Context, t model.TOTP) error
+	DeleteTOTP(ctx context.Context, userID string) error
+	SetRecoveryCodes(ctx context.Context, userID string, codes []string) error
+	UseRecoveryCode(ctx context.Context, userID, code string) bool
+	UseTOTPStep(ctx context.Context, userID string, step int64) bool
+}
+
+type TokenRepository interface {
+	CreateToken(ctx context.Context, t model.Token) error
+	ConsumeToken(ctx context.Context, id, purpose string) (model.Token, error)
+	DeleteTokensByUser(ctx context.Context, userID, purpose string) error
+	DeleteExpiredTokens(ctx context.Context, now time.Time) error
+}
+
+type TenantRepository interface {
+	CreateTenant(ctx context.Context, t model.Tenant) error
+	GetTenant(ctx context.Context, id string) (model.Tenant, error)
+	GetAllTenants(ctx context.Context) []model.Tenant
+	UpdateTenant(ctx context.Context, t model.Tenant) error
+	DeleteTenant(ctx context.Context, id string) error
+}
+
+type GroupRepository interface {
+	CreateGroup(ctx context.Context, g model.Group) error
+	GetGroup(ctx context.Context, id string) (model.Group, error)
+	GetGroups(ctx context.Context) []model.Group
+	UpdateGroup(ctx context.Context, g model.Group) error
+	DeleteGroup(ctx context.Context, id string) error
+	AddMember(ctx context.Context, groupID, userID string) error
+	RemoveMember(ctx context.Context, groupID, userID string) error
+	GetMembers(ctx context.Context, id string) ([]model.User, error)
+	GetGroupsByUser(ctx context.Context, userID string) []model.Group
+}
+
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, k model.APIKey) error
+	GetAPIKey(ctx context.Context, id string) (model.APIKey, error)
+	GetAPIKeysByUser(ctx context.Context, userID string) []model.APIKey
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+	DeleteAPIKey(ctx context.Context, id string) error
+}
+
+type OAuthRepository interface {
+	CreateOAuthClient(ctx context.Context, c model.OAuthClient) error
+	GetOAuthClient(ctx context.Context, id string) (model.OAuthClient, error)
+	GetOAuthClients(ctx context.Context) []model.OAuthClient
+	UpdateOAuthClient(ctx context.Context, c model.OAuthClient) error
+	DeleteOAuthClient(ctx context.Context, id string) error
+	SaveConsent(ctx context.Context, c model.OAuthConsent) error
+	GetConsent(ctx context.Context, userID, clientID string) (model.OAuthConsent, error)
+	GetConsentsByUser(ctx context.Context, userID string) []model.OAuthConsent
+	DeleteConsent(ctx context.Context, userID, clientID string) error
+	CreateOAuthToken(ctx context.Context, t model.OAuthToken) error
+	GetOAuthToken(ctx context.Context, id string) (model.OAuthToken, error)
+	ConsumeOAuthToken(ctx context.Context, id, kind string) (model.OAuthToken, error)
+	DeleteOAuthToken(ctx context.Context, id string) error
+	DeleteOAuthGrant(ctx context.Context, grantID string) error
+	DeleteUserOAuthGrants(ctx context.Context, userID string) error
+	DeleteExpiredOAuthTokens(ctx context.Context, now time.Time) error
+}
+
+type SigningKeyRepository interface {
+	CreateSigningKey(ctx context.Context, k model.SigningKey) error
+	GetSigningKeys(ctx context.Context) []model.SigningKey
+	RetireSigningKey(ctx context.Context, id string, at time.Time) error
+	DeleteSigningKey(ctx context.Context, id string) error
+}
+
+type IdentityRepository interface {
+	CreateIdentity(ctx context.Context, i model.Identity) error
+	GetIdentity(ctx context.Context, connector, subject string) (model.Identity, error)
+	GetIdentitiesByUser(ctx context.Context, userID string) []model.Identity
+	UpdateIdentity(ctx context.Context, i model.Identity) error
+	DeleteIdentity(ctx context.Context, connector, subject string) error
+}
+
+type IdempotencyRepository interface {
+	CreateIdempotencyRecord(ctx context.Context, r model.IdempotencyRecord) error
+	GetIdempotencyRecord(ctx context.Context, key string) (model.IdempotencyRecord, error)
+	UpdateIdempotencyRecord(ctx context.Context, r model.IdempotencyRecord) error
+	DeleteIdempotencyRecord(ctx context.Context, key string) error
+	DeleteExpiredIdempotencyRecords(ctx context.Context, now time.Time) error
+}
+
+// Storage is everything a storage backend provides.
+type Storage interface {
+	Repository
+	SessionRepository
+	TOTPRepository
+	TokenRepository
+	TenantRepository
+	GroupRepository
+	APIKeyRepository
+	OAuthRepository
+	SigningKeyRepository
+	IdentityRepository
+	IdempotencyRepository
 }
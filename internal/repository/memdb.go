@@ -1,74 +1,125 @@
 package repository
 
 import (
-	"crypto/rand"
+	"context"
 	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/tenant"
 	"errors"
-	"fmt"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/argon2"
+	"go.opentelemetry.io/otel"
 	"sync"
+	"time"
 )
 
+var tracer = otel.Tracer("dev/profileSaver/internal/repository")
+
 var (
 	ErrUserNameExists = errors.New("username exists")
 	ErrUserNotFound   = errors.New("user not found")
 )
 
 type DB struct {
-	mu     sync.RWMutex
-	userId map[string]string
-	store  map[string]model.User
+	mu       sync.RWMutex
+	userId   map[string]string
+	store    map[string]model.User
+	sessions map[string]model.Session
+	totp     map[string]model.TOTP
+	tokens   map[string]model.Token
+	tenants  map[string]model.Tenant
+	groups   map[string]model.Group
+	members  map[string]map[string]bool
+	apiKeys  map[string]model.APIKey
+	clients  map[string]model.OAuthClient
+	consents map[string]model.OAuthConsent
+	// oauth holds the codes and tokens of the OAuth2 server.
+	oauth map[string]model.OAuthToken
+	// signingKeys sign the ID tokens of the OpenID Connect provider.
+	signingKeys map[string]model.SigningKey
+	// identities link users to upstream identity providers.
+	identities map[string]model.Identity
+	// idempotency keeps the answers to requests with an Idempotency-Key.
+	idempotency map[string]model.IdempotencyRecord
+	hash        HashParams
 }
 
-func New() *DB {
+func New(opts ...Option) *DB {
 	userId := make(map[string]string)
 	store := make(map[string]model.User)
-	return &DB{
-		mu:     sync.RWMutex{},
-		userId: userId,
-		store:  store,
+	db := &DB{
+		mu:          sync.RWMutex{},
+		userId:      userId,
+		store:       store,
+		sessions:    make(map[string]model.Session),
+		totp:        make(map[string]model.TOTP),
+		tokens:      make(map[string]model.Token),
+		tenants:     make(map[string]model.Tenant),
+		groups:      make(map[string]model.Group),
+		members:     make(map[string]map[string]bool),
+		apiKeys:     make(map[string]model.APIKey),
+		clients:     make(map[string]model.OAuthClient),
+		consents:    make(map[string]model.OAuthConsent),
+		oauth:       make(map[string]model.OAuthToken),
+		signingKeys: make(map[string]model.SigningKey),
+		identities:  make(map[string]model.Identity),
+		idempotency: make(map[string]model.IdempotencyRecord),
+		hash:        DefaultHashParams,
+	}
+
+	for _, opt := range opts {
+		opt(db)
 	}
+
+	return db
 }
 
-func (db *DB) CreateUser(u model.User) error {
+func (db *DB) CreateUser(ctx context.Context, u model.User) (model.User, error) {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	if _, ok := db.userId[u.Username]; ok {
-		return ErrUserNameExists
+	if _, ok := db.tenants[u.Tenant]; u.Tenant != tenant.Default && !ok {
+		return model.User{}, ErrTenantNotFound
 	}
 
-	u.ID = uuid.New().String()
+	if _, ok := db.userId[nameKey(u.Tenant, u.Username)]; ok {
+		return model.User{}, ErrUserNameExists
+	}
 
-	hashedPass, salt := db.hashPass([]byte(u.Password), nil)
+	u.ID = uuid.New().String()
+	u.CreatedAt = time.Now().UTC()
+	u.UpdatedAt = u.CreatedAt
 
-	u.Password = fmt.Sprintf("%x", hashedPass)
-	u.Salt = salt
+	u.Password = db.encodeHash(ctx, u.Password)
+	u.Salt = nil
 
-	db.userId[u.Username] = u.ID
+	db.userId[nameKey(u.Tenant, u.Username)] = u.ID
 	db.store[u.ID] = u
 
-	return nil
+	return u, nil
 }
 
-func (db *DB) GetAllUsers() []model.User {
+// GetAllUsers returns the users of the tenant of ctx.
+func (db *DB) GetAllUsers(ctx context.Context) []model.User {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
+	id := tenant.FromContext(ctx)
+
 	users := make([]model.User, 0, len(db.store))
 	for _, u := range db.store {
-		users = append(users, u)
+		if u.Tenant == id {
+			users = append(users, u)
+		}
 	}
 
 	return users
 }
 
-func (db *DB) GetUserByName(name string) (model.User, error) {
+// GetUserByName looks name up in the tenant of ctx.
+func (db *DB) GetUserByName(ctx context.Context, name string) (model.User, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	id, ok := db.userId[name]
+	id, ok := db.userId[nameKey(tenant.FromContext(ctx), name)]
 	if !ok {
 		return model.User{}, ErrUserNotFound
 	}
@@ -76,7 +127,7 @@ func (db *DB) GetUserByName(name string) (model.User, error) {
 	return db.store[id], nil
 }
 
-func (db *DB) GetUserByID(id string) (model.User, error) {
+func (db *DB) GetUserByID(_ context.Context, id string) (model.User, error) {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
@@ -88,7 +139,7 @@ func (db *DB) GetUserByID(id string) (model.User, error) {
 	return u, nil
 }
 
-func (db *DB) UpdateUser(u model.User) error {
+func (db *DB) UpdateUser(ctx context.Context, u model.User) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
@@ -97,18 +148,27 @@ func (db *DB) UpdateUser(u model.User) error {
 		return ErrUserNotFound
 	}
 
-	hashedPass, salt := db.hashPass([]byte(u.Password), nil)
-
-	u.Password = fmt.Sprintf("%x", hashedPass)
-	u.Salt = salt
+	// Users never move to another tenant.
+	u.Tenant = old.Tenant
+	u.CreatedAt = old.CreatedAt
+	u.UpdatedAt = time.Now().UTC()
+
+	// An empty password keeps the stored hash.
+	if u.Password == "" {
+		u.Password = old.Password
+		u.Salt = old.Salt
+	} else {
+		u.Password = db.encodeHash(ctx, u.Password)
+		u.Salt = nil
+	}
 
 	if old.Username != u.Username {
-		if _, ok := db.userId[u.Username]; ok {
+		if _, ok := db.userId[nameKey(u.Tenant, u.Username)]; ok {
 			return ErrUserNameExists
 		}
 
-		delete(db.userId, old.Username)
-		db.userId[u.Username] = u.ID
+		delete(db.userId, nameKey(old.Tenant, old.Username))
+		db.userId[nameKey(u.Tenant, u.Username)] = u.ID
 	}
 
 	db.store[u.ID] = u
@@ -116,7 +176,7 @@ func (db *DB) UpdateUser(u model.User) error {
 	return nil
 }
 
-func (db *DB) DeleteUser(id string) error {
+func (db *DB) DeleteUser(_ context.Context, id string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
@@ -125,65 +185,101 @@ func (db *DB) DeleteUser(id string) error {
 		return ErrUserNotFound
 	}
 
-	delete(db.userId, u.Username)
-	delete(db.store, u.ID)
+	db.deleteUser(u)
 
 	return nil
 }
 
-func (db *DB) updateUserFields(oldUser, newUser model.User) model.User {
-	if newUser.Username == "" {
-		newUser.Username = oldUser.Username
+// deleteUser removes u with everything kept for them. The caller holds the
+// write lock.
+func (db *DB) deleteUser(u model.User) {
+	delete(db.userId, nameKey(u.Tenant, u.Username))
+	delete(db.store, u.ID)
+	delete(db.totp, u.ID)
+	db.deleteMemberships(u.ID)
+	db.deleteUserGrants(u.ID)
+	db.deleteUserIdentities(u.ID)
+
+	for kid, k := range db.apiKeys {
+		if k.UserID == u.ID {
+			delete(db.apiKeys, kid)
+		}
 	}
 
-	if newUser.Email == "" {
-		newUser.Email = oldUser.Email
+	for sid, s := range db.sessions {
+		if s.UserID == u.ID {
+			delete(db.sessions, sid)
+		}
 	}
 
-	if newUser.Password == "" {
-		newUser.Password = oldUser.Password
+	for tid, t := range db.tokens {
+		if t.UserID == u.ID {
+			delete(db.tokens, tid)
+		}
 	}
+}
 
-	hashedPass, _ := db.hashPass([]byte(newUser.Password), oldUser.Salt)
-
-	newUser.Password = string(hashedPass)
-	newUser.Salt = oldUser.Salt
+// allUsers returns the users of every tenant.
+func (db *DB) allUsers() []model.User {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
 
-	newUser.ID = oldUser.ID
-	newUser.Admin = oldUser.Admin
+	users := make([]model.User, 0, len(db.store))
+	for _, u := range db.store {
+		users = append(users, u)
+	}
 
-	return newUser
+	return users
 }
 
-func (db *DB) hashPass(password, salt []byte) ([]byte, []byte) {
-	if salt == nil {
-		salt = make([]byte, 8)
-		rand.Read(salt)
+// nameKey indexes a username within its tenant.
+func nameKey(tenantID, username string) string {
+	if tenantID == tenant.Default {
+		return username
 	}
-	hashedPass := argon2.IDKey(password, salt, 1, 64*1024, 4, 32)
 
-	return hashedPass, salt
+	return tenantID + "\x00" + username
 }
 
-func (db *DB) IsAuthorized(username, password string) bool {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+func (db *DB) IsAuthorized(ctx context.Context, username, password string) bool {
+	ok, _ := db.authorize(ctx, username, password)
 
-	uID, ok := db.userId[username]
+	return ok
+}
 
-	if !ok {
-		return false
+// authorize checks password like IsAuthorized. A hash made with other
+// parameters than the current ones is replaced on success, rehashed reports
+// that it was.
+func (db *DB) authorize(ctx context.Context, username, password string) (ok, rehashed bool) {
+	db.mu.RLock()
+	uID, found := db.userId[nameKey(tenant.FromContext(ctx), username)]
+	user := db.store[uID]
+	db.mu.RUnlock()
+
+	// Service accounts have no password anyone knows.
+	if !found || user.ServiceAccount || user.Disabled {
+		return false, false
 	}
 
-	user := db.store[uID]
+	ok, stale := db.verifyHash(ctx, password, user.Password, user.Salt)
+	if !ok || !stale {
+		return ok, false
+	}
 
-	hashPass, _ := db.hashPass([]byte(password), user.Salt)
+	encoded := db.encodeHash(ctx, password)
 
-	pas := fmt.Sprintf("%x", hashPass)
+	db.mu.Lock()
+	defer db.mu.Unlock()
 
-	if pas != user.Password {
-		return false
+	// The password may have changed while it was hashed.
+	current, found := db.store[uID]
+	if !found || current.Password != user.Password {
+		return true, false
 	}
 
-	return true
+	current.Password = encoded
+	current.Salt = nil
+	db.store[uID] = current
+
+	return true, true
 }
@@ -1,8 +1,11 @@
 package repository
 
 import (
+	"context"
 	"dev/profileSaver/internal/model"
 	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
 	"testing"
 )
 
@@ -44,9 +47,20 @@ func TestDB_CreateUser(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			actualErr := db.CreateUser(test.input)
+			user, actualErr := db.CreateUser(context.Background(), test.input)
 
 			assert.Equal(t, test.expectedErr, actualErr)
+			if actualErr == nil {
+				assert.NotEmpty(t, user.ID)
+				assert.Equal(t, test.input.Username, user.Username)
+				assert.NotEqual(t, test.input.Password, user.Password)
+				assert.False(t, user.CreatedAt.IsZero())
+				assert.Equal(t, user.CreatedAt, user.UpdatedAt)
+
+				stored, err := db.GetUserByID(context.Background(), user.ID)
+				assert.NoError(t, err)
+				assert.Equal(t, stored, user)
+			}
 		})
 	}
 }
@@ -74,7 +88,7 @@ func TestDB_GetAllUsers(t *testing.T) {
 		},
 	}
 
-	actual := db.GetAllUsers()
+	actual := db.GetAllUsers(context.Background())
 
 	assert.Equal(t, expected, actual)
 }
@@ -122,7 +136,7 @@ func TestDB_GetUserByID(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			actualRes, actualErr := db.GetUserByID(test.input)
+			actualRes, actualErr := db.GetUserByID(context.Background(), test.input)
 
 			assert.Equal(t, test.expectedRes, actualRes)
 			assert.Equal(t, test.expectedErr, actualErr)
@@ -173,7 +187,7 @@ func TestDB_GetUserByName(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			actualRes, actualErr := db.GetUserByName(test.input)
+			actualRes, actualErr := db.GetUserByName(context.Background(), test.input)
 
 			assert.Equal(t, test.expectedRes, actualRes)
 			assert.Equal(t, test.expectedErr, actualErr)
@@ -243,7 +257,7 @@ func TestDB_UpdateUser(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			actualErr := db.UpdateUser(test.input)
+			actualErr := db.UpdateUser(context.Background(), test.input)
 
 			assert.Equal(t, test.expectedErr, actualErr)
 		})
@@ -282,9 +296,91 @@ func TestDB_DeleteUser(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			actualErr := db.DeleteUser(test.input)
+			actualErr := db.DeleteUser(context.Background(), test.input)
 
 			assert.Equal(t, test.expectedErr, actualErr)
 		})
 	}
 }
+
+func TestDB_UseRecoveryCode(t *testing.T) {
+	ctx := context.Background()
+	db := New(WithHashParams(HashParams{Time: 1, Memory: 64, Threads: 1, KeyLen: 16, SaltLen: 8}))
+
+	assert.ErrorIs(t, db.SetRecoveryCodes(ctx, "1", []string{"aaaaa-bbbbb"}), ErrTOTPNotFound)
+
+	assert.NoError(t, db.SaveTOTP(ctx, model.TOTP{UserID: "1", Secret: "ABC", Enabled: true}))
+	assert.NoError(t, db.SetRecoveryCodes(ctx, "1", []string{"aaaaa-bbbbb", "ccccc-ddddd"}))
+
+	stored, err := db.GetTOTP(ctx, "1")
+	assert.NoError(t, err)
+	assert.Len(t, stored.RecoveryCodes, 2)
+	assert.NotEqual(t, "aaaaa-bbbbb", stored.RecoveryCodes[0].Hash)
+
+	tests := []struct {
+		name     string
+		code     string
+		expected bool
+	}{
+		{name: "OK", code: " AAAAA-BBBBB ", expected: true},
+		{name: "SPENT", code: "aaaaa-bbbbb", expected: false},
+		{name: "NOT_OK", code: "eeeee-fffff", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, db.UseRecoveryCode(ctx, "1", test.code))
+		})
+	}
+
+	stored, err = db.GetTOTP(ctx, "1")
+	assert.NoError(t, err)
+	assert.Len(t, stored.RecoveryCodes, 1)
+
+	t.Run("CONCURRENT", func(t *testing.T) {
+		var (
+			wg       sync.WaitGroup
+			accepted int32
+		)
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if db.UseRecoveryCode(ctx, "1", "ccccc-ddddd") {
+					atomic.AddInt32(&accepted, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(1), accepted, "a code is spent once")
+	})
+}
+
+func TestDB_UseTOTPStep(t *testing.T) {
+	ctx := context.Background()
+	db := New()
+
+	assert.False(t, db.UseTOTPStep(ctx, "1", 10))
+	assert.NoError(t, db.SaveTOTP(ctx, model.TOTP{UserID: "1", Secret: "ABC", Enabled: true, LastStep: 10}))
+
+	tests := []struct {
+		name     string
+		step     int64
+		expected bool
+	}{
+		{name: "OK", step: 11, expected: true},
+		{name: "USED", step: 11, expected: false},
+		{name: "OLDER", step: 10, expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, db.UseTOTPStep(ctx, "1", test.step))
+		})
+	}
+
+	stored, err := db.GetTOTP(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), stored.LastStep)
+}
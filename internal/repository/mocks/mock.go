@@ -5,131 +5,2361 @@
 package mock_repository
 
 import (
+	context "context"
 	model "dev/profileSaver/internal/model"
-	reflect "reflect"
-
 	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+	time "time"
 )
 
-// MockRepository is a mock of Repository interface.
+// MockRepository is a mock of Repository interface
 type MockRepository struct {
 	ctrl     *gomock.Controller
 	recorder *MockRepositoryMockRecorder
 }
 
-// MockRepositoryMockRecorder is the mock recorder for MockRepository.
+// MockRepositoryMockRecorder is the mock recorder for MockRepository
 type MockRepositoryMockRecorder struct {
 	mock *MockRepository
 }
 
-// NewMockRepository creates a new mock instance.
+// NewMockRepository creates a new mock instance
 func NewMockRepository(ctrl *gomock.Controller) *MockRepository {
 	mock := &MockRepository{ctrl: ctrl}
 	mock.recorder = &MockRepositoryMockRecorder{mock}
 	return mock
 }
 
-// EXPECT returns an object that allows the caller to indicate expected use.
+// EXPECT returns an object that allows the caller to indicate expected use
 func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
-// CreateUser mocks base method.
-func (m *MockRepository) CreateUser(u model.User) error {
+// CreateUser mocks base method
+func (m *MockRepository) CreateUser(ctx context.Context, u model.User) (model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", ctx, u)
+	ret0, _ := ret[0].(model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUser indicates an expected call of CreateUser
+func (mr *MockRepositoryMockRecorder) CreateUser(ctx, u interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockRepository)(nil).CreateUser), ctx, u)
+}
+
+// GetAllUsers mocks base method
+func (m *MockRepository) GetAllUsers(ctx context.Context) []model.User {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllUsers", ctx)
+	ret0, _ := ret[0].([]model.User)
+	return ret0
+}
+
+// GetAllUsers indicates an expected call of GetAllUsers
+func (mr *MockRepositoryMockRecorder) GetAllUsers(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllUsers", reflect.TypeOf((*MockRepository)(nil).GetAllUsers), ctx)
+}
+
+// GetUserByName mocks base method
+func (m *MockRepository) GetUserByName(ctx context.Context, name string) (model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByName", ctx, name)
+	ret0, _ := ret[0].(model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByName indicates an expected call of GetUserByName
+func (mr *MockRepositoryMockRecorder) GetUserByName(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByName", reflect.TypeOf((*MockRepository)(nil).GetUserByName), ctx, name)
+}
+
+// GetUserByID mocks base method
+func (m *MockRepository) GetUserByID(ctx context.Context, id string) (model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByID", ctx, id)
+	ret0, _ := ret[0].(model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByID indicates an expected call of GetUserByID
+func (mr *MockRepositoryMockRecorder) GetUserByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockRepository)(nil).GetUserByID), ctx, id)
+}
+
+// UpdateUser mocks base method
+func (m *MockRepository) UpdateUser(ctx context.Context, u model.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", u)
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, u)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// CreateUser indicates an expected call of CreateUser.
-func (mr *MockRepositoryMockRecorder) CreateUser(u interface{}) *gomock.Call {
+// UpdateUser indicates an expected call of UpdateUser
+func (mr *MockRepositoryMockRecorder) UpdateUser(ctx, u interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockRepository)(nil).CreateUser), u)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockRepository)(nil).UpdateUser), ctx, u)
 }
 
-// DeleteUser mocks base method.
-func (m *MockRepository) DeleteUser(id string) error {
+// DeleteUser mocks base method
+func (m *MockRepository) DeleteUser(ctx context.Context, id string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteUser", id)
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, id)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// DeleteUser indicates an expected call of DeleteUser.
-func (mr *MockRepositoryMockRecorder) DeleteUser(id interface{}) *gomock.Call {
+// DeleteUser indicates an expected call of DeleteUser
+func (mr *MockRepositoryMockRecorder) DeleteUser(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockRepository)(nil).DeleteUser), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockRepository)(nil).DeleteUser), ctx, id)
 }
 
-// GetAllUsers mocks base method.
-func (m *MockRepository) GetAllUsers() []model.User {
+// IsAuthorized mocks base method
+func (m *MockRepository) IsAuthorized(ctx context.Context, username, password string) bool {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAllUsers")
-	ret0, _ := ret[0].([]model.User)
+	ret := m.ctrl.Call(m, "IsAuthorized", ctx, username, password)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsAuthorized indicates an expected call of IsAuthorized
+func (mr *MockRepositoryMockRecorder) IsAuthorized(ctx, username, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsAuthorized", reflect.TypeOf((*MockRepository)(nil).IsAuthorized), ctx, username, password)
+}
+
+// MockSessionRepository is a mock of SessionRepository interface
+type MockSessionRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockSessionRepositoryMockRecorder
+}
+
+// MockSessionRepositoryMockRecorder is the mock recorder for MockSessionRepository
+type MockSessionRepositoryMockRecorder struct {
+	mock *MockSessionRepository
+}
+
+// NewMockSessionRepository creates a new mock instance
+func NewMockSessionRepository(ctrl *gomock.Controller) *MockSessionRepository {
+	mock := &MockSessionRepository{ctrl: ctrl}
+	mock.recorder = &MockSessionRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockSessionRepository) EXPECT() *MockSessionRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateSession mocks base method
+func (m *MockSessionRepository) CreateSession(ctx context.Context, s model.Session) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", ctx, s)
+	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// GetAllUsers indicates an expected call of GetAllUsers.
-func (mr *MockRepositoryMockRecorder) GetAllUsers() *gomock.Call {
+// CreateSession indicates an expected call of CreateSession
+func (mr *MockSessionRepositoryMockRecorder) CreateSession(ctx, s interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllUsers", reflect.TypeOf((*MockRepository)(nil).GetAllUsers))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockSessionRepository)(nil).CreateSession), ctx, s)
 }
 
-// GetUserByID mocks base method.
-func (m *MockRepository) GetUserByID(id string) (model.User, error) {
+// GetSession mocks base method
+func (m *MockSessionRepository) GetSession(ctx context.Context, id string) (model.Session, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserByID", id)
-	ret0, _ := ret[0].(model.User)
+	ret := m.ctrl.Call(m, "GetSession", ctx, id)
+	ret0, _ := ret[0].(model.Session)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserByID indicates an expected call of GetUserByID.
-func (mr *MockRepositoryMockRecorder) GetUserByID(id interface{}) *gomock.Call {
+// GetSession indicates an expected call of GetSession
+func (mr *MockSessionRepositoryMockRecorder) GetSession(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockRepository)(nil).GetUserByID), id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSession", reflect.TypeOf((*MockSessionRepository)(nil).GetSession), ctx, id)
 }
 
-// GetUserByName mocks base method.
-func (m *MockRepository) GetUserByName(name string) (model.User, error) {
+// GetSessionsByUser mocks base method
+func (m *MockSessionRepository) GetSessionsByUser(ctx context.Context, userID string) []model.Session {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserByName", name)
-	ret0, _ := ret[0].(model.User)
+	ret := m.ctrl.Call(m, "GetSessionsByUser", ctx, userID)
+	ret0, _ := ret[0].([]model.Session)
+	return ret0
+}
+
+// GetSessionsByUser indicates an expected call of GetSessionsByUser
+func (mr *MockSessionRepositoryMockRecorder) GetSessionsByUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionsByUser", reflect.TypeOf((*MockSessionRepository)(nil).GetSessionsByUser), ctx, userID)
+}
+
+// UpdateSession mocks base method
+func (m *MockSessionRepository) UpdateSession(ctx context.Context, s model.Session) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSession", ctx, s)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSession indicates an expected call of UpdateSession
+func (mr *MockSessionRepositoryMockRecorder) UpdateSession(ctx, s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSession", reflect.TypeOf((*MockSessionRepository)(nil).UpdateSession), ctx, s)
+}
+
+// DeleteSession mocks base method
+func (m *MockSessionRepository) DeleteSession(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSession", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSession indicates an expected call of DeleteSession
+func (mr *MockSessionRepositoryMockRecorder) DeleteSession(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSession", reflect.TypeOf((*MockSessionRepository)(nil).DeleteSession), ctx, id)
+}
+
+// DeleteSessionsByUser mocks base method
+func (m *MockSessionRepository) DeleteSessionsByUser(ctx context.Context, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSessionsByUser", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSessionsByUser indicates an expected call of DeleteSessionsByUser
+func (mr *MockSessionRepositoryMockRecorder) DeleteSessionsByUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSessionsByUser", reflect.TypeOf((*MockSessionRepository)(nil).DeleteSessionsByUser), ctx, userID)
+}
+
+// DeleteExpiredSessions mocks base method
+func (m *MockSessionRepository) DeleteExpiredSessions(ctx context.Context, now time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredSessions", ctx, now)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredSessions indicates an expected call of DeleteExpiredSessions
+func (mr *MockSessionRepositoryMockRecorder) DeleteExpiredSessions(ctx, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredSessions", reflect.TypeOf((*MockSessionRepository)(nil).DeleteExpiredSessions), ctx, now)
+}
+
+// MockTOTPRepository is a mock of TOTPRepository interface
+type MockTOTPRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockTOTPRepositoryMockRecorder
+}
+
+// MockTOTPRepositoryMockRecorder is the mock recorder for MockTOTPRepository
+type MockTOTPRepositoryMockRecorder struct {
+	mock *MockTOTPRepository
+}
+
+// NewMockTOTPRepository creates a new mock instance
+func NewMockTOTPRepository(ctrl *gomock.Controller) *MockTOTPRepository {
+	mock := &MockTOTPRepository{ctrl: ctrl}
+	mock.recorder = &MockTOTPRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockTOTPRepository) EXPECT() *MockTOTPRepositoryMockRecorder {
+	return m.recorder
+}
+
+// GetTOTP mocks base method
+func (m *MockTOTPRepository) GetTOTP(ctx context.Context, userID string) (model.TOTP, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTOTP", ctx, userID)
+	ret0, _ := ret[0].(model.TOTP)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserByName indicates an expected call of GetUserByName.
-func (mr *MockRepositoryMockRecorder) GetUserByName(name interface{}) *gomock.Call {
+// GetTOTP indicates an expected call of GetTOTP
+func (mr *MockTOTPRepositoryMockRecorder) GetTOTP(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTOTP", reflect.TypeOf((*MockTOTPRepository)(nil).GetTOTP), ctx, userID)
+}
+
+// SaveTOTP mocks base method
+func (m *MockTOTPRepository) SaveTOTP(ctx context.Context, t model.TOTP) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveTOTP", ctx, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveTOTP indicates an expected call of SaveTOTP
+func (mr *MockTOTPRepositoryMockRecorder) SaveTOTP(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveTOTP", reflect.TypeOf((*MockTOTPRepository)(nil).SaveTOTP), ctx, t)
+}
+
+// DeleteTOTP mocks base method
+func (m *MockTOTPRepository) DeleteTOTP(ctx context.Context, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTOTP", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTOTP indicates an expected call of DeleteTOTP
+func (mr *MockTOTPRepositoryMockRecorder) DeleteTOTP(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTOTP", reflect.TypeOf((*MockTOTPRepository)(nil).DeleteTOTP), ctx, userID)
+}
+
+// SetRecoveryCodes mocks base method
+func (m *MockTOTPRepository) SetRecoveryCodes(ctx context.Context, userID string, codes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRecoveryCodes", ctx, userID, codes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRecoveryCodes indicates an expected call of SetRecoveryCodes
+func (mr *MockTOTPRepositoryMockRecorder) SetRecoveryCodes(ctx, userID, codes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRecoveryCodes", reflect.TypeOf((*MockTOTPRepository)(nil).SetRecoveryCodes), ctx, userID, codes)
+}
+
+// UseRecoveryCode mocks base method
+func (m *MockTOTPRepository) UseRecoveryCode(ctx context.Context, userID, code string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, userID, code)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode
+func (mr *MockTOTPRepositoryMockRecorder) UseRecoveryCode(ctx, userID, code interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByName", reflect.TypeOf((*MockRepository)(nil).GetUserByName), name)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockTOTPRepository)(nil).UseRecoveryCode), ctx, userID, code)
 }
 
-// IsAuthorized mocks base method.
-func (m *MockRepository) IsAuthorized(username, password string) bool {
+// UseTOTPStep mocks base method
+func (m *MockTOTPRepository) UseTOTPStep(ctx context.Context, userID string, step int64) bool {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "IsAuthorized", username, password)
+	ret := m.ctrl.Call(m, "UseTOTPStep", ctx, userID, step)
 	ret0, _ := ret[0].(bool)
 	return ret0
 }
 
-// IsAuthorized indicates an expected call of IsAuthorized.
-func (mr *MockRepositoryMockRecorder) IsAuthorized(username, password interface{}) *gomock.Call {
+// UseTOTPStep indicates an expected call of UseTOTPStep
+func (mr *MockTOTPRepositoryMockRecorder) UseTOTPStep(ctx, userID, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockTOTPRepository)(nil).UseTOTPStep), ctx, userID, step)
+}
+
+// MockTokenRepository is a mock of TokenRepository interface
+type MockTokenRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockTokenRepositoryMockRecorder
+}
+
+// MockTokenRepositoryMockRecorder is the mock recorder for MockTokenRepository
+type MockTokenRepositoryMockRecorder struct {
+	mock *MockTokenRepository
+}
+
+// NewMockTokenRepository creates a new mock instance
+func NewMockTokenRepository(ctrl *gomock.Controller) *MockTokenRepository {
+	mock := &MockTokenRepository{ctrl: ctrl}
+	mock.recorder = &MockTokenRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockTokenRepository) EXPECT() *MockTokenRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateToken mocks base method
+func (m *MockTokenRepository) CreateToken(ctx context.Context, t model.Token) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateToken", ctx, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateToken indicates an expected call of CreateToken
+func (mr *MockTokenRepositoryMockRecorder) CreateToken(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateToken", reflect.TypeOf((*MockTokenRepository)(nil).CreateToken), ctx, t)
+}
+
+// ConsumeToken mocks base method
+func (m *MockTokenRepository) ConsumeToken(ctx context.Context, id, purpose string) (model.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeToken", ctx, id, purpose)
+	ret0, _ := ret[0].(model.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeToken indicates an expected call of ConsumeToken
+func (mr *MockTokenRepositoryMockRecorder) ConsumeToken(ctx, id, purpose interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeToken", reflect.TypeOf((*MockTokenRepository)(nil).ConsumeToken), ctx, id, purpose)
+}
+
+// DeleteTokensByUser mocks base method
+func (m *MockTokenRepository) DeleteTokensByUser(ctx context.Context, userID, purpose string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTokensByUser", ctx, userID, purpose)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTokensByUser indicates an expected call of DeleteTokensByUser
+func (mr *MockTokenRepositoryMockRecorder) DeleteTokensByUser(ctx, userID, purpose interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTokensByUser", reflect.TypeOf((*MockTokenRepository)(nil).DeleteTokensByUser), ctx, userID, purpose)
+}
+
+// DeleteExpiredTokens mocks base method
+func (m *MockTokenRepository) DeleteExpiredTokens(ctx context.Context, now time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredTokens", ctx, now)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredTokens indicates an expected call of DeleteExpiredTokens
+func (mr *MockTokenRepositoryMockRecorder) DeleteExpiredTokens(ctx, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredTokens", reflect.TypeOf((*MockTokenRepository)(nil).DeleteExpiredTokens), ctx, now)
+}
+
+// MockTenantRepository is a mock of TenantRepository interface
+type MockTenantRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockTenantRepositoryMockRecorder
+}
+
+// MockTenantRepositoryMockRecorder is the mock recorder for MockTenantRepository
+type MockTenantRepositoryMockRecorder struct {
+	mock *MockTenantRepository
+}
+
+// NewMockTenantRepository creates a new mock instance
+func NewMockTenantRepository(ctrl *gomock.Controller) *MockTenantRepository {
+	mock := &MockTenantRepository{ctrl: ctrl}
+	mock.recorder = &MockTenantRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockTenantRepository) EXPECT() *MockTenantRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateTenant mocks base method
+func (m *MockTenantRepository) CreateTenant(ctx context.Context, t model.Tenant) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTenant", ctx, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateTenant indicates an expected call of CreateTenant
+func (mr *MockTenantRepositoryMockRecorder) CreateTenant(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTenant", reflect.TypeOf((*MockTenantRepository)(nil).CreateTenant), ctx, t)
+}
+
+// GetTenant mocks base method
+func (m *MockTenantRepository) GetTenant(ctx context.Context, id string) (model.Tenant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTenant", ctx, id)
+	ret0, _ := ret[0].(model.Tenant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTenant indicates an expected call of GetTenant
+func (mr *MockTenantRepositoryMockRecorder) GetTenant(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTenant", reflect.TypeOf((*MockTenantRepository)(nil).GetTenant), ctx, id)
+}
+
+// GetAllTenants mocks base method
+func (m *MockTenantRepository) GetAllTenants(ctx context.Context) []model.Tenant {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllTenants", ctx)
+	ret0, _ := ret[0].([]model.Tenant)
+	return ret0
+}
+
+// GetAllTenants indicates an expected call of GetAllTenants
+func (mr *MockTenantRepositoryMockRecorder) GetAllTenants(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllTenants", reflect.TypeOf((*MockTenantRepository)(nil).GetAllTenants), ctx)
+}
+
+// UpdateTenant mocks base method
+func (m *MockTenantRepository) UpdateTenant(ctx context.Context, t model.Tenant) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTenant", ctx, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateTenant indicates an expected call of UpdateTenant
+func (mr *MockTenantRepositoryMockRecorder) UpdateTenant(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTenant", reflect.TypeOf((*MockTenantRepository)(nil).UpdateTenant), ctx, t)
+}
+
+// DeleteTenant mocks base method
+func (m *MockTenantRepository) DeleteTenant(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTenant", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTenant indicates an expected call of DeleteTenant
+func (mr *MockTenantRepositoryMockRecorder) DeleteTenant(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTenant", reflect.TypeOf((*MockTenantRepository)(nil).DeleteTenant), ctx, id)
+}
+
+// MockGroupRepository is a mock of GroupRepository interface
+type MockGroupRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockGroupRepositoryMockRecorder
+}
+
+// MockGroupRepositoryMockRecorder is the mock recorder for MockGroupRepository
+type MockGroupRepositoryMockRecorder struct {
+	mock *MockGroupRepository
+}
+
+// NewMockGroupRepository creates a new mock instance
+func NewMockGroupRepository(ctrl *gomock.Controller) *MockGroupRepository {
+	mock := &MockGroupRepository{ctrl: ctrl}
+	mock.recorder = &MockGroupRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockGroupRepository) EXPECT() *MockGroupRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateGroup mocks base method
+func (m *MockGroupRepository) CreateGroup(ctx context.Context, g model.Group) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateGroup", ctx, g)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateGroup indicates an expected call of CreateGroup
+func (mr *MockGroupRepositoryMockRecorder) CreateGroup(ctx, g interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateGroup", reflect.TypeOf((*MockGroupRepository)(nil).CreateGroup), ctx, g)
+}
+
+// GetGroup mocks base method
+func (m *MockGroupRepository) GetGroup(ctx context.Context, id string) (model.Group, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGroup", ctx, id)
+	ret0, _ := ret[0].(model.Group)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGroup indicates an expected call of GetGroup
+func (mr *MockGroupRepositoryMockRecorder) GetGroup(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGroup", reflect.TypeOf((*MockGroupRepository)(nil).GetGroup), ctx, id)
+}
+
+// GetGroups mocks base method
+func (m *MockGroupRepository) GetGroups(ctx context.Context) []model.Group {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGroups", ctx)
+	ret0, _ := ret[0].([]model.Group)
+	return ret0
+}
+
+// GetGroups indicates an expected call of GetGroups
+func (mr *MockGroupRepositoryMockRecorder) GetGroups(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGroups", reflect.TypeOf((*MockGroupRepository)(nil).GetGroups), ctx)
+}
+
+// UpdateGroup mocks base method
+func (m *MockGroupRepository) UpdateGroup(ctx context.Context, g model.Group) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateGroup", ctx, g)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateGroup indicates an expected call of UpdateGroup
+func (mr *MockGroupRepositoryMockRecorder) UpdateGroup(ctx, g interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateGroup", reflect.TypeOf((*MockGroupRepository)(nil).UpdateGroup), ctx, g)
+}
+
+// DeleteGroup mocks base method
+func (m *MockGroupRepository) DeleteGroup(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteGroup", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteGroup indicates an expected call of DeleteGroup
+func (mr *MockGroupRepositoryMockRecorder) DeleteGroup(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteGroup", reflect.TypeOf((*MockGroupRepository)(nil).DeleteGroup), ctx, id)
+}
+
+// AddMember mocks base method
+func (m *MockGroupRepository) AddMember(ctx context.Context, groupID, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddMember", ctx, groupID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddMember indicates an expected call of AddMember
+func (mr *MockGroupRepositoryMockRecorder) AddMember(ctx, groupID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMember", reflect.TypeOf((*MockGroupRepository)(nil).AddMember), ctx, groupID, userID)
+}
+
+// RemoveMember mocks base method
+func (m *MockGroupRepository) RemoveMember(ctx context.Context, groupID, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveMember", ctx, groupID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveMember indicates an expected call of RemoveMember
+func (mr *MockGroupRepositoryMockRecorder) RemoveMember(ctx, groupID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveMember", reflect.TypeOf((*MockGroupRepository)(nil).RemoveMember), ctx, groupID, userID)
+}
+
+// GetMembers mocks base method
+func (m *MockGroupRepository) GetMembers(ctx context.Context, id string) ([]model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMembers", ctx, id)
+	ret0, _ := ret[0].([]model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMembers indicates an expected call of GetMembers
+func (mr *MockGroupRepositoryMockRecorder) GetMembers(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMembers", reflect.TypeOf((*MockGroupRepository)(nil).GetMembers), ctx, id)
+}
+
+// GetGroupsByUser mocks base method
+func (m *MockGroupRepository) GetGroupsByUser(ctx context.Context, userID string) []model.Group {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGroupsByUser", ctx, userID)
+	ret0, _ := ret[0].([]model.Group)
+	return ret0
+}
+
+// GetGroupsByUser indicates an expected call of GetGroupsByUser
+func (mr *MockGroupRepositoryMockRecorder) GetGroupsByUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGroupsByUser", reflect.TypeOf((*MockGroupRepository)(nil).GetGroupsByUser), ctx, userID)
+}
+
+// MockAPIKeyRepository is a mock of APIKeyRepository interface
+type MockAPIKeyRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockAPIKeyRepositoryMockRecorder
+}
+
+// MockAPIKeyRepositoryMockRecorder is the mock recorder for MockAPIKeyRepository
+type MockAPIKeyRepositoryMockRecorder struct {
+	mock *MockAPIKeyRepository
+}
+
+// NewMockAPIKeyRepository creates a new mock instance
+func NewMockAPIKeyRepository(ctrl *gomock.Controller) *MockAPIKeyRepository {
+	mock := &MockAPIKeyRepository{ctrl: ctrl}
+	mock.recorder = &MockAPIKeyRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockAPIKeyRepository) EXPECT() *MockAPIKeyRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateAPIKey mocks base method
+func (m *MockAPIKeyRepository) CreateAPIKey(ctx context.Context, k model.APIKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", ctx, k)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey
+func (mr *MockAPIKeyRepositoryMockRecorder) CreateAPIKey(ctx, k interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockAPIKeyRepository)(nil).CreateAPIKey), ctx, k)
+}
+
+// GetAPIKey mocks base method
+func (m *MockAPIKeyRepository) GetAPIKey(ctx context.Context, id string) (model.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKey", ctx, id)
+	ret0, _ := ret[0].(model.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKey indicates an expected call of GetAPIKey
+func (mr *MockAPIKeyRepositoryMockRecorder) GetAPIKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKey", reflect.TypeOf((*MockAPIKeyRepository)(nil).GetAPIKey), ctx, id)
+}
+
+// GetAPIKeysByUser mocks base method
+func (m *MockAPIKeyRepository) GetAPIKeysByUser(ctx context.Context, userID string) []model.APIKey {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeysByUser", ctx, userID)
+	ret0, _ := ret[0].([]model.APIKey)
+	return ret0
+}
+
+// GetAPIKeysByUser indicates an expected call of GetAPIKeysByUser
+func (mr *MockAPIKeyRepositoryMockRecorder) GetAPIKeysByUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeysByUser", reflect.TypeOf((*MockAPIKeyRepository)(nil).GetAPIKeysByUser), ctx, userID)
+}
+
+// TouchAPIKey mocks base method
+func (m *MockAPIKeyRepository) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchAPIKey", ctx, id, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchAPIKey indicates an expected call of TouchAPIKey
+func (mr *MockAPIKeyRepositoryMockRecorder) TouchAPIKey(ctx, id, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchAPIKey", reflect.TypeOf((*MockAPIKeyRepository)(nil).TouchAPIKey), ctx, id, at)
+}
+
+// DeleteAPIKey mocks base method
+func (m *MockAPIKeyRepository) DeleteAPIKey(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAPIKey", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAPIKey indicates an expected call of DeleteAPIKey
+func (mr *MockAPIKeyRepositoryMockRecorder) DeleteAPIKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAPIKey", reflect.TypeOf((*MockAPIKeyRepository)(nil).DeleteAPIKey), ctx, id)
+}
+
+// MockOAuthRepository is a mock of OAuthRepository interface
+type MockOAuthRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockOAuthRepositoryMockRecorder
+}
+
+// MockOAuthRepositoryMockRecorder is the mock recorder for MockOAuthRepository
+type MockOAuthRepositoryMockRecorder struct {
+	mock *MockOAuthRepository
+}
+
+// NewMockOAuthRepository creates a new mock instance
+func NewMockOAuthRepository(ctrl *gomock.Controller) *MockOAuthRepository {
+	mock := &MockOAuthRepository{ctrl: ctrl}
+	mock.recorder = &MockOAuthRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockOAuthRepository) EXPECT() *MockOAuthRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateOAuthClient mocks base method
+func (m *MockOAuthRepository) CreateOAuthClient(ctx context.Context, c model.OAuthClient) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOAuthClient", ctx, c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOAuthClient indicates an expected call of CreateOAuthClient
+func (mr *MockOAuthRepositoryMockRecorder) CreateOAuthClient(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOAuthClient", reflect.TypeOf((*MockOAuthRepository)(nil).CreateOAuthClient), ctx, c)
+}
+
+// GetOAuthClient mocks base method
+func (m *MockOAuthRepository) GetOAuthClient(ctx context.Context, id string) (model.OAuthClient, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOAuthClient", ctx, id)
+	ret0, _ := ret[0].(model.OAuthClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOAuthClient indicates an expected call of GetOAuthClient
+func (mr *MockOAuthRepositoryMockRecorder) GetOAuthClient(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOAuthClient", reflect.TypeOf((*MockOAuthRepository)(nil).GetOAuthClient), ctx, id)
+}
+
+// GetOAuthClients mocks base method
+func (m *MockOAuthRepository) GetOAuthClients(ctx context.Context) []model.OAuthClient {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOAuthClients", ctx)
+	ret0, _ := ret[0].([]model.OAuthClient)
+	return ret0
+}
+
+// GetOAuthClients indicates an expected call of GetOAuthClients
+func (mr *MockOAuthRepositoryMockRecorder) GetOAuthClients(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOAuthClients", reflect.TypeOf((*MockOAuthRepository)(nil).GetOAuthClients), ctx)
+}
+
+// UpdateOAuthClient mocks base method
+func (m *MockOAuthRepository) UpdateOAuthClient(ctx context.Context, c model.OAuthClient) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOAuthClient", ctx, c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateOAuthClient indicates an expected call of UpdateOAuthClient
+func (mr *MockOAuthRepositoryMockRecorder) UpdateOAuthClient(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOAuthClient", reflect.TypeOf((*MockOAuthRepository)(nil).UpdateOAuthClient), ctx, c)
+}
+
+// DeleteOAuthClient mocks base method
+func (m *MockOAuthRepository) DeleteOAuthClient(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOAuthClient", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOAuthClient indicates an expected call of DeleteOAuthClient
+func (mr *MockOAuthRepositoryMockRecorder) DeleteOAuthClient(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOAuthClient", reflect.TypeOf((*MockOAuthRepository)(nil).DeleteOAuthClient), ctx, id)
+}
+
+// SaveConsent mocks base method
+func (m *MockOAuthRepository) SaveConsent(ctx context.Context, c model.OAuthConsent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveConsent", ctx, c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveConsent indicates an expected call of SaveConsent
+func (mr *MockOAuthRepositoryMockRecorder) SaveConsent(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveConsent", reflect.TypeOf((*MockOAuthRepository)(nil).SaveConsent), ctx, c)
+}
+
+// GetConsent mocks base method
+func (m *MockOAuthRepository) GetConsent(ctx context.Context, userID, clientID string) (model.OAuthConsent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetConsent", ctx, userID, clientID)
+	ret0, _ := ret[0].(model.OAuthConsent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetConsent indicates an expected call of GetConsent
+func (mr *MockOAuthRepositoryMockRecorder) GetConsent(ctx, userID, clientID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConsent", reflect.TypeOf((*MockOAuthRepository)(nil).GetConsent), ctx, userID, clientID)
+}
+
+// GetConsentsByUser mocks base method
+func (m *MockOAuthRepository) GetConsentsByUser(ctx context.Context, userID string) []model.OAuthConsent {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetConsentsByUser", ctx, userID)
+	ret0, _ := ret[0].([]model.OAuthConsent)
+	return ret0
+}
+
+// GetConsentsByUser indicates an expected call of GetConsentsByUser
+func (mr *MockOAuthRepositoryMockRecorder) GetConsentsByUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConsentsByUser", reflect.TypeOf((*MockOAuthRepository)(nil).GetConsentsByUser), ctx, userID)
+}
+
+// DeleteConsent mocks base method
+func (m *MockOAuthRepository) DeleteConsent(ctx context.Context, userID, clientID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteConsent", ctx, userID, clientID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteConsent indicates an expected call of DeleteConsent
+func (mr *MockOAuthRepositoryMockRecorder) DeleteConsent(ctx, userID, clientID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteConsent", reflect.TypeOf((*MockOAuthRepository)(nil).DeleteConsent), ctx, userID, clientID)
+}
+
+// CreateOAuthToken mocks base method
+func (m *MockOAuthRepository) CreateOAuthToken(ctx context.Context, t model.OAuthToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOAuthToken", ctx, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOAuthToken indicates an expected call of CreateOAuthToken
+func (mr *MockOAuthRepositoryMockRecorder) CreateOAuthToken(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOAuthToken", reflect.TypeOf((*MockOAuthRepository)(nil).CreateOAuthToken), ctx, t)
+}
+
+// GetOAuthToken mocks base method
+func (m *MockOAuthRepository) GetOAuthToken(ctx context.Context, id string) (model.OAuthToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOAuthToken", ctx, id)
+	ret0, _ := ret[0].(model.OAuthToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOAuthToken indicates an expected call of GetOAuthToken
+func (mr *MockOAuthRepositoryMockRecorder) GetOAuthToken(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOAuthToken", reflect.TypeOf((*MockOAuthRepository)(nil).GetOAuthToken), ctx, id)
+}
+
+// ConsumeOAuthToken mocks base method
+func (m *MockOAuthRepository) ConsumeOAuthToken(ctx context.Context, id, kind string) (model.OAuthToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeOAuthToken", ctx, id, kind)
+	ret0, _ := ret[0].(model.OAuthToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeOAuthToken indicates an expected call of ConsumeOAuthToken
+func (mr *MockOAuthRepositoryMockRecorder) ConsumeOAuthToken(ctx, id, kind interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeOAuthToken", reflect.TypeOf((*MockOAuthRepository)(nil).ConsumeOAuthToken), ctx, id, kind)
+}
+
+// DeleteOAuthToken mocks base method
+func (m *MockOAuthRepository) DeleteOAuthToken(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOAuthToken", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOAuthToken indicates an expected call of DeleteOAuthToken
+func (mr *MockOAuthRepositoryMockRecorder) DeleteOAuthToken(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOAuthToken", reflect.TypeOf((*MockOAuthRepository)(nil).DeleteOAuthToken), ctx, id)
+}
+
+// DeleteOAuthGrant mocks base method
+func (m *MockOAuthRepository) DeleteOAuthGrant(ctx context.Context, grantID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOAuthGrant", ctx, grantID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOAuthGrant indicates an expected call of DeleteOAuthGrant
+func (mr *MockOAuthRepositoryMockRecorder) DeleteOAuthGrant(ctx, grantID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOAuthGrant", reflect.TypeOf((*MockOAuthRepository)(nil).DeleteOAuthGrant), ctx, grantID)
+}
+
+// DeleteUserOAuthGrants mocks base method
+func (m *MockOAuthRepository) DeleteUserOAuthGrants(ctx context.Context, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserOAuthGrants", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserOAuthGrants indicates an expected call of DeleteUserOAuthGrants
+func (mr *MockOAuthRepositoryMockRecorder) DeleteUserOAuthGrants(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserOAuthGrants", reflect.TypeOf((*MockOAuthRepository)(nil).DeleteUserOAuthGrants), ctx, userID)
+}
+
+// DeleteExpiredOAuthTokens mocks base method
+func (m *MockOAuthRepository) DeleteExpiredOAuthTokens(ctx context.Context, now time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredOAuthTokens", ctx, now)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredOAuthTokens indicates an expected call of DeleteExpiredOAuthTokens
+func (mr *MockOAuthRepositoryMockRecorder) DeleteExpiredOAuthTokens(ctx, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredOAuthTokens", reflect.TypeOf((*MockOAuthRepository)(nil).DeleteExpiredOAuthTokens), ctx, now)
+}
+
+// MockSigningKeyRepository is a mock of SigningKeyRepository interface
+type MockSigningKeyRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockSigningKeyRepositoryMockRecorder
+}
+
+// MockSigningKeyRepositoryMockRecorder is the mock recorder for MockSigningKeyRepository
+type MockSigningKeyRepositoryMockRecorder struct {
+	mock *MockSigningKeyRepository
+}
+
+// NewMockSigningKeyRepository creates a new mock instance
+func NewMockSigningKeyRepository(ctrl *gomock.Controller) *MockSigningKeyRepository {
+	mock := &MockSigningKeyRepository{ctrl: ctrl}
+	mock.recorder = &MockSigningKeyRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockSigningKeyRepository) EXPECT() *MockSigningKeyRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateSigningKey mocks base method
+func (m *MockSigningKeyRepository) CreateSigningKey(ctx context.Context, k model.SigningKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSigningKey", ctx, k)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSigningKey indicates an expected call of CreateSigningKey
+func (mr *MockSigningKeyRepositoryMockRecorder) CreateSigningKey(ctx, k interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSigningKey", reflect.TypeOf((*MockSigningKeyRepository)(nil).CreateSigningKey), ctx, k)
+}
+
+// GetSigningKeys mocks base method
+func (m *MockSigningKeyRepository) GetSigningKeys(ctx context.Context) []model.SigningKey {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSigningKeys", ctx)
+	ret0, _ := ret[0].([]model.SigningKey)
+	return ret0
+}
+
+// GetSigningKeys indicates an expected call of GetSigningKeys
+func (mr *MockSigningKeyRepositoryMockRecorder) GetSigningKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSigningKeys", reflect.TypeOf((*MockSigningKeyRepository)(nil).GetSigningKeys), ctx)
+}
+
+// RetireSigningKey mocks base method
+func (m *MockSigningKeyRepository) RetireSigningKey(ctx context.Context, id string, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetireSigningKey", ctx, id, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetireSigningKey indicates an expected call of RetireSigningKey
+func (mr *MockSigningKeyRepositoryMockRecorder) RetireSigningKey(ctx, id, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetireSigningKey", reflect.TypeOf((*MockSigningKeyRepository)(nil).RetireSigningKey), ctx, id, at)
+}
+
+// DeleteSigningKey mocks base method
+func (m *MockSigningKeyRepository) DeleteSigningKey(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSigningKey", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSigningKey indicates an expected call of DeleteSigningKey
+func (mr *MockSigningKeyRepositoryMockRecorder) DeleteSigningKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSigningKey", reflect.TypeOf((*MockSigningKeyRepository)(nil).DeleteSigningKey), ctx, id)
+}
+
+// MockIdentityRepository is a mock of IdentityRepository interface
+type MockIdentityRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockIdentityRepositoryMockRecorder
+}
+
+// MockIdentityRepositoryMockRecorder is the mock recorder for MockIdentityRepository
+type MockIdentityRepositoryMockRecorder struct {
+	mock *MockIdentityRepository
+}
+
+// NewMockIdentityRepository creates a new mock instance
+func NewMockIdentityRepository(ctrl *gomock.Controller) *MockIdentityRepository {
+	mock := &MockIdentityRepository{ctrl: ctrl}
+	mock.recorder = &MockIdentityRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockIdentityRepository) EXPECT() *MockIdentityRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateIdentity mocks base method
+func (m *MockIdentityRepository) CreateIdentity(ctx context.Context, i model.Identity) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIdentity", ctx, i)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateIdentity indicates an expected call of CreateIdentity
+func (mr *MockIdentityRepositoryMockRecorder) CreateIdentity(ctx, i interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIdentity", reflect.TypeOf((*MockIdentityRepository)(nil).CreateIdentity), ctx, i)
+}
+
+// GetIdentity mocks base method
+func (m *MockIdentityRepository) GetIdentity(ctx context.Context, connector, subject string) (model.Identity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdentity", ctx, connector, subject)
+	ret0, _ := ret[0].(model.Identity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdentity indicates an expected call of GetIdentity
+func (mr *MockIdentityRepositoryMockRecorder) GetIdentity(ctx, connector, subject interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdentity", reflect.TypeOf((*MockIdentityRepository)(nil).GetIdentity), ctx, connector, subject)
+}
+
+// GetIdentitiesByUser mocks base method
+func (m *MockIdentityRepository) GetIdentitiesByUser(ctx context.Context, userID string) []model.Identity {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdentitiesByUser", ctx, userID)
+	ret0, _ := ret[0].([]model.Identity)
+	return ret0
+}
+
+// GetIdentitiesByUser indicates an expected call of GetIdentitiesByUser
+func (mr *MockIdentityRepositoryMockRecorder) GetIdentitiesByUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdentitiesByUser", reflect.TypeOf((*MockIdentityRepository)(nil).GetIdentitiesByUser), ctx, userID)
+}
+
+// UpdateIdentity mocks base method
+func (m *MockIdentityRepository) UpdateIdentity(ctx context.Context, i model.Identity) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateIdentity", ctx, i)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateIdentity indicates an expected call of UpdateIdentity
+func (mr *MockIdentityRepositoryMockRecorder) UpdateIdentity(ctx, i interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateIdentity", reflect.TypeOf((*MockIdentityRepository)(nil).UpdateIdentity), ctx, i)
+}
+
+// DeleteIdentity mocks base method
+func (m *MockIdentityRepository) DeleteIdentity(ctx context.Context, connector, subject string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdentity", ctx, connector, subject)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIdentity indicates an expected call of DeleteIdentity
+func (mr *MockIdentityRepositoryMockRecorder) DeleteIdentity(ctx, connector, subject interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdentity", reflect.TypeOf((*MockIdentityRepository)(nil).DeleteIdentity), ctx, connector, subject)
+}
+
+// MockIdempotencyRepository is a mock of IdempotencyRepository interface
+type MockIdempotencyRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockIdempotencyRepositoryMockRecorder
+}
+
+// MockIdempotencyRepositoryMockRecorder is the mock recorder for MockIdempotencyRepository
+type MockIdempotencyRepositoryMockRecorder struct {
+	mock *MockIdempotencyRepository
+}
+
+// NewMockIdempotencyRepository creates a new mock instance
+func NewMockIdempotencyRepository(ctrl *gomock.Controller) *MockIdempotencyRepository {
+	mock := &MockIdempotencyRepository{ctrl: ctrl}
+	mock.recorder = &MockIdempotencyRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockIdempotencyRepository) EXPECT() *MockIdempotencyRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateIdempotencyRecord mocks base method
+func (m *MockIdempotencyRepository) CreateIdempotencyRecord(ctx context.Context, r model.IdempotencyRecord) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIdempotencyRecord", ctx, r)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateIdempotencyRecord indicates an expected call of CreateIdempotencyRecord
+func (mr *MockIdempotencyRepositoryMockRecorder) CreateIdempotencyRecord(ctx, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIdempotencyRecord", reflect.TypeOf((*MockIdempotencyRepository)(nil).CreateIdempotencyRecord), ctx, r)
+}
+
+// GetIdempotencyRecord mocks base method
+func (m *MockIdempotencyRepository) GetIdempotencyRecord(ctx context.Context, key string) (model.IdempotencyRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdempotencyRecord", ctx, key)
+	ret0, _ := ret[0].(model.IdempotencyRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdempotencyRecord indicates an expected call of GetIdempotencyRecord
+func (mr *MockIdempotencyRepositoryMockRecorder) GetIdempotencyRecord(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdempotencyRecord", reflect.TypeOf((*MockIdempotencyRepository)(nil).GetIdempotencyRecord), ctx, key)
+}
+
+// UpdateIdempotencyRecord mocks base method
+func (m *MockIdempotencyRepository) UpdateIdempotencyRecord(ctx context.Context, r model.IdempotencyRecord) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateIdempotencyRecord", ctx, r)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateIdempotencyRecord indicates an expected call of UpdateIdempotencyRecord
+func (mr *MockIdempotencyRepositoryMockRecorder) UpdateIdempotencyRecord(ctx, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateIdempotencyRecord", reflect.TypeOf((*MockIdempotencyRepository)(nil).UpdateIdempotencyRecord), ctx, r)
+}
+
+// DeleteIdempotencyRecord mocks base method
+func (m *MockIdempotencyRepository) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdempotencyRecord", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIdempotencyRecord indicates an expected call of DeleteIdempotencyRecord
+func (mr *MockIdempotencyRepositoryMockRecorder) DeleteIdempotencyRecord(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdempotencyRecord", reflect.TypeOf((*MockIdempotencyRepository)(nil).DeleteIdempotencyRecord), ctx, key)
+}
+
+// DeleteExpiredIdempotencyRecords mocks base method
+func (m *MockIdempotencyRepository) DeleteExpiredIdempotencyRecords(ctx context.Context, now time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredIdempotencyRecords", ctx, now)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredIdempotencyRecords indicates an expected call of DeleteExpiredIdempotencyRecords
+func (mr *MockIdempotencyRepositoryMockRecorder) DeleteExpiredIdempotencyRecords(ctx, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredIdempotencyRecords", reflect.TypeOf((*MockIdempotencyRepository)(nil).DeleteExpiredIdempotencyRecords), ctx, now)
+}
+
+// MockStorage is a mock of Storage interface
+type MockStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockStorageMockRecorder
+}
+
+// MockStorageMockRecorder is the mock recorder for MockStorage
+type MockStorageMockRecorder struct {
+	mock *MockStorage
+}
+
+// NewMockStorage creates a new mock instance
+func NewMockStorage(ctrl *gomock.Controller) *MockStorage {
+	mock := &MockStorage{ctrl: ctrl}
+	mock.recorder = &MockStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
+	return m.recorder
+}
+
+// CreateUser mocks base method
+func (m *MockStorage) CreateUser(ctx context.Context, u model.User) (model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", ctx, u)
+	ret0, _ := ret[0].(model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUser indicates an expected call of CreateUser
+func (mr *MockStorageMockRecorder) CreateUser(ctx, u interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStorage)(nil).CreateUser), ctx, u)
+}
+
+// GetAllUsers mocks base method
+func (m *MockStorage) GetAllUsers(ctx context.Context) []model.User {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllUsers", ctx)
+	ret0, _ := ret[0].([]model.User)
+	return ret0
+}
+
+// GetAllUsers indicates an expected call of GetAllUsers
+func (mr *MockStorageMockRecorder) GetAllUsers(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllUsers", reflect.TypeOf((*MockStorage)(nil).GetAllUsers), ctx)
+}
+
+// GetUserByName mocks base method
+func (m *MockStorage) GetUserByName(ctx context.Context, name string) (model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByName", ctx, name)
+	ret0, _ := ret[0].(model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByName indicates an expected call of GetUserByName
+func (mr *MockStorageMockRecorder) GetUserByName(ctx, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByName", reflect.TypeOf((*MockStorage)(nil).GetUserByName), ctx, name)
+}
+
+// GetUserByID mocks base method
+func (m *MockStorage) GetUserByID(ctx context.Context, id string) (model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByID", ctx, id)
+	ret0, _ := ret[0].(model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByID indicates an expected call of GetUserByID
+func (mr *MockStorageMockRecorder) GetUserByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockStorage)(nil).GetUserByID), ctx, id)
+}
+
+// UpdateUser mocks base method
+func (m *MockStorage) UpdateUser(ctx context.Context, u model.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, u)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateUser indicates an expected call of UpdateUser
+func (mr *MockStorageMockRecorder) UpdateUser(ctx, u interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockStorage)(nil).UpdateUser), ctx, u)
+}
+
+// DeleteUser mocks base method
+func (m *MockStorage) DeleteUser(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser
+func (mr *MockStorageMockRecorder) DeleteUser(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockStorage)(nil).DeleteUser), ctx, id)
+}
+
+// IsAuthorized mocks base method
+func (m *MockStorage) IsAuthorized(ctx context.Context, username, password string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsAuthorized", ctx, username, password)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsAuthorized indicates an expected call of IsAuthorized
+func (mr *MockStorageMockRecorder) IsAuthorized(ctx, username, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsAuthorized", reflect.TypeOf((*MockStorage)(nil).IsAuthorized), ctx, username, password)
+}
+
+// CreateSession mocks base method
+func (m *MockStorage) CreateSession(ctx context.Context, s model.Session) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", ctx, s)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSession indicates an expected call of CreateSession
+func (mr *MockStorageMockRecorder) CreateSession(ctx, s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockStorage)(nil).CreateSession), ctx, s)
+}
+
+// GetSession mocks base method
+func (m *MockStorage) GetSession(ctx context.Context, id string) (model.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSession", ctx, id)
+	ret0, _ := ret[0].(model.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSession indicates an expected call of GetSession
+func (mr *MockStorageMockRecorder) GetSession(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSession", reflect.TypeOf((*MockStorage)(nil).GetSession), ctx, id)
+}
+
+// GetSessionsByUser mocks base method
+func (m *MockStorage) GetSessionsByUser(ctx context.Context, userID string) []model.Session {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessionsByUser", ctx, userID)
+	ret0, _ := ret[0].([]model.Session)
+	return ret0
+}
+
+// GetSessionsByUser indicates an expected call of GetSessionsByUser
+func (mr *MockStorageMockRecorder) GetSessionsByUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionsByUser", reflect.TypeOf((*MockStorage)(nil).GetSessionsByUser), ctx, userID)
+}
+
+// UpdateSession mocks base method
+func (m *MockStorage) UpdateSession(ctx context.Context, s model.Session) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSession", ctx, s)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSession indicates an expected call of UpdateSession
+func (mr *MockStorageMockRecorder) UpdateSession(ctx, s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSession", reflect.TypeOf((*MockStorage)(nil).UpdateSession), ctx, s)
+}
+
+// DeleteSession mocks base method
+func (m *MockStorage) DeleteSession(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSession", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSession indicates an expected call of DeleteSession
+func (mr *MockStorageMockRecorder) DeleteSession(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSession", reflect.TypeOf((*MockStorage)(nil).DeleteSession), ctx, id)
+}
+
+// DeleteSessionsByUser mocks base method
+func (m *MockStorage) DeleteSessionsByUser(ctx context.Context, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSessionsByUser", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSessionsByUser indicates an expected call of DeleteSessionsByUser
+func (mr *MockStorageMockRecorder) DeleteSessionsByUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSessionsByUser", reflect.TypeOf((*MockStorage)(nil).DeleteSessionsByUser), ctx, userID)
+}
+
+// DeleteExpiredSessions mocks base method
+func (m *MockStorage) DeleteExpiredSessions(ctx context.Context, now time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredSessions", ctx, now)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredSessions indicates an expected call of DeleteExpiredSessions
+func (mr *MockStorageMockRecorder) DeleteExpiredSessions(ctx, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredSessions", reflect.TypeOf((*MockStorage)(nil).DeleteExpiredSessions), ctx, now)
+}
+
+// GetTOTP mocks base method
+func (m *MockStorage) GetTOTP(ctx context.Context, userID string) (model.TOTP, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTOTP", ctx, userID)
+	ret0, _ := ret[0].(model.TOTP)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTOTP indicates an expected call of GetTOTP
+func (mr *MockStorageMockRecorder) GetTOTP(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTOTP", reflect.TypeOf((*MockStorage)(nil).GetTOTP), ctx, userID)
+}
+
+// SaveTOTP mocks base method
+func (m *MockStorage) SaveTOTP(ctx context.Context, t model.TOTP) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveTOTP", ctx, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveTOTP indicates an expected call of SaveTOTP
+func (mr *MockStorageMockRecorder) SaveTOTP(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveTOTP", reflect.TypeOf((*MockStorage)(nil).SaveTOTP), ctx, t)
+}
+
+// DeleteTOTP mocks base method
+func (m *MockStorage) DeleteTOTP(ctx context.Context, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTOTP", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTOTP indicates an expected call of DeleteTOTP
+func (mr *MockStorageMockRecorder) DeleteTOTP(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTOTP", reflect.TypeOf((*MockStorage)(nil).DeleteTOTP), ctx, userID)
+}
+
+// SetRecoveryCodes mocks base method
+func (m *MockStorage) SetRecoveryCodes(ctx context.Context, userID string, codes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRecoveryCodes", ctx, userID, codes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRecoveryCodes indicates an expected call of SetRecoveryCodes
+func (mr *MockStorageMockRecorder) SetRecoveryCodes(ctx, userID, codes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRecoveryCodes", reflect.TypeOf((*MockStorage)(nil).SetRecoveryCodes), ctx, userID, codes)
+}
+
+// UseRecoveryCode mocks base method
+func (m *MockStorage) UseRecoveryCode(ctx context.Context, userID, code string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, userID, code)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode
+func (mr *MockStorageMockRecorder) UseRecoveryCode(ctx, userID, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockStorage)(nil).UseRecoveryCode), ctx, userID, code)
+}
+
+// UseTOTPStep mocks base method
+func (m *MockStorage) UseTOTPStep(ctx context.Context, userID string, step int64) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPStep", ctx, userID, step)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep
+func (mr *MockStorageMockRecorder) UseTOTPStep(ctx, userID, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockStorage)(nil).UseTOTPStep), ctx, userID, step)
+}
+
+// CreateToken mocks base method
+func (m *MockStorage) CreateToken(ctx context.Context, t model.Token) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateToken", ctx, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateToken indicates an expected call of CreateToken
+func (mr *MockStorageMockRecorder) CreateToken(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateToken", reflect.TypeOf((*MockStorage)(nil).CreateToken), ctx, t)
+}
+
+// ConsumeToken mocks base method
+func (m *MockStorage) ConsumeToken(ctx context.Context, id, purpose string) (model.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeToken", ctx, id, purpose)
+	ret0, _ := ret[0].(model.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeToken indicates an expected call of ConsumeToken
+func (mr *MockStorageMockRecorder) ConsumeToken(ctx, id, purpose interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeToken", reflect.TypeOf((*MockStorage)(nil).ConsumeToken), ctx, id, purpose)
+}
+
+// DeleteTokensByUser mocks base method
+func (m *MockStorage) DeleteTokensByUser(ctx context.Context, userID, purpose string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTokensByUser", ctx, userID, purpose)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTokensByUser indicates an expected call of DeleteTokensByUser
+func (mr *MockStorageMockRecorder) DeleteTokensByUser(ctx, userID, purpose interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTokensByUser", reflect.TypeOf((*MockStorage)(nil).DeleteTokensByUser), ctx, userID, purpose)
+}
+
+// DeleteExpiredTokens mocks base method
+func (m *MockStorage) DeleteExpiredTokens(ctx context.Context, now time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredTokens", ctx, now)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredTokens indicates an expected call of DeleteExpiredTokens
+func (mr *MockStorageMockRecorder) DeleteExpiredTokens(ctx, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredTokens", reflect.TypeOf((*MockStorage)(nil).DeleteExpiredTokens), ctx, now)
+}
+
+// CreateTenant mocks base method
+func (m *MockStorage) CreateTenant(ctx context.Context, t model.Tenant) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTenant", ctx, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateTenant indicates an expected call of CreateTenant
+func (mr *MockStorageMockRecorder) CreateTenant(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTenant", reflect.TypeOf((*MockStorage)(nil).CreateTenant), ctx, t)
+}
+
+// GetTenant mocks base method
+func (m *MockStorage) GetTenant(ctx context.Context, id string) (model.Tenant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTenant", ctx, id)
+	ret0, _ := ret[0].(model.Tenant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTenant indicates an expected call of GetTenant
+func (mr *MockStorageMockRecorder) GetTenant(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTenant", reflect.TypeOf((*MockStorage)(nil).GetTenant), ctx, id)
+}
+
+// GetAllTenants mocks base method
+func (m *MockStorage) GetAllTenants(ctx context.Context) []model.Tenant {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllTenants", ctx)
+	ret0, _ := ret[0].([]model.Tenant)
+	return ret0
+}
+
+// GetAllTenants indicates an expected call of GetAllTenants
+func (mr *MockStorageMockRecorder) GetAllTenants(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllTenants", reflect.TypeOf((*MockStorage)(nil).GetAllTenants), ctx)
+}
+
+// UpdateTenant mocks base method
+func (m *MockStorage) UpdateTenant(ctx context.Context, t model.Tenant) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateTenant", ctx, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateTenant indicates an expected call of UpdateTenant
+func (mr *MockStorageMockRecorder) UpdateTenant(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateTenant", reflect.TypeOf((*MockStorage)(nil).UpdateTenant), ctx, t)
+}
+
+// DeleteTenant mocks base method
+func (m *MockStorage) DeleteTenant(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTenant", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTenant indicates an expected call of DeleteTenant
+func (mr *MockStorageMockRecorder) DeleteTenant(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTenant", reflect.TypeOf((*MockStorage)(nil).DeleteTenant), ctx, id)
+}
+
+// CreateGroup mocks base method
+func (m *MockStorage) CreateGroup(ctx context.Context, g model.Group) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateGroup", ctx, g)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateGroup indicates an expected call of CreateGroup
+func (mr *MockStorageMockRecorder) CreateGroup(ctx, g interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateGroup", reflect.TypeOf((*MockStorage)(nil).CreateGroup), ctx, g)
+}
+
+// GetGroup mocks base method
+func (m *MockStorage) GetGroup(ctx context.Context, id string) (model.Group, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGroup", ctx, id)
+	ret0, _ := ret[0].(model.Group)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGroup indicates an expected call of GetGroup
+func (mr *MockStorageMockRecorder) GetGroup(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGroup", reflect.TypeOf((*MockStorage)(nil).GetGroup), ctx, id)
+}
+
+// GetGroups mocks base method
+func (m *MockStorage) GetGroups(ctx context.Context) []model.Group {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGroups", ctx)
+	ret0, _ := ret[0].([]model.Group)
+	return ret0
+}
+
+// GetGroups indicates an expected call of GetGroups
+func (mr *MockStorageMockRecorder) GetGroups(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGroups", reflect.TypeOf((*MockStorage)(nil).GetGroups), ctx)
+}
+
+// UpdateGroup mocks base method
+func (m *MockStorage) UpdateGroup(ctx context.Context, g model.Group) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateGroup", ctx, g)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateGroup indicates an expected call of UpdateGroup
+func (mr *MockStorageMockRecorder) UpdateGroup(ctx, g interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateGroup", reflect.TypeOf((*MockStorage)(nil).UpdateGroup), ctx, g)
+}
+
+// DeleteGroup mocks base method
+func (m *MockStorage) DeleteGroup(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteGroup", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteGroup indicates an expected call of DeleteGroup
+func (mr *MockStorageMockRecorder) DeleteGroup(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteGroup", reflect.TypeOf((*MockStorage)(nil).DeleteGroup), ctx, id)
+}
+
+// AddMember mocks base method
+func (m *MockStorage) AddMember(ctx context.Context, groupID, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddMember", ctx, groupID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddMember indicates an expected call of AddMember
+func (mr *MockStorageMockRecorder) AddMember(ctx, groupID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMember", reflect.TypeOf((*MockStorage)(nil).AddMember), ctx, groupID, userID)
+}
+
+// RemoveMember mocks base method
+func (m *MockStorage) RemoveMember(ctx context.Context, groupID, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveMember", ctx, groupID, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveMember indicates an expected call of RemoveMember
+func (mr *MockStorageMockRecorder) RemoveMember(ctx, groupID, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveMember", reflect.TypeOf((*MockStorage)(nil).RemoveMember), ctx, groupID, userID)
+}
+
+// GetMembers mocks base method
+func (m *MockStorage) GetMembers(ctx context.Context, id string) ([]model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMembers", ctx, id)
+	ret0, _ := ret[0].([]model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMembers indicates an expected call of GetMembers
+func (mr *MockStorageMockRecorder) GetMembers(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMembers", reflect.TypeOf((*MockStorage)(nil).GetMembers), ctx, id)
+}
+
+// GetGroupsByUser mocks base method
+func (m *MockStorage) GetGroupsByUser(ctx context.Context, userID string) []model.Group {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGroupsByUser", ctx, userID)
+	ret0, _ := ret[0].([]model.Group)
+	return ret0
+}
+
+// GetGroupsByUser indicates an expected call of GetGroupsByUser
+func (mr *MockStorageMockRecorder) GetGroupsByUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGroupsByUser", reflect.TypeOf((*MockStorage)(nil).GetGroupsByUser), ctx, userID)
+}
+
+// CreateAPIKey mocks base method
+func (m *MockStorage) CreateAPIKey(ctx context.Context, k model.APIKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", ctx, k)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey
+func (mr *MockStorageMockRecorder) CreateAPIKey(ctx, k interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockStorage)(nil).CreateAPIKey), ctx, k)
+}
+
+// GetAPIKey mocks base method
+func (m *MockStorage) GetAPIKey(ctx context.Context, id string) (model.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKey", ctx, id)
+	ret0, _ := ret[0].(model.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKey indicates an expected call of GetAPIKey
+func (mr *MockStorageMockRecorder) GetAPIKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKey", reflect.TypeOf((*MockStorage)(nil).GetAPIKey), ctx, id)
+}
+
+// GetAPIKeysByUser mocks base method
+func (m *MockStorage) GetAPIKeysByUser(ctx context.Context, userID string) []model.APIKey {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKeysByUser", ctx, userID)
+	ret0, _ := ret[0].([]model.APIKey)
+	return ret0
+}
+
+// GetAPIKeysByUser indicates an expected call of GetAPIKeysByUser
+func (mr *MockStorageMockRecorder) GetAPIKeysByUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKeysByUser", reflect.TypeOf((*MockStorage)(nil).GetAPIKeysByUser), ctx, userID)
+}
+
+// TouchAPIKey mocks base method
+func (m *MockStorage) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchAPIKey", ctx, id, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchAPIKey indicates an expected call of TouchAPIKey
+func (mr *MockStorageMockRecorder) TouchAPIKey(ctx, id, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchAPIKey", reflect.TypeOf((*MockStorage)(nil).TouchAPIKey), ctx, id, at)
+}
+
+// DeleteAPIKey mocks base method
+func (m *MockStorage) DeleteAPIKey(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAPIKey", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAPIKey indicates an expected call of DeleteAPIKey
+func (mr *MockStorageMockRecorder) DeleteAPIKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAPIKey", reflect.TypeOf((*MockStorage)(nil).DeleteAPIKey), ctx, id)
+}
+
+// CreateOAuthClient mocks base method
+func (m *MockStorage) CreateOAuthClient(ctx context.Context, c model.OAuthClient) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOAuthClient", ctx, c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOAuthClient indicates an expected call of CreateOAuthClient
+func (mr *MockStorageMockRecorder) CreateOAuthClient(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOAuthClient", reflect.TypeOf((*MockStorage)(nil).CreateOAuthClient), ctx, c)
+}
+
+// GetOAuthClient mocks base method
+func (m *MockStorage) GetOAuthClient(ctx context.Context, id string) (model.OAuthClient, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOAuthClient", ctx, id)
+	ret0, _ := ret[0].(model.OAuthClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOAuthClient indicates an expected call of GetOAuthClient
+func (mr *MockStorageMockRecorder) GetOAuthClient(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOAuthClient", reflect.TypeOf((*MockStorage)(nil).GetOAuthClient), ctx, id)
+}
+
+// GetOAuthClients mocks base method
+func (m *MockStorage) GetOAuthClients(ctx context.Context) []model.OAuthClient {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOAuthClients", ctx)
+	ret0, _ := ret[0].([]model.OAuthClient)
+	return ret0
+}
+
+// GetOAuthClients indicates an expected call of GetOAuthClients
+func (mr *MockStorageMockRecorder) GetOAuthClients(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOAuthClients", reflect.TypeOf((*MockStorage)(nil).GetOAuthClients), ctx)
+}
+
+// UpdateOAuthClient mocks base method
+func (m *MockStorage) UpdateOAuthClient(ctx context.Context, c model.OAuthClient) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOAuthClient", ctx, c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateOAuthClient indicates an expected call of UpdateOAuthClient
+func (mr *MockStorageMockRecorder) UpdateOAuthClient(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOAuthClient", reflect.TypeOf((*MockStorage)(nil).UpdateOAuthClient), ctx, c)
+}
+
+// DeleteOAuthClient mocks base method
+func (m *MockStorage) DeleteOAuthClient(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOAuthClient", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOAuthClient indicates an expected call of DeleteOAuthClient
+func (mr *MockStorageMockRecorder) DeleteOAuthClient(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOAuthClient", reflect.TypeOf((*MockStorage)(nil).DeleteOAuthClient), ctx, id)
+}
+
+// SaveConsent mocks base method
+func (m *MockStorage) SaveConsent(ctx context.Context, c model.OAuthConsent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveConsent", ctx, c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveConsent indicates an expected call of SaveConsent
+func (mr *MockStorageMockRecorder) SaveConsent(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveConsent", reflect.TypeOf((*MockStorage)(nil).SaveConsent), ctx, c)
+}
+
+// GetConsent mocks base method
+func (m *MockStorage) GetConsent(ctx context.Context, userID, clientID string) (model.OAuthConsent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetConsent", ctx, userID, clientID)
+	ret0, _ := ret[0].(model.OAuthConsent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetConsent indicates an expected call of GetConsent
+func (mr *MockStorageMockRecorder) GetConsent(ctx, userID, clientID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConsent", reflect.TypeOf((*MockStorage)(nil).GetConsent), ctx, userID, clientID)
+}
+
+// GetConsentsByUser mocks base method
+func (m *MockStorage) GetConsentsByUser(ctx context.Context, userID string) []model.OAuthConsent {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetConsentsByUser", ctx, userID)
+	ret0, _ := ret[0].([]model.OAuthConsent)
+	return ret0
+}
+
+// GetConsentsByUser indicates an expected call of GetConsentsByUser
+func (mr *MockStorageMockRecorder) GetConsentsByUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetConsentsByUser", reflect.TypeOf((*MockStorage)(nil).GetConsentsByUser), ctx, userID)
+}
+
+// DeleteConsent mocks base method
+func (m *MockStorage) DeleteConsent(ctx context.Context, userID, clientID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteConsent", ctx, userID, clientID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteConsent indicates an expected call of DeleteConsent
+func (mr *MockStorageMockRecorder) DeleteConsent(ctx, userID, clientID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteConsent", reflect.TypeOf((*MockStorage)(nil).DeleteConsent), ctx, userID, clientID)
+}
+
+// CreateOAuthToken mocks base method
+func (m *MockStorage) CreateOAuthToken(ctx context.Context, t model.OAuthToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOAuthToken", ctx, t)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOAuthToken indicates an expected call of CreateOAuthToken
+func (mr *MockStorageMockRecorder) CreateOAuthToken(ctx, t interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOAuthToken", reflect.TypeOf((*MockStorage)(nil).CreateOAuthToken), ctx, t)
+}
+
+// GetOAuthToken mocks base method
+func (m *MockStorage) GetOAuthToken(ctx context.Context, id string) (model.OAuthToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOAuthToken", ctx, id)
+	ret0, _ := ret[0].(model.OAuthToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOAuthToken indicates an expected call of GetOAuthToken
+func (mr *MockStorageMockRecorder) GetOAuthToken(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOAuthToken", reflect.TypeOf((*MockStorage)(nil).GetOAuthToken), ctx, id)
+}
+
+// ConsumeOAuthToken mocks base method
+func (m *MockStorage) ConsumeOAuthToken(ctx context.Context, id, kind string) (model.OAuthToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeOAuthToken", ctx, id, kind)
+	ret0, _ := ret[0].(model.OAuthToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeOAuthToken indicates an expected call of ConsumeOAuthToken
+func (mr *MockStorageMockRecorder) ConsumeOAuthToken(ctx, id, kind interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeOAuthToken", reflect.TypeOf((*MockStorage)(nil).ConsumeOAuthToken), ctx, id, kind)
+}
+
+// DeleteOAuthToken mocks base method
+func (m *MockStorage) DeleteOAuthToken(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOAuthToken", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOAuthToken indicates an expected call of DeleteOAuthToken
+func (mr *MockStorageMockRecorder) DeleteOAuthToken(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOAuthToken", reflect.TypeOf((*MockStorage)(nil).DeleteOAuthToken), ctx, id)
+}
+
+// DeleteOAuthGrant mocks base method
+func (m *MockStorage) DeleteOAuthGrant(ctx context.Context, grantID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOAuthGrant", ctx, grantID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOAuthGrant indicates an expected call of DeleteOAuthGrant
+func (mr *MockStorageMockRecorder) DeleteOAuthGrant(ctx, grantID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOAuthGrant", reflect.TypeOf((*MockStorage)(nil).DeleteOAuthGrant), ctx, grantID)
+}
+
+// DeleteUserOAuthGrants mocks base method
+func (m *MockStorage) DeleteUserOAuthGrants(ctx context.Context, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserOAuthGrants", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserOAuthGrants indicates an expected call of DeleteUserOAuthGrants
+func (mr *MockStorageMockRecorder) DeleteUserOAuthGrants(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserOAuthGrants", reflect.TypeOf((*MockStorage)(nil).DeleteUserOAuthGrants), ctx, userID)
+}
+
+// DeleteExpiredOAuthTokens mocks base method
+func (m *MockStorage) DeleteExpiredOAuthTokens(ctx context.Context, now time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredOAuthTokens", ctx, now)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredOAuthTokens indicates an expected call of DeleteExpiredOAuthTokens
+func (mr *MockStorageMockRecorder) DeleteExpiredOAuthTokens(ctx, now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredOAuthTokens", reflect.TypeOf((*MockStorage)(nil).DeleteExpiredOAuthTokens), ctx, now)
+}
+
+// CreateSigningKey mocks base method
+func (m *MockStorage) CreateSigningKey(ctx context.Context, k model.SigningKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSigningKey", ctx, k)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSigningKey indicates an expected call of CreateSigningKey
+func (mr *MockStorageMockRecorder) CreateSigningKey(ctx, k interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSigningKey", reflect.TypeOf((*MockStorage)(nil).CreateSigningKey), ctx, k)
+}
+
+// GetSigningKeys mocks base method
+func (m *MockStorage) GetSigningKeys(ctx context.Context) []model.SigningKey {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSigningKeys", ctx)
+	ret0, _ := ret[0].([]model.SigningKey)
+	return ret0
+}
+
+// GetSigningKeys indicates an expected call of GetSigningKeys
+func (mr *MockStorageMockRecorder) GetSigningKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSigningKeys", reflect.TypeOf((*MockStorage)(nil).GetSigningKeys), ctx)
+}
+
+// RetireSigningKey mocks base method
+func (m *MockStorage) RetireSigningKey(ctx context.Context, id string, at time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetireSigningKey", ctx, id, at)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetireSigningKey indicates an expected call of RetireSigningKey
+func (mr *MockStorageMockRecorder) RetireSigningKey(ctx, id, at interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetireSigningKey", reflect.TypeOf((*MockStorage)(nil).RetireSigningKey), ctx, id, at)
+}
+
+// DeleteSigningKey mocks base method
+func (m *MockStorage) DeleteSigningKey(ctx context.Context, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSigningKey", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSigningKey indicates an expected call of DeleteSigningKey
+func (mr *MockStorageMockRecorder) DeleteSigningKey(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSigningKey", reflect.TypeOf((*MockStorage)(nil).DeleteSigningKey), ctx, id)
+}
+
+// CreateIdentity mocks base method
+func (m *MockStorage) CreateIdentity(ctx context.Context, i model.Identity) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIdentity", ctx, i)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateIdentity indicates an expected call of CreateIdentity
+func (mr *MockStorageMockRecorder) CreateIdentity(ctx, i interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIdentity", reflect.TypeOf((*MockStorage)(nil).CreateIdentity), ctx, i)
+}
+
+// GetIdentity mocks base method
+func (m *MockStorage) GetIdentity(ctx context.Context, connector, subject string) (model.Identity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdentity", ctx, connector, subject)
+	ret0, _ := ret[0].(model.Identity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdentity indicates an expected call of GetIdentity
+func (mr *MockStorageMockRecorder) GetIdentity(ctx, connector, subject interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdentity", reflect.TypeOf((*MockStorage)(nil).GetIdentity), ctx, connector, subject)
+}
+
+// GetIdentitiesByUser mocks base method
+func (m *MockStorage) GetIdentitiesByUser(ctx context.Context, userID string) []model.Identity {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdentitiesByUser", ctx, userID)
+	ret0, _ := ret[0].([]model.Identity)
+	return ret0
+}
+
+// GetIdentitiesByUser indicates an expected call of GetIdentitiesByUser
+func (mr *MockStorageMockRecorder) GetIdentitiesByUser(ctx, userID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdentitiesByUser", reflect.TypeOf((*MockStorage)(nil).GetIdentitiesByUser), ctx, userID)
+}
+
+// UpdateIdentity mocks base method
+func (m *MockStorage) UpdateIdentity(ctx context.Context, i model.Identity) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateIdentity", ctx, i)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateIdentity indicates an expected call of UpdateIdentity
+func (mr *MockStorageMockRecorder) UpdateIdentity(ctx, i interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateIdentity", reflect.TypeOf((*MockStorage)(nil).UpdateIdentity), ctx, i)
+}
+
+// DeleteIdentity mocks base method
+func (m *MockStorage) DeleteIdentity(ctx context.Context, connector, subject string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdentity", ctx, connector, subject)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIdentity indicates an expected call of DeleteIdentity
+func (mr *MockStorageMockRecorder) DeleteIdentity(ctx, connector, subject interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdentity", reflect.TypeOf((*MockStorage)(nil).DeleteIdentity), ctx, connector, subject)
+}
+
+// CreateIdempotencyRecord mocks base method
+func (m *MockStorage) CreateIdempotencyRecord(ctx context.Context, r model.IdempotencyRecord) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIdempotencyRecord", ctx, r)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateIdempotencyRecord indicates an expected call of CreateIdempotencyRecord
+func (mr *MockStorageMockRecorder) CreateIdempotencyRecord(ctx, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIdempotencyRecord", reflect.TypeOf((*MockStorage)(nil).CreateIdempotencyRecord), ctx, r)
+}
+
+// GetIdempotencyRecord mocks base method
+func (m *MockStorage) GetIdempotencyRecord(ctx context.Context, key string) (model.IdempotencyRecord, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdempotencyRecord", ctx, key)
+	ret0, _ := ret[0].(model.IdempotencyRecord)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdempotencyRecord indicates an expected call of GetIdempotencyRecord
+func (mr *MockStorageMockRecorder) GetIdempotencyRecord(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdempotencyRecord", reflect.TypeOf((*MockStorage)(nil).GetIdempotencyRecord), ctx, key)
+}
+
+// UpdateIdempotencyRecord mocks base method
+func (m *MockStorage) UpdateIdempotencyRecord(ctx context.Context, r model.IdempotencyRecord) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateIdempotencyRecord", ctx, r)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateIdempotencyRecord indicates an expected call of UpdateIdempotencyRecord
+func (mr *MockStorageMockRecorder) UpdateIdempotencyRecord(ctx, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateIdempotencyRecord", reflect.TypeOf((*MockStorage)(nil).UpdateIdempotencyRecord), ctx, r)
+}
+
+// DeleteIdempotencyRecord mocks base method
+func (m *MockStorage) DeleteIdempotencyRecord(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdempotencyRecord", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIdempotencyRecord indicates an expected call of DeleteIdempotencyRecord
+func (mr *MockStorageMockRecorder) DeleteIdempotencyRecord(ctx, key interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsAuthorized", reflect.TypeOf((*MockRepository)(nil).IsAuthorized), username, password)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdempotencyRecord", reflect.TypeOf((*MockStorage)(nil).DeleteIdempotencyRecord), ctx, key)
 }
 
-// UpdateUser mocks base method.
-func (m *MockRepository) UpdateUser(u model.User) error {
+// DeleteExpiredIdempotencyRecords mocks base method
+func (m *MockStorage) DeleteExpiredIdempotencyRecords(ctx context.Context, now time.Time) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateUser", u)
+	ret := m.ctrl.Call(m, "DeleteExpiredIdempotencyRecords", ctx, now)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// UpdateUser indicates an expected call of UpdateUser.
-func (mr *MockRepositoryMockRecorder) UpdateUser(u interface{}) *gomock.Call {
+// DeleteExpiredIdempotencyRecords indicates an expected call of DeleteExpiredIdempotencyRecords
+func (mr *MockStorageMockRecorder) DeleteExpiredIdempotencyRecords(ctx, now interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockRepository)(nil).UpdateUser), u)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredIdempotencyRecords", reflect.TypeOf((*MockStorage)(nil).DeleteExpiredIdempotencyRecords), ctx, now)
 }
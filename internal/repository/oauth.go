@@ -0,0 +1,351 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/tenant"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrClientExists       = errors.New("oauth client exists")
+	ErrClientNotFound     = errors.New("oauth client not found")
+	ErrConsentNotFound    = errors.New("consent not found")
+	ErrOAuthTokenNotFound = errors.New("oauth token not found")
+)
+
+func (db *DB) CreateOAuthClient(_ context.Context, c model.OAuthClient) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.tenants[c.Tenant]; c.Tenant != tenant.Default && !ok {
+		return ErrTenantNotFound
+	}
+
+	if _, ok := db.clients[c.ID]; ok {
+		return ErrClientExists
+	}
+
+	db.clients[c.ID] = c
+
+	return nil
+}
+
+// GetOAuthClient returns client id of any tenant.
+func (db *DB) GetOAuthClient(_ context.Context, id string) (model.OAuthClient, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	c, ok := db.clients[id]
+	if !ok {
+		return model.OAuthClient{}, ErrClientNotFound
+	}
+
+	return c, nil
+}
+
+// GetOAuthClients returns the clients of the tenant of ctx ordered by name.
+func (db *DB) GetOAuthClients(ctx context.Context) []model.OAuthClient {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	id := tenant.FromContext(ctx)
+
+	clients := make([]model.OAuthClient, 0)
+	for _, c := range db.clients {
+		if c.Tenant == id {
+			clients = append(clients, c)
+		}
+	}
+
+	sortClients(clients)
+
+	return clients
+}
+
+// UpdateOAuthClient replaces client c, keeping its tenant.
+func (db *DB) UpdateOAuthClient(_ context.Context, c model.OAuthClient) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	old, ok := db.clients[c.ID]
+	if !ok {
+		return ErrClientNotFound
+	}
+
+	c.Tenant = old.Tenant
+	db.clients[c.ID] = c
+
+	return nil
+}
+
+// DeleteOAuthClient deletes client id together with its consents and tokens.
+func (db *DB) DeleteOAuthClient(_ context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.clients[id]; !ok {
+		return ErrClientNotFound
+	}
+
+	db.deleteClient(id)
+
+	return nil
+}
+
+// deleteClient removes client id and what it was granted, the caller holds
+// the lock.
+func (db *DB) deleteClient(id string) {
+	delete(db.clients, id)
+
+	for key, c := range db.consents {
+		if c.ClientID == id {
+			delete(db.consents, key)
+		}
+	}
+
+	for tid, t := range db.oauth {
+		if t.ClientID == id {
+			delete(db.oauth, tid)
+		}
+	}
+}
+
+// SaveConsent stores the consent of a user to a client, replacing an earlier
+// one.
+func (db *DB) SaveConsent(_ context.Context, c model.OAuthConsent) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.store[c.UserID]; !ok {
+		return ErrUserNotFound
+	}
+	if _, ok := db.clients[c.ClientID]; !ok {
+		return ErrClientNotFound
+	}
+
+	db.consents[consentKey(c.UserID, c.ClientID)] = c
+
+	return nil
+}
+
+func (db *DB) GetConsent(_ context.Context, userID, clientID string) (model.OAuthConsent, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	c, ok := db.consents[consentKey(userID, clientID)]
+	if !ok {
+		return model.OAuthConsent{}, ErrConsentNotFound
+	}
+
+	return c, nil
+}
+
+// GetConsentsByUser returns the consents of userID, newest first.
+func (db *DB) GetConsentsByUser(_ context.Context, userID string) []model.OAuthConsent {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	consents := make([]model.OAuthConsent, 0)
+	for _, c := range db.consents {
+		if c.UserID == userID {
+			consents = append(consents, c)
+		}
+	}
+
+	sortConsents(consents)
+
+	return consents
+}
+
+// DeleteConsent withdraws the consent of userID to clientID and revokes the
+// tokens the client holds for the user.
+func (db *DB) DeleteConsent(_ context.Context, userID, clientID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	key := consentKey(userID, clientID)
+	if _, ok := db.consents[key]; !ok {
+		return ErrConsentNotFound
+	}
+
+	delete(db.consents, key)
+
+	for tid, t := range db.oauth {
+		if t.UserID == userID && t.ClientID == clientID {
+			delete(db.oauth, tid)
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) CreateOAuthToken(_ context.Context, t model.OAuthToken) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.oauth[t.ID] = t
+
+	return nil
+}
+
+func (db *DB) GetOAuthToken(_ context.Context, id string) (model.OAuthToken, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	t, ok := db.oauth[id]
+	if !ok {
+		return model.OAuthToken{}, ErrOAuthTokenNotFound
+	}
+
+	return t, nil
+}
+
+// ConsumeOAuthToken deletes token id of kind and returns it, so it can only
+// be used once.
+func (db *DB) ConsumeOAuthToken(_ context.Context, id, kind string) (model.OAuthToken, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, ok := db.oauth[id]
+	if !ok || t.Kind != kind {
+		return model.OAuthToken{}, ErrOAuthTokenNotFound
+	}
+
+	delete(db.oauth, id)
+
+	return t, nil
+}
+
+// DeleteOAuthToken deletes token id.
+func (db *DB) DeleteOAuthToken(_ context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.oauth[id]; !ok {
+		return ErrOAuthTokenNotFound
+	}
+
+	delete(db.oauth, id)
+
+	return nil
+}
+
+// DeleteOAuthGrant deletes every token issued under grantID.
+func (db *DB) DeleteOAuthGrant(_ context.Context, grantID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for tid, t := range db.oauth {
+		if t.GrantID == grantID {
+			delete(db.oauth, tid)
+		}
+	}
+
+	return nil
+}
+
+// DeleteUserOAuthGrants deletes the consents and tokens of userID.
+func (db *DB) DeleteUserOAuthGrants(_ context.Context, userID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.deleteUserGrants(userID)
+
+	return nil
+}
+
+func (db *DB) DeleteExpiredOAuthTokens(_ context.Context, now time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for tid, t := range db.oauth {
+		if !now.Before(t.ExpiresAt) {
+			delete(db.oauth, tid)
+		}
+	}
+
+	return nil
+}
+
+// deleteUserGrants removes the consents and tokens of userID, the caller
+// holds the lock.
+func (db *DB) deleteUserGrants(userID string) {
+	for key, c := range db.consents {
+		if c.UserID == userID {
+			delete(db.consents, key)
+		}
+	}
+
+	for tid, t := range db.oauth {
+		if t.UserID == userID {
+			delete(db.oauth, tid)
+		}
+	}
+}
+
+func (db *DB) allClients() []model.OAuthClient {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	clients := make([]model.OAuthClient, 0, len(db.clients))
+	for _, c := range db.clients {
+		clients = append(clients, c)
+	}
+
+	sortClients(clients)
+
+	return clients
+}
+
+func (db *DB) allConsents() []model.OAuthConsent {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	consents := make([]model.OAuthConsent, 0, len(db.consents))
+	for _, c := range db.consents {
+		consents = append(consents, c)
+	}
+
+	sortConsents(consents)
+
+	return consents
+}
+
+func (db *DB) allOAuthTokens() []model.OAuthToken {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	tokens := make([]model.OAuthToken, 0, len(db.oauth))
+	for _, t := range db.oauth {
+		tokens = append(tokens, t)
+	}
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+
+	return tokens
+}
+
+func consentKey(userID, clientID string) string {
+	return userID + "\x00" + clientID
+}
+
+func sortClients(clients []model.OAuthClient) {
+	sort.Slice(clients, func(i, j int) bool {
+		if clients[i].Name != clients[j].Name {
+			return clients[i].Name < clients[j].Name
+		}
+		return clients[i].ID < clients[j].ID
+	})
+}
+
+func sortConsents(consents []model.OAuthConsent) {
+	sort.Slice(consents, func(i, j int) bool {
+		if !consents[i].GrantedAt.Equal(consents[j].GrantedAt) {
+			return consents[i].GrantedAt.After(consents[j].GrantedAt)
+		}
+		return consentKey(consents[i].UserID, consents[i].ClientID) < consentKey(consents[j].UserID, consents[j].ClientID)
+	})
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDB_OAuth(t *testing.T) {
+	db := New(WithHashParams(HashParams{Time: 1, Memory: 64, Threads: 1, KeyLen: 16, SaltLen: 8}))
+	ctx := context.Background()
+	acme := tenant.WithID(ctx, "acme")
+
+	require.NoError(t, db.CreateTenant(ctx, model.Tenant{ID: "acme"}))
+	_, err := db.CreateUser(ctx, model.User{Username: "bob", Password: "p"})
+	require.NoError(t, err)
+	bob, err := db.GetUserByName(ctx, "bob")
+	require.NoError(t, err)
+
+	require.NoError(t, db.CreateOAuthClient(ctx, model.OAuthClient{ID: "wiki", Name: "wiki"}))
+	require.NoError(t, db.CreateOAuthClient(ctx, model.OAuthClient{ID: "crm", Name: "crm", Tenant: "acme"}))
+	assert.Equal(t, ErrClientExists, db.CreateOAuthClient(ctx, model.OAuthClient{ID: "wiki"}))
+	assert.Equal(t, ErrTenantNotFound, db.CreateOAuthClient(ctx, model.OAuthClient{ID: "x", Tenant: "nope"}))
+	assert.Len(t, db.GetOAuthClients(ctx), 1)
+	assert.Len(t, db.GetOAuthClients(acme), 1)
+
+	require.NoError(t, db.UpdateOAuthClient(ctx, model.OAuthClient{ID: "crm", Name: "CRM"}))
+	crm, err := db.GetOAuthClient(ctx, "crm")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", crm.Tenant, "clients keep their tenant")
+
+	require.NoError(t, db.SaveConsent(ctx, model.OAuthConsent{UserID: bob.ID, ClientID: "wiki", Scopes: []string{"read"}}))
+	assert.Equal(t, ErrClientNotFound, db.SaveConsent(ctx, model.OAuthConsent{UserID: bob.ID, ClientID: "nope"}))
+	consent, err := db.GetConsent(ctx, bob.ID, "wiki")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read"}, consent.Scopes)
+	assert.Len(t, db.GetConsentsByUser(ctx, bob.ID), 1)
+
+	now := time.Now()
+	require.NoError(t, db.CreateOAuthToken(ctx, model.OAuthToken{ID: "code", Kind: model.OAuthCode, ClientID: "wiki",
+		UserID: bob.ID, GrantID: "g1", ExpiresAt: now.Add(time.Minute)}))
+	require.NoError(t, db.CreateOAuthToken(ctx, model.OAuthToken{ID: "access", Kind: model.OAuthAccess, ClientID: "wiki",
+		UserID: bob.ID, GrantID: "g1", ExpiresAt: now.Add(time.Hour)}))
+	require.NoError(t, db.CreateOAuthToken(ctx, model.OAuthToken{ID: "old", Kind: model.OAuthAccess, ClientID: "wiki",
+		GrantID: "g2", ExpiresAt: now.Add(-time.Second)}))
+
+	_, err = db.ConsumeOAuthToken(ctx, "code", model.OAuthAccess)
+	assert.Equal(t, ErrOAuthTokenNotFound, err, "kinds don't mix")
+	_, err = db.ConsumeOAuthToken(ctx, "code", model.OAuthCode)
+	require.NoError(t, err)
+	_, err = db.ConsumeOAuthToken(ctx, "code", model.OAuthCode)
+	assert.Equal(t, ErrOAuthTokenNotFound, err, "codes are single-use")
+
+	require.NoError(t, db.DeleteExpiredOAuthTokens(ctx, now))
+	_, err = db.GetOAuthToken(ctx, "old")
+	assert.Equal(t, ErrOAuthTokenNotFound, err)
+
+	require.NoError(t, db.DeleteConsent(ctx, bob.ID, "wiki"))
+	_, err = db.GetOAuthToken(ctx, "access")
+	assert.Equal(t, ErrOAuthTokenNotFound, err, "withdrawing consent revokes the tokens")
+	assert.Equal(t, ErrConsentNotFound, db.DeleteConsent(ctx, bob.ID, "wiki"))
+
+	require.NoError(t, db.SaveConsent(ctx, model.OAuthConsent{UserID: bob.ID, ClientID: "wiki"}))
+	require.NoError(t, db.DeleteUser(ctx, bob.ID))
+	_, err = db.GetConsent(ctx, bob.ID, "wiki")
+	assert.Equal(t, ErrConsentNotFound, err, "consents go with their user")
+
+	require.NoError(t, db.DeleteTenant(ctx, "acme"))
+	_, err = db.GetOAuthClient(ctx, "crm")
+	assert.Equal(t, ErrClientNotFound, err, "clients go with their tenant")
+
+	require.NoError(t, db.DeleteOAuthClient(ctx, "wiki"))
+	assert.Equal(t, ErrClientNotFound, db.DeleteOAuthClient(ctx, "wiki"))
+}
+
+func TestFileDB_OAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	ctx := context.Background()
+
+	f, err := NewFile(path)
+	require.NoError(t, err)
+	_, err = f.CreateUser(ctx, model.User{Username: "bob", Password: "p", Admin: true})
+	require.NoError(t, err)
+	bob, err := f.GetUserByName(ctx, "bob")
+	require.NoError(t, err)
+	require.NoError(t, f.CreateOAuthClient(ctx, model.OAuthClient{ID: "wiki", Name: "wiki", RedirectURIs: []string{"https://wiki/cb"}}))
+	require.NoError(t, f.SaveConsent(ctx, model.OAuthConsent{UserID: bob.ID, ClientID: "wiki", Scopes: []string{"read"}}))
+	require.NoError(t, f.CreateOAuthToken(ctx, model.OAuthToken{ID: "a", Kind: model.OAuthRefresh, ClientID: "wiki", UserID: bob.ID,
+		GrantID: "g", ExpiresAt: time.Now().Add(time.Hour)}))
+
+	f, err = NewFile(path)
+	require.NoError(t, err)
+
+	c, err := f.GetOAuthClient(ctx, "wiki")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://wiki/cb"}, c.RedirectURIs)
+	_, err = f.GetConsent(ctx, bob.ID, "wiki")
+	assert.NoError(t, err)
+
+	_, err = f.ConsumeOAuthToken(ctx, "a", model.OAuthRefresh)
+	require.NoError(t, err)
+	assert.Empty(t, f.Verify(ctx))
+
+	f, err = NewFile(path)
+	require.NoError(t, err)
+	_, err = f.GetOAuthToken(ctx, "a")
+	assert.Equal(t, ErrOAuthTokenNotFound, err, "consumed tokens stay consumed")
+}
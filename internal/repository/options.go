@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"dev/profileSaver/internal/config"
+	"fmt"
+)
+
+// HashParams are the argon2id parameters used for password hashes.
+type HashParams struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+var DefaultHashParams = HashParams{
+	Time:    1,
+	Memory:  64 * 1024,
+	Threads: 4,
+	KeyLen:  32,
+	SaltLen: 8,
+}
+
+type Option func(db *DB)
+
+func WithHashParams(p HashParams) Option {
+	return func(db *DB) {
+		db.hash = p
+	}
+}
+
+// HashParamsFromConfig converts the hashing section of the config.
+func HashParamsFromConfig(cfg config.Hashing) HashParams {
+	return HashParams{
+		Time:    cfg.Time,
+		Memory:  cfg.Memory,
+		Threads: cfg.Threads,
+		KeyLen:  cfg.KeyLen,
+		SaltLen: cfg.SaltLen,
+	}
+}
+
+// Open returns the Storage for the configured storage backend.
+func Open(cfg config.Storage, opts ...Option) (Storage, error) {
+	switch cfg.Backend {
+	case config.StorageMemory:
+		return New(opts...), nil
+	case config.StorageFile:
+		db, err := NewFile(cfg.DSN, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return db, nil
+	default:
+		return nil, fmt.Errorf("unsupported storage backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"dev/profileSaver/internal/model"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
+)
+
+// hashPrefix starts the PHC strings of argon2id hashes.
+const hashPrefix = "$argon2id$"
+
+// encodeHash hashes secret with the parameters of db into a PHC string,
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<hash>, which keeps
+// the parameters with the hash so they can change once hashes are stored.
+func (db *DB) encodeHash(ctx context.Context, secret string) string {
+	salt := make([]byte, db.hash.SaltLen)
+	rand.Read(salt)
+
+	hash := hashPass(ctx, []byte(secret), salt, db.hash)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", hashPrefix, argon2.Version,
+		db.hash.Memory, db.hash.Time, db.hash.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash))
+}
+
+// verifyHash reports whether secret matches encoded, hashing with the
+// parameters stored in it, and whether encoded should be replaced because
+// the parameters of db changed since. Hashes stored before the parameters
+// were kept with them are hex with a separate salt and are checked with the
+// parameters of db.
+func (db *DB) verifyHash(ctx context.Context, secret, encoded string, salt []byte) (ok, stale bool) {
+	if !strings.HasPrefix(encoded, hashPrefix) {
+		hash := hashPass(ctx, []byte(secret), salt, db.hash)
+
+		return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(hash)), []byte(encoded)) == 1, true
+	}
+
+	p, salt, want, err := decodeHash(encoded)
+	if err != nil {
+		return false, false
+	}
+
+	hash := hashPass(ctx, []byte(secret), salt, p)
+
+	return subtle.ConstantTimeCompare(hash, want) == 1, p != db.hash
+}
+
+// decodeHash parses a PHC string made by encodeHash.
+func decodeHash(encoded string) (HashParams, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return HashParams{}, nil, nil, fmt.Errorf("not an argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return HashParams{}, nil, nil, fmt.Errorf("unsupported argon2 version %q", parts[2])
+	}
+
+	var p HashParams
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+		return HashParams{}, nil, nil, fmt.Errorf("argon2 parameters %q: %w", parts[3], err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return HashParams{}, nil, nil, fmt.Errorf("argon2 salt: %w", err)
+	}
+
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(hash) == 0 {
+		return HashParams{}, nil, nil, fmt.Errorf("argon2 hash is invalid")
+	}
+
+	p.SaltLen = uint32(len(salt))
+	p.KeyLen = uint32(len(hash))
+
+	return p, salt, hash, nil
+}
+
+// IsHashed reports whether u holds a password hash, as exported users do,
+// rather than a plain text password.
+func IsHashed(u model.User) bool {
+	return strings.HasPrefix(u.Password, hashPrefix) || len(u.Salt) != 0
+}
+
+func hashPass(ctx context.Context, password, salt []byte, p HashParams) []byte {
+	_, span := tracer.Start(ctx, "repository.hashPass")
+	defer span.End()
+
+	return argon2.IDKey(password, salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+}
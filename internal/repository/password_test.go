@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileDB_HashParams(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	ctx := context.Background()
+	before := HashParams{Time: 1, Memory: 64, Threads: 1, KeyLen: 16, SaltLen: 8}
+	after := HashParams{Time: 2, Memory: 128, Threads: 1, KeyLen: 32, SaltLen: 16}
+
+	f, err := NewFile(path, WithHashParams(before))
+	require.NoError(t, err)
+	bob, err := f.CreateUser(ctx, model.User{Username: "bob", Password: "bob-password", Admin: true})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(bob.Password, "$argon2id$v=19$m=64,t=1,p=1$"), bob.Password)
+	assert.True(t, IsHashed(bob))
+
+	t.Run("CHANGED", func(t *testing.T) {
+		f, err := NewFile(path, WithHashParams(after))
+		require.NoError(t, err)
+		assert.False(t, f.IsAuthorized(ctx, "bob", "wrong"))
+		assert.True(t, f.IsAuthorized(ctx, "bob", "bob-password"), "hashes keep their parameters")
+
+		f, err = NewFile(path, WithHashParams(after))
+		require.NoError(t, err)
+		stored, err := f.GetUserByName(ctx, "bob")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(stored.Password, "$argon2id$v=19$m=128,t=2,p=1$"), "rehashed on login")
+		assert.True(t, f.IsAuthorized(ctx, "bob", "bob-password"))
+		assert.Empty(t, f.Verify(ctx))
+	})
+
+	t.Run("LEGACY", func(t *testing.T) {
+		f, err := NewFile(path, WithHashParams(before))
+		require.NoError(t, err)
+		salt := []byte("saltsalt")
+		bob.Password = hex.EncodeToString(hashPass(ctx, []byte("old-password"), salt, before))
+		bob.Salt = salt
+		require.NoError(t, f.RestoreUser(ctx, bob))
+		assert.Empty(t, f.Verify(ctx))
+
+		assert.True(t, f.IsAuthorized(ctx, "bob", "old-password"), "hex hashes use the current parameters")
+
+		stored, err := f.GetUserByName(ctx, "bob")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(stored.Password, "$argon2id$v=19$m=64,t=1,p=1$"))
+		assert.Empty(t, stored.Salt)
+		assert.True(t, f.IsAuthorized(ctx, "bob", "old-password"))
+	})
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"errors"
+	"sort"
+	"time"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+func (db *DB) CreateSession(_ context.Context, s model.Session) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.sessions[s.ID] = s
+
+	return nil
+}
+
+func (db *DB) GetSession(_ context.Context, id string) (model.Session, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	s, ok := db.sessions[id]
+	if !ok {
+		return model.Session{}, ErrSessionNotFound
+	}
+
+	return s, nil
+}
+
+func (db *DB) GetSessionsByUser(_ context.Context, userID string) []model.Session {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var sessions []model.Session
+	for _, s := range db.sessions {
+		if s.UserID == userID {
+			sessions = append(sessions, s)
+		}
+	}
+
+	return sessions
+}
+
+func (db *DB) UpdateSession(_ context.Context, s model.Session) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.sessions[s.ID]; !ok {
+		return ErrSessionNotFound
+	}
+
+	db.sessions[s.ID] = s
+
+	return nil
+}
+
+func (db *DB) DeleteSession(_ context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.sessions[id]; !ok {
+		return ErrSessionNotFound
+	}
+
+	delete(db.sessions, id)
+
+	return nil
+}
+
+func (db *DB) DeleteSessionsByUser(_ context.Context, userID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for id, s := range db.sessions {
+		if s.UserID == userID {
+			delete(db.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) DeleteExpiredSessions(_ context.Context, now time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for id, s := range db.sessions {
+		if !now.Before(s.ExpiresAt) {
+			delete(db.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) allSessions() []model.Session {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	sessions := make([]model.Session, 0, len(db.sessions))
+	for _, s := range db.sessions {
+		sessions = append(sessions, s)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+
+	return sessions
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrSigningKeyExists   = errors.New("signing key exists")
+	ErrSigningKeyNotFound = errors.New("signing key not found")
+)
+
+func (db *DB) CreateSigningKey(_ context.Context, k model.SigningKey) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.signingKeys[k.ID]; ok {
+		return ErrSigningKeyExists
+	}
+
+	db.signingKeys[k.ID] = k
+
+	return nil
+}
+
+// GetSigningKeys returns all signing keys, newest first.
+func (db *DB) GetSigningKeys(_ context.Context) []model.SigningKey {
+	return db.allSigningKeys()
+}
+
+// RetireSigningKey marks key id as retired at at.
+func (db *DB) RetireSigningKey(_ context.Context, id string, at time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	k, ok := db.signingKeys[id]
+	if !ok {
+		return ErrSigningKeyNotFound
+	}
+
+	k.RetiredAt = at
+	db.signingKeys[id] = k
+
+	return nil
+}
+
+func (db *DB) DeleteSigningKey(_ context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.signingKeys[id]; !ok {
+		return ErrSigningKeyNotFound
+	}
+
+	delete(db.signingKeys, id)
+
+	return nil
+}
+
+func (db *DB) allSigningKeys() []model.SigningKey {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	keys := make([]model.SigningKey, 0, len(db.signingKeys))
+	for _, k := range db.signingKeys {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.After(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileDB_SigningKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	f, err := NewFile(path)
+	require.NoError(t, err)
+	require.NoError(t, f.CreateSigningKey(ctx, model.SigningKey{ID: "old", Algorithm: model.AlgRS256, PrivateKey: []byte{1}, CreatedAt: now.Add(-time.Hour)}))
+	require.NoError(t, f.CreateSigningKey(ctx, model.SigningKey{ID: "new", Algorithm: model.AlgES256, PrivateKey: []byte{2}, CreatedAt: now}))
+	assert.Equal(t, ErrSigningKeyExists, f.CreateSigningKey(ctx, model.SigningKey{ID: "new"}))
+	require.NoError(t, f.RetireSigningKey(ctx, "old", now))
+	assert.Equal(t, ErrSigningKeyNotFound, f.RetireSigningKey(ctx, "nope", now))
+
+	f, err = NewFile(path)
+	require.NoError(t, err)
+
+	keys := f.GetSigningKeys(ctx)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "new", keys[0].ID, "newest first")
+	assert.Equal(t, []byte{2}, keys[0].PrivateKey)
+	assert.True(t, keys[0].RetiredAt.IsZero())
+	assert.Equal(t, now, keys[1].RetiredAt)
+
+	require.NoError(t, f.DeleteSigningKey(ctx, "old"))
+	assert.Equal(t, ErrSigningKeyNotFound, f.DeleteSigningKey(ctx, "old"))
+
+	f, err = NewFile(path)
+	require.NoError(t, err)
+	assert.Len(t, f.GetSigningKeys(ctx), 1)
+}
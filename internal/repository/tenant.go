@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"errors"
+	"sort"
+)
+
+var (
+	ErrTenantExists   = errors.New("tenant exists")
+	ErrTenantNotFound = errors.New("tenant not found")
+)
+
+func (db *DB) CreateTenant(_ context.Context, t model.Tenant) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.tenants[t.ID]; ok {
+		return ErrTenantExists
+	}
+
+	db.tenants[t.ID] = t
+
+	return nil
+}
+
+func (db *DB) GetTenant(_ context.Context, id string) (model.Tenant, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	t, ok := db.tenants[id]
+	if !ok {
+		return model.Tenant{}, ErrTenantNotFound
+	}
+
+	return t, nil
+}
+
+// GetAllTenants returns the tenants ordered by ID.
+func (db *DB) GetAllTenants(_ context.Context) []model.Tenant {
+	return db.allTenants()
+}
+
+func (db *DB) UpdateTenant(_ context.Context, t model.Tenant) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	old, ok := db.tenants[t.ID]
+	if !ok {
+		return ErrTenantNotFound
+	}
+
+	t.CreatedAt = old.CreatedAt
+	db.tenants[t.ID] = t
+
+	return nil
+}
+
+// DeleteTenant removes tenant id together with its users, groups and the
+// invites issued in it.
+func (db *DB) DeleteTenant(_ context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.tenants[id]; !ok {
+		return ErrTenantNotFound
+	}
+
+	for _, u := range db.store {
+		if u.Tenant == id {
+			db.deleteUser(u)
+		}
+	}
+
+	for gid, g := range db.groups {
+		if g.Tenant == id {
+			delete(db.groups, gid)
+			delete(db.members, gid)
+		}
+	}
+
+	for tid, t := range db.tokens {
+		if t.Tenant == id {
+			delete(db.tokens, tid)
+		}
+	}
+
+	for cid, c := range db.clients {
+		if c.Tenant == id {
+			db.deleteClient(cid)
+		}
+	}
+
+	delete(db.tenants, id)
+
+	return nil
+}
+
+func (db *DB) allTenants() []model.Tenant {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	tenants := make([]model.Tenant, 0, len(db.tenants))
+	for _, t := range db.tenants {
+		tenants = append(tenants, t)
+	}
+
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].ID < tenants[j].ID
+	})
+
+	return tenants
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestDB_Tenants(t *testing.T) {
+	db := New(WithHashParams(HashParams{Time: 1, Memory: 64, Threads: 1, KeyLen: 16, SaltLen: 8}))
+
+	ctx := context.Background()
+	acme := tenant.WithID(ctx, "acme")
+
+	_, err := db.CreateUser(ctx, model.User{Username: "bob", Password: "p", Tenant: "acme"})
+	assert.Equal(t, ErrTenantNotFound, err)
+
+	require.NoError(t, db.CreateTenant(ctx, model.Tenant{ID: "acme", Name: "Acme"}))
+	assert.Equal(t, ErrTenantExists, db.CreateTenant(ctx, model.Tenant{ID: "acme"}))
+
+	_, err = db.CreateUser(ctx, model.User{Username: "bob", Password: "default"})
+	require.NoError(t, err)
+	_, err = db.CreateUser(ctx, model.User{Username: "bob", Password: "acme", Tenant: "acme"})
+	require.NoError(t, err)
+	_, err = db.CreateUser(ctx, model.User{Username: "bob", Password: "p", Tenant: "acme"})
+	assert.Equal(t, ErrUserNameExists, err)
+
+	assert.Len(t, db.GetAllUsers(ctx), 1)
+	assert.Len(t, db.GetAllUsers(acme), 1)
+
+	bob, err := db.GetUserByName(acme, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", bob.Tenant)
+
+	assert.True(t, db.IsAuthorized(acme, "bob", "acme"))
+	assert.False(t, db.IsAuthorized(acme, "bob", "default"))
+	assert.True(t, db.IsAuthorized(ctx, "bob", "default"))
+
+	bob.Tenant = tenant.Default
+	bob.Password = ""
+	require.NoError(t, db.UpdateUser(ctx, bob))
+	bob, err = db.GetUserByID(ctx, bob.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "acme", bob.Tenant, "users never move to another tenant")
+
+	require.NoError(t, db.CreateToken(ctx, model.Token{ID: "invite", Purpose: "invite", Tenant: "acme"}))
+	require.NoError(t, db.CreateSession(ctx, model.Session{ID: "s", UserID: bob.ID}))
+
+	require.NoError(t, db.DeleteTenant(ctx, "acme"))
+	assert.Equal(t, ErrTenantNotFound, db.DeleteTenant(ctx, "acme"))
+
+	_, err = db.GetUserByID(ctx, bob.ID)
+	assert.Equal(t, ErrUserNotFound, err)
+	_, err = db.ConsumeToken(ctx, "invite", "invite")
+	assert.Equal(t, ErrTokenNotFound, err)
+	_, err = db.GetSession(ctx, "s")
+	assert.Equal(t, ErrSessionNotFound, err)
+	assert.Len(t, db.GetAllUsers(ctx), 1, "other tenants are untouched")
+}
+
+func TestFileDB_Tenants(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	ctx := context.Background()
+
+	f, err := NewFile(path)
+	require.NoError(t, err)
+	require.NoError(t, f.CreateTenant(ctx, model.Tenant{ID: "acme", Name: "Acme"}))
+	_, err = f.CreateUser(ctx, model.User{Username: "bob", Password: "p", Tenant: "acme"})
+	require.NoError(t, err)
+	require.NoError(t, f.UpdateTenant(ctx, model.Tenant{ID: "acme", Name: "Acme Inc", Disabled: true}))
+
+	f, err = NewFile(path)
+	require.NoError(t, err)
+
+	acme, err := f.GetTenant(ctx, "acme")
+	require.NoError(t, err)
+	assert.Equal(t, "Acme Inc", acme.Name)
+	assert.True(t, acme.Disabled)
+
+	_, err = f.GetUserByName(tenant.WithID(ctx, "acme"), "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"no admin user"}, f.Verify(ctx), "tenant admins can't manage tenants")
+}
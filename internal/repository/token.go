@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"errors"
+	"sort"
+	"time"
+)
+
+var ErrTokenNotFound = errors.New("token not found")
+
+func (db *DB) CreateToken(_ context.Context, t model.Token) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.tokens[t.ID] = t
+
+	return nil
+}
+
+// ConsumeToken removes and returns token id if it was issued for purpose.
+func (db *DB) ConsumeToken(_ context.Context, id, purpose string) (model.Token, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, ok := db.tokens[id]
+	if !ok || t.Purpose != purpose {
+		return model.Token{}, ErrTokenNotFound
+	}
+
+	delete(db.tokens, id)
+
+	return t, nil
+}
+
+func (db *DB) DeleteTokensByUser(_ context.Context, userID, purpose string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for id, t := range db.tokens {
+		if t.UserID == userID && t.Purpose == purpose {
+			delete(db.tokens, id)
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) DeleteExpiredTokens(_ context.Context, now time.Time) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for id, t := range db.tokens {
+		if !now.Before(t.ExpiresAt) {
+			delete(db.tokens, id)
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) allTokens() []model.Token {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	tokens := make([]model.Token, 0, len(db.tokens))
+	for _, t := range db.tokens {
+		tokens = append(tokens, t)
+	}
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+
+	return tokens
+}
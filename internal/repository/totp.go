@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"errors"
+	"sort"
+	"strings"
+)
+
+var ErrTOTPNotFound = errors.New("two-factor authentication not set up")
+
+func (db *DB) GetTOTP(_ context.Context, userID string) (model.TOTP, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	t, ok := db.totp[userID]
+	if !ok {
+		return model.TOTP{}, ErrTOTPNotFound
+	}
+
+	return t, nil
+}
+
+// SaveTOTP stores t as is, recovery codes must already be hashed.
+func (db *DB) SaveTOTP(_ context.Context, t model.TOTP) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.totp[t.UserID] = t
+
+	return nil
+}
+
+func (db *DB) DeleteTOTP(_ context.Context, userID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.totp[userID]; !ok {
+		return ErrTOTPNotFound
+	}
+
+	delete(db.totp, userID)
+
+	return nil
+}
+
+// SetRecoveryCodes replaces the recovery codes of userID with hashes of codes.
+func (db *DB) SetRecoveryCodes(ctx context.Context, userID string, codes []string) error {
+	hashed := make([]model.RecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		hashed = append(hashed, model.RecoveryCode{Hash: db.encodeHash(ctx, normalizeCode(code))})
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, ok := db.totp[userID]
+	if !ok {
+		return ErrTOTPNotFound
+	}
+
+	t.RecoveryCodes = hashed
+	db.totp[userID] = t
+
+	return nil
+}
+
+// UseRecoveryCode reports whether code is one of the recovery codes of userID
+// and removes it if so. Like SetRecoveryCodes it hashes without the lock, a
+// code spent by a concurrent call in the meantime is not accepted twice.
+func (db *DB) UseRecoveryCode(ctx context.Context, userID, code string) bool {
+	db.mu.RLock()
+	t, ok := db.totp[userID]
+	codes := append([]model.RecoveryCode(nil), t.RecoveryCodes...)
+	db.mu.RUnlock()
+
+	if !ok {
+		return false
+	}
+
+	code = normalizeCode(code)
+
+	var matched string
+	for _, rc := range codes {
+		if ok, _ := db.verifyHash(ctx, code, rc.Hash, rc.Salt); ok {
+			matched = rc.Hash
+			break
+		}
+	}
+
+	if matched == "" {
+		return false
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, ok = db.totp[userID]
+	if !ok {
+		return false
+	}
+
+	for i, rc := range t.RecoveryCodes {
+		if rc.Hash == matched {
+			t.RecoveryCodes = append(t.RecoveryCodes[:i:i], t.RecoveryCodes[i+1:]...)
+			db.totp[userID] = t
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// UseTOTPStep records step as the last accepted time step of userID. It
+// reports false when that step or a later one was accepted before, so a code
+// is accepted once even by concurrent requests.
+func (db *DB) UseTOTPStep(_ context.Context, userID string, step int64) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, ok := db.totp[userID]
+	if !ok || step <= t.LastStep {
+		return false
+	}
+
+	t.LastStep = step
+	db.totp[userID] = t
+
+	return true
+}
+
+func (db *DB) allTOTP() []model.TOTP {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	all := make([]model.TOTP, 0, len(db.totp))
+	for _, t := range db.totp {
+		all = append(all, t)
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].UserID < all[j].UserID })
+
+	return all
+}
+
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
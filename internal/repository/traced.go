@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Traced wraps a Repository and records a child span for every call.
+type Traced struct {
+	next Repository
+}
+
+func NewTraced(next Repository) *Traced {
+	return &Traced{next: next}
+}
+
+func (t *Traced) CreateUser(ctx context.Context, u model.User) (model.User, error) {
+	ctx, span := tracer.Start(ctx, "repository.CreateUser",
+		trace.WithAttributes(attribute.String("user.name", u.Username)))
+	defer span.End()
+
+	u, err := t.next.CreateUser(ctx, u)
+	recordErr(span, err)
+
+	return u, err
+}
+
+func (t *Traced) GetAllUsers(ctx context.Context) []model.User {
+	ctx, span := tracer.Start(ctx, "repository.GetAllUsers")
+	defer span.End()
+
+	users := t.next.GetAllUsers(ctx)
+	span.SetAttributes(attribute.Int("user.count", len(users)))
+
+	return users
+}
+
+func (t *Traced) GetUserByName(ctx context.Context, name string) (model.User, error) {
+	ctx, span := tracer.Start(ctx, "repository.GetUserByName",
+		trace.WithAttributes(attribute.String("user.name", name)))
+	defer span.End()
+
+	u, err := t.next.GetUserByName(ctx, name)
+	recordErr(span, err)
+
+	return u, err
+}
+
+func (t *Traced) GetUserByID(ctx context.Context, id string) (model.User, error) {
+	ctx, span := tracer.Start(ctx, "repository.GetUserByID",
+		trace.WithAttributes(attribute.String("user.id", id)))
+	defer span.End()
+
+	u, err := t.next.GetUserByID(ctx, id)
+	recordErr(span, err)
+
+	return u, err
+}
+
+func (t *Traced) UpdateUser(ctx context.Context, u model.User) error {
+	ctx, span := tracer.Start(ctx, "repository.UpdateUser",
+		trace.WithAttributes(attribute.String("user.id", u.ID)))
+	defer span.End()
+
+	err := t.next.UpdateUser(ctx, u)
+	recordErr(span, err)
+
+	return err
+}
+
+func (t *Traced) DeleteUser(ctx context.Context, id string) error {
+	ctx, span := tracer.Start(ctx, "repository.DeleteUser",
+		trace.WithAttributes(attribute.String("user.id", id)))
+	defer span.End()
+
+	err := t.next.DeleteUser(ctx, id)
+	recordErr(span, err)
+
+	return err
+}
+
+func (t *Traced) IsAuthorized(ctx context.Context, username string, password string) bool {
+	ctx, span := tracer.Start(ctx, "repository.IsAuthorized",
+		trace.WithAttributes(attribute.String("user.name", username)))
+	defer span.End()
+
+	ok := t.next.IsAuthorized(ctx, username, password)
+	span.SetAttributes(attribute.Bool("auth.ok", ok))
+
+	return ok
+}
+
+func recordErr(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
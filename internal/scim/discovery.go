@@ -0,0 +1,211 @@
+package scim
+
+// Supported says whether an optional feature is available.
+type Supported struct {
+	Supported bool `json:"supported"`
+}
+
+// BulkConfig describes bulk operations, which aren't supported.
+type BulkConfig struct {
+	Supported      bool `json:"supported"`
+	MaxOperations  int  `json:"maxOperations"`
+	MaxPayloadSize int  `json:"maxPayloadSize"`
+}
+
+// FilterConfig describes filtering.
+type FilterConfig struct {
+	Supported  bool `json:"supported"`
+	MaxResults int  `json:"maxResults"`
+}
+
+// AuthenticationScheme is a way clients authenticate.
+type AuthenticationScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Primary     bool   `json:"primary,omitempty"`
+}
+
+// ServiceProviderConfig tells clients which features are available.
+type ServiceProviderConfig struct {
+	Schemas               []string               `json:"schemas"`
+	Patch                 Supported              `json:"patch"`
+	Bulk                  BulkConfig             `json:"bulk"`
+	Filter                FilterConfig           `json:"filter"`
+	ChangePassword        Supported              `json:"changePassword"`
+	Sort                  Supported              `json:"sort"`
+	ETag                  Supported              `json:"etag"`
+	AuthenticationSchemes []AuthenticationScheme `json:"authenticationSchemes"`
+	Meta                  Meta                   `json:"meta"`
+}
+
+// NewServiceProviderConfig returns the configuration of the API at base,
+// which returns at most maxResults resources per page.
+func NewServiceProviderConfig(base string, maxResults int) ServiceProviderConfig {
+	return ServiceProviderConfig{
+		Schemas:        []string{SchemaServiceProviderConfig},
+		Patch:          Supported{Supported: true},
+		Filter:         FilterConfig{Supported: true, MaxResults: maxResults},
+		ChangePassword: Supported{Supported: true},
+		AuthenticationSchemes: []AuthenticationScheme{
+			{
+				Type:        "oauthbearertoken",
+				Name:        "API key",
+				Description: "An API key with the user:write and group:write scopes, sent as a Bearer token",
+				Primary:     true,
+			},
+			{
+				Type:        "httpbasic",
+				Name:        "HTTP Basic",
+				Description: "Username and password of a user",
+			},
+		},
+		Meta: Meta{
+			ResourceType: "ServiceProviderConfig",
+			Location:     base + "/ServiceProviderConfig",
+		},
+	}
+}
+
+// ResourceType describes an endpoint.
+type ResourceType struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Endpoint    string   `json:"endpoint"`
+	Description string   `json:"description"`
+	Schema      string   `json:"schema"`
+	Meta        Meta     `json:"meta"`
+}
+
+// ResourceTypes returns the resource types of the API at base.
+func ResourceTypes(base string) []ResourceType {
+	return []ResourceType{
+		{
+			Schemas:     []string{SchemaResourceType},
+			ID:          "User",
+			Name:        "User",
+			Endpoint:    "/Users",
+			Description: "User Account",
+			Schema:      SchemaUser,
+			Meta:        Meta{ResourceType: "ResourceType", Location: base + "/ResourceTypes/User"},
+		},
+		{
+			Schemas:     []string{SchemaResourceType},
+			ID:          "Group",
+			Name:        "Group",
+			Endpoint:    "/Groups",
+			Description: "Group",
+			Schema:      SchemaGroup,
+			Meta:        Meta{ResourceType: "ResourceType", Location: base + "/ResourceTypes/Group"},
+		},
+	}
+}
+
+// Attribute describes an attribute of a schema.
+type Attribute struct {
+	Name          string      `json:"name"`
+	Type          string      `json:"type"`
+	MultiValued   bool        `json:"multiValued"`
+	Description   string      `json:"description"`
+	Required      bool        `json:"required"`
+	CaseExact     bool        `json:"caseExact"`
+	Mutability    string      `json:"mutability"`
+	Returned      string      `json:"returned"`
+	Uniqueness    string      `json:"uniqueness"`
+	SubAttributes []Attribute `json:"subAttributes,omitempty"`
+}
+
+// Schema lists the attributes of a resource.
+type Schema struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Attributes  []Attribute `json:"attributes"`
+	Meta        Meta        `json:"meta"`
+}
+
+// Schemas returns the schemas of the API at base: the attributes of the
+// User and Group resources that are kept.
+func Schemas(base string) []Schema {
+	return []Schema{
+		{
+			Schemas:     []string{SchemaSchema},
+			ID:          SchemaUser,
+			Name:        "User",
+			Description: "User Account",
+			Attributes: []Attribute{
+				attribute("userName", "string", "Unique identifier of the user within its tenant.", "readWrite", "default", "server", true),
+				attribute("password", "string", "Password of the user, never returned.", "writeOnly", "never", "none", false),
+				attribute("active", "boolean", "Whether the user may sign in.", "readWrite", "default", "none", false),
+				{
+					Name:        "emails",
+					Type:        "complex",
+					MultiValued: true,
+					Description: "Email address of the user, only the primary one is kept.",
+					Mutability:  "readWrite",
+					Returned:    "default",
+					Uniqueness:  "none",
+					SubAttributes: []Attribute{
+						attribute("value", "string", "Email address.", "readWrite", "default", "none", false),
+						attribute("type", "string", "Kind of address.", "readWrite", "default", "none", false),
+						attribute("primary", "boolean", "Whether this is the address in use.", "readWrite", "default", "none", false),
+					},
+				},
+				{
+					Name:        "groups",
+					Type:        "complex",
+					MultiValued: true,
+					Description: "Groups the user is a member of.",
+					Mutability:  "readOnly",
+					Returned:    "default",
+					Uniqueness:  "none",
+					SubAttributes: []Attribute{
+						attribute("value", "string", "ID of the group.", "readOnly", "default", "none", false),
+						attribute("$ref", "reference", "URI of the group.", "readOnly", "default", "none", false),
+						attribute("display", "string", "Name of the group.", "readOnly", "default", "none", false),
+					},
+				},
+			},
+			Meta: Meta{ResourceType: "Schema", Location: base + "/Schemas/" + SchemaUser},
+		},
+		{
+			Schemas:     []string{SchemaSchema},
+			ID:          SchemaGroup,
+			Name:        "Group",
+			Description: "Group",
+			Attributes: []Attribute{
+				attribute("displayName", "string", "Name of the group, unique within its tenant.", "readWrite", "default", "server", true),
+				{
+					Name:        "members",
+					Type:        "complex",
+					MultiValued: true,
+					Description: "Users in the group.",
+					Mutability:  "readWrite",
+					Returned:    "default",
+					Uniqueness:  "none",
+					SubAttributes: []Attribute{
+						attribute("value", "string", "ID of the user.", "immutable", "default", "none", false),
+						attribute("$ref", "reference", "URI of the user.", "immutable", "default", "none", false),
+						attribute("display", "string", "Username of the user.", "readOnly", "default", "none", false),
+						attribute("type", "string", "Always User.", "immutable", "default", "none", false),
+					},
+				},
+			},
+			Meta: Meta{ResourceType: "Schema", Location: base + "/Schemas/" + SchemaGroup},
+		},
+	}
+}
+
+func attribute(name, typ, description, mutability, returned, uniqueness string, required bool) Attribute {
+	return Attribute{
+		Name:        name,
+		Type:        typ,
+		Description: description,
+		Required:    required,
+		Mutability:  mutability,
+		Returned:    returned,
+		Uniqueness:  uniqueness,
+	}
+}
@@ -0,0 +1,512 @@
+package scim
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Filter selects resources by their JSON object (RFC 7644 section 3.4.2.2).
+// Strings compare case-insensitively.
+type Filter interface {
+	Match(resource map[string]interface{}) bool
+}
+
+// ParseFilter parses the filter expression s.
+func ParseFilter(s string) (Filter, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{toks: toks}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, badRequest(TypeInvalidFilter, "unexpected %q", t.text)
+	}
+
+	return f, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func lex(s string) ([]token, error) {
+	var toks []token
+
+	for i := 0; i < len(s); {
+		switch c := s[i]; c {
+		case ' ', '\t', '\n', '\r':
+			i++
+		case '(':
+			toks = append(toks, token{kind: tokLParen, text: "("})
+			i++
+		case ')':
+			toks = append(toks, token{kind: tokRParen, text: ")"})
+			i++
+		case '[':
+			toks = append(toks, token{kind: tokLBracket, text: "["})
+			i++
+		case ']':
+			toks = append(toks, token{kind: tokRBracket, text: "]"})
+			i++
+		case '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, badRequest(TypeInvalidFilter, "unterminated string")
+			}
+
+			var str string
+			if err := json.Unmarshal([]byte(s[i:j+1]), &str); err != nil {
+				return nil, badRequest(TypeInvalidFilter, "invalid string %s", s[i:j+1])
+			}
+			toks = append(toks, token{kind: tokString, text: str})
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\n\r()[]\"", rune(s[j])) {
+				j++
+			}
+			toks = append(toks, token{kind: tokWord, text: s[i:j]})
+			i = j
+		}
+	}
+
+	return append(toks, token{kind: tokEOF}), nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+// keyword consumes the next token if it is the word kw.
+func (p *parser) keyword(kw string) bool {
+	if t := p.peek(); t.kind == tokWord && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) expect(kind tokenKind, text string) error {
+	if t := p.next(); t.kind != kind {
+		return badRequest(TypeInvalidFilter, "expected %q", text)
+	}
+
+	return nil
+}
+
+// or, and, and not follow the precedence of RFC 7644: not binds tighter than
+// and, which binds tighter than or.
+func (p *parser) or() (Filter, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = orFilter{l, r}
+	}
+
+	return l, nil
+}
+
+func (p *parser) and() (Filter, error) {
+	l, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("and") {
+		r, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l = andFilter{l, r}
+	}
+
+	return l, nil
+}
+
+func (p *parser) not() (Filter, error) {
+	negate := p.keyword("not")
+
+	if negate || p.peek().kind == tokLParen {
+		if err := p.expect(tokLParen, "("); err != nil {
+			return nil, err
+		}
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+
+		if negate {
+			return notFilter{f}, nil
+		}
+		return f, nil
+	}
+
+	return p.attrExp()
+}
+
+func (p *parser) attrExp() (Filter, error) {
+	t := p.next()
+	if t.kind != tokWord {
+		return nil, badRequest(TypeInvalidFilter, "expected an attribute, got %q", t.text)
+	}
+
+	path, err := parseAttrPath(t.text)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind == tokLBracket {
+		p.next()
+		if path.sub != "" {
+			return nil, badRequest(TypeInvalidFilter, "%q has no sub-attributes to filter", t.text)
+		}
+
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(tokRBracket, "]"); err != nil {
+			return nil, err
+		}
+
+		return valueFilter{attr: path.attr, filter: f}, nil
+	}
+
+	op := p.next()
+	if op.kind != tokWord {
+		return nil, badRequest(TypeInvalidFilter, "expected an operator after %q", t.text)
+	}
+
+	switch o := strings.ToLower(op.text); o {
+	case "pr":
+		return present{path}, nil
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+		value, err := p.compValue()
+		if err != nil {
+			return nil, err
+		}
+		return compare{path: path, op: o, value: value}, nil
+	default:
+		return nil, badRequest(TypeInvalidFilter, "unknown operator %q", op.text)
+	}
+}
+
+func (p *parser) compValue() (interface{}, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokString:
+		return t.text, nil
+	case tokWord:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		if n, err := strconv.ParseFloat(t.text, 64); err == nil {
+			return n, nil
+		}
+	}
+
+	return nil, badRequest(TypeInvalidFilter, "invalid value %q", t.text)
+}
+
+// attrPath names an attribute and optionally one of its sub-attributes.
+type attrPath struct {
+	attr string
+	sub  string
+}
+
+func parseAttrPath(s string) (attrPath, error) {
+	// A schema URN may lead, e.g.
+	// urn:ietf:params:scim:schemas:core:2.0:User:userName.
+	name := s
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	attr, sub, hasSub := strings.Cut(name, ".")
+	if !validName(attr) || hasSub && !validName(sub) {
+		return attrPath{}, badRequest(TypeInvalidPath, "invalid attribute %q", s)
+	}
+
+	return attrPath{attr: attr, sub: sub}, nil
+}
+
+func validName(s string) bool {
+	if s == "$ref" {
+		return true
+	}
+	if s == "" {
+		return false
+	}
+
+	for i, r := range s {
+		letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+		if i == 0 && !letter || !letter && r != '-' && r != '_' && !(r >= '0' && r <= '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// values returns what p holds in resource, the values of multi-valued
+// attributes one by one. Elements of multi-valued complex attributes stand
+// for their value sub-attribute.
+func (p attrPath) values(resource map[string]interface{}) []interface{} {
+	v, _ := lookup(resource, p.attr)
+
+	var values []interface{}
+	for _, e := range elements(v) {
+		m, complex := e.(map[string]interface{})
+		switch {
+		case p.sub != "" && complex:
+			sv, _ := lookup(m, p.sub)
+			values = append(values, elements(sv)...)
+		case p.sub != "":
+		case complex:
+			if sv, ok := lookup(m, "value"); ok {
+				values = append(values, sv)
+			}
+		default:
+			values = append(values, e)
+		}
+	}
+
+	return values
+}
+
+// lookup returns the attribute name of m, names are case-insensitive.
+func lookup(m map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := m[name]; ok {
+		return v, true
+	}
+
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+// key returns the key m has for name, or name if it has none.
+func key(m map[string]interface{}, name string) string {
+	if _, ok := m[name]; ok {
+		return name
+	}
+
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+
+	return name
+}
+
+func elements(v interface{}) []interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	}
+
+	return []interface{}{v}
+}
+
+type andFilter struct{ l, r Filter }
+
+func (f andFilter) Match(resource map[string]interface{}) bool {
+	return f.l.Match(resource) && f.r.Match(resource)
+}
+
+type orFilter struct{ l, r Filter }
+
+func (f orFilter) Match(resource map[string]interface{}) bool {
+	return f.l.Match(resource) || f.r.Match(resource)
+}
+
+type notFilter struct{ f Filter }
+
+func (f notFilter) Match(resource map[string]interface{}) bool {
+	return !f.f.Match(resource)
+}
+
+// valueFilter matches resources with an element of the multi-valued complex
+// attribute attr matching filter, e.g. emails[type eq "work"].
+type valueFilter struct {
+	attr   string
+	filter Filter
+}
+
+func (f valueFilter) Match(resource map[string]interface{}) bool {
+	v, _ := lookup(resource, f.attr)
+	for _, e := range elements(v) {
+		if m, ok := e.(map[string]interface{}); ok && f.filter.Match(m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type present struct{ path attrPath }
+
+func (f present) Match(resource map[string]interface{}) bool {
+	for _, v := range f.path.values(resource) {
+		switch v := v.(type) {
+		case string:
+			if v != "" {
+				return true
+			}
+		case map[string]interface{}:
+			if len(v) > 0 {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+
+	return false
+}
+
+type compare struct {
+	path  attrPath
+	op    string
+	value interface{}
+}
+
+func (f compare) Match(resource map[string]interface{}) bool {
+	values := f.path.values(resource)
+
+	if f.op == "ne" {
+		return !compare{path: f.path, op: "eq", value: f.value}.Match(resource)
+	}
+	if f.value == nil {
+		return f.op == "eq" && len(values) == 0
+	}
+
+	for _, v := range values {
+		if f.matches(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f compare) matches(v interface{}) bool {
+	switch want := f.value.(type) {
+	case string:
+		got, ok := v.(string)
+		if !ok {
+			return false
+		}
+		got, want = strings.ToLower(got), strings.ToLower(want)
+
+		switch f.op {
+		case "eq":
+			return got == want
+		case "co":
+			return strings.Contains(got, want)
+		case "sw":
+			return strings.HasPrefix(got, want)
+		case "ew":
+			return strings.HasSuffix(got, want)
+		}
+		return ordered(strings.Compare(got, want), f.op)
+	case float64:
+		got, ok := v.(float64)
+		if !ok {
+			return false
+		}
+		if f.op == "eq" {
+			return got == want
+		}
+		switch {
+		case got < want:
+			return ordered(-1, f.op)
+		case got > want:
+			return ordered(1, f.op)
+		}
+		return ordered(0, f.op)
+	case bool:
+		got, ok := v.(bool)
+		return ok && f.op == "eq" && got == want
+	}
+
+	return false
+}
+
+// ordered reports whether a comparison result satisfies op.
+func ordered(cmp int, op string) bool {
+	switch op {
+	case "gt":
+		return cmp > 0
+	case "ge":
+		return cmp >= 0
+	case "lt":
+		return cmp < 0
+	case "le":
+		return cmp <= 0
+	}
+
+	return false
+}
@@ -0,0 +1,93 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const bob = `{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"id": "2819c223",
+	"externalId": "bob-ext",
+	"userName": "Bob",
+	"active": true,
+	"emails": [
+		{"value": "bob@work.example", "type": "work", "primary": true},
+		{"value": "bob@home.example", "type": "home"}
+	],
+	"groups": [{"value": "g1", "display": "staff"}],
+	"meta": {"resourceType": "User", "created": "2024-05-01T10:00:00Z"},
+	"logins": 3
+}`
+
+func TestParseFilter(t *testing.T) {
+	var resource map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(bob), &resource))
+
+	tests := []struct {
+		filter string
+		match  bool
+	}{
+		{filter: `userName eq "bob"`, match: true},
+		{filter: `USERNAME Eq "BOB"`, match: true},
+		{filter: `urn:ietf:params:scim:schemas:core:2.0:User:userName eq "bob"`, match: true},
+		{filter: `userName ne "bob"`, match: false},
+		{filter: `userName ne "alice"`, match: true},
+		{filter: `userName co "o"`, match: true},
+		{filter: `userName sw "b"`, match: true},
+		{filter: `userName ew "x"`, match: false},
+		{filter: `externalId pr`, match: true},
+		{filter: `password pr`, match: false},
+		{filter: `active eq true`, match: true},
+		{filter: `active eq false`, match: false},
+		{filter: `logins gt 2`, match: true},
+		{filter: `logins le 2`, match: false},
+		{filter: `meta.created gt "2024-01-01T00:00:00Z"`, match: true},
+		{filter: `meta.created lt "2024-01-01T00:00:00Z"`, match: false},
+		{filter: `emails co "home.example"`, match: true},
+		{filter: `emails.type eq "home"`, match: true},
+		{filter: `emails[type eq "work" and value ew "@work.example"]`, match: true},
+		{filter: `emails[type eq "work" and value ew "@home.example"]`, match: false},
+		{filter: `groups[value eq "g1"]`, match: true},
+		{filter: `title eq null`, match: true},
+		{filter: `userName eq "alice" or userName eq "bob"`, match: true},
+		{filter: `userName eq "bob" and active eq false`, match: false},
+		{filter: `not (userName eq "bob")`, match: false},
+		{filter: `userName eq "alice" or userName eq "bob" and active eq false`, match: false},
+		{filter: `(userName eq "alice" or userName eq "bob") and not (active eq false)`, match: true},
+		{filter: `userName eq "quote\"d"`, match: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.filter, func(t *testing.T) {
+			f, err := ParseFilter(test.filter)
+			require.NoError(t, err)
+			assert.Equal(t, test.match, f.Match(resource))
+		})
+	}
+
+	t.Run("NOT_OK", func(t *testing.T) {
+		for _, filter := range []string{
+			`userName`,
+			`userName eq`,
+			`userName xx "bob"`,
+			`userName eq bob`,
+			`userName eq "bob`,
+			`(userName eq "bob"`,
+			`userName eq "bob")`,
+			`emails[type eq "work"`,
+			`not userName eq "bob"`,
+			`1name eq "bob"`,
+			`userName eq "bob" and`,
+		} {
+			_, err := ParseFilter(filter)
+
+			var bad *BadRequestError
+			require.True(t, errors.As(err, &bad), filter)
+			assert.Contains(t, []string{TypeInvalidFilter, TypeInvalidPath}, bad.Type, filter)
+		}
+	})
+}
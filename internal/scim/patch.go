@@ -0,0 +1,323 @@
+package scim
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// PatchRequest is the body of a PATCH request (RFC 7644 section 3.5.2).
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation adds, replaces or removes the value at Path. Without a path
+// Value is an object of the attributes to add or replace.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty" swaggertype:"object"`
+}
+
+// path is the target of an operation: an attribute, optionally narrowed to
+// the elements matching filter, and optionally one of their sub-attributes,
+// e.g. emails[type eq "work"].value.
+type path struct {
+	attrPath
+	filter Filter
+}
+
+func parsePath(s string) (path, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return path{}, badRequest(TypeInvalidPath, "invalid path %q", s)
+	}
+
+	p := &parser{toks: toks}
+	t := p.next()
+	if t.kind != tokWord {
+		return path{}, badRequest(TypeInvalidPath, "invalid path %q", s)
+	}
+
+	attr, err := parseAttrPath(t.text)
+	if err != nil {
+		return path{}, err
+	}
+	target := path{attrPath: attr}
+
+	if p.peek().kind == tokLBracket {
+		p.next()
+		if attr.sub != "" {
+			return path{}, badRequest(TypeInvalidPath, "invalid path %q", s)
+		}
+
+		if target.filter, err = p.or(); err != nil {
+			return path{}, err
+		}
+		if err = p.expect(tokRBracket, "]"); err != nil {
+			return path{}, err
+		}
+
+		// A sub-attribute may follow the filter.
+		if t := p.peek(); t.kind == tokWord && strings.HasPrefix(t.text, ".") {
+			p.next()
+			target.sub = t.text[1:]
+			if !validName(target.sub) {
+				return path{}, badRequest(TypeInvalidPath, "invalid path %q", s)
+			}
+		}
+	}
+
+	if p.peek().kind != tokEOF {
+		return path{}, badRequest(TypeInvalidPath, "invalid path %q", s)
+	}
+
+	return target, nil
+}
+
+// Apply applies the operations of r in order to resource, the JSON object of
+// a resource. It stops at the first failing operation, leaving resource
+// partly changed.
+func (r PatchRequest) Apply(resource map[string]interface{}) error {
+	if !hasSchema(r.Schemas, SchemaPatchOp) {
+		return badRequest(TypeInvalidSyntax, "schemas must be [%q]", SchemaPatchOp)
+	}
+	if len(r.Operations) == 0 {
+		return badRequest(TypeInvalidSyntax, "no Operations")
+	}
+
+	for _, op := range r.Operations {
+		if err := op.apply(resource); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (op PatchOperation) apply(resource map[string]interface{}) error {
+	kind := strings.ToLower(op.Op)
+	switch kind {
+	case "add", "replace", "remove":
+	default:
+		return badRequest(TypeInvalidSyntax, "unknown op %q", op.Op)
+	}
+
+	var value interface{}
+	if len(op.Value) > 0 {
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return badRequest(TypeInvalidValue, "invalid value: %v", err)
+		}
+	}
+
+	if op.Path != "" {
+		target, err := parsePath(op.Path)
+		if err != nil {
+			return err
+		}
+
+		return patch(resource, kind, target, value)
+	}
+
+	if kind == "remove" {
+		return badRequest(TypeNoTarget, "remove needs a path")
+	}
+
+	attrs, ok := value.(map[string]interface{})
+	if !ok {
+		return badRequest(TypeInvalidValue, "value must be an object when there is no path")
+	}
+
+	// Some clients name sub-attributes in the keys, e.g. "name.givenName".
+	for k, v := range attrs {
+		target, err := parsePath(k)
+		if err != nil {
+			return err
+		}
+
+		if err = patch(resource, kind, target, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func patch(resource map[string]interface{}, kind string, target path, value interface{}) error {
+	if kind != "remove" && value == nil {
+		return badRequest(TypeInvalidValue, "%s of %s needs a value", kind, target.attr)
+	}
+
+	if target.filter != nil {
+		return patchElements(resource, kind, target, value)
+	}
+
+	attr := key(resource, target.attr)
+	current, exists := resource[attr]
+
+	if target.sub != "" {
+		parent, ok := current.(map[string]interface{})
+		switch {
+		case exists && !ok:
+			return badRequest(TypeInvalidPath, "%s has no sub-attribute %s", target.attr, target.sub)
+		case kind == "remove" && ok:
+			delete(parent, key(parent, target.sub))
+		case kind != "remove":
+			if !ok {
+				parent = make(map[string]interface{})
+				resource[attr] = parent
+			}
+			parent[key(parent, target.sub)] = value
+		}
+		return nil
+	}
+
+	switch kind {
+	case "remove":
+		// Some clients remove elements of a multi-valued attribute by naming
+		// them in the value.
+		if list, ok := current.([]interface{}); ok && value != nil {
+			resource[attr] = without(list, elements(value))
+			return nil
+		}
+		delete(resource, attr)
+	case "add":
+		if list, ok := current.([]interface{}); ok || !exists && isList(value) {
+			for _, e := range elements(value) {
+				if !containsElement(list, e) {
+					list = append(list, e)
+				}
+			}
+			resource[attr] = list
+			return nil
+		}
+		resource[attr] = merge(current, value)
+	case "replace":
+		resource[attr] = merge(current, value)
+	}
+
+	return nil
+}
+
+// patchElements applies an operation to the elements of a multi-valued
+// attribute matching the filter of target.
+func patchElements(resource map[string]interface{}, kind string, target path, value interface{}) error {
+	attr := key(resource, target.attr)
+	list := elements(resource[attr])
+
+	var matched []int
+	for i, e := range list {
+		if m, ok := e.(map[string]interface{}); ok && target.filter.Match(m) {
+			matched = append(matched, i)
+		}
+	}
+
+	if len(matched) == 0 {
+		// Setting emails[type eq "work"].value creates the work address.
+		if c, ok := target.filter.(compare); ok && kind != "remove" && target.sub != "" && c.op == "eq" && c.path.sub == "" {
+			resource[attr] = append(list, map[string]interface{}{c.path.attr: c.value, target.sub: value})
+			return nil
+		}
+
+		return badRequest(TypeNoTarget, "no %s matches the filter", target.attr)
+	}
+
+	if kind == "add" && target.sub == "" {
+		return badRequest(TypeInvalidPath, "add to a filtered %s needs a sub-attribute", target.attr)
+	}
+
+	result := make([]interface{}, 0, len(list))
+	next := 0
+	for i, e := range list {
+		if next >= len(matched) || matched[next] != i {
+			result = append(result, e)
+			continue
+		}
+		next++
+
+		m := e.(map[string]interface{})
+		switch {
+		case kind == "remove" && target.sub == "":
+			continue
+		case kind == "remove":
+			delete(m, key(m, target.sub))
+		case target.sub == "":
+			e = value
+		default:
+			m[key(m, target.sub)] = value
+		}
+		result = append(result, e)
+	}
+	resource[attr] = result
+
+	return nil
+}
+
+// merge returns value replacing current. Complex values keep the
+// sub-attributes value doesn't name.
+func merge(current, value interface{}) interface{} {
+	cm, ok := current.(map[string]interface{})
+	vm, ok2 := value.(map[string]interface{})
+	if !ok || !ok2 {
+		return value
+	}
+
+	for k, v := range vm {
+		cm[key(cm, k)] = v
+	}
+
+	return cm
+}
+
+func isList(v interface{}) bool {
+	_, ok := v.([]interface{})
+	return ok
+}
+
+// sameElement reports whether a and b are the same element of a
+// multi-valued attribute: complex elements are the same if their values are.
+func sameElement(a, b interface{}) bool {
+	am, ok := a.(map[string]interface{})
+	bm, ok2 := b.(map[string]interface{})
+	if ok && ok2 {
+		av, ok := lookup(am, "value")
+		bv, ok2 := lookup(bm, "value")
+		if ok && ok2 {
+			return reflect.DeepEqual(av, bv)
+		}
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func containsElement(list []interface{}, e interface{}) bool {
+	for _, v := range list {
+		if sameElement(v, e) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func without(list, remove []interface{}) []interface{} {
+	result := make([]interface{}, 0, len(list))
+	for _, e := range list {
+		if !containsElement(remove, e) {
+			result = append(result, e)
+		}
+	}
+
+	return result
+}
+
+func hasSchema(schemas []string, schema string) bool {
+	for _, s := range schemas {
+		if strings.EqualFold(s, schema) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,192 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPatchRequest_Apply(t *testing.T) {
+	const group = `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+		"id": "g1",
+		"displayName": "staff",
+		"members": [{"value": "u1", "display": "alice"}, {"value": "u2", "display": "bob"}]
+	}`
+
+	tests := []struct {
+		name       string
+		resource   string
+		operations string
+		expected   string
+	}{
+		{
+			name:       "REPLACE",
+			resource:   bob,
+			operations: `[{"op": "replace", "path": "userName", "value": "robert"}]`,
+			expected:   `{"userName": "robert"}`,
+		},
+		{
+			name:       "REPLACE_WITHOUT_PATH",
+			resource:   bob,
+			operations: `[{"op": "Replace", "value": {"active": false, "externalId": "x"}}]`,
+			expected:   `{"active": false, "externalId": "x"}`,
+		},
+		{
+			name:       "REPLACE_STRING_BOOLEAN",
+			resource:   bob,
+			operations: `[{"op": "Replace", "path": "active", "value": "False"}]`,
+			expected:   `{"active": "False"}`,
+		},
+		{
+			name:       "REPLACE_FILTERED_SUB_ATTRIBUTE",
+			resource:   bob,
+			operations: `[{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "b@work.example"}]`,
+			expected: `{"emails": [
+				{"value": "b@work.example", "type": "work", "primary": true},
+				{"value": "bob@home.example", "type": "home"}
+			]}`,
+		},
+		{
+			name:       "ADD_FILTERED_SUB_ATTRIBUTE_CREATES",
+			resource:   `{"userName": "bob"}`,
+			operations: `[{"op": "add", "path": "emails[type eq \"work\"].value", "value": "bob@work.example"}]`,
+			expected:   `{"userName": "bob", "emails": [{"type": "work", "value": "bob@work.example"}]}`,
+		},
+		{
+			name:       "ADD_SUB_ATTRIBUTE",
+			resource:   `{"userName": "bob"}`,
+			operations: `[{"op": "add", "path": "name.givenName", "value": "Bob"}, {"op": "add", "value": {"name.familyName": "Smith"}}]`,
+			expected:   `{"userName": "bob", "name": {"givenName": "Bob", "familyName": "Smith"}}`,
+		},
+		{
+			name:       "ADD_MEMBERS",
+			resource:   group,
+			operations: `[{"op": "add", "path": "members", "value": [{"value": "u2"}, {"value": "u3"}]}]`,
+			expected: `{"members": [
+				{"value": "u1", "display": "alice"}, {"value": "u2", "display": "bob"}, {"value": "u3"}
+			]}`,
+		},
+		{
+			name:       "REMOVE_FILTERED",
+			resource:   group,
+			operations: `[{"op": "remove", "path": "members[value eq \"u1\"]"}]`,
+			expected:   `{"members": [{"value": "u2", "display": "bob"}]}`,
+		},
+		{
+			name:       "REMOVE_BY_VALUE",
+			resource:   group,
+			operations: `[{"op": "remove", "path": "members", "value": [{"value": "u2"}]}]`,
+			expected:   `{"members": [{"value": "u1", "display": "alice"}]}`,
+		},
+		{
+			name:       "REMOVE_ALL",
+			resource:   group,
+			operations: `[{"op": "remove", "path": "members"}]`,
+			expected:   `{"displayName": "staff"}`,
+		},
+		{
+			name:       "REPLACE_MEMBERS",
+			resource:   group,
+			operations: `[{"op": "replace", "path": "members", "value": [{"value": "u3"}]}]`,
+			expected:   `{"members": [{"value": "u3"}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var resource, expected map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(test.resource), &resource))
+			require.NoError(t, json.Unmarshal([]byte(test.expected), &expected))
+
+			var ops []PatchOperation
+			require.NoError(t, json.Unmarshal([]byte(test.operations), &ops))
+
+			err := PatchRequest{Schemas: []string{SchemaPatchOp}, Operations: ops}.Apply(resource)
+			require.NoError(t, err)
+
+			for k, v := range expected {
+				assert.Equal(t, v, resource[k], k)
+			}
+			if test.name == "REMOVE_ALL" {
+				assert.NotContains(t, resource, "members")
+			}
+		})
+	}
+
+	t.Run("NOT_OK", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			schemas    []string
+			operations string
+			scimType   string
+		}{
+			{name: "SCHEMA", operations: `[{"op": "add", "path": "userName", "value": "x"}]`, scimType: TypeInvalidSyntax},
+			{name: "NO_OPERATIONS", schemas: []string{SchemaPatchOp}, operations: `[]`, scimType: TypeInvalidSyntax},
+			{name: "UNKNOWN_OP", schemas: []string{SchemaPatchOp}, operations: `[{"op": "move", "path": "userName"}]`, scimType: TypeInvalidSyntax},
+			{name: "REMOVE_WITHOUT_PATH", schemas: []string{SchemaPatchOp}, operations: `[{"op": "remove"}]`, scimType: TypeNoTarget},
+			{name: "NO_MATCH", schemas: []string{SchemaPatchOp}, operations: `[{"op": "remove", "path": "emails[type eq \"other\"]"}]`, scimType: TypeNoTarget},
+			{name: "INVALID_PATH", schemas: []string{SchemaPatchOp}, operations: `[{"op": "add", "path": "emails[type eq", "value": "x"}]`, scimType: TypeInvalidFilter},
+			{name: "NO_VALUE", schemas: []string{SchemaPatchOp}, operations: `[{"op": "replace", "path": "userName"}]`, scimType: TypeInvalidValue},
+			{name: "VALUE_NOT_OBJECT", schemas: []string{SchemaPatchOp}, operations: `[{"op": "add", "value": "x"}]`, scimType: TypeInvalidValue},
+			{name: "SUB_OF_STRING", schemas: []string{SchemaPatchOp}, operations: `[{"op": "add", "path": "userName.x", "value": "x"}]`, scimType: TypeInvalidPath},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				var resource map[string]interface{}
+				require.NoError(t, json.Unmarshal([]byte(bob), &resource))
+
+				var ops []PatchOperation
+				require.NoError(t, json.Unmarshal([]byte(test.operations), &ops))
+
+				err := PatchRequest{Schemas: test.schemas, Operations: ops}.Apply(resource)
+
+				var bad *BadRequestError
+				require.True(t, errors.As(err, &bad), "%v", err)
+				assert.Equal(t, test.scimType, bad.Type)
+			})
+		}
+	})
+}
+
+func TestParseListParams(t *testing.T) {
+	params, err := ParseListParams(map[string][]string{
+		"startIndex":         {"0"},
+		"count":              {"500"},
+		"excludedAttributes": {"members, meta"},
+	}, 100)
+	require.NoError(t, err)
+	assert.Equal(t, 1, params.StartIndex)
+	assert.Equal(t, 100, params.Count)
+	assert.Nil(t, params.Filter)
+
+	start, end := params.Page(150)
+	assert.Equal(t, 0, start)
+	assert.Equal(t, 100, end)
+
+	params, err = ParseListParams(map[string][]string{"startIndex": {"140"}, "count": {"20"}, "filter": {`userName pr`}}, 100)
+	require.NoError(t, err)
+	start, end = params.Page(150)
+	assert.Equal(t, 139, start)
+	assert.Equal(t, 150, end)
+	assert.NotNil(t, params.Filter)
+
+	resource := map[string]interface{}{"schemas": []interface{}{}, "id": "1", "userName": "bob", "members": []interface{}{}, "meta": map[string]interface{}{}}
+	Project(resource, nil, []string{"members", "meta"})
+	assert.Equal(t, map[string]interface{}{"schemas": []interface{}{}, "id": "1", "userName": "bob", "meta": map[string]interface{}{}}, resource)
+	Project(resource, []string{"urn:ietf:params:scim:schemas:core:2.0:Group:displayName"}, nil)
+	assert.NotContains(t, resource, "userName")
+
+	for _, query := range []map[string][]string{
+		{"count": {"ten"}},
+		{"startIndex": {"x"}},
+		{"filter": {"userName eq"}},
+	} {
+		_, err = ParseListParams(query, 100)
+		var bad *BadRequestError
+		assert.True(t, errors.As(err, &bad), "%v", query)
+	}
+}
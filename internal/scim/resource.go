@@ -0,0 +1,159 @@
+package scim
+
+import (
+	"dev/profileSaver/internal/model"
+	"time"
+)
+
+// Meta describes a resource.
+type Meta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	Location     string     `json:"location,omitempty"`
+}
+
+// Email is an address of a user.
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary Bool   `json:"primary,omitempty"`
+}
+
+// GroupRef is a group a user belongs to.
+type GroupRef struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+// User is the User resource. Attributes without a place in model.User, like
+// name, are ignored.
+type User struct {
+	Schemas    []string `json:"schemas"`
+	ID         string   `json:"id,omitempty"`
+	ExternalID string   `json:"externalId,omitempty"`
+	UserName   string   `json:"userName"`
+	// Password is write-only, it is never returned.
+	Password string `json:"password,omitempty"`
+	// Active is true unless set otherwise.
+	Active *Bool      `json:"active,omitempty"`
+	Emails []Email    `json:"emails,omitempty"`
+	Groups []GroupRef `json:"groups,omitempty"`
+	Meta   *Meta      `json:"meta,omitempty"`
+}
+
+// Member is a member of a group. Only users can be members.
+type Member struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+// Group is the Group resource.
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []Member `json:"members,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// NewUser returns the resource of u, a member of groups. base is the URL of
+// the SCIM API.
+func NewUser(u model.User, groups []model.Group, base string) User {
+	active := Bool(!u.Disabled)
+
+	r := User{
+		Schemas:    []string{SchemaUser},
+		ID:         u.ID,
+		ExternalID: u.ExternalID,
+		UserName:   u.Username,
+		Active:     &active,
+		Meta: &Meta{
+			ResourceType: "User",
+			Location:     base + "/Users/" + u.ID,
+		},
+	}
+
+	if u.Email != "" {
+		r.Emails = []Email{{Value: u.Email, Type: "work", Primary: true}}
+	}
+
+	for _, g := range groups {
+		r.Groups = append(r.Groups, GroupRef{
+			Value:   g.ID,
+			Ref:     base + "/Groups/" + g.ID,
+			Display: g.Name,
+		})
+	}
+
+	return r
+}
+
+// IsActive reports whether the user may sign in.
+func (r User) IsActive() bool {
+	return r.Active == nil || bool(*r.Active)
+}
+
+// Email returns the primary address of the user, else the first one.
+func (r User) Email() string {
+	for _, e := range r.Emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+
+	if len(r.Emails) > 0 {
+		return r.Emails[0].Value
+	}
+
+	return ""
+}
+
+// NewGroup returns the resource of g with its members. base is the URL of
+// the SCIM API.
+func NewGroup(g model.Group, members []model.User, base string) Group {
+	created := g.CreatedAt
+
+	r := Group{
+		Schemas:     []string{SchemaGroup},
+		ID:          g.ID,
+		ExternalID:  g.ExternalID,
+		DisplayName: g.Name,
+		Meta: &Meta{
+			ResourceType: "Group",
+			Created:      &created,
+			Location:     base + "/Groups/" + g.ID,
+		},
+	}
+
+	for _, u := range members {
+		r.Members = append(r.Members, Member{
+			Value:   u.ID,
+			Ref:     base + "/Users/" + u.ID,
+			Display: u.Username,
+			Type:    "User",
+		})
+	}
+
+	return r
+}
+
+// MemberIDs returns the user IDs of the members, failing for members that
+// aren't users.
+func (r Group) MemberIDs() ([]string, error) {
+	ids := make([]string, 0, len(r.Members))
+	for _, m := range r.Members {
+		if m.Type != "" && m.Type != "User" {
+			return nil, badRequest(TypeInvalidValue, "members of type %q aren't supported", m.Type)
+		}
+		if m.Value == "" {
+			return nil, badRequest(TypeInvalidValue, "member without value")
+		}
+		ids = append(ids, m.Value)
+	}
+
+	return ids, nil
+}
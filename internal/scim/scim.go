@@ -0,0 +1,268 @@
+// Package scim implements what the SCIM 2.0 provisioning API needs of RFC 7643
+// and RFC 7644: the User and Group resources, filters, PATCH operations,
+// paging and the discovery documents. Resources are filtered and patched in
+// their JSON form.
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the media type of SCIM requests and responses.
+const ContentType = "application/scim+json"
+
+// Schema URNs.
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// scimType values of 400 and 409 errors (RFC 7644 section 3.12).
+const (
+	TypeInvalidFilter = "invalidFilter"
+	TypeInvalidSyntax = "invalidSyntax"
+	TypeInvalidPath   = "invalidPath"
+	TypeInvalidValue  = "invalidValue"
+	TypeNoTarget      = "noTarget"
+	TypeUniqueness    = "uniqueness"
+)
+
+// Error is the body of error responses.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+// NewError returns the error response of status.
+func NewError(status int, scimType, detail string) Error {
+	return Error{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	}
+}
+
+// BadRequestError is a request that can't be processed, answered with 400
+// and its Type.
+type BadRequestError struct {
+	Type   string
+	Detail string
+}
+
+func (e *BadRequestError) Error() string {
+	return e.Type + ": " + e.Detail
+}
+
+func badRequest(scimType, format string, args ...interface{}) error {
+	return &BadRequestError{Type: scimType, Detail: fmt.Sprintf(format, args...)}
+}
+
+// ListResponse is a page of resources.
+type ListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+// ListParams are the query parameters of a list request.
+type ListParams struct {
+	// Filter is nil when every resource is listed.
+	Filter Filter
+	// StartIndex is 1-based.
+	StartIndex         int
+	Count              int
+	Attributes         []string
+	ExcludedAttributes []string
+}
+
+// ParseListParams reads filter, startIndex, count, attributes and
+// excludedAttributes. Count defaults to and is capped at maxResults.
+func ParseListParams(query url.Values, maxResults int) (ListParams, error) {
+	params := ListParams{
+		StartIndex:         1,
+		Count:              maxResults,
+		Attributes:         splitList(query.Get("attributes")),
+		ExcludedAttributes: splitList(query.Get("excludedAttributes")),
+	}
+
+	if v := query.Get("filter"); v != "" {
+		f, err := ParseFilter(v)
+		if err != nil {
+			return ListParams{}, err
+		}
+		params.Filter = f
+	}
+
+	// Values below 1 and 0 are interpreted as 1 and 0 (RFC 7644 section
+	// 3.4.2.4).
+	if v := query.Get("startIndex"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return ListParams{}, badRequest(TypeInvalidValue, "startIndex %q is not a number", v)
+		}
+		if n > 1 {
+			params.StartIndex = n
+		}
+	}
+
+	if v := query.Get("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return ListParams{}, badRequest(TypeInvalidValue, "count %q is not a number", v)
+		}
+		if n < 0 {
+			n = 0
+		}
+		if n < maxResults {
+			params.Count = n
+		}
+	}
+
+	return params, nil
+}
+
+// Page returns the part of a list of total resources p covers.
+func (p ListParams) Page(total int) (int, int) {
+	start := p.StartIndex - 1
+	if start > total {
+		start = total
+	}
+
+	end := start + p.Count
+	if end > total {
+		end = total
+	}
+
+	return start, end
+}
+
+// List answers a list request with the resources of p among total matches.
+func (p ListParams) List(total int, resources []interface{}) ListResponse {
+	if resources == nil {
+		resources = []interface{}{}
+	}
+
+	return ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   p.StartIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
+
+// ToMap returns the JSON object of resource.
+func ToMap(resource interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// FromMap decodes the JSON object m into resource.
+func FromMap(m map[string]interface{}, resource interface{}) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	if err = json.Unmarshal(data, resource); err != nil {
+		return badRequest(TypeInvalidValue, "%v", err)
+	}
+
+	return nil
+}
+
+// Project keeps only the top-level attributes named in attributes, when
+// given, and drops those in excluded. schemas, id and meta are always
+// returned.
+func Project(resource map[string]interface{}, attributes, excluded []string) {
+	keep := make(map[string]bool)
+	for _, a := range attributes {
+		keep[strings.ToLower(attributeName(a))] = true
+	}
+
+	drop := make(map[string]bool)
+	for _, a := range excluded {
+		drop[strings.ToLower(attributeName(a))] = true
+	}
+
+	for k := range resource {
+		name := strings.ToLower(k)
+		if name == "schemas" || name == "id" || name == "meta" {
+			continue
+		}
+		if len(keep) > 0 && !keep[name] || drop[name] {
+			delete(resource, k)
+		}
+	}
+}
+
+// attributeName returns the top-level attribute of an attribute path.
+func attributeName(path string) string {
+	p, err := parseAttrPath(path)
+	if err != nil {
+		return path
+	}
+
+	return p.attr
+}
+
+// Bool decodes JSON booleans and, as some clients send them, the strings
+// "true" and "false".
+type Bool bool
+
+func (b *Bool) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case bool:
+		*b = Bool(v)
+		return nil
+	case string:
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", v)
+		}
+		*b = Bool(parsed)
+		return nil
+	}
+
+	return fmt.Errorf("%s is not a boolean", data)
+}
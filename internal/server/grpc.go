@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"dev/profileSaver/internal/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"net"
+)
+
+// GRPCServer serves the gRPC API next to Server.
+type GRPCServer struct {
+	server *grpc.Server
+}
+
+// Run serves the server newServer builds until Shutdown is called. With
+// TLS enabled in tlsCfg the server gets its credentials.
+func (s *GRPCServer) Run(cfg config.GRPC, tlsCfg config.TLS, newServer func(opts ...grpc.ServerOption) *grpc.Server) error {
+	var opts []grpc.ServerOption
+
+	if tlsCfg.Enabled {
+		tlsConfig, err := NewTLSConfig(tlsCfg)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(cfg.Addr, cfg.Port))
+	if err != nil {
+		return err
+	}
+
+	s.server = newServer(opts...)
+
+	return s.server.Serve(listener)
+}
+
+// Shutdown waits for running calls to finish, cancelling them once ctx is
+// done.
+func (s *GRPCServer) Shutdown(ctx context.Context) {
+	if s.server == nil {
+		return
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		s.server.Stop()
+	}
+}
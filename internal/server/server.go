@@ -2,26 +2,97 @@ package server
 
 import (
 	"context"
+	"dev/profileSaver/internal/config"
+	"errors"
+	"net"
 	"net/http"
-	"time"
 )
 
 type Server struct {
-	httpServer *http.Server
+	httpServer     *http.Server
+	redirectServer *http.Server
 }
 
-func (s *Server) Run(port string, handler http.Handler) error {
+// Run serves handler until Shutdown is called. With TLS enabled it also
+// starts the HTTP to HTTPS redirect listener when a redirect port is set.
+func (s *Server) Run(cfg config.Server, handler http.Handler) error {
 	s.httpServer = &http.Server{
-		Addr:           ":" + port,
+		Addr:           net.JoinHostPort(cfg.Addr, cfg.Port),
 		Handler:        handler,
-		MaxHeaderBytes: 1 << 20, // 1 MB
-		ReadTimeout:    100 * time.Second,
-		WriteTimeout:   100 * time.Second,
+		MaxHeaderBytes: cfg.MaxHeaderBytes,
+		ReadTimeout:    cfg.ReadTimeout,
+		WriteTimeout:   cfg.WriteTimeout,
+		IdleTimeout:    cfg.IdleTimeout,
 	}
 
-	return s.httpServer.ListenAndServe()
+	if !cfg.TLS.Enabled {
+		return s.httpServer.ListenAndServe()
+	}
+
+	tlsConfig, err := NewTLSConfig(cfg.TLS)
+	if err != nil {
+		return err
+	}
+	s.httpServer.TLSConfig = tlsConfig
+
+	errChan := make(chan error, 2)
+
+	if cfg.TLS.RedirectPort != "" {
+		s.redirectServer = &http.Server{
+			Addr:           net.JoinHostPort(cfg.Addr, cfg.TLS.RedirectPort),
+			Handler:        redirectHandler(cfg.Port),
+			MaxHeaderBytes: cfg.MaxHeaderBytes,
+			ReadTimeout:    cfg.ReadTimeout,
+			WriteTimeout:   cfg.WriteTimeout,
+			IdleTimeout:    cfg.IdleTimeout,
+		}
+
+		go func() {
+			errChan <- s.redirectServer.ListenAndServe()
+		}()
+	}
+
+	go func() {
+		// The certificate comes from TLSConfig.GetCertificate.
+		errChan <- s.httpServer.ListenAndServeTLS("", "")
+	}()
+
+	return <-errChan
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
-	return s.httpServer.Shutdown(ctx)
+	var err error
+
+	if s.redirectServer != nil {
+		err = s.redirectServer.Shutdown(ctx)
+	}
+
+	if shutdownErr := s.httpServer.Shutdown(ctx); shutdownErr != nil && err == nil {
+		err = shutdownErr
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// redirectHandler sends every request to the same host and path on the
+// HTTPS port.
+func redirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host, _, err := net.SplitHostPort(req.Host)
+		if err != nil {
+			host = req.Host
+		}
+
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		target := "https://" + host + req.URL.RequestURI()
+
+		http.Redirect(w, req, target, http.StatusPermanentRedirect)
+	})
 }
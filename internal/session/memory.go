@@ -0,0 +1,105 @@
+package session
+
+import (
+	"context"
+	"dev/profileSaver/internal/model"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps sessions in process memory, they are lost on restart.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[string]model.Session
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]model.Session)}
+}
+
+func (m *MemoryStore) Create(_ context.Context, s model.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions[s.ID] = s
+
+	return nil
+}
+
+func (m *MemoryStore) Get(_ context.Context, id string) (model.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s, ok := m.sessions[id]
+	if !ok {
+		return model.Session{}, ErrNotFound
+	}
+
+	return s, nil
+}
+
+func (m *MemoryStore) ListByUser(_ context.Context, userID string) ([]model.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var sessions []model.Session
+	for _, s := range m.sessions {
+		if s.UserID == userID {
+			sessions = append(sessions, s)
+		}
+	}
+
+	return sessions, nil
+}
+
+func (m *MemoryStore) Update(_ context.Context, s model.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessions[s.ID]; !ok {
+		return ErrNotFound
+	}
+
+	m.sessions[s.ID] = s
+
+	return nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sessions[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(m.sessions, id)
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteByUser(_ context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, s := range m.sessions {
+		if s.UserID == userID {
+			delete(m.sessions, id)
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) DeleteExpired(_ context.Context, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, s := range m.sessions {
+		if !now.Before(s.ExpiresAt) {
+			delete(m.sessions, id)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package session
+
+import (
+	"context"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RepositoryStore keeps sessions in the storage backend.
+type RepositoryStore struct {
+	repo repository.SessionRepository
+}
+
+func NewRepositoryStore(repo repository.SessionRepository) *RepositoryStore {
+	return &RepositoryStore{repo: repo}
+}
+
+// OpenStore returns the Store selected by cfg.Store.
+func OpenStore(cfg config.Session, repo repository.SessionRepository) (Store, error) {
+	switch cfg.Store {
+	case config.SessionStoreMemory, "":
+		return NewMemoryStore(), nil
+	case config.SessionStoreRepository:
+		return NewRepositoryStore(repo), nil
+	default:
+		return nil, fmt.Errorf("unsupported session store %q", cfg.Store)
+	}
+}
+
+func (r *RepositoryStore) Create(ctx context.Context, s model.Session) error {
+	return r.repo.CreateSession(ctx, s)
+}
+
+func (r *RepositoryStore) Get(ctx context.Context, id string) (model.Session, error) {
+	s, err := r.repo.GetSession(ctx, id)
+
+	return s, notFound(err)
+}
+
+func (r *RepositoryStore) ListByUser(ctx context.Context, userID string) ([]model.Session, error) {
+	return r.repo.GetSessionsByUser(ctx, userID), nil
+}
+
+func (r *RepositoryStore) Update(ctx context.Context, s model.Session) error {
+	return notFound(r.repo.UpdateSession(ctx, s))
+}
+
+func (r *RepositoryStore) Delete(ctx context.Context, id string) error {
+	return notFound(r.repo.DeleteSession(ctx, id))
+}
+
+func (r *RepositoryStore) DeleteByUser(ctx context.Context, userID string) error {
+	return r.repo.DeleteSessionsByUser(ctx, userID)
+}
+
+func (r *RepositoryStore) DeleteExpired(ctx context.Context, now time.Time) error {
+	return r.repo.DeleteExpiredSessions(ctx, now)
+}
+
+func notFound(err error) error {
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		return ErrNotFound
+	}
+
+	return err
+}
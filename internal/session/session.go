@@ -0,0 +1,230 @@
+// Package session issues and checks the cookie sessions created on login.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/model"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+)
+
+var ErrNotFound = errors.New("session not found")
+
+const (
+	DefaultTTL        = 24 * time.Hour
+	DefaultCookieName = "profile_session"
+)
+
+// touchInterval limits how often LastSeenAt is written back to the store.
+const touchInterval = time.Minute
+
+// Store keeps sessions by ID.
+type Store interface {
+	Create(ctx context.Context, s model.Session) error
+	Get(ctx context.Context, id string) (model.Session, error)
+	ListByUser(ctx context.Context, userID string) ([]model.Session, error)
+	Update(ctx context.Context, s model.Session) error
+	Delete(ctx context.Context, id string) error
+	DeleteByUser(ctx context.Context, userID string) error
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+// Manager creates sessions, resolves session cookies and revokes sessions.
+type Manager struct {
+	store Store
+	cfg   config.Session
+	now   func() time.Time
+}
+
+// NewManager returns a Manager over store. Zero TTL and cookie name fall back
+// to DefaultTTL and DefaultCookieName.
+func NewManager(store Store, cfg config.Session) *Manager {
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = DefaultCookieName
+	}
+
+	return &Manager{
+		store: store,
+		cfg:   cfg,
+		now:   time.Now,
+	}
+}
+
+// ID returns the session ID for a cookie token.
+func ID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Create starts a session for userID and returns the token for the cookie.
+func (m *Manager) Create(ctx context.Context, userID, ip, userAgent string) (string, model.Session, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", model.Session{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	now := m.now()
+	s := model.Session{
+		ID:         ID(token),
+		UserID:     userID,
+		IP:         ip,
+		UserAgent:  userAgent,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(m.cfg.TTL),
+	}
+
+	if err := m.store.Create(ctx, s); err != nil {
+		return "", model.Session{}, err
+	}
+
+	return token, s, nil
+}
+
+// Validate returns the live session for token. Expired and idle sessions are
+// deleted and reported as ErrNotFound.
+func (m *Manager) Validate(ctx context.Context, token string) (model.Session, error) {
+	s, err := m.store.Get(ctx, ID(token))
+	if err != nil {
+		return model.Session{}, err
+	}
+
+	now := m.now()
+	if !m.alive(s, now) {
+		_ = m.store.Delete(ctx, s.ID)
+		return model.Session{}, ErrNotFound
+	}
+
+	if now.Sub(s.LastSeenAt) >= touchInterval {
+		s.LastSeenAt = now
+		if err = m.store.Update(ctx, s); err != nil {
+			return model.Session{}, err
+		}
+	}
+
+	return s, nil
+}
+
+// List returns the live sessions of userID, most recently used first.
+func (m *Manager) List(ctx context.Context, userID string) ([]model.Session, error) {
+	all, err := m.store.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	sessions := make([]model.Session, 0, len(all))
+	for _, s := range all {
+		if m.alive(s, now) {
+			sessions = append(sessions, s)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+
+	return sessions, nil
+}
+
+// Revoke ends session id if it belongs to userID.
+func (m *Manager) Revoke(ctx context.Context, userID, id string) error {
+	s, err := m.store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if s.UserID != userID {
+		return ErrNotFound
+	}
+
+	return m.store.Delete(ctx, id)
+}
+
+// RevokeAll ends every session of userID except keep, which may be empty.
+func (m *Manager) RevokeAll(ctx context.Context, userID, keep string) error {
+	if keep == "" {
+		return m.store.DeleteByUser(ctx, userID)
+	}
+
+	sessions, err := m.store.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sessions {
+		if s.ID == keep {
+			continue
+		}
+		if err = m.store.Delete(ctx, s.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Prune deletes expired sessions. Idle sessions are left to Validate.
+func (m *Manager) Prune(ctx context.Context) error {
+	return m.store.DeleteExpired(ctx, m.now())
+}
+
+// CookieName is the name of the session cookie.
+func (m *Manager) CookieName() string {
+	return m.cfg.CookieName
+}
+
+// Cookie returns the session cookie carrying token.
+func (m *Manager) Cookie(token string, s model.Session) *http.Cookie {
+	return &http.Cookie{
+		Name:     m.cfg.CookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  s.ExpiresAt,
+		Secure:   m.cfg.CookieSecure,
+		HttpOnly: true,
+		SameSite: sameSite(m.cfg.SameSite),
+	}
+}
+
+// ClearCookie returns a cookie that removes the session cookie.
+func (m *Manager) ClearCookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     m.cfg.CookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   m.cfg.CookieSecure,
+		HttpOnly: true,
+		SameSite: sameSite(m.cfg.SameSite),
+	}
+}
+
+func (m *Manager) alive(s model.Session, now time.Time) bool {
+	if !now.Before(s.ExpiresAt) {
+		return false
+	}
+
+	return m.cfg.IdleTimeout <= 0 || now.Sub(s.LastSeenAt) < m.cfg.IdleTimeout
+}
+
+func sameSite(name string) http.SameSite {
+	switch name {
+	case config.SameSiteStrict:
+		return http.SameSiteStrictMode
+	case config.SameSiteNone:
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
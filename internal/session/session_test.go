@@ -0,0 +1,128 @@
+package session
+
+import (
+	"context"
+	"dev/profileSaver/internal/config"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func stores(t *testing.T) map[string]Store {
+	file, err := repository.NewFile(filepath.Join(t.TempDir(), "db.json"))
+	require.NoError(t, err)
+
+	return map[string]Store{
+		"MEMORY":     NewMemoryStore(),
+		"REPOSITORY": NewRepositoryStore(repository.New()),
+		"FILE":       NewRepositoryStore(file),
+	}
+}
+
+func TestManager_Validate(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			m := NewManager(store, config.Session{TTL: time.Hour, IdleTimeout: 10 * time.Minute})
+			m.now = func() time.Time { return now }
+
+			token, created, err := m.Create(ctx, "1", "10.0.0.1", "curl/8.0")
+			require.NoError(t, err)
+			assert.Equal(t, ID(token), created.ID)
+			assert.Equal(t, now.Add(time.Hour), created.ExpiresAt)
+
+			now = now.Add(5 * time.Minute)
+			s, err := m.Validate(ctx, token)
+			require.NoError(t, err)
+			assert.Equal(t, "10.0.0.1", s.IP)
+			assert.Equal(t, now, s.LastSeenAt)
+
+			_, err = m.Validate(ctx, "unknown")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			now = now.Add(10 * time.Minute)
+			_, err = m.Validate(ctx, token)
+			assert.ErrorIs(t, err, ErrNotFound, "idle session")
+
+			token, _, err = m.Create(ctx, "1", "", "")
+			require.NoError(t, err)
+
+			now = now.Add(time.Hour)
+			_, err = m.Validate(ctx, token)
+			assert.ErrorIs(t, err, ErrNotFound, "expired session")
+		})
+	}
+}
+
+func TestManager_Revoke(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			m := NewManager(store, config.Session{})
+
+			_, first, err := m.Create(ctx, "1", "", "")
+			require.NoError(t, err)
+			_, _, err = m.Create(ctx, "1", "", "")
+			require.NoError(t, err)
+			_, third, err := m.Create(ctx, "1", "", "")
+			require.NoError(t, err)
+			_, other, err := m.Create(ctx, "2", "", "")
+			require.NoError(t, err)
+
+			assert.ErrorIs(t, m.Revoke(ctx, "1", other.ID), ErrNotFound)
+			require.NoError(t, m.Revoke(ctx, "1", first.ID))
+			assert.ErrorIs(t, m.Revoke(ctx, "1", first.ID), ErrNotFound)
+
+			require.NoError(t, m.RevokeAll(ctx, "1", third.ID))
+			sessions, err := m.List(ctx, "1")
+			require.NoError(t, err)
+			require.Len(t, sessions, 1)
+			assert.Equal(t, third.ID, sessions[0].ID)
+
+			require.NoError(t, m.RevokeAll(ctx, "1", ""))
+			sessions, err = m.List(ctx, "1")
+			require.NoError(t, err)
+			assert.Empty(t, sessions)
+
+			sessions, err = m.List(ctx, "2")
+			require.NoError(t, err)
+			assert.Len(t, sessions, 1)
+		})
+	}
+}
+
+func TestManager_Prune(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Now()
+
+	m := NewManager(store, config.Session{TTL: time.Minute})
+	m.now = func() time.Time { return now }
+
+	_, s, err := m.Create(ctx, "1", "", "")
+	require.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, m.Prune(ctx))
+
+	_, err = store.Get(ctx, s.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestManager_Cookie(t *testing.T) {
+	m := NewManager(NewMemoryStore(), config.Session{CookieSecure: true, SameSite: config.SameSiteStrict})
+
+	c := m.Cookie("token", model.Session{})
+	assert.Equal(t, DefaultCookieName, c.Name)
+	assert.True(t, c.HttpOnly)
+	assert.True(t, c.Secure)
+	assert.Equal(t, http.SameSiteStrictMode, c.SameSite)
+	assert.Equal(t, -1, m.ClearCookie().MaxAge)
+}
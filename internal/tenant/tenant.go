@@ -0,0 +1,48 @@
+// Package tenant carries the tenant a request acts on. Users of the default
+// tenant, whose ID is empty, are the operators of the service; an admin of
+// the default tenant is a super-admin managing all tenants.
+package tenant
+
+import (
+	"context"
+	"regexp"
+)
+
+// Default is the ID of the default tenant.
+const Default = ""
+
+// Header names the tenant of a request when the path doesn't.
+const Header = "X-Tenant"
+
+// PathPrefix followed by a tenant ID, e.g. /t/acme/v1/user, names the tenant
+// of a request.
+const PathPrefix = "/t/"
+
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+type ctxKey struct{}
+
+// WithID returns a copy of ctx acting on tenant id.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the tenant of ctx, the default tenant when none is set.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+
+	return id
+}
+
+// Lookup is FromContext that also reports whether a tenant was set at all.
+func Lookup(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(ctxKey{}).(string)
+
+	return id, ok
+}
+
+// Valid reports whether id can name a tenant: up to 63 lowercase letters,
+// digits and dashes, not starting with a dash.
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
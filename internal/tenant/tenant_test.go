@@ -0,0 +1,44 @@
+package tenant
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+
+	id, ok := Lookup(ctx)
+	assert.False(t, ok)
+	assert.Equal(t, Default, id)
+	assert.Equal(t, Default, FromContext(ctx))
+
+	ctx = WithID(ctx, "acme")
+
+	id, ok = Lookup(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "acme", id)
+	assert.Equal(t, "acme", FromContext(ctx))
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		id       string
+		expected bool
+	}{
+		{name: "OK", id: "acme", expected: true},
+		{name: "DASHES_AND_DIGITS", id: "acme-2", expected: true},
+		{name: "EMPTY", id: "", expected: false},
+		{name: "UPPERCASE", id: "Acme", expected: false},
+		{name: "LEADING_DASH", id: "-acme", expected: false},
+		{name: "SLASH", id: "acme/x", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, Valid(test.id))
+		})
+	}
+}
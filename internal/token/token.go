@@ -0,0 +1,99 @@
+// Package token issues the single-use tokens sent to users by email.
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"dev/profileSaver/internal/model"
+	"dev/profileSaver/internal/repository"
+	"dev/profileSaver/internal/tenant"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const (
+	// PurposeReset marks password reset tokens.
+	PurposeReset = "reset"
+	// PurposeVerify marks email verification tokens.
+	PurposeVerify = "verify"
+	// PurposeInvite marks registration invite codes.
+	PurposeInvite = "invite"
+)
+
+var ErrInvalid = errors.New("invalid or expired token")
+
+type Manager struct {
+	repo repository.TokenRepository
+	now  func() time.Time
+}
+
+func NewManager(repo repository.TokenRepository) *Manager {
+	return &Manager{
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+// ID returns the stored ID of token.
+func ID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue creates a token for userID in the tenant of ctx, sent to email, valid
+// for ttl and returns it. Only its hash is stored.
+func (m *Manager) Issue(ctx context.Context, purpose, userID, email string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	now := m.now()
+	err := m.repo.CreateToken(ctx, model.Token{
+		ID:        ID(token),
+		Purpose:   purpose,
+		UserID:    userID,
+		Tenant:    tenant.FromContext(ctx),
+		Email:     email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Consume spends token and returns what it was issued for. Unknown, expired
+// and already used tokens are ErrInvalid.
+func (m *Manager) Consume(ctx context.Context, purpose, token string) (model.Token, error) {
+	t, err := m.repo.ConsumeToken(ctx, ID(token), purpose)
+	if errors.Is(err, repository.ErrTokenNotFound) {
+		return model.Token{}, ErrInvalid
+	}
+	if err != nil {
+		return model.Token{}, err
+	}
+
+	if !m.now().Before(t.ExpiresAt) {
+		return model.Token{}, ErrInvalid
+	}
+
+	return t, nil
+}
+
+// Revoke drops all tokens of userID issued for purpose.
+func (m *Manager) Revoke(ctx context.Context, purpose, userID string) error {
+	return m.repo.DeleteTokensByUser(ctx, userID, purpose)
+}
+
+// Prune deletes expired tokens.
+func (m *Manager) Prune(ctx context.Context) error {
+	return m.repo.DeleteExpiredTokens(ctx, m.now())
+}
@@ -0,0 +1,67 @@
+package token
+
+import (
+	"context"
+	"dev/profileSaver/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestManager_Consume(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	m := NewManager(repository.New())
+	m.now = func() time.Time { return now }
+
+	valid, err := m.Issue(ctx, PurposeReset, "1", "", time.Hour)
+	require.NoError(t, err)
+	expired, err := m.Issue(ctx, PurposeReset, "1", "", time.Minute)
+	require.NoError(t, err)
+	other, err := m.Issue(ctx, "other", "1", "", time.Hour)
+	require.NoError(t, err)
+
+	now = now.Add(time.Minute)
+
+	tests := []struct {
+		name        string
+		token       string
+		expectedErr error
+	}{
+		{name: "OK", token: valid, expectedErr: nil},
+		{name: "USED", token: valid, expectedErr: ErrInvalid},
+		{name: "EXPIRED", token: expired, expectedErr: ErrInvalid},
+		{name: "OTHER_PURPOSE", token: other, expectedErr: ErrInvalid},
+		{name: "UNKNOWN", token: "unknown", expectedErr: ErrInvalid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := m.Consume(ctx, PurposeReset, test.token)
+
+			assert.Equal(t, test.expectedErr, err)
+			if err == nil {
+				assert.Equal(t, "1", got.UserID)
+			}
+		})
+	}
+}
+
+func TestManager_Revoke(t *testing.T) {
+	ctx := context.Background()
+	m := NewManager(repository.New())
+
+	first, err := m.Issue(ctx, PurposeReset, "1", "", time.Hour)
+	require.NoError(t, err)
+	second, err := m.Issue(ctx, PurposeReset, "2", "", time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Revoke(ctx, PurposeReset, "1"))
+
+	_, err = m.Consume(ctx, PurposeReset, first)
+	assert.Equal(t, ErrInvalid, err)
+	_, err = m.Consume(ctx, PurposeReset, second)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,89 @@
+package totp
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcKey is the SHA1 seed from RFC 6238 appendix B.
+var rfcKey = []byte("12345678901234567890")
+
+func TestHOTP_RFC6238(t *testing.T) {
+	tests := []struct {
+		name     string
+		unix     int64
+		expected string
+	}{
+		{name: "59", unix: 59, expected: "94287082"},
+		{name: "1111111109", unix: 1111111109, expected: "07081804"},
+		{name: "1111111111", unix: 1111111111, expected: "14050471"},
+		{name: "1234567890", unix: 1234567890, expected: "89005924"},
+		{name: "2000000000", unix: 2000000000, expected: "69279037"},
+		{name: "20000000000", unix: 20000000000, expected: "65353130"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			step := Step(time.Unix(test.unix, 0))
+
+			assert.Equal(t, test.expected, hotp(rfcKey, uint64(step), 8))
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := encoding.EncodeToString(rfcKey)
+	now := time.Unix(1111111111, 0)
+	step := Step(now)
+
+	current, err := Code(secret, step)
+	require.NoError(t, err)
+	previous, err := Code(secret, step-1)
+	require.NoError(t, err)
+	old, err := Code(secret, step-2)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		ok       bool
+	}{
+		{name: "OK", code: current, ok: true},
+		{name: "SKEW", code: previous, ok: true},
+		{name: "TOO_OLD", code: old, ok: false},
+		{name: "REPLAY", code: current, lastStep: step, ok: false},
+		{name: "WRONG", code: "000000", ok: current == "000000"},
+		{name: "LENGTH", code: "12345", ok: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, ok := Validate(secret, test.code, now, test.lastStep)
+
+			assert.Equal(t, test.ok, ok)
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("profile Saver", "bob", "ABC")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/profile%20Saver:bob?"))
+	assert.Contains(t, uri, "secret=ABC")
+	assert.Contains(t, uri, "issuer=profile+Saver")
+	assert.Contains(t, uri, "digits=6")
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := RecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	for _, code := range codes {
+		assert.Regexp(t, `^[a-z2-7]{5}-[a-z2-7]{5}$`, code)
+	}
+}